@@ -0,0 +1,488 @@
+// Package agg aggregates classification results on the device, and
+// periodically syncs summaries to an HTTP endpoint, so devices do not have to
+// send every result, e.g. over a metered or intermittent connection.
+//
+// A summary counts, per label, the results in a time bucket in which the
+// label scored at or above a threshold, and holds notable events: results
+// for configured labels, with an optional snapshot of the input, like a JPEG
+// image. Summaries that could not be synced are kept, optionally in a spool
+// directory so they survive restarts, and retried at the next sync.
+//
+// Summaries are POSTed as a JSON array of Summary.
+package agg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/location"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sink"
+)
+
+// Summary aggregates the results of a time bucket.
+type Summary struct {
+	Start   time.Time      `json:"start"`
+	End     time.Time      `json:"end"`
+	Source  string         `json:"source,omitempty"`
+	Results int            `json:"results"` // Number of results in the bucket.
+	Counts  map[string]int `json:"counts"`  // Per label, results in which the label scored at or above the threshold.
+	Events  []Event        `json:"events,omitempty"`
+}
+
+// Event is a notable result.
+type Event struct {
+	Time     time.Time     `json:"time"`
+	Label    string        `json:"label"`
+	Score    float64       `json:"score"`
+	Location *location.Fix `json:"location,omitempty"`
+
+	// Snapshot of the input, e.g. a JPEG image or WAV audio, base64 in JSON.
+	Snapshot    []byte `json:"snapshot,omitempty"`
+	ContentType string `json:"contentType,omitempty"` // Of Snapshot, e.g. image/jpeg.
+}
+
+// Opts are options for an Aggregator.
+type Opts struct {
+	// Endpoint summaries are POSTed to. If empty, summaries are only kept,
+	// and returned by Pending.
+	URL string
+
+	// Additional request headers, e.g. for authentication.
+	Header map[string]string
+
+	// If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// Duration of a bucket. Defaults to a minute.
+	Bucket time.Duration
+
+	// How often summaries are synced. Defaults to 5 minutes.
+	SyncInterval time.Duration
+
+	// Minimum score for a label to be counted. Defaults to 0.5.
+	Threshold float64
+
+	// Labels for which results at or above Threshold are notable events.
+	Notable []string
+
+	// Maximum number of events per summary, later events are only counted.
+	// Defaults to 10.
+	MaxEvents int
+
+	// Directory where unsynced summaries are stored, and loaded from on
+	// start. If empty, unsynced summaries are kept in memory only.
+	SpoolDir string
+
+	// Maximum number of unsynced summaries kept, the oldest are dropped
+	// first. Defaults to 1440, a day of one minute buckets.
+	MaxPending int
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For closing buckets. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures an aggregator created with New. A *Opts is also an
+// Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *Opts)
+}
+
+type optionFunc func(o *Opts)
+
+func (fn optionFunc) apply(o *Opts) {
+	fn(o)
+}
+
+func (opts *Opts) apply(o *Opts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithURL sets Opts.URL.
+func WithURL(url string) Option {
+	return optionFunc(func(o *Opts) { o.URL = url })
+}
+
+// WithHeader sets Opts.Header.
+func WithHeader(header map[string]string) Option {
+	return optionFunc(func(o *Opts) { o.Header = header })
+}
+
+// WithClient sets Opts.Client.
+func WithClient(client *http.Client) Option {
+	return optionFunc(func(o *Opts) { o.Client = client })
+}
+
+// WithBucket sets Opts.Bucket.
+func WithBucket(d time.Duration) Option {
+	return optionFunc(func(o *Opts) { o.Bucket = d })
+}
+
+// WithSyncInterval sets Opts.SyncInterval.
+func WithSyncInterval(d time.Duration) Option {
+	return optionFunc(func(o *Opts) { o.SyncInterval = d })
+}
+
+// WithThreshold sets Opts.Threshold.
+func WithThreshold(threshold float64) Option {
+	return optionFunc(func(o *Opts) { o.Threshold = threshold })
+}
+
+// WithNotable sets Opts.Notable.
+func WithNotable(labels ...string) Option {
+	return optionFunc(func(o *Opts) { o.Notable = labels })
+}
+
+// WithSpoolDir sets Opts.SpoolDir.
+func WithSpoolDir(dir string) Option {
+	return optionFunc(func(o *Opts) { o.SpoolDir = dir })
+}
+
+// WithVerbose sets Opts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *Opts) { o.Verbose = verbose })
+}
+
+// WithLogger sets Opts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *Opts) { o.Logger = logger })
+}
+
+// WithClock sets Opts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *Opts) { o.Clock = clock })
+}
+
+// pending is a closed summary waiting to be synced.
+type pending struct {
+	id      int64
+	summary Summary
+	path    string // File in the spool directory, if any.
+}
+
+// Aggregator aggregates results into summaries. Aggregator is a sink.Sink.
+type Aggregator struct {
+	opts    Opts
+	logger  edgeimpulse.Logger
+	clock   edgeimpulse.Clock
+	notable map[string]bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mutex   sync.Mutex
+	buckets map[time.Time]*Summary // Open buckets, by start.
+	pending []pending
+	lastID  int64
+
+	syncMutex sync.Mutex // Serializes syncs.
+}
+
+// Ensure that Aggregator implements interface Sink.
+var _ sink.Sink = (*Aggregator)(nil)
+
+// New returns an aggregator, loading unsynced summaries from the spool
+// directory, if any, and starts syncing.
+//
+// Callers must call Close, which closes the open buckets and syncs one last
+// time.
+func New(opts ...Option) (*Aggregator, error) {
+	a := &Aggregator{buckets: map[time.Time]*Summary{}, notable: map[string]bool{}, done: make(chan struct{})}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&a.opts)
+		}
+	}
+	if a.opts.Client == nil {
+		a.opts.Client = http.DefaultClient
+	}
+	if a.opts.Bucket <= 0 {
+		a.opts.Bucket = time.Minute
+	}
+	if a.opts.SyncInterval <= 0 {
+		a.opts.SyncInterval = 5 * time.Minute
+	}
+	if a.opts.Threshold == 0 {
+		a.opts.Threshold = 0.5
+	}
+	if a.opts.MaxEvents == 0 {
+		a.opts.MaxEvents = 10
+	}
+	if a.opts.MaxPending == 0 {
+		a.opts.MaxPending = 1440
+	}
+	for _, l := range a.opts.Notable {
+		a.notable[l] = true
+	}
+	a.logger = edgeimpulse.DefaultLogger(a.opts.Logger, a.opts.Verbose)
+	a.clock = edgeimpulse.DefaultClock(a.opts.Clock)
+
+	if a.opts.SpoolDir != "" {
+		if err := os.MkdirAll(a.opts.SpoolDir, 0755); err != nil {
+			return nil, fmt.Errorf("making spool dir: %w", err)
+		}
+		if err := a.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+	go func() {
+		defer close(a.done)
+		t := time.NewTicker(a.opts.SyncInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := a.Sync(ctx); err != nil {
+					a.logger.Logf(edgeimpulse.LogDebug, "syncing summaries, will retry: %v", err)
+				}
+			}
+		}
+	}()
+	return a, nil
+}
+
+// load reads unsynced summaries from the spool directory.
+func (a *Aggregator) load() error {
+	paths, err := filepath.Glob(filepath.Join(a.opts.SpoolDir, "summary-*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		buf, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("reading spooled summary: %w", err)
+		}
+		var s Summary
+		if err := json.Unmarshal(buf, &s); err != nil {
+			a.logger.Logf(edgeimpulse.LogError, "removing bad spooled summary %s: %v", p, err)
+			os.Remove(p)
+			continue
+		}
+		a.lastID++
+		a.pending = append(a.pending, pending{a.lastID, s, p})
+	}
+	return nil
+}
+
+// Send adds a result, without snapshot. Send implements sink.Sink.
+func (a *Aggregator) Send(ctx context.Context, r sink.Result) error {
+	a.Add(r, nil)
+	return nil
+}
+
+// Add adds a result to the summary of its bucket. If the result is notable,
+// snapshot, if not nil, is called for a snapshot of the input and its content
+// type.
+func (a *Aggregator) Add(r sink.Result, snapshot func() ([]byte, string)) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	start := r.Time.Truncate(a.opts.Bucket)
+	s := a.buckets[start]
+	if s == nil {
+		s = &Summary{Start: start, End: start.Add(a.opts.Bucket), Source: r.Source, Counts: map[string]int{}}
+		a.buckets[start] = s
+	}
+	s.Results++
+
+	scores := map[string]float64{}
+	for l, v := range r.Response.Result.Classification {
+		scores[l] = v
+	}
+	for _, b := range r.Response.Result.BoundingBoxes {
+		if b.Value > scores[b.Label] {
+			scores[b.Label] = b.Value
+		}
+	}
+	labels := make([]string, 0, len(scores))
+	for l := range scores {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+	for _, l := range labels {
+		v := scores[l]
+		if v < a.opts.Threshold {
+			continue
+		}
+		s.Counts[l]++
+		if !a.notable[l] || len(s.Events) >= a.opts.MaxEvents {
+			continue
+		}
+		ev := Event{Time: r.Time, Label: l, Score: v, Location: r.Location}
+		if snapshot != nil {
+			ev.Snapshot, ev.ContentType = snapshot()
+			// One snapshot of the input is enough.
+			snapshot = nil
+		}
+		s.Events = append(s.Events, ev)
+	}
+
+	a.closeBuckets(a.clock.Now())
+}
+
+// closeBuckets moves buckets that ended at or before now to pending. Must be
+// called with mutex held.
+func (a *Aggregator) closeBuckets(now time.Time) {
+	var closed []*Summary
+	for start, s := range a.buckets {
+		if !s.End.After(now) {
+			closed = append(closed, s)
+			delete(a.buckets, start)
+		}
+	}
+	sort.Slice(closed, func(i, j int) bool { return closed[i].Start.Before(closed[j].Start) })
+	for _, s := range closed {
+		a.lastID++
+		p := pending{id: a.lastID, summary: *s}
+		if a.opts.SpoolDir != "" {
+			p.path = filepath.Join(a.opts.SpoolDir, fmt.Sprintf("summary-%020d.json", s.Start.UnixNano()))
+			buf, err := json.Marshal(s)
+			if err == nil {
+				err = os.WriteFile(p.path, buf, 0644)
+			}
+			if err != nil {
+				a.logger.Logf(edgeimpulse.LogError, "spooling summary: %v", err)
+				p.path = ""
+			}
+		}
+		a.pending = append(a.pending, p)
+	}
+	if n := len(a.pending) - a.opts.MaxPending; n > 0 {
+		a.logger.Logf(edgeimpulse.LogError, "dropping %d oldest unsynced summaries", n)
+		for _, p := range a.pending[:n] {
+			if p.path != "" {
+				os.Remove(p.path)
+			}
+		}
+		a.pending = append([]pending(nil), a.pending[n:]...)
+	}
+}
+
+// Pending returns the summaries waiting to be synced, oldest first, after
+// closing buckets that have ended.
+func (a *Aggregator) Pending() []Summary {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.closeBuckets(a.clock.Now())
+	l := make([]Summary, len(a.pending))
+	for i, p := range a.pending {
+		l[i] = p.summary
+	}
+	return l
+}
+
+// Sync closes buckets that have ended, and POSTs pending summaries to the
+// URL. Summaries are removed once the endpoint accepts them with a 2xx
+// response. Without URL, Sync does nothing.
+func (a *Aggregator) Sync(ctx context.Context) error {
+	if a.opts.URL == "" {
+		return nil
+	}
+	a.syncMutex.Lock()
+	defer a.syncMutex.Unlock()
+
+	a.mutex.Lock()
+	a.closeBuckets(a.clock.Now())
+	l := append([]pending(nil), a.pending...)
+	a.mutex.Unlock()
+	if len(l) == 0 {
+		return nil
+	}
+
+	summaries := make([]Summary, len(l))
+	for i, p := range l {
+		summaries[i] = p.summary
+	}
+	buf, err := json.Marshal(summaries)
+	if err != nil {
+		return fmt.Errorf("marshal summaries: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", a.opts.URL, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("new HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range a.opts.Header {
+		req.Header.Set(k, v)
+	}
+	resp, err := a.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("http response error: %s", resp.Status)
+	}
+	a.logger.Logf(edgeimpulse.LogDebug, "synced %d summaries", len(l))
+
+	// Remove the synced summaries. Summaries may have been dropped or
+	// added meanwhile.
+	synced := map[int64]bool{}
+	for _, p := range l {
+		synced[p.id] = true
+		if p.path != "" {
+			os.Remove(p.path)
+		}
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	var keep []pending
+	for _, p := range a.pending {
+		if !synced[p.id] {
+			keep = append(keep, p)
+		}
+	}
+	a.pending = keep
+	return nil
+}
+
+// Close closes all buckets, including the current one, and syncs one last
+// time, giving up after 10 seconds. Unsynced summaries stay in the spool
+// directory.
+func (a *Aggregator) Close() error {
+	a.cancel()
+	<-a.done
+	a.mutex.Lock()
+	var last time.Time
+	for _, s := range a.buckets {
+		if s.End.After(last) {
+			last = s.End
+		}
+	}
+	a.closeBuckets(last)
+	a.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.Sync(ctx); err != nil {
+		if a.opts.SpoolDir != "" {
+			a.logger.Logf(edgeimpulse.LogInfo, "summaries not synced, kept in %s: %v", a.opts.SpoolDir, err)
+			return nil
+		}
+		return fmt.Errorf("syncing summaries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package agg_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/agg"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sink"
+)
+
+func result(t time.Time, scores map[string]float64) sink.Result {
+	var resp edgeimpulse.RunnerClassifyResponse
+	resp.Result.Classification = scores
+	return sink.Result{Time: t, Source: "test", Response: resp}
+}
+
+func TestAggregator(t *testing.T) {
+	var mutex sync.Mutex
+	var received []agg.Summary
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if fail {
+			http.Error(w, "offline", http.StatusServiceUnavailable)
+			return
+		}
+		var l []agg.Summary
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = append(received, l...)
+	}))
+	defer srv.Close()
+
+	t0 := time.Unix(6000, 0) // Start of a minute.
+	clock := edgeimpulse.NewManualClock(t0)
+	dir := t.TempDir()
+	opts := []agg.Option{
+		agg.WithURL(srv.URL),
+		agg.WithSyncInterval(time.Hour),
+		agg.WithNotable("person"),
+		agg.WithSpoolDir(dir),
+		agg.WithClock(clock),
+	}
+	a, err := agg.New(opts...)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	snapshots := 0
+	snapshot := func() ([]byte, string) {
+		snapshots++
+		return []byte("jpeg"), "image/jpeg"
+	}
+	a.Add(result(t0, map[string]float64{"person": 0.9, "car": 0.1}), snapshot)
+	a.Add(result(t0.Add(10*time.Second), map[string]float64{"person": 0.2, "car": 0.7}), snapshot)
+	clock.Set(t0.Add(70 * time.Second))
+	a.Add(result(t0.Add(70*time.Second), map[string]float64{"person": 0.6}), nil)
+
+	l := a.Pending()
+	if len(l) != 1 {
+		t.Fatalf("got %d pending summaries, expected 1", len(l))
+	}
+	s := l[0]
+	if s.Results != 2 || s.Counts["person"] != 1 || s.Counts["car"] != 1 {
+		t.Errorf("got %+v, expected 2 results with 1 person and 1 car", s)
+	}
+	if len(s.Events) != 1 || string(s.Events[0].Snapshot) != "jpeg" || snapshots != 1 {
+		t.Errorf("got events %+v after %d snapshots, expected 1 person with snapshot", s.Events, snapshots)
+	}
+
+	// Offline, the summary is kept, and survives a restart.
+	if err := a.Sync(context.Background()); err == nil {
+		t.Fatalf("sync: expected error while offline")
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("close with spool dir: %v", err)
+	}
+	a, err = agg.New(opts...)
+	if err != nil {
+		t.Fatalf("new after restart: %v", err)
+	}
+	if n := len(a.Pending()); n != 2 {
+		t.Fatalf("got %d pending summaries after restart, expected 2", n)
+	}
+
+	mutex.Lock()
+	fail = false
+	mutex.Unlock()
+	if err := a.Sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if n := len(a.Pending()); n != 0 {
+		t.Errorf("got %d pending summaries after sync, expected 0", n)
+	}
+	mutex.Lock()
+	if len(received) != 2 || received[1].Counts["person"] != 1 {
+		t.Errorf("got %+v, expected 2 summaries", received)
+	}
+	mutex.Unlock()
+	a.Close()
+}
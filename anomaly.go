@@ -0,0 +1,140 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"time"
+)
+
+// AnomalyLevel is the alert level of an anomaly score.
+type AnomalyLevel int
+
+// Anomaly levels, from least to most severe.
+const (
+	AnomalyNormal AnomalyLevel = iota
+	AnomalyWarning
+	AnomalyCritical
+)
+
+// String returns the name of the level.
+func (l AnomalyLevel) String() string {
+	switch l {
+	case AnomalyNormal:
+		return "normal"
+	case AnomalyWarning:
+		return "warning"
+	case AnomalyCritical:
+		return "critical"
+	}
+	return fmt.Sprintf("level %d", int(l))
+}
+
+// AnomalyAlert is a change of alert level, returned by AnomalyFilter.Update.
+type AnomalyAlert struct {
+	Level    AnomalyLevel
+	Previous AnomalyLevel
+	Score    float64   // Anomaly score causing the change.
+	Baseline float64   // Baseline at the time of the change, 0 without baseline tracking.
+	Since    time.Time // Start of the period the new level was sustained.
+	Time     time.Time // Time of the update causing the change.
+}
+
+// AnomalyFilterOpts are options for an AnomalyFilter.
+type AnomalyFilterOpts struct {
+	// Thresholds for the warning and critical levels. With baseline
+	// tracking, thresholds apply to the score minus the baseline.
+	Warning  float64
+	Critical float64
+
+	// How long a score must remain at a new level before changing to that
+	// level. Zero changes level immediately.
+	Sustain time.Duration
+
+	// Weight of a new score in the exponential moving average tracking the
+	// baseline, between 0 and 1. The baseline is only updated while the
+	// level is normal. If zero, no baseline is tracked and thresholds apply
+	// to scores directly.
+	BaselineAlpha float64
+}
+
+// AnomalyFilter turns anomaly scores into alerts with a warning and critical
+// level, requiring levels to be sustained for a while before alerting, and
+// optionally tracking a baseline for slowly drifting scores.
+type AnomalyFilter struct {
+	opts AnomalyFilterOpts
+
+	level     AnomalyLevel
+	baseline  float64
+	haveBase  bool
+	candidate AnomalyLevel // Level the scores currently indicate.
+	since     time.Time    // Start of candidate level.
+}
+
+// NewAnomalyFilter returns a new anomaly filter, starting at the normal level.
+func NewAnomalyFilter(opts AnomalyFilterOpts) (*AnomalyFilter, error) {
+	if opts.Critical < opts.Warning {
+		return nil, fmt.Errorf("critical threshold must be >= warning threshold")
+	}
+	if opts.BaselineAlpha < 0 || opts.BaselineAlpha > 1 {
+		return nil, fmt.Errorf("baseline alpha must be between 0 and 1")
+	}
+	return &AnomalyFilter{opts: opts}, nil
+}
+
+// Level returns the current alert level.
+func (f *AnomalyFilter) Level() AnomalyLevel {
+	return f.level
+}
+
+// Baseline returns the current baseline, 0 if no baseline is tracked.
+func (f *AnomalyFilter) Baseline() float64 {
+	return f.baseline
+}
+
+// Update adds an anomaly score observed at time t, and returns an alert if the
+// level changed, nil otherwise.
+func (f *AnomalyFilter) Update(score float64, t time.Time) *AnomalyAlert {
+	v := score
+	if f.opts.BaselineAlpha > 0 {
+		if !f.haveBase {
+			f.baseline = score
+			f.haveBase = true
+		}
+		v -= f.baseline
+	}
+
+	level := AnomalyNormal
+	if v >= f.opts.Critical {
+		level = AnomalyCritical
+	} else if v >= f.opts.Warning {
+		level = AnomalyWarning
+	}
+
+	if level != f.candidate {
+		f.candidate = level
+		f.since = t
+	}
+
+	var alert *AnomalyAlert
+	if f.candidate != f.level && t.Sub(f.since) >= f.opts.Sustain {
+		alert = &AnomalyAlert{
+			Level:    f.candidate,
+			Previous: f.level,
+			Score:    score,
+			Baseline: f.baseline,
+			Since:    f.since,
+			Time:     t,
+		}
+		f.level = f.candidate
+	}
+
+	if f.opts.BaselineAlpha > 0 && f.level == AnomalyNormal && level == AnomalyNormal {
+		f.baseline = f.opts.BaselineAlpha*score + (1-f.opts.BaselineAlpha)*f.baseline
+	}
+	return alert
+}
+
+// UpdateResponse adds the anomaly score of a classify response observed at time
+// t, see Update.
+func (f *AnomalyFilter) UpdateResponse(resp RunnerClassifyResponse, t time.Time) *AnomalyAlert {
+	return f.Update(resp.Result.Anomaly, t)
+}
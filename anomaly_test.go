@@ -0,0 +1,52 @@
+package edgeimpulse_test
+
+import (
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestAnomalyFilter(t *testing.T) {
+	_, err := edgeimpulse.NewAnomalyFilter(edgeimpulse.AnomalyFilterOpts{Warning: 2, Critical: 1})
+	if err == nil {
+		t.Fatalf("missing error for critical below warning")
+	}
+
+	f, err := edgeimpulse.NewAnomalyFilter(edgeimpulse.AnomalyFilterOpts{Warning: 1, Critical: 2, Sustain: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("new anomaly filter: %v", err)
+	}
+
+	t0 := time.Now()
+	update := func(score float64, secs int, exp edgeimpulse.AnomalyLevel, expAlert bool) {
+		t.Helper()
+		alert := f.Update(score, t0.Add(time.Duration(secs)*time.Second))
+		if (alert != nil) != expAlert || f.Level() != exp {
+			t.Fatalf("update %v at %ds, got alert %v level %v, expected alert %v level %v", score, secs, alert, f.Level(), expAlert, exp)
+		}
+	}
+
+	update(0.5, 0, edgeimpulse.AnomalyNormal, false)
+	update(1.5, 1, edgeimpulse.AnomalyNormal, false)
+	update(0.5, 2, edgeimpulse.AnomalyNormal, false) // Not sustained.
+	update(1.5, 3, edgeimpulse.AnomalyNormal, false)
+	update(1.5, 4, edgeimpulse.AnomalyNormal, false)
+	update(1.5, 5, edgeimpulse.AnomalyWarning, true)
+	update(2.5, 6, edgeimpulse.AnomalyWarning, false)
+	update(2.5, 8, edgeimpulse.AnomalyCritical, true)
+	update(0, 9, edgeimpulse.AnomalyCritical, false)
+	update(0, 11, edgeimpulse.AnomalyNormal, true)
+
+	// With baseline, thresholds are relative to the tracked baseline.
+	f, err = edgeimpulse.NewAnomalyFilter(edgeimpulse.AnomalyFilterOpts{Warning: 1, Critical: 2, BaselineAlpha: 0.5})
+	if err != nil {
+		t.Fatalf("new anomaly filter: %v", err)
+	}
+	update(10, 0, edgeimpulse.AnomalyNormal, false)
+	update(10.5, 1, edgeimpulse.AnomalyNormal, false)
+	update(11.5, 2, edgeimpulse.AnomalyWarning, true)
+	if f.Baseline() != 10.25 {
+		t.Fatalf("unexpected baseline %v", f.Baseline())
+	}
+}
@@ -0,0 +1,149 @@
+package edgeimpulse
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// ErrModelArch is returned, wrapped, when a model file is built for another
+// operating system or architecture than the one we are running on.
+var ErrModelArch = errors.New("model for wrong platform")
+
+// archNames maps GOARCH values to the names used in model deployments.
+var archNames = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+	"arm":   "armv7",
+	"386":   "x86",
+}
+
+// compatArchs lists the architectures whose executables also run on an
+// architecture, per OS: 32 bit executables on 64 bit Linux, and Intel
+// executables on Apple silicon with Rosetta 2.
+var compatArchs = map[string]map[string][]string{
+	"linux": {
+		"amd64": {"386"},
+		"arm64": {"arm"},
+	},
+	"darwin": {
+		"arm64": {"amd64"},
+	},
+}
+
+// elfOSs maps the OS ABI of ELF files to GOOS values. Linux executables
+// typically have the System V ABI.
+var elfOSs = map[elf.OSABI]string{
+	elf.ELFOSABI_NONE:    "linux",
+	elf.ELFOSABI_LINUX:   "linux",
+	elf.ELFOSABI_FREEBSD: "freebsd",
+	elf.ELFOSABI_NETBSD:  "netbsd",
+	elf.ELFOSABI_OPENBSD: "openbsd",
+	elf.ELFOSABI_SOLARIS: "solaris",
+}
+
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:  "amd64",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_ARM:     "arm",
+	elf.EM_386:     "386",
+}
+
+var machoArchs = map[macho.Cpu]string{
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm64: "arm64",
+	macho.CpuArm:   "arm",
+	macho.Cpu386:   "386",
+}
+
+// CheckModelArch returns an error wrapping ErrModelArch if the model file at
+// path is an ELF or Mach-O executable for another OS or architecture than the
+// current one. Executables for architectures the current one can run, e.g.
+// 32 bit ARM on arm64 Linux, are accepted. Files in other formats, and unknown
+// OSs and architectures, are not checked. NewRunnerProcess calls
+// CheckModelArch before starting a model, unless RunnerOpts.SkipArchCheck is
+// set.
+func CheckModelArch(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var goos string
+	var goarchs []string
+	if ef, err := elf.NewFile(f); err == nil {
+		goos = elfOSs[ef.OSABI]
+		if arch, ok := elfArchs[ef.Machine]; ok {
+			goarchs = []string{arch}
+		}
+	} else if mf, err := macho.NewFile(f); err == nil {
+		goos = "darwin"
+		if arch, ok := machoArchs[mf.Cpu]; ok {
+			goarchs = []string{arch}
+		}
+	} else if ff, err := macho.NewFatFile(f); err == nil {
+		goos = "darwin"
+		for _, a := range ff.Arches {
+			if arch, ok := machoArchs[a.Cpu]; ok {
+				goarchs = append(goarchs, arch)
+			}
+		}
+	} else {
+		return nil
+	}
+	if goos == "" || len(goarchs) == 0 {
+		return nil
+	}
+
+	if goos == runtime.GOOS {
+		for _, arch := range goarchs {
+			if archRuns(goos, arch, runtime.GOARCH) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: model built for %s on %s, running on %s on %s, download the %s deployment", ErrModelArch, archName(goarchs[0]), osName(goos), archName(runtime.GOARCH), osName(runtime.GOOS), deploymentName(runtime.GOOS, runtime.GOARCH))
+}
+
+// archRuns returns whether executables for arch run on host on goos.
+func archRuns(goos, arch, host string) bool {
+	if arch == host {
+		return true
+	}
+	for _, a := range compatArchs[goos][host] {
+		if a == arch {
+			return true
+		}
+	}
+	return false
+}
+
+func archName(goarch string) string {
+	if s, ok := archNames[goarch]; ok {
+		return s
+	}
+	return goarch
+}
+
+func osName(goos string) string {
+	if goos == "darwin" {
+		return "macos"
+	}
+	return goos
+}
+
+// deploymentName returns the name of the model deployment for an OS and
+// architecture, e.g. linux-x86_64 or mac-arm64.
+func deploymentName(goos, goarch string) string {
+	if goos == "darwin" {
+		if goarch == "arm64" {
+			return "mac-arm64"
+		}
+		return "mac-" + archName(goarch)
+	}
+	return goos + "-" + archName(goarch)
+}
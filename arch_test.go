@@ -0,0 +1,82 @@
+package edgeimpulse_test
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestCheckModelArch(t *testing.T) {
+	// The test binary is built for the current platform.
+	if err := edgeimpulse.CheckModelArch(os.Args[0]); err != nil {
+		t.Fatalf("checking test binary: %v", err)
+	}
+
+	dir := t.TempDir()
+	write := func(name string, buf []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf, 0755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if err := edgeimpulse.CheckModelArch(write("script", []byte("#!/bin/sh\n"))); err != nil {
+		t.Fatalf("checking script: %v", err)
+	}
+
+	machine := elf.EM_AARCH64
+	if runtime.GOOS == "linux" && runtime.GOARCH == "arm64" {
+		machine = elf.EM_X86_64
+	}
+	foreign := write("model.eim", elfHeader(t, elf.ELFCLASS64, machine, elf.ELFOSABI_NONE))
+	if err := edgeimpulse.CheckModelArch(foreign); !errors.Is(err, edgeimpulse.ErrModelArch) {
+		t.Fatalf("got error %v, expected ErrModelArch", err)
+	}
+	if _, err := edgeimpulse.NewRunnerProcess(foreign, edgeimpulse.WithSkipArchCheck(true)); err == nil || errors.Is(err, edgeimpulse.ErrModelArch) {
+		t.Fatalf("got error %v with SkipArchCheck, expected other error", err)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	native := map[string]elf.Machine{"amd64": elf.EM_X86_64, "arm64": elf.EM_AARCH64}[runtime.GOARCH]
+	compat := map[string]elf.Machine{"amd64": elf.EM_386, "arm64": elf.EM_ARM}[runtime.GOARCH]
+	if native == 0 {
+		return
+	}
+	if err := edgeimpulse.CheckModelArch(write("freebsd.eim", elfHeader(t, elf.ELFCLASS64, native, elf.ELFOSABI_FREEBSD))); !errors.Is(err, edgeimpulse.ErrModelArch) {
+		t.Fatalf("got error %v for freebsd model, expected ErrModelArch", err)
+	}
+	if err := edgeimpulse.CheckModelArch(write("compat.eim", elfHeader(t, elf.ELFCLASS32, compat, elf.ELFOSABI_LINUX))); err != nil {
+		t.Fatalf("checking 32 bit model: %v", err)
+	}
+}
+
+// elfHeader returns the header of an ELF executable.
+func elfHeader(t *testing.T, class elf.Class, machine elf.Machine, osabi elf.OSABI) []byte {
+	var ident [elf.EI_NIDENT]byte
+	copy(ident[:], elf.ELFMAG)
+	ident[elf.EI_CLASS] = byte(class)
+	ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	ident[elf.EI_OSABI] = byte(osabi)
+	var hdr interface{}
+	if class == elf.ELFCLASS64 {
+		hdr = elf.Header64{Ident: ident, Type: uint16(elf.ET_EXEC), Machine: uint16(machine), Version: uint32(elf.EV_CURRENT), Ehsize: 64, Phentsize: 56, Shentsize: 64}
+	} else {
+		hdr = elf.Header32{Ident: ident, Type: uint16(elf.ET_EXEC), Machine: uint16(machine), Version: uint32(elf.EV_CURRENT), Ehsize: 52, Phentsize: 32, Shentsize: 40}
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, hdr); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
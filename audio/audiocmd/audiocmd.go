@@ -8,17 +8,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"os/exec"
 	"regexp"
 	"runtime"
 	"strings"
 
-	"github.com/edgeimpulse/linux-sdk-go/audio"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio"
 )
 
-var errSoxInstallHint = errors.New("sox executable not found, install with: sudo apt install -y sox")
+var errSoxInstallHint = fmt.Errorf("sox %w, install with: sudo apt install -y sox", exec.ErrNotFound)
 
 // RecorderOpts holds option for a Recorder.
 type RecorderOpts struct {
@@ -34,6 +34,58 @@ type RecorderOpts struct {
 	AudioType      string
 	AsRaw          bool
 	DeviceID       string
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+}
+
+// Option configures a recorder created with NewRecorder. A *RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts *RecorderOpts) apply(o *RecorderOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithDevice sets RecorderOpts.DeviceID.
+func WithDevice(id string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.DeviceID = id })
+}
+
+// WithSampleRate sets RecorderOpts.SampleRate.
+func WithSampleRate(rate int) Option {
+	return optionFunc(func(o *RecorderOpts) { o.SampleRate = rate })
+}
+
+// WithChannels sets RecorderOpts.Channels.
+func WithChannels(channels int) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Channels = channels })
+}
+
+// WithRecordProgram sets RecorderOpts.RecordProgram.
+func WithRecordProgram(program string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.RecordProgram = program })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
 }
 
 // recorderOptsDefault has default option values for a Recorder.
@@ -90,6 +142,36 @@ func ListDevices() ([]audio.Device, error) {
 	return r, nil
 }
 
+// checkDevice returns an error wrapping audio.ErrDeviceNotFound if id is not in
+// the list of devices. Only IDs in the form returned by ListDevices are
+// checked: numeric ALSA hw:card,device on Linux and any name on macOS. Other
+// IDs, like ALSA plugin names, are passed to the recording program as is.
+func checkDevice(id string) error {
+	if runtime.GOOS != "darwin" && !hwRegexp.MatchString(id) {
+		return nil
+	}
+	devices, err := ListDevices()
+	if err != nil {
+		// Let the recording program report problems.
+		return nil
+	}
+	var cards []string
+	for _, d := range devices {
+		if d.ID == "" {
+			// Only the default device, listing found nothing.
+			return nil
+		}
+		card := strings.TrimSuffix(d.ID, ",0")
+		if d.ID == id || id == card || strings.HasPrefix(id, card+",") {
+			return nil
+		}
+		cards = append(cards, d.ID)
+	}
+	return fmt.Errorf("%w: %q, available: %s", audio.ErrDeviceNotFound, id, strings.Join(cards, ", "))
+}
+
+var hwRegexp = regexp.MustCompile(`^hw:[0-9]+(,[0-9]+)?$`)
+
 var asoundRegexp = regexp.MustCompile(`^[ \t]*([0-9]*) [^\]]*\]: (.*)$`)
 
 func parseAsoundCards(f io.Reader) ([]audio.Device, error) {
@@ -106,7 +188,7 @@ func parseAsoundCards(f io.Reader) ([]audio.Device, error) {
 		}
 	}
 	if err := b.Err(); err != nil {
-		return nil, fmt.Errorf("parsing list of sound cards: %v", err)
+		return nil, fmt.Errorf("parsing list of sound cards: %w", err)
 	}
 	return r, nil
 }
@@ -142,10 +224,14 @@ func parseSoxDevices(s string) ([]audio.Device, error) {
 //
 // Opts and its fields can be nil or zero, in which case default values are
 // used.
-func NewRecorder(opts *RecorderOpts) (recorder *Recorder, rerr error) {
+//
+// Canceling ctx stops the recording command, as does Close.
+func NewRecorder(ctx context.Context, opts ...Option) (recorder *Recorder, rerr error) {
 	var xopts RecorderOpts
-	if opts != nil {
-		xopts = *opts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
 	}
 	if xopts.SampleRate == 0 {
 		xopts.SampleRate = recorderOptsDefault.SampleRate
@@ -163,6 +249,12 @@ func NewRecorder(opts *RecorderOpts) (recorder *Recorder, rerr error) {
 		xopts.AudioType = recorderOptsDefault.AudioType
 	}
 
+	if xopts.DeviceID != "" {
+		if err := checkDevice(xopts.DeviceID); err != nil {
+			return nil, err
+		}
+	}
+
 	audioType := xopts.AudioType
 
 	var args []string
@@ -238,7 +330,7 @@ func NewRecorder(opts *RecorderOpts) (recorder *Recorder, rerr error) {
 		}
 	}()
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	r.cancel = cancel
 	cmd := exec.CommandContext(ctx, xopts.RecordProgram, args...)
 	audio, err := cmd.StdoutPipe()
@@ -246,17 +338,16 @@ func NewRecorder(opts *RecorderOpts) (recorder *Recorder, rerr error) {
 		if xopts.RecordProgram == "sox" && errors.Is(err, exec.ErrNotFound) {
 			return nil, errSoxInstallHint
 		}
-		return nil, fmt.Errorf("stdout pipe: %v", err)
+		return nil, fmt.Errorf("stdout pipe: %w", err)
 	}
 	r.audio = audio
 
-	if xopts.Verbose {
-		log.Printf("Recording %d channels with sample rate %d...", xopts.Channels, xopts.SampleRate)
-		log.Printf("Command %s", strings.Join(append([]string{xopts.RecordProgram}, args...), " "))
-	}
+	logger := edgeimpulse.DefaultLogger(xopts.Logger, xopts.Verbose)
+	logger.Logf(edgeimpulse.LogDebug, "Recording %d channels with sample rate %d...", xopts.Channels, xopts.SampleRate)
+	logger.Logf(edgeimpulse.LogDebug, "Command %s", strings.Join(append([]string{xopts.RecordProgram}, args...), " "))
 
 	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("starting recorder: %v", err)
+		return nil, fmt.Errorf("starting recorder: %w", err)
 	}
 
 	return r, nil
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"testing"
 
-	"github.com/edgeimpulse/linux-sdk-go/audio"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio"
 )
 
 func TestParseAsoundCards(t *testing.T) {
@@ -3,13 +3,16 @@ package audio
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"time"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/classifier"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
 )
 
 // ClassifyEvent is the result of classifying one audio slice.
@@ -23,81 +26,286 @@ type ClassifyEvent struct {
 	// How long classifying took.
 	Classifying time.Duration
 
-	// The image that was classified, after transforming to fit the
-	// requirements for the model.
+	// The samples that were classified: a window, or a slice with
+	// ClassifierOpts.Continuous.
 	Samples []float64
+
+	// Sequence number of the window of samples, starting at 1.
+	WindowID int64
+
+	// Context with the "eim.postprocess" tracing span of this window, for
+	// tracing post-processing. See ClassifierOpts.Tracer.
+	Context context.Context
+
+	trace *classifier.Trace
 }
 
 // ClassifierOpts are options for the classifier.
 type ClassifierOpts struct {
-	Verbose bool // Print verbose logging.
+	Verbose bool               // Print verbose logging.
+	Logger  edgeimpulse.Logger // Receives log messages. If nil, the standard logger is used, see edgeimpulse.DefaultLogger.
+	Tracer  edgeimpulse.Tracer // If set, spans are started for each window of samples, and its capture, classification and postprocessing.
+	Clock   edgeimpulse.Clock  // For measuring latencies. If nil, edgeimpulse.SystemClock is used.
+
+	// If OnResult or OnError is set, the classifier calls them for each
+	// event from a goroutine it manages, instead of sending events on
+	// Events, which must not be read. Handlers are called one at a time,
+	// and should return quickly; slow handlers cause input to be dropped.
+	// Errors without OnError are logged. Handlers must not call Close.
+	OnResult func(ev ClassifyEvent) // For successful classifications.
+	OnError  func(err error)        // For errors, e.g. reading input or from the model.
+
+	// If set, the classifier classifies exactly one window for each value
+	// received, e.g. for push-to-talk, of audio recorded after the value was
+	// received. Audio is discarded while not triggered. Triggers while a
+	// window is being recorded are received after it.
+	Trigger <-chan struct{}
+
+	// If set, slices of ModelParameters.SliceSize samples are classified
+	// with ClassifyContinuous of the runner, which must be a
+	// edgeimpulse.ContinuousRunner for a model in continuous mode, with the
+	// model keeping the rest of the window. Instead of sending overlapping
+	// windows every interval, each sample is sent once, and the interval
+	// is ignored. Like windows, slices are dropped while the runner is busy.
+	// Cannot be combined with Trigger.
+	Continuous bool
+}
+
+// ClassifierOption configures a classifier created with NewClassifier. A
+// *ClassifierOpts is also a ClassifierOption, and replaces all settings made
+// by earlier options.
+type ClassifierOption interface {
+	applyClassifier(o *ClassifierOpts)
+}
+
+type classifierOptionFunc func(o *ClassifierOpts)
+
+func (fn classifierOptionFunc) applyClassifier(o *ClassifierOpts) {
+	fn(o)
+}
+
+func (opts *ClassifierOpts) applyClassifier(o *ClassifierOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithVerbose sets ClassifierOpts.Verbose.
+func WithVerbose(verbose bool) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets ClassifierOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Logger = logger })
+}
+
+// WithTracer sets ClassifierOpts.Tracer.
+func WithTracer(tracer edgeimpulse.Tracer) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Tracer = tracer })
+}
+
+// WithClock sets ClassifierOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Clock = clock })
+}
+
+// WithOnResult sets ClassifierOpts.OnResult.
+func WithOnResult(fn func(ev ClassifyEvent)) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.OnResult = fn })
+}
+
+// WithOnError sets ClassifierOpts.OnError.
+func WithOnError(fn func(err error)) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.OnError = fn })
+}
+
+// WithTrigger sets ClassifierOpts.Trigger.
+func WithTrigger(trigger <-chan struct{}) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Trigger = trigger })
+}
+
+// WithContinuous sets ClassifierOpts.Continuous.
+func WithContinuous(continuous bool) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Continuous = continuous })
 }
 
 // Classifier continuously reads audio from a recorder, classifies them, and
-// sends the results on channel Events.
+// sends the results on channel Events. Events is closed when the classifier
+// stops, after Close, canceling its context, or an error. Errors with
+// edgeimpulse.ErrModelExited only fail the window, the classifier continues
+// with the next window, e.g. for a runner with RunnerOpts.Respawn.
 type Classifier struct {
 	Events chan ClassifyEvent
+
+	loop *classifier.Loop
+}
+
+// window is a window of samples to classify, or an error reading audio.
+type window struct {
+	samples []float64
+	err     error
 }
 
 // NewClassifier starts an audio recorder, reads audio data, and classifies
 // them every interval, sending the results on its channel Events.
 //
 // Callers must call Close on the classifier to clean it up, and separately
-// close the runner and recorder.
-func NewClassifier(runner edgeimpulse.Runner, recorder Recorder, interval time.Duration, opts *ClassifierOpts) (*Classifier, error) {
+// close the runner and recorder. Canceling ctx also stops the classifier.
+func NewClassifier(ctx context.Context, runner edgeimpulse.Runner, recorder Recorder, interval time.Duration, opts ...ClassifierOption) (*Classifier, error) {
 	var xopts ClassifierOpts
-	if opts != nil {
-		xopts = *opts
+	for _, o := range opts {
+		if o != nil {
+			o.applyClassifier(&xopts)
+		}
 	}
+	handlers := xopts.OnResult != nil || xopts.OnError != nil
+	loop := classifier.New(ctx, classifier.Opts{Verbose: xopts.Verbose, Logger: xopts.Logger, Tracer: xopts.Tracer, Clock: xopts.Clock, Handlers: handlers})
+	logger, clock := loop.Logger, loop.Clock
 
 	modelParams := runner.ModelParameters()
 	if modelParams.SensorType != edgeimpulse.SensorTypeMicrophone {
 		return nil, fmt.Errorf("sensor for this model was %q, expected microphone", modelParams.SensorType)
 	}
 
+	// Samples are 16 bit, so are classified as float32, with the shorter
+	// encoding for the model. Only the classifying goroutine classifies.
+	windowSize := modelParams.InputFeaturesCount
+	samples32 := make([]float32, windowSize)
+	classify := func(s []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+		for i, v := range s {
+			samples32[i] = float32(v)
+		}
+		return edgeimpulse.Classify32(runner, samples32)
+	}
+	if xopts.Continuous {
+		cr, ok := runner.(edgeimpulse.ContinuousRunner)
+		if !ok || !modelParams.UseContinuousMode || modelParams.SliceSize <= 0 {
+			return nil, fmt.Errorf("continuous classification: %w", edgeimpulse.ErrNoContinuous)
+		}
+		if xopts.Trigger != nil {
+			return nil, fmt.Errorf("continuous classification cannot be combined with a trigger")
+		}
+		classify = cr.ClassifyContinuous
+		windowSize = modelParams.SliceSize
+	}
+
 	c := &Classifier{
-		make(chan ClassifyEvent, 1),
+		Events: make(chan ClassifyEvent, 1),
+		loop:   loop,
 	}
 
 	// We keep reading an interval worth of audio data. We keep track of a
 	// full frame with the size the model needs. So the new interval-slice
 	// of samples is appended, and oldest data chopped off.
+	// In continuous mode, the frame is a slice, read at once.
 	intervalSampleCount := int(modelParams.Frequency * interval.Seconds())
+	if xopts.Continuous {
+		intervalSampleCount = windowSize
+	}
 	intervalBuf := make([]byte, 2*intervalSampleCount) // For single channel, 16 bit samples.
-	modelSamples := make([]float64, modelParams.InputFeaturesCount)
+	modelSamples := make([]float64, windowSize)
 	modelSampleCount := 0
 
 	audio := recorder.Reader()
-	samples := make(chan []float64)
+	windows := make(chan window)
+
+	// send delivers an event, returning false if the classifier was stopped
+	// instead. Only the classifying goroutine sends events, and closes Events
+	// when done.
+	send := func(ev ClassifyEvent) bool {
+		select {
+		case c.Events <- ev:
+			return true
+		case <-loop.Done():
+			return false
+		}
+	}
 
-	go func() {
+	loop.Run(func() {
+		var windowID int64
 		for {
-			s, ok := <-samples
-			if !ok {
+			tr := loop.StartTrace("eim.window")
+			var w window
+			select {
+			case <-loop.Done():
+				tr.End()
+				return
+			case x, ok := <-windows:
+				if !ok {
+					tr.End()
+					return
+				}
+				w = x
+			}
+			if w.err != nil {
+				tr.Fail(w.err)
+				send(ClassifyEvent{Err: w.err})
 				return
 			}
-			t0 := time.Now()
-			resp, err := runner.Classify(s)
+			s := w.samples
+			windowID++
+			tr.SetAttributes(edgeimpulse.Attribute{Key: "eim.window.id", Value: windowID})
+			tr.Stage("eim.classify")
+			t0 := clock.Now()
+			resp, err := classify(s)
 			if err != nil {
-				c.Events <- ClassifyEvent{Err: err}
+				loop.Stats.AddError()
+				tr.Fail(err)
+				// A crashed model can be respawned by the runner, so
+				// only this window fails.
+				if !send(ClassifyEvent{Err: err}) || !errors.Is(err, edgeimpulse.ErrModelExited) {
+					return
+				}
+				continue
+			}
+			tr.StageAttributes(edgeimpulse.TimingAttributes(resp)...)
+			latency := clock.Now().Sub(t0)
+			loop.Stats.Add(resp, latency)
+			pctx := tr.Stage("eim.postprocess")
+			ev := ClassifyEvent{nil, resp, latency, s, windowID, pctx, tr}
+			if !loop.Deliver(tr, func() bool { return send(ev) }) {
 				return
 			}
-			c.Events <- ClassifyEvent{nil, resp, time.Since(t0), s}
 		}
-	}()
-
-	go func() {
-		// When we stop, also stop the classifier.
-		defer func() {
-			close(samples)
-		}()
+	}, func(err error) {
+		send(ClassifyEvent{Err: err})
+	}, func() {
+		close(c.Events)
+	})
 
+	sendErr := func(err error) {
+		select {
+		case windows <- window{err: err}:
+		case <-loop.Done():
+		}
+	}
+	loop.Go(func() {
+		triggered := false
 		for {
-			// Read one interval-sized buffer of audio.
+			// Read one interval-sized buffer of audio. This blocks until
+			// the recorder returns data, or is closed.
 			if _, err := io.ReadFull(audio, intervalBuf); err != nil {
-				c.Events <- ClassifyEvent{Err: fmt.Errorf("reading audio: %v", err)}
+				sendErr(fmt.Errorf("reading audio: %w", err))
 				return
 			}
+			select {
+			case <-loop.Done():
+				return
+			default:
+			}
+
+			if xopts.Trigger != nil && !triggered {
+				// The buffer was recorded before a trigger now, so
+				// is discarded either way.
+				select {
+				case <-xopts.Trigger:
+					triggered = true
+					modelSampleCount = 0
+				default:
+				}
+				continue
+			}
 
 			// The interval may be longer than the model needs. If so, only use the end of the buffer.
 			buf := intervalBuf
@@ -133,21 +341,64 @@ func NewClassifier(runner edgeimpulse.Runner, recorder Recorder, interval time.D
 			// This creates a lot of garbage for the collector, might want to change in the future.
 			s := make([]float64, len(modelSamples))
 			copy(s, modelSamples)
+			metrics.FramesCaptured.Inc()
+			if xopts.Trigger != nil {
+				// Triggered windows are never dropped.
+				triggered = false
+				select {
+				case windows <- window{samples: s}:
+				case <-loop.Done():
+					return
+				}
+				continue
+			}
 			select {
-			case samples <- s:
+			case windows <- window{samples: s}:
 			default:
-				if xopts.Verbose {
-					log.Printf("dropping samples, classifier still busy")
-				}
+				metrics.FramesDropped.Inc()
+				logger.Logf(edgeimpulse.LogDebug, "dropping samples, classifier still busy")
 			}
 		}
-	}()
+	}, sendErr, func() {
+		// When we stop, also stop the classifier.
+		close(windows)
+	})
+
+	if handlers {
+		loop.Handle(func() {
+			for ev := range c.Events {
+				if ev.Err != nil {
+					loop.HandleError(ev.Err, xopts.OnError)
+					continue
+				}
+				if xopts.OnResult != nil {
+					xopts.OnResult(ev)
+				}
+				ev.trace.End()
+			}
+		})
+	}
 
 	return c, nil
 }
 
-// Close shuts down the classifier.
-// Close does not close the runner or recorder.
+// Stats returns latency statistics of the classifications so far.
+func (c *Classifier) Stats() *edgeimpulse.Stats {
+	return c.loop.Stats
+}
+
+// Close shuts down the classifier, waiting for a classification in progress to
+// finish, and for handlers to return. Events is closed when Close returns.
+// Close can be called multiple times, and does not require Events to be read.
+// Close does not close the runner or recorder. Reading audio stops when the
+// recorder is closed.
 func (c *Classifier) Close() error {
-	return nil
+	return c.loop.Close()
+}
+
+// Err returns the error that stopped the classifier after recovering from a
+// panic, e.g. for a malformed input, or nil. The error is also sent on
+// Events.
+func (c *Classifier) Err() error {
+	return c.loop.Err()
 }
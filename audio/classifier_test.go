@@ -0,0 +1,113 @@
+package audio_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio"
+)
+
+// testRunner returns the errors in errs for classifications, in order, and
+// succeeds for nil errors and after.
+type testRunner struct {
+	mutex sync.Mutex
+	errs  []error
+}
+
+func (r *testRunner) ModelParameters() edgeimpulse.ModelParameters {
+	return edgeimpulse.ModelParameters{SensorType: edgeimpulse.SensorTypeMicrophone, Frequency: 4, InputFeaturesCount: 4}
+}
+
+func (r *testRunner) Project() edgeimpulse.Project {
+	return edgeimpulse.Project{}
+}
+
+func (r *testRunner) Classify(data []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var resp edgeimpulse.RunnerClassifyResponse
+	if len(r.errs) > 0 {
+		err := r.errs[0]
+		r.errs = r.errs[1:]
+		if err != nil {
+			return resp, err
+		}
+	}
+	resp.Result.Classification = map[string]float64{"yes": 1}
+	return resp, nil
+}
+
+func (r *testRunner) Close() error {
+	return nil
+}
+
+// testRecorder records silence.
+type testRecorder struct{}
+
+func (testRecorder) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+	return len(buf), nil
+}
+
+func (r testRecorder) Reader() io.Reader {
+	return r
+}
+
+func (testRecorder) Close() error {
+	return nil
+}
+
+func TestClassifierModelExited(t *testing.T) {
+	runner := &testRunner{errs: []error{
+		fmt.Errorf("classifying: %w", edgeimpulse.ErrModelExited),
+		nil,
+		errors.New("broken"),
+	}}
+
+	c, err := audio.NewClassifier(context.Background(), runner, testRecorder{}, time.Second)
+	if err != nil {
+		t.Fatalf("new classifier: %v", err)
+	}
+	defer c.Close()
+
+	next := func() audio.ClassifyEvent {
+		t.Helper()
+		select {
+		case ev, ok := <-c.Events:
+			if !ok {
+				t.Fatalf("events closed")
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+		}
+		t.Fatalf("timeout waiting for event")
+		return audio.ClassifyEvent{}
+	}
+	// The model crashed, only failing the window.
+	if ev := next(); !errors.Is(ev.Err, edgeimpulse.ErrModelExited) {
+		t.Fatalf("got error %v, expected ErrModelExited", ev.Err)
+	}
+	if ev := next(); ev.Err != nil {
+		t.Fatalf("got error %v, expected classification after crash", ev.Err)
+	}
+	// Other errors stop the classifier.
+	if ev := next(); ev.Err == nil || errors.Is(ev.Err, edgeimpulse.ErrModelExited) {
+		t.Fatalf("got error %v, expected other error", ev.Err)
+	}
+	select {
+	case _, ok := <-c.Events:
+		if ok {
+			t.Fatalf("got event after error, expected events closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for events to close")
+	}
+}
@@ -1,5 +1,13 @@
 package audio
 
+import (
+	"errors"
+)
+
+// ErrDeviceNotFound is returned by recorders when the requested device does not
+// exist, for use with errors.Is.
+var ErrDeviceNotFound = errors.New("device not found")
+
 // Device is a microphone capable of recording audio.
 type Device struct {
 	Name string
@@ -0,0 +1,138 @@
+// Package render draws windows of audio samples as images, for inspecting what
+// a model hears without exporting audio to an editor: a spectrogram of the
+// mel-filterbank energies, as computed by the MFE block, and a waveform.
+// Encode the images with image/png, as eimtrace does for traced requests.
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/dsp"
+)
+
+// Colors of waveforms.
+var (
+	background = color.RGBA{0x10, 0x10, 0x18, 0xff}
+	foreground = color.RGBA{0x4c, 0xc2, 0xff, 0xff}
+)
+
+// SpectrogramOpts are options for Spectrogram.
+type SpectrogramOpts struct {
+	// Parameters of the MFE block computing the energies. Zero values are
+	// replaced by the defaults of Studio.
+	MFE dsp.MFEOpts
+
+	// Size in pixels of the energy of a filter in a frame. Default 4.
+	Scale int
+}
+
+// Spectrogram returns an image of the mel-filterbank energies of samples,
+// int16 values at frequency Hz, with time from left to right and low
+// frequencies at the bottom. Energies are colored from black at the noise
+// floor, through purple, red and yellow, to white.
+func Spectrogram(samples []float64, frequency float64, opts SpectrogramOpts) (*image.RGBA, error) {
+	if opts.MFE.Filters == 0 {
+		opts.MFE.Filters = 40
+	}
+	if opts.Scale == 0 {
+		opts.Scale = 4
+	}
+	mfe, err := dsp.NewMFE(frequency, opts.MFE)
+	if err != nil {
+		return nil, err
+	}
+	features, err := mfe.Features(samples)
+	if err != nil {
+		return nil, fmt.Errorf("mfe: %w", err)
+	}
+	filters := opts.MFE.Filters
+	frames := len(features) / filters
+	s := opts.Scale
+	img := image.NewRGBA(image.Rect(0, 0, frames*s, filters*s))
+	for i, v := range features {
+		x := (i / filters) * s
+		y := (filters - 1 - i%filters) * s
+		c := heat(v)
+		for dy := 0; dy < s; dy++ {
+			for dx := 0; dx < s; dx++ {
+				img.SetRGBA(x+dx, y+dy, c)
+			}
+		}
+	}
+	return img, nil
+}
+
+// heatStops are the colors of heat, evenly spaced.
+var heatStops = []color.RGBA{
+	{0x00, 0x00, 0x04, 0xff},
+	{0x3b, 0x0f, 0x70, 0xff},
+	{0x8c, 0x29, 0x81, 0xff},
+	{0xde, 0x49, 0x68, 0xff},
+	{0xfe, 0x9f, 0x6d, 0xff},
+	{0xfc, 0xfd, 0xbf, 0xff},
+}
+
+// heat returns the color for f, between 0 and 1.
+func heat(f float64) color.RGBA {
+	f = math.Min(math.Max(f, 0), 1) * float64(len(heatStops)-1)
+	i := int(f)
+	if i >= len(heatStops)-1 {
+		return heatStops[len(heatStops)-1]
+	}
+	a, b := heatStops[i], heatStops[i+1]
+	t := f - float64(i)
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + t*(float64(y)-float64(x))))
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 0xff}
+}
+
+// Waveform returns an image of width by height pixels of samples, int16
+// values, with the range of the samples of each column drawn as a vertical
+// line around the center.
+func Waveform(samples []float64, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+	if len(samples) == 0 || height == 0 {
+		return img
+	}
+	// Maps an int16 sample to a row, with the maximum at the top.
+	row := func(v float64) int {
+		y := int(math.Round((1 - (v/32768+1)/2) * float64(height-1)))
+		return minInt(maxInt(y, 0), height-1)
+	}
+	for x := 0; x < width; x++ {
+		start := x * len(samples) / width
+		end := maxInt((x+1)*len(samples)/width, start+1)
+		if start >= len(samples) {
+			break
+		}
+		lo, hi := samples[start], samples[start]
+		for _, v := range samples[start:minInt(end, len(samples))] {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+		for y := row(hi); y <= row(lo); y++ {
+			img.SetRGBA(x, y, foreground)
+		}
+	}
+	return img
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
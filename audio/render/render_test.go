@@ -0,0 +1,47 @@
+package render
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpectrogram(t *testing.T) {
+	// One second of a 1kHz tone: 99 frames of 40 filters.
+	samples := make([]float64, 16000)
+	for i := range samples {
+		samples[i] = 10000 * math.Sin(2*math.Pi*1000*float64(i)/16000)
+	}
+	img, err := Spectrogram(samples, 16000, SpectrogramOpts{Scale: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 99*2 || size.Y != 40*2 {
+		t.Fatalf("got size %v, expected 198x80", size)
+	}
+	// The tone is brighter than the highest filter, at the top.
+	var tone, top int
+	for y := 0; y < 80; y++ {
+		c := img.RGBAAt(100, y)
+		if v := int(c.R) + int(c.G) + int(c.B); v > tone {
+			tone = v
+		}
+	}
+	c := img.RGBAAt(100, 0)
+	top = int(c.R) + int(c.G) + int(c.B)
+	if tone <= top {
+		t.Errorf("tone not brighter than top filter, %d <= %d", tone, top)
+	}
+	if _, err := Spectrogram(samples[:10], 16000, SpectrogramOpts{}); err == nil {
+		t.Errorf("missing error for too few samples")
+	}
+}
+
+func TestWaveform(t *testing.T) {
+	img := Waveform([]float64{32767, -32768, 0, 0}, 4, 9)
+	if img.RGBAAt(0, 0) != foreground || img.RGBAAt(1, 8) != foreground {
+		t.Errorf("extremes not drawn")
+	}
+	if img.RGBAAt(2, 4) != foreground || img.RGBAAt(2, 0) != background {
+		t.Errorf("silence not drawn at center only")
+	}
+}
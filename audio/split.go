@@ -0,0 +1,125 @@
+package audio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// framesPerRead is the number of multi-channel frames Split reads at a time.
+const framesPerRead = 256
+
+// Split returns a recorder for each channel of recorder, which must produce
+// interleaved 16 bit samples of channels channels, e.g. a stereo device with
+// two microphones. Each recorder produces the samples of its channel, and can
+// be classified by its own Classifier, e.g. with a shared runner.
+//
+// Reading from recorder blocks until all channels have read their samples, so
+// read all channels, or close the ones not needed. Closing a channel recorder
+// stops delivering samples to it, and closing the last closes recorder.
+func Split(recorder Recorder, channels int) ([]Recorder, error) {
+	if channels < 1 {
+		return nil, fmt.Errorf("channels must be > 0")
+	}
+	s := &splitter{source: recorder, open: channels}
+	var l []Recorder
+	var writers []*io.PipeWriter
+	for i := 0; i < channels; i++ {
+		r, w := io.Pipe()
+		l = append(l, &channelRecorder{s: s, r: r})
+		writers = append(writers, w)
+	}
+	go s.run(writers)
+	return l, nil
+}
+
+type splitter struct {
+	source Recorder
+
+	mutex sync.Mutex
+	open  int // Channels not yet closed.
+}
+
+func (s *splitter) run(writers []*io.PipeWriter) {
+	var err error
+	defer func() {
+		if x := recover(); x != nil {
+			err = edgeimpulse.PanicError(x)
+		}
+		for _, w := range writers {
+			w.CloseWithError(err)
+		}
+	}()
+
+	r := s.source.Reader()
+	buf := make([]byte, 2*len(writers)*framesPerRead)
+	closed := make([]bool, len(writers))
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		// Only whole frames.
+		n -= n % (2 * len(writers))
+		for i, b := range deinterleave(buf[:n], len(writers)) {
+			if closed[i] {
+				continue
+			}
+			if _, err := writers[i].Write(b); err != nil {
+				// Reader of the channel was closed.
+				closed[i] = true
+			}
+		}
+		if rerr != nil {
+			if rerr == io.ErrUnexpectedEOF {
+				rerr = io.EOF
+			}
+			err = rerr
+			return
+		}
+	}
+}
+
+// deinterleave splits buf of interleaved 16 bit samples into a buffer of
+// samples for each of channels.
+func deinterleave(buf []byte, channels int) [][]byte {
+	frames := len(buf) / (2 * channels)
+	l := make([][]byte, channels)
+	for c := range l {
+		l[c] = make([]byte, 2*frames)
+		for i := 0; i < frames; i++ {
+			o := 2 * (i*channels + c)
+			l[c][2*i] = buf[o]
+			l[c][2*i+1] = buf[o+1]
+		}
+	}
+	return l
+}
+
+// channelRecorder is a channel of a split recorder.
+type channelRecorder struct {
+	s    *splitter
+	r    *io.PipeReader
+	once sync.Once
+}
+
+// Ensure that channelRecorder implements the Recorder interface.
+var _ Recorder = (*channelRecorder)(nil)
+
+func (c *channelRecorder) Reader() io.Reader {
+	return c.r
+}
+
+func (c *channelRecorder) Close() error {
+	var err error
+	c.once.Do(func() {
+		c.r.Close()
+		c.s.mutex.Lock()
+		c.s.open--
+		last := c.s.open == 0
+		c.s.mutex.Unlock()
+		if last {
+			err = c.s.source.Close()
+		}
+	})
+	return err
+}
@@ -0,0 +1,64 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sync"
+	"testing"
+)
+
+type fakeRecorder struct {
+	r      io.Reader
+	closed bool
+}
+
+func (f *fakeRecorder) Reader() io.Reader { return f.r }
+func (f *fakeRecorder) Close() error      { f.closed = true; return nil }
+
+func TestSplit(t *testing.T) {
+	// 1000 stereo frames, left i, right -i.
+	var buf bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		binary.Write(&buf, binary.LittleEndian, []int16{int16(i), int16(-i)})
+	}
+	rec := &fakeRecorder{r: &buf}
+	channels, err := Split(rec, 2)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+
+	// Channels must be read concurrently.
+	got := make([][]byte, 2)
+	var wg sync.WaitGroup
+	for i, c := range channels {
+		wg.Add(1)
+		go func(i int, c Recorder) {
+			defer wg.Done()
+			got[i], _ = io.ReadAll(c.Reader())
+		}(i, c)
+	}
+	wg.Wait()
+
+	for c, sign := range []int{1, -1} {
+		samples := make([]int16, len(got[c])/2)
+		binary.Read(bytes.NewReader(got[c]), binary.LittleEndian, samples)
+		if len(samples) != 1000 {
+			t.Fatalf("channel %d: got %d samples, expected 1000", c, len(samples))
+		}
+		for i, v := range samples {
+			if int(v) != sign*i {
+				t.Fatalf("channel %d: sample %d is %d, expected %d", c, i, v, sign*i)
+			}
+		}
+	}
+
+	channels[0].Close()
+	if rec.closed {
+		t.Errorf("source closed with a channel still open")
+	}
+	channels[1].Close()
+	if !rec.closed {
+		t.Errorf("source not closed after closing all channels")
+	}
+}
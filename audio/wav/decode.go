@@ -0,0 +1,116 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Format codes of the fmt chunk.
+const (
+	formatPCM        = 1
+	formatFloat      = 3
+	formatExtensible = 0xfffe
+)
+
+// ErrFormat is returned by Decode for files that are not WAV files, or have an
+// unsupported sample format.
+var ErrFormat = errors.New("unsupported wav format")
+
+// Decode reads a WAV file with PCM samples of 8, 16, 24 or 32 bits, or IEEE
+// float samples of 32 or 64 bits, and returns the samples converted to the 16
+// bit range models expect, interleaved for multiple channels. Float samples are
+// scaled from -1..1 and clipped.
+func Decode(r io.Reader) (samples []int16, sampleRate, channels int, err error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, 0, 0, fmt.Errorf("reading wav header: %w", err)
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, 0, 0, fmt.Errorf("%w: not a riff wave file", ErrFormat)
+	}
+
+	var format, bits int
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, 0, 0, fmt.Errorf("reading wav chunk: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		switch string(chunk[0:4]) {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, 0, fmt.Errorf("%w: fmt chunk of %d bytes", ErrFormat, size)
+			}
+			buf := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, 0, 0, fmt.Errorf("reading fmt chunk: %w", err)
+			}
+			format = int(binary.LittleEndian.Uint16(buf[0:]))
+			channels = int(binary.LittleEndian.Uint16(buf[2:]))
+			sampleRate = int(binary.LittleEndian.Uint32(buf[4:]))
+			bits = int(binary.LittleEndian.Uint16(buf[14:]))
+			// The format of extensible files is in the first bytes of the
+			// subformat guid.
+			if format == formatExtensible && size >= 26 {
+				format = int(binary.LittleEndian.Uint16(buf[24:]))
+			}
+		case "data":
+			if format == 0 {
+				return nil, 0, 0, fmt.Errorf("%w: data before fmt chunk", ErrFormat)
+			}
+			if channels <= 0 || sampleRate <= 0 {
+				return nil, 0, 0, fmt.Errorf("%w: %d channels at %d Hz", ErrFormat, channels, sampleRate)
+			}
+			convert, err := converter(format, bits)
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			// Size is 0xffffffff for files written while streaming, and
+			// may be too large for files that were cut off.
+			data, err := io.ReadAll(io.LimitReader(r, size))
+			if err != nil {
+				return nil, 0, 0, fmt.Errorf("reading wav data: %w", err)
+			}
+			n := bits / 8
+			samples = make([]int16, len(data)/n)
+			for i := range samples {
+				samples[i] = convert(data[i*n:])
+			}
+			return samples, sampleRate, channels, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, 0, 0, fmt.Errorf("skipping wav chunk: %w", err)
+			}
+		}
+	}
+}
+
+// converter returns a function converting a sample at the start of a buffer
+// to 16 bits.
+func converter(format, bits int) (func(b []byte) int16, error) {
+	switch {
+	case format == formatPCM && bits == 8:
+		// 8 bit samples are unsigned.
+		return func(b []byte) int16 { return int16(int(b[0])-128) << 8 }, nil
+	case format == formatPCM && bits == 16:
+		return func(b []byte) int16 { return int16(binary.LittleEndian.Uint16(b)) }, nil
+	case format == formatPCM && bits == 24:
+		return func(b []byte) int16 { return int16(b[1]) | int16(int8(b[2]))<<8 }, nil
+	case format == formatPCM && bits == 32:
+		return func(b []byte) int16 { return int16(int32(binary.LittleEndian.Uint32(b)) >> 16) }, nil
+	case format == formatFloat && bits == 32:
+		return func(b []byte) int16 { return floatSample(float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))) }, nil
+	case format == formatFloat && bits == 64:
+		return func(b []byte) int16 { return floatSample(math.Float64frombits(binary.LittleEndian.Uint64(b))) }, nil
+	}
+	return nil, fmt.Errorf("%w: format %d with %d bits per sample", ErrFormat, format, bits)
+}
+
+// floatSample converts a float sample, nominally between -1 and 1, to 16 bits.
+func floatSample(f float64) int16 {
+	v := math.Round(f * math.MaxInt16)
+	return int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+}
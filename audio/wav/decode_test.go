@@ -0,0 +1,64 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// wavFile returns a WAV file with a fmt chunk of format and bits, and data.
+func wavFile(format, bits, channels int, data []byte) []byte {
+	var b bytes.Buffer
+	w := func(v interface{}) { binary.Write(&b, binary.LittleEndian, v) }
+	b.WriteString("RIFF")
+	w(uint32(4 + 8 + 16 + 8 + 8 + len(data)))
+	b.WriteString("WAVE")
+	b.WriteString("fmt ")
+	w(uint32(16))
+	w(uint16(format))
+	w(uint16(channels))
+	w(uint32(16000))
+	w(uint32(16000 * channels * bits / 8))
+	w(uint16(channels * bits / 8))
+	w(uint16(bits))
+	// Unknown chunks are skipped.
+	b.WriteString("LIST")
+	w(uint32(0))
+	b.WriteString("data")
+	w(uint32(len(data)))
+	b.Write(data)
+	return b.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	var enc bytes.Buffer
+	if err := Encode(&enc, []int16{1, -2, 3, -4}, 16000, 2); err != nil {
+		t.Fatal(err)
+	}
+	samples, rate, channels, err := Decode(&enc)
+	if err != nil || rate != 16000 || channels != 2 || !reflect.DeepEqual(samples, []int16{1, -2, 3, -4}) {
+		t.Fatalf("16 bit: got %v %d %d %v", samples, rate, channels, err)
+	}
+
+	// 24 bit: 0x123456 and -0x123456.
+	data := []byte{0x56, 0x34, 0x12, 0xaa, 0xcb, 0xed}
+	samples, _, _, err = Decode(bytes.NewReader(wavFile(formatPCM, 24, 1, data)))
+	if err != nil || !reflect.DeepEqual(samples, []int16{0x1234, -0x1235}) {
+		t.Fatalf("24 bit: got %v %v", samples, err)
+	}
+
+	var floats bytes.Buffer
+	binary.Write(&floats, binary.LittleEndian, []float32{0, 0.5, -1, 2})
+	samples, _, _, err = Decode(bytes.NewReader(wavFile(formatFloat, 32, 1, floats.Bytes())))
+	if err != nil || !reflect.DeepEqual(samples, []int16{0, 16384, -math.MaxInt16, math.MaxInt16}) {
+		t.Fatalf("float: got %v %v", samples, err)
+	}
+
+	_, _, _, err = Decode(bytes.NewReader(wavFile(formatPCM, 12, 1, nil)))
+	if !errors.Is(err, ErrFormat) {
+		t.Fatalf("12 bit: got %v, expected ErrFormat", err)
+	}
+}
@@ -0,0 +1,90 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Recorder is an audio source playing back a WAV file, e.g. for classifying
+// field recordings offline. Samples are read as raw 16 bit little endian
+// values, interleaved for multiple channels, like from a microphone recorder.
+// Reads return io.EOF at the end of the file.
+type Recorder struct {
+	SampleRate int
+	Channels   int
+
+	data     *bytes.Reader
+	realtime bool
+	start    time.Time
+	read     int64
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// NewRecorder reads and decodes the WAV file at path, see Decode. If realtime
+// is set, reads are paced to the sample rate, as if recording the audio live.
+// Classifiers drop windows they cannot keep up with, so playback should
+// typically be realtime.
+func NewRecorder(path string, realtime bool) (*Recorder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	samples, rate, channels, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, samples); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		SampleRate: rate,
+		Channels:   channels,
+		data:       bytes.NewReader(buf.Bytes()),
+		realtime:   realtime,
+		closed:     make(chan struct{}),
+	}, nil
+}
+
+// Reader returns the source of samples.
+func (r *Recorder) Reader() io.Reader {
+	return r
+}
+
+// Read implements io.Reader.
+func (r *Recorder) Read(buf []byte) (int, error) {
+	select {
+	case <-r.closed:
+		return 0, errors.New("recorder closed")
+	default:
+	}
+	if r.realtime {
+		if r.start.IsZero() {
+			r.start = time.Now()
+		}
+		bytesPerSecond := float64(2 * r.Channels * r.SampleRate)
+		due := r.start.Add(time.Duration(float64(r.read) / bytesPerSecond * float64(time.Second)))
+		select {
+		case <-r.closed:
+			return 0, errors.New("recorder closed")
+		case <-time.After(time.Until(due)):
+		}
+	}
+	n, err := r.data.Read(buf)
+	r.read += int64(n)
+	return n, err
+}
+
+// Close stops playback, making reads fail.
+func (r *Recorder) Close() error {
+	r.once.Do(func() { close(r.closed) })
+	return nil
+}
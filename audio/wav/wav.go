@@ -0,0 +1,53 @@
+// Package wav implements reading and writing audio samples as WAV files.
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Encode writes samples as a WAV file with 16 bit signed PCM samples. For
+// multiple channels, samples must be interleaved.
+func Encode(w io.Writer, samples []int16, sampleRate, channels int) error {
+	if channels <= 0 {
+		return fmt.Errorf("channels must be > 0")
+	}
+	if sampleRate <= 0 {
+		return fmt.Errorf("sample rate must be > 0")
+	}
+
+	const bitsPerSample = 16
+	dataSize := uint32(2 * len(samples))
+	blockAlign := uint16(channels * bitsPerSample / 8)
+
+	bw := bufio.NewWriter(w)
+	fields := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(36 + dataSize),
+		[4]byte{'W', 'A', 'V', 'E'},
+
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),                              // Size of fmt chunk.
+		uint16(1),                               // PCM.
+		uint16(channels),                        // Channels.
+		uint32(sampleRate),                      // Sample rate.
+		uint32(sampleRate) * uint32(blockAlign), // Byte rate.
+		blockAlign,                              // Bytes per frame.
+		uint16(bitsPerSample),                   // Bits per sample.
+
+		[4]byte{'d', 'a', 't', 'a'},
+		dataSize,
+		samples,
+	}
+	for _, f := range fields {
+		if err := binary.Write(bw, binary.LittleEndian, f); err != nil {
+			return fmt.Errorf("writing wav: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("writing wav: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BenchmarkOpts are options for Benchmark.
+type BenchmarkOpts struct {
+	// Number of measured classifications. If 0, 100 are done.
+	N int
+
+	// Classifications before measuring, not counted, e.g. for an engine that
+	// is slow on the first inference.
+	Warmup int
+
+	// Features to classify. If nil, random features in [0, 1) of
+	// ModelParameters.InputFeaturesCount are used. Results of random
+	// features are meaningless, but timing is typically representative.
+	Features []float64
+
+	// Number of goroutines classifying at the same time, e.g. the size of a
+	// RunnerPool. If 0, 1 is used.
+	Concurrency int
+}
+
+// BenchmarkResult is the outcome of Benchmark.
+type BenchmarkResult struct {
+	Count      int           `json:"count"`      // Measured classifications.
+	Duration   time.Duration `json:"duration"`   // Wall clock time of the measured classifications.
+	Throughput float64       `json:"throughput"` // Classifications per second.
+
+	// Latencies, with DSP and classification time as reported by the
+	// model, and the total as seen by the caller, including encoding and
+	// waiting for a model process.
+	Stats StatsSnapshot `json:"stats"`
+}
+
+func (r BenchmarkResult) String() string {
+	ms := func(d time.Duration) string {
+		return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+	}
+	s := r.Stats
+	return fmt.Sprintf("%d classifications in %v, %.1f/s\ndsp            min %s, mean %s, p95 %s\nclassification min %s, mean %s, p95 %s\ntotal          min %s, mean %s, p95 %s",
+		r.Count, r.Duration.Round(time.Millisecond), r.Throughput,
+		ms(s.DSP.Min), ms(s.DSP.Mean), ms(s.DSP.P95),
+		ms(s.Classification.Min), ms(s.Classification.Mean), ms(s.Classification.P95),
+		ms(s.Total.Min), ms(s.Total.Mean), ms(s.Total.P95))
+}
+
+// Benchmark classifies features repeatedly with r, and returns the latencies
+// and throughput, e.g. for choosing hardware for a model or comparing
+// RunnerOpts.Threads settings. It stops at the first error.
+func Benchmark(r Runner, opts BenchmarkOpts) (BenchmarkResult, error) {
+	n := opts.N
+	if n <= 0 {
+		n = 100
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	features := opts.Features
+	if features == nil {
+		features = make([]float64, r.ModelParameters().InputFeaturesCount)
+		rnd := rand.New(rand.NewSource(1))
+		for i := range features {
+			features[i] = rnd.Float64()
+		}
+	}
+
+	for i := 0; i < opts.Warmup; i++ {
+		if _, err := r.Classify(features); err != nil {
+			return BenchmarkResult{}, fmt.Errorf("warmup: %w", err)
+		}
+	}
+
+	var stats Stats
+	var mutex sync.Mutex
+	var next int
+	var err error
+	var wg sync.WaitGroup
+	t0 := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mutex.Lock()
+				if next == n || err != nil {
+					mutex.Unlock()
+					return
+				}
+				next++
+				mutex.Unlock()
+
+				start := time.Now()
+				resp, xerr := r.Classify(features)
+				if xerr != nil {
+					mutex.Lock()
+					if err == nil {
+						err = xerr
+					}
+					mutex.Unlock()
+					return
+				}
+				stats.Add(resp, time.Since(start))
+			}
+		}()
+	}
+	wg.Wait()
+	if err != nil {
+		return BenchmarkResult{}, fmt.Errorf("classify: %w", err)
+	}
+	d := time.Since(t0)
+	return BenchmarkResult{
+		Count:      n,
+		Duration:   d,
+		Throughput: float64(n) / d.Seconds(),
+		Stats:      stats.Snapshot(),
+	}, nil
+}
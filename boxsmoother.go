@@ -0,0 +1,194 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SmoothedBox is a bounding box smoothed over consecutive frames by a
+// BoxSmoother.
+type SmoothedBox struct {
+	ID     int64 // Identifies the tracked object across frames.
+	Label  string
+	Value  float64
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// BoxSmootherOpts are options for a BoxSmoother.
+type BoxSmootherOpts struct {
+	// Weight of a new observation in the exponential moving average of
+	// coordinates and score, between 0 and 1. Lower is smoother. If zero,
+	// 0.5 is used.
+	Alpha float64
+
+	// Minimum intersection over union of a box with a tracked box to be
+	// considered the same object. If zero, 0.3 is used.
+	MinIoU float64
+
+	// Number of consecutive frames a tracked box is kept when it is not
+	// detected, while its score decays. If zero, 2 is used.
+	MaxMissed int
+
+	// Minimum smoothed score for a box to be returned by Update.
+	Threshold float64
+}
+
+type boxTrack struct {
+	box        SmoothedBox
+	x, y, w, h float64 // Smoothed coordinates.
+	missed     int     // Consecutive frames without match.
+}
+
+// BoxSmoother smooths the coordinates and scores of bounding boxes of object
+// detection models across consecutive frames, reducing jitter in overlays and
+// flapping of detections near a threshold. Boxes are tracked across frames by
+// matching on label and overlap.
+type BoxSmoother struct {
+	opts   BoxSmootherOpts
+	tracks []*boxTrack
+	lastID int64
+}
+
+// NewBoxSmoother returns a new box smoother.
+func NewBoxSmoother(opts BoxSmootherOpts) (*BoxSmoother, error) {
+	if opts.Alpha == 0 {
+		opts.Alpha = 0.5
+	}
+	if opts.MinIoU == 0 {
+		opts.MinIoU = 0.3
+	}
+	if opts.MaxMissed == 0 {
+		opts.MaxMissed = 2
+	}
+	if opts.Alpha < 0 || opts.Alpha > 1 {
+		return nil, fmt.Errorf("alpha must be between 0 and 1")
+	}
+	return &BoxSmoother{opts: opts}, nil
+}
+
+// iou returns the intersection over union of two boxes.
+func iou(ax, ay, aw, ah, bx, by, bw, bh float64) float64 {
+	x0 := max64(ax, bx)
+	y0 := max64(ay, by)
+	x1 := min64(ax+aw, bx+bw)
+	y1 := min64(ay+ah, by+bh)
+	if x1 <= x0 || y1 <= y0 {
+		return 0
+	}
+	inter := (x1 - x0) * (y1 - y0)
+	return inter / (aw*ah + bw*bh - inter)
+}
+
+func max64(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min64(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Update adds the bounding boxes of the classification of one frame, and
+// returns the smoothed boxes with a score at or above the threshold, ordered by
+// ID.
+func (s *BoxSmoother) Update(resp RunnerClassifyResponse) []SmoothedBox {
+	boxes := resp.Result.BoundingBoxes
+
+	// Match boxes to tracks, best overlap first.
+	type pair struct {
+		track, box int
+		iou        float64
+	}
+	var pairs []pair
+	for ti, t := range s.tracks {
+		for bi, b := range boxes {
+			if b.Label != t.box.Label {
+				continue
+			}
+			v := iou(t.x, t.y, t.w, t.h, float64(b.X), float64(b.Y), float64(b.Width), float64(b.Height))
+			if v >= s.opts.MinIoU {
+				pairs = append(pairs, pair{ti, bi, v})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].iou > pairs[j].iou
+	})
+	trackMatched := make([]bool, len(s.tracks))
+	boxMatched := make([]bool, len(boxes))
+	a := s.opts.Alpha
+	for _, p := range pairs {
+		if trackMatched[p.track] || boxMatched[p.box] {
+			continue
+		}
+		trackMatched[p.track] = true
+		boxMatched[p.box] = true
+		t := s.tracks[p.track]
+		b := boxes[p.box]
+		t.x = a*float64(b.X) + (1-a)*t.x
+		t.y = a*float64(b.Y) + (1-a)*t.y
+		t.w = a*float64(b.Width) + (1-a)*t.w
+		t.h = a*float64(b.Height) + (1-a)*t.h
+		t.box.Value = a*b.Value + (1-a)*t.box.Value
+		t.missed = 0
+	}
+
+	// Decay unmatched tracks, dropping those missing for too long.
+	tracks := s.tracks[:0]
+	for i, t := range s.tracks {
+		if !trackMatched[i] {
+			t.missed++
+			if t.missed > s.opts.MaxMissed {
+				continue
+			}
+			t.box.Value = (1 - a) * t.box.Value
+		}
+		tracks = append(tracks, t)
+	}
+	s.tracks = tracks
+
+	// New boxes start new tracks.
+	for i, b := range boxes {
+		if boxMatched[i] {
+			continue
+		}
+		s.lastID++
+		s.tracks = append(s.tracks, &boxTrack{
+			box: SmoothedBox{ID: s.lastID, Label: b.Label, Value: b.Value},
+			x:   float64(b.X),
+			y:   float64(b.Y),
+			w:   float64(b.Width),
+			h:   float64(b.Height),
+		})
+	}
+
+	var r []SmoothedBox
+	for _, t := range s.tracks {
+		if t.box.Value < s.opts.Threshold {
+			continue
+		}
+		b := t.box
+		b.X = int(t.x + 0.5)
+		b.Y = int(t.y + 0.5)
+		b.Width = int(t.w + 0.5)
+		b.Height = int(t.h + 0.5)
+		r = append(r, b)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].ID < r[j].ID
+	})
+	return r
+}
+
+// Reset forgets all tracked boxes.
+func (s *BoxSmoother) Reset() {
+	s.tracks = nil
+}
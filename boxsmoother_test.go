@@ -0,0 +1,46 @@
+package edgeimpulse_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestBoxSmoother(t *testing.T) {
+	s, err := edgeimpulse.NewBoxSmoother(edgeimpulse.BoxSmootherOpts{Alpha: 0.5, Threshold: 0.3, MaxMissed: 1})
+	if err != nil {
+		t.Fatalf("new box smoother: %v", err)
+	}
+
+	update := func(boxes string) []edgeimpulse.SmoothedBox {
+		t.Helper()
+		var resp edgeimpulse.RunnerClassifyResponse
+		if err := json.Unmarshal([]byte(`{"result": {"bounding_boxes": `+boxes+`}}`), &resp); err != nil {
+			t.Fatalf("parsing response: %v", err)
+		}
+		return s.Update(resp)
+	}
+
+	r := update(`[{"label": "a", "value": 0.8, "x": 10, "y": 10, "width": 20, "height": 20}]`)
+	if len(r) != 1 || r[0].X != 10 || r[0].Value != 0.8 {
+		t.Fatalf("unexpected first result %v", r)
+	}
+	id := r[0].ID
+
+	// Moved box is smoothed, and keeps its ID. A box with another label is a new object.
+	r = update(`[{"label": "a", "value": 0.6, "x": 14, "y": 10, "width": 20, "height": 20}, {"label": "b", "value": 0.9, "x": 10, "y": 10, "width": 20, "height": 20}]`)
+	if len(r) != 2 || r[0].ID != id || r[0].X != 12 || r[0].Value != 0.7 || r[1].Label != "b" {
+		t.Fatalf("unexpected second result %v", r)
+	}
+
+	// Missing box is kept for a frame with decayed score.
+	r = update(`[{"label": "b", "value": 0.9, "x": 10, "y": 10, "width": 20, "height": 20}]`)
+	if len(r) != 2 || r[0].ID != id || r[0].Value != 0.35 {
+		t.Fatalf("unexpected third result %v", r)
+	}
+	r = update(`[{"label": "b", "value": 0.9, "x": 10, "y": 10, "width": 20, "height": 20}]`)
+	if len(r) != 1 || r[0].Label != "b" {
+		t.Fatalf("unexpected fourth result %v", r)
+	}
+}
@@ -0,0 +1,366 @@
+// Package cascade runs models in stages, where a detection by one model
+// triggers another model. Two kinds of cascades are supported:
+//
+//   - Wake: an audio model, e.g. for a keyword, enables classifying camera
+//     images for a while. The camera model only runs when needed.
+//   - Crop: an object detection model, e.g. FOMO for persons, finds regions in
+//     camera images, and a second image model classifies each region.
+//
+// A cascade owns its components: recorders, classifiers and runners passed in
+// are closed in order on Close, like with edgeimpulse.Group. Results of the
+// stages are combined into a single stream of events.
+package cascade
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio"
+	eimage "github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+// Result is a classification by the second stage.
+type Result struct {
+	edgeimpulse.RunnerClassifyResponse
+
+	// The image that was classified, for a crop cascade the region of the
+	// camera image.
+	Image image.Image
+
+	// For a crop cascade, the detection of the first stage that was
+	// classified, with its region in the coordinates of the camera image.
+	Label  string
+	Score  float64
+	Region image.Rectangle
+}
+
+// Event is a result of a cascade.
+type Event struct {
+	// If not nil, an error occurred in one of the stages, and other fields
+	// are not meaningful.
+	Err error
+
+	// Response of the first stage that triggered the second stage.
+	Trigger edgeimpulse.RunnerClassifyResponse
+
+	// When the first stage triggered.
+	Time time.Time
+
+	// Results of the second stage: one for each image classified after
+	// waking, or one for each detected region.
+	Results []Result
+}
+
+// Opts are options for a cascade.
+type Opts struct {
+	// Labels of the first stage that trigger the second stage. If empty,
+	// any label triggers.
+	Labels []string
+
+	// Minimum score of a label or bounding box to trigger. Defaults to 0.8
+	// for wake, and 0.5 for crop cascades.
+	Threshold float64
+
+	// For wake cascades, how long images are classified after a trigger.
+	// Defaults to 5 seconds.
+	Duration time.Duration
+
+	// For crop cascades, fraction of the size of a region that is added on
+	// each side before classifying, for context, e.g. 0.1.
+	Padding float64
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For wake durations. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a cascade. A *Opts is also an Option, and replaces all
+// settings made by earlier options.
+type Option interface {
+	apply(o *Opts)
+}
+
+type optionFunc func(o *Opts)
+
+func (fn optionFunc) apply(o *Opts) {
+	fn(o)
+}
+
+func (opts *Opts) apply(o *Opts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithTrigger sets Opts.Labels and Opts.Threshold.
+func WithTrigger(threshold float64, labels ...string) Option {
+	return optionFunc(func(o *Opts) {
+		o.Threshold = threshold
+		o.Labels = labels
+	})
+}
+
+// WithDuration sets Opts.Duration.
+func WithDuration(d time.Duration) Option {
+	return optionFunc(func(o *Opts) { o.Duration = d })
+}
+
+// WithPadding sets Opts.Padding.
+func WithPadding(padding float64) Option {
+	return optionFunc(func(o *Opts) { o.Padding = padding })
+}
+
+// WithVerbose sets Opts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *Opts) { o.Verbose = verbose })
+}
+
+// WithLogger sets Opts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *Opts) { o.Logger = logger })
+}
+
+// WithClock sets Opts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *Opts) { o.Clock = clock })
+}
+
+// Cascade runs two models in stages, and sends the combined results on
+// Events. Events is closed when the cascade stops, after Close, canceling its
+// context, or when a stage stops.
+type Cascade struct {
+	Events chan Event
+
+	group *edgeimpulse.Group
+	done  chan struct{}
+}
+
+func newCascade(ctx context.Context, opts []Option, defaultThreshold float64) (*Cascade, Opts) {
+	var xopts Opts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
+	}
+	if xopts.Threshold == 0 {
+		xopts.Threshold = defaultThreshold
+	}
+	if xopts.Duration == 0 {
+		xopts.Duration = 5 * time.Second
+	}
+	c := &Cascade{
+		Events: make(chan Event, 1),
+		group:  edgeimpulse.NewGroup(ctx),
+		done:   make(chan struct{}),
+	}
+	return c, xopts
+}
+
+// Own adds a component that the cascade closes in stage, e.g. the recorder
+// of the audio classifier of a wake cascade.
+func (c *Cascade) Own(stage edgeimpulse.Stage, closer io.Closer) {
+	c.group.Add(stage, closer)
+}
+
+// send delivers an event, returning false if the cascade was stopped instead.
+func (c *Cascade) send(ev Event) bool {
+	select {
+	case c.Events <- ev:
+		return true
+	case <-c.group.Done():
+		return false
+	}
+}
+
+// Close stops all stages, and closes all components of the cascade in order.
+// Close can be called multiple times, and does not require Events to be read.
+func (c *Cascade) Close() error {
+	err := c.group.Close()
+	<-c.done
+	return err
+}
+
+// labelOK returns whether label is one of labels, or labels is empty.
+func labelOK(label string, labels []string) bool {
+	if len(labels) == 0 {
+		return true
+	}
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the highest score of a triggering label in resp, from
+// classifications or bounding boxes.
+func match(resp edgeimpulse.RunnerClassifyResponse, labels []string) float64 {
+	var max float64
+	for l, v := range resp.Result.Classification {
+		if labelOK(l, labels) && v > max {
+			max = v
+		}
+	}
+	for _, b := range resp.Result.BoundingBoxes {
+		if labelOK(b.Label, labels) && b.Value > max {
+			max = b.Value
+		}
+	}
+	return max
+}
+
+// NewWake returns a cascade in which detections by the audio classifier wake
+// up classifying images from recorder with runner, for Opts.Duration after the
+// last detection. Images arriving while asleep are dropped. The cascade takes
+// ownership of wake, recorder and runner, and closes them on errors too. The
+// recorder and runner of wake must be closed by the caller, or added with Own.
+func NewWake(ctx context.Context, wake *audio.Classifier, recorder eimage.Recorder, runner edgeimpulse.Runner, opts ...Option) (*Cascade, error) {
+	c, xopts := newCascade(ctx, opts, 0.8)
+	logger := edgeimpulse.DefaultLogger(xopts.Logger, xopts.Verbose)
+	clock := edgeimpulse.DefaultClock(xopts.Clock)
+	ctx = c.group.Context()
+
+	gate := NewGate(ctx, recorder, clock)
+	c.group.Add(edgeimpulse.StageCapture, gate)
+	c.group.Add(edgeimpulse.StageClassify, wake)
+	c.group.Add(edgeimpulse.StageModel, runner)
+	ic, err := eimage.NewClassifier(ctx, runner, gate, eimage.WithLogger(logger))
+	if err != nil {
+		c.group.Close()
+		return nil, err
+	}
+	c.group.Add(edgeimpulse.StageClassify, ic)
+
+	var mutex sync.Mutex
+	var trigger edgeimpulse.RunnerClassifyResponse
+	var triggered time.Time
+
+	// Wake on detections.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ev := range wake.Events {
+			if ev.Err != nil {
+				if !c.send(Event{Err: fmt.Errorf("wake stage: %w", ev.Err)}) {
+					return
+				}
+				continue
+			}
+			if match(ev.RunnerClassifyResponse, xopts.Labels) < xopts.Threshold {
+				continue
+			}
+			logger.Logf(edgeimpulse.LogDebug, "cascade: waking for %v", xopts.Duration)
+			mutex.Lock()
+			trigger = ev.RunnerClassifyResponse
+			triggered = clock.Now()
+			mutex.Unlock()
+			gate.Open(xopts.Duration)
+		}
+	}()
+
+	go func() {
+		defer close(c.done)
+		defer close(c.Events)
+		defer wg.Wait()
+		for ev := range ic.Events {
+			if ev.Err != nil {
+				if !c.send(Event{Err: fmt.Errorf("image stage: %w", ev.Err)}) {
+					return
+				}
+				continue
+			}
+			mutex.Lock()
+			xev := Event{Trigger: trigger, Time: triggered}
+			mutex.Unlock()
+			xev.Results = []Result{{RunnerClassifyResponse: ev.RunnerClassifyResponse, Image: ev.Image}}
+			if !c.send(xev) {
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
+
+// NewCrop returns a cascade that detects objects in images from recorder with
+// detector, and classifies the region of each detection with classifier.
+// Detections are in the coordinates of the detector's input, regions are
+// mapped back to the camera image before cropping, see image.SourceRect. An
+// event is sent for each image with at least one detection. The cascade takes
+// ownership of recorder, detector and classifier, and closes them on errors
+// too.
+func NewCrop(ctx context.Context, recorder eimage.Recorder, detector, classifier edgeimpulse.Runner, opts ...Option) (*Cascade, error) {
+	c, xopts := newCascade(ctx, opts, 0.5)
+	logger := edgeimpulse.DefaultLogger(xopts.Logger, xopts.Verbose)
+	ctx = c.group.Context()
+
+	c.group.Add(edgeimpulse.StageCapture, recorder)
+	c.group.Add(edgeimpulse.StageModel, detector)
+	c.group.Add(edgeimpulse.StageModel, classifier)
+
+	mp := detector.ModelParameters()
+	// Not comparing with ModelTypeObjectDetection, FOMO models have type
+	// constrained_object_detection.
+	if mp.ModelType == edgeimpulse.ModelTypeClassification {
+		c.group.Close()
+		return nil, fmt.Errorf("detector model type is %q, expected object detection", mp.ModelType)
+	}
+	if cmp := classifier.ModelParameters(); cmp.SensorType != edgeimpulse.SensorTypeCamera {
+		c.group.Close()
+		return nil, fmt.Errorf("sensor for classifier model was %q, expected camera", cmp.SensorType)
+	}
+	ic, err := eimage.NewClassifier(ctx, detector, recorder, eimage.WithLogger(logger))
+	if err != nil {
+		c.group.Close()
+		return nil, err
+	}
+	c.group.Add(edgeimpulse.StageClassify, ic)
+	modelSize := image.Point{mp.ImageInputWidth, mp.ImageInputHeight}
+
+	go func() {
+		defer close(c.done)
+		defer close(c.Events)
+		for ev := range ic.Events {
+			if ev.Err != nil {
+				if !c.send(Event{Err: fmt.Errorf("detector stage: %w", ev.Err)}) {
+					return
+				}
+				continue
+			}
+			xev := Event{Trigger: ev.RunnerClassifyResponse, Time: time.Now()}
+			for _, crop := range eimage.Crops(ev.Image, ev.RunnerClassifyResponse, modelSize, xopts.Padding, xopts.Threshold) {
+				if !labelOK(crop.Label, xopts.Labels) {
+					continue
+				}
+				resp, _, err := eimage.Classify(classifier, crop.Image)
+				if err != nil {
+					if !c.send(Event{Err: fmt.Errorf("classifier stage: %w", err)}) {
+						return
+					}
+					continue
+				}
+				xev.Results = append(xev.Results, Result{resp, crop.Image, crop.Label, crop.Score, crop.Rect})
+			}
+			if len(xev.Results) == 0 {
+				continue
+			}
+			if !c.send(xev) {
+				return
+			}
+		}
+	}()
+
+	return c, nil
+}
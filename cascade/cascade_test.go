@@ -0,0 +1,138 @@
+package cascade
+
+import (
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	eimage "github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+// fakeRunner returns a fixed response for every classification.
+type fakeRunner struct {
+	params edgeimpulse.ModelParameters
+	resp   string
+	inputs chan []float64
+}
+
+func (r *fakeRunner) ModelParameters() edgeimpulse.ModelParameters { return r.params }
+func (r *fakeRunner) Project() edgeimpulse.Project                 { return edgeimpulse.Project{} }
+func (r *fakeRunner) Close() error                                 { return nil }
+
+func (r *fakeRunner) Classify(data []float64) (resp edgeimpulse.RunnerClassifyResponse, err error) {
+	if r.inputs != nil {
+		r.inputs <- append([]float64(nil), data...)
+	}
+	err = json.Unmarshal([]byte(r.resp), &resp)
+	return
+}
+
+type recorder struct {
+	events chan eimage.Event
+}
+
+func (r recorder) Events() chan eimage.Event { return r.events }
+func (r recorder) Close() error              { return nil }
+
+func cameraParams(modelType edgeimpulse.ModelType, size int) edgeimpulse.ModelParameters {
+	return edgeimpulse.ModelParameters{
+		ModelType:          modelType,
+		SensorType:         edgeimpulse.SensorTypeCamera,
+		ImageInputWidth:    size,
+		ImageInputHeight:   size,
+		ImageChannelCount:  3,
+		InputFeaturesCount: size * size,
+	}
+}
+
+func TestCrop(t *testing.T) {
+	detector := &fakeRunner{
+		params: cameraParams(edgeimpulse.ModelTypeObjectDetection, 8),
+		resp:   `{"success": true, "result": {"bounding_boxes": [{"label": "person", "value": 0.9, "x": 4, "y": 4, "width": 4, "height": 4}, {"label": "dog", "value": 0.9, "x": 0, "y": 0, "width": 2, "height": 2}]}}`,
+	}
+	classifier := &fakeRunner{
+		params: cameraParams(edgeimpulse.ModelTypeClassification, 2),
+		resp:   `{"success": true, "result": {"classification": {"standing": 0.7, "sitting": 0.3}}}`,
+		inputs: make(chan []float64, 1),
+	}
+
+	// Red bottom-right quadrant, where the person is detected.
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 8; y < 16; y++ {
+		for x := 8; x < 16; x++ {
+			img.Set(x, y, color.NRGBA{255, 0, 0, 255})
+		}
+	}
+	rec := recorder{make(chan eimage.Event, 1)}
+	rec.events <- eimage.Event{Image: img}
+
+	c, err := NewCrop(context.Background(), rec, detector, classifier, WithTrigger(0.5, "person"))
+	if err != nil {
+		t.Fatalf("new crop cascade: %v", err)
+	}
+	defer c.Close()
+
+	ev := <-c.Events
+	if ev.Err != nil {
+		t.Fatalf("got error %v", ev.Err)
+	}
+	if len(ev.Results) != 1 {
+		t.Fatalf("got %d results, expected 1", len(ev.Results))
+	}
+	r := ev.Results[0]
+	if r.Label != "person" || r.Region != image.Rect(8, 8, 16, 16) || r.Result.Classification["standing"] != 0.7 {
+		t.Fatalf("got result %+v", r)
+	}
+	for _, v := range <-classifier.inputs {
+		if v != 0xff0000 {
+			t.Fatalf("classified pixel %x, expected only red", int(v))
+		}
+	}
+
+	if _, err := NewCrop(context.Background(), rec, classifier, classifier); err == nil {
+		t.Fatalf("classification model accepted as detector")
+	}
+}
+
+func TestGate(t *testing.T) {
+	clock := edgeimpulse.NewManualClock(time.Now())
+	rec := recorder{make(chan eimage.Event)}
+	g := NewGate(context.Background(), rec, clock)
+	defer g.Close()
+
+	img := image.NewGray(image.Rect(0, 0, 1, 1))
+	// The recorder sending a second event means the gate handled the first.
+	sendTwo := func() {
+		rec.events <- eimage.Event{Image: img}
+		rec.events <- eimage.Event{Image: img}
+	}
+	empty := func() bool {
+		select {
+		case <-g.Events():
+			return false
+		default:
+			return true
+		}
+	}
+
+	sendTwo()
+	if !empty() {
+		t.Fatalf("image passed before opening")
+	}
+	g.Open(time.Second)
+	sendTwo()
+	if empty() {
+		t.Fatalf("no image after opening")
+	}
+	clock.Advance(2 * time.Second)
+	sendTwo()
+	empty() // An image from while open may still have been queued.
+	sendTwo()
+	if !empty() {
+		t.Fatalf("image passed after closing")
+	}
+}
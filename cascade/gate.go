@@ -0,0 +1,94 @@
+package cascade
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	eimage "github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+)
+
+// Gate is an image recorder that passes images from another recorder only
+// while open, e.g. after a detection by another model. Errors are always
+// passed.
+type Gate struct {
+	recorder eimage.Recorder
+	clock    edgeimpulse.Clock
+	events   chan eimage.Event
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mutex sync.Mutex
+	until time.Time
+}
+
+// Ensure that Gate implements the Recorder interface.
+var _ eimage.Recorder = (*Gate)(nil)
+
+// NewGate returns a closed gate for recorder. Closing the gate closes
+// recorder. If clock is nil, edgeimpulse.SystemClock is used.
+func NewGate(ctx context.Context, recorder eimage.Recorder, clock edgeimpulse.Clock) *Gate {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Gate{
+		recorder: recorder,
+		clock:    edgeimpulse.DefaultClock(clock),
+		events:   make(chan eimage.Event, 1),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	go func() {
+		defer close(g.done)
+		for {
+			var ev eimage.Event
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok = <-recorder.Events():
+				if !ok {
+					return
+				}
+			}
+			if ev.Err == nil && !g.IsOpen() {
+				continue
+			}
+			select {
+			case g.events <- ev:
+			default:
+				metrics.FramesDropped.Inc()
+			}
+		}
+	}()
+	return g
+}
+
+// Open passes images for d from now, or longer if the gate was already open
+// longer.
+func (g *Gate) Open(d time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if until := g.clock.Now().Add(d); until.After(g.until) {
+		g.until = until
+	}
+}
+
+// IsOpen returns whether images are passed.
+func (g *Gate) IsOpen() bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.clock.Now().Before(g.until)
+}
+
+// Events returns the channel on which passed images are sent.
+func (g *Gate) Events() chan eimage.Event {
+	return g.events
+}
+
+// Close closes the gate and its recorder.
+func (g *Gate) Close() error {
+	g.cancel()
+	<-g.done
+	return g.recorder.Close()
+}
@@ -0,0 +1,202 @@
+package edgeimpulse
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time, and creates timers and tickers. Recorders and
+// classifiers use a Clock for throttling, pacing and latencies, so tests can
+// control time with a ManualClock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer sends the time on its channel once after a duration, like time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool // Returns false if the timer already fired or was stopped.
+}
+
+// Ticker sends the time on its channel at intervals, like time.Ticker. Ticks
+// are dropped for slow receivers.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTicker) Stop() {
+	t.t.Stop()
+}
+
+// SystemClock is the Clock for the real time.
+var SystemClock Clock = systemClock{}
+
+// DefaultClock returns clock, or SystemClock if clock is nil.
+func DefaultClock(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock
+	}
+	return clock
+}
+
+// ManualClock is a Clock whose time only changes by calling Set or Advance.
+// Its timers and tickers fire when the time is moved past their deadline. It
+// is safe for concurrent use.
+type ManualClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*manualWaiter
+}
+
+// manualWaiter is a timer, or a ticker if period is non-zero.
+type manualWaiter struct {
+	clock  *ManualClock
+	c      chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+// NewManualClock returns a ManualClock set to now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *ManualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Set sets the time of the clock, firing timers and tickers that are due.
+func (c *ManualClock) Set(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+	c.fire()
+}
+
+// Advance moves the time of the clock forward by d, and returns the new time.
+// Timers and tickers that are due fire.
+func (c *ManualClock) Advance(d time.Duration) time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+	return c.now
+}
+
+// NewTimer returns a timer that fires once the time of the clock has moved d
+// forward.
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	return c.add(d, 0)
+}
+
+// NewTicker returns a ticker that fires each time the clock has moved d
+// forward. Like time.NewTicker, it panics if d is not positive.
+func (c *ManualClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for ManualClock.NewTicker")
+	}
+	return manualTicker{c.add(d, d)}
+}
+
+func (c *ManualClock) add(d, period time.Duration) *manualWaiter {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	w := &manualWaiter{clock: c, c: make(chan time.Time, 1), when: c.now.Add(d), period: period}
+	c.waiters = append(c.waiters, w)
+	c.fire()
+	return w
+}
+
+// fire sends the time on the channels of due waiters, and removes fired
+// timers. Must be called with mutex held.
+func (c *ManualClock) fire() {
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.when.After(c.now) {
+			select {
+			case w.c <- c.now:
+			default:
+			}
+			if w.period == 0 {
+				continue
+			}
+			for !w.when.After(c.now) {
+				w.when = w.when.Add(w.period)
+			}
+		}
+		waiters = append(waiters, w)
+	}
+	for i := len(waiters); i < len(c.waiters); i++ {
+		c.waiters[i] = nil
+	}
+	c.waiters = waiters
+}
+
+// remove removes w from the waiters, returning whether it was present.
+func (c *ManualClock) remove(w *manualWaiter) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, x := range c.waiters {
+		if x == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *manualWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *manualWaiter) Stop() bool {
+	return w.clock.remove(w)
+}
+
+type manualTicker struct {
+	*manualWaiter
+}
+
+func (t manualTicker) Stop() {
+	t.manualWaiter.Stop()
+}
@@ -0,0 +1,53 @@
+package edgeimpulse_test
+
+import (
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestManualClock(t *testing.T) {
+	clock := edgeimpulse.NewManualClock(time.Unix(1000, 0))
+	timer := clock.NewTimer(time.Second)
+	ticker := clock.NewTicker(300 * time.Millisecond)
+	defer ticker.Stop()
+
+	fired := func(c <-chan time.Time) bool {
+		select {
+		case <-c:
+			return true
+		default:
+			return false
+		}
+	}
+
+	clock.Advance(200 * time.Millisecond)
+	if fired(timer.C()) || fired(ticker.C()) {
+		t.Fatalf("fired before deadline")
+	}
+	clock.Advance(100 * time.Millisecond)
+	if fired(timer.C()) || !fired(ticker.C()) {
+		t.Fatalf("ticker did not fire at interval")
+	}
+	clock.Advance(time.Second)
+	if !fired(timer.C()) || !fired(ticker.C()) {
+		t.Fatalf("timer or ticker did not fire after deadline")
+	}
+	if timer.Stop() {
+		t.Fatalf("stop of fired timer returned true")
+	}
+	clock.Advance(time.Second)
+	if fired(timer.C()) {
+		t.Fatalf("timer fired twice")
+	}
+
+	timer = clock.NewTimer(time.Second)
+	if !timer.Stop() {
+		t.Fatalf("stop of pending timer returned false")
+	}
+	clock.Advance(time.Second)
+	if fired(timer.C()) {
+		t.Fatalf("stopped timer fired")
+	}
+}
@@ -9,43 +9,190 @@
 //	# Classify audio, and apply a moving average filter with a history of 4.
 //	eimaudio -maf 4 -verbose -interval 250ms ../../custom-keywords.eim
 //
+//	# Classify audio with windows overlapping by half, without averaging.
+//	eimaudio -overlap 0.5 -maf 0 ../../custom-keywords.eim
+//
+//	# Send each slice once to a model in continuous mode, which keeps the
+//	# rest of the window, instead of overlapping windows.
+//	eimaudio -continuous ../../custom-keywords.eim
+//
 //	# List audio devices, to be used with the -device flag.
 //	eimaudio -listdevices
 //
 //	# List audio devices, to be used with the -device flag.
 //	eimaudio -device hw:0,0 ../../custom-keywords.eim
+//
+//	# Switch on a relay at gpio pin 17 for 2 seconds when "yes" is heard.
+//	eimaudio -gpio 17 -gpio-label yes -gpio-duration 2s ../../custom-keywords.eim
+//
+//	# Upload audio windows the model is unsure about, for retraining.
+//	eimaudio -upload-apikey ei_... -upload-category split ../../custom-keywords.eim
+//
+//	# Recognize "hey device, lights on" and "hey device, lights off" as commands.
+//	eimaudio -command 'lights_on=hey_device+on,lights_off=hey_device+off' -command-window 3s ../../custom-keywords.eim
+//
+//	# Send per-minute counts of labels to a server every 5 minutes, instead of
+//	# every result, keeping summaries on disk while offline.
+//	eimaudio -agg-url https://example.com/summaries -agg-spool /var/lib/eimaudio/agg -agg-notable yes ../../custom-keywords.eim
+//
+//	# Monitor two machines with a microphone each on the left and right
+//	# channel of a stereo device.
+//	eimaudio -channels 2 -channel-names pump,compressor -device hw:1,0 ../../machine-faults.eim
+//
+//	# Classify a field recording, e.g. with 24 bit samples, instead of the
+//	# microphone.
+//	eimaudio -file recording.wav ../../custom-keywords.eim
+//
+//	# Classify one window of audio recorded after each press of enter, e.g.
+//	# for push-to-talk. Also "gpio:17" for a button, or "http::8081" for POST
+//	# requests to /trigger.
+//	eimaudio -trigger key ../../custom-keywords.eim
+//
+//	# Use settings from a configuration file, see package config. Flags
+//	# override EI_* environment variables, which override the file, and
+//	# apply without -config too.
+//	eimaudio -config keywords.json
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"net/http"
 	"os"
-	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
-	"github.com/edgeimpulse/linux-sdk-go/audio"
-	"github.com/edgeimpulse/linux-sdk-go/audio/audiocmd"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/agg"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio/audiocmd"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio/wav"
+	"github.com/edgeimpulse/linux-sdk-go/v2/config"
+	"github.com/edgeimpulse/linux-sdk-go/v2/gpio"
+	"github.com/edgeimpulse/linux-sdk-go/v2/health"
+	"github.com/edgeimpulse/linux-sdk-go/v2/ingest"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/exit"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/score"
+	"github.com/edgeimpulse/linux-sdk-go/v2/location"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+	"github.com/edgeimpulse/linux-sdk-go/v2/pipeline"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sink"
+	"github.com/edgeimpulse/linux-sdk-go/v2/status"
 )
 
 var (
-	listDevices bool
-	interval    time.Duration
-	mafSize     int
-	verbose     bool
-	traceDir    string
-	deviceID    string
+	configPath   string
+	tempRoot     string
+	listDevices  bool
+	interval     time.Duration
+	overlap      float64
+	mafSize      int
+	verbose      bool
+	traceDir     string
+	modelThreads int
+	modelEnv     string
+	modelArgs    string
+	deviceID     string
+	audioFile    string
+	triggerSpec  string
+	continuous   bool
+
+	modelRestartAfter time.Duration
+	modelRestartCount int64
+	modelTimeout      time.Duration
+	modelHelloTimeout time.Duration
+	modelRespawn      bool
+
+	gpioLine      string
+	gpioLabel     string
+	gpioThreshold float64
+	gpioDuration  time.Duration
+
+	healthAddr      string
+	healthIntervals int
+
+	uploadAPIKey   string
+	uploadCategory string
+	uploadTags     string
+	uploadVersion  string
+	uncertainMin   float64
+	uncertainMax   float64
+
+	sinks        sink.Specs
+	filters      string
+	locationSpec string
+
+	aggURL     string
+	aggSpool   string
+	aggNotable string
+	aggBucket  time.Duration
+	aggSync    time.Duration
+
+	commands         string
+	commandWindow    time.Duration
+	commandThreshold float64
+
+	channels     int
+	channelNames string
 )
 
 func init() {
 	flag.BoolVar(&listDevices, "listdevices", false, "if set, lists devices and exits")
-	flag.DurationVar(&interval, "interval", 250*time.Millisecond, "classify audio every interval")
-	flag.IntVar(&mafSize, "maf", 0, "apply moving-average-filter for all labels of the model of given size (only if >0)")
+	flag.DurationVar(&interval, "interval", 0, "classify audio every interval, by default the slice length of the model, or a quarter of its window")
+	flag.Float64Var(&overlap, "overlap", 0, "if >0 and no -interval is set, fraction of the model window that consecutive classifications overlap, e.g. 0.75")
+	flag.IntVar(&mafSize, "maf", -1, "apply moving-average-filter for all labels of the model of given size (only if >0), by default derived from the performance calibration of the model")
 	flag.BoolVar(&verbose, "verbose", false, "print more logging")
 	flag.StringVar(&traceDir, "tracedir", "", "if set, store the parsed classify data to the named directory")
+	flag.IntVar(&modelThreads, "model-threads", 0, "if > 0, number of threads the model may use for inference, if its engine supports it; -1 for a thread per performance core, e.g. the big cores of big.LITTLE socs")
+	flag.DurationVar(&modelRestartAfter, "model-restart-after", 0, "if > 0, restart the model process after running this long, e.g. 168h, to mitigate slow memory growth; the new process is started before the old one is stopped")
+	flag.Int64Var(&modelRestartCount, "model-restart-classifications", 0, "if > 0, restart the model process after this many classifications")
+	flag.DurationVar(&modelTimeout, "model-timeout", edgeimpulse.DefaultClassifyTimeout, "maximum time to wait for a classification by the model process, e.g. longer for slow models on low-power boards")
+	flag.DurationVar(&modelHelloTimeout, "model-hello-timeout", edgeimpulse.DefaultHelloTimeout, "maximum time to wait for the model process to start")
+	flag.BoolVar(&modelRespawn, "model-respawn", false, "if set, restart the model process when it crashes, failing only the classification in progress")
+	flag.StringVar(&modelEnv, "model-env", "", "comma-separated environment variables for the model process, e.g. USE_GPU_INFERENCE=0 to select the npu delegate on i.mx 8m plus")
+	flag.StringVar(&modelArgs, "model-args", "", "space-separated additional command-line arguments for the model process")
 	flag.StringVar(&deviceID, "device", "", "if set, device ID is used for microphone instead of the default microphone")
+	flag.StringVar(&audioFile, "file", "", "if set, classify this wav file, played back in real time, instead of recording a microphone; 8 to 32 bit pcm and float samples are supported, at the sample rate of the model")
+	flag.BoolVar(&continuous, "continuous", false, "for models in continuous mode, send each slice once with the model keeping the window, instead of overlapping windows every interval; the interval is the slice length")
+	flag.StringVar(&triggerSpec, "trigger", "", "if set, classify only one window of audio recorded after each trigger, instead of continuously: key for enter on stdin, gpio:line for a rising edge of a gpio input line like gpio:17 or gpio:gpiochip0:17, or http:addr for POST requests to /trigger, e.g. http::8081")
+	flag.IntVar(&channels, "channels", 1, "number of channels to record, each classified independently with the same model, e.g. 2 for a stereo device with a microphone per machine")
+	flag.StringVar(&channelNames, "channel-names", "", "comma-separated names of the channels, used as source of results, e.g. left,right; by default the channel numbers starting at 1")
+	flag.StringVar(&configPath, "config", "", "if set, json configuration file with defaults for flags and the model, see package config; EI_* environment variables apply regardless")
+	flag.StringVar(&exit.Format, "error-format", "text", "format of fatal errors written to stderr: text or json")
+	flag.StringVar(&tempRoot, "tempdir", "", "if set, directory for temporary files of the model process and recorders, instead of /dev/shm or the os default")
+	flag.StringVar(&gpioLine, "gpio", "", "if set, gpio line to drive high when -gpio-label is detected, either a sysfs pin number like 17, or a gpiod chip and line like gpiochip0:17")
+	flag.StringVar(&gpioLabel, "gpio-label", "", "label that drives the gpio line high")
+	flag.Float64Var(&gpioThreshold, "gpio-threshold", 0.8, "minimum score for -gpio-label to drive the gpio line high")
+	flag.DurationVar(&gpioDuration, "gpio-duration", time.Second, "how long to keep the gpio line high after a detection")
+	flag.StringVar(&healthAddr, "health-addr", "", "if set, address to serve http health endpoints /healthz and /readyz, latency statistics on /debug/vars, prometheus metrics on /metrics and pipeline state on /debug/edgeimpulse, e.g. :8080")
+	flag.IntVar(&healthIntervals, "health-intervals", 10, "number of intervals without classification after which the health endpoints fail")
+	flag.StringVar(&uploadAPIKey, "upload-apikey", os.Getenv("EI_API_KEY"), "if set, upload audio windows with an uncertain top score to EdgeImpulse with this api key, for active learning")
+	flag.StringVar(&uploadTags, "upload-tags", "", "comma-separated tags stored as metadata with all uploaded audio windows, e.g. to filter a collection campaign in studio; uploads are also stamped with a session id")
+	flag.StringVar(&uploadVersion, "upload-dataset-version", "", "if set, dataset version stored as metadata with all uploaded audio windows")
+	flag.StringVar(&uploadCategory, "upload-category", "training", "category for uploaded audio windows: split, training or testing")
+	flag.Float64Var(&uncertainMin, "uncertain-min", 0.4, "lowest top score considered uncertain")
+	flag.Float64Var(&uncertainMax, "uncertain-max", 0.7, "highest top score considered uncertain")
+	flag.Var(&sinks, "sink", "where to send results, repeatable: text or json for stdout, file:path for json lines with rotation, mqtt://host:port/topic, or an http(s) webhook url; default text")
+	flag.StringVar(&locationSpec, "location", "", "if set, attach the position from a gnss receiver to results and uploads: gpsd, gpsd:host:port, nmea:/dev/ttyUSB0 or nmea:/dev/ttyUSB0:baud")
+	flag.StringVar(&commands, "command", "", "if set, comma-separated voice commands recognized as sequences of keywords and sent as results, e.g. lights_on=hey_device+on,stop")
+	flag.DurationVar(&commandWindow, "command-window", 3*time.Second, "time within which all keywords of a command must be heard")
+	flag.Float64Var(&commandThreshold, "command-threshold", 0.8, "minimum score for a label to count as a heard keyword")
+	flag.StringVar(&aggURL, "agg-url", "", "if set, aggregate results into summaries with counts per label and notable events, and POST them to this url periodically")
+	flag.StringVar(&aggSpool, "agg-spool", "", "if set, directory keeping summaries that are not yet synced, e.g. while offline; also enables aggregation without -agg-url")
+	flag.StringVar(&aggNotable, "agg-notable", "", "comma-separated labels whose detections are included in summaries as events, with a snapshot of the input")
+	flag.DurationVar(&aggBucket, "agg-bucket", time.Minute, "duration of a summary")
+	flag.DurationVar(&aggSync, "agg-sync", 5*time.Minute, "how often summaries are synced")
+	flag.StringVar(&filters, "filters", "", "comma-separated post-processing filters applied to results in order, e.g. ema:0.5,threshold:0.6; filters: maf:size, ema:alpha, threshold:min, nms:iou, tracker:alpha, debounce:threshold:release:activate:deactivate, vote:size, cooldown:seconds:threshold, labels:path.json")
 }
 
 func usage() {
@@ -59,6 +206,18 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 	args := flag.Args()
+	// EI_* environment variables always apply, the file only with -config.
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		exit.Fatalf(exit.Config, "%v", err)
+	}
+	if err := cfg.ApplyFlags(flag.CommandLine); err != nil {
+		exit.Fatalf(exit.Config, "%v", err)
+	}
+	if len(args) == 0 && cfg.Model != "" {
+		args = []string{cfg.Model}
+	}
+	edgeimpulse.SetTempRoot(tempRoot)
 	os.Exit(main0(args))
 }
 
@@ -66,91 +225,549 @@ func main0(args []string) int {
 	if listDevices {
 		devs, err := audiocmd.ListDevices()
 		if err != nil {
-			log.Fatalf("listing devices: %v", err)
+			exit.Fatalf(exit.Device, "listing devices: %v", err)
 		}
 		for _, dev := range devs {
 			log.Printf("%v: %v", dev.ID, dev.Name)
 		}
-		os.Exit(0)
+		os.Exit(exit.OK)
 	}
 
 	if len(args) != 1 {
 		usage()
 	}
+
+	var checker *health.Checker
+	var state *status.Status
+	if healthAddr != "" {
+		checker = health.NewChecker()
+		state = status.New(nil, 20)
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/healthz", checker)
+			mux.Handle("/readyz", checker)
+			mux.Handle("/debug/vars", expvar.Handler())
+			mux.Handle("/metrics", metrics.Handler(metrics.Default))
+			mux.Handle("/debug/edgeimpulse", state)
+			if err := http.ListenAndServe(healthAddr, mux); err != nil {
+				log.Printf("serving health endpoints: %v", err)
+			}
+		}()
+	}
+
+	// Handle signals, so components are shut down in order and the runners
+	// temporary directory is cleaned up.
+	group := edgeimpulse.NewGroup(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer group.Close()
+	ctx := group.Context()
+
 	ropts := &edgeimpulse.RunnerOpts{
-		TraceDir: traceDir,
+		TraceDir:        traceDir,
+		Threads:         modelThreads,
+		ClassifyTimeout: modelTimeout,
+		HelloTimeout:    modelHelloTimeout,
+		Respawn:         modelRespawn,
 	}
-	runner, err := edgeimpulse.NewRunnerProcess(args[0], ropts)
+	if modelEnv != "" {
+		ropts.Env = strings.Split(modelEnv, ",")
+	}
+	ropts.Args = strings.Fields(modelArgs)
+	var runner edgeimpulse.Runner
+	var err error
+	if modelRestartAfter > 0 || modelRestartCount > 0 {
+		open := func() (edgeimpulse.Runner, error) {
+			r, err := edgeimpulse.NewRunnerProcess(args[0], ropts)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		}
+		runner, err = edgeimpulse.NewRestartRunner(open, edgeimpulse.RestartRunnerOpts{MaxAge: modelRestartAfter, MaxClassifications: modelRestartCount})
+	} else {
+		runner, err = edgeimpulse.NewRunnerProcess(args[0], ropts)
+	}
+	if err != nil {
+		return exit.Errorf(exit.Model, "new runner: %v", err)
+	}
+	group.Add(edgeimpulse.StageModel, runner)
+
+	results, err := sinks.Open("text")
 	if err != nil {
-		log.Printf("new runner: %v", err)
-		return 1
+		return exit.Errorf(exit.Config, "opening sinks: %v", err)
+	}
+	group.Add(edgeimpulse.StageOutput, results)
+
+	var loc location.Provider
+	if locationSpec != "" {
+		loc, err = location.Open(ctx, locationSpec)
+		if err != nil {
+			return exit.Errorf(exit.Device, "opening location provider: %v", err)
+		}
+		group.Add(edgeimpulse.StageOutput, loc)
+	}
+
+	if _, err := pipeline.Parse(filters, runner.ModelParameters().Labels); err != nil {
+		return exit.Errorf(exit.Config, "parsing filters: %v", err)
+	}
+	if channels < 1 {
+		return exit.Errorf(exit.Config, "-channels must be > 0")
+	}
+	names := make([]string, channels)
+	for i := range names {
+		names[i] = fmt.Sprintf("%d", i+1)
+	}
+	if channelNames != "" {
+		names = strings.Split(channelNames, ",")
+		if len(names) != channels {
+			return exit.Errorf(exit.Config, "-channel-names has %d names, expected %d", len(names), channels)
+		}
 	}
-	defer runner.Close()
 
 	log.Printf("project %s\nmodel %s", runner.Project(), runner.ModelParameters())
+	if r, ok := runner.(interface{ Threads() int }); ok && r.Threads() > 0 {
+		log.Printf("model threads %d", r.Threads())
+	}
 
-	recOpts := &audiocmd.RecorderOpts{
-		SampleRate:    int(runner.ModelParameters().Frequency),
-		Channels:      1,
-		AsRaw:         true,
-		RecordProgram: "sox",
-		Verbose:       verbose,
-		DeviceID:      deviceID,
+	if overlap < 0 || overlap >= 1 {
+		return exit.Errorf(exit.Config, "-overlap must be >= 0 and < 1")
 	}
-	recorder, err := audiocmd.NewRecorder(recOpts)
-	if err != nil {
-		log.Printf("new recorder: %v", err)
-		return 1
+	if continuous {
+		if channels > 1 || triggerSpec != "" {
+			return exit.Errorf(exit.Config, "-continuous cannot be combined with multiple -channels or -trigger")
+		}
+		interval = modelInterval(runner.ModelParameters(), 0)
+	} else if interval == 0 {
+		interval = modelInterval(runner.ModelParameters(), overlap)
+	}
+	if mafSize < 0 {
+		mafSize = modelMAFSize(runner.ModelParameters(), interval)
+	}
+	if verbose {
+		log.Printf("classifying every %v", interval)
 	}
-	defer recorder.Close()
 
-	copts := &audio.ClassifierOpts{
-		Verbose: verbose,
+	var recorder audio.Recorder
+	if audioFile != "" {
+		f, err := wav.NewRecorder(audioFile, true)
+		if err != nil {
+			return exit.Errorf(exit.Config, "-file: %v", err)
+		}
+		if f.SampleRate != int(runner.ModelParameters().Frequency) || f.Channels != channels {
+			return exit.Errorf(exit.Config, "-file: %d channels at %d Hz, model and -channels need %d at %v Hz", f.Channels, f.SampleRate, channels, runner.ModelParameters().Frequency)
+		}
+		recorder = f
+	} else {
+		recOpts := &audiocmd.RecorderOpts{
+			SampleRate:    int(runner.ModelParameters().Frequency),
+			Channels:      channels,
+			AsRaw:         true,
+			RecordProgram: "sox",
+			Verbose:       verbose,
+			DeviceID:      deviceID,
+		}
+		r, err := audiocmd.NewRecorder(ctx, recOpts)
+		if err != nil {
+			return exit.Errorf(exit.Device, "new recorder: %v", err)
+		}
+		recorder = r
 	}
-	ac, err := audio.NewClassifier(runner, recorder, interval, copts)
-	if err != nil {
-		log.Printf("new audio classifier: %v", err)
-		return 1
+	recorders := []audio.Recorder{recorder}
+	if channels > 1 {
+		// Closing the last channel closes the recorder.
+		recorders, err = audio.Split(recorder, channels)
+		if err != nil {
+			recorder.Close()
+			return exit.Errorf(exit.Config, "splitting channels: %v", err)
+		}
+	}
+	for _, r := range recorders {
+		group.Add(edgeimpulse.StageCapture, r)
+	}
+
+	var triggers []chan struct{}
+	if triggerSpec != "" {
+		src, err := startTrigger(ctx, triggerSpec)
+		if err != nil {
+			return exit.Errorf(exit.Config, "trigger: %v", err)
+		}
+		// Each trigger captures a window on all channels.
+		for i := 0; i < channels; i++ {
+			triggers = append(triggers, make(chan struct{}, 1))
+		}
+		go func() {
+			for range src {
+				for _, c := range triggers {
+					select {
+					case c <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
 	}
-	defer ac.Close()
 
-	var maf *edgeimpulse.MAF
-	if mafSize > 0 {
-		if verbose {
-			log.Printf("applying moving average filter of size %d", mafSize)
+	// Each channel is classified and post-processed independently, sharing
+	// the runner.
+	chans := make([]*channel, channels)
+	for i := range chans {
+		ch := &channel{source: "eimaudio"}
+		if channels > 1 {
+			ch.source = "eimaudio:" + names[i]
 		}
-		maf, err = edgeimpulse.NewMAF(mafSize, runner.ModelParameters().Labels)
+		copts := &audio.ClassifierOpts{
+			Verbose:    verbose,
+			Continuous: continuous,
+		}
+		if triggers != nil {
+			copts.Trigger = triggers[i]
+		}
+		ch.classifier, err = audio.NewClassifier(ctx, runner, recorders[i], interval, copts)
 		if err != nil {
-			log.Printf("new MAF: %v", err)
+			return exit.Errorf(exit.Model, "new audio classifier: %v", err)
+		}
+		group.Add(edgeimpulse.StageClassify, ch.classifier)
+		statsName, componentName := "stats", "classifier"
+		if channels > 1 {
+			statsName += "-" + names[i]
+			componentName += "-" + names[i]
 		}
+		expvar.Publish(statsName, ch.classifier.Stats())
+		if state != nil {
+			state.AddComponent(componentName, ch.classifier.Err)
+		}
+		ch.pipe, _ = pipeline.Parse(filters, runner.ModelParameters().Labels)
+		if mafSize > 0 {
+			ch.maf, err = edgeimpulse.NewMAF(mafSize, runner.ModelParameters().Labels)
+			if err != nil {
+				log.Printf("new MAF: %v", err)
+			}
+		}
+		chans[i] = ch
+	}
+	if state != nil {
+		state.SetRunner(runner)
+	}
+	if mafSize > 0 && verbose {
+		log.Printf("applying moving average filter of size %d", mafSize)
 	}
 
-	// Handle signals, so cleanup of the runners temporary directory is done.
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	var trigger *gpio.Trigger
+	if gpioLine != "" {
+		if gpioLabel == "" {
+			return exit.Errorf(exit.Config, "-gpio requires -gpio-label")
+		}
+		line, err := gpio.Open(gpioLine)
+		if err != nil {
+			return exit.Errorf(exit.Device, "opening gpio line: %v", err)
+		}
+		group.Add(edgeimpulse.StageOutput, line)
+		trigger = gpio.NewTrigger(line, gpioDuration)
+		group.Add(edgeimpulse.StageOutput, trigger)
+	}
+
+	if commands != "" {
+		l, err := edgeimpulse.ParseCommands(commands)
+		if err != nil {
+			return exit.Errorf(exit.Config, "parsing commands: %v", err)
+		}
+		for _, ch := range chans {
+			ch.grammar, err = edgeimpulse.NewGrammar(commandWindow, l...)
+			if err != nil {
+				return exit.Errorf(exit.Config, "new grammar: %v", err)
+			}
+			ch.keywords, err = edgeimpulse.NewDetector(edgeimpulse.DetectorOpts{Threshold: commandThreshold})
+			if err != nil {
+				return exit.Errorf(exit.Config, "new keyword detector: %v", err)
+			}
+		}
+	}
+
+	var aggregator *agg.Aggregator
+	if aggURL != "" || aggSpool != "" {
+		var notable []string
+		if aggNotable != "" {
+			notable = strings.Split(aggNotable, ",")
+		}
+		aggregator, err = agg.New(
+			agg.WithURL(aggURL),
+			agg.WithSpoolDir(aggSpool),
+			agg.WithNotable(notable...),
+			agg.WithBucket(aggBucket),
+			agg.WithSyncInterval(aggSync),
+			agg.WithVerbose(verbose),
+		)
+		if err != nil {
+			return exit.Errorf(exit.Config, "new aggregator: %v", err)
+		}
+		group.Add(edgeimpulse.StageOutput, aggregator)
+	}
+
+	var queue *ingest.Queue
+	if uploadAPIKey != "" {
+		collector, err := ingest.NewCollector(uploadAPIKey, "")
+		if err != nil {
+			return exit.Errorf(exit.Config, "new collector: %v", err)
+		}
+		collector.Session, err = ingest.NewSession(ingest.ParseTags(uploadTags), uploadVersion)
+		if err != nil {
+			return exit.Errorf(exit.Runtime, "new upload session: %v", err)
+		}
+		log.Printf("upload session %s", collector.Session.ID)
+		queue = ingest.NewQueue(collector, uploadCategory, 10, log.Printf)
+		group.Add(edgeimpulse.StageOutput, queue)
+	}
+
+	if checker != nil {
+		checker.Ready(time.Duration(healthIntervals) * interval)
+		if triggers != nil {
+			// Classifications only happen on triggers.
+			checker.SetPaused(true)
+		}
+	}
 
-	// Keep reading classification events.
+	// Keep reading classification events of all channels.
+	events := make(chan channelEvent)
+	var wg sync.WaitGroup
+	for _, ch := range chans {
+		wg.Add(1)
+		go func(ch *channel) {
+			defer wg.Done()
+			for ev := range ch.classifier.Events {
+				select {
+				case events <- channelEvent{ch, ev}:
+				case <-group.Done():
+					return
+				}
+			}
+		}(ch)
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
 	for {
 		select {
-		case <-signals:
-			return 1
-		case ev, ok := <-ac.Events:
+		case <-group.Done():
+			// Stopped by a signal.
+			return exit.OK
+		case cev, ok := <-events:
 			if !ok {
-				log.Printf("no more events")
-				return 0
+				if group.Signal() != nil {
+					return exit.OK
+				}
+				// The classifiers stopped after a fatal error, e.g. of
+				// the recorder or model.
+				return exit.Errorf(exit.Runtime, "no more events")
+			}
+			ch, ev := cev.ch, cev.ev
+			if audioFile != "" && (errors.Is(ev.Err, io.EOF) || errors.Is(ev.Err, io.ErrUnexpectedEOF)) {
+				log.Printf("end of %s", audioFile)
+				return exit.OK
+			}
+			if !modelRespawn && errors.Is(ev.Err, edgeimpulse.ErrModelExited) {
+				// Without respawning, every next window would fail too.
+				return exit.Errorf(exit.Model, "%v", ev.Err)
 			}
 			if ev.Err != nil {
 				log.Printf("%s", ev.Err)
+				if state != nil {
+					state.RecordError(ev.Err)
+				}
 			} else {
-				if maf != nil {
-					r, err := maf.Update(ev.RunnerClassifyResponse.Result.Classification)
+				if ch.maf != nil {
+					r, err := ch.maf.Update(ev.RunnerClassifyResponse.Result.Classification)
 					if err != nil {
 						log.Printf("update moving average filter: %v", err)
 					}
 					ev.RunnerClassifyResponse.Result.Classification = r
 				}
-				fmt.Printf("%s\n", ev.RunnerClassifyResponse)
+				ev.RunnerClassifyResponse, err = ch.pipe.Apply(ev.RunnerClassifyResponse)
+				if err != nil {
+					log.Printf("applying filters: %v", err)
+				}
+				result := sink.Result{Time: time.Now(), Source: ch.source, Response: ev.RunnerClassifyResponse, Location: location.Current(loc)}
+				if err := results.Send(ctx, result); err != nil {
+					log.Printf("sending result: %v", err)
+				}
+				if aggregator != nil {
+					aggregator.Add(result, func() ([]byte, string) {
+						samples := make([]int16, len(ev.Samples))
+						for i, v := range ev.Samples {
+							samples[i] = int16(v)
+						}
+						var buf bytes.Buffer
+						if err := wav.Encode(&buf, samples, int(runner.ModelParameters().Frequency), 1); err != nil {
+							log.Printf("encoding snapshot: %v", err)
+							return nil, ""
+						}
+						return buf.Bytes(), "audio/wav"
+					})
+				}
+				if state != nil {
+					state.Record(ev.RunnerClassifyResponse)
+				}
+				if checker != nil {
+					checker.Event()
+				}
+				if queue != nil {
+					uploadUncertain(queue, runner.Project(), location.Current(loc), ev, runner.ModelParameters().Frequency)
+				}
+				if ch.grammar != nil {
+					now := time.Now()
+					for _, cev := range ch.grammar.Update(ch.keywords.Update(ev.RunnerClassifyResponse.Result.Classification), now) {
+						result := sink.Result{Time: now, Source: ch.source, Response: ev.RunnerClassifyResponse, Location: location.Current(loc), Command: cev.Command}
+						if err := results.Send(ctx, result); err != nil {
+							log.Printf("sending command: %v", err)
+						}
+					}
+				}
+				if trigger != nil && score.Label(ev.RunnerClassifyResponse, gpioLabel) >= gpioThreshold {
+					if err := trigger.Fire(); err != nil {
+						log.Printf("setting gpio line: %v", err)
+					}
+				}
 			}
 		}
 	}
 }
+
+// channel is an audio channel, classified and post-processed independently.
+type channel struct {
+	source     string // Source of results.
+	classifier *audio.Classifier
+	pipe       *pipeline.Pipeline
+	maf        *edgeimpulse.MAF
+	keywords   *edgeimpulse.Detector
+	grammar    *edgeimpulse.Grammar
+}
+
+// channelEvent is a classification event of a channel.
+type channelEvent struct {
+	ch *channel
+	ev audio.ClassifyEvent
+}
+
+// startTrigger starts the trigger source described by spec, see the -trigger
+// flag. Values are dropped while a previous trigger is pending.
+func startTrigger(ctx context.Context, spec string) (<-chan struct{}, error) {
+	c := make(chan struct{}, 1)
+	send := func() {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+	switch {
+	case spec == "key":
+		log.Printf("press enter to classify")
+		go func() {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				send()
+			}
+		}()
+		return c, nil
+	case strings.HasPrefix(spec, "gpio:"):
+		return gpio.Rising(ctx, strings.TrimPrefix(spec, "gpio:"))
+	case strings.HasPrefix(spec, "http:"):
+		mux := http.NewServeMux()
+		mux.HandleFunc("/trigger", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			send()
+			w.WriteHeader(http.StatusAccepted)
+		})
+		srv := &http.Server{Addr: strings.TrimPrefix(spec, "http:"), Handler: mux}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("serving trigger endpoint: %v", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			srv.Close()
+		}()
+		return c, nil
+	}
+	return nil, fmt.Errorf("unknown trigger %q, need key, gpio:line or http:addr", spec)
+}
+
+// modelInterval returns the interval between classifications for the model.
+// With a positive overlap, the interval is the part of the window that does not
+// overlap with the previous window. Otherwise the slice length of the model is
+// used, falling back to a quarter of the window.
+func modelInterval(mp edgeimpulse.ModelParameters, overlap float64) time.Duration {
+	if mp.Frequency <= 0 || mp.InputFeaturesCount <= 0 {
+		return 250 * time.Millisecond
+	}
+	window := time.Duration(float64(mp.InputFeaturesCount) / mp.Frequency * float64(time.Second))
+	if overlap > 0 {
+		return time.Duration(float64(window) * (1 - overlap))
+	}
+	if mp.SliceSize > 0 {
+		return time.Duration(float64(mp.SliceSize) / mp.Frequency * float64(time.Second))
+	}
+	return window / 4
+}
+
+// modelMAFSize returns the size of a moving average filter that averages over
+// the window configured in the performance calibration of the model, or 0 if
+// the model has no performance calibration.
+func modelMAFSize(mp edgeimpulse.ModelParameters, interval time.Duration) int {
+	pc := mp.PerformanceCalibration
+	if pc == nil || pc.AverageWindowDurationMS <= 0 || interval <= 0 {
+		return 0
+	}
+	n := int(math.Ceil(pc.AverageWindowDurationMS * float64(time.Millisecond) / float64(interval)))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// uncertainOpts returns upload options with metadata about an uncertain
+// classification, and its location if fix is not nil.
+func uncertainOpts(project edgeimpulse.Project, fix *location.Fix, label string, score float64) *ingest.UploadOpts {
+	opts := &ingest.UploadOpts{
+		Metadata: map[string]string{
+			"source":          "eimaudio",
+			"project":         project.String(),
+			"predicted_label": label,
+			"score":           fmt.Sprintf("%.4f", score),
+		},
+	}
+	if fix != nil {
+		for k, v := range fix.Metadata() {
+			opts.Metadata[k] = v
+		}
+	}
+	return opts
+}
+
+// uploadUncertain queues the audio window of ev as WAV file for uploading if its
+// top score is uncertain.
+func uploadUncertain(q *ingest.Queue, project edgeimpulse.Project, fix *location.Fix, ev audio.ClassifyEvent, frequency float64) {
+	label, top := score.Top(ev.RunnerClassifyResponse)
+	if top < uncertainMin || top > uncertainMax {
+		return
+	}
+	samples := make([]int16, len(ev.Samples))
+	for i, v := range ev.Samples {
+		samples[i] = int16(v)
+	}
+	var buf bytes.Buffer
+	if err := wav.Encode(&buf, samples, int(frequency), 1); err != nil {
+		log.Printf("encoding uncertain audio: %v", err)
+		return
+	}
+	f := ingest.QueueFile{
+		Filename: fmt.Sprintf("uncertain-%d.wav", time.Now().UnixNano()),
+		Data:     buf.Bytes(),
+		Opts:     uncertainOpts(project, fix, label, top),
+	}
+	if !q.Add(f) && verbose {
+		log.Printf("dropping uncertain audio, upload queue full")
+	}
+}
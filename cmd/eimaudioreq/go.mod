@@ -1,5 +0,0 @@
-module eimaudioreq
-
-go 1.16
-
-require github.com/youpy/go-wav v0.1.0
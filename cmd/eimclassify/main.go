@@ -5,30 +5,68 @@
 // Example:
 //
 // 	eimclassify ../../models/linux-x86/continuous-gestures.eim ../../node/examples/features.txt
+//
+// 	# Print model parameters as JSON, cached for unchanged models.
+// 	eimclassify -info ../../models/linux-x86/continuous-gestures.eim
+//
+// 	# Classify many feature files with a model process per core.
+// 	eimclassify -model-processes 0 -model-threads 1 ../../models/linux-x86/continuous-gestures.eim features/*.txt
+//
+// 	# Print latencies and throughput of 500 classifications of random features.
+// 	eimclassify -bench 500 ../../models/linux-x86/continuous-gestures.eim
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/exit"
 )
 
 var (
-	traceDir string
+	tempRoot     string
+	traceDir     string
+	modelThreads int
+	modelEnv     string
+	modelArgs    string
+	info         bool
+	noCache      bool
+	bench        int
+	modelDebug   bool
+
+	modelTimeout      time.Duration
+	modelHelloTimeout time.Duration
+	modelProcesses    int
 )
 
 func init() {
 	flag.StringVar(&traceDir, "tracedir", "", "if set, store the parsed classify data to the named directory")
+	flag.IntVar(&modelThreads, "model-threads", 0, "if > 0, number of threads the model may use for inference, if its engine supports it; -1 for a thread per performance core, e.g. the big cores of big.LITTLE socs")
+	flag.StringVar(&modelEnv, "model-env", "", "comma-separated environment variables for the model process, e.g. USE_GPU_INFERENCE=0 to select the npu delegate on i.mx 8m plus")
+	flag.StringVar(&modelArgs, "model-args", "", "space-separated additional command-line arguments for the model process")
+	flag.DurationVar(&modelTimeout, "model-timeout", edgeimpulse.DefaultClassifyTimeout, "maximum time to wait for a classification by the model process, e.g. longer for slow models on low-power boards")
+	flag.IntVar(&modelProcesses, "model-processes", 1, "number of model processes classifying files in parallel, e.g. one per core with -model-threads 1; 0 for a process per core")
+	flag.DurationVar(&modelHelloTimeout, "model-hello-timeout", edgeimpulse.DefaultHelloTimeout, "maximum time to wait for the model process to start")
+	flag.StringVar(&exit.Format, "error-format", "text", "format of fatal errors written to stderr: text or json")
+	flag.BoolVar(&info, "info", false, "if set, print model parameters and project of the model as json and exit, without feature files")
+	flag.BoolVar(&noCache, "nocache", false, "with -info, start the model instead of using cached model parameters")
+	flag.BoolVar(&modelDebug, "model-debug", false, "if set, request debug output from the model, e.g. features after signal processing, and print it, if the model supports it")
+	flag.IntVar(&bench, "bench", 0, "if > 0, classify the first feature file this many times, or random features without feature files, and print latencies and throughput")
+	flag.StringVar(&tempRoot, "tempdir", "", "if set, directory for temporary files of the model process, instead of /dev/shm or the os default")
 }
 
 func usage() {
 	log.Println("usage: eimclassify model featurefile ...")
+	log.Println("       eimclassify -info model")
+	log.Println("       eimclassify -bench n model [featurefile]")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -37,25 +75,82 @@ func main() {
 	log.SetFlags(0)
 	flag.Usage = usage
 	flag.Parse()
+	edgeimpulse.SetTempRoot(tempRoot)
 	args := flag.Args()
-	if len(args) < 2 {
+	if info {
+		if len(args) != 1 {
+			usage()
+		}
+		mi, err := edgeimpulse.ReadModelInfo(args[0], &edgeimpulse.ModelInfoOpts{Refresh: noCache})
+		if err != nil {
+			exit.Fatalf(exit.Model, "reading model info: %v", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(mi); err != nil {
+			exit.Fatalf(exit.Runtime, "writing model info: %v", err)
+		}
+		return
+	}
+	if len(args) < 2 && !(bench > 0 && len(args) == 1) {
 		usage()
 	}
 
 	ropts := &edgeimpulse.RunnerOpts{
-		TraceDir: traceDir,
+		TraceDir:        traceDir,
+		Threads:         modelThreads,
+		ClassifyTimeout: modelTimeout,
+		HelloTimeout:    modelHelloTimeout,
+		Debug:           modelDebug,
+	}
+	if modelEnv != "" {
+		ropts.Env = strings.Split(modelEnv, ",")
+	}
+	ropts.Args = strings.Fields(modelArgs)
+	var runner edgeimpulse.Runner
+	var err error
+	if modelProcesses != 1 {
+		var pool *edgeimpulse.RunnerPool
+		pool, err = edgeimpulse.NewRunnerPool(args[0], modelProcesses, ropts)
+		if err == nil {
+			runner = pool
+			modelProcesses = pool.Size()
+		}
+	} else {
+		runner, err = edgeimpulse.NewRunnerProcess(args[0], ropts)
 	}
-	runner, err := edgeimpulse.NewRunnerProcess(args[0], ropts)
 	if err != nil {
-		log.Fatalf("new runner: %v", err)
+		exit.Fatalf(exit.Model, "new runner: %v", err)
 	}
 
 	log.Printf("project %s\nmodel %s", runner.Project(), runner.ModelParameters())
+	if t, ok := runner.(interface{ Threads() int }); ok && t.Threads() > 0 {
+		log.Printf("model threads %d", t.Threads())
+	} else if modelProcesses > 1 {
+		log.Printf("model processes %d", modelProcesses)
+	}
 
-	fatalf := func(format string, args ...interface{}) {
-		log.Printf(format, args...)
+	fatalf := func(code int, format string, args ...interface{}) {
+		exit.Errorf(code, format, args...)
 		runner.Close()
-		os.Exit(1)
+		os.Exit(code)
+	}
+
+	if bench > 0 {
+		var features []float64
+		if len(args) > 1 {
+			features, err = readFile(args[1])
+			if err != nil {
+				fatalf(exit.Config, "reading file: %v", err)
+			}
+		}
+		res, err := edgeimpulse.Benchmark(runner, edgeimpulse.BenchmarkOpts{N: bench, Warmup: 1, Features: features, Concurrency: modelProcesses})
+		if err != nil {
+			fatalf(exit.Runtime, "benchmark: %v", err)
+		}
+		fmt.Println(res)
+		runner.Close()
+		return
 	}
 
 	files := args[1:]
@@ -64,24 +159,44 @@ func main() {
 		var err error
 		datas[i], err = readFile(f)
 		if err != nil {
-			fatalf("reading file: %v", err)
+			fatalf(exit.Config, "reading file: %v", err)
 		}
 	}
 
-	for _, data := range datas {
-		data := data
-		resp, err := runner.Classify(data)
-		if err != nil {
-			log.Printf("classify: %v", err)
+	// Classify in parallel with multiple model processes, printing results
+	// in order of the files.
+	resps := make([]edgeimpulse.RunnerClassifyResponse, len(datas))
+	errs := make([]error, len(datas))
+	sem := make(chan struct{}, modelProcesses)
+	var wg sync.WaitGroup
+	for i, data := range datas {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, data []float64) {
+			defer wg.Done()
+			resps[i], errs[i] = runner.Classify(data)
+			<-sem
+		}(i, data)
+	}
+	wg.Wait()
+
+	code := exit.OK
+	for i, resp := range resps {
+		if errs[i] != nil {
+			code = exit.Errorf(exit.Runtime, "classify: %v", errs[i])
 		} else {
 			fmt.Printf("%s\n", resp)
+			if resp.Debug != nil {
+				fmt.Printf("debug %s\n", resp.Debug)
+			}
 		}
 	}
 	runner.Close()
+	os.Exit(code)
 }
 
 func readFile(path string) ([]float64, error) {
-	buf, err := ioutil.ReadFile(path)
+	buf, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
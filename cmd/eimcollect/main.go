@@ -8,17 +8,54 @@
 //	# Upload to explicit URL, with label eimcollect, as testing data
 //	eimcollect -baseurl https://ingestion.edgeimpulse.com -label eimcollect -category testing your_api_key your_hmac_key payload.json
 //
+//	# Record 2 seconds of accelerometer and gyroscope data at 100Hz from a linux iio device, and upload it.
+//	eimcollect -iio mpu6050 -iio-channels accel,anglvel -frequency 100 -duration 2s -label wave your_api_key your_hmac_key
+//
+//	# Record 2 seconds of values a microcontroller prints over serial, in the data forwarder format, and upload it.
+//	eimcollect -serial /dev/ttyACM0 -serial-axes accX,accY,accZ -duration 2s -label wave your_api_key your_hmac_key
+//
+//	# Record 10 seconds of temperature, pressure and humidity at 10Hz from a BME280 on i2c bus 1, and upload it.
+//	eimcollect -i2c bme280 -i2c-bus 1 -frequency 10 -duration 10s -label indoor your_api_key your_hmac_key
+//
+//	# Record 2 seconds of 8x8 distance frames at 15Hz that a vendor tool for a VL53L5CX prints as text, and upload it.
+//	eimcollect -frames-cmd "vl53l5cx_stream --rate 15" -frame-shape 8x8 -frequency 15 -duration 2s -label hand your_api_key your_hmac_key
+//
+//	# Connect to studio as a device, and record samples from an iio device when requested in the data acquisition view.
+//	eimcollect -remote -iio mpu6050 -frequency 100 -remote-sensor Accelerometer your_api_key
+//
+//	# Record from an iio device, and tag the sample with the position from gpsd.
+//	eimcollect -iio mpu6050 -location gpsd -duration 2s -label pothole your_api_key your_hmac_key
+//
+//	# Upload a dataset of images or audio in label folders, e.g. dataset/cat/1.jpg
+//	# labeled cat. Run again to resume an interrupted upload, skipping files
+//	# already uploaded.
+//	eimcollect -dir dataset your_api_key
+//
 // Payload.json must be in the format specified in package ingest.
 package main
 
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"math"
+	"net"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/edgeimpulse/linux-sdk-go/ingest"
+	"github.com/edgeimpulse/linux-sdk-go/v2/frame"
+	"github.com/edgeimpulse/linux-sdk-go/v2/ingest"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/exit"
+	"github.com/edgeimpulse/linux-sdk-go/v2/location"
+	"github.com/edgeimpulse/linux-sdk-go/v2/remote"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sensor/i2c"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sensor/iio"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sensor/serial"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
 )
 
 var (
@@ -26,10 +63,38 @@ var (
 	disallowDuplicates = flag.Bool("disallow-duplicates", false, "disallow duplicates")
 	label              = flag.String("label", "", "label for data")
 	category           = flag.String("category", "training", "type of data: split, training or testing")
+	iioDevice          = flag.String("iio", "", "if set, record from this linux iio device (e.g. iio:device0 or mpu6050) instead of uploading example data")
+	iioChannels        = flag.String("iio-channels", "accel", "comma-separated iio channel types to record: accel, anglvel, magn")
+	serialDevice       = flag.String("serial", "", "if set, record values in the data forwarder format from this serial port (e.g. /dev/ttyACM0) instead of uploading example data")
+	serialBaud         = flag.Int("baud", 115200, "baud rate of serial port")
+	serialAxes         = flag.String("serial-axes", "", "comma-separated names of the values on each line from the serial port; if empty, the number of values is detected")
+	i2cSensor          = flag.String("i2c", "", "if set, record from this i2c sensor instead of uploading example data: adxl345, mpu6050, bme280, bme680")
+	i2cBus             = flag.Int("i2c-bus", 1, "i2c bus of sensor, e.g. 1 for /dev/i2c-1")
+	i2cAddr            = flag.Uint("i2c-addr", 0, "i2c address of sensor; if 0, the default address of the sensor is used")
+	framesPath         = flag.String("frames", "", "if set, record frames from a frame-based sensor, like a radar or time-of-flight array, from this file or named pipe instead of uploading example data")
+	framesCmd          = flag.String("frames-cmd", "", "if set, record frames from the output of this command, with space-separated arguments, instead of uploading example data")
+	frameShape         = flag.String("frame-shape", "", "dimensions of frames, e.g. 8x8")
+	frameEncoding      = flag.String("frame-encoding", "text", "encoding of frames: text for a line of values per frame, or little endian int16, uint16 or float32")
+	frequency          = flag.Float64("frequency", 100, "frequency in Hz to record iio values at; for serial, if not set, the frequency is detected")
+	duration           = flag.Duration("duration", 2*time.Second, "how long to record values")
+	remoteMode         = flag.Bool("remote", false, "if set, connect to edge impulse studio like the data forwarder, and record samples from the sensor when requested in the data acquisition view; only the api key argument is required")
+	remoteURL          = flag.String("remote-url", "", "url of the remote management service; by default wss://remote-mgmt.edgeimpulse.com")
+	remoteSensor       = flag.String("remote-sensor", "Sensor", "name of the sensor shown in studio")
+	deviceID           = flag.String("device-id", "", "globally unique id of the device in studio; by default the hardware address of the first network interface")
+	uploadDir          = flag.String("dir", "", "if set, upload the files in this directory, labeled by the name of the folder they are in, e.g. dir/cat/1.jpg as cat; files directly in the directory get -label; uploaded files are recorded in a state file, and skipped when run again; only the api key argument is required")
+	dirState           = flag.String("dir-state", "", "state file for -dir; by default "+ingest.DefaultDirState+" in the directory")
+	tags               = flag.String("tags", "", "comma-separated tags stored as metadata with the uploaded samples, e.g. to filter a collection campaign in studio; uploads are also stamped with a session id")
+	datasetVersion     = flag.String("dataset-version", "", "if set, dataset version stored as metadata with the uploaded samples")
+	locationSpec       = flag.String("location", "", "if set, add the position from a gnss receiver as metadata to the sample: gpsd, gpsd:host:port, nmea:/dev/ttyUSB0 or nmea:/dev/ttyUSB0:baud")
 )
 
+func init() {
+	flag.StringVar(&exit.Format, "error-format", "text", "format of fatal errors written to stderr: text or json")
+}
+
 func usage() {
-	log.Println("usage: eimcollect [-baseurl https://...] [-label label] [-allow-duplicates] [-category split|training|testing] apikey hmackey payload.json")
+	log.Println("usage: eimcollect [-baseurl https://...] [-label label] [-allow-duplicates] [-category split|training|testing] [-iio device | -serial device | -i2c sensor | -frames path | -frames-cmd command] [-remote] apikey hmackey")
+	log.Println("       eimcollect -dir directory [-dir-state path] apikey")
 	flag.PrintDefaults()
 	os.Exit(2)
 }
@@ -39,24 +104,336 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 	args := flag.Args()
-	if len(args) != 2 {
+	if len(args) != 2 && !((*remoteMode || *uploadDir != "") && len(args) == 1) {
 		usage()
 	}
 
 	apiKey := args[0]
-	hmacKey := args[1]
+	hmacKey := ""
+	if len(args) == 2 {
+		hmacKey = args[1]
+	}
 	opts := ingest.UploadOpts{
 		Label:              *label,
 		DisallowDuplicates: *disallowDuplicates,
 	}
 	c, err := ingest.NewCollector(apiKey, hmacKey)
 	if err != nil {
-		log.Fatalf("new collector: %v", err)
+		exit.Fatalf(exit.Config, "new collector: %v", err)
 	}
 	if *baseURL != "" {
 		c.IngestionBaseURL = *baseURL
 	}
+	c.Session, err = ingest.NewSession(ingest.ParseTags(*tags), *datasetVersion)
+	if err != nil {
+		exit.Fatalf(exit.Runtime, "new upload session: %v", err)
+	}
+
+	var loc location.Provider
+	if *locationSpec != "" {
+		loc, err = location.Open(context.Background(), *locationSpec)
+		if err != nil {
+			exit.Fatalf(exit.Device, "opening location provider: %v", err)
+		}
+		defer loc.Close()
+	}
+
+	if *remoteMode {
+		runRemote(apiKey, loc)
+		return
+	}
+	if *uploadDir != "" {
+		runDir(c)
+		return
+	}
+
+	var payload ingest.CollectPayload
+	if sourceSet() {
+		if *frequency <= 0 {
+			exit.Fatalf(exit.Config, "frequency must be > 0")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *duration+5*time.Second)
+		defer cancel()
+
+		recorder, err := openRecorder(ctx, *frequency)
+		if err != nil {
+			exit.Fatalf(exit.Device, "%v", err)
+		}
+		axes := recorder.Axes()
+		freq := recorder.Frequency()
+		log.Printf("recording %d axes at %vHz for %v...", len(axes), freq, *duration)
+		values, err := record(ctx, recorder, int(duration.Seconds()*freq))
+		recorder.Close()
+		if err != nil {
+			exit.Fatalf(exit.Device, "recording: %v", err)
+		}
+		payload = ingest.CollectPayload{
+			DeviceName: "00:00:00:00:00:00", // set this to a **globally unique** identifier
+			DeviceType: deviceType(),
+			IntervalMS: int64(1000 / freq),
+			Values:     values,
+		}
+		for _, a := range axes {
+			payload.Sensors = append(payload.Sensors, ingest.Sensor{Name: a.Name, Units: a.Units})
+		}
+	} else {
+		payload = examplePayload()
+	}
+
+	if loc != nil {
+		if fix := location.Current(loc); fix != nil {
+			opts.Metadata = fix.Metadata()
+		} else {
+			log.Printf("no location fix, uploading without location")
+		}
+	}
+
+	sampleName, err := c.Upload(context.Background(), "linux01", *category, payload, &opts)
+	if err != nil {
+		exit.Fatalf(exit.Runtime, "upload: %v", err)
+	}
+	log.Printf("uploaded: sample name: %s", sampleName)
+}
+
+// runDir uploads the files of -dir, printing the status of each file.
+func runDir(c *ingest.Collector) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	counts := map[string]int{}
+	opts := &ingest.DirOpts{
+		StatePath:          *dirState,
+		Label:              *label,
+		DisallowDuplicates: *disallowDuplicates,
+		Status: func(s ingest.FileStatus) {
+			counts[s.Status]++
+			if s.Err != nil {
+				fmt.Printf("%s %s: %v\n", s.Status, s.Path, s.Err)
+			} else {
+				fmt.Printf("%s %s, label %q\n", s.Status, s.Path, s.Label)
+			}
+		},
+	}
+	err := c.UploadDir(ctx, *uploadDir, *category, opts)
+	log.Printf("%d uploaded, %d skipped, %d failed", counts[ingest.FileUploaded], counts[ingest.FileSkipped], counts[ingest.FileFailed])
+	if err != nil {
+		exit.Fatalf(exit.Runtime, "uploading directory: %v", err)
+	}
+}
+
+// sourceSet returns whether a sensor to record from is set with the flags.
+func sourceSet() bool {
+	return *iioDevice != "" || *serialDevice != "" || *i2cSensor != "" || *framesPath != "" || *framesCmd != ""
+}
+
+// deviceType returns the device type for uploads of the sensor set with the
+// flags.
+func deviceType() string {
+	switch {
+	case *iioDevice != "":
+		return "LINUX_GO_IIO"
+	case *i2cSensor != "":
+		return "LINUX_GO_I2C"
+	case *framesPath != "" || *framesCmd != "":
+		return "LINUX_GO_FRAMES"
+	}
+	return "LINUX_GO_SERIAL"
+}
+
+// openRecorder starts recording at freq from the sensor set with the flags.
+func openRecorder(ctx context.Context, freq float64) (timeseries.Recorder, error) {
+	switch {
+	case *iioDevice != "":
+		r, err := iio.NewRecorder(ctx, iio.WithDevice(*iioDevice), iio.WithChannels(strings.Split(*iioChannels, ",")...), iio.WithFrequency(freq))
+		if err != nil {
+			return nil, fmt.Errorf("opening iio device: %w", err)
+		}
+		return r, nil
+	case *i2cSensor != "":
+		r, err := openI2C(ctx, freq)
+		if err != nil {
+			return nil, fmt.Errorf("opening i2c sensor: %w", err)
+		}
+		return r, nil
+	case *framesPath != "" || *framesCmd != "":
+		r, err := openFrames(ctx, freq)
+		if err != nil {
+			return nil, fmt.Errorf("opening frame source: %w", err)
+		}
+		return r, nil
+	}
+	opts := []serial.Option{serial.WithDevice(*serialDevice), serial.WithBaud(*serialBaud)}
+	if *serialAxes != "" {
+		opts = append(opts, serial.WithAxes(strings.Split(*serialAxes, ",")...))
+	}
+	// Without an explicit frequency, it is detected from the data.
+	if flagSet("frequency") || *remoteMode {
+		opts = append(opts, serial.WithFrequency(freq))
+	}
+	r, err := serial.NewRecorder(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("opening serial port: %w", err)
+	}
+	return r, nil
+}
+
+// runRemote connects to Studio, recording from the sensor set with the flags
+// when samples are requested, until interrupted.
+func runRemote(apiKey string, loc location.Provider) {
+	if !sourceSet() {
+		exit.Fatalf(exit.Usage, "-remote requires a sensor: -iio, -serial, -i2c, -frames or -frames-cmd")
+	}
+	id := *deviceID
+	if id == "" {
+		id = defaultDeviceID()
+	}
+	sensor := remote.Sensor{
+		Name:        *remoteSensor,
+		Frequencies: []float64{*frequency},
+		Open:        openRecorder,
+	}
+	opts := []remote.Option{
+		remote.WithAPIKey(apiKey),
+		remote.WithDevice(id, deviceType()),
+		remote.WithSensors(sensor),
+		remote.WithIngestionURL(*baseURL),
+	}
+	if *remoteURL != "" {
+		opts = append(opts, remote.WithURL(*remoteURL))
+	}
+	if loc != nil {
+		opts = append(opts, remote.WithMetadata(func() map[string]string {
+			if fix := location.Current(loc); fix != nil {
+				return fix.Metadata()
+			}
+			return nil
+		}))
+	}
+	client, err := remote.NewClient(opts...)
+	if err != nil {
+		exit.Fatalf(exit.Config, "new remote management client: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	log.Printf("connecting to studio as device %s, press ctrl-c to stop", id)
+	if err := client.Run(ctx); err != nil && ctx.Err() == nil {
+		exit.Fatalf(exit.Config, "remote management: %v", err)
+	}
+}
 
+// defaultDeviceID returns the hardware address of the first network interface
+// that has one, or the hostname.
+func defaultDeviceID() string {
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 && len(iface.HardwareAddr) > 0 {
+			return iface.HardwareAddr.String()
+		}
+	}
+	name, _ := os.Hostname()
+	return name
+}
+
+// openI2C opens the i2c sensor from the flags, and starts recording.
+func openI2C(ctx context.Context, freq float64) (timeseries.Recorder, error) {
+	type driver struct {
+		addr uint16
+		open func(conn i2c.Conn) (i2c.Sensor, error)
+	}
+	drivers := map[string]driver{
+		"adxl345": {i2c.ADXL345Addr, func(c i2c.Conn) (i2c.Sensor, error) { return i2c.NewADXL345(c) }},
+		"mpu6050": {i2c.MPU6050Addr, func(c i2c.Conn) (i2c.Sensor, error) { return i2c.NewMPU6050(c) }},
+		"bme280":  {i2c.BMEAddr, func(c i2c.Conn) (i2c.Sensor, error) { return i2c.NewBME280(c) }},
+		"bme680":  {i2c.BMEAddr, func(c i2c.Conn) (i2c.Sensor, error) { return i2c.NewBME680(c) }},
+	}
+	d, ok := drivers[*i2cSensor]
+	if !ok {
+		return nil, fmt.Errorf("unknown sensor %q", *i2cSensor)
+	}
+	addr := d.addr
+	if *i2cAddr != 0 {
+		addr = uint16(*i2cAddr)
+	}
+	dev, err := i2c.Open(*i2cBus, addr)
+	if err != nil {
+		return nil, err
+	}
+	sensor, err := d.open(dev)
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	r, err := i2c.NewRecorder(ctx, sensor, i2c.WithFrequency(freq))
+	if err != nil {
+		dev.Close()
+		return nil, err
+	}
+	return &i2cRecorder{r, dev}, nil
+}
+
+// openFrames starts reading frames from the file or command from the flags.
+func openFrames(ctx context.Context, freq float64) (timeseries.Recorder, error) {
+	shape, err := frame.ParseShape(*frameShape)
+	if err != nil {
+		return nil, err
+	}
+	opts := []frame.Option{
+		frame.WithShape(shape...),
+		frame.WithEncoding(frame.Encoding(*frameEncoding)),
+		frame.WithFrequency(freq),
+		frame.WithPath(*framesPath),
+	}
+	if *framesCmd != "" {
+		opts = append(opts, frame.WithCommand(strings.Fields(*framesCmd)...))
+	}
+	src, err := frame.NewStream(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return frame.Recorder(ctx, src), nil
+}
+
+// i2cRecorder also closes the device on Close.
+type i2cRecorder struct {
+	*i2c.Recorder
+	dev *i2c.Device
+}
+
+func (r *i2cRecorder) Close() error {
+	r.Recorder.Close()
+	return r.dev.Close()
+}
+
+// flagSet returns whether flag name was set on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// record returns the values of n samples from recorder.
+func record(ctx context.Context, recorder timeseries.Recorder, n int) ([][]float64, error) {
+	values := make([][]float64, 0, n)
+	for len(values) < n {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case ev := <-recorder.Events():
+			if ev.Err != nil {
+				return nil, ev.Err
+			}
+			values = append(values, ev.Sample.Values)
+		}
+	}
+	return values, nil
+}
+
+// examplePayload returns a payload with generated accelerometer-like data.
+func examplePayload() ingest.CollectPayload {
 	var values [][]float64
 	for i := 0; i <= 200; i++ {
 		ix := float64(i)
@@ -69,7 +446,7 @@ func main() {
 		values = append(values, frame)
 	}
 
-	payload := ingest.CollectPayload{
+	return ingest.CollectPayload{
 		DeviceName: "00:00:00:00:00:00", // set this to a **globally unique** identifier
 		DeviceType: "LINUX_GO_EXAMPLE",
 		IntervalMS: 10,
@@ -80,10 +457,4 @@ func main() {
 		},
 		Values: values,
 	}
-
-	sampleName, err := c.Upload(context.Background(), "linux01", *category, payload, &opts)
-	if err != nil {
-		log.Fatalf("upload: %v", err)
-	}
-	log.Printf("uploaded: sample name: %s", sampleName)
 }
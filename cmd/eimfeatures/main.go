@@ -0,0 +1,251 @@
+// Command eimfeatures converts input files into the features a model expects,
+// in the comma-separated format read by eimclassify, e.g. for reproducible test
+// inputs. JPEG and PNG images are resized and scaled for camera models, the
+// same way eimimage does. WAV files for microphone models, and CSV files for
+// other models, are cut into windows of the model's input length.
+//
+// CSV files have a column per axis of the model, and a row per sample at the
+// frequency of the model. A header line and a leading timestamp column, as in
+// exports of EdgeImpulse studio, are skipped. WAV files must have a single
+// channel at the sample rate of the model.
+//
+// The model parameters are read from the model, and cached, see eimclassify
+// -info.
+//
+// Examples:
+//
+//	# Print the features of an image, a line per feature file.
+//	eimfeatures ../../models/linux-x86/car-detection.eim car.jpg
+//
+//	# Write a feature file per window of audio, starting every 500ms,
+//	# then classify them.
+//	eimfeatures -out /tmp/features -stride 500ms ../../custom-keywords.eim recording.wav
+//	eimclassify ../../custom-keywords.eim /tmp/features/recording-*.txt
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	goimage "image"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio/wav"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/exit"
+)
+
+var (
+	outDir       string
+	stride       time.Duration
+	imageScaling string
+	noCache      bool
+	tempRoot     string
+)
+
+func init() {
+	flag.StringVar(&outDir, "out", "", "if set, directory to write a feature file per window of each input to, named after the input, with a window number for inputs with multiple windows; by default features are printed, a line per window")
+	flag.DurationVar(&stride, "stride", 0, "time between the start of consecutive windows of wav and csv inputs, by default the window length of the model")
+	flag.StringVar(&imageScaling, "image-scaling", "", "scaling of image features: packed, unit, imagenet, -1..1, or normalize:mean:std; by default as the model reports")
+	flag.BoolVar(&noCache, "nocache", false, "start the model instead of using cached model parameters")
+	flag.StringVar(&exit.Format, "error-format", "text", "format of fatal errors written to stderr: text or json")
+	flag.StringVar(&tempRoot, "tempdir", "", "if set, directory for temporary files of the model process, instead of /dev/shm or the os default")
+}
+
+func usage() {
+	log.Println("usage: eimfeatures [flags] model file.{jpg,png,wav,csv} ...")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = usage
+	flag.Parse()
+	edgeimpulse.SetTempRoot(tempRoot)
+	args := flag.Args()
+	if len(args) < 2 {
+		usage()
+	}
+
+	scaling, err := image.ParseScaling(imageScaling)
+	if err != nil {
+		exit.Fatalf(exit.Config, "-image-scaling: %v", err)
+	}
+	mi, err := edgeimpulse.ReadModelInfo(args[0], &edgeimpulse.ModelInfoOpts{Refresh: noCache})
+	if err != nil {
+		exit.Fatalf(exit.Model, "reading model info: %v", err)
+	}
+	mp := mi.ModelParameters
+
+	for _, path := range args[1:] {
+		windows, err := readFeatures(path, mp, scaling)
+		if err != nil {
+			exit.Fatalf(exit.Config, "%s: %v", path, err)
+		}
+		if err := writeFeatures(path, windows); err != nil {
+			exit.Fatalf(exit.Runtime, "%s: writing features: %v", path, err)
+		}
+	}
+}
+
+// readFeatures returns the feature windows of the file at path for a model
+// with mp.
+func readFeatures(path string, mp edgeimpulse.ModelParameters, scaling image.Scaling) ([][]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".jpg", ".jpeg", ".png":
+		if mp.SensorType != edgeimpulse.SensorTypeCamera {
+			return nil, fmt.Errorf("image input, but sensor for this model is %q", mp.SensorType)
+		}
+		var img goimage.Image
+		if ext == ".png" {
+			img, err = png.Decode(f)
+		} else {
+			img, err = image.DecodeJPEG(f)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding image: %w", err)
+		}
+		data, _ := image.Features(img, mp, scaling)
+		return [][]float64{data}, nil
+
+	case ".wav":
+		if mp.SensorType != edgeimpulse.SensorTypeMicrophone {
+			return nil, fmt.Errorf("audio input, but sensor for this model is %q", mp.SensorType)
+		}
+		samples, rate, channels, err := wav.Decode(f)
+		if err != nil {
+			return nil, err
+		}
+		if float64(rate) != mp.Frequency {
+			return nil, fmt.Errorf("sample rate %dHz, model needs %vHz", rate, mp.Frequency)
+		}
+		if channels != 1 {
+			return nil, fmt.Errorf("%d channels, need 1", channels)
+		}
+		values := make([]float64, len(samples))
+		for i, v := range samples {
+			values[i] = float64(v)
+		}
+		return windows(values, 1, mp)
+
+	case ".csv":
+		if mp.SensorType == edgeimpulse.SensorTypeCamera || mp.SensorType == edgeimpulse.SensorTypeMicrophone {
+			return nil, fmt.Errorf("csv input, but sensor for this model is %q", mp.SensorType)
+		}
+		axes := mp.AxisCount
+		if axes <= 0 {
+			axes = 1
+		}
+		values, err := readCSV(f, axes)
+		if err != nil {
+			return nil, err
+		}
+		return windows(values, axes, mp)
+	}
+	return nil, fmt.Errorf("unknown input type %q, need jpg, png, wav or csv", ext)
+}
+
+// readCSV reads rows of axes values, skipping a header line and a leading
+// timestamp column.
+func readCSV(r io.Reader, axes int) ([]float64, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var values []float64
+	for line := 1; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) == axes+1 {
+			row = row[1:]
+		}
+		if len(row) != axes {
+			return nil, fmt.Errorf("line %d: %d columns, model has %d axes", line, len(row), axes)
+		}
+		rowValues := make([]float64, axes)
+		for i, s := range row {
+			rowValues[i], err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if line == 1 {
+				// Header.
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		values = append(values, rowValues...)
+	}
+}
+
+// windows cuts values, a row of axes values per sample, into windows of the
+// input length of the model, starting every stride.
+func windows(values []float64, axes int, mp edgeimpulse.ModelParameters) ([][]float64, error) {
+	n := mp.InputFeaturesCount
+	if n <= 0 {
+		return nil, fmt.Errorf("model has no input features count")
+	}
+	if len(values) < n {
+		return nil, fmt.Errorf("%d values, need at least a window of %d", len(values), n)
+	}
+	step := n
+	if stride > 0 {
+		step = int(math.Round(stride.Seconds()*mp.Frequency)) * axes
+		if step <= 0 {
+			return nil, fmt.Errorf("stride %v is less than a sample", stride)
+		}
+	}
+	var l [][]float64
+	for i := 0; i+n <= len(values); i += step {
+		l = append(l, values[i:i+n])
+	}
+	return l, nil
+}
+
+// writeFeatures prints windows, or writes them to files in outDir named after
+// input.
+func writeFeatures(input string, windows [][]float64) error {
+	for i, data := range windows {
+		t := make([]string, len(data))
+		for j, v := range data {
+			t[j] = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		line := strings.Join(t, ",") + "\n"
+		if outDir == "" {
+			if _, err := os.Stdout.WriteString(line); err != nil {
+				return err
+			}
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+		if len(windows) > 1 {
+			name += fmt.Sprintf("-%d", i)
+		}
+		if err := os.WriteFile(filepath.Join(outDir, name+".txt"), []byte(line), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
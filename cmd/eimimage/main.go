@@ -10,38 +10,192 @@
 //	# Record using default settings.
 //	eimimage ../../models/linux-x86/jan-vs-niet-jan.eim
 //
+//	# List devices of all recorders, and record from the first one found.
+//	eimimage -recorder auto -listdevices
+//	eimimage -recorder auto ../../models/linux-x86/jan-vs-niet-jan.eim
+//
 //	# Record using ffmpeg as recorder, with explicit device, every 250ms.
 //	eimimage -recorder ffmpeg -device /dev/video0 -verbose -interval 250ms ../../models/linux-x86/jan-vs-niet-jan.eim
 //
 //	# Record using imagesnap. NOTE: on macOS, imagesnap is the default recorder.
 //	eimimage -recorder imagesnap -device 'FaceTime HD Camera (Built-in)' -verbose -interval 250ms ../../models/mac/jan-vs-niet-jan.eim
+//
+//	# Drive gpio line 17 of gpiochip0 high for a second when a person is detected.
+//	eimimage -gpio gpiochip0:17 -gpio-label person ../../models/linux-x86/person-detection.eim
+//
+//	# Upload images the model is unsure about, to build a dataset of hard examples.
+//	eimimage -upload-apikey ei_... -uncertain-min 0.4 -uncertain-max 0.7 ../../models/linux-x86/jan-vs-niet-jan.eim
+//
+//	# Upload a video clip of 5 seconds before until 5 seconds after each
+//	# detection of a person, labeled person, to build a dataset from the field.
+//	eimimage -upload-apikey ei_... -clip-labels person -clip-pre 5s -clip-post 5s ../../models/linux-x86/person-detection.eim
+//
+//	# Upload each object detected with a score of at least 0.8 as an image,
+//	# labeled with its class, to build a dataset per class.
+//	eimimage -upload-apikey ei_... -upload-crops -crop-threshold 0.8 ../../models/linux-x86/person-detection.eim
+//
+//	# Print results and also publish them as JSON to an MQTT broker.
+//	eimimage -sink text -sink mqtt://localhost:1883/eim/results ../../models/linux-x86/jan-vs-niet-jan.eim
+//
+//	# Detect objects at 30 frames per second, passing raw frames from gstreamer
+//	# through shared memory.
+//	eimimage -recorder gstreamer -gstreamer-shm -interval 33ms ../../models/linux-aarch64/cars.eim
+//
+//	# Classify images of a FLIR Lepton 3, scaling 15°C to 40°C to the iron palette.
+//	eimimage -recorder thermal -device lepton:/dev/spidev0.0 -thermal-range 15:40 -thermal-palette iron ../../models/linux-armv7/presence.eim
+//
+//	# Classify colorized depth of a RealSense camera, near 300mm in blue, far 3m in red.
+//	eimimage -recorder depth -depth-mode depth -depth-range 300:3000 ../../models/linux-x86/depth-gestures.eim
+//
+//	# Save power: classify for 5 seconds every 30 seconds, stopping the camera
+//	# and the model process in between.
+//	eimimage -schedule 5s/25s -schedule-model-idle 20s ../../models/linux-x86/jan-vs-niet-jan.eim
+//
+//	# Send per-minute counts of labels to a server every 5 minutes, instead of
+//	# every result, keeping summaries on disk while offline.
+//	eimimage -agg-url https://example.com/summaries -agg-spool /var/lib/eimimage/agg -agg-notable person ../../models/linux-x86/person-detection.eim
+//
+//	# Fix an over-exposed scene: manual exposure, then exposure time and gain.
+//	eimimage -camera-control exposure_auto=1 -camera-control exposure_absolute=150 -camera-control gain=0 ../../models/linux-x86/jan-vs-niet-jan.eim
+//
+//	# Run a TensorFlow Lite deployment in-process, with model parameters from
+//	# person-detection.json, as printed by eimclassify -info. Requires building
+//	# with -tags tflite.
+//	eimimage ../../models/tflite/person-detection.tflite
+//
+//	# Likewise for an ONNX model with ONNX Runtime, building with -tags onnx.
+//	eimimage ../../models/onnx/person-detection.onnx
+//
+//	# Run an .eim model for the i.MX 8M Plus on its NPU instead of the GPU,
+//	# with at most 2 threads.
+//	eimimage -model-env USE_GPU_INFERENCE=0 -model-threads 2 ../../models/linux-aarch64/person-detection.eim
+//
+//	# Only report boxes scoring 0.6 or higher. The model process drops lower
+//	# scoring boxes itself if it supports it.
+//	eimimage -min-score 0.6 ../../models/linux-x86/person-detection.eim
+//
+//	# Check once whether a person is in view, e.g. from cron: print the result
+//	# of one image, taken after 2s for the camera to adjust, as JSON, and exit
+//	# with status 0 if a label scores at least 0.7, or 6 if not.
+//	eimimage -once -once-warmup 2s -once-threshold 0.7 ../../models/linux-x86/person-detection.eim
+//
+//	# Use settings from a configuration file, see package config. Flags
+//	# override EI_* environment variables, which override the file, and
+//	# apply without -config too.
+//	eimimage -config camera.json
 package main
 
 import (
+	"bytes"
+	"context"
+	"expvar"
 	"flag"
 	"fmt"
+	goimage "image"
+	"image/jpeg"
 	"log"
+	"net/http"
 	"os"
-	"os/signal"
 	"runtime"
 	"strings"
 	"syscall"
 	"time"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
-	"github.com/edgeimpulse/linux-sdk-go/image"
-	"github.com/edgeimpulse/linux-sdk-go/image/ffmpeg"
-	"github.com/edgeimpulse/linux-sdk-go/image/gstreamer"
-	"github.com/edgeimpulse/linux-sdk-go/image/imagesnap"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/agg"
+	"github.com/edgeimpulse/linux-sdk-go/v2/config"
+	"github.com/edgeimpulse/linux-sdk-go/v2/gpio"
+	"github.com/edgeimpulse/linux-sdk-go/v2/health"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image/depth"
+	_ "github.com/edgeimpulse/linux-sdk-go/v2/image/ffmpeg"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image/gstreamer"
+	_ "github.com/edgeimpulse/linux-sdk-go/v2/image/imagesnap"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image/thermal"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image/v4l2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/ingest"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/exit"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/score"
+	"github.com/edgeimpulse/linux-sdk-go/v2/location"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+	"github.com/edgeimpulse/linux-sdk-go/v2/pipeline"
+	"github.com/edgeimpulse/linux-sdk-go/v2/runner/onnx"
+	"github.com/edgeimpulse/linux-sdk-go/v2/runner/tflite"
+	"github.com/edgeimpulse/linux-sdk-go/v2/schedule"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sink"
+	"github.com/edgeimpulse/linux-sdk-go/v2/status"
 )
 
 var (
+	configPath   string
+	tempRoot     string
 	listDevices  bool
 	recorderType string
 	deviceID     string
+	devicePolicy string
+	controls     v4l2.Settings
 	interval     time.Duration
+	once         bool
+	onceWarmup   time.Duration
+	onceMin      float64
 	verbose      bool
 	traceDir     string
+	modelThreads int
+	modelEnv     string
+	modelArgs    string
+	minScore     float64
+	imageScaling string
+
+	modelRestartAfter time.Duration
+	modelRestartCount int64
+	modelTimeout      time.Duration
+	modelHelloTimeout time.Duration
+	modelRespawn      bool
+
+	gpioLine      string
+	gpioLabel     string
+	gpioThreshold float64
+	gpioDuration  time.Duration
+
+	healthAddr      string
+	healthIntervals int
+
+	uploadAPIKey   string
+	uploadCategory string
+	uploadTags     string
+	uploadVersion  string
+	uncertainMin   float64
+	uncertainMax   float64
+	uploadCrops    bool
+	cropThreshold  float64
+	cropPadding    float64
+	clipLabels     string
+	clipThreshold  float64
+	clipPre        time.Duration
+	clipPost       time.Duration
+
+	sinks        sink.Specs
+	filters      string
+	locationSpec string
+
+	aggURL     string
+	aggSpool   string
+	aggNotable string
+	aggBucket  time.Duration
+	aggSync    time.Duration
+
+	gstreamerShm bool
+
+	thermalRange   string
+	thermalPalette string
+
+	depthMode  string
+	depthRange string
+	depthSize  string
+	colorSize  string
+
+	scheduleSpec      string
+	scheduleModelIdle time.Duration
 )
 
 func init() {
@@ -52,11 +206,65 @@ func init() {
 	}
 
 	flag.BoolVar(&listDevices, "listdevices", false, "if set, lists devices and exits")
-	flag.StringVar(&recorderType, "recorder", recorderType, "type of recorder to use, imagesnap on macOS; gstreamer or ffmpeg on linux")
-	flag.StringVar(&deviceID, "device", "", "device ID to use, by default, the first device returned when listing devices")
+	flag.StringVar(&recorderType, "recorder", recorderType, "type of recorder to use, imagesnap on macOS; gstreamer or ffmpeg on linux; thermal for lepton:/dev/spidevX.Y, lepton2:... or mlx90640:command devices; depth for depth cameras like realsense; auto to use any recorder that has the device")
+	flag.StringVar(&deviceID, "device", "", "device ID to use, by default the device selected by -device-policy")
+	flag.StringVar(&devicePolicy, "device-policy", "", "if no -device is set, comma-separated device preferences: usb or csi for the bus, name:regexp for the name, and device IDs to use if present, e.g. usb,name:Logitech,/dev/video2; primary capture nodes are preferred over e.g. metadata nodes")
+	flag.Var(&controls, "camera-control", "v4l2 camera control to set at startup, as name=value, e.g. exposure_auto=1, gain=10 or focus_absolute=0; repeatable, applied in order")
 	flag.DurationVar(&interval, "interval", 250*time.Millisecond, "how often to take an image and classify it")
+	flag.BoolVar(&once, "once", false, "if set, classify a single image after -once-warmup, print the result as json by default, and exit with status 0 if a label scores at least -once-threshold, and 6 if not")
+	flag.DurationVar(&onceWarmup, "once-warmup", time.Second, "with -once, duration of images skipped while the camera adjusts exposure and focus")
+	flag.Float64Var(&onceMin, "once-threshold", 0.5, "with -once, minimum score of a label for exit status 0")
 	flag.BoolVar(&verbose, "verbose", false, "print verbose output")
 	flag.StringVar(&traceDir, "tracedir", "", "if set, store the images and parsed classify data to the named directory")
+	flag.IntVar(&modelThreads, "model-threads", 0, "if > 0, number of threads the model may use for inference, if its engine supports it; -1 for a thread per performance core, e.g. the big cores of big.LITTLE socs")
+	flag.DurationVar(&modelRestartAfter, "model-restart-after", 0, "if > 0, restart the model process after running this long, e.g. 168h, to mitigate slow memory growth; the new process is started before the old one is stopped")
+	flag.Int64Var(&modelRestartCount, "model-restart-classifications", 0, "if > 0, restart the model process after this many classifications")
+	flag.DurationVar(&modelTimeout, "model-timeout", edgeimpulse.DefaultClassifyTimeout, "maximum time to wait for a classification by the model process, e.g. longer for slow models on low-power boards")
+	flag.DurationVar(&modelHelloTimeout, "model-hello-timeout", edgeimpulse.DefaultHelloTimeout, "maximum time to wait for the model process to start")
+	flag.BoolVar(&modelRespawn, "model-respawn", false, "if set, restart the model process when it crashes, failing only the classification in progress")
+	flag.StringVar(&modelEnv, "model-env", "", "comma-separated environment variables for the model process, e.g. USE_GPU_INFERENCE=0 to select the npu delegate on i.mx 8m plus")
+	flag.StringVar(&modelArgs, "model-args", "", "space-separated additional command-line arguments for the model process")
+	flag.StringVar(&imageScaling, "image-scaling", "", "scaling of image features sent to the model: packed for packed rgb pixels as model processes take, unit for 0 to 1 per channel, imagenet, -1..1, or normalize:mean:std; by default as the model reports")
+	flag.Float64Var(&minScore, "min-score", 0, "if > 0, minimum score of bounding boxes for object detection models; set in the model if it supports it, otherwise boxes are filtered after classification")
+	flag.StringVar(&configPath, "config", "", "if set, json configuration file with defaults for flags and the model, see package config; EI_* environment variables apply regardless")
+	flag.StringVar(&exit.Format, "error-format", "text", "format of fatal errors written to stderr: text or json")
+	flag.StringVar(&tempRoot, "tempdir", "", "if set, directory for temporary files of the model process and recorders, instead of /dev/shm or the os default")
+	flag.StringVar(&gpioLine, "gpio", "", "if set, gpio line to drive high when -gpio-label is detected, either a sysfs pin number like 17, or a gpiod chip and line like gpiochip0:17")
+	flag.StringVar(&gpioLabel, "gpio-label", "", "label that drives the gpio line high")
+	flag.Float64Var(&gpioThreshold, "gpio-threshold", 0.8, "minimum score for -gpio-label to drive the gpio line high")
+	flag.DurationVar(&gpioDuration, "gpio-duration", time.Second, "how long to keep the gpio line high after a detection")
+	flag.StringVar(&healthAddr, "health-addr", "", "if set, address to serve http health endpoints /healthz and /readyz, latency statistics on /debug/vars, prometheus metrics on /metrics and pipeline state on /debug/edgeimpulse, e.g. :8080")
+	flag.IntVar(&healthIntervals, "health-intervals", 10, "number of intervals without classification after which the health endpoints fail")
+	flag.StringVar(&uploadAPIKey, "upload-apikey", os.Getenv("EI_API_KEY"), "if set, upload images with an uncertain top score to EdgeImpulse with this api key, for active learning")
+	flag.StringVar(&uploadTags, "upload-tags", "", "comma-separated tags stored as metadata with all uploaded images, e.g. to filter a collection campaign in studio; uploads are also stamped with a session id")
+	flag.StringVar(&uploadVersion, "upload-dataset-version", "", "if set, dataset version stored as metadata with all uploaded images")
+	flag.StringVar(&uploadCategory, "upload-category", "training", "category for uploaded images: split, training or testing")
+	flag.Float64Var(&uncertainMin, "uncertain-min", 0.4, "lowest top score considered uncertain")
+	flag.Float64Var(&uncertainMax, "uncertain-max", 0.7, "highest top score considered uncertain")
+	flag.BoolVar(&uploadCrops, "upload-crops", false, "if set, upload the region of each detected object as an image labeled with its class to EdgeImpulse, requires -upload-apikey")
+	flag.Float64Var(&cropThreshold, "crop-threshold", 0.7, "minimum score of objects uploaded with -upload-crops")
+	flag.Float64Var(&cropPadding, "crop-padding", 0.1, "with -upload-crops, fraction of the size of an object added on each side")
+	flag.StringVar(&clipLabels, "clip-labels", "", "comma-separated labels whose detections upload a video clip of the images around it to EdgeImpulse, requires -upload-apikey")
+	flag.Float64Var(&clipThreshold, "clip-threshold", 0.8, "minimum score for -clip-labels to start a clip")
+	flag.DurationVar(&clipPre, "clip-pre", 5*time.Second, "duration of images before a detection included in a clip")
+	flag.DurationVar(&clipPost, "clip-post", 5*time.Second, "duration of images after a detection included in a clip")
+	flag.Var(&sinks, "sink", "where to send results, repeatable: text or json for stdout, file:path for json lines with rotation, mqtt://host:port/topic, or an http(s) webhook url; default text")
+	flag.StringVar(&locationSpec, "location", "", "if set, attach the position from a gnss receiver to results and uploads: gpsd, gpsd:host:port, nmea:/dev/ttyUSB0 or nmea:/dev/ttyUSB0:baud")
+	flag.BoolVar(&gstreamerShm, "gstreamer-shm", false, "for the gstreamer recorder, receive raw frames through shared memory instead of JPEG files, for high frame rates; requires gstreamer1.0-plugins-bad")
+	flag.StringVar(&thermalRange, "thermal-range", "", "for the thermal recorder, temperatures in °C scaled to black and white as min:max, e.g. 15:40; by default each image is scaled from its coldest to its hottest pixel")
+	flag.StringVar(&thermalPalette, "thermal-palette", string(thermal.PaletteGray), "for the thermal recorder, palette of images: gray or iron")
+	flag.StringVar(&depthMode, "depth-mode", string(depth.ModeDepth), "for the depth recorder, images to classify: color, depth (colorized) or rgbd (color with aligned depth)")
+	flag.StringVar(&depthRange, "depth-range", "", "for the depth recorder, depths in mm colorized as near and far as min:max, e.g. 300:3000; by default each image is colorized from its nearest to its farthest pixel")
+	flag.StringVar(&depthSize, "depth-size", "640x480", "for the depth recorder, resolution of the depth stream")
+	flag.StringVar(&colorSize, "color-size", "640x480", "for the depth recorder, resolution of the color stream")
+	flag.StringVar(&scheduleSpec, "schedule", "", "if set, only record and classify during windows: a duty cycle like 5s/25s for 5s on and 25s off, or daily windows like 06:00-09:00,17:00-20:00")
+	flag.DurationVar(&scheduleModelIdle, "schedule-model-idle", 0, "with -schedule, stop the model process during off periods of at least this duration; 0 keeps it running")
+	flag.StringVar(&aggURL, "agg-url", "", "if set, aggregate results into summaries with counts per label and notable events, and POST them to this url periodically")
+	flag.StringVar(&aggSpool, "agg-spool", "", "if set, directory keeping summaries that are not yet synced, e.g. while offline; also enables aggregation without -agg-url")
+	flag.StringVar(&aggNotable, "agg-notable", "", "comma-separated labels whose detections are included in summaries as events, with a snapshot of the input")
+	flag.DurationVar(&aggBucket, "agg-bucket", time.Minute, "duration of a summary")
+	flag.DurationVar(&aggSync, "agg-sync", 5*time.Minute, "how often summaries are synced")
+	flag.StringVar(&filters, "filters", "", "comma-separated post-processing filters applied to results in order, e.g. ema:0.5,threshold:0.6; filters: maf:size, ema:alpha, threshold:min, nms:iou, tracker:alpha, debounce:threshold:release:activate:deactivate, vote:size, cooldown:seconds:threshold, labels:path.json")
 }
 
 func usage() {
@@ -70,28 +278,56 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 	args := flag.Args()
+	// EI_* environment variables always apply, the file only with -config.
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		exit.Fatalf(exit.Config, "%v", err)
+	}
+	if err := cfg.ApplyFlags(flag.CommandLine); err != nil {
+		exit.Fatalf(exit.Config, "%v", err)
+	}
+	if len(args) == 0 && cfg.Model != "" {
+		args = []string{cfg.Model}
+	}
+	edgeimpulse.SetTempRoot(tempRoot)
 	os.Exit(main0(args))
 }
 
 func main0(args []string) int {
-	var listFn func() ([]image.Device, error)
-	switch recorderType {
-	case "imagesnap":
-		listFn = imagesnap.ListDevices
-	case "gstreamer":
-		listFn = gstreamer.ListDevices
-	case "ffmpeg":
-		listFn = ffmpeg.ListDevices
-	default:
-		log.Fatalf("unknown recorder type %q", recorderType)
+	if err := setBackendDefaults(); err != nil {
+		exit.Fatalf(exit.Config, "%v", err)
+	}
+	var backend image.Backend
+	if recorderType != "auto" {
+		var ok bool
+		backend, ok = image.LookupBackend(recorderType)
+		if !ok {
+			exit.Fatalf(exit.Config, "unknown recorder type %q", recorderType)
+		}
 	}
 
 	if listDevices {
-		devs, err := listFn()
-		if err != nil {
-			log.Fatalf("listing devices: %v", err)
+		var devs []image.BackendDevice
+		if recorderType == "auto" {
+			var err error
+			devs, err = image.ListAllDevices()
+			if err != nil {
+				exit.Fatalf(exit.Device, "listing devices: %v", err)
+			}
+		} else {
+			l, err := backend.ListDevices()
+			if err != nil {
+				exit.Fatalf(exit.Device, "listing devices: %v", err)
+			}
+			for _, d := range l {
+				devs = append(devs, image.BackendDevice{Backend: backend.Name, Device: d})
+			}
 		}
 		for _, dev := range devs {
+			prefix := ""
+			if recorderType == "auto" {
+				prefix = dev.Backend + ": "
+			}
 			caps := ""
 			if len(dev.Caps) > 0 {
 				l := []string{}
@@ -100,98 +336,507 @@ func main0(args []string) int {
 				}
 				caps = fmt.Sprintf(" (caps: %s)", strings.Join(l, " "))
 			}
-			fmt.Printf("%s: %s%s\n", dev.ID, dev.Name, caps)
+			fmt.Printf("%s%s: %s%s\n", prefix, dev.ID, dev.Name, caps)
 		}
-		os.Exit(0)
+		os.Exit(exit.OK)
 	}
 
 	if len(args) != 1 {
 		usage()
 	}
 
+	var sched schedule.Schedule
+	if scheduleSpec != "" {
+		var err error
+		sched, err = schedule.Parse(scheduleSpec)
+		if err != nil {
+			return exit.Errorf(exit.Config, "parsing schedule: %v", err)
+		}
+	}
+
+	var checker *health.Checker
+	var state *status.Status
+	if healthAddr != "" {
+		checker = health.NewChecker()
+		state = status.New(nil, 20)
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/healthz", checker)
+			mux.Handle("/readyz", checker)
+			mux.Handle("/debug/vars", expvar.Handler())
+			mux.Handle("/metrics", metrics.Handler(metrics.Default))
+			mux.Handle("/debug/edgeimpulse", state)
+			if err := http.ListenAndServe(healthAddr, mux); err != nil {
+				log.Printf("serving health endpoints: %v", err)
+			}
+		}()
+	}
+
+	// Handle signals, so components are shut down in order and the runners
+	// temporary directory is cleaned up.
+	group := edgeimpulse.NewGroup(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer group.Close()
+	ctx := group.Context()
+
 	ropts := &edgeimpulse.RunnerOpts{
-		TraceDir: traceDir,
+		TraceDir:        traceDir,
+		Threads:         modelThreads,
+		ClassifyTimeout: modelTimeout,
+		HelloTimeout:    modelHelloTimeout,
+		Respawn:         modelRespawn,
+		MinScore:        minScore,
+	}
+	if modelEnv != "" {
+		ropts.Env = strings.Split(modelEnv, ",")
+	}
+	ropts.Args = strings.Fields(modelArgs)
+	newRunner := func() (edgeimpulse.Runner, error) {
+		if strings.HasSuffix(args[0], ".tflite") {
+			r, err := tflite.New(args[0], tflite.WithThreads(modelThreads), tflite.WithThreshold(minScore), tflite.WithVerbose(verbose))
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		}
+		if strings.HasSuffix(args[0], ".onnx") {
+			r, err := onnx.New(args[0], onnx.WithThreads(modelThreads), onnx.WithThreshold(minScore), onnx.WithVerbose(verbose))
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		}
+		r, err := edgeimpulse.NewRunnerProcess(args[0], ropts)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	if modelRestartAfter > 0 || modelRestartCount > 0 {
+		open := newRunner
+		newRunner = func() (edgeimpulse.Runner, error) {
+			r, err := edgeimpulse.NewRestartRunner(open, edgeimpulse.RestartRunnerOpts{MaxAge: modelRestartAfter, MaxClassifications: modelRestartCount})
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		}
+	}
+	var runner edgeimpulse.Runner
+	var model *schedule.Model
+	var err error
+	if sched != nil && scheduleModelIdle > 0 {
+		model, err = schedule.NewModel(newRunner)
+		runner = model
+	} else {
+		runner, err = newRunner()
+	}
+	if err != nil {
+		return exit.Errorf(exit.Model, "new runner: %v", err)
+	}
+	group.Add(edgeimpulse.StageModel, runner)
+
+	defaultSink := "text"
+	if once {
+		defaultSink = "json"
 	}
-	runner, err := edgeimpulse.NewRunnerProcess(args[0], ropts)
+	results, err := sinks.Open(defaultSink)
 	if err != nil {
-		log.Printf("new runner: %v", err)
-		return 1
+		return exit.Errorf(exit.Config, "opening sinks: %v", err)
+	}
+	group.Add(edgeimpulse.StageOutput, results)
+
+	var loc location.Provider
+	if locationSpec != "" {
+		loc, err = location.Open(ctx, locationSpec)
+		if err != nil {
+			return exit.Errorf(exit.Device, "opening location provider: %v", err)
+		}
+		group.Add(edgeimpulse.StageOutput, loc)
+	}
+
+	pipe, err := pipeline.Parse(filters, runner.ModelParameters().Labels)
+	if err != nil {
+		return exit.Errorf(exit.Config, "parsing filters: %v", err)
+	}
+	if mp := runner.ModelParameters(); minScore > 0 && mp.ModelType.ObjectDetection() {
+		if min, ok := mp.MinScore(); !ok || min != minScore {
+			// Model could not apply the minimum score, filter boxes instead.
+			pipe = pipeline.New(pipeline.NewThreshold(minScore), pipe)
+		}
 	}
-	defer runner.Close()
 
 	log.Printf("project %s\nmodel %s", runner.Project(), runner.ModelParameters())
+	if r, ok := runner.(interface{ Threads() int }); ok && r.Threads() > 0 {
+		log.Printf("model threads %d", r.Threads())
+	}
 
-	var recorder image.Recorder
-	switch recorderType {
-	case "gstreamer":
+	if recorderType == "auto" {
+		var dev image.Device
 		var err error
-		recorderOpts := gstreamer.RecorderOpts{
-			Verbose:  verbose,
-			Interval: interval,
-			DeviceID: deviceID,
-		}
-		recorder, err = gstreamer.NewRecorder(recorderOpts)
+		backend, dev, err = image.FindDevice(deviceID)
 		if err != nil {
-			log.Printf("new gstreamer recorder: %v", err)
-			return 1
+			return exit.Errorf(exit.Device, "finding device: %v", err)
 		}
-	case "ffmpeg":
-		var err error
-		recorderOpts := ffmpeg.RecorderOpts{
-			Verbose:  verbose,
-			Interval: interval,
-			DeviceID: deviceID,
+		deviceID = dev.ID
+		log.Printf("recording from %s device %s", backend.Name, deviceID)
+	}
+	if len(controls) > 0 {
+		if !v4l2Backends[backend.Name] {
+			return exit.Errorf(exit.Usage, "camera controls are only supported for v4l2 cameras, not with recorder %s", backend.Name)
 		}
-		recorder, err = ffmpeg.NewRecorder(recorderOpts)
-		if err != nil {
-			log.Printf("new ffmpeg recorder: %v", err)
-			return 1
+		dev := deviceID
+		if dev == "" {
+			if backend.DeviceLister == nil {
+				return exit.Errorf(exit.Device, "recorder %s cannot list devices for camera controls, specify a device", backend.Name)
+			}
+			devs, err := backend.DeviceLister.ListDevices()
+			if err != nil {
+				return exit.Errorf(exit.Device, "listing devices for camera controls: %v", err)
+			}
+			if len(devs) == 0 {
+				return exit.Errorf(exit.Device, "no camera found for camera controls")
+			}
+			dev = devs[0].ID
 		}
-	case "imagesnap":
-		var err error
-		recorderOpts := imagesnap.RecorderOpts{
+		if err := v4l2.Set(dev, controls); err != nil {
+			return exit.Errorf(exit.Device, "setting camera controls: %v", err)
+		}
+		log.Printf("set camera controls %s on %s", controls.String(), dev)
+	}
+	openRecorder := func(ctx context.Context) (image.Recorder, error) {
+		return backend.NewRecorder(ctx, image.BackendOpts{
 			Verbose:  verbose,
 			Interval: interval,
 			DeviceID: deviceID,
+		})
+	}
+	var recorder image.Recorder
+	if sched != nil {
+		sopts := []schedule.Option{
+			schedule.WithModel(model, scheduleModelIdle),
+			schedule.WithVerbose(verbose),
+		}
+		if checker != nil {
+			// No events are expected outside the windows of the schedule.
+			checker.SetPaused(true)
+			sopts = append(sopts, schedule.WithOnChange(func(active bool) { checker.SetPaused(!active) }))
 		}
-		recorder, err = imagesnap.NewRecorder(recorderOpts)
+		recorder = schedule.NewImageRecorder(ctx, sched, openRecorder, sopts...)
+	} else {
+		recorder, err = openRecorder(ctx)
 		if err != nil {
-			log.Printf("new imagesnap recorder: %v", err)
-			return 1
+			return exit.Errorf(exit.Device, "new %s recorder: %v", backend.Name, err)
 		}
-	default:
-		log.Fatalf("bad recorder type %q", recorderType)
 	}
-	defer recorder.Close()
+	group.Add(edgeimpulse.StageCapture, recorder)
 
+	scaling, err := image.ParseScaling(imageScaling)
+	if err != nil {
+		return exit.Errorf(exit.Config, "-image-scaling: %v", err)
+	}
 	opts := &image.ClassifierOpts{
 		Verbose:  verbose,
 		TraceDir: traceDir,
+		Scaling:  scaling,
 	}
-	cl, err := image.NewClassifier(runner, recorder, opts)
+	cl, err := image.NewClassifier(ctx, runner, recorder, opts)
 	if err != nil {
-		log.Printf("new image classifier: %v", err)
-		return 1
+		return exit.Errorf(exit.Model, "new image classifier: %v", err)
+	}
+	group.Add(edgeimpulse.StageClassify, cl)
+	expvar.Publish("stats", cl.Stats())
+	if state != nil {
+		state.SetRunner(runner)
+		state.AddComponent("classifier", cl.Err)
+		if r, ok := recorder.(interface{ Err() error }); ok {
+			state.AddComponent("recorder", r.Err)
+		}
+	}
+
+	var trigger *gpio.Trigger
+	if gpioLine != "" {
+		if gpioLabel == "" {
+			return exit.Errorf(exit.Config, "-gpio requires -gpio-label")
+		}
+		line, err := gpio.Open(gpioLine)
+		if err != nil {
+			return exit.Errorf(exit.Device, "opening gpio line: %v", err)
+		}
+		group.Add(edgeimpulse.StageOutput, line)
+		trigger = gpio.NewTrigger(line, gpioDuration)
+		group.Add(edgeimpulse.StageOutput, trigger)
 	}
-	defer cl.Close()
 
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	var aggregator *agg.Aggregator
+	if aggURL != "" || aggSpool != "" {
+		var notable []string
+		if aggNotable != "" {
+			notable = strings.Split(aggNotable, ",")
+		}
+		aggregator, err = agg.New(
+			agg.WithURL(aggURL),
+			agg.WithSpoolDir(aggSpool),
+			agg.WithNotable(notable...),
+			agg.WithBucket(aggBucket),
+			agg.WithSyncInterval(aggSync),
+			agg.WithVerbose(verbose),
+		)
+		if err != nil {
+			return exit.Errorf(exit.Config, "new aggregator: %v", err)
+		}
+		group.Add(edgeimpulse.StageOutput, aggregator)
+	}
+
+	var queue *ingest.Queue
+	if uploadAPIKey != "" {
+		collector, err := ingest.NewCollector(uploadAPIKey, "")
+		if err != nil {
+			return exit.Errorf(exit.Config, "new collector: %v", err)
+		}
+		collector.Session, err = ingest.NewSession(ingest.ParseTags(uploadTags), uploadVersion)
+		if err != nil {
+			return exit.Errorf(exit.Runtime, "new upload session: %v", err)
+		}
+		log.Printf("upload session %s", collector.Session.ID)
+		queue = ingest.NewQueue(collector, uploadCategory, 10, log.Printf)
+		group.Add(edgeimpulse.StageOutput, queue)
+	}
+
+	if uploadCrops && queue == nil {
+		return exit.Errorf(exit.Usage, "-upload-crops requires -upload-apikey")
+	}
+
+	var clips *ingest.ClipBuffer
+	if clipLabels != "" {
+		if queue == nil {
+			return exit.Errorf(exit.Usage, "-clip-labels requires -upload-apikey")
+		}
+		clips = ingest.NewClipBuffer(clipPre, clipPost)
+	}
+
+	if checker != nil {
+		checker.Ready(time.Duration(healthIntervals) * interval)
+	}
+
+	warm := time.Now().Add(onceWarmup)
 
 	for {
 		select {
-		case <-signals:
-			return 1
+		case <-group.Done():
+			return exit.Runtime
 		case ev, ok := <-cl.Events:
 			if !ok {
-				log.Printf("no more events")
-				return 1
+				if group.Signal() != nil {
+					return exit.Runtime
+				}
+				return exit.Errorf(exit.Runtime, "no more events")
 			}
 			if ev.Err != nil {
 				log.Printf("%s", ev.Err)
+				if state != nil {
+					state.RecordError(ev.Err)
+				}
 			} else {
-				fmt.Printf("%v\n", ev.RunnerClassifyResponse)
+				if once && time.Now().Before(warm) {
+					continue
+				}
+				ev.RunnerClassifyResponse, err = pipe.Apply(ev.RunnerClassifyResponse)
+				if err != nil {
+					log.Printf("applying filters: %v", err)
+				}
+				result := sink.Result{Time: time.Now(), Source: "eimimage", Response: ev.RunnerClassifyResponse, Location: location.Current(loc)}
+				if err := results.Send(ctx, result); err != nil {
+					log.Printf("sending result: %v", err)
+				}
+				if aggregator != nil {
+					aggregator.Add(result, func() ([]byte, string) {
+						var buf bytes.Buffer
+						if err := jpeg.Encode(&buf, ev.Image, nil); err != nil {
+							log.Printf("encoding snapshot: %v", err)
+							return nil, ""
+						}
+						return buf.Bytes(), "image/jpeg"
+					})
+				}
+				if state != nil {
+					state.Record(ev.RunnerClassifyResponse)
+				}
+				if checker != nil {
+					checker.Event()
+				}
+				if queue != nil {
+					uploadUncertain(queue, runner.Project(), location.Current(loc), ev)
+				}
+				if uploadCrops {
+					uploadCrop(queue, runner.ModelParameters(), runner.Project(), location.Current(loc), ev)
+				}
+				if clips != nil {
+					uploadClip(queue, clips, runner.Project(), location.Current(loc), result.Time, ev)
+				}
+				if trigger != nil && score.Label(ev.RunnerClassifyResponse, gpioLabel) >= gpioThreshold {
+					if err := trigger.Fire(); err != nil {
+						log.Printf("setting gpio line: %v", err)
+					}
+				}
+				if once {
+					if _, top := score.Top(ev.RunnerClassifyResponse); top >= onceMin {
+						return exit.OK
+					}
+					return exit.NotDetected
+				}
 			}
 		}
 	}
 }
+
+// sampleOpts returns upload options with metadata about the classification of
+// an uploaded sample, and its location if fix is not nil.
+func sampleOpts(project edgeimpulse.Project, fix *location.Fix, label string, score float64) *ingest.UploadOpts {
+	opts := &ingest.UploadOpts{
+		Metadata: map[string]string{
+			"source":          "eimimage",
+			"project":         project.String(),
+			"predicted_label": label,
+			"score":           fmt.Sprintf("%.4f", score),
+		},
+	}
+	if fix != nil {
+		for k, v := range fix.Metadata() {
+			opts.Metadata[k] = v
+		}
+	}
+	return opts
+}
+
+// uploadUncertain queues the image of ev as JPEG file for uploading if its top
+// score is uncertain.
+func uploadUncertain(q *ingest.Queue, project edgeimpulse.Project, fix *location.Fix, ev image.ClassifyEvent) {
+	label, top := score.Top(ev.RunnerClassifyResponse)
+	if top < uncertainMin || top > uncertainMax {
+		return
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, ev.Image, nil); err != nil {
+		log.Printf("encoding uncertain image: %v", err)
+		return
+	}
+	f := ingest.QueueFile{
+		Filename: fmt.Sprintf("uncertain-%d.jpg", time.Now().UnixNano()),
+		Data:     buf.Bytes(),
+		Opts:     sampleOpts(project, fix, label, top),
+	}
+	if !q.Add(f) && verbose {
+		log.Printf("dropping uncertain image, upload queue full")
+	}
+}
+
+// uploadCrop queues the region of each object detected in ev as JPEG file for
+// uploading, labeled with its class.
+func uploadCrop(q *ingest.Queue, mp edgeimpulse.ModelParameters, project edgeimpulse.Project, fix *location.Fix, ev image.ClassifyEvent) {
+	modelSize := goimage.Pt(mp.ImageInputWidth, mp.ImageInputHeight)
+	for _, c := range image.Crops(ev.Image, ev.RunnerClassifyResponse, modelSize, cropPadding, cropThreshold) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, c.Image, nil); err != nil {
+			log.Printf("encoding object image: %v", err)
+			return
+		}
+		opts := sampleOpts(project, fix, c.Label, c.Score)
+		opts.Label = c.Label
+		opts.Metadata["bbox"] = fmt.Sprintf("%d,%d,%d,%d", c.Rect.Min.X, c.Rect.Min.Y, c.Rect.Dx(), c.Rect.Dy())
+		f := ingest.QueueFile{
+			Filename: fmt.Sprintf("%s-%d.jpg", c.Label, time.Now().UnixNano()),
+			Data:     buf.Bytes(),
+			Opts:     opts,
+		}
+		if !q.Add(f) && verbose {
+			log.Printf("dropping object image, upload queue full")
+		}
+	}
+}
+
+// uploadClip adds the image of ev to clips, starts a clip if ev has a detection
+// of one of -clip-labels, and queues completed clips for uploading.
+func uploadClip(q *ingest.Queue, clips *ingest.ClipBuffer, project edgeimpulse.Project, fix *location.Fix, t time.Time, ev image.ClassifyEvent) {
+	clip, err := clips.Add(t, ev.Image)
+	if err != nil {
+		log.Printf("adding image to clip: %v", err)
+		return
+	}
+	for _, label := range strings.Split(clipLabels, ",") {
+		if score := score.Label(ev.RunnerClassifyResponse, label); score >= clipThreshold {
+			opts := sampleOpts(project, fix, label, score)
+			if clips.Trigger(t, label, opts.Metadata) && verbose {
+				log.Printf("recording clip for %s", label)
+			}
+			break
+		}
+	}
+	if clip == nil {
+		return
+	}
+	buf, err := clip.AVI()
+	if err != nil {
+		log.Printf("encoding clip: %v", err)
+		return
+	}
+	f := ingest.QueueFile{
+		Filename: clip.Filename(),
+		Data:     buf,
+		Opts:     clip.UploadOpts(),
+	}
+	if !q.Add(f) && verbose {
+		log.Printf("dropping clip, upload queue full")
+	}
+}
+
+// v4l2Backends are the recorder backends whose device IDs are V4L2 devices,
+// whose controls can be set with -camera-control.
+var v4l2Backends = map[string]bool{
+	"ffmpeg":    true,
+	"gstreamer": true,
+}
+
+// setBackendDefaults configures the device policy and the gstreamer, thermal
+// and depth recorder backends from the flags.
+func setBackendDefaults() error {
+	policy, err := image.ParseDevicePolicy(devicePolicy)
+	if err != nil {
+		return fmt.Errorf("-device-policy: %w", err)
+	}
+	image.SetDevicePolicy(policy)
+
+	gstreamer.SetDefaults(gstreamer.WithSharedMemory(gstreamerShm))
+
+	palette, err := thermal.ParsePalette(thermalPalette)
+	if err != nil {
+		return fmt.Errorf("-thermal-palette: %w", err)
+	}
+	var min, max float64
+	if thermalRange != "" {
+		if _, err := fmt.Sscanf(thermalRange, "%g:%g", &min, &max); err != nil || min >= max {
+			return fmt.Errorf("-thermal-range: must be min:max with min < max, got %q", thermalRange)
+		}
+	}
+	thermal.SetDefaults(thermal.WithRange(min, max), thermal.WithPalette(palette))
+
+	mode, err := depth.ParseMode(depthMode)
+	if err != nil {
+		return fmt.Errorf("-depth-mode: %w", err)
+	}
+	var near, far int
+	if depthRange != "" {
+		if _, err := fmt.Sscanf(depthRange, "%d:%d", &near, &far); err != nil || near >= far {
+			return fmt.Errorf("-depth-range: must be min:max in mm with min < max, got %q", depthRange)
+		}
+	}
+	dsize, err := depth.ParseSize(depthSize)
+	if err != nil {
+		return fmt.Errorf("-depth-size: %w", err)
+	}
+	csize, err := depth.ParseSize(colorSize)
+	if err != nil {
+		return fmt.Errorf("-color-size: %w", err)
+	}
+	depth.SetDefaults(depth.WithMode(mode), depth.WithRange(near, far), depth.WithStreams(dsize, csize, 0))
+	return nil
+}
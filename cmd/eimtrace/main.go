@@ -0,0 +1,418 @@
+// Command eimtrace converts the requests in a trace directory, as written by a
+// runner with a trace directory, into files that are easy to inspect: PNG
+// images for camera models, WAV files with spectrogram and waveform PNG images
+// for microphone models, and CSV files for other models. A summary of the
+// requests and responses is printed.
+//
+// Given a WAV file instead of a trace directory, e.g. a field recording with
+// 24 bit or float samples, its spectrogram and waveform are written, with the
+// samples converted to the 16 bit range of models.
+//
+// The model parameters, such as the image size or sample rate, are read from
+// the hello response in the trace directory. Flags can override them, e.g. for
+// traces without hello response.
+//
+// Examples:
+//
+//	# Write trace files while classifying, then convert them.
+//	eimaudio -tracedir /tmp/trace ../../custom-keywords.eim
+//	eimtrace /tmp/trace
+//
+//	# Convert a trace with 160x120 grayscale images to another directory.
+//	eimtrace -sensor camera -width 160 -height 120 -channels 1 -out /tmp/images /tmp/trace
+//
+//	# Write recording-spectrogram.png and recording-waveform.png.
+//	eimtrace recording.wav
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio/render"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio/wav"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/exit"
+)
+
+var (
+	outDir    string
+	sensor    string
+	width     int
+	height    int
+	channels  int
+	frequency float64
+	axes      int
+)
+
+func init() {
+	flag.StringVar(&outDir, "out", "", "directory to write files to, by default the trace directory")
+	flag.StringVar(&sensor, "sensor", "", "override sensor type from model: camera, microphone or other")
+	flag.IntVar(&width, "width", 0, "override image width from model")
+	flag.IntVar(&height, "height", 0, "override image height from model")
+	flag.IntVar(&channels, "channels", 0, "override image channel count from model, 1 or 3")
+	flag.Float64Var(&frequency, "frequency", 0, "override sample rate/frequency from model")
+	flag.IntVar(&axes, "axes", 0, "override number of axes (columns in csv) from model")
+	flag.StringVar(&exit.Format, "error-format", "text", "format of fatal errors written to stderr: text or json")
+}
+
+func usage() {
+	log.Println("usage: eimtrace [flags] tracedir | file.wav")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+var traceRegexp = regexp.MustCompile(`^runner-([0-9]+)-(request|response)\.json$`)
+
+// transaction is a request with its response from a trace directory.
+type transaction struct {
+	id       int64
+	request  []byte
+	response []byte
+}
+
+// traceRequest holds the fields of all request types.
+type traceRequest struct {
+	Hello    *int      `json:"hello"`
+	Classify []float64 `json:"classify"`
+}
+
+func main() {
+	log.SetFlags(0)
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 1 {
+		usage()
+	}
+	traceDir := args[0]
+	if strings.EqualFold(filepath.Ext(traceDir), ".wav") {
+		if err := renderWAV(traceDir); err != nil {
+			exit.Fatalf(exit.Config, "%v", err)
+		}
+		return
+	}
+	if outDir == "" {
+		outDir = traceDir
+	}
+
+	transactions, err := readTrace(traceDir)
+	if err != nil {
+		exit.Fatalf(exit.Config, "reading trace directory: %v", err)
+	}
+
+	// Find model parameters from the hello response, if any.
+	var mp *edgeimpulse.ModelParameters
+	for _, t := range transactions {
+		var req traceRequest
+		if err := json.Unmarshal(t.request, &req); err != nil || req.Hello == nil || t.response == nil {
+			continue
+		}
+		var resp struct {
+			ModelParameters edgeimpulse.ModelParameters `json:"model_parameters"`
+			Project         edgeimpulse.Project         `json:"project"`
+		}
+		if err := json.Unmarshal(t.response, &resp); err != nil {
+			log.Printf("parsing hello response %d: %v", t.id, err)
+			continue
+		}
+		mp = &resp.ModelParameters
+		fmt.Printf("project %s\n", resp.Project)
+	}
+	if mp == nil {
+		mp = &edgeimpulse.ModelParameters{}
+	}
+	applyOverrides(mp)
+
+	code := exit.OK
+	counts := map[string]int{}
+	for _, t := range transactions {
+		var req traceRequest
+		if err := json.Unmarshal(t.request, &req); err != nil {
+			log.Printf("parsing request %d: %v", t.id, err)
+			counts["invalid"]++
+			continue
+		}
+		if req.Hello != nil {
+			counts["hello"]++
+			fmt.Printf("%d: hello\n", t.id)
+			continue
+		}
+		if req.Classify == nil {
+			counts["unknown"]++
+			fmt.Printf("%d: unknown request type\n", t.id)
+			continue
+		}
+		counts["classify"]++
+
+		path, err := writeFeatures(mp, t.id, req.Classify)
+		if err != nil {
+			code = exit.Errorf(exit.Runtime, "writing features of request %d: %v", t.id, err)
+			continue
+		}
+		result := "(no response)"
+		if t.response != nil {
+			var resp edgeimpulse.RunnerClassifyResponse
+			if err := json.Unmarshal(t.response, &resp); err != nil {
+				result = fmt.Sprintf("(invalid response: %v)", err)
+			} else {
+				result = resp.String()
+			}
+		}
+		fmt.Printf("%d: %s, %s\n", t.id, path, result)
+	}
+
+	var kinds []string
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	fmt.Printf("%d transactions:", len(transactions))
+	for _, k := range kinds {
+		fmt.Printf(" %s %d", k, counts[k])
+	}
+	fmt.Println()
+	os.Exit(code)
+}
+
+// readTrace reads all requests and responses from dir, ordered by ID.
+func readTrace(dir string) ([]*transaction, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[int64]*transaction{}
+	for _, fi := range files {
+		m := traceRegexp.FindStringSubmatch(fi.Name())
+		if m == nil {
+			continue
+		}
+		id, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		buf, err := os.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		t := byID[id]
+		if t == nil {
+			t = &transaction{id: id}
+			byID[id] = t
+		}
+		if m[2] == "request" {
+			t.request = buf
+		} else {
+			t.response = buf
+		}
+	}
+	var r []*transaction
+	for _, t := range byID {
+		if t.request == nil {
+			continue
+		}
+		r = append(r, t)
+	}
+	sort.Slice(r, func(i, j int) bool {
+		return r[i].id < r[j].id
+	})
+	return r, nil
+}
+
+func applyOverrides(mp *edgeimpulse.ModelParameters) {
+	switch sensor {
+	case "":
+	case "camera":
+		mp.Sensor = 3
+	case "microphone":
+		mp.Sensor = 1
+	case "other":
+		mp.Sensor = 0
+	default:
+		exit.Fatalf(exit.Config, "unknown sensor %q", sensor)
+	}
+	if width > 0 {
+		mp.ImageInputWidth = width
+	}
+	if height > 0 {
+		mp.ImageInputHeight = height
+	}
+	if channels > 0 {
+		mp.ImageChannelCount = channels
+	}
+	if frequency > 0 {
+		mp.Frequency = frequency
+	}
+	if axes > 0 {
+		mp.AxisCount = axes
+	}
+}
+
+// writeFeatures writes the features of classify request id to a file in the
+// format matching the sensor of the model, returning the path.
+func writeFeatures(mp *edgeimpulse.ModelParameters, id int64, features []float64) (string, error) {
+	if mp.Sensor == 0 && mp.ImageInputWidth == 0 {
+		// Without model parameters, assume square images are images, and
+		// keep that for the following requests.
+		n := int(math.Sqrt(float64(len(features))))
+		if n > 0 && n*n == len(features) {
+			mp.Sensor = 3
+			mp.ImageInputWidth = n
+			mp.ImageInputHeight = n
+		}
+	}
+
+	switch mp.Sensor {
+	case 3:
+		path := filepath.Join(outDir, fmt.Sprintf("runner-%d.png", id))
+		return path, writePNG(path, mp, features)
+	case 1:
+		path := filepath.Join(outDir, fmt.Sprintf("runner-%d.wav", id))
+		if err := writeWAV(path, mp, features); err != nil {
+			return path, err
+		}
+		return path, writeAudioPNGs(filepath.Join(outDir, fmt.Sprintf("runner-%d", id)), mp, features)
+	default:
+		path := filepath.Join(outDir, fmt.Sprintf("runner-%d.csv", id))
+		return path, writeCSV(path, mp, features)
+	}
+}
+
+func writePNG(path string, mp *edgeimpulse.ModelParameters, features []float64) error {
+	w, h := mp.ImageInputWidth, mp.ImageInputHeight
+	if w*h != len(features) {
+		return fmt.Errorf("got %d features, expected %dx%d", len(features), w, h)
+	}
+	rect := image.Rect(0, 0, w, h)
+	var img image.Image
+	if mp.ImageChannelCount == 1 {
+		gray := image.NewGray(rect)
+		for i, v := range features {
+			gray.Pix[i] = uint8(int64(v) & 0xff)
+		}
+		img = gray
+	} else {
+		nrgba := image.NewNRGBA(rect)
+		for i, f := range features {
+			v := int64(f)
+			nrgba.Set(i%w, i/w, color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff})
+		}
+		img = nrgba
+	}
+	return writeImage(path, img)
+}
+
+func writeWAV(path string, mp *edgeimpulse.ModelParameters, features []float64) error {
+	rate := int(mp.Frequency)
+	if rate <= 0 {
+		rate = 16000
+	}
+	samples := make([]int16, len(features))
+	for i, v := range features {
+		samples[i] = int16(math.Max(math.MinInt16, math.Min(math.MaxInt16, v)))
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := wav.Encode(f, samples, rate, 1); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeAudioPNGs writes a spectrogram and waveform of features to files with
+// prefix.
+func writeAudioPNGs(prefix string, mp *edgeimpulse.ModelParameters, features []float64) error {
+	rate := mp.Frequency
+	if rate <= 0 {
+		rate = 16000
+	}
+	spectrogram, err := render.Spectrogram(features, rate, render.SpectrogramOpts{})
+	if err != nil {
+		return fmt.Errorf("spectrogram: %w", err)
+	}
+	if err := writeImage(prefix+"-spectrogram.png", spectrogram); err != nil {
+		return err
+	}
+	return writeImage(prefix+"-waveform.png", render.Waveform(features, spectrogram.Bounds().Dx(), 160))
+}
+
+// renderWAV writes a spectrogram and waveform of the WAV file at path, with
+// channels mixed down, to the output directory or next to the file.
+func renderWAV(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	samples, rate, n, err := wav.Decode(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+	features := make([]float64, len(samples)/n)
+	for i := range features {
+		for _, v := range samples[i*n : (i+1)*n] {
+			features[i] += float64(v) / float64(n)
+		}
+	}
+	fmt.Printf("%s: %d channels at %d Hz, %v\n", path, n, rate, time.Duration(len(features))*time.Second/time.Duration(rate))
+	dir := outDir
+	if dir == "" {
+		dir = filepath.Dir(path)
+	}
+	prefix := filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	return writeAudioPNGs(prefix, &edgeimpulse.ModelParameters{Frequency: float64(rate)}, features)
+}
+
+func writeImage(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeCSV(path string, mp *edgeimpulse.ModelParameters, features []float64) error {
+	n := mp.AxisCount
+	if n <= 0 || len(features)%n != 0 {
+		n = 1
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	for i := 0; i < len(features); i += n {
+		row := make([]string, n)
+		for j := range row {
+			row[j] = strconv.FormatFloat(features[i+j], 'g', -1, 64)
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestWriteFeaturesSquare(t *testing.T) {
+	dir, err := os.MkdirTemp("", "eimtrace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outDir = dir
+
+	// Without model parameters, all requests with square features are images.
+	mp := &edgeimpulse.ModelParameters{}
+	features := make([]float64, 4*4)
+	for id := int64(1); id <= 3; id++ {
+		path, err := writeFeatures(mp, id, features)
+		if err != nil {
+			t.Fatalf("request %d: %v", id, err)
+		}
+		if filepath.Ext(path) != ".png" {
+			t.Fatalf("request %d: wrote %s, expected png", id, path)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("request %d: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio/audiocmd"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/pipeline"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sink"
+
+	// Register the image recorder backends.
+	_ "github.com/edgeimpulse/linux-sdk-go/v2/image/ffmpeg"
+	_ "github.com/edgeimpulse/linux-sdk-go/v2/image/gstreamer"
+	_ "github.com/edgeimpulse/linux-sdk-go/v2/image/imagesnap"
+)
+
+// NewRunner starts the model. If TempDir is set, it is first set as root for
+// temporary directories with edgeimpulse.SetTempRoot. Options in opts are
+// applied after those from the configuration.
+func (c Config) NewRunner(opts ...edgeimpulse.RunnerOption) (*edgeimpulse.RunnerProcess, error) {
+	if c.Model == "" {
+		return nil, fmt.Errorf("no model configured")
+	}
+	if c.TempDir != "" {
+		edgeimpulse.SetTempRoot(c.TempDir)
+	}
+	opts = append([]edgeimpulse.RunnerOption{
+		edgeimpulse.WithTraceDir(c.TraceDir),
+		edgeimpulse.WithClassifyTimeout(time.Duration(c.ModelTimeout)),
+		edgeimpulse.WithHelloTimeout(time.Duration(c.ModelHelloTimeout)),
+	}, opts...)
+	return edgeimpulse.NewRunnerProcess(c.Model, opts...)
+}
+
+// NewImageRecorder starts the configured image recorder backend. If Recorder is
+// empty or "auto", the preferred backend with the device is used. If
+// DevicePolicy is set, it is first set with image.SetDevicePolicy.
+func (c Config) NewImageRecorder(ctx context.Context) (image.Recorder, error) {
+	if c.DevicePolicy != "" {
+		p, err := image.ParseDevicePolicy(c.DevicePolicy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing device policy: %w", err)
+		}
+		image.SetDevicePolicy(p)
+	}
+	interval := time.Duration(c.Interval)
+	if interval == 0 {
+		interval = 250 * time.Millisecond
+	}
+	deviceID := c.Device
+	var backend image.Backend
+	if c.Recorder == "" || c.Recorder == "auto" {
+		var dev image.Device
+		var err error
+		backend, dev, err = image.FindDevice(deviceID)
+		if err != nil {
+			return nil, fmt.Errorf("finding device: %w", err)
+		}
+		deviceID = dev.ID
+	} else {
+		var ok bool
+		backend, ok = image.LookupBackend(c.Recorder)
+		if !ok {
+			return nil, fmt.Errorf("unknown recorder %q", c.Recorder)
+		}
+	}
+	return backend.NewRecorder(ctx, image.BackendOpts{
+		Verbose:  c.Verbose,
+		Interval: interval,
+		DeviceID: deviceID,
+	})
+}
+
+// NewImageClassifier returns a classifier for images from recorder. Options in
+// opts are applied after those from the configuration.
+func (c Config) NewImageClassifier(ctx context.Context, runner edgeimpulse.Runner, recorder image.Recorder, opts ...image.ClassifierOption) (*image.Classifier, error) {
+	opts = append([]image.ClassifierOption{image.WithVerbose(c.Verbose), image.WithTraceDir(c.TraceDir)}, opts...)
+	return image.NewClassifier(ctx, runner, recorder, opts...)
+}
+
+// NewAudioRecorder starts recording audio from the configured device, at the
+// frequency of the model.
+func (c Config) NewAudioRecorder(ctx context.Context, mp edgeimpulse.ModelParameters) (*audiocmd.Recorder, error) {
+	opts := &audiocmd.RecorderOpts{
+		SampleRate:    int(mp.Frequency),
+		Channels:      1,
+		AsRaw:         true,
+		RecordProgram: "sox",
+		Verbose:       c.Verbose,
+		DeviceID:      c.Device,
+	}
+	return audiocmd.NewRecorder(ctx, opts)
+}
+
+// NewAudioClassifier returns a classifier for audio from recorder, classifying
+// every Interval, or by default every quarter of the window of the model.
+// Options in opts are applied after those from the configuration.
+func (c Config) NewAudioClassifier(ctx context.Context, runner edgeimpulse.Runner, recorder audio.Recorder, opts ...audio.ClassifierOption) (*audio.Classifier, error) {
+	interval := time.Duration(c.Interval)
+	if interval == 0 {
+		interval = 250 * time.Millisecond
+		mp := runner.ModelParameters()
+		if mp.Frequency > 0 && mp.InputFeaturesCount > 0 {
+			interval = time.Duration(float64(mp.InputFeaturesCount)/mp.Frequency*float64(time.Second)) / 4
+		}
+	}
+	opts = append([]audio.ClassifierOption{audio.WithVerbose(c.Verbose)}, opts...)
+	return audio.NewClassifier(ctx, runner, recorder, interval, opts...)
+}
+
+// NewPipeline returns the configured post-processing filters for a model with
+// labels.
+func (c Config) NewPipeline(labels []string) (*pipeline.Pipeline, error) {
+	return pipeline.Parse(c.Filters, labels)
+}
+
+// OpenSinks opens the configured sinks, by default writing text to stdout.
+func (c Config) OpenSinks() (sink.Sink, error) {
+	return sink.Specs(c.Sinks).Open("text")
+}
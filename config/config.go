@@ -0,0 +1,216 @@
+// Package config loads the configuration of a classification pipeline from a
+// JSON file and environment variables, and constructs the runner, recorders,
+// classifiers, filters and sinks it describes. The commands use the same
+// schema with their -config flag.
+//
+// An example configuration file:
+//
+//	{
+//		"model": "/opt/models/person-detection.eim",
+//		"recorder": "gstreamer",
+//		"device": "/dev/video0",
+//		"interval": "250ms",
+//		"filters": "ema:0.5,threshold:0.6",
+//		"sinks": ["text", "mqtt://localhost:1883/eim/results"]
+//	}
+//
+// Environment variables override values from the file, see ApplyEnv.
+//
+// Only JSON configuration files are supported, not YAML or TOML: the module
+// has no dependencies outside the standard library, which has no parsers for
+// them.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config describes a classification pipeline. Empty fields use the defaults
+// of the commands and constructors.
+type Config struct {
+	Model    string   `json:"model"`              // Path to .eim model file.
+	Recorder string   `json:"recorder,omitempty"` // Image recorder backend, e.g. gstreamer, ffmpeg, imagesnap, or auto. Unused for audio.
+	Device   string   `json:"device,omitempty"`   // Device ID for the recorder.
+	Interval Duration `json:"interval,omitempty"` // How often to classify.
+	Verbose  bool     `json:"verbose,omitempty"`
+	TraceDir string   `json:"tracedir,omitempty"` // If set, directory to write traces of model requests and inputs to.
+	TempDir  string   `json:"tempdir,omitempty"`  // If set, root for temporary directories, see edgeimpulse.SetTempRoot.
+	Filters  string   `json:"filters,omitempty"`  // Post-processing filters, including thresholds, see pipeline.Parse.
+	Sinks    []string `json:"sinks,omitempty"`    // Where to send results, see sink.Parse.
+
+	// Preferred devices if Device is empty, see image.ParseDevicePolicy.
+	DevicePolicy string `json:"device_policy,omitempty"`
+
+	// Maximum time to wait for classifications and for the model to start,
+	// see edgeimpulse.RunnerOpts.
+	ModelTimeout      Duration `json:"model_timeout,omitempty"`
+	ModelHelloTimeout Duration `json:"model_hello_timeout,omitempty"`
+}
+
+// Duration is a time.Duration that is represented in JSON as a string like
+// "250ms", or as a number of nanoseconds.
+type Duration time.Duration
+
+// MarshalJSON returns d as JSON string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON parses a duration string or number of nanoseconds.
+func (d *Duration) UnmarshalJSON(buf []byte) error {
+	var s string
+	if err := json.Unmarshal(buf, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(buf, &n); err != nil {
+			return fmt.Errorf("duration must be a string like \"250ms\" or a number of nanoseconds")
+		}
+		*d = Duration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load reads the JSON configuration at path and applies environment variables
+// with ApplyEnv. If path is empty, only the environment is used.
+func Load(path string) (Config, error) {
+	var c Config
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config: %w", err)
+		}
+		defer f.Close()
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&c); err != nil {
+			return Config{}, fmt.Errorf("parsing config %s: %w", path, err)
+		}
+	}
+	if err := c.ApplyEnv(os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// ApplyEnv overrides fields with environment variables found with lookup,
+// typically os.LookupEnv: EI_MODEL, EI_RECORDER, EI_DEVICE, EI_INTERVAL (e.g.
+// 250ms), EI_VERBOSE (true or false), EI_TRACEDIR, EI_TEMPDIR, EI_FILTERS,
+// EI_SINKS (comma-separated), EI_DEVICE_POLICY, EI_MODEL_TIMEOUT and
+// EI_MODEL_HELLO_TIMEOUT.
+func (c *Config) ApplyEnv(lookup func(key string) (string, bool)) error {
+	strs := []struct {
+		key string
+		p   *string
+	}{
+		{"EI_MODEL", &c.Model},
+		{"EI_RECORDER", &c.Recorder},
+		{"EI_DEVICE", &c.Device},
+		{"EI_TRACEDIR", &c.TraceDir},
+		{"EI_TEMPDIR", &c.TempDir},
+		{"EI_FILTERS", &c.Filters},
+		{"EI_DEVICE_POLICY", &c.DevicePolicy},
+	}
+	for _, s := range strs {
+		if v, ok := lookup(s.key); ok {
+			*s.p = v
+		}
+	}
+	durations := []struct {
+		key string
+		p   *Duration
+	}{
+		{"EI_INTERVAL", &c.Interval},
+		{"EI_MODEL_TIMEOUT", &c.ModelTimeout},
+		{"EI_MODEL_HELLO_TIMEOUT", &c.ModelHelloTimeout},
+	}
+	for _, d := range durations {
+		if v, ok := lookup(d.key); ok {
+			v, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", d.key, err)
+			}
+			*d.p = Duration(v)
+		}
+	}
+	if v, ok := lookup("EI_VERBOSE"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("parsing EI_VERBOSE: %w", err)
+		}
+		c.Verbose = b
+	}
+	if v, ok := lookup("EI_SINKS"); ok {
+		c.Sinks = nil
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				c.Sinks = append(c.Sinks, s)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyFlags sets the command-line flags in fs for the non-empty fields of the
+// configuration, for flags that were not set on the command line, so they
+// take precedence. Flags are named after the JSON fields, with underscores
+// as dashes, and sinks added to the repeatable flag "sink". Flags not defined
+// in fs are skipped. The model is not a flag, but typically the first
+// argument.
+func (c Config) ApplyFlags(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	apply := func(name, value string) error {
+		if value == "" || set[name] || fs.Lookup(name) == nil {
+			return nil
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("setting flag %s from config: %w", name, err)
+		}
+		return nil
+	}
+	values := [][2]string{
+		{"recorder", c.Recorder},
+		{"device", c.Device},
+		{"tracedir", c.TraceDir},
+		{"tempdir", c.TempDir},
+		{"filters", c.Filters},
+		{"device-policy", c.DevicePolicy},
+	}
+	for _, d := range []struct {
+		name string
+		d    Duration
+	}{
+		{"interval", c.Interval},
+		{"model-timeout", c.ModelTimeout},
+		{"model-hello-timeout", c.ModelHelloTimeout},
+	} {
+		if d.d != 0 {
+			values = append(values, [2]string{d.name, time.Duration(d.d).String()})
+		}
+	}
+	if c.Verbose {
+		values = append(values, [2]string{"verbose", "true"})
+	}
+	for _, s := range c.Sinks {
+		values = append(values, [2]string{"sink", s})
+	}
+	for _, v := range values {
+		if err := apply(v[0], v[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package config_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/config"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sink"
+)
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	buf := []byte(`{"model": "model.eim", "recorder": "ffmpeg", "interval": "250ms", "sinks": ["text"]}`)
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+
+	env := map[string]string{"EI_DEVICE": "/dev/video1", "EI_SINKS": "json, file:out.jsonl", "EI_VERBOSE": "true", "EI_MODEL_TIMEOUT": "20s"}
+	err = c.ApplyEnv(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatalf("applying env: %v", err)
+	}
+	exp := config.Config{
+		Model:    "model.eim",
+		Recorder: "ffmpeg",
+		Device:   "/dev/video1",
+		Interval: config.Duration(250 * time.Millisecond),
+		Verbose:  true,
+		Sinks:    []string{"json", "file:out.jsonl"},
+
+		ModelTimeout: config.Duration(20 * time.Second),
+	}
+	if !reflect.DeepEqual(c, exp) {
+		t.Fatalf("got config %+v, expected %+v", c, exp)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	recorder := fs.String("recorder", "gstreamer", "")
+	device := fs.String("device", "", "")
+	interval := fs.Duration("interval", time.Second, "")
+	var sinks sink.Specs
+	fs.Var(&sinks, "sink", "")
+	if err := fs.Parse([]string{"-device", "/dev/video2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ApplyFlags(fs); err != nil {
+		t.Fatalf("applying flags: %v", err)
+	}
+	if *recorder != "ffmpeg" || *device != "/dev/video2" || *interval != 250*time.Millisecond || !reflect.DeepEqual([]string(sinks), exp.Sinks) {
+		t.Fatalf("got flags recorder %q, device %q, interval %v, sinks %v", *recorder, *device, *interval, sinks)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	buf := []byte(`{"model": "model.eim", "recorder": "ffmpeg", "interval": "250ms"}`)
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"EI_RECORDER": "gstreamer", "EI_TEMPDIR": "/tmp/eim"} {
+		prev, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+		defer func(key string) {
+			if ok {
+				os.Setenv(key, prev)
+			} else {
+				os.Unsetenv(key)
+			}
+		}(key)
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("loading config: %v", err)
+	}
+	exp := config.Config{
+		Model:    "model.eim",
+		Recorder: "gstreamer",
+		Interval: config.Duration(250 * time.Millisecond),
+		TempDir:  "/tmp/eim",
+	}
+	if !reflect.DeepEqual(c, exp) {
+		t.Fatalf("got config %+v, expected %+v", c, exp)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"modle": "model.eim"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(path); err == nil {
+		t.Fatalf("loading config with unknown field succeeded, expected error")
+	}
+}
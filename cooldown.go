@@ -0,0 +1,55 @@
+package edgeimpulse
+
+import (
+	"time"
+)
+
+// Cooldown suppresses repeated events for a label: after an event for a label
+// is allowed, further events for that label are suppressed for a period. This
+// is typically used for notifications, e.g. "notify me when a person is
+// detected", where a single notification per occurrence is wanted.
+type Cooldown struct {
+	period time.Duration
+	until  map[string]time.Time // Per label, end of the cooldown period.
+}
+
+// NewCooldown returns a new cooldown with the given suppression period.
+func NewCooldown(period time.Duration) *Cooldown {
+	return &Cooldown{period, map[string]time.Time{}}
+}
+
+// Allow returns whether an event for label at time t is allowed. If so, a new
+// cooldown period for label starts at t.
+func (c *Cooldown) Allow(label string, t time.Time) bool {
+	if until, ok := c.until[label]; ok && t.Before(until) {
+		return false
+	}
+	c.until[label] = t.Add(c.period)
+	return true
+}
+
+// Remaining returns how long events for label will still be suppressed at time
+// t, 0 if events are allowed.
+func (c *Cooldown) Remaining(label string, t time.Time) time.Duration {
+	if until, ok := c.until[label]; ok && t.Before(until) {
+		return until.Sub(t)
+	}
+	return 0
+}
+
+// Filter returns the events that are allowed at time t. Only DetectorActivated
+// events are subject to cooldown, other events are always returned.
+func (c *Cooldown) Filter(events []DetectorEvent, t time.Time) []DetectorEvent {
+	var r []DetectorEvent
+	for _, ev := range events {
+		if ev.Type != DetectorActivated || c.Allow(ev.Label, t) {
+			r = append(r, ev)
+		}
+	}
+	return r
+}
+
+// Reset ends all cooldown periods.
+func (c *Cooldown) Reset() {
+	c.until = map[string]time.Time{}
+}
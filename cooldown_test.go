@@ -0,0 +1,39 @@
+package edgeimpulse_test
+
+import (
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestCooldown(t *testing.T) {
+	c := edgeimpulse.NewCooldown(10 * time.Second)
+	t0 := time.Unix(1000, 0)
+
+	if !c.Allow("person", t0) {
+		t.Errorf("first event suppressed")
+	}
+	if c.Allow("person", t0.Add(5*time.Second)) {
+		t.Errorf("event during cooldown allowed")
+	}
+	if !c.Allow("dog", t0.Add(5*time.Second)) {
+		t.Errorf("event for other label suppressed")
+	}
+	if d := c.Remaining("person", t0.Add(4*time.Second)); d != 6*time.Second {
+		t.Errorf("got remaining %v, expected 6s", d)
+	}
+	if !c.Allow("person", t0.Add(10*time.Second)) {
+		t.Errorf("event after cooldown suppressed")
+	}
+
+	c.Reset()
+	events := []edgeimpulse.DetectorEvent{
+		{Type: edgeimpulse.DetectorActivated, Label: "person"},
+		{Type: edgeimpulse.DetectorDeactivated, Label: "person"},
+		{Type: edgeimpulse.DetectorActivated, Label: "person"},
+	}
+	if r := c.Filter(events, t0); len(r) != 2 || r[1].Type != edgeimpulse.DetectorDeactivated {
+		t.Errorf("got %+v, expected activation and deactivation", r)
+	}
+}
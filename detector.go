@@ -0,0 +1,127 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DetectorEventType indicates the kind of state change of a label.
+type DetectorEventType string
+
+// Types of DetectorEvents.
+const (
+	DetectorActivated   DetectorEventType = "activated"
+	DetectorDeactivated DetectorEventType = "deactivated"
+)
+
+// DetectorEvent is a change in state of a label, returned by Detector.Update.
+type DetectorEvent struct {
+	Type  DetectorEventType
+	Label string
+	Score float64 // Score of the label in the classification causing the change.
+}
+
+// DetectorOpts are options for a Detector.
+type DetectorOpts struct {
+	// Score at or above which a label counts as present.
+	Threshold float64
+
+	// Score below which an active label counts as absent. Set lower than
+	// Threshold for hysteresis. If zero, Threshold is used.
+	ReleaseThreshold float64
+
+	// Number of consecutive classifications in which a label must be
+	// present before it becomes active. Values below 1 are treated as 1.
+	Activate int
+
+	// Number of consecutive classifications in which an active label must
+	// be absent before it becomes inactive. Values below 1 are treated as 1.
+	Deactivate int
+}
+
+type detectorState struct {
+	active bool
+	count  int // Consecutive classifications towards changing state.
+}
+
+// Detector turns a stream of (typically smoothed) classifications into
+// discrete events for labels becoming active and inactive, with debouncing and
+// hysteresis to prevent flapping around the threshold.
+type Detector struct {
+	opts  DetectorOpts
+	state map[string]*detectorState
+}
+
+// NewDetector returns a new detector. Initially, all labels are inactive.
+func NewDetector(opts DetectorOpts) (*Detector, error) {
+	if opts.ReleaseThreshold == 0 {
+		opts.ReleaseThreshold = opts.Threshold
+	}
+	if opts.ReleaseThreshold > opts.Threshold {
+		return nil, fmt.Errorf("release threshold must be <= threshold")
+	}
+	if opts.Activate < 1 {
+		opts.Activate = 1
+	}
+	if opts.Deactivate < 1 {
+		opts.Deactivate = 1
+	}
+	return &Detector{opts, map[string]*detectorState{}}, nil
+}
+
+// Update processes one classification, and returns the resulting state
+// changes, ordered by label. Labels missing from the classification are
+// treated as having score 0.
+func (d *Detector) Update(classification map[string]float64) []DetectorEvent {
+	for label := range classification {
+		if _, ok := d.state[label]; !ok {
+			d.state[label] = &detectorState{}
+		}
+	}
+	labels := make([]string, 0, len(d.state))
+	for label := range d.state {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var events []DetectorEvent
+	for _, label := range labels {
+		ls := d.state[label]
+		score := classification[label]
+		if !ls.active {
+			if score < d.opts.Threshold {
+				ls.count = 0
+				continue
+			}
+			ls.count++
+			if ls.count >= d.opts.Activate {
+				ls.active = true
+				ls.count = 0
+				events = append(events, DetectorEvent{DetectorActivated, label, score})
+			}
+		} else {
+			if score >= d.opts.ReleaseThreshold {
+				ls.count = 0
+				continue
+			}
+			ls.count++
+			if ls.count >= d.opts.Deactivate {
+				ls.active = false
+				ls.count = 0
+				events = append(events, DetectorEvent{DetectorDeactivated, label, score})
+			}
+		}
+	}
+	return events
+}
+
+// Active returns whether label is currently active.
+func (d *Detector) Active(label string) bool {
+	ls, ok := d.state[label]
+	return ok && ls.active
+}
+
+// Reset makes all labels inactive, without emitting events.
+func (d *Detector) Reset() {
+	d.state = map[string]*detectorState{}
+}
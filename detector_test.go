@@ -0,0 +1,52 @@
+package edgeimpulse_test
+
+import (
+	"reflect"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestDetector(t *testing.T) {
+	_, err := edgeimpulse.NewDetector(edgeimpulse.DetectorOpts{Threshold: 0.5, ReleaseThreshold: 0.6})
+	if err == nil {
+		t.Fatalf("missing error for release threshold above threshold")
+	}
+
+	d, err := edgeimpulse.NewDetector(edgeimpulse.DetectorOpts{Threshold: 0.8, ReleaseThreshold: 0.5, Activate: 2, Deactivate: 2})
+	if err != nil {
+		t.Fatalf("new detector: %v", err)
+	}
+
+	update := func(score float64, exp []edgeimpulse.DetectorEvent) {
+		t.Helper()
+		events := d.Update(map[string]float64{"a": score, "b": 1 - score})
+		if !reflect.DeepEqual(events, exp) {
+			t.Fatalf("update with score %v, got %v, expected %v", score, events, exp)
+		}
+	}
+
+	update(0.9, nil)
+	update(0.7, nil) // Not consecutive.
+	update(0.9, nil)
+	update(0.85, []edgeimpulse.DetectorEvent{{Type: edgeimpulse.DetectorActivated, Label: "a", Score: 0.85}})
+	if !d.Active("a") || d.Active("b") {
+		t.Fatalf("unexpected active state")
+	}
+	update(0.6, nil) // Above release threshold.
+	update(0.4, nil)
+	update(0.6, nil) // Not consecutive.
+	update(0.4, nil)
+	update(0.1, []edgeimpulse.DetectorEvent{{Type: edgeimpulse.DetectorDeactivated, Label: "a", Score: 0.1}})
+
+	// Missing labels count as 0.
+	d.Reset()
+	d.Update(map[string]float64{"a": 0.9})
+	d.Update(map[string]float64{"a": 0.9})
+	d.Update(map[string]float64{})
+	events := d.Update(map[string]float64{"b": 0.3})
+	exp := []edgeimpulse.DetectorEvent{{Type: edgeimpulse.DetectorDeactivated, Label: "a", Score: 0}}
+	if !reflect.DeepEqual(events, exp) {
+		t.Fatalf("got %v, expected %v", events, exp)
+	}
+}
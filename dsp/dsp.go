@@ -0,0 +1,166 @@
+// Package dsp computes features of audio like the MFE and MFCC processing
+// blocks of Edge Impulse Studio, for running the neural network of an impulse
+// without its model process, e.g. with package runner/onnx or runner/tflite.
+//
+// Samples are the int16 values sent by audio classifiers. Features are
+// returned flattened, frame after frame, the input format of the network.
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// frames splits samples into frames of length, starting every stride samples.
+// Trailing samples that do not fill a frame are dropped.
+func frames(samples []float64, length, stride int) [][]float64 {
+	var l [][]float64
+	for i := 0; i+length <= len(samples); i += stride {
+		l = append(l, samples[i:i+length])
+	}
+	return l
+}
+
+// powerSpectrum returns |FFT(frame)|²/n for the n/2+1 non-negative
+// frequencies, with frame truncated or zero padded to n.
+func powerSpectrum(frame []float64, n int) []float64 {
+	x := make([]complex128, n)
+	for i := 0; i < n && i < len(frame); i++ {
+		x[i] = complex(frame[i], 0)
+	}
+	fft(x)
+	power := make([]float64, n/2+1)
+	for i := range power {
+		a := cmplx.Abs(x[i])
+		power[i] = a * a / float64(n)
+	}
+	return power
+}
+
+// fft computes the discrete Fourier transform of x in place. The length of x
+// must be a power of 2.
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
+
+func hzToMel(f float64) float64 {
+	return 1127 * math.Log(1+f/700)
+}
+
+func melToHz(m float64) float64 {
+	return 700 * (math.Exp(m/1127) - 1)
+}
+
+// filterbank returns n triangular filters, equally spaced on the mel scale
+// between low and high Hz, over the fftLength/2+1 bins of a power spectrum.
+func filterbank(n, fftLength int, frequency, low, high float64) [][]float64 {
+	lowMel, highMel := hzToMel(low), hzToMel(high)
+	bins := make([]int, n+2)
+	for i := range bins {
+		hz := melToHz(lowMel + (highMel-lowMel)*float64(i)/float64(n+1))
+		bins[i] = int(math.Floor(float64(fftLength+1) * hz / frequency))
+	}
+	fb := make([][]float64, n)
+	for i := range fb {
+		fb[i] = make([]float64, fftLength/2+1)
+		left, center, right := bins[i], bins[i+1], bins[i+2]
+		for j := left; j < center && j < len(fb[i]); j++ {
+			fb[i][j] = float64(j-left) / float64(center-left)
+		}
+		for j := center; j < right && j < len(fb[i]); j++ {
+			fb[i][j] = float64(right-j) / float64(right-center)
+		}
+	}
+	return fb
+}
+
+// frameOpts are the settings shared by MFE and MFCC.
+type frameOpts struct {
+	length, stride int
+	fftLength      int
+	filters        [][]float64
+}
+
+func newFrameOpts(frequency, frameLength, frameStride float64, filters, fftLength int, low, high float64) (frameOpts, error) {
+	if frequency <= 0 {
+		return frameOpts{}, fmt.Errorf("frequency must be > 0")
+	}
+	if frameLength == 0 {
+		frameLength = 0.02
+	}
+	if frameStride == 0 {
+		frameStride = 0.01
+	}
+	if filters == 0 {
+		filters = 40
+	}
+	if fftLength == 0 {
+		fftLength = 256
+	}
+	if fftLength&(fftLength-1) != 0 {
+		return frameOpts{}, fmt.Errorf("fft length %d is not a power of 2", fftLength)
+	}
+	if high == 0 {
+		high = frequency / 2
+	}
+	if low < 0 || low >= high {
+		return frameOpts{}, fmt.Errorf("low frequency %v must be between 0 and high frequency %v", low, high)
+	}
+	fo := frameOpts{
+		length:    int(math.Round(frameLength * frequency)),
+		stride:    int(math.Round(frameStride * frequency)),
+		fftLength: fftLength,
+		filters:   filterbank(filters, fftLength, frequency, low, high),
+	}
+	if fo.length <= 0 || fo.stride <= 0 {
+		return frameOpts{}, fmt.Errorf("frame length and stride must be at least a sample")
+	}
+	return fo, nil
+}
+
+// energies returns the filterbank energies of each frame of samples, with
+// zeroes replaced by a tiny value so they can be logged.
+func (fo frameOpts) energies(samples []float64) ([][]float64, error) {
+	l := frames(samples, fo.length, fo.stride)
+	if len(l) == 0 {
+		return nil, fmt.Errorf("got %d samples, need at least %d for a frame", len(samples), fo.length)
+	}
+	e := make([][]float64, len(l))
+	for i, frame := range l {
+		power := powerSpectrum(frame, fo.fftLength)
+		e[i] = make([]float64, len(fo.filters))
+		for j, f := range fo.filters {
+			var sum float64
+			for k, w := range f {
+				sum += w * power[k]
+			}
+			if sum == 0 {
+				sum = math.SmallestNonzeroFloat64
+			}
+			e[i][j] = sum
+		}
+	}
+	return e, nil
+}
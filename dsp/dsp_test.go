@@ -0,0 +1,114 @@
+package dsp
+
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestFFT(t *testing.T) {
+	x := []complex128{1, 2, 3, 4, 0, -1, 2, 5}
+	expected := make([]complex128, len(x))
+	for k := range expected {
+		for i, v := range x {
+			expected[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*i)/float64(len(x))))
+		}
+	}
+	fft(x)
+	for k := range x {
+		if cmplx.Abs(x[k]-expected[k]) > 1e-9 {
+			t.Fatalf("got %v, expected %v", x, expected)
+		}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	var got []int
+	for i := -3; i < 6; i++ {
+		got = append(got, mirror(i, 3))
+	}
+	expected := []int{2, 1, 0, 0, 1, 2, 2, 1, 0}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("got %v, expected %v", got, expected)
+		}
+	}
+}
+
+// sine returns a second of a tone of hz at frequency, as int16 samples.
+func sine(hz, frequency float64) []float64 {
+	samples := make([]float64, int(frequency))
+	for i := range samples {
+		samples[i] = 16000 * math.Sin(2*math.Pi*hz*float64(i)/frequency)
+	}
+	return samples
+}
+
+func TestMFE(t *testing.T) {
+	if _, err := NewMFE(16000, MFEOpts{FFTLength: 300}); err == nil {
+		t.Errorf("fft length not a power of 2 accepted")
+	}
+	m, err := NewMFE(16000, MFEOpts{})
+	if err != nil {
+		t.Fatalf("new mfe: %v", err)
+	}
+	features, err := m.Features(sine(1000, 16000))
+	if err != nil {
+		t.Fatalf("features: %v", err)
+	}
+	// 99 frames of 320 samples every 160 samples, 40 filters each.
+	if len(features) != 99*40 {
+		t.Fatalf("got %d features, expected %d", len(features), 99*40)
+	}
+	frame := features[:40]
+	var max int
+	for i, v := range frame {
+		if v < 0 || v > 1 {
+			t.Fatalf("feature %v out of range", v)
+		}
+		if v > frame[max] {
+			max = i
+		}
+	}
+	// The filter around 1kHz has the most energy.
+	fb := m.fo.filters[max]
+	var peak int
+	for i, w := range fb {
+		if w > fb[peak] {
+			peak = i
+		}
+	}
+	if hz := float64(peak) * 16000 / 256; math.Abs(hz-1000) > 200 {
+		t.Errorf("highest energy in filter around %vHz, expected 1000Hz", hz)
+	}
+
+	if _, err := m.Features(make([]float64, 100)); err == nil {
+		t.Errorf("features for less than a frame succeeded")
+	}
+}
+
+func TestMFCC(t *testing.T) {
+	m, err := NewMFCC(16000, MFCCOpts{PreEmphasis: -1})
+	if err != nil {
+		t.Fatalf("new mfcc: %v", err)
+	}
+	// Repeat a frame, so all frames are exactly the same.
+	tone := sine(500, 16000)
+	for i := 320; i < len(tone); i++ {
+		tone[i] = tone[i-320]
+	}
+	features, err := m.Features(tone)
+	if err != nil {
+		t.Fatalf("features: %v", err)
+	}
+	// 50 frames of 320 samples every 320 samples, 13 coefficients each.
+	if len(features) != 50*13 {
+		t.Fatalf("got %d features, expected %d", len(features), 50*13)
+	}
+	// Coefficients of identical frames are zero after subtracting the mean.
+	for _, v := range features {
+		if math.Abs(v) > 1e-6 {
+			t.Fatalf("got coefficient %v, expected 0", v)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package dsp
+
+import (
+	"math"
+)
+
+// MFCCOpts are the parameters of an MFCC block, as configured in Studio. Zero
+// values are replaced by the defaults of Studio.
+type MFCCOpts struct {
+	// Number of cepstral coefficients per frame, default 13.
+	Coefficients int
+
+	// Length and stride of frames in seconds. Default 0.02 and 0.02.
+	FrameLength float64
+	FrameStride float64
+
+	// Number of mel filters, default 32.
+	Filters int
+
+	// Number of FFT points, a power of 2, default 256.
+	FFTLength int
+
+	// Number of frames of the window over which the mean of coefficients is
+	// subtracted, default 101.
+	Window int
+
+	// Range of the filters in Hz. If HighFrequency is 0, half the sampling
+	// frequency is used. LowFrequency defaults to 300Hz.
+	LowFrequency  float64
+	HighFrequency float64
+
+	// Pre-emphasis coefficient, default 0.98. Set to a negative value to
+	// disable.
+	PreEmphasis float64
+}
+
+// MFCC computes mel-frequency cepstral coefficients, like the MFCC block of
+// Studio.
+type MFCC struct {
+	fo           frameOpts
+	coefficients int
+	window       int
+	preEmphasis  float64
+}
+
+// NewMFCC returns an MFCC block for audio of frequency Hz.
+func NewMFCC(frequency float64, opts MFCCOpts) (*MFCC, error) {
+	if opts.FrameStride == 0 {
+		opts.FrameStride = 0.02
+	}
+	if opts.Filters == 0 {
+		opts.Filters = 32
+	}
+	if opts.LowFrequency == 0 {
+		opts.LowFrequency = 300
+	}
+	fo, err := newFrameOpts(frequency, opts.FrameLength, opts.FrameStride, opts.Filters, opts.FFTLength, opts.LowFrequency, opts.HighFrequency)
+	if err != nil {
+		return nil, err
+	}
+	m := &MFCC{fo: fo, coefficients: opts.Coefficients, window: opts.Window, preEmphasis: opts.PreEmphasis}
+	if m.coefficients == 0 {
+		m.coefficients = 13
+	}
+	if m.coefficients > opts.Filters {
+		m.coefficients = opts.Filters
+	}
+	if m.window == 0 {
+		m.window = 101
+	}
+	if m.preEmphasis == 0 {
+		m.preEmphasis = 0.98
+	}
+	return m, nil
+}
+
+// Features returns the coefficients of each frame of samples, normalized by
+// subtracting the mean over a sliding window of frames.
+func (m *MFCC) Features(samples []float64) ([]float64, error) {
+	s := samples
+	if m.preEmphasis > 0 {
+		s = make([]float64, len(samples))
+		for i, v := range samples {
+			if i == 0 {
+				s[i] = v
+			} else {
+				s[i] = v - m.preEmphasis*samples[i-1]
+			}
+		}
+	}
+	e, err := m.fo.energies(s)
+	if err != nil {
+		return nil, err
+	}
+	coefs := make([][]float64, len(e))
+	for i, frame := range e {
+		for j := range frame {
+			frame[j] = math.Log(frame[j])
+		}
+		coefs[i] = dct(frame)[:m.coefficients]
+	}
+	var features []float64
+	for _, frame := range cmvn(coefs, m.window) {
+		features = append(features, frame...)
+	}
+	return features, nil
+}
+
+// dct returns the orthonormal type 2 discrete cosine transform of x.
+func dct(x []float64) []float64 {
+	n := len(x)
+	y := make([]float64, n)
+	for k := range y {
+		var sum float64
+		for i, v := range x {
+			sum += v * math.Cos(math.Pi*float64(k)*(2*float64(i)+1)/(2*float64(n)))
+		}
+		scale := math.Sqrt(2 / float64(n))
+		if k == 0 {
+			scale = math.Sqrt(1 / float64(n))
+		}
+		y[k] = sum * scale
+	}
+	return y
+}
+
+// cmvn subtracts from each frame the mean of the window frames around it,
+// with frames mirrored at the edges.
+func cmvn(frames [][]float64, window int) [][]float64 {
+	n := len(frames)
+	pad := (window - 1) / 2
+	out := make([][]float64, n)
+	for i := range frames {
+		mean := make([]float64, len(frames[i]))
+		for j := i - pad; j < i-pad+window; j++ {
+			for k, v := range frames[mirror(j, n)] {
+				mean[k] += v / float64(window)
+			}
+		}
+		out[i] = make([]float64, len(frames[i]))
+		for k, v := range frames[i] {
+			out[i][k] = v - mean[k]
+		}
+	}
+	return out
+}
+
+// mirror maps index i into [0, n), reflecting at the edges with the edge
+// repeated, like symmetric padding.
+func mirror(i, n int) int {
+	period := 2 * n
+	i %= period
+	if i < 0 {
+		i += period
+	}
+	if i >= n {
+		i = period - 1 - i
+	}
+	return i
+}
@@ -0,0 +1,68 @@
+package dsp
+
+import (
+	"math"
+)
+
+// MFEOpts are the parameters of an MFE block, as configured in Studio. Zero
+// values are replaced by the defaults of Studio.
+type MFEOpts struct {
+	// Length and stride of frames in seconds. Default 0.02 and 0.01.
+	FrameLength float64
+	FrameStride float64
+
+	// Number of mel filters, default 40.
+	Filters int
+
+	// Number of FFT points, a power of 2, default 256.
+	FFTLength int
+
+	// Range of the filters in Hz. If HighFrequency is 0, half the sampling
+	// frequency is used.
+	LowFrequency  float64
+	HighFrequency float64
+
+	// Energies below this level are clipped to 0. Default -52dB.
+	NoiseFloorDB float64
+}
+
+// MFE computes mel-filterbank energies, like the MFE block of Studio.
+type MFE struct {
+	fo         frameOpts
+	noiseFloor float64
+}
+
+// NewMFE returns an MFE block for audio of frequency Hz.
+func NewMFE(frequency float64, opts MFEOpts) (*MFE, error) {
+	fo, err := newFrameOpts(frequency, opts.FrameLength, opts.FrameStride, opts.Filters, opts.FFTLength, opts.LowFrequency, opts.HighFrequency)
+	if err != nil {
+		return nil, err
+	}
+	if opts.NoiseFloorDB == 0 {
+		opts.NoiseFloorDB = -52
+	}
+	return &MFE{fo, opts.NoiseFloorDB}, nil
+}
+
+// Features returns the energies of each frame of samples, in dB, scaled from
+// the noise floor to 0 to 1, and quantized to steps of 1/256.
+func (m *MFE) Features(samples []float64) ([]float64, error) {
+	scaled := make([]float64, len(samples))
+	for i, v := range samples {
+		scaled[i] = v / 32768
+	}
+	e, err := m.fo.energies(scaled)
+	if err != nil {
+		return nil, err
+	}
+	var features []float64
+	for _, frame := range e {
+		for _, v := range frame {
+			v = 10 * math.Log10(math.Max(v, 1e-30))
+			v = (v - m.noiseFloor) / (-m.noiseFloor + 12)
+			v = math.Min(math.Max(v, 0), 1)
+			features = append(features, math.Round(v*256)/256)
+		}
+	}
+	return features, nil
+}
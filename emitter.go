@@ -0,0 +1,107 @@
+package edgeimpulse
+
+import (
+	"sync"
+)
+
+type emitterHandler struct {
+	label     string
+	threshold float64
+	above     bool // Whether last score was at or above threshold.
+	fn        func(label string, score float64, above bool)
+	changes   int // 1 for only calling when going above, -1 only for going below, 0 for both.
+}
+
+// Emitter calls registered functions when the score of a label crosses a
+// threshold. Scores are fed with Update or UpdateResponse, typically from the
+// events of an image or audio classifier. Initially, all labels are considered
+// below their thresholds.
+//
+// Functions are called synchronously from Update and UpdateResponse.
+type Emitter struct {
+	mutex    sync.Mutex
+	handlers []*emitterHandler
+}
+
+// NewEmitter returns a new emitter without registered functions.
+func NewEmitter() *Emitter {
+	return &Emitter{}
+}
+
+func (e *Emitter) register(label string, threshold float64, changes int, fn func(label string, score float64, above bool)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.handlers = append(e.handlers, &emitterHandler{label, threshold, false, fn, changes})
+}
+
+// OnAbove registers fn to be called when the score of label goes from below to
+// at or above threshold.
+func (e *Emitter) OnAbove(label string, threshold float64, fn func(label string, score float64)) {
+	e.register(label, threshold, 1, func(label string, score float64, above bool) {
+		fn(label, score)
+	})
+}
+
+// OnBelow registers fn to be called when the score of label goes from at or
+// above threshold to below threshold.
+func (e *Emitter) OnBelow(label string, threshold float64, fn func(label string, score float64)) {
+	e.register(label, threshold, -1, func(label string, score float64, above bool) {
+		fn(label, score)
+	})
+}
+
+// OnChange registers fn to be called when the score of label crosses
+// threshold in either direction.
+func (e *Emitter) OnChange(label string, threshold float64, fn func(label string, score float64, above bool)) {
+	e.register(label, threshold, 0, fn)
+}
+
+// Update feeds the scores of a classification to the emitter, calling the
+// functions for all thresholds crossed. Labels missing from classification are
+// treated as having score 0.
+func (e *Emitter) Update(classification map[string]float64) {
+	// Calls are made after releasing the lock, with the state copied while
+	// holding it, as a concurrent Update can change the handlers.
+	type call struct {
+		fn    func(label string, score float64, above bool)
+		label string
+		score float64
+		above bool
+	}
+	var calls []call
+
+	e.mutex.Lock()
+	for _, h := range e.handlers {
+		score := classification[h.label]
+		above := score >= h.threshold
+		if above == h.above {
+			continue
+		}
+		h.above = above
+		if h.changes == 0 || above == (h.changes > 0) {
+			calls = append(calls, call{h.fn, h.label, score, above})
+		}
+	}
+	e.mutex.Unlock()
+
+	for _, c := range calls {
+		c.fn(c.label, c.score, c.above)
+	}
+}
+
+// UpdateResponse feeds a classify response to the emitter. For object
+// detection models, the score of a label is the highest value of its bounding
+// boxes.
+func (e *Emitter) UpdateResponse(resp RunnerClassifyResponse) {
+	if resp.Result.BoundingBoxes == nil {
+		e.Update(resp.Result.Classification)
+		return
+	}
+	scores := map[string]float64{}
+	for _, b := range resp.Result.BoundingBoxes {
+		if b.Value > scores[b.Label] {
+			scores[b.Label] = b.Value
+		}
+	}
+	e.Update(scores)
+}
@@ -0,0 +1,70 @@
+package edgeimpulse_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestEmitter(t *testing.T) {
+	var calls []string
+	e := edgeimpulse.NewEmitter()
+	e.OnAbove("a", 0.5, func(label string, score float64) {
+		calls = append(calls, fmt.Sprintf("above %s %v", label, score))
+	})
+	e.OnBelow("a", 0.5, func(label string, score float64) {
+		calls = append(calls, fmt.Sprintf("below %s %v", label, score))
+	})
+	e.OnChange("b", 0.8, func(label string, score float64, above bool) {
+		calls = append(calls, fmt.Sprintf("change %s %v %v", label, score, above))
+	})
+
+	e.Update(map[string]float64{"a": 0.1, "b": 0.1})
+	e.Update(map[string]float64{"a": 0.6, "b": 0.1})
+	e.Update(map[string]float64{"a": 0.7, "b": 0.9})
+	e.Update(map[string]float64{"a": 0.2})
+
+	var resp edgeimpulse.RunnerClassifyResponse
+	if err := json.Unmarshal([]byte(`{"result": {"bounding_boxes": [{"label": "b", "value": 0.85}]}}`), &resp); err != nil {
+		t.Fatalf("parsing response: %v", err)
+	}
+	e.UpdateResponse(resp)
+
+	exp := []string{
+		"above a 0.6",
+		"change b 0.9 true",
+		"below a 0.2",
+		"change b 0 false",
+		"change b 0.85 true",
+	}
+	if !reflect.DeepEqual(calls, exp) {
+		t.Fatalf("got calls %v, expected %v", calls, exp)
+	}
+}
+
+func TestEmitterConcurrent(t *testing.T) {
+	e := edgeimpulse.NewEmitter()
+	var mutex sync.Mutex
+	e.OnChange("a", 0.5, func(label string, score float64, above bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if above != (score >= 0.5) {
+			t.Errorf("got above %v for score %v", above, score)
+		}
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				e.Update(map[string]float64{"a": float64(j % 2)})
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,182 @@
+package edgeimpulse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ClassMetrics are evaluation metrics for a single label.
+type ClassMetrics struct {
+	Label     string  `json:"label"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"` // Number of samples with this expected label.
+}
+
+// Evaluation accumulates pairs of predicted and expected labels, and computes
+// accuracy, per-label metrics and a confusion matrix.
+type Evaluation struct {
+	labels []string
+	index  map[string]int
+	matrix [][]int // Expected label (row) by predicted label (column).
+	total  int
+}
+
+// NewEvaluation returns a new evaluation. Labels determine the order of labels
+// in the confusion matrix and metrics. Labels not in labels are added when
+// first seen.
+func NewEvaluation(labels []string) *Evaluation {
+	e := &Evaluation{index: map[string]int{}}
+	for _, label := range labels {
+		e.label(label)
+	}
+	return e
+}
+
+// label returns the index of label, adding it if needed.
+func (e *Evaluation) label(label string) int {
+	if i, ok := e.index[label]; ok {
+		return i
+	}
+	i := len(e.labels)
+	e.labels = append(e.labels, label)
+	e.index[label] = i
+	for j := range e.matrix {
+		e.matrix[j] = append(e.matrix[j], 0)
+	}
+	e.matrix = append(e.matrix, make([]int, len(e.labels)))
+	return i
+}
+
+// Add adds a single prediction for a sample with the expected label.
+func (e *Evaluation) Add(predicted, expected string) {
+	p := e.label(predicted)
+	x := e.label(expected)
+	e.matrix[x][p]++
+	e.total++
+}
+
+// AddResponse adds the label with the highest score in the classification of
+// resp as prediction for a sample with the expected label. Responses without
+// classification are ignored.
+func (e *Evaluation) AddResponse(resp RunnerClassifyResponse, expected string) {
+	var label string
+	score := -1.0
+	for l, v := range resp.Result.Classification {
+		if v > score || v == score && l < label {
+			label, score = l, v
+		}
+	}
+	if label != "" {
+		e.Add(label, expected)
+	}
+}
+
+// Labels returns the labels in the order of the confusion matrix.
+func (e *Evaluation) Labels() []string {
+	return append([]string(nil), e.labels...)
+}
+
+// Total returns the number of samples added.
+func (e *Evaluation) Total() int {
+	return e.total
+}
+
+// Accuracy returns the fraction of samples predicted correctly.
+func (e *Evaluation) Accuracy() float64 {
+	if e.total == 0 {
+		return 0
+	}
+	var correct int
+	for i := range e.matrix {
+		correct += e.matrix[i][i]
+	}
+	return float64(correct) / float64(e.total)
+}
+
+// ConfusionMatrix returns a copy of the confusion matrix. Rows are expected
+// labels, columns are predicted labels, both in the order of Labels.
+func (e *Evaluation) ConfusionMatrix() [][]int {
+	m := make([][]int, len(e.matrix))
+	for i, row := range e.matrix {
+		m[i] = append([]int(nil), row...)
+	}
+	return m
+}
+
+// Metrics returns precision, recall and F1 for each label, in the order of
+// Labels.
+func (e *Evaluation) Metrics() []ClassMetrics {
+	r := make([]ClassMetrics, len(e.labels))
+	for i, label := range e.labels {
+		var predicted, expected int
+		for j := range e.labels {
+			predicted += e.matrix[j][i]
+			expected += e.matrix[i][j]
+		}
+		m := ClassMetrics{Label: label, Support: expected}
+		tp := float64(e.matrix[i][i])
+		if predicted > 0 {
+			m.Precision = tp / float64(predicted)
+		}
+		if expected > 0 {
+			m.Recall = tp / float64(expected)
+		}
+		if m.Precision+m.Recall > 0 {
+			m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+		}
+		r[i] = m
+	}
+	return r
+}
+
+// MarshalJSON returns the labels, accuracy, metrics and confusion matrix.
+func (e *Evaluation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Labels          []string       `json:"labels"`
+		Total           int            `json:"total"`
+		Accuracy        float64        `json:"accuracy"`
+		Metrics         []ClassMetrics `json:"metrics"`
+		ConfusionMatrix [][]int        `json:"confusion_matrix"`
+	}{e.Labels(), e.total, e.Accuracy(), e.Metrics(), e.ConfusionMatrix()})
+}
+
+// String returns a printable report with accuracy, metrics per label and the
+// confusion matrix.
+func (e *Evaluation) String() string {
+	width := len("expected")
+	for _, label := range e.labels {
+		if len(label) > width {
+			width = len(label)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "accuracy %.4f (%d samples)\n\n", e.Accuracy(), e.total)
+	fmt.Fprintf(&b, "%-*s  precision  recall     f1  support\n", width, "label")
+	for _, m := range e.Metrics() {
+		fmt.Fprintf(&b, "%-*s  %9.4f  %6.4f %6.4f  %7d\n", width, m.Label, m.Precision, m.Recall, m.F1, m.Support)
+	}
+
+	// Columns are at least as wide as the largest count.
+	cw := len(fmt.Sprint(e.total))
+	for _, label := range e.labels {
+		if len(label) > cw {
+			cw = len(label)
+		}
+	}
+	fmt.Fprintf(&b, "\n%-*s", width, "expected")
+	for _, label := range e.labels {
+		fmt.Fprintf(&b, "  %*s", cw, label)
+	}
+	b.WriteString("\n")
+	for i, label := range e.labels {
+		fmt.Fprintf(&b, "%-*s", width, label)
+		for _, n := range e.matrix[i] {
+			fmt.Fprintf(&b, "  %*d", cw, n)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,41 @@
+package edgeimpulse_test
+
+import (
+	"math"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestEvaluation(t *testing.T) {
+	e := edgeimpulse.NewEvaluation([]string{"yes", "no"})
+	e.Add("yes", "yes")
+	e.Add("yes", "yes")
+	e.Add("no", "yes")
+	e.Add("no", "no")
+	e.Add("yes", "no")
+	e.Add("unknown", "no")
+
+	if e.Total() != 6 {
+		t.Errorf("got total %d, expected 6", e.Total())
+	}
+	if v := e.Accuracy(); v != 0.5 {
+		t.Errorf("got accuracy %v, expected 0.5", v)
+	}
+	m := e.Metrics()
+	if len(m) != 3 || m[2].Label != "unknown" {
+		t.Fatalf("got metrics %+v", m)
+	}
+	// yes: 2 of 3 predicted yes were correct, 2 of 3 expected yes were found.
+	if math.Abs(m[0].Precision-2.0/3) > 1e-9 || math.Abs(m[0].Recall-2.0/3) > 1e-9 || math.Abs(m[0].F1-2.0/3) > 1e-9 || m[0].Support != 3 {
+		t.Errorf("got yes metrics %+v", m[0])
+	}
+	// no: 1 of 2 predicted no correct, 1 of 3 expected no found.
+	if m[1].Precision != 0.5 || math.Abs(m[1].Recall-1.0/3) > 1e-9 {
+		t.Errorf("got no metrics %+v", m[1])
+	}
+	cm := e.ConfusionMatrix()
+	if cm[0][0] != 2 || cm[0][1] != 1 || cm[1][0] != 1 || cm[1][2] != 1 {
+		t.Errorf("got confusion matrix %v", cm)
+	}
+}
@@ -0,0 +1,118 @@
+package edgeimpulse
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+)
+
+// Float32Runner is a Runner that also classifies float32 features, e.g. for
+// images and audio that fit in a float32. Model processes receive the shorter
+// JSON representation of float32 values, without conversion to float64.
+type Float32Runner interface {
+	Runner
+	Classify32(data []float32) (RunnerClassifyResponse, error) // Must not retain data after returning.
+}
+
+// Classify32 classifies data with the Classify32 method of runner if it is a
+// Float32Runner, and otherwise with Classify, converting data to float64.
+func Classify32(runner Runner, data []float32) (RunnerClassifyResponse, error) {
+	if r, ok := runner.(Float32Runner); ok {
+		return r.Classify32(data)
+	}
+	data64 := make([]float64, len(data))
+	for i, v := range data {
+		data64[i] = float64(v)
+	}
+	return runner.Classify(data64)
+}
+
+// Ensure the runners of this package implement Float32Runner.
+var (
+	_ Float32Runner = (*RunnerProcess)(nil)
+	_ Float32Runner = (*RunnerPool)(nil)
+	_ Float32Runner = (*RestartRunner)(nil)
+)
+
+// runnerClassify32Request is a request to the model to classify float32 data,
+// encoded by writeJSON instead of package encoding/json.
+type runnerClassify32Request struct {
+	ID       int64     `json:"id"`
+	Classify []float32 `json:"classify"`
+	Debug    bool      `json:"debug,omitempty"`
+}
+
+// jsonWriter is a request that encodes itself as JSON, followed by a newline.
+type jsonWriter interface {
+	writeJSON(buf *bytes.Buffer) error
+}
+
+func (r runnerClassify32Request) writeJSON(buf *bytes.Buffer) error {
+	var scratch [32]byte
+	buf.WriteString(`{"id":`)
+	buf.Write(strconv.AppendInt(scratch[:0], r.ID, 10))
+	buf.WriteString(`,"classify":[`)
+	for i, v := range r.Classify {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("unsupported value %v at index %d", v, i)
+		}
+		// Like package encoding/json, without exponent for the common
+		// range, e.g. for packed pixels.
+		format := byte('f')
+		if abs := math.Abs(float64(v)); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
+		buf.Write(strconv.AppendFloat(scratch[:0], float64(v), format, -1, 32))
+	}
+	buf.WriteString("]")
+	if r.Debug {
+		buf.WriteString(`,"debug":true`)
+	}
+	buf.WriteString("}\n")
+	return nil
+}
+
+// Classify32 is like Classify, for float32 features.
+func (r *RunnerProcess) Classify32(data []float32) (RunnerClassifyResponse, error) {
+	req := runnerClassify32Request{
+		ID:       r.nextID(),
+		Classify: data,
+		Debug:    r.state().debug,
+	}
+	return r.classify(req.ID, req)
+}
+
+// Classify32 is like Classify, for float32 features.
+func (p *RunnerPool) Classify32(data []float32) (RunnerClassifyResponse, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.closed {
+		return RunnerClassifyResponse{}, errRunnerClosed
+	}
+	r := <-p.idle
+	defer func() {
+		p.idle <- r
+	}()
+	return r.Classify32(data)
+}
+
+// Classify32 is like Classify, for float32 features, with Classify32 of the
+// current runner if it supports it.
+func (r *RestartRunner) Classify32(data []float32) (RunnerClassifyResponse, error) {
+	r.restart()
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.closed {
+		return RunnerClassifyResponse{}, errRunnerClosed
+	}
+	atomic.AddInt64(&r.count, 1)
+	t0 := r.clock.Now()
+	resp, err := Classify32(r.runner, data)
+	r.record(resp, err, t0)
+	return resp, err
+}
@@ -0,0 +1,42 @@
+package edgeimpulse
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestClassify32Request(t *testing.T) {
+	req := runnerClassify32Request{ID: 7, Classify: []float32{0, 1.5, -2, 16777215, 0.1, 1e-9}}
+	var buf bytes.Buffer
+	if err := req.writeJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "{\"id\":7,\"classify\":[0,1.5,-2,16777215,0.1,1e-09]}\n" {
+		t.Errorf("got %q", s)
+	}
+	var got runnerClassify32Request
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, req) {
+		t.Errorf("got %+v, expected %+v", got, req)
+	}
+
+	req.Debug = true
+	buf.Reset()
+	if err := req.writeJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got = runnerClassify32Request{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || !reflect.DeepEqual(got, req) {
+		t.Errorf("got %+v, %v, expected %+v", got, err, req)
+	}
+
+	req.Classify[1] = float32(math.NaN())
+	if err := req.writeJSON(&buf); err == nil {
+		t.Errorf("got no error for NaN")
+	}
+}
@@ -0,0 +1,159 @@
+// Package frame implements sources for sensors that produce a frame of values
+// at a time, but are not cameras, like 60GHz radar chips that produce
+// range-doppler maps, or time-of-flight sensors like the VL53L5CX with an 8x8
+// array of distances.
+//
+// Each frame is flattened into a single sample, with one axis per value, in
+// row-major order. Use Recorder to turn a Source into a timeseries.Recorder,
+// for classifying with timeseries.NewClassifier, which concatenates as many
+// frames as the model has input features, or for collecting training data.
+package frame
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// Frame is a frame of values from a sensor.
+type Frame struct {
+	Time   time.Time
+	Values []float64 // Row-major, with as many values as the product of the shape.
+}
+
+// Event is a frame, or an error. After an error, no more events are sent.
+type Event struct {
+	Err   error
+	Frame Frame
+}
+
+// Source produces frames.
+type Source interface {
+	// Shape returns the dimensions of frames, e.g. [8 8] for an 8x8 array
+	// of zones, or [chirps samples] for a radar.
+	Shape() []int
+
+	// Units returns the units of values, e.g. mm, or empty if unknown.
+	Units() string
+
+	// Frequency returns the number of frames per second.
+	Frequency() float64
+
+	// Events returns the channel on which frames are sent.
+	Events() chan Event
+
+	Close() error
+}
+
+// Size returns the number of values in a frame of shape.
+func Size(shape []int) int {
+	if len(shape) == 0 {
+		return 0
+	}
+	n := 1
+	for _, d := range shape {
+		n *= d
+	}
+	return n
+}
+
+// ParseShape parses a shape like "8x8" or "64".
+func ParseShape(s string) ([]int, error) {
+	var shape []int
+	for _, t := range strings.Split(s, "x") {
+		d, err := strconv.Atoi(t)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("bad dimension %q in shape %q", t, s)
+		}
+		shape = append(shape, d)
+	}
+	return shape, nil
+}
+
+// Axes returns an axis for each value in a frame of shape, named after the
+// indices of the value, e.g. "z3_4" for the value in row 3 and column 4, with
+// prefix "z".
+func Axes(prefix string, shape []int, units string) []timeseries.Axis {
+	axes := make([]timeseries.Axis, Size(shape))
+	index := make([]int, len(shape))
+	for i := range axes {
+		t := make([]string, len(index))
+		for j, v := range index {
+			t[j] = strconv.Itoa(v)
+		}
+		axes[i] = timeseries.Axis{Name: prefix + strings.Join(t, "_"), Units: units}
+		// Increment the index, last dimension fastest.
+		for j := len(index) - 1; j >= 0; j-- {
+			index[j]++
+			if index[j] < shape[j] {
+				break
+			}
+			index[j] = 0
+		}
+	}
+	return axes
+}
+
+// recorder is a Source as timeseries.Recorder.
+type recorder struct {
+	src    Source
+	axes   []timeseries.Axis
+	events chan timeseries.Event
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Recorder returns a recorder that sends each frame of src as sample, with axes
+// named by Axes with prefix "v". Closing the recorder closes src.
+func Recorder(ctx context.Context, src Source) timeseries.Recorder {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &recorder{
+		src:    src,
+		axes:   Axes("v", src.Shape(), src.Units()),
+		events: make(chan timeseries.Event, cap(src.Events())),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		for {
+			var ev Event
+			select {
+			case <-ctx.Done():
+				return
+			case ev = <-src.Events():
+			}
+			select {
+			case r.events <- timeseries.Event{Err: ev.Err, Sample: timeseries.Sample{Time: ev.Frame.Time, Values: ev.Frame.Values}}:
+			case <-ctx.Done():
+				return
+			}
+			if ev.Err != nil {
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *recorder) Axes() []timeseries.Axis {
+	return r.axes
+}
+
+func (r *recorder) Frequency() float64 {
+	return r.src.Frequency()
+}
+
+func (r *recorder) Events() chan timeseries.Event {
+	return r.events
+}
+
+func (r *recorder) Close() error {
+	r.cancel()
+	<-r.done
+	return r.src.Close()
+}
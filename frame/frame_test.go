@@ -0,0 +1,65 @@
+package frame
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAxes(t *testing.T) {
+	var names []string
+	for _, a := range Axes("z", []int{2, 3}, "mm") {
+		names = append(names, a.Name)
+	}
+	want := []string{"z0_0", "z0_1", "z0_2", "z1_0", "z1_1", "z1_2"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("got %v, expected %v", names, want)
+	}
+
+	if shape, err := ParseShape("8x8"); err != nil || !reflect.DeepEqual(shape, []int{8, 8}) {
+		t.Fatalf("parse shape: got %v, %v", shape, err)
+	}
+	if _, err := ParseShape("8x"); err == nil {
+		t.Fatalf("parse shape: bad shape accepted")
+	}
+}
+
+func TestStream(t *testing.T) {
+	var buf bytes.Buffer
+	for _, v := range []int16{1, -2, 3, 4, 5, -6} {
+		binary.Write(&buf, binary.LittleEndian, v)
+	}
+	text := "booting\n1,2\t3 4\n5,6,7\n"
+
+	test := func(name string, r io.Reader, opts StreamOpts, want [][]float64) {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		s, err := newStream(ctx, cancel, io.NopCloser(r), nil, opts)
+		if err != nil {
+			t.Fatalf("%s: new stream: %v", name, err)
+		}
+		defer s.Close()
+		rec := Recorder(ctx, s)
+		if n := len(rec.Axes()); n != Size(opts.Shape) {
+			t.Fatalf("%s: got %d axes, expected %d", name, n, Size(opts.Shape))
+		}
+		for _, w := range want {
+			ev := <-rec.Events()
+			if ev.Err != nil {
+				t.Fatalf("%s: got error %v", name, ev.Err)
+			}
+			if !reflect.DeepEqual(ev.Sample.Values, w) {
+				t.Fatalf("%s: got %v, expected %v", name, ev.Sample.Values, w)
+			}
+		}
+		if ev := <-rec.Events(); ev.Err == nil {
+			t.Fatalf("%s: expected error at end of stream", name)
+		}
+	}
+	test("binary", &buf, StreamOpts{Shape: []int{3}, Encoding: EncodingInt16, Scale: 2, Frequency: 10}, [][]float64{{2, -4, 6}, {8, 10, -12}})
+	test("text", strings.NewReader(text), StreamOpts{Shape: []int{2, 2}, Frequency: 10}, [][]float64{{1, 2, 3, 4}})
+}
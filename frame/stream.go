@@ -0,0 +1,403 @@
+package frame
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+)
+
+// Encoding is the encoding of frames in a stream.
+type Encoding string
+
+// Encodings of frames. Binary encodings are little endian, without separators
+// between frames.
+const (
+	EncodingInt16   Encoding = "int16"
+	EncodingUint16  Encoding = "uint16"
+	EncodingFloat32 Encoding = "float32"
+	EncodingText    Encoding = "text" // A line per frame, values separated by commas, tabs or spaces.
+)
+
+// size returns the number of bytes of a value, or 0 for text.
+func (e Encoding) size() int {
+	switch e {
+	case EncodingInt16, EncodingUint16:
+		return 2
+	case EncodingFloat32:
+		return 4
+	}
+	return 0
+}
+
+// StreamOpts are options for a Stream.
+type StreamOpts struct {
+	// Dimensions of frames, e.g. [8 8]. Required.
+	Shape []int
+
+	// Encoding of frames. Defaults to EncodingText.
+	Encoding Encoding
+
+	// Multiplied with raw values, e.g. to convert to physical units. If 0,
+	// values are not scaled.
+	Scale float64
+
+	// Units of values after scaling, e.g. mm.
+	Units string
+
+	// Frames per second produced by the sensor. Required.
+	Frequency float64
+
+	// File to read frames from, e.g. a character device of a driver, or a
+	// named pipe. Ignored if Command is set.
+	Path string
+
+	// Command, with arguments, that writes frames to its stdout, e.g. a
+	// vendor tool that configures the sensor over SPI or I2C.
+	Command []string
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For the time of frames. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a stream created with NewStream. A *StreamOpts is also an
+// Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *StreamOpts)
+}
+
+type optionFunc func(o *StreamOpts)
+
+func (fn optionFunc) apply(o *StreamOpts) {
+	fn(o)
+}
+
+func (opts *StreamOpts) apply(o *StreamOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithShape sets StreamOpts.Shape.
+func WithShape(shape ...int) Option {
+	return optionFunc(func(o *StreamOpts) { o.Shape = shape })
+}
+
+// WithEncoding sets StreamOpts.Encoding.
+func WithEncoding(e Encoding) Option {
+	return optionFunc(func(o *StreamOpts) { o.Encoding = e })
+}
+
+// WithScale sets StreamOpts.Scale and StreamOpts.Units.
+func WithScale(scale float64, units string) Option {
+	return optionFunc(func(o *StreamOpts) {
+		o.Scale = scale
+		o.Units = units
+	})
+}
+
+// WithFrequency sets StreamOpts.Frequency.
+func WithFrequency(frequency float64) Option {
+	return optionFunc(func(o *StreamOpts) { o.Frequency = frequency })
+}
+
+// WithPath sets StreamOpts.Path.
+func WithPath(path string) Option {
+	return optionFunc(func(o *StreamOpts) { o.Path = path })
+}
+
+// WithCommand sets StreamOpts.Command.
+func WithCommand(args ...string) Option {
+	return optionFunc(func(o *StreamOpts) { o.Command = args })
+}
+
+// WithVerbose sets StreamOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *StreamOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets StreamOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *StreamOpts) { o.Logger = logger })
+}
+
+// WithClock sets StreamOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *StreamOpts) { o.Clock = clock })
+}
+
+// Stream reads frames from a file or the output of a command.
+type Stream struct {
+	opts   StreamOpts
+	logger edgeimpulse.Logger
+	clock  edgeimpulse.Clock
+	rc     io.ReadCloser
+	cmd    *exec.Cmd
+	events chan Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Ensure that Stream implements the Source interface.
+var _ Source = (*Stream)(nil)
+
+// NewStream opens the file or starts the command, and starts reading frames.
+//
+// Callers must call Close to clean up. Canceling ctx also stops the stream.
+func NewStream(ctx context.Context, opts ...Option) (*Stream, error) {
+	var xopts StreamOpts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
+	}
+	if len(xopts.Command) == 0 && xopts.Path == "" {
+		return nil, fmt.Errorf("no path or command to read frames from")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var rc io.ReadCloser
+	var cmd *exec.Cmd
+	if len(xopts.Command) > 0 {
+		cmd = exec.CommandContext(ctx, xopts.Command[0], xopts.Command[1:]...)
+		if xopts.Verbose {
+			cmd.Stderr = os.Stderr
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("stdout pipe for %s: %w", xopts.Command[0], err)
+		}
+		if err := cmd.Start(); err != nil {
+			cancel()
+			return nil, fmt.Errorf("starting command %s: %w", xopts.Command[0], err)
+		}
+		rc = stdout
+	} else {
+		f, err := os.Open(xopts.Path)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		rc = f
+	}
+	s, err := newStream(ctx, cancel, rc, cmd, xopts)
+	if err != nil {
+		cancel()
+		rc.Close()
+		if cmd != nil {
+			cmd.Wait()
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// newStream starts reading frames from rc. Cmd, if not nil, is waited for
+// after reading stops.
+func newStream(ctx context.Context, cancel context.CancelFunc, rc io.ReadCloser, cmd *exec.Cmd, opts StreamOpts) (*Stream, error) {
+	if Size(opts.Shape) <= 0 {
+		return nil, fmt.Errorf("shape required")
+	}
+	if opts.Frequency <= 0 {
+		return nil, fmt.Errorf("frequency must be > 0")
+	}
+	if opts.Encoding == "" {
+		opts.Encoding = EncodingText
+	}
+	if opts.Encoding != EncodingText && opts.Encoding.size() == 0 {
+		return nil, fmt.Errorf("unknown encoding %q", opts.Encoding)
+	}
+	s := &Stream{
+		opts:   opts,
+		logger: edgeimpulse.DefaultLogger(opts.Logger, opts.Verbose),
+		clock:  edgeimpulse.DefaultClock(opts.Clock),
+		rc:     rc,
+		cmd:    cmd,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		// Room for a second of frames, so a consumer that is briefly
+		// busy does not cause gaps.
+		events: make(chan Event, int(opts.Frequency)+1),
+	}
+
+	go func() {
+		<-ctx.Done()
+		// Unblocks the reading goroutine.
+		s.rc.Close()
+	}()
+
+	go func() {
+		defer close(s.done)
+		if s.cmd != nil {
+			defer s.cmd.Wait()
+		}
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				s.mutex.Lock()
+				s.err = err
+				s.mutex.Unlock()
+				s.sendErr(ctx, err)
+			}
+		}()
+
+		var read func() ([]float64, error)
+		if s.opts.Encoding == EncodingText {
+			read = s.readText(bufio.NewScanner(s.rc))
+		} else {
+			read = s.readBinary(bufio.NewReader(s.rc))
+		}
+		for {
+			values, err := read()
+			if err != nil {
+				if ctx.Err() == nil {
+					s.sendErr(ctx, fmt.Errorf("reading frames: %w", err))
+				}
+				return
+			}
+			if s.opts.Scale != 0 {
+				for i := range values {
+					values[i] *= s.opts.Scale
+				}
+			}
+			metrics.FramesCaptured.Inc()
+			select {
+			case s.events <- Event{Frame: Frame{Time: s.clock.Now(), Values: values}}:
+			default:
+				metrics.FramesDropped.Inc()
+				s.logger.Logf(edgeimpulse.LogDebug, "dropping frame, consumer still busy")
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+// readBinary returns a function reading a frame of binary values from r.
+func (s *Stream) readBinary(r io.Reader) func() ([]float64, error) {
+	n := Size(s.opts.Shape)
+	size := s.opts.Encoding.size()
+	buf := make([]byte, n*size)
+	return func() ([]float64, error) {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return decode(buf, s.opts.Encoding, n), nil
+	}
+}
+
+// decode returns n values of encoding e from buf.
+func decode(buf []byte, e Encoding, n int) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		switch e {
+		case EncodingInt16:
+			values[i] = float64(int16(binary.LittleEndian.Uint16(buf[2*i:])))
+		case EncodingUint16:
+			values[i] = float64(binary.LittleEndian.Uint16(buf[2*i:]))
+		case EncodingFloat32:
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+		}
+	}
+	return values
+}
+
+// readText returns a function reading a frame from a line of text, skipping
+// lines without the right number of values.
+func (s *Stream) readText(scanner *bufio.Scanner) func() ([]float64, error) {
+	n := Size(s.opts.Shape)
+	// Frames of radars can be long lines.
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return func() ([]float64, error) {
+		for scanner.Scan() {
+			values, err := parseLine(scanner.Text())
+			if err != nil || len(values) != n {
+				s.logger.Logf(edgeimpulse.LogDebug, "skipping line with %d values, expected %d", len(values), n)
+				continue
+			}
+			return values, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+}
+
+// parseLine parses values separated by commas, tabs or spaces.
+func parseLine(line string) ([]float64, error) {
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == '\t' || r == ' '
+	})
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing value %q: %w", f, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (s *Stream) sendErr(ctx context.Context, err error) {
+	select {
+	case s.events <- Event{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// Shape returns the dimensions of frames.
+func (s *Stream) Shape() []int {
+	return s.opts.Shape
+}
+
+// Units returns the units of values.
+func (s *Stream) Units() string {
+	return s.opts.Units
+}
+
+// Frequency returns the configured number of frames per second.
+func (s *Stream) Frequency() float64 {
+	return s.opts.Frequency
+}
+
+// Events returns the channel on which frames are sent.
+func (s *Stream) Events() chan Event {
+	return s.events
+}
+
+// Close stops reading, and stops the command.
+func (s *Stream) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Err returns the error that stopped the stream after recovering from a
+// panic, or nil.
+func (s *Stream) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
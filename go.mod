@@ -1,6 +1,6 @@
-module github.com/edgeimpulse/linux-sdk-go
+module github.com/edgeimpulse/linux-sdk-go/v2
 
-go 1.15
+go 1.16
 
 require (
 	github.com/disintegration/imaging v1.6.2
@@ -0,0 +1,235 @@
+// Package gpio implements driving GPIO output lines, e.g. to switch on a
+// light, buzzer or relay when a model detects something, and watching input
+// lines, e.g. for a push button.
+package gpio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errGpiodInstallHint = fmt.Errorf("gpioset %w, install with: sudo apt install -y gpiod", exec.ErrNotFound)
+
+// Line is a GPIO line configured for output.
+type Line interface {
+	// Set drives the line high or low.
+	Set(high bool) error
+
+	// Close releases the line.
+	Close() error
+}
+
+// SysfsLine is an output line controlled through /sys/class/gpio.
+type SysfsLine struct {
+	pin      int
+	exported bool // Whether we exported the pin, and should unexport it on close.
+}
+
+// Ensure SysfsLine implements interface Line.
+var _ Line = (*SysfsLine)(nil)
+
+// SysfsRoot is the directory of the sysfs GPIO interface.
+var SysfsRoot = "/sys/class/gpio"
+
+// OpenSysfs exports pin through the sysfs GPIO interface if needed, and
+// configures it as output, initially low.
+//
+// Callers must call Close to unexport the pin again.
+func OpenSysfs(pin int) (*SysfsLine, error) {
+	l := &SysfsLine{pin: pin}
+	dir := fmt.Sprintf("%s/gpio%d", SysfsRoot, pin)
+	if _, err := os.Stat(dir); err != nil {
+		if err := os.WriteFile(SysfsRoot+"/export", []byte(fmt.Sprintf("%d", pin)), 0644); err != nil {
+			return nil, fmt.Errorf("exporting gpio pin %d: %w", pin, err)
+		}
+		l.exported = true
+	}
+
+	// The direction file may not be writable directly after exporting,
+	// until udev has fixed up permissions. So retry for a while.
+	var err error
+	for i := 0; i < 20; i++ {
+		err = os.WriteFile(dir+"/direction", []byte("low"), 0644)
+		if err == nil {
+			return l, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	l.Close()
+	return nil, fmt.Errorf("setting direction for gpio pin %d: %w", pin, err)
+}
+
+// Set drives the line high or low.
+func (l *SysfsLine) Set(high bool) error {
+	v := "0"
+	if high {
+		v = "1"
+	}
+	if err := os.WriteFile(fmt.Sprintf("%s/gpio%d/value", SysfsRoot, l.pin), []byte(v), 0644); err != nil {
+		return fmt.Errorf("setting gpio pin %d: %w", l.pin, err)
+	}
+	return nil
+}
+
+// Close drives the line low, and unexports the pin if it was exported by
+// OpenSysfs.
+func (l *SysfsLine) Close() error {
+	err := l.Set(false)
+	if l.exported {
+		l.exported = false
+		if xerr := os.WriteFile(SysfsRoot+"/unexport", []byte(fmt.Sprintf("%d", l.pin)), 0644); xerr != nil && err == nil {
+			err = fmt.Errorf("unexporting gpio pin %d: %w", l.pin, xerr)
+		}
+	}
+	return err
+}
+
+// GpiodLine is an output line controlled with the gpioset command from the
+// libgpiod tools, for systems without the deprecated sysfs interface.
+type GpiodLine struct {
+	chip string
+	line int
+
+	mutex  sync.Mutex
+	cancel context.CancelFunc // For stopping the running gpioset, if any.
+}
+
+// Ensure GpiodLine implements interface Line.
+var _ Line = (*GpiodLine)(nil)
+
+// OpenGpiod returns a line on chip (e.g. "gpiochip0") with offset line, and
+// drives it low.
+//
+// Callers must call Close to release the line.
+func OpenGpiod(chip string, line int) (*GpiodLine, error) {
+	l := &GpiodLine{chip: chip, line: line}
+	if err := l.Set(false); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Set drives the line high or low. A gpioset process is kept running to hold
+// the value, it is replaced on the next call to Set.
+func (l *GpiodLine) Set(high bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+	}
+
+	v := 0
+	if high {
+		v = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, "gpioset", "--mode=signal", l.chip, fmt.Sprintf("%d=%d", l.line, v))
+	if err := cmd.Start(); err != nil {
+		cancel()
+		if errors.Is(err, exec.ErrNotFound) {
+			err = errGpiodInstallHint
+		}
+		return fmt.Errorf("starting gpioset for %s line %d: %w", l.chip, l.line, err)
+	}
+	go cmd.Wait()
+	l.cancel = cancel
+	return nil
+}
+
+// Close stops the gpioset process holding the line, releasing it.
+func (l *GpiodLine) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+	}
+	return nil
+}
+
+// Open opens a line described by spec, either a sysfs pin number like "17",
+// or a gpiod chip and line offset like "gpiochip0:17".
+func Open(spec string) (Line, error) {
+	t := strings.Split(spec, ":")
+	var pin int
+	if _, err := fmt.Sscanf(t[len(t)-1], "%d", &pin); err != nil || len(t) > 2 {
+		return nil, fmt.Errorf("bad gpio line %q, need pin like 17 or chip and line like gpiochip0:17", spec)
+	}
+	if len(t) == 2 {
+		return OpenGpiod(t[0], pin)
+	}
+	return OpenSysfs(pin)
+}
+
+// Trigger drives a line high for a period of time after Fire is called. Firing
+// again while the line is high extends the period.
+type Trigger struct {
+	line     Line
+	duration time.Duration
+
+	mutex sync.Mutex
+	until time.Time   // When to drive the line low again.
+	timer *time.Timer // Non-nil while line is high.
+}
+
+// NewTrigger returns a new trigger for line, keeping the line high for
+// duration after each Fire.
+func NewTrigger(line Line, duration time.Duration) *Trigger {
+	return &Trigger{line: line, duration: duration}
+}
+
+// Fire drives the line high, and schedules it to be driven low after the
+// duration of the trigger.
+func (t *Trigger) Fire() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.until = time.Now().Add(t.duration)
+	if t.timer != nil {
+		return nil
+	}
+	if err := t.line.Set(true); err != nil {
+		return err
+	}
+	t.timer = time.AfterFunc(t.duration, t.expire)
+	return nil
+}
+
+func (t *Trigger) expire() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.timer == nil {
+		// Closed in the mean time.
+		return
+	}
+	if d := time.Until(t.until); d > 0 {
+		// Fired again while high.
+		t.timer = time.AfterFunc(d, t.expire)
+		return
+	}
+	t.timer = nil
+	t.line.Set(false)
+}
+
+// Close stops a pending timer and drives the line low. Close does not close
+// the line.
+func (t *Trigger) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	return t.line.Set(false)
+}
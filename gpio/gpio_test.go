@@ -0,0 +1,91 @@
+package gpio
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLine struct {
+	mutex sync.Mutex
+	high  bool
+}
+
+func (l *fakeLine) Set(high bool) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.high = high
+	return nil
+}
+
+func (l *fakeLine) Close() error {
+	return nil
+}
+
+func (l *fakeLine) get() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.high
+}
+
+func TestTrigger(t *testing.T) {
+	l := &fakeLine{}
+	tr := NewTrigger(l, 50*time.Millisecond)
+	if err := tr.Fire(); err != nil {
+		t.Fatalf("fire: %v", err)
+	}
+	if !l.get() {
+		t.Fatalf("line not high after fire")
+	}
+	time.Sleep(30 * time.Millisecond)
+	tr.Fire()
+	time.Sleep(30 * time.Millisecond)
+	if !l.get() {
+		t.Fatalf("line not high after extending")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if l.get() {
+		t.Fatalf("line still high after duration")
+	}
+
+	tr.Fire()
+	tr.Close()
+	if l.get() {
+		t.Fatalf("line still high after close")
+	}
+}
+
+func TestSysfs(t *testing.T) {
+	dir, err := os.MkdirTemp("", "gpiotest")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(s string) {
+		SysfsRoot = s
+	}(SysfsRoot)
+	SysfsRoot = dir
+
+	// Simulate an already exported pin.
+	if err := os.Mkdir(dir+"/gpio17", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	l, err := OpenSysfs(17)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := l.Set(true); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	buf, err := os.ReadFile(dir + "/gpio17/value")
+	if err != nil || string(buf) != "1" {
+		t.Fatalf("value after set, got %q, %v, expected 1", buf, err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := os.Stat(dir + "/unexport"); err == nil {
+		t.Fatalf("unexported pin that was not exported by us")
+	}
+}
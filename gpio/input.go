@@ -0,0 +1,116 @@
+package gpio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Rising returns a channel that receives a value for each rising edge of the
+// input line described by spec, as for Open, e.g. a push button. Values are
+// dropped while the receiver is not ready. The line is released and the
+// channel closed when ctx is done, or reading the line fails.
+//
+// Sysfs lines are polled, gpiod lines are monitored with gpiomon.
+func Rising(ctx context.Context, spec string) (<-chan struct{}, error) {
+	t := strings.Split(spec, ":")
+	var pin int
+	if _, err := fmt.Sscanf(t[len(t)-1], "%d", &pin); err != nil || len(t) > 2 {
+		return nil, fmt.Errorf("bad gpio line %q, need pin like 17 or chip and line like gpiochip0:17", spec)
+	}
+	c := make(chan struct{}, 1)
+	send := func() {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+	if len(t) == 2 {
+		return c, risingGpiod(ctx, t[0], pin, c, send)
+	}
+	return c, risingSysfs(ctx, pin, c, send)
+}
+
+func risingSysfs(ctx context.Context, pin int, c chan struct{}, send func()) error {
+	dir := fmt.Sprintf("%s/gpio%d", SysfsRoot, pin)
+	exported := false
+	if _, err := os.Stat(dir); err != nil {
+		if err := os.WriteFile(SysfsRoot+"/export", []byte(fmt.Sprintf("%d", pin)), 0644); err != nil {
+			return fmt.Errorf("exporting gpio pin %d: %w", pin, err)
+		}
+		exported = true
+	}
+	unexport := func() {
+		if exported {
+			os.WriteFile(SysfsRoot+"/unexport", []byte(fmt.Sprintf("%d", pin)), 0644)
+		}
+	}
+
+	// As in OpenSysfs, wait for udev to fix up permissions.
+	var err error
+	for i := 0; i < 20; i++ {
+		err = os.WriteFile(dir+"/direction", []byte("in"), 0644)
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		unexport()
+		return fmt.Errorf("setting direction for gpio pin %d: %w", pin, err)
+	}
+
+	go func() {
+		defer close(c)
+		defer unexport()
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		high := true // Not an edge if the line is high initially.
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			buf, err := os.ReadFile(dir + "/value")
+			if err != nil {
+				return
+			}
+			v := strings.TrimSpace(string(buf)) == "1"
+			if v && !high {
+				send()
+			}
+			high = v
+		}
+	}()
+	return nil
+}
+
+func risingGpiod(ctx context.Context, chip string, line int, c chan struct{}, send func()) error {
+	cmd := exec.CommandContext(ctx, "gpiomon", "--rising-edge", chip, fmt.Sprintf("%d", line))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("gpiomon stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			err = fmt.Errorf("gpiomon %w, install with: sudo apt install -y gpiod", exec.ErrNotFound)
+		}
+		return fmt.Errorf("starting gpiomon for %s line %d: %w", chip, line, err)
+	}
+	go func() {
+		defer close(c)
+		defer cmd.Wait()
+		// Each line printed is an event.
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			send()
+		}
+	}()
+	return nil
+}
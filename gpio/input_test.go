@@ -0,0 +1,43 @@
+package gpio
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRisingSysfs(t *testing.T) {
+	root := SysfsRoot
+	defer func() { SysfsRoot = root }()
+	SysfsRoot = t.TempDir()
+	dir := SysfsRoot + "/gpio5"
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	value := func(v string) {
+		if err := os.WriteFile(dir+"/value", []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	value("0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	edges, err := Rising(ctx, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	value("1")
+	select {
+	case <-edges:
+	case <-time.After(time.Second):
+		t.Fatalf("no edge")
+	}
+	cancel()
+	for range edges {
+	}
+	if buf, _ := os.ReadFile(dir + "/direction"); string(buf) != "in" {
+		t.Fatalf("direction %q, expected in", buf)
+	}
+}
@@ -0,0 +1,153 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Command is a named sequence of keywords, e.g. "lights_on" for "hey_device"
+// followed by "lights_on".
+type Command struct {
+	Name     string
+	Keywords []string
+
+	// All keywords must be heard within Window of the first. If zero, the
+	// window of the grammar is used.
+	Window time.Duration
+}
+
+// CommandEvent is a command recognized by a Grammar.
+type CommandEvent struct {
+	Command    string
+	Start, End time.Time // Of the first and last keyword.
+}
+
+// Grammar recognizes commands from keyword detections, bridging the labels of
+// a keyword spotting model and voice control. Each command is a state machine
+// advancing on its next keyword, and starting over on another keyword of the
+// grammar or when its window expires. Labels not used in any command, like
+// noise, are ignored. When a command is recognized, all commands start over,
+// so a keyword completes at most one command; commands that are a prefix of
+// another command win.
+type Grammar struct {
+	commands []Command
+	keywords map[string]bool
+	progress []commandProgress
+}
+
+type commandProgress struct {
+	next  int // Index of the next expected keyword.
+	start time.Time
+}
+
+// NewGrammar returns a grammar for commands, with window as default time to
+// complete a command.
+func NewGrammar(window time.Duration, commands ...Command) (*Grammar, error) {
+	g := &Grammar{keywords: map[string]bool{}, progress: make([]commandProgress, len(commands))}
+	for _, c := range commands {
+		if c.Name == "" {
+			return nil, fmt.Errorf("command without name")
+		}
+		if len(c.Keywords) == 0 {
+			return nil, fmt.Errorf("command %q without keywords", c.Name)
+		}
+		if c.Window == 0 {
+			c.Window = window
+		}
+		if c.Window <= 0 && len(c.Keywords) > 1 {
+			return nil, fmt.Errorf("command %q needs a window > 0", c.Name)
+		}
+		for _, k := range c.Keywords {
+			g.keywords[k] = true
+		}
+		g.commands = append(g.commands, c)
+	}
+	return g, nil
+}
+
+// ParseCommands parses commands like "lights_on=hey_device+lights_on", separated
+// by commas. Commands of a single keyword, like "stop", are named after their
+// keyword.
+func ParseCommands(spec string) ([]Command, error) {
+	var l []Command
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		name := s
+		seq := s
+		if i := strings.Index(s, "="); i >= 0 {
+			name, seq = s[:i], s[i+1:]
+		}
+		c := Command{Name: name}
+		for _, k := range strings.Split(seq, "+") {
+			if k == "" {
+				return nil, fmt.Errorf("empty keyword in command %q", s)
+			}
+			c.Keywords = append(c.Keywords, k)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("command %q without name", s)
+		}
+		l = append(l, c)
+	}
+	if len(l) == 0 {
+		return nil, fmt.Errorf("no commands in %q", spec)
+	}
+	return l, nil
+}
+
+// Keyword processes a keyword heard at time t, returning the recognized
+// command, if any.
+func (g *Grammar) Keyword(label string, t time.Time) []CommandEvent {
+	if !g.keywords[label] {
+		return nil
+	}
+	for i, c := range g.commands {
+		p := &g.progress[i]
+		if p.next > 0 && t.Sub(p.start) > c.Window {
+			p.next = 0
+		}
+		switch {
+		case c.Keywords[p.next] == label:
+			if p.next == 0 {
+				p.start = t
+			}
+			p.next++
+		case p.next > 0 && c.Keywords[p.next-1] == label:
+			// Repeated detection of the previous keyword.
+		case c.Keywords[0] == label:
+			p.next = 1
+			p.start = t
+		default:
+			p.next = 0
+		}
+		if p.next == len(c.Keywords) {
+			ev := CommandEvent{c.Name, p.start, t}
+			g.Reset()
+			return []CommandEvent{ev}
+		}
+	}
+	return nil
+}
+
+// Update processes the events of a Detector at time t, treating each
+// activated label as a keyword.
+func (g *Grammar) Update(events []DetectorEvent, t time.Time) []CommandEvent {
+	var l []CommandEvent
+	for _, ev := range events {
+		if ev.Type == DetectorActivated {
+			l = append(l, g.Keyword(ev.Label, t)...)
+		}
+	}
+	return l
+}
+
+// Reset makes all commands start over.
+func (g *Grammar) Reset() {
+	for i := range g.progress {
+		g.progress[i] = commandProgress{}
+	}
+}
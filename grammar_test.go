@@ -0,0 +1,73 @@
+package edgeimpulse_test
+
+import (
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestGrammar(t *testing.T) {
+	commands, err := edgeimpulse.ParseCommands("on=hey+lights+on, off=hey+lights+off, stop")
+	if err != nil {
+		t.Fatalf("parse commands: %v", err)
+	}
+	g, err := edgeimpulse.NewGrammar(3*time.Second, commands...)
+	if err != nil {
+		t.Fatalf("new grammar: %v", err)
+	}
+	t0 := time.Unix(1000, 0)
+	at := func(s float64) time.Time {
+		return t0.Add(time.Duration(s * float64(time.Second)))
+	}
+
+	hear := func(s float64, labels ...string) string {
+		var got string
+		for _, l := range labels {
+			for _, ev := range g.Keyword(l, at(s)) {
+				got = ev.Command
+			}
+		}
+		return got
+	}
+
+	if c := hear(0, "hey", "noise", "hey", "lights", "lights", "off"); c != "off" {
+		t.Errorf("got %q, expected off", c)
+	}
+	if c := hear(1, "on"); c != "" {
+		t.Errorf("got %q after recognized command, expected none", c)
+	}
+	if c := hear(2, "stop"); c != "stop" {
+		t.Errorf("got %q, expected stop", c)
+	}
+
+	// Too slow.
+	g.Keyword("hey", at(10))
+	g.Keyword("lights", at(11))
+	if c := hear(14, "on"); c != "" {
+		t.Errorf("got %q after window, expected none", c)
+	}
+
+	// Another keyword breaks the sequence, hey starts over.
+	if c := hear(20, "hey", "on", "lights", "on"); c != "" {
+		t.Errorf("got %q for broken sequence, expected none", c)
+	}
+	if c := hear(21, "hey", "lights", "on"); c != "on" {
+		t.Errorf("got %q after starting over, expected on", c)
+	}
+
+	// Detector activations.
+	g.Keyword("hey", at(30))
+	g.Keyword("lights", at(30))
+	evs := g.Update([]edgeimpulse.DetectorEvent{
+		{Type: edgeimpulse.DetectorDeactivated, Label: "lights"},
+		{Type: edgeimpulse.DetectorActivated, Label: "on"},
+	}, at(31))
+	if len(evs) != 1 || evs[0].Command != "on" || !evs[0].Start.Equal(at(30)) {
+		t.Errorf("got %+v, expected command on started at 30s", evs)
+	}
+
+	if _, err := edgeimpulse.ParseCommands("x=a++b"); err == nil {
+		t.Errorf("expected error for empty keyword")
+	}
+}
@@ -0,0 +1,118 @@
+package edgeimpulse
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// Stage determines when a component of a Group is closed.
+type Stage int
+
+// Stages in the order in which Group.Close closes them.
+const (
+	StageCapture  Stage = iota // Recorders: stop capturing new input first.
+	StageClassify              // Classifiers: wait for classifications in progress.
+	StageOutput                // Sinks, triggers, upload queues: flush results.
+	StageModel                 // Runners: stop the model processes last.
+
+	numStages
+)
+
+// Group owns the components of a classification pipeline, and shuts them down
+// in order: first capture is stopped, then classifiers are drained, then
+// outputs are flushed, and finally the runners are closed. Within a stage,
+// components are closed in reverse order of adding, like deferred calls.
+//
+// A typical main creates a group, adds each component as it is created, and
+// defers Close.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mutex     sync.Mutex
+	stages    [numStages][]io.Closer
+	signals   chan os.Signal
+	signal    os.Signal
+	closeOnce sync.Once
+	err       error
+}
+
+// NewGroup returns a group with a context derived from ctx. The context is
+// canceled when Close has closed all components, or when one of signals is
+// received, e.g. os.Interrupt. Without signals, no signals are handled.
+func NewGroup(ctx context.Context, signals ...os.Signal) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group{ctx: ctx, cancel: cancel}
+	if len(signals) > 0 {
+		g.signals = make(chan os.Signal, 1)
+		signal.Notify(g.signals, signals...)
+		go func() {
+			select {
+			case sig := <-g.signals:
+				g.mutex.Lock()
+				g.signal = sig
+				g.mutex.Unlock()
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return g
+}
+
+// Context returns the context of the group, to pass to recorders and
+// classifiers. It is canceled at the end of Close or on a signal, so
+// classifications in progress are delivered while the stages are closed.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// Done is closed when the context of the group is canceled.
+func (g *Group) Done() <-chan struct{} {
+	return g.ctx.Done()
+}
+
+// Signal returns the signal that canceled the group, or nil.
+func (g *Group) Signal() os.Signal {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.signal
+}
+
+// Add registers c to be closed in stage.
+func (g *Group) Add(stage Stage, c io.Closer) {
+	if stage < 0 || stage >= numStages {
+		panic("edgeimpulse: bad stage")
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.stages[stage] = append(g.stages[stage], c)
+}
+
+// Close closes all components in order of their stages, and then cancels the
+// context of the group. Close returns the first error from closing a
+// component. Close can be called multiple times.
+func (g *Group) Close() error {
+	g.closeOnce.Do(func() {
+		if g.signals != nil {
+			signal.Stop(g.signals)
+		}
+
+		g.mutex.Lock()
+		stages := g.stages
+		g.mutex.Unlock()
+
+		for _, l := range stages {
+			for i := len(l) - 1; i >= 0; i-- {
+				if err := l[i].Close(); err != nil && g.err == nil {
+					g.err = err
+				}
+			}
+		}
+		g.cancel()
+	})
+	return g.err
+}
@@ -0,0 +1,54 @@
+package edgeimpulse_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+type closer func() error
+
+func (fn closer) Close() error {
+	return fn()
+}
+
+func TestGroup(t *testing.T) {
+	g := edgeimpulse.NewGroup(context.Background())
+
+	var closed []string
+	add := func(stage edgeimpulse.Stage, name string, err error) {
+		g.Add(stage, closer(func() error {
+			if g.Context().Err() != nil {
+				t.Errorf("context canceled before closing %s", name)
+			}
+			closed = append(closed, name)
+			return err
+		}))
+	}
+	add(edgeimpulse.StageModel, "runner", nil)
+	add(edgeimpulse.StageOutput, "sink", errors.New("sink"))
+	add(edgeimpulse.StageCapture, "recorder", nil)
+	add(edgeimpulse.StageClassify, "classifier", nil)
+	add(edgeimpulse.StageOutput, "trigger", errors.New("trigger"))
+
+	if err := g.Close(); err == nil || err.Error() != "trigger" {
+		t.Fatalf("got error %v, expected first error from trigger", err)
+	}
+	exp := []string{"recorder", "classifier", "trigger", "sink", "runner"}
+	if !reflect.DeepEqual(closed, exp) {
+		t.Fatalf("closed in order %v, expected %v", closed, exp)
+	}
+	select {
+	case <-g.Done():
+	default:
+		t.Fatalf("context not canceled after close")
+	}
+
+	g.Close()
+	if len(closed) != len(exp) {
+		t.Fatalf("second close closed components again")
+	}
+}
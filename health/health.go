@@ -0,0 +1,97 @@
+// Package health implements HTTP health and readiness endpoints for
+// long-running classification programs, for use by supervisors such as
+// Kubernetes, balena or docker health checks.
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Checker tracks whether a classification pipeline is ready and still making
+// progress. Checker is an http.Handler serving /healthz and /readyz.
+//
+// /healthz fails once the pipeline is ready but has not seen an event for
+// longer than the maximum age, i.e. when it is wedged and should be restarted.
+// /readyz succeeds only when the pipeline is ready and has recently seen an
+// event.
+type Checker struct {
+	mutex  sync.Mutex
+	ready  bool
+	paused bool
+	maxAge time.Duration
+	last   time.Time // Last event, or when pipeline became ready.
+	now    func() time.Time
+}
+
+// NewChecker returns a new checker for a pipeline that is not yet ready.
+func NewChecker() *Checker {
+	return &Checker{now: time.Now}
+}
+
+// Ready marks the pipeline as ready, e.g. when the model is loaded and the
+// recorder started. From now on, events must be seen within maxAge.
+func (c *Checker) Ready(maxAge time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ready = true
+	c.maxAge = maxAge
+	c.last = c.now()
+}
+
+// Event records progress, e.g. a frame or audio window that was classified.
+func (c *Checker) Event() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.last = c.now()
+}
+
+// SetPaused marks the pipeline as paused or running again, e.g. during the off
+// periods of a schedule. A paused pipeline is alive without events. When
+// running again, events must be seen within the maximum age from then.
+func (c *Checker) SetPaused(paused bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.paused = paused
+	c.last = c.now()
+}
+
+// status returns whether the pipeline is alive and ready, with a
+// human-readable explanation.
+func (c *Checker) status() (alive, ready bool, msg string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.ready {
+		return true, false, "not ready"
+	}
+	if c.paused {
+		return true, true, "paused"
+	}
+	age := c.now().Sub(c.last)
+	if age > c.maxAge {
+		return false, false, fmt.Sprintf("no events for %v", age.Round(time.Millisecond))
+	}
+	return true, true, fmt.Sprintf("ok, last event %v ago", age.Round(time.Millisecond))
+}
+
+// ServeHTTP serves the /healthz and /readyz endpoints.
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	alive, ready, msg := c.status()
+	var ok bool
+	switch r.URL.Path {
+	case "/healthz":
+		ok = alive
+	case "/readyz":
+		ok = ready
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprintln(w, msg)
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChecker(t *testing.T) {
+	now := time.Now()
+	c := NewChecker()
+	c.now = func() time.Time {
+		return now
+	}
+
+	check := func(path string, expCode int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != expCode {
+			t.Fatalf("%s: got status %d, expected %d", path, w.Code, expCode)
+		}
+	}
+
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusServiceUnavailable)
+	check("/other", http.StatusNotFound)
+
+	c.Ready(time.Second)
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusOK)
+
+	now = now.Add(2 * time.Second)
+	check("/healthz", http.StatusServiceUnavailable)
+	check("/readyz", http.StatusServiceUnavailable)
+
+	c.Event()
+	check("/healthz", http.StatusOK)
+	check("/readyz", http.StatusOK)
+
+	c.SetPaused(true)
+	now = now.Add(time.Minute)
+	check("/healthz", http.StatusOK)
+
+	c.SetPaused(false)
+	check("/healthz", http.StatusOK)
+	now = now.Add(2 * time.Second)
+	check("/healthz", http.StatusServiceUnavailable)
+}
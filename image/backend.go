@@ -0,0 +1,152 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// DeviceLister lists the devices a recorder backend can record from.
+type DeviceLister interface {
+	ListDevices() ([]Device, error)
+}
+
+// DeviceListerFunc is a function implementing DeviceLister.
+type DeviceListerFunc func() ([]Device, error)
+
+// ListDevices calls fn.
+func (fn DeviceListerFunc) ListDevices() ([]Device, error) {
+	return fn()
+}
+
+// BackendOpts are the options passed to Backend.NewRecorder.
+type BackendOpts struct {
+	Verbose  bool
+	Interval time.Duration      // How often to record an image.
+	DeviceID string             // As returned by ListDevices. If empty, the backend picks a device.
+	Logger   edgeimpulse.Logger // Receives log messages.
+	Clock    edgeimpulse.Clock  // For throttling to Interval, if the backend throttles. If nil, the system clock is used.
+}
+
+// Backend is a recorder implementation, e.g. ffmpeg or gstreamer. Backends
+// register themselves with Register when their package is imported.
+type Backend struct {
+	Name string
+
+	// Backends with a higher priority are preferred when selecting a device.
+	Priority int
+
+	DeviceLister
+	NewRecorder func(ctx context.Context, opts BackendOpts) (Recorder, error)
+}
+
+// BackendDevice is a device along with the backend that can record from it.
+type BackendDevice struct {
+	Backend string
+	Device
+}
+
+var backends struct {
+	sync.Mutex
+	l []Backend
+}
+
+// Register makes a recorder backend available. Register panics if a backend
+// with the same name was already registered.
+func Register(b Backend) {
+	backends.Lock()
+	defer backends.Unlock()
+	for _, o := range backends.l {
+		if o.Name == b.Name {
+			panic(fmt.Sprintf("image: backend %q registered twice", b.Name))
+		}
+	}
+	backends.l = append(backends.l, b)
+	sort.SliceStable(backends.l, func(i, j int) bool {
+		bi, bj := backends.l[i], backends.l[j]
+		if bi.Priority != bj.Priority {
+			return bi.Priority > bj.Priority
+		}
+		return bi.Name < bj.Name
+	})
+}
+
+// Backends returns the registered backends, preferred backends first.
+func Backends() []Backend {
+	backends.Lock()
+	defer backends.Unlock()
+	return append([]Backend(nil), backends.l...)
+}
+
+// LookupBackend returns the registered backend with name.
+func LookupBackend(name string) (Backend, bool) {
+	for _, b := range Backends() {
+		if b.Name == name {
+			return b, true
+		}
+	}
+	return Backend{}, false
+}
+
+// ListAllDevices returns the devices of all registered backends, preferred
+// backends first. Backends that fail to list devices, e.g. because their
+// program is not installed, are skipped. If no devices are found, the error of
+// the first failing backend is returned, or ErrNoDevices.
+func ListAllDevices() ([]BackendDevice, error) {
+	var l []BackendDevice
+	var firstErr error
+	for _, b := range Backends() {
+		devs, err := b.ListDevices()
+		if err != nil {
+			if firstErr == nil && !errors.Is(err, ErrNoDevices) {
+				firstErr = fmt.Errorf("%s: %w", b.Name, err)
+			}
+			continue
+		}
+		for _, d := range devs {
+			l = append(l, BackendDevice{b.Name, d})
+		}
+	}
+	if len(l) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, ErrNoDevices
+	}
+	return l, nil
+}
+
+// FindDevice returns the preferred backend that can record from the device
+// with deviceID, or from the device selected by the policy set with
+// SetDevicePolicy if deviceID is empty. ErrDeviceNotFound is returned if no
+// backend has the device.
+func FindDevice(deviceID string) (Backend, Device, error) {
+	devs, err := ListAllDevices()
+	if err != nil {
+		return Backend{}, Device{}, err
+	}
+	if deviceID == "" {
+		l := make([]Device, len(devs))
+		for i, d := range devs {
+			l[i] = d.Device
+		}
+		devicePolicy.Lock()
+		p := devicePolicy.p
+		devicePolicy.Unlock()
+		d := devs[p.Select(l)]
+		b, _ := LookupBackend(d.Backend)
+		return b, d.Device, nil
+	}
+	for _, d := range devs {
+		if deviceID == "" || d.ID == deviceID {
+			b, _ := LookupBackend(d.Backend)
+			return b, d.Device, nil
+		}
+	}
+	return Backend{}, Device{}, fmt.Errorf("%w: %q", ErrDeviceNotFound, deviceID)
+}
@@ -0,0 +1,52 @@
+package image_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+func TestBackends(t *testing.T) {
+	lister := func(ids ...string) image.DeviceLister {
+		return image.DeviceListerFunc(func() ([]image.Device, error) {
+			if len(ids) == 0 {
+				return nil, image.ErrNoDevices
+			}
+			var l []image.Device
+			for _, id := range ids {
+				l = append(l, image.Device{ID: id, Name: id})
+			}
+			return l, nil
+		})
+	}
+	image.Register(image.Backend{Name: "low", Priority: 1, DeviceLister: lister("/dev/video0", "/dev/video1")})
+	image.Register(image.Backend{Name: "high", Priority: 2, DeviceLister: lister("/dev/video0")})
+	image.Register(image.Backend{Name: "empty", Priority: 3, DeviceLister: lister()})
+
+	devs, err := image.ListAllDevices()
+	if err != nil {
+		t.Fatalf("listing devices: %v", err)
+	}
+	if len(devs) != 3 || devs[0].Backend != "high" || devs[1].Backend != "low" {
+		t.Fatalf("unexpected devices %v", devs)
+	}
+
+	check := func(id, expBackend string) {
+		t.Helper()
+		b, d, err := image.FindDevice(id)
+		if err != nil {
+			t.Fatalf("finding device %q: %v", id, err)
+		}
+		if b.Name != expBackend || (id != "" && d.ID != id) {
+			t.Fatalf("found %s %v for %q, expected backend %s", b.Name, d, id, expBackend)
+		}
+	}
+	check("", "high")
+	check("/dev/video0", "high")
+	check("/dev/video1", "low")
+
+	if _, _, err := image.FindDevice("/dev/video2"); !errors.Is(err, image.ErrDeviceNotFound) {
+		t.Fatalf("got error %v, expected ErrDeviceNotFound", err)
+	}
+}
@@ -3,15 +3,18 @@
 package image
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/draw"
 	"image/png"
-	"log"
-	"os"
+	"io"
+	"math"
+	"sync"
 	"time"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/classifier"
 
 	"github.com/disintegration/imaging"
 )
@@ -31,21 +34,112 @@ type ClassifyEvent struct {
 	// The image that was classified, after transforming to fit the
 	// requirements for the model.
 	Image image.Image
+
+	// Sequence number of the image, starting at 1.
+	FrameID int64
+
+	// Context with the "eim.postprocess" tracing span of this frame, for
+	// tracing post-processing. See ClassifierOpts.Tracer.
+	Context context.Context
+
+	trace *classifier.Trace
 }
 
 // Classifier receives images from a recorder, classifies them, and sends the
-// results on channel Events.
+// results on channel Events. Events is closed when the classifier stops, after
+// Close, canceling its context, or when the recorder stops.
 type Classifier struct {
 	Events chan ClassifyEvent
 
 	recorder Recorder
-	stop     chan struct{}
+	loop     *classifier.Loop
 }
 
 // ClassifierOpts are options for the classifier.
 type ClassifierOpts struct {
-	Verbose  bool   // Print verbose logging.
-	TraceDir string // If not empty, directory to write images sent to runner.
+	Verbose  bool                    // Print verbose logging.
+	Trace    edgeimpulse.TraceWriter // If set, images sent to the runner are written to it as PNG traces named image-<seq>.png.
+	TraceDir string                  // If not empty and Trace is nil, directory to write image traces to.
+	Logger   edgeimpulse.Logger      // Receives log messages. If nil, the standard logger is used, see edgeimpulse.DefaultLogger.
+	Tracer   edgeimpulse.Tracer      // If set, spans are started for each frame, and its capture, preprocessing, classification and postprocessing.
+	Clock    edgeimpulse.Clock       // For measuring latencies. If nil, edgeimpulse.SystemClock is used.
+
+	// Scaling of the image features sent to the runner. If zero, the
+	// scaling the model reports is used, see ModelScaling, which is packed
+	// RGB pixels for model processes.
+	Scaling Scaling
+
+	// If OnResult or OnError is set, the classifier calls them for each
+	// event from a goroutine it manages, instead of sending events on
+	// Events, which must not be read. Handlers are called one at a time,
+	// and should return quickly; slow handlers cause input to be dropped.
+	// Errors without OnError are logged. Handlers must not call Close.
+	OnResult func(ev ClassifyEvent) // For successful classifications.
+	OnError  func(err error)        // For errors, e.g. reading input or from the model.
+}
+
+// ClassifierOption configures a classifier created with NewClassifier. A
+// *ClassifierOpts is also a ClassifierOption, and replaces all settings made
+// by earlier options.
+type ClassifierOption interface {
+	applyClassifier(o *ClassifierOpts)
+}
+
+type classifierOptionFunc func(o *ClassifierOpts)
+
+func (fn classifierOptionFunc) applyClassifier(o *ClassifierOpts) {
+	fn(o)
+}
+
+func (opts *ClassifierOpts) applyClassifier(o *ClassifierOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithVerbose sets ClassifierOpts.Verbose.
+func WithVerbose(verbose bool) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Verbose = verbose })
+}
+
+// WithTrace sets ClassifierOpts.Trace.
+func WithTrace(tw edgeimpulse.TraceWriter) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Trace = tw })
+}
+
+// WithTraceDir sets ClassifierOpts.TraceDir.
+func WithTraceDir(dir string) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.TraceDir = dir })
+}
+
+// WithLogger sets ClassifierOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Logger = logger })
+}
+
+// WithTracer sets ClassifierOpts.Tracer.
+func WithTracer(tracer edgeimpulse.Tracer) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Tracer = tracer })
+}
+
+// WithClock sets ClassifierOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Clock = clock })
+}
+
+// WithScaling sets ClassifierOpts.Scaling.
+func WithScaling(scaling Scaling) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Scaling = scaling })
+}
+
+// WithOnResult sets ClassifierOpts.OnResult.
+func WithOnResult(fn func(ev ClassifyEvent)) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.OnResult = fn })
+}
+
+// WithOnError sets ClassifierOpts.OnError.
+func WithOnError(fn func(err error)) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.OnError = fn })
 }
 
 // NewClassifier returns a new classifier that receives messages from recorder,
@@ -53,22 +147,32 @@ type ClassifierOpts struct {
 // Events.
 //
 // Callers must call Close to clean up the classifier, and separately close the
-// runner and recorder.
-func NewClassifier(runner edgeimpulse.Runner, recorder Recorder, opts *ClassifierOpts) (*Classifier, error) {
+// runner and recorder. Canceling ctx also stops the classifier.
+func NewClassifier(ctx context.Context, runner edgeimpulse.Runner, recorder Recorder, opts ...ClassifierOption) (*Classifier, error) {
 	var xopts ClassifierOpts
-	if opts != nil {
-		xopts = *opts
+	for _, o := range opts {
+		if o != nil {
+			o.applyClassifier(&xopts)
+		}
 	}
+	handlers := xopts.OnResult != nil || xopts.OnError != nil
+	loop := classifier.New(ctx, classifier.Opts{Verbose: xopts.Verbose, Logger: xopts.Logger, Tracer: xopts.Tracer, Clock: xopts.Clock, Handlers: handlers})
+	logger, clock := loop.Logger, loop.Clock
+	trace := edgeimpulse.DefaultTraceWriter(xopts.Trace, xopts.TraceDir)
 
 	modelParams := runner.ModelParameters()
 	if modelParams.SensorType != edgeimpulse.SensorTypeCamera {
 		return nil, fmt.Errorf("sensor for this model was %q, expected camera", modelParams.SensorType)
 	}
+	scaling := xopts.Scaling
+	if scaling.Mode == "" {
+		scaling = ModelScaling(modelParams)
+	}
 
 	c := &Classifier{
-		make(chan ClassifyEvent, 1),
-		recorder,
-		make(chan struct{}, 1),
+		Events:   make(chan ClassifyEvent, 1),
+		recorder: recorder,
+		loop:     loop,
 	}
 
 	imageEvents := recorder.Events()
@@ -76,115 +180,217 @@ func NewClassifier(runner edgeimpulse.Runner, recorder Recorder, opts *Classifie
 	// Start at 2 to match the sequence numbers in the typical runner that uses message
 	// ID's, with ID 1 for the hello transaction.
 	seq := 2
+	var frame int64
+
+	// send delivers an event, returning false if the classifier was stopped
+	// instead.
+	send := func(ev ClassifyEvent) bool {
+		select {
+		case c.Events <- ev:
+			return true
+		case <-loop.Done():
+			return false
+		}
+	}
 
-	go func() {
+	// Payloads for the model, reused for the next frames. Runners don't
+	// retain the data passed to Classify.
+	payloads := sync.Pool{
+		New: func() interface{} {
+			data := make([]float32, scaling.Len(modelParams.ImageInputWidth, modelParams.ImageInputHeight, modelParams.ImageChannelCount))
+			return &data
+		},
+	}
+
+	loop.Run(func() {
 		for {
+			tr := loop.StartTrace("eim.frame")
 			select {
-			case <-c.stop:
+			case <-loop.Done():
+				tr.End()
 				return
 			case iev, ok := <-imageEvents:
 				if !ok {
+					tr.End()
 					return
 				}
 				if iev.Err != nil {
-					c.Events <- ClassifyEvent{Err: iev.Err}
+					tr.Fail(iev.Err)
+					if !send(ClassifyEvent{Err: iev.Err}) {
+						return
+					}
 					continue
 				}
 
-				modelSize := image.Point{modelParams.ImageInputWidth, modelParams.ImageInputHeight}
-
-				img := iev.Image
-				imgSize := img.Bounds().Size()
-				if imgSize != modelSize {
-					if xopts.Verbose {
-						log.Printf("resizing image from %v to %v", imgSize, modelSize)
-					}
-					img = imageResize(img, modelSize, xopts.Verbose)
-				}
+				start := clock.Now()
+				frame++
+				tr.SetAttributes(edgeimpulse.Attribute{Key: "eim.frame.id", Value: frame})
+				tr.Stage("eim.preprocess")
+				img := prepare(iev.Image, modelParams, logger)
+				payload := payloads.Get().(*[]float32)
+				data := *payload
+				scaling.Features32(img, modelParams.ImageChannelCount, data)
 
-				if modelParams.ImageChannelCount == 3 {
-					switch img.(type) {
-					case *image.NRGBA:
-					default:
-						if xopts.Verbose {
-							log.Printf("converting to nrgba image")
-						}
-						nimg := image.NewNRGBA(img.Bounds())
-						draw.Draw(nimg, nimg.Bounds(), img, image.Point{}, draw.Src)
-						img = nimg
-					}
-				} else {
-					switch img.(type) {
-					case *image.Gray:
-					default:
-						if xopts.Verbose {
-							log.Printf("converting to gray image")
-						}
-						nimg := image.NewGray(img.Bounds())
-						draw.Draw(nimg, nimg.Bounds(), img, image.Point{}, draw.Src)
-						img = nimg
-					}
-				}
+				edgeimpulse.WriteTrace(trace, logger, fmt.Sprintf("image-%d.png", seq), func(w io.Writer) error {
+					return png.Encode(w, img)
+				})
 
-				data := make([]float64, modelSize.X*modelSize.Y)
-				i := 0
-				for y := 0; y < modelSize.Y; y++ {
-					for x := 0; x < modelSize.X; x++ {
-						r, g, b, _ := img.At(x, y).RGBA()
-						r >>= 8
-						g >>= 8
-						b >>= 8
-						v := (r << 16) | (g << 8) | b
-						data[i] = float64(v)
-						i++
+				tr.Stage("eim.classify")
+				t0 := clock.Now()
+				resp, err := edgeimpulse.Classify32(runner, data)
+				payloads.Put(payload)
+				if err != nil {
+					loop.Stats.AddError()
+					tr.Fail(err)
+					if !send(ClassifyEvent{Err: err}) {
+						return
 					}
+					continue
 				}
-
-				if xopts.TraceDir != "" {
-					pngPath := fmt.Sprintf("%s/image-%d.png", xopts.TraceDir, seq)
-					pf, err := os.Create(pngPath)
-					if err != nil {
-						log.Printf("trace, creating %s: %v", pngPath, err)
-					} else {
-						if err := png.Encode(pf, img); err != nil {
-							log.Printf("trace, encoding png: %v", err)
-						}
-						if err := pf.Close(); err != nil {
-							log.Printf("trace, closing file: %v", err)
-						} else {
-							log.Printf("trace %s", pngPath)
-						}
-					}
+				tr.StageAttributes(edgeimpulse.TimingAttributes(resp)...)
+				latency := clock.Now().Sub(t0)
+				loop.Stats.Add(resp, clock.Now().Sub(start))
+				pctx := tr.Stage("eim.postprocess")
+				ev := ClassifyEvent{nil, resp, latency, iev.Image, frame, pctx, tr}
+				if !loop.Deliver(tr, func() bool { return send(ev) }) {
+					return
 				}
+				seq++
+			}
+		}
+	}, func(err error) {
+		send(ClassifyEvent{Err: err})
+	}, func() {
+		close(c.Events)
+	})
 
-				t0 := time.Now()
-				resp, err := runner.Classify(data)
-				if err != nil {
-					c.Events <- ClassifyEvent{Err: err}
+	if handlers {
+		loop.Handle(func() {
+			for ev := range c.Events {
+				if ev.Err != nil {
+					loop.HandleError(ev.Err, xopts.OnError)
 					continue
 				}
-				c.Events <- ClassifyEvent{nil, resp, time.Since(t0), iev.Image}
-				seq++
+				if xopts.OnResult != nil {
+					xopts.OnResult(ev)
+				}
+				ev.trace.End()
 			}
-		}
-	}()
+		})
+	}
 
 	return c, nil
 }
 
-// Close shuts down the classifier.
+// Stats returns latency statistics of the classifications so far. The total
+// latency includes preparing the image for the model.
+func (c *Classifier) Stats() *edgeimpulse.Stats {
+	return c.loop.Stats
+}
+
+// Close shuts down the classifier, waiting for a classification in progress to
+// finish, and for handlers to return. Events is closed when Close returns.
+// Close can be called multiple times, and does not require Events to be read.
 // The runner and recorder must be stopped by the caller.
 func (c *Classifier) Close() error {
-	c.stop <- struct{}{}
-	return nil
+	return c.loop.Close()
+}
+
+// Err returns the error that stopped the classifier after recovering from a
+// panic, e.g. for a malformed input, or nil. The error is also sent on
+// Events.
+func (c *Classifier) Err() error {
+	return c.loop.Err()
+}
+
+// prepare resizes img to the input size of the model, and converts it to the
+// color model of the model.
+func prepare(img image.Image, modelParams edgeimpulse.ModelParameters, logger edgeimpulse.Logger) image.Image {
+	modelSize := image.Point{modelParams.ImageInputWidth, modelParams.ImageInputHeight}
+	imgSize := img.Bounds().Size()
+	if imgSize != modelSize {
+		logger.Logf(edgeimpulse.LogDebug, "resizing image from %v to %v", imgSize, modelSize)
+		img = imageResize(img, modelSize, logger)
+	}
+
+	if modelParams.ImageChannelCount == 3 {
+		switch img.(type) {
+		case *image.NRGBA:
+		default:
+			logger.Logf(edgeimpulse.LogDebug, "converting to nrgba image")
+			nimg := image.NewNRGBA(img.Bounds())
+			draw.Draw(nimg, nimg.Bounds(), img, img.Bounds().Min, draw.Src)
+			img = nimg
+		}
+	} else {
+		switch img.(type) {
+		case *image.Gray:
+		default:
+			logger.Logf(edgeimpulse.LogDebug, "converting to gray image")
+			nimg := image.NewGray(img.Bounds())
+			draw.Draw(nimg, nimg.Bounds(), img, img.Bounds().Min, draw.Src)
+			img = nimg
+		}
+	}
+	return img
+}
+
+// Classify prepares img for the model of runner, the same way the Classifier
+// does, and classifies it. Useful for classifying single images, or regions of
+// images. Features are scaled as the model reports, see ModelScaling. The
+// prepared image is returned as well.
+func Classify(runner edgeimpulse.Runner, img image.Image) (edgeimpulse.RunnerClassifyResponse, image.Image, error) {
+	modelParams := runner.ModelParameters()
+	if modelParams.SensorType != edgeimpulse.SensorTypeCamera {
+		return edgeimpulse.RunnerClassifyResponse{}, nil, fmt.Errorf("sensor for this model was %q, expected camera", modelParams.SensorType)
+	}
+	scaling := ModelScaling(modelParams)
+	img = prepare(img, modelParams, edgeimpulse.DefaultLogger(nil, false))
+	data := make([]float32, scaling.Len(modelParams.ImageInputWidth, modelParams.ImageInputHeight, modelParams.ImageChannelCount))
+	scaling.Features32(img, modelParams.ImageChannelCount, data)
+	resp, err := edgeimpulse.Classify32(runner, data)
+	return resp, img, err
+}
+
+// Features prepares img for a model with modelParams, the same way the
+// Classifier does, and returns its features scaled with scaling, or as the
+// model reports if scaling is zero, e.g. for storing test inputs. The prepared
+// image is returned as well.
+func Features(img image.Image, modelParams edgeimpulse.ModelParameters, scaling Scaling) ([]float64, image.Image) {
+	if scaling.Mode == "" {
+		scaling = ModelScaling(modelParams)
+	}
+	img = prepare(img, modelParams, edgeimpulse.DefaultLogger(nil, false))
+	data := make([]float64, scaling.Len(modelParams.ImageInputWidth, modelParams.ImageInputHeight, modelParams.ImageChannelCount))
+	scaling.Features(img, modelParams.ImageChannelCount, data)
+	return data, img
+}
+
+// SourceRect returns the rectangle in a source image of srcSize that
+// corresponds to r in the coordinates of a model input of modelSize, e.g. of a
+// bounding box. It inverts the resizing and center cropping applied to images
+// before classifying.
+func SourceRect(r image.Rectangle, srcSize, modelSize image.Point) image.Rectangle {
+	if srcSize == modelSize || modelSize.X == 0 || modelSize.Y == 0 {
+		return r
+	}
+	// The source is scaled to cover the model input, and centered.
+	scale := math.Max(float64(modelSize.X)/float64(srcSize.X), float64(modelSize.Y)/float64(srcSize.Y))
+	offX := (float64(srcSize.X)*scale - float64(modelSize.X)) / 2
+	offY := (float64(srcSize.Y)*scale - float64(modelSize.Y)) / 2
+	conv := func(p image.Point) image.Point {
+		return image.Point{
+			int(math.Round((float64(p.X) + offX) / scale)),
+			int(math.Round((float64(p.Y) + offY) / scale)),
+		}
+	}
+	return image.Rectangle{conv(r.Min), conv(r.Max)}.Intersect(image.Rectangle{Max: srcSize})
 }
 
 // imageResize resizes to the exact size. It crops part of the image to keep aspect ratio.
-func imageResize(img image.Image, size image.Point, verbose bool) image.Image {
+func imageResize(img image.Image, size image.Point, logger edgeimpulse.Logger) image.Image {
 	t0 := time.Now()
 	r := imaging.Fill(img, size.X, size.Y, imaging.Center, imaging.NearestNeighbor)
-	if verbose {
-		log.Printf("resizing in %v", time.Since(t0))
-	}
+	logger.Logf(edgeimpulse.LogDebug, "resizing in %v", time.Since(t0))
 	return r
 }
@@ -0,0 +1,82 @@
+package image_test
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	eimage "github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+func TestSourceRect(t *testing.T) {
+	test := func(r image.Rectangle, src, model image.Point, want image.Rectangle) {
+		t.Helper()
+		if got := eimage.SourceRect(r, src, model); got != want {
+			t.Fatalf("SourceRect(%v, %v, %v) = %v, expected %v", r, src, model, got, want)
+		}
+	}
+	test(image.Rect(1, 2, 3, 4), image.Pt(8, 8), image.Pt(8, 8), image.Rect(1, 2, 3, 4))
+	test(image.Rect(0, 0, 8, 8), image.Pt(16, 8), image.Pt(8, 8), image.Rect(4, 0, 12, 8))
+	test(image.Rect(0, 0, 4, 4), image.Pt(32, 16), image.Pt(8, 8), image.Rect(8, 0, 16, 8))
+	test(image.Rect(0, 0, 8, 8), image.Pt(8, 16), image.Pt(16, 16), image.Rect(0, 4, 4, 8))
+}
+
+func TestCrops(t *testing.T) {
+	var resp edgeimpulse.RunnerClassifyResponse
+	err := json.Unmarshal([]byte(`{"result": {"bounding_boxes": [
+		{"label": "cat", "value": 0.9, "x": 0, "y": 0, "width": 4, "height": 4},
+		{"label": "dog", "value": 0.3, "x": 4, "y": 4, "width": 4, "height": 4}
+	]}}`), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	crops := eimage.Crops(img, resp, image.Pt(8, 8), 0.25, 0.5)
+	if len(crops) != 1 {
+		t.Fatalf("got %d crops, expected 1", len(crops))
+	}
+	// Source x offset 4, then padded by 1 on each side and clipped.
+	c := crops[0]
+	if c.Label != "cat" || c.Score != 0.9 || c.Rect != image.Rect(3, 0, 9, 5) || c.Image.Bounds() != c.Rect {
+		t.Errorf("got crop %s %v at %v, bounds %v", c.Label, c.Score, c.Rect, c.Image.Bounds())
+	}
+}
+
+func TestScaling(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{255, 0, 51, 255})
+	img.Set(1, 0, color.NRGBA{0, 255, 0, 255})
+
+	test := func(spec string, channels int, want []float64) {
+		t.Helper()
+		s, err := eimage.ParseScaling(spec)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", spec, err)
+		}
+		if s.Mode == "" {
+			s = eimage.ModelScaling(edgeimpulse.ModelParameters{})
+		}
+		data := make([]float64, s.Len(2, 1, channels))
+		s.Features(img, channels, data)
+		for i := range want {
+			if math.Abs(data[i]-want[i]) > 0.005 {
+				t.Fatalf("scaling %q: got %v, expected %v", spec, data, want)
+			}
+		}
+	}
+	test("", 3, []float64{0xff0033, 0x00ff00})
+	test("unit", 3, []float64{1, 0, 0.2, 0, 1, 0})
+	test("-1..1", 3, []float64{1, -1, -0.6, -1, 1, -1})
+	test("normalize:0.5:0.25,0.5,1", 3, []float64{2, -1, -0.3, -2, 1, -0.5})
+	test("unit", 1, []float64{0.299 + 0.114*0.2, 0.587})
+
+	if _, err := eimage.ParseScaling("normalize:0:0"); err == nil {
+		t.Errorf("std 0: expected error")
+	}
+	if s := eimage.ModelScaling(edgeimpulse.ModelParameters{ImageFeatureScaling: "imagenet"}); s != eimage.ScalingImageNet {
+		t.Errorf("got model scaling %v, expected imagenet", s)
+	}
+}
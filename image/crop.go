@@ -0,0 +1,54 @@
+package image
+
+import (
+	"image"
+	"image/draw"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Crop is the region of a bounding box in the image that was classified.
+type Crop struct {
+	Label string
+	Score float64
+	Rect  image.Rectangle // In coordinates of the image.
+	Image image.Image
+}
+
+// Crops returns the region of img for each bounding box in resp with a score
+// of at least min, e.g. for classifying the detected objects with a second
+// model, or uploading them as samples. ModelSize is the input size of the
+// model, see SourceRect. Regions are grown by padding, a fraction of their
+// size on each side, and clipped to img.
+func Crops(img image.Image, resp edgeimpulse.RunnerClassifyResponse, modelSize image.Point, padding, min float64) []Crop {
+	var l []Crop
+	bounds := img.Bounds()
+	for _, b := range resp.Result.BoundingBoxes {
+		if b.Value < min {
+			continue
+		}
+		r := image.Rect(b.X, b.Y, b.X+b.Width, b.Y+b.Height)
+		r = SourceRect(r, bounds.Size(), modelSize)
+		dx := int(float64(r.Dx()) * padding)
+		dy := int(float64(r.Dy()) * padding)
+		r = image.Rect(r.Min.X-dx, r.Min.Y-dy, r.Max.X+dx, r.Max.Y+dy)
+		r = r.Add(bounds.Min).Intersect(bounds)
+		if r.Empty() {
+			continue
+		}
+		l = append(l, Crop{b.Label, b.Value, r, SubImage(img, r)})
+	}
+	return l
+}
+
+// SubImage returns the region r of img, sharing pixels if possible.
+func SubImage(img image.Image, r image.Rectangle) image.Image {
+	if s, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	dst := image.NewNRGBA(r)
+	draw.Draw(dst, r, img, r.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,305 @@
+// Package depth implements an image recorder for depth cameras, like the
+// Intel RealSense D400 series, that expose a depth stream in the Z16 format
+// through V4L2, usually next to a color stream of the same camera.
+//
+// Streams are read with ffmpeg. The recorder sends color images, depth images
+// colorized from near to far, or aligned RGB-D images: color images with the
+// depth of each pixel, see RGBD.
+//
+// Importing this package registers image backend "depth".
+package depth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	goimage "image"
+	"image/color"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+var errInstallHint = errors.New("ffmpeg not found, install with: sudo apt install -y ffmpeg")
+
+// Mode is the kind of images sent by a recorder.
+type Mode string
+
+// Modes.
+const (
+	ModeColor Mode = "color" // Images of the color stream.
+	ModeDepth Mode = "depth" // Depth, colorized from near in blue to far in red.
+	ModeRGBD  Mode = "rgbd"  // Images of the color stream as *RGBD, with aligned depth.
+)
+
+// ParseMode parses the name of a mode.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeColor, ModeDepth, ModeRGBD:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown mode %q, must be color, depth or rgbd", s)
+}
+
+// Size is the resolution of a stream.
+type Size struct {
+	Width, Height int
+}
+
+// ParseSize parses a size like "640x480".
+func ParseSize(s string) (Size, error) {
+	var sz Size
+	if _, err := fmt.Sscanf(s, "%dx%d", &sz.Width, &sz.Height); err != nil || sz.Width <= 0 || sz.Height <= 0 {
+		return Size{}, fmt.Errorf("bad size %q, must be like 640x480", s)
+	}
+	return sz, nil
+}
+
+func (s Size) String() string {
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+// RecorderOpts has options for a new depth recorder.
+type RecorderOpts struct {
+	Verbose  bool
+	Interval time.Duration // How often to record an image. Defaults to a second.
+
+	// Depth stream, as returned by ListDevices. If empty, NewRecorder will
+	// use the first device returned by ListDevices.
+	DeviceID string
+
+	// Color stream. If empty, the color stream of the camera of DeviceID is
+	// used.
+	ColorDeviceID string
+
+	// Defaults to ModeDepth.
+	Mode Mode
+
+	// Resolution of the streams, both default to 640x480.
+	DepthSize, ColorSize Size
+
+	// Frames per second requested from the camera. Defaults to 30.
+	Framerate int
+
+	// Depths in mm colorized as nearest and farthest in ModeDepth. If equal,
+	// e.g. both 0, each frame is colorized from its nearest to its farthest
+	// pixel.
+	Min, Max int
+
+	// Region of the depth frame that covers the view of the color stream, for
+	// aligning depth to color in ModeRGBD. Depth cameras often have a wider
+	// view than their color camera. If empty, the whole depth frame is used.
+	Align goimage.Rectangle
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// Used for throttling to Interval. If nil, edgeimpulse.SystemClock is
+	// used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts RecorderOpts) apply(o *RecorderOpts) {
+	*o = opts
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithInterval sets RecorderOpts.Interval.
+func WithInterval(interval time.Duration) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Interval = interval })
+}
+
+// WithDevice sets RecorderOpts.DeviceID.
+func WithDevice(id string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.DeviceID = id })
+}
+
+// WithColorDevice sets RecorderOpts.ColorDeviceID.
+func WithColorDevice(id string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.ColorDeviceID = id })
+}
+
+// WithMode sets RecorderOpts.Mode.
+func WithMode(mode Mode) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Mode = mode })
+}
+
+// WithStreams sets RecorderOpts.DepthSize, RecorderOpts.ColorSize and
+// RecorderOpts.Framerate.
+func WithStreams(depth, color Size, framerate int) Option {
+	return optionFunc(func(o *RecorderOpts) {
+		o.DepthSize = depth
+		o.ColorSize = color
+		o.Framerate = framerate
+	})
+}
+
+// WithRange sets RecorderOpts.Min and RecorderOpts.Max.
+func WithRange(min, max int) Option {
+	return optionFunc(func(o *RecorderOpts) {
+		o.Min = min
+		o.Max = max
+	})
+}
+
+// WithAlign sets RecorderOpts.Align.
+func WithAlign(r goimage.Rectangle) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Align = r })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
+}
+
+var defaults struct {
+	sync.Mutex
+	opts RecorderOpts
+}
+
+// SetDefaults sets the options for recorders of the "depth" backend, e.g.
+// the mode and streams, before the options of the backend.
+func SetDefaults(opts ...Option) {
+	defaults.Lock()
+	defer defaults.Unlock()
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&defaults.opts)
+		}
+	}
+}
+
+func init() {
+	image.Register(image.Backend{
+		Name:         "depth",
+		Priority:     0,
+		DeviceLister: image.DeviceListerFunc(ListDevices),
+		NewRecorder: func(ctx context.Context, opts image.BackendOpts) (image.Recorder, error) {
+			defaults.Lock()
+			xopts := defaults.opts
+			defaults.Unlock()
+			xopts.Verbose = opts.Verbose
+			xopts.Interval = opts.Interval
+			xopts.DeviceID = opts.DeviceID
+			xopts.Logger = opts.Logger
+			xopts.Clock = opts.Clock
+			r, err := NewRecorder(ctx, xopts)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+	})
+}
+
+// ListDevices returns the depth streams of depth cameras, with the path of
+// the V4L2 device as ID. ListDevices returns an error if no devices are
+// available.
+func ListDevices() ([]image.Device, error) {
+	cams, err := listCameras()
+	if err != nil {
+		return nil, err
+	}
+	var devices []image.Device
+	for _, c := range cams {
+		devices = append(devices, image.Device{Name: c.name, ID: c.depth})
+	}
+	if len(devices) == 0 {
+		return nil, image.ErrNoDevices
+	}
+	return devices, nil
+}
+
+// camera is a depth camera, with the V4L2 devices of its streams.
+type camera struct {
+	name  string
+	depth string
+	color string // Empty if the camera has no color stream.
+}
+
+// jetStops are the colors of depths, from near to far, evenly spaced.
+var jetStops = []color.RGBA{
+	{0, 0, 255, 255},
+	{0, 255, 255, 255},
+	{0, 255, 0, 255},
+	{255, 255, 0, 255},
+	{255, 0, 0, 255},
+}
+
+// jet returns the color of f, between 0 and 1.
+func jet(f float64) color.RGBA {
+	x := f * float64(len(jetStops)-1)
+	i := int(x)
+	if i >= len(jetStops)-1 {
+		return jetStops[len(jetStops)-1]
+	}
+	a, b := jetStops[i], jetStops[i+1]
+	t := x - float64(i)
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + t*(float64(b)-float64(a)) + 0.5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// Colorize returns an image of width by height depths in row-major order,
+// colorized from min in blue to max in red. Pixels without depth, with value
+// 0, are black. If min and max are equal, the range of the valid depths is
+// used.
+func Colorize(depths []uint16, width, height int, min, max int) *goimage.RGBA {
+	if min == max {
+		min, max = 0, 0
+		for _, v := range depths {
+			if v == 0 {
+				continue
+			}
+			if min == 0 || int(v) < min {
+				min = int(v)
+			}
+			if int(v) > max {
+				max = int(v)
+			}
+		}
+	}
+	img := goimage.NewRGBA(goimage.Rect(0, 0, width, height))
+	for i, v := range depths[:width*height] {
+		c := color.RGBA{0, 0, 0, 255}
+		if v != 0 {
+			f := 0.0
+			if max > min {
+				f = float64(int(v)-min) / float64(max-min)
+			}
+			if f < 0 {
+				f = 0
+			} else if f > 1 {
+				f = 1
+			}
+			c = jet(f)
+		}
+		copy(img.Pix[4*i:], []uint8{c.R, c.G, c.B, c.A})
+	}
+	return img
+}
@@ -0,0 +1,72 @@
+package depth
+
+import (
+	goimage "image"
+	"image/color"
+	"testing"
+)
+
+func TestColorize(t *testing.T) {
+	depths := []uint16{0, 500, 1000, 1500}
+
+	img := Colorize(depths, 2, 2, 500, 1500)
+	for _, c := range []struct {
+		x, y int
+		c    color.RGBA
+	}{
+		{0, 0, color.RGBA{0, 0, 0, 255}},   // No depth.
+		{1, 0, color.RGBA{0, 0, 255, 255}}, // Near.
+		{0, 1, color.RGBA{0, 255, 0, 255}}, // Middle.
+		{1, 1, color.RGBA{255, 0, 0, 255}}, // Far.
+	} {
+		if got := img.RGBAAt(c.x, c.y); got != c.c {
+			t.Errorf("%d,%d: got %v, expected %v", c.x, c.y, got, c.c)
+		}
+	}
+
+	// Automatic range ignores pixels without depth.
+	auto := Colorize(depths, 2, 2, 0, 0)
+	if got, exp := auto.RGBAAt(1, 0), (color.RGBA{0, 0, 255, 255}); got != exp {
+		t.Errorf("auto near: got %v, expected %v", got, exp)
+	}
+}
+
+func TestAlign(t *testing.T) {
+	// 4x2 depth frame, the middle 2x2 covers the view of the color camera.
+	depths := []uint16{
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+	}
+	got := Align(depths, 4, 2, goimage.Rect(1, 0, 3, 2), Size{4, 4})
+	exp := []uint16{
+		2, 2, 3, 3,
+		2, 2, 3, 3,
+		6, 6, 7, 7,
+		6, 6, 7, 7,
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Fatalf("got %v, expected %v", got, exp)
+		}
+	}
+
+	m := &RGBD{goimage.NewRGBA(goimage.Rect(0, 0, 4, 4)), got}
+	if d := m.DepthAt(2, 3); d != 7 {
+		t.Errorf("depth at 2,3: got %d, expected 7", d)
+	}
+	if d := m.DepthAt(4, 0); d != 0 {
+		t.Errorf("depth outside image: got %d, expected 0", d)
+	}
+}
+
+func TestParse(t *testing.T) {
+	if s, err := ParseSize("848x480"); err != nil || s != (Size{848, 480}) {
+		t.Errorf("parse size: got %v, %v", s, err)
+	}
+	if _, err := ParseSize("848"); err == nil {
+		t.Errorf("parse size: expected error")
+	}
+	if _, err := ParseMode("ir"); err == nil {
+		t.Errorf("parse mode: expected error")
+	}
+}
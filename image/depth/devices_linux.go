@@ -0,0 +1,92 @@
+//go:build linux
+// +build linux
+
+package depth
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// V4L2 ioctl and values, from linux/videodev2.h.
+const (
+	vidiocEnumFmt        = 0xc0405602
+	v4l2BufTypeCapture   = 1
+	fmtdescSize          = 64
+	fmtdescPixelformat   = 44
+	pixelformatZ16       = 'Z' | '1'<<8 | '6'<<16 | ' '<<24
+	pixelformatYUYV      = 'Y' | 'U'<<8 | 'Y'<<16 | 'V'<<24
+	sysfsVideo4linuxGlob = "/sys/class/video4linux/video*"
+)
+
+// listCameras returns cameras with a V4L2 device with the Z16 depth format.
+// Devices of the same USB device are grouped into a camera.
+func listCameras() ([]camera, error) {
+	paths, err := filepath.Glob(sysfsVideo4linuxGlob)
+	if err != nil {
+		return nil, err
+	}
+	// Numerical order, so video10 comes after video2.
+	num := func(p string) int {
+		n, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(p), "video"))
+		return n
+	}
+	sort.Slice(paths, func(i, j int) bool { return num(paths[i]) < num(paths[j]) })
+
+	var cams []*camera
+	byParent := map[string]*camera{}
+	for _, p := range paths {
+		dev := "/dev/" + filepath.Base(p)
+		formats := pixelFormats(dev)
+		iface, err := filepath.EvalSymlinks(filepath.Join(p, "device"))
+		if err != nil {
+			continue
+		}
+		parent := filepath.Dir(iface)
+		c := byParent[parent]
+		if c == nil {
+			name, _ := os.ReadFile(filepath.Join(p, "name"))
+			c = &camera{name: strings.TrimSpace(string(name))}
+			byParent[parent] = c
+			cams = append(cams, c)
+		}
+		switch {
+		case formats[pixelformatZ16] && c.depth == "":
+			c.depth = dev
+		case formats[pixelformatYUYV] && !formats[pixelformatZ16] && c.color == "":
+			c.color = dev
+		}
+	}
+	var l []camera
+	for _, c := range cams {
+		if c.depth != "" {
+			l = append(l, *c)
+		}
+	}
+	return l, nil
+}
+
+// pixelFormats returns the capture formats of a V4L2 device, as fourcc.
+func pixelFormats(dev string) map[uint32]bool {
+	formats := map[uint32]bool{}
+	f, err := os.Open(dev)
+	if err != nil {
+		return formats
+	}
+	defer f.Close()
+	for i := uint32(0); ; i++ {
+		var desc [fmtdescSize]byte
+		binary.LittleEndian.PutUint32(desc[0:], i)
+		binary.LittleEndian.PutUint32(desc[4:], v4l2BufTypeCapture)
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), vidiocEnumFmt, uintptr(unsafe.Pointer(&desc[0]))); errno != 0 {
+			return formats
+		}
+		formats[binary.LittleEndian.Uint32(desc[fmtdescPixelformat:])] = true
+	}
+}
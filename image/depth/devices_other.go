@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package depth
+
+import (
+	"fmt"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+// listCameras returns an error, depth cameras are found through V4L2, only
+// available on linux.
+func listCameras() ([]camera, error) {
+	return nil, fmt.Errorf("%w: depth cameras are only supported on linux", image.ErrNoDevices)
+}
@@ -0,0 +1,290 @@
+package depth
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	goimage "image"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+)
+
+// Recorder is an image recorder for depth cameras.
+type Recorder struct {
+	opts        RecorderOpts
+	logger      edgeimpulse.Logger
+	imageEvents chan image.Event
+	cancel      context.CancelFunc
+	done        chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Check that Recorder implements interface Recorder.
+var _ image.Recorder = (*Recorder)(nil)
+
+// rawFrame is a frame read from a stream, or an error.
+type rawFrame struct {
+	buf []byte
+	err error
+}
+
+// NewRecorder creates a new recorder, starting ffmpeg for the depth and color
+// streams needed for the mode.
+//
+// Callers must call Close to clean up. Canceling ctx also stops the recorder.
+func NewRecorder(ctx context.Context, opts ...Option) (*Recorder, error) {
+	r := &Recorder{}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if r.opts.Interval <= 0 {
+		r.opts.Interval = time.Second
+	}
+	if r.opts.Mode == "" {
+		r.opts.Mode = ModeDepth
+	}
+	if _, err := ParseMode(string(r.opts.Mode)); err != nil {
+		return nil, err
+	}
+	if r.opts.DepthSize == (Size{}) {
+		r.opts.DepthSize = Size{640, 480}
+	}
+	if r.opts.ColorSize == (Size{}) {
+		r.opts.ColorSize = Size{640, 480}
+	}
+	if r.opts.Framerate <= 0 {
+		r.opts.Framerate = 30
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
+
+	needColor := r.opts.Mode != ModeDepth
+	needDepth := r.opts.Mode != ModeColor
+	if r.opts.DeviceID == "" || needColor && r.opts.ColorDeviceID == "" {
+		cams, err := listCameras()
+		if err != nil {
+			return nil, fmt.Errorf("listing devices: %w", err)
+		}
+		var cam *camera
+		for i, c := range cams {
+			if r.opts.DeviceID == "" || c.depth == r.opts.DeviceID {
+				cam = &cams[i]
+				break
+			}
+		}
+		if cam == nil {
+			return nil, fmt.Errorf("%w: %q", image.ErrDeviceNotFound, r.opts.DeviceID)
+		}
+		r.opts.DeviceID = cam.depth
+		if r.opts.ColorDeviceID == "" {
+			r.opts.ColorDeviceID = cam.color
+		}
+		if needColor && r.opts.ColorDeviceID == "" {
+			return nil, fmt.Errorf("no color stream found for %s, set the color device", cam.depth)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	r.imageEvents = make(chan image.Event)
+
+	var depthFrames, colorFrames chan rawFrame
+	if needDepth {
+		ch, err := r.start(ctx, r.opts.DeviceID, r.opts.DepthSize, 2, "gray16le")
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		depthFrames = ch
+	}
+	if needColor {
+		ch, err := r.start(ctx, r.opts.ColorDeviceID, r.opts.ColorSize, 3, "rgb24")
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		colorFrames = ch
+	}
+
+	go func() {
+		defer close(r.done)
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				r.sendErr(ctx, err)
+			}
+		}()
+
+		throttle := image.NewThrottle(r.opts.Interval, r.opts.Clock)
+		var depth, color []byte
+		for {
+			// A new frame of the color stream, or of the depth stream in
+			// ModeDepth, makes an image.
+			var fresh bool
+			select {
+			case <-ctx.Done():
+				return
+			case f := <-depthFrames:
+				if f.err != nil {
+					r.sendErr(ctx, fmt.Errorf("reading depth stream: %w", f.err))
+					return
+				}
+				depth = f.buf
+				fresh = !needColor
+			case f := <-colorFrames:
+				if f.err != nil {
+					r.sendErr(ctx, fmt.Errorf("reading color stream: %w", f.err))
+					return
+				}
+				color = f.buf
+				fresh = true
+			}
+			if !fresh || needDepth && depth == nil || needColor && color == nil {
+				continue
+			}
+			now, due := throttle.Due()
+			if !due {
+				continue
+			}
+			metrics.FramesCaptured.Inc()
+			select {
+			case r.imageEvents <- image.Event{Image: r.image(depth, color)}:
+				throttle.Used(now)
+			default:
+				metrics.FramesDropped.Inc()
+				r.logger.Logf(edgeimpulse.LogDebug, "dropping image, classifier still busy")
+			}
+		}
+	}()
+
+	return r, nil
+}
+
+// image returns the image for the mode from raw frames of the streams.
+func (r *Recorder) image(depth, color []byte) goimage.Image {
+	var depths []uint16
+	if depth != nil {
+		depths = make([]uint16, len(depth)/2)
+		for i := range depths {
+			depths[i] = binary.LittleEndian.Uint16(depth[2*i:])
+		}
+	}
+	ds := r.opts.DepthSize
+	if r.opts.Mode == ModeDepth {
+		return Colorize(depths, ds.Width, ds.Height, r.opts.Min, r.opts.Max)
+	}
+
+	cs := r.opts.ColorSize
+	img := goimage.NewRGBA(goimage.Rect(0, 0, cs.Width, cs.Height))
+	for i := 0; i < cs.Width*cs.Height; i++ {
+		copy(img.Pix[4*i:], color[3*i:3*i+3])
+		img.Pix[4*i+3] = 0xff
+	}
+	if r.opts.Mode == ModeColor {
+		return img
+	}
+	return &RGBD{img, Align(depths, ds.Width, ds.Height, r.opts.Align, cs)}
+}
+
+// start starts ffmpeg for a stream, returning a channel with the latest frame
+// of size with bpp bytes per pixel in pixel format pixFmt.
+func (r *Recorder) start(ctx context.Context, device string, size Size, bpp int, pixFmt string) (chan rawFrame, error) {
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-f", "v4l2",
+		"-video_size", size.String(),
+		"-framerate", fmt.Sprintf("%d", r.opts.Framerate),
+		"-i", device,
+		"-f", "rawvideo",
+		"-pix_fmt", pixFmt,
+		"-",
+	}
+	r.logger.Logf(edgeimpulse.LogDebug, "starting ffmpeg with args %s", args)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if r.opts.Verbose {
+		cmd.Stderr = os.Stderr
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			err = errInstallHint
+		}
+		return nil, fmt.Errorf("starting command ffmpeg: %w", err)
+	}
+
+	// Only the latest frame is kept, older frames are not useful.
+	frames := make(chan rawFrame, 1)
+	go func() {
+		defer cmd.Wait()
+		br := bufio.NewReaderSize(stdout, size.Width*size.Height*bpp)
+		for {
+			buf := make([]byte, size.Width*size.Height*bpp)
+			if _, err := io.ReadFull(br, buf); err != nil {
+				if ctx.Err() == nil {
+					select {
+					case frames <- rawFrame{err: err}:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			select {
+			case frames <- rawFrame{buf: buf}:
+			default:
+				select {
+				case <-frames:
+				default:
+				}
+				frames <- rawFrame{buf: buf}
+			}
+		}
+	}()
+	return frames, nil
+}
+
+func (r *Recorder) sendErr(ctx context.Context, err error) {
+	select {
+	case r.imageEvents <- image.Event{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// Events returns a channel on which Events can be received.
+func (r *Recorder) Events() chan image.Event {
+	return r.imageEvents
+}
+
+// Close shuts down the recorder, stopping ffmpeg.
+func (r *Recorder) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
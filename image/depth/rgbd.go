@@ -0,0 +1,48 @@
+package depth
+
+import (
+	goimage "image"
+)
+
+// RGBD is a color image with the depth of each pixel. As image.Image, it is
+// the color image, so it can be classified by image models, while the depth
+// can be used to find the distance of detected objects.
+type RGBD struct {
+	*goimage.RGBA
+
+	// Depths in mm, row-major, one for each pixel of the color image. Pixels
+	// without depth have value 0.
+	Depth []uint16
+}
+
+// DepthAt returns the depth in mm of the pixel at x, y, or 0 if unknown.
+func (m *RGBD) DepthAt(x, y int) uint16 {
+	p := goimage.Pt(x, y)
+	if !p.In(m.Rect) {
+		return 0
+	}
+	b := m.Rect
+	return m.Depth[(y-b.Min.Y)*b.Dx()+(x-b.Min.X)]
+}
+
+// Align returns depths of width by height resampled to size, taking the
+// nearest depth from region r of the depth frame. If r is empty, the whole
+// frame is used.
+func Align(depths []uint16, width, height int, r goimage.Rectangle, size Size) []uint16 {
+	if r.Empty() {
+		r = goimage.Rect(0, 0, width, height)
+	}
+	r = r.Intersect(goimage.Rect(0, 0, width, height))
+	out := make([]uint16, size.Width*size.Height)
+	if r.Empty() {
+		return out
+	}
+	for y := 0; y < size.Height; y++ {
+		sy := r.Min.Y + y*r.Dy()/size.Height
+		for x := 0; x < size.Width; x++ {
+			sx := r.Min.X + x*r.Dx()/size.Width
+			out[y*size.Width+x] = depths[sy*width+sx]
+		}
+	}
+	return out
+}
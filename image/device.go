@@ -1,5 +1,15 @@
 package image
 
+import (
+	"errors"
+)
+
+// Errors returned by recorders, for use with errors.Is.
+var (
+	ErrNoDevices      = errors.New("no devices available")
+	ErrDeviceNotFound = errors.New("device not found")
+)
+
 // DeviceCap describes a capability of a device.
 type DeviceCap struct {
 	Width     int
@@ -5,35 +5,88 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"image/jpeg"
-	"log"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
-	"github.com/edgeimpulse/linux-sdk-go/image"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-var errInstallHint = errors.New("executable not found, install with: sudo apt install -y ffmpeg v4l-utils")
+var errInstallHint = fmt.Errorf("%w, install with: sudo apt install -y ffmpeg v4l-utils", exec.ErrNotFound)
 
 // RecorderOpts has options for a new ffmpeg recorder.
 type RecorderOpts struct {
 	Verbose  bool
-	Interval time.Duration // How often to record an image.
+	Interval time.Duration // How often to record an image. Defaults to a second.
 	DeviceID string        // As retrieved from ListDevices. If empty, NewRecorder will use the first device returned by ListDevices.
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// Used for throttling to Interval. If nil, edgeimpulse.SystemClock is
+	// used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts RecorderOpts) apply(o *RecorderOpts) {
+	*o = opts
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithInterval sets RecorderOpts.Interval.
+func WithInterval(interval time.Duration) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Interval = interval })
+}
+
+// WithDevice sets RecorderOpts.DeviceID.
+func WithDevice(id string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.DeviceID = id })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
 }
 
 // Recorder is an image recorder using ffmpeg.
 type Recorder struct {
 	opts        RecorderOpts
+	logger      edgeimpulse.Logger
 	imageEvents chan image.Event
 	tempDir     string
 	cancel      context.CancelFunc
 	watcher     *fsnotify.Watcher
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
 }
 
 // Check that Recorder implements interface Recorder.
@@ -44,6 +97,21 @@ func (r *Recorder) Events() chan image.Event {
 	return r.imageEvents
 }
 
+func init() {
+	image.Register(image.Backend{
+		Name:         "ffmpeg",
+		Priority:     1,
+		DeviceLister: image.DeviceListerFunc(ListDevices),
+		NewRecorder: func(ctx context.Context, opts image.BackendOpts) (image.Recorder, error) {
+			r, err := NewRecorder(ctx, RecorderOpts(opts))
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+	})
+}
+
 // ListDevices returns a list of devices that can be used for recording.
 // ListDevices returns an error if no devices are available.
 func ListDevices() ([]image.Device, error) {
@@ -53,7 +121,7 @@ func ListDevices() ([]image.Device, error) {
 		if errors.Is(err, exec.ErrNotFound) {
 			err = errInstallHint
 		}
-		return nil, fmt.Errorf("listing devices using v4l2-ctl: %v", err)
+		return nil, fmt.Errorf("listing devices using v4l2-ctl: %w", err)
 	}
 	var curDevice string
 	devices := []image.Device{}
@@ -74,7 +142,7 @@ func ListDevices() ([]image.Device, error) {
 		devices = append(devices, dev)
 	}
 	if len(devices) == 0 {
-		return nil, fmt.Errorf("no devices available")
+		return nil, image.ErrNoDevices
 	}
 	return devices, nil
 }
@@ -83,17 +151,30 @@ func ListDevices() ([]image.Device, error) {
 // temporary directory. These files are read and sent over the channel returned
 // by Events.
 //
-// Callers must call Close to clean up.
-func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
+// Callers must call Close to clean up. Canceling ctx also stops the recorder
+// and cleans up.
+func NewRecorder(ctx context.Context, opts ...Option) (recorder *Recorder, rerr error) {
 	r := &Recorder{}
-	r.opts = opts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if r.opts.Interval <= 0 {
+		r.opts.Interval = time.Second
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
 
 	if r.opts.DeviceID == "" {
 		devs, err := ListDevices()
 		if err != nil {
-			return nil, fmt.Errorf("listing devices: %v", err)
+			return nil, fmt.Errorf("listing devices: %w", err)
 		}
-		r.opts.DeviceID = devs[0].ID
+		dev, err := image.SelectDevice(devs)
+		if err != nil {
+			return nil, err
+		}
+		r.opts.DeviceID = dev.ID
 	}
 
 	// Ensure cleanup in case of failure.
@@ -105,12 +186,10 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 
 	tempDir, err := edgeimpulse.TempDir()
 	if err != nil {
-		return nil, fmt.Errorf("making temp dir: %v", err)
+		return nil, fmt.Errorf("making temp dir: %w", err)
 	}
 	r.tempDir = tempDir
-	if r.opts.Verbose {
-		log.Printf("ffmpegrecorder, writing images to tempdir %s", r.tempDir)
-	}
+	r.logger.Logf(edgeimpulse.LogDebug, "ffmpegrecorder, writing images to tempdir %s", r.tempDir)
 
 	args := []string{
 		"-framerate", fmt.Sprintf("%d", int(time.Second/r.opts.Interval)),
@@ -124,11 +203,9 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 		"test%d.jpg",
 	}
 
-	if r.opts.Verbose {
-		log.Printf("starting ffmpeg with args %s", args)
-	}
+	r.logger.Logf(edgeimpulse.LogDebug, "starting ffmpeg with args %s", args)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	r.cancel = cancel
 	ffmpeg := exec.CommandContext(ctx, "ffmpeg", args...)
 	ffmpeg.Dir = r.tempDir
@@ -140,7 +217,7 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 		if errors.Is(err, exec.ErrNotFound) {
 			err = errInstallHint
 		}
-		return nil, fmt.Errorf("starting command ffmpeg: %v", err)
+		return nil, fmt.Errorf("starting command ffmpeg: %w", err)
 	}
 	go ffmpeg.Wait()
 
@@ -148,20 +225,35 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, fmt.Errorf("new file change watcher: %v", err)
+		return nil, fmt.Errorf("new file change watcher: %w", err)
 	}
 	r.watcher = watcher
 
 	logf := func(format string, args ...interface{}) {
-		if r.opts.Verbose {
-			log.Printf(format, args...)
-		}
+		r.logger.Logf(edgeimpulse.LogDebug, format, args...)
 	}
 
 	go func() {
-		var last time.Time
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				select {
+				case r.imageEvents <- image.Event{Err: err}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+
+		throttle := image.NewThrottle(r.opts.Interval, r.opts.Clock)
 		for {
 			select {
+			case <-ctx.Done():
+				// Canceled, by the caller or by Close.
+				r.Close()
+				return
 			case ev, ok := <-watcher.Events:
 				if !ok {
 					return
@@ -169,10 +261,10 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 				if ev.Op != fsnotify.Write || !strings.HasSuffix(ev.Name, ".jpg") {
 					continue
 				}
-				now := time.Now()
-				if now.Sub(last) < r.opts.Interval*9/10 {
-					if err := os.Remove(ev.Name); err != nil && r.opts.Verbose {
-						log.Printf("removing skipped image %q: %v", ev.Name, err)
+				now, due := throttle.Due()
+				if !due {
+					if err := os.Remove(ev.Name); err != nil {
+						r.logger.Logf(edgeimpulse.LogDebug, "removing skipped image %q: %v", ev.Name, err)
 					}
 					continue
 				}
@@ -181,35 +273,38 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 					logf("open written file %q: %v", ev.Name, err)
 					continue
 				}
-				img, err := jpeg.Decode(f)
+				img, err := image.DecodeJPEG(f)
 				f.Close()
 				if err != nil {
 					logf("decoding jpeg %q: %v (may be partially written)", ev.Name, err)
 					continue
 				}
-				if err := os.Remove(ev.Name); err != nil && r.opts.Verbose {
-					log.Printf("removing image %s: %v", ev.Name, err)
+				if err := os.Remove(ev.Name); err != nil {
+					r.logger.Logf(edgeimpulse.LogDebug, "removing image %s: %v", ev.Name, err)
 				}
+				metrics.FramesCaptured.Inc()
 				select {
 				case r.imageEvents <- image.Event{Image: img}:
-					last = now
+					throttle.Used(now)
 				default:
-					if r.opts.Verbose {
-						log.Printf("dropping image, classifier still busy")
-					}
+					metrics.FramesDropped.Inc()
+					r.logger.Logf(edgeimpulse.LogDebug, "dropping image, classifier still busy")
 				}
 
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
-				r.imageEvents <- image.Event{Err: fmt.Errorf("watching for changes: %v", err)}
+				select {
+				case r.imageEvents <- image.Event{Err: fmt.Errorf("watching for changes: %w", err)}:
+				case <-ctx.Done():
+				}
 			}
 		}
 	}()
 
 	if err := watcher.Add(r.tempDir); err != nil {
-		return nil, fmt.Errorf("registering file change watcher for temp dir: %v", err)
+		return nil, fmt.Errorf("registering file change watcher for temp dir: %w", err)
 	}
 
 	return r, nil
@@ -229,3 +324,11 @@ func (r *Recorder) Close() error {
 	}
 	return nil
 }
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
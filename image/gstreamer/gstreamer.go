@@ -7,38 +7,107 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"image/jpeg"
-	"log"
+	goimage "image"
 	"os"
 	"os/exec"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
-	"github.com/edgeimpulse/linux-sdk-go/image"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-var errInstallHint = errors.New("executable not found, install with: sudo apt install -y gstreamer1.0-tools gstreamer1.0-plugins-good gstreamer1.0-plugins-base gstreamer1.0-plugins-base-apps")
+var errInstallHint = fmt.Errorf("%w, install with: sudo apt install -y gstreamer1.0-tools gstreamer1.0-plugins-good gstreamer1.0-plugins-base gstreamer1.0-plugins-base-apps", exec.ErrNotFound)
+
+var errShmNotSupported = errors.New("shared memory frames are only supported on linux")
 
 // RecorderOpts has options for a new gstreamer recorder.
 type RecorderOpts struct {
 	Verbose  bool
-	Interval time.Duration // How often to record an image.
+	Interval time.Duration // How often to record an image. Defaults to a second.
 	DeviceID string        // As retrieved from ListDevices. If empty, NewRecorder will use the first device returned by ListDevices.
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// Used for throttling to Interval. If nil, edgeimpulse.SystemClock is
+	// used.
+	Clock edgeimpulse.Clock
+
+	// Receive raw RGBA frames from gstreamer's shmsink through shared memory,
+	// instead of JPEG files in a temporary directory. This avoids encoding,
+	// decoding and file I/O for each frame, for high frame rates on small
+	// devices. Frames are copied once out of shared memory. Linux only, and
+	// requires the shm plugin from gstreamer1.0-plugins-bad.
+	SharedMemory bool
+}
+
+// Option configures a recorder created with NewRecorder. A RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts RecorderOpts) apply(o *RecorderOpts) {
+	*o = opts
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithInterval sets RecorderOpts.Interval.
+func WithInterval(interval time.Duration) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Interval = interval })
+}
+
+// WithDevice sets RecorderOpts.DeviceID.
+func WithDevice(id string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.DeviceID = id })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// WithSharedMemory sets RecorderOpts.SharedMemory.
+func WithSharedMemory(shm bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.SharedMemory = shm })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
 }
 
 // Recorder is an image recorder using gstreamer.
 type Recorder struct {
 	opts        RecorderOpts
+	logger      edgeimpulse.Logger
 	imageEvents chan image.Event
 	tempDir     string
 	cancel      context.CancelFunc
 	watcher     *fsnotify.Watcher
+	shm         *shmClient
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
 }
 
 // Check that Recorder implements interface Recorder.
@@ -69,6 +138,46 @@ func abs(a int) int {
 	return a
 }
 
+var defaults struct {
+	sync.Mutex
+	opts RecorderOpts
+}
+
+// SetDefaults sets the options for recorders of the "gstreamer" backend, e.g.
+// SharedMemory, before the options of the backend.
+func SetDefaults(opts ...Option) {
+	defaults.Lock()
+	defer defaults.Unlock()
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&defaults.opts)
+		}
+	}
+}
+
+func init() {
+	image.Register(image.Backend{
+		Name:         "gstreamer",
+		Priority:     2,
+		DeviceLister: image.DeviceListerFunc(ListDevices),
+		NewRecorder: func(ctx context.Context, opts image.BackendOpts) (image.Recorder, error) {
+			defaults.Lock()
+			xopts := defaults.opts
+			defaults.Unlock()
+			xopts.Verbose = opts.Verbose
+			xopts.Interval = opts.Interval
+			xopts.DeviceID = opts.DeviceID
+			xopts.Logger = opts.Logger
+			xopts.Clock = opts.Clock
+			r, err := NewRecorder(ctx, xopts)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+	})
+}
+
 // ListDevices returns a list of devices that can be used for recording.
 // ListDevices returns an error if no devices are available.
 func ListDevices() ([]image.Device, error) {
@@ -78,7 +187,7 @@ func ListDevices() ([]image.Device, error) {
 		if errors.Is(err, exec.ErrNotFound) {
 			err = errInstallHint
 		}
-		return nil, fmt.Errorf("listing devices using gst-device-monitor-1.0: %v", err)
+		return nil, fmt.Errorf("listing devices using gst-device-monitor-1.0: %w", err)
 	}
 
 	var r []device
@@ -182,28 +291,40 @@ func ListDevices() ([]image.Device, error) {
 		})
 	}
 	if len(devs) == 0 {
-		return nil, fmt.Errorf("no devices found")
+		return nil, image.ErrNoDevices
 	}
 
 	return devs, nil
 }
 
 // NewRecorder creates a new recorder using gstream. Gstreamer writes images to a
-// temporary directory. These files are read and sent over the channel returned
-// by Events.
+// temporary directory, or with SharedMemory passes frames through shared
+// memory. Images are sent over the channel returned by Events.
 //
-// Callers must call Close to clean up.
-func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
+// Callers must call Close to clean up. Canceling ctx also stops the recorder
+// and cleans up.
+func NewRecorder(ctx context.Context, opts ...Option) (recorder *Recorder, rerr error) {
 	r := &Recorder{}
-	r.opts = opts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if r.opts.Interval <= 0 {
+		r.opts.Interval = time.Second
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
 
 	devices, err := ListDevices()
 	if err != nil {
-		return nil, fmt.Errorf("listing devices: %v", err)
+		return nil, fmt.Errorf("listing devices: %w", err)
 	}
 	var dev image.Device
 	if r.opts.DeviceID == "" {
-		dev = devices[0]
+		dev, err = image.SelectDevice(devices)
+		if err != nil {
+			return nil, err
+		}
 		r.opts.DeviceID = dev.ID
 	} else {
 		for _, d := range devices {
@@ -213,7 +334,7 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 			}
 		}
 		if dev.ID == "" {
-			return nil, fmt.Errorf("device not found")
+			return nil, fmt.Errorf("%w: %q", image.ErrDeviceNotFound, r.opts.DeviceID)
 		}
 	}
 
@@ -226,33 +347,50 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 
 	tempDir, err := edgeimpulse.TempDir()
 	if err != nil {
-		return nil, fmt.Errorf("making temp dir: %v", err)
+		return nil, fmt.Errorf("making temp dir: %w", err)
 	}
 	r.tempDir = tempDir
-	if r.opts.Verbose {
-		log.Printf("gstreamer recorder, writing images to tempdir %s", r.tempDir)
-	}
+	r.logger.Logf(edgeimpulse.LogDebug, "gstreamer recorder, writing images to tempdir %s", r.tempDir)
 
+	width, height := dev.Caps[0].Width, dev.Caps[0].Height
 	args := []string{
 		"v4l2src",
 		"device=" + r.opts.DeviceID,
 		// "num-buffers=999999999",
 		"!",
-		fmt.Sprintf("video/x-raw,width=%d,height=%d", dev.Caps[0].Width, dev.Caps[0].Height),
+		fmt.Sprintf("video/x-raw,width=%d,height=%d", width, height),
 		"!",
 		"videoconvert",
 		"!",
-		"jpegenc",
-		"!",
-		"multifilesink",
-		"location=" + r.tempDir + "/test%05d.jpg",
 	}
-
-	if r.opts.Verbose {
-		log.Printf("starting gstreamer as gst-launch-1.0 %s", strings.Join(args, " "))
+	socketPath := r.tempDir + "/frames.sock"
+	if r.opts.SharedMemory {
+		if !shmSupported {
+			return nil, errShmNotSupported
+		}
+		args = append(args,
+			"video/x-raw,format=RGBA",
+			"!",
+			"shmsink",
+			"socket-path="+socketPath,
+			// Room for a few frames, so gstreamer can continue while we
+			// copy a frame.
+			fmt.Sprintf("shm-size=%d", 4*width*height*4),
+			"wait-for-connection=true",
+			"sync=false",
+		)
+	} else {
+		args = append(args,
+			"jpegenc",
+			"!",
+			"multifilesink",
+			"location="+r.tempDir+"/test%05d.jpg",
+		)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	r.logger.Logf(edgeimpulse.LogDebug, "starting gstreamer as gst-launch-1.0 %s", strings.Join(args, " "))
+
+	ctx, cancel := context.WithCancel(ctx)
 	r.cancel = cancel
 	cmd := exec.CommandContext(ctx, "gst-launch-1.0", args...)
 	cmd.Dir = r.tempDir
@@ -264,28 +402,44 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 		if errors.Is(err, exec.ErrNotFound) {
 			err = errInstallHint
 		}
-		return nil, fmt.Errorf("starting gstreamer with gst-launch-1.0: %v", err)
+		return nil, fmt.Errorf("starting gstreamer with gst-launch-1.0: %w", err)
 	}
 	go cmd.Wait()
 
 	r.imageEvents = make(chan image.Event)
 
+	if r.opts.SharedMemory {
+		dialCtx, dialCancel := context.WithTimeout(ctx, 10*time.Second)
+		defer dialCancel()
+		shm, err := dialShm(dialCtx, socketPath)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to gstreamer shmsink: %w", err)
+		}
+		r.shm = shm
+		go r.readShm(ctx, width, height)
+		return r, nil
+	}
+
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, fmt.Errorf("new file change watcher: %v", err)
+		return nil, fmt.Errorf("new file change watcher: %w", err)
 	}
 	r.watcher = watcher
 
 	logf := func(format string, args ...interface{}) {
-		if r.opts.Verbose {
-			log.Printf(format, args...)
-		}
+		r.logger.Logf(edgeimpulse.LogDebug, format, args...)
 	}
 
 	go func() {
-		var last time.Time
+		defer r.recoverPanic(ctx)
+
+		throttle := image.NewThrottle(r.opts.Interval, r.opts.Clock)
 		for {
 			select {
+			case <-ctx.Done():
+				// Canceled, by the caller or by Close.
+				r.Close()
+				return
 			case ev, ok := <-watcher.Events:
 				if !ok {
 					return
@@ -293,10 +447,10 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 				if ev.Op == fsnotify.Remove || !strings.HasSuffix(ev.Name, ".jpg") {
 					continue
 				}
-				now := time.Now()
-				if now.Sub(last) < r.opts.Interval*9/10 {
-					if err := os.Remove(ev.Name); err != nil && r.opts.Verbose {
-						log.Printf("removing skipped image %q: %v", ev.Name, err)
+				now, due := throttle.Due()
+				if !due {
+					if err := os.Remove(ev.Name); err != nil {
+						r.logger.Logf(edgeimpulse.LogDebug, "removing skipped image %q: %v", ev.Name, err)
 					}
 					continue
 				}
@@ -305,40 +459,101 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 					logf("open written file %q: %v", ev.Name, err)
 					continue
 				}
-				img, err := jpeg.Decode(f)
+				img, err := image.DecodeJPEG(f)
 				f.Close()
 				if err != nil {
 					logf("decoding jpeg %q: %v (may be partially written)", ev.Name, err)
 					continue
 				}
-				if err := os.Remove(ev.Name); err != nil && r.opts.Verbose {
-					log.Printf("removing image %s: %v", ev.Name, err)
+				if err := os.Remove(ev.Name); err != nil {
+					r.logger.Logf(edgeimpulse.LogDebug, "removing image %s: %v", ev.Name, err)
 				}
+				metrics.FramesCaptured.Inc()
 				select {
 				case r.imageEvents <- image.Event{Image: img}:
-					last = now
+					throttle.Used(now)
 				default:
-					if r.opts.Verbose {
-						log.Printf("dropping image, classifier still busy")
-					}
+					metrics.FramesDropped.Inc()
+					r.logger.Logf(edgeimpulse.LogDebug, "dropping image, classifier still busy")
 				}
 
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
-				r.imageEvents <- image.Event{Err: fmt.Errorf("watching for changes: %v", err)}
+				select {
+				case r.imageEvents <- image.Event{Err: fmt.Errorf("watching for changes: %w", err)}:
+				case <-ctx.Done():
+				}
 			}
 		}
 	}()
 
 	if err := watcher.Add(r.tempDir); err != nil {
-		return nil, fmt.Errorf("registering file change watcher for temp dir: %v", err)
+		return nil, fmt.Errorf("registering file change watcher for temp dir: %w", err)
 	}
 
 	return r, nil
 }
 
+// readShm sends frames read from shmsink on the events channel, until the
+// connection fails, e.g. because gstreamer stopped.
+func (r *Recorder) readShm(ctx context.Context, width, height int) {
+	defer r.recoverPanic(ctx)
+	defer r.shm.unmap()
+
+	throttle := image.NewThrottle(r.opts.Interval, r.opts.Clock)
+	for {
+		buf, ack, err := r.shm.next()
+		if err != nil {
+			if ctx.Err() == nil {
+				select {
+				case r.imageEvents <- image.Event{Err: err}:
+				case <-ctx.Done():
+				}
+			}
+			r.Close()
+			return
+		}
+		var img *goimage.RGBA
+		now, due := throttle.Due()
+		if due && len(buf) >= width*height*4 {
+			img = goimage.NewRGBA(goimage.Rect(0, 0, width, height))
+			copy(img.Pix, buf)
+		}
+		// Gstreamer reuses the buffer after the ack, so only after copying.
+		if err := ack(); err != nil {
+			r.logger.Logf(edgeimpulse.LogDebug, "acknowledging shm buffer: %v", err)
+		}
+		if img == nil {
+			continue
+		}
+		metrics.FramesCaptured.Inc()
+		select {
+		case r.imageEvents <- image.Event{Image: img}:
+			throttle.Used(now)
+		default:
+			metrics.FramesDropped.Inc()
+			r.logger.Logf(edgeimpulse.LogDebug, "dropping image, classifier still busy")
+		}
+	}
+}
+
+// recoverPanic stores and sends the error for a panic in a goroutine of the
+// recorder. It must be deferred.
+func (r *Recorder) recoverPanic(ctx context.Context) {
+	if x := recover(); x != nil {
+		err := edgeimpulse.PanicError(x)
+		r.mutex.Lock()
+		r.err = err
+		r.mutex.Unlock()
+		select {
+		case r.imageEvents <- image.Event{Err: err}:
+		case <-ctx.Done():
+		}
+	}
+}
+
 // Close shuts down the recorder, stopping gstreamer and removing the temporary
 // directory.
 func (r *Recorder) Close() error {
@@ -348,8 +563,19 @@ func (r *Recorder) Close() error {
 	if r.watcher != nil {
 		r.watcher.Close()
 	}
+	if r.shm != nil {
+		r.shm.Close()
+	}
 	if r.tempDir != "" {
 		os.RemoveAll(r.tempDir)
 	}
 	return nil
 }
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
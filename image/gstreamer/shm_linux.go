@@ -0,0 +1,159 @@
+//go:build linux
+// +build linux
+
+package gstreamer
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const shmSupported = true
+
+// Commands of the shmpipe protocol spoken by gstreamer's shmsink, from
+// sys/shm/shmpipe.c in gst-plugins-bad.
+const (
+	shmNewArea   = 1
+	shmCloseArea = 2
+	shmNewBuffer = 3
+	shmAckBuffer = 4
+)
+
+// shmWord is the size of the size_t and unsigned long fields of commands.
+const shmWord = int(unsafe.Sizeof(uintptr(0)))
+
+// shmCommandSize is the size of struct CommandBuffer: type and area id,
+// followed by a union of at most 3 words.
+const shmCommandSize = 8 + 3*shmWord
+
+// shmClient reads buffers from a shmsink. Buffers are in shared memory areas
+// mapped into our process, so frames are not encoded or written to files.
+type shmClient struct {
+	conn  net.Conn
+	areas map[int32][]byte
+}
+
+// dialShm connects to the shmsink listening on the unix socket at path,
+// waiting for it to be created.
+func dialShm(ctx context.Context, path string) (*shmClient, error) {
+	for {
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			return &shmClient{conn: conn, areas: map[int32][]byte{}}, nil
+		}
+		if !errors.Is(err, syscall.ENOENT) && !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, fmt.Errorf("connecting to shmsink: %w", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// word reads the n-th word of the union of a command. Commands are in host
+// byte order, little endian on the platforms we support.
+func word(cmd []byte, n int) uint64 {
+	o := 8 + n*shmWord
+	if shmWord == 8 {
+		return binary.LittleEndian.Uint64(cmd[o:])
+	}
+	return uint64(binary.LittleEndian.Uint32(cmd[o:]))
+}
+
+func putWord(cmd []byte, n int, v uint64) {
+	o := 8 + n*shmWord
+	if shmWord == 8 {
+		binary.LittleEndian.PutUint64(cmd[o:], v)
+	} else {
+		binary.LittleEndian.PutUint32(cmd[o:], uint32(v))
+	}
+}
+
+// next returns the next buffer from shmsink, handling area commands. The
+// buffer is only valid until it is acknowledged with ack.
+func (c *shmClient) next() (buf []byte, ack func() error, err error) {
+	cmd := make([]byte, shmCommandSize)
+	for {
+		if _, err := io.ReadFull(c.conn, cmd); err != nil {
+			return nil, nil, fmt.Errorf("reading shmsink command: %w", err)
+		}
+		typ := binary.LittleEndian.Uint32(cmd[0:])
+		id := int32(binary.LittleEndian.Uint32(cmd[4:]))
+		switch typ {
+		case shmNewArea:
+			size := int(word(cmd, 0))
+			// Path size follows the size_t, and includes the trailing NUL.
+			pathSize := binary.LittleEndian.Uint32(cmd[8+shmWord:])
+			path := make([]byte, pathSize)
+			if _, err := io.ReadFull(c.conn, path); err != nil {
+				return nil, nil, fmt.Errorf("reading shm area path: %w", err)
+			}
+			if n := len(path); n > 0 && path[n-1] == 0 {
+				path = path[:n-1]
+			}
+			area, err := mapArea("/dev/shm"+string(path), size)
+			if err != nil {
+				return nil, nil, err
+			}
+			c.areas[id] = area
+		case shmCloseArea:
+			if area, ok := c.areas[id]; ok {
+				syscall.Munmap(area)
+				delete(c.areas, id)
+			}
+		case shmNewBuffer:
+			area, ok := c.areas[id]
+			offset, size := word(cmd, 0), word(cmd, 1)
+			if !ok || offset+size > uint64(len(area)) {
+				return nil, nil, fmt.Errorf("buffer outside shm area %d", id)
+			}
+			ack := func() error {
+				reply := make([]byte, shmCommandSize)
+				binary.LittleEndian.PutUint32(reply[0:], shmAckBuffer)
+				binary.LittleEndian.PutUint32(reply[4:], uint32(id))
+				putWord(reply, 0, offset)
+				_, err := c.conn.Write(reply)
+				return err
+			}
+			return area[offset : offset+size], ack, nil
+		}
+	}
+}
+
+// mapArea maps the shared memory area at path, read-only.
+func mapArea(path string, size int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening shm area: %w", err)
+	}
+	defer f.Close()
+	area, err := syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mapping shm area: %w", err)
+	}
+	return area, nil
+}
+
+// Close disconnects from shmsink, making a pending next return an error.
+func (c *shmClient) Close() error {
+	return c.conn.Close()
+}
+
+// unmap unmaps all areas. Only to be called by the goroutine calling next,
+// after it is done with the buffers.
+func (c *shmClient) unmap() {
+	for id, area := range c.areas {
+		syscall.Munmap(area)
+		delete(c.areas, id)
+	}
+}
@@ -0,0 +1,65 @@
+//go:build linux
+// +build linux
+
+package gstreamer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+)
+
+func TestShmClient(t *testing.T) {
+	name := fmt.Sprintf("/shmpipe-test.%d", os.Getpid())
+	pixels := []byte("0123456789abcdef")
+	if err := os.WriteFile("/dev/shm"+name, pixels, 0600); err != nil {
+		t.Skipf("no /dev/shm: %v", err)
+	}
+	defer os.Remove("/dev/shm" + name)
+
+	server, conn := net.Pipe()
+	c := &shmClient{conn: conn, areas: map[int32][]byte{}}
+	defer c.Close()
+
+	go func() {
+		cmd := make([]byte, shmCommandSize)
+		binary.LittleEndian.PutUint32(cmd[0:], shmNewArea)
+		binary.LittleEndian.PutUint32(cmd[4:], 7)
+		putWord(cmd, 0, uint64(len(pixels)))
+		binary.LittleEndian.PutUint32(cmd[8+shmWord:], uint32(len(name)+1))
+		server.Write(cmd)
+		server.Write(append([]byte(name), 0))
+
+		cmd = make([]byte, shmCommandSize)
+		binary.LittleEndian.PutUint32(cmd[0:], shmNewBuffer)
+		binary.LittleEndian.PutUint32(cmd[4:], 7)
+		putWord(cmd, 0, 4)
+		putWord(cmd, 1, 8)
+		server.Write(cmd)
+	}()
+
+	buf, ack, err := c.next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if !bytes.Equal(buf, pixels[4:12]) {
+		t.Fatalf("got buffer %q, expected %q", buf, pixels[4:12])
+	}
+
+	go func() {
+		if err := ack(); err != nil {
+			t.Errorf("ack: %v", err)
+		}
+	}()
+	reply := make([]byte, shmCommandSize)
+	if _, err := server.Read(reply); err != nil {
+		t.Fatalf("reading ack: %v", err)
+	}
+	if typ, id, offset := binary.LittleEndian.Uint32(reply), binary.LittleEndian.Uint32(reply[4:]), word(reply, 0); typ != shmAckBuffer || id != 7 || offset != 4 {
+		t.Fatalf("got ack %d for area %d offset %d, expected %d for 7 offset 4", typ, id, offset, shmAckBuffer)
+	}
+	c.unmap()
+}
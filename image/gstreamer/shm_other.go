@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package gstreamer
+
+import (
+	"context"
+)
+
+const shmSupported = false
+
+// shmClient is only implemented on linux.
+type shmClient struct{}
+
+func dialShm(ctx context.Context, path string) (*shmClient, error) {
+	return nil, errShmNotSupported
+}
+
+func (c *shmClient) next() (buf []byte, ack func() error, err error) {
+	return nil, nil, errShmNotSupported
+}
+
+func (c *shmClient) Close() error {
+	return nil
+}
+
+func (c *shmClient) unmap() {}
@@ -5,26 +5,46 @@ package imagesnap
 import (
 	"context"
 	"fmt"
-	"image/jpeg"
-	"log"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
-	"github.com/edgeimpulse/linux-sdk-go/image"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
 
 	"github.com/fsnotify/fsnotify"
 )
 
+func init() {
+	image.Register(image.Backend{
+		Name:         "imagesnap",
+		Priority:     2,
+		DeviceLister: image.DeviceListerFunc(ListDevices),
+		NewRecorder: func(ctx context.Context, opts image.BackendOpts) (image.Recorder, error) {
+			r, err := NewRecorder(ctx, RecorderOpts{
+				Verbose:  opts.Verbose,
+				Interval: opts.Interval,
+				DeviceID: opts.DeviceID,
+				Logger:   opts.Logger,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+	})
+}
+
 // ListDevices returns all image capturing devices available to imagesnap.
 // ListDevices returns an error if no devices are available.
 func ListDevices() ([]image.Device, error) {
 	cmd := exec.Command("imagesnap", "-l")
 	buf, err := cmd.Output()
 	if err != nil {
-		return nil, fmt.Errorf("listing devices with imagesnap -l: %v", err)
+		return nil, fmt.Errorf("listing devices with imagesnap -l: %w", err)
 	}
 	return parseDevices(string(buf))
 }
@@ -50,7 +70,7 @@ func parseDevices(s string) ([]image.Device, error) {
 		}
 	}
 	if len(devs) == 0 {
-		return nil, fmt.Errorf("no devices available")
+		return nil, image.ErrNoDevices
 	}
 	return devs, nil
 }
@@ -58,17 +78,61 @@ func parseDevices(s string) ([]image.Device, error) {
 // RecorderOpts has options for a new imagesnap recorder.
 type RecorderOpts struct {
 	Verbose  bool
-	Interval time.Duration // How often to record an image.
+	Interval time.Duration // How often to record an image. Defaults to a second.
 	DeviceID string        // As returned by ListDevices. If empty, NewRecorder will use the first device returned by ListDevices.
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+}
+
+// Option configures a recorder created with NewRecorder. A RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts RecorderOpts) apply(o *RecorderOpts) {
+	*o = opts
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithInterval sets RecorderOpts.Interval.
+func WithInterval(interval time.Duration) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Interval = interval })
+}
+
+// WithDevice sets RecorderOpts.DeviceID.
+func WithDevice(id string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.DeviceID = id })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
 }
 
 // Recorder records images by starting imagesnap and configuring it to write images to temporary storage.
 type Recorder struct {
 	opts        RecorderOpts
+	logger      edgeimpulse.Logger
 	imageEvents chan image.Event
 	tempDir     string
 	cancel      context.CancelFunc
 	watcher     *fsnotify.Watcher
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
 }
 
 // Check that Recorder implements interface Recorder.
@@ -83,17 +147,30 @@ func (r *Recorder) Events() chan image.Event {
 // images to a temporary directory. These images are read and sent on the
 // channel returned by Events.
 //
-// Callers must call Close to clean up.
-func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
+// Callers must call Close to clean up. Canceling ctx also stops the recorder
+// and cleans up.
+func NewRecorder(ctx context.Context, opts ...Option) (recorder *Recorder, rerr error) {
 	r := &Recorder{}
-	r.opts = opts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if r.opts.Interval <= 0 {
+		r.opts.Interval = time.Second
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
 
 	if r.opts.DeviceID == "" {
 		devs, err := ListDevices()
 		if err != nil {
-			return nil, fmt.Errorf("listing devices: %v", err)
+			return nil, fmt.Errorf("listing devices: %w", err)
+		}
+		dev, err := image.SelectDevice(devs)
+		if err != nil {
+			return nil, err
 		}
-		r.opts.DeviceID = devs[0].ID
+		r.opts.DeviceID = dev.ID
 	}
 
 	// Ensure cleanup in case of failure.
@@ -105,23 +182,19 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 
 	tempDir, err := edgeimpulse.TempDir()
 	if err != nil {
-		return nil, fmt.Errorf("making temp dir: %v", err)
+		return nil, fmt.Errorf("making temp dir: %w", err)
 	}
 	r.tempDir = tempDir
-	if r.opts.Verbose {
-		log.Printf("imagesnap recorder, tempdir for images: %s", r.tempDir)
-	}
+	r.logger.Logf(edgeimpulse.LogDebug, "imagesnap recorder, tempdir for images: %s", r.tempDir)
 
 	args := []string{
 		"-d", r.opts.DeviceID,
 		"-t", fmt.Sprintf("%.2f", r.opts.Interval.Seconds()),
 	}
 
-	if r.opts.Verbose {
-		log.Printf("starting imagesnap with args %s", args)
-	}
+	r.logger.Logf(edgeimpulse.LogDebug, "starting imagesnap with args %s", args)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	r.cancel = cancel
 	cmd := exec.CommandContext(ctx, "imagesnap", args...)
 	cmd.Dir = r.tempDir
@@ -130,7 +203,7 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 		cmd.Stderr = os.Stderr
 	}
 	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("starting imagesnap: %v", err)
+		return nil, fmt.Errorf("starting imagesnap: %w", err)
 	}
 	go cmd.Wait()
 
@@ -138,19 +211,34 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, fmt.Errorf("new file change watcher: %v", err)
+		return nil, fmt.Errorf("new file change watcher: %w", err)
 	}
 	r.watcher = watcher
 
 	logf := func(format string, args ...interface{}) {
-		if r.opts.Verbose {
-			log.Printf(format, args...)
-		}
+		r.logger.Logf(edgeimpulse.LogDebug, format, args...)
 	}
 
 	go func() {
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				select {
+				case r.imageEvents <- image.Event{Err: err}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+
 		for {
 			select {
+			case <-ctx.Done():
+				// Canceled, by the caller or by Close.
+				r.Close()
+				return
 			case ev, ok := <-watcher.Events:
 				if !ok {
 					return
@@ -163,34 +251,37 @@ func NewRecorder(opts RecorderOpts) (recorder *Recorder, rerr error) {
 					logf("open written file %q: %v", ev.Name, err)
 					continue
 				}
-				img, err := jpeg.Decode(f)
+				img, err := image.DecodeJPEG(f)
 				f.Close()
 				if err != nil {
 					logf("decoding jpeg %q: %v (perhaps partially written?)", ev.Name, err)
 					continue
 				}
-				if err := os.Remove(ev.Name); err != nil && r.opts.Verbose {
-					log.Printf("removing image %s: %v", ev.Name, err)
+				if err := os.Remove(ev.Name); err != nil {
+					r.logger.Logf(edgeimpulse.LogDebug, "removing image %s: %v", ev.Name, err)
 				}
+				metrics.FramesCaptured.Inc()
 				select {
 				case r.imageEvents <- image.Event{Image: img}:
 				default:
-					if r.opts.Verbose {
-						log.Printf("dropping image, classifier still busy")
-					}
+					metrics.FramesDropped.Inc()
+					r.logger.Logf(edgeimpulse.LogDebug, "dropping image, classifier still busy")
 				}
 
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
-				r.imageEvents <- image.Event{Err: fmt.Errorf("watching for changes: %v", err)}
+				select {
+				case r.imageEvents <- image.Event{Err: fmt.Errorf("watching for changes: %w", err)}:
+				case <-ctx.Done():
+				}
 			}
 		}
 	}()
 
 	if err := watcher.Add(r.tempDir); err != nil {
-		return nil, fmt.Errorf("registering file change watcher for temp dir: %v", err)
+		return nil, fmt.Errorf("registering file change watcher for temp dir: %w", err)
 	}
 
 	return r, nil
@@ -210,3 +301,11 @@ func (r *Recorder) Close() error {
 	}
 	return nil
 }
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
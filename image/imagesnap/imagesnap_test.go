@@ -4,7 +4,7 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/edgeimpulse/linux-sdk-go/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
 )
 
 func TestParseDevices(t *testing.T) {
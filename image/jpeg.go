@@ -0,0 +1,27 @@
+package image
+
+import (
+	"bufio"
+	"image"
+	"image/jpeg"
+	"io"
+	"sync"
+)
+
+// jpegReaders holds buffered readers for decoding, so recorders don't allocate
+// a read buffer for each frame.
+var jpegReaders = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewReaderSize(nil, 64*1024)
+	},
+}
+
+// DecodeJPEG decodes a JPEG image from r, reading through a pooled buffer.
+func DecodeJPEG(r io.Reader) (image.Image, error) {
+	br := jpegReaders.Get().(*bufio.Reader)
+	br.Reset(r)
+	img, err := jpeg.Decode(br)
+	br.Reset(nil)
+	jpegReaders.Put(br)
+	return img, err
+}
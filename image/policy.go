@@ -0,0 +1,158 @@
+package image
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Buses of devices, as returned by DeviceBus.
+const (
+	BusUSB = "usb"
+	BusCSI = "csi" // Camera connected to the SoC, e.g. a Raspberry Pi camera.
+)
+
+// DevicePolicy selects the device to record from when no device ID is given,
+// instead of the first listed device, which on many boards is an infrared or
+// metadata node of a camera.
+type DevicePolicy struct {
+	// IDs of devices to use if present, in order of preference, before any
+	// other device.
+	Fallback []string
+
+	// If set, devices with a matching name are preferred.
+	Name *regexp.Regexp
+
+	// If set, devices on this bus, BusUSB or BusCSI, are preferred after
+	// devices matching Name.
+	Bus string
+}
+
+// ParseDevicePolicy parses a comma-separated list of preferences: "usb" or
+// "csi" for the bus, "name:regexp" for the name, and any other value as a
+// fallback device ID, e.g. "usb,name:Logitech,/dev/video2".
+func ParseDevicePolicy(s string) (DevicePolicy, error) {
+	var p DevicePolicy
+	for _, t := range strings.Split(s, ",") {
+		t = strings.TrimSpace(t)
+		switch {
+		case t == "":
+		case t == BusUSB || t == BusCSI:
+			p.Bus = t
+		case strings.HasPrefix(t, "name:"):
+			re, err := regexp.Compile(strings.TrimPrefix(t, "name:"))
+			if err != nil {
+				return DevicePolicy{}, fmt.Errorf("device name: %w", err)
+			}
+			p.Name = re
+		default:
+			p.Fallback = append(p.Fallback, t)
+		}
+	}
+	return p, nil
+}
+
+// Select returns the index in devs of the preferred device: the first
+// fallback device that is present, or else the first device ordered by
+// matching Name, being on Bus, and being the primary capture node of its
+// camera. Select returns -1 if devs is empty.
+func (p DevicePolicy) Select(devs []Device) int {
+	for _, id := range p.Fallback {
+		for i, d := range devs {
+			if d.ID == id {
+				return i
+			}
+		}
+	}
+	if len(devs) == 0 {
+		return -1
+	}
+	type ranked struct {
+		index, rank int
+	}
+	l := make([]ranked, len(devs))
+	for i, d := range devs {
+		l[i].index = i
+		if p.Name != nil && p.Name.MatchString(d.Name) {
+			l[i].rank += 4
+		}
+		if p.Bus != "" && DeviceBus(d) == p.Bus {
+			l[i].rank += 2
+		}
+		if primaryNode(d) {
+			l[i].rank++
+		}
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].rank > l[j].rank
+	})
+	return l[0].index
+}
+
+// sysfsVideo is where linux describes video devices.
+var sysfsVideo = "/sys/class/video4linux"
+
+// DeviceBus returns BusUSB or BusCSI for the bus the device is connected to,
+// or the empty string if unknown. The bus is looked up in sysfs for linux
+// video devices, or else guessed from the name.
+func DeviceBus(d Device) string {
+	if strings.HasPrefix(d.ID, "/dev/video") {
+		if p, err := filepath.EvalSymlinks(filepath.Join(sysfsVideo, filepath.Base(d.ID), "device")); err == nil {
+			switch {
+			case strings.Contains(p, "/usb"):
+				return BusUSB
+			case strings.Contains(p, "/platform/"):
+				return BusCSI
+			}
+		}
+	}
+	name := strings.ToLower(d.Name)
+	switch {
+	case strings.Contains(name, "usb"):
+		return BusUSB
+	case strings.Contains(name, "csi") || strings.Contains(name, "platform:") || strings.Contains(name, "unicam"):
+		return BusCSI
+	}
+	return ""
+}
+
+// primaryNode returns whether d is the first video node of its camera, as
+// opposed to e.g. a metadata node. Devices without an index in sysfs are
+// assumed primary.
+func primaryNode(d Device) bool {
+	if !strings.HasPrefix(d.ID, "/dev/video") {
+		return true
+	}
+	buf, err := os.ReadFile(filepath.Join(sysfsVideo, filepath.Base(d.ID), "index"))
+	return err != nil || strings.TrimSpace(string(buf)) == "0"
+}
+
+var devicePolicy struct {
+	sync.Mutex
+	p DevicePolicy
+}
+
+// SetDevicePolicy sets the policy used by SelectDevice and FindDevice.
+func SetDevicePolicy(p DevicePolicy) {
+	devicePolicy.Lock()
+	defer devicePolicy.Unlock()
+	devicePolicy.p = p
+}
+
+// SelectDevice returns the device selected from devs by the policy set with
+// SetDevicePolicy, for recorders without a device ID. ErrNoDevices is returned
+// if devs is empty.
+func SelectDevice(devs []Device) (Device, error) {
+	devicePolicy.Lock()
+	p := devicePolicy.p
+	devicePolicy.Unlock()
+	i := p.Select(devs)
+	if i < 0 {
+		return Device{}, ErrNoDevices
+	}
+	return devs[i], nil
+}
@@ -0,0 +1,53 @@
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDevicePolicy(t *testing.T) {
+	// video0 is a csi camera, video1 its metadata node, video2 a usb camera.
+	dir := t.TempDir()
+	sysfsVideo = dir
+	defer func() { sysfsVideo = "/sys/class/video4linux" }()
+	for _, v := range []struct{ node, device, index string }{
+		{"video0", "devices/platform/soc/csi", "0"},
+		{"video1", "devices/platform/soc/csi", "1"},
+		{"video2", "devices/pci0000:00/usb1/1-1", "0"},
+	} {
+		os.MkdirAll(filepath.Join(dir, v.device), 0755)
+		os.MkdirAll(filepath.Join(dir, v.node), 0755)
+		os.Symlink(filepath.Join(dir, v.device), filepath.Join(dir, v.node, "device"))
+		os.WriteFile(filepath.Join(dir, v.node, "index"), []byte(v.index+"\n"), 0644)
+	}
+	devs := []Device{
+		{Name: "unicam meta", ID: "/dev/video1"},
+		{Name: "unicam", ID: "/dev/video0"},
+		{Name: "Logitech C920", ID: "/dev/video2"},
+	}
+
+	tests := []struct {
+		policy string
+		id     string
+	}{
+		{"", "/dev/video0"},
+		{"usb", "/dev/video2"},
+		{"csi", "/dev/video0"},
+		{"name:Logi", "/dev/video2"},
+		{"usb,name:unicam", "/dev/video0"},
+		{"/dev/video5,/dev/video1,usb", "/dev/video1"},
+	}
+	for _, tc := range tests {
+		p, err := ParseDevicePolicy(tc.policy)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", tc.policy, err)
+		}
+		if id := devs[p.Select(devs)].ID; id != tc.id {
+			t.Errorf("policy %q: got %s, expected %s", tc.policy, id, tc.id)
+		}
+	}
+	if i := (DevicePolicy{}).Select(nil); i != -1 {
+		t.Errorf("no devices: got %d, expected -1", i)
+	}
+}
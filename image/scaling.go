@@ -0,0 +1,155 @@
+package image
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Modes of Scaling.
+const (
+	// A value per pixel, with its RGB channels packed as 0xRRGGBB, as model
+	// processes take.
+	ScalingPacked = "packed"
+
+	// A value per channel, from 0 to 1.
+	ScalingUnit = "unit"
+
+	// A value per channel, (v/255 - mean) / std.
+	ScalingNormalize = "normalize"
+)
+
+// Scaling describes the image features sent to a runner. The zero Scaling
+// uses the scaling of the model, see ModelScaling.
+type Scaling struct {
+	Mode string // ScalingPacked, ScalingUnit or ScalingNormalize.
+
+	// Per channel R, G and B for ScalingNormalize. Grayscale images use the
+	// first.
+	Mean, Std [3]float64
+}
+
+// ScalingImageNet normalizes channels with the mean and standard deviation of
+// the ImageNet dataset, as typically needed by networks trained with PyTorch.
+var ScalingImageNet = Scaling{
+	Mode: ScalingNormalize,
+	Mean: [3]float64{0.485, 0.456, 0.406},
+	Std:  [3]float64{0.229, 0.224, 0.225},
+}
+
+// ParseScaling parses a scaling: "packed", "unit", "imagenet", "-1..1", or
+// "normalize:mean:std" with comma-separated R,G,B values or a single value
+// for all channels, e.g. "normalize:0.5:0.5". The empty string is the zero
+// Scaling.
+func ParseScaling(s string) (Scaling, error) {
+	switch s {
+	case "":
+		return Scaling{}, nil
+	case ScalingPacked, ScalingUnit:
+		return Scaling{Mode: s}, nil
+	case "imagenet":
+		return ScalingImageNet, nil
+	case "-1..1":
+		return Scaling{Mode: ScalingNormalize, Mean: [3]float64{0.5, 0.5, 0.5}, Std: [3]float64{0.5, 0.5, 0.5}}, nil
+	}
+	t := strings.Split(s, ":")
+	if len(t) != 3 || t[0] != ScalingNormalize {
+		return Scaling{}, fmt.Errorf("unknown scaling %q, need packed, unit, imagenet, -1..1 or normalize:mean:std", s)
+	}
+	sc := Scaling{Mode: ScalingNormalize}
+	for i, dst := range []*[3]float64{&sc.Mean, &sc.Std} {
+		l := strings.Split(t[1+i], ",")
+		if len(l) != 1 && len(l) != 3 {
+			return Scaling{}, fmt.Errorf("scaling %q: need 1 or 3 values", s)
+		}
+		for c := range dst {
+			v, err := strconv.ParseFloat(l[c%len(l)], 64)
+			if err != nil {
+				return Scaling{}, fmt.Errorf("scaling %q: %w", s, err)
+			}
+			dst[c] = v
+		}
+	}
+	for _, v := range sc.Std {
+		if v == 0 {
+			return Scaling{}, fmt.Errorf("scaling %q: std must not be 0", s)
+		}
+	}
+	return sc, nil
+}
+
+// ModelScaling returns the scaling of ModelParameters.ImageFeatureScaling, or
+// ScalingPacked if it is empty or invalid.
+func ModelScaling(mp edgeimpulse.ModelParameters) Scaling {
+	s, err := ParseScaling(mp.ImageFeatureScaling)
+	if err != nil || s.Mode == "" {
+		return Scaling{Mode: ScalingPacked}
+	}
+	return s
+}
+
+// Len returns the number of features for an image of width by height pixels
+// with channels, 1 or 3.
+func (s Scaling) Len(width, height, channels int) int {
+	if s.Mode == ScalingPacked || s.Mode == "" {
+		return width * height
+	}
+	return width * height * channels
+}
+
+// Features stores the pixels of img in data, scaled according to s, for a
+// model with channels, 1 or 3. Data must have room for all features, see
+// Len. Features for grayscale are the luminance of pixels.
+func (s Scaling) Features(img image.Image, channels int, data []float64) {
+	s.each(img, channels, func(i int, v float64) { data[i] = v })
+}
+
+// Features32 is like Features, for float32 features, see
+// edgeimpulse.Classify32.
+func (s Scaling) Features32(img image.Image, channels int, data []float32) {
+	s.each(img, channels, func(i int, v float64) { data[i] = float32(v) })
+}
+
+// each calls fn with the index and value of each feature of img.
+func (s Scaling) each(img image.Image, channels int, fn func(i int, v float64)) {
+	b := img.Bounds()
+	i := 0
+	if s.Mode == ScalingPacked || s.Mode == "" {
+		// Packed RGB values, the input format of model processes.
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				fn(i, float64((r>>8)<<16|(g>>8)<<8|b>>8))
+				i++
+			}
+		}
+		return
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if channels == 1 {
+				g := color.GrayModel.Convert(img.At(x, y)).(color.Gray)
+				fn(i, s.scale(float64(g.Y)/255, 0))
+				i++
+				continue
+			}
+			r, g, b, _ := img.At(x, y).RGBA()
+			fn(i, s.scale(float64(r>>8)/255, 0))
+			fn(i+1, s.scale(float64(g>>8)/255, 1))
+			fn(i+2, s.scale(float64(b>>8)/255, 2))
+			i += 3
+		}
+	}
+}
+
+// scale returns the scaled value of v, between 0 and 1, for channel c.
+func (s Scaling) scale(v float64, c int) float64 {
+	if s.Mode == ScalingNormalize {
+		return (v - s.Mean[c]) / s.Std[c]
+	}
+	return v
+}
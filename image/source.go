@@ -0,0 +1,60 @@
+package image
+
+import (
+	"context"
+	"io"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Source is an edgeimpulse.PipelineSource classifying the images of a
+// recorder with a Classifier. Results have the prepared image.Image as input.
+type Source struct {
+	recorder Recorder
+	opts     []ClassifierOption
+}
+
+// Ensure Source implements interface edgeimpulse.PipelineSource.
+var _ edgeimpulse.PipelineSource = (*Source)(nil)
+
+// NewSource returns a source for recorder, classifying with opts, which must
+// not set OnResult or OnError. The recorder is closed with the source.
+func NewSource(recorder Recorder, opts ...ClassifierOption) *Source {
+	return &Source{recorder, opts}
+}
+
+// Start starts a classifier with runner.
+func (s *Source) Start(ctx context.Context, runner edgeimpulse.Runner) (<-chan edgeimpulse.PipelineResult, io.Closer, error) {
+	c, err := NewClassifier(ctx, runner, s.recorder, s.opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make(chan edgeimpulse.PipelineResult)
+	go func() {
+		defer close(results)
+		for ev := range c.Events {
+			r := edgeimpulse.PipelineResult{Err: ev.Err, RunnerClassifyResponse: ev.RunnerClassifyResponse, Time: time.Now(), Input: ev.Image}
+			select {
+			case results <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, sourceCloser{s.recorder, c}, nil
+}
+
+// sourceCloser stops capture before the classifier.
+type sourceCloser struct {
+	recorder   Recorder
+	classifier *Classifier
+}
+
+func (c sourceCloser) Close() error {
+	err := c.recorder.Close()
+	if xerr := c.classifier.Close(); xerr != nil && err == nil {
+		err = xerr
+	}
+	return err
+}
@@ -0,0 +1,143 @@
+package thermal
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/frame"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/sensor/lepton"
+)
+
+var defaults struct {
+	sync.Mutex
+	opts RecorderOpts
+}
+
+// SetDefaults sets the options for recorders of the "thermal" backend, e.g.
+// the temperature range and palette, before the options of the backend.
+func SetDefaults(opts ...Option) {
+	defaults.Lock()
+	defer defaults.Unlock()
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&defaults.opts)
+		}
+	}
+}
+
+func init() {
+	image.Register(image.Backend{
+		Name: "thermal",
+		// Any spidev device is listed as a possible Lepton, so cameras are
+		// preferred.
+		Priority:     -1,
+		DeviceLister: image.DeviceListerFunc(ListDevices),
+		NewRecorder: func(ctx context.Context, opts image.BackendOpts) (image.Recorder, error) {
+			defaults.Lock()
+			xopts := defaults.opts
+			defaults.Unlock()
+			xopts.Verbose = opts.Verbose
+			xopts.Interval = opts.Interval
+			xopts.Logger = opts.Logger
+			xopts.Clock = opts.Clock
+			r, err := Open(ctx, opts.DeviceID, xopts)
+			if err != nil {
+				return nil, err
+			}
+			return r, nil
+		},
+	})
+}
+
+// ListDevices returns a Lepton device for each spidev device, with ID
+// "lepton:/dev/spidev0.0". Whether a Lepton is connected cannot be detected.
+// MLX90640 sensors are not listed, see Open.
+func ListDevices() ([]image.Device, error) {
+	paths, err := filepath.Glob("/dev/spidev*")
+	if err != nil {
+		return nil, err
+	}
+	var devices []image.Device
+	for _, p := range paths {
+		devices = append(devices, image.Device{
+			Name: "FLIR Lepton 3 on " + p,
+			ID:   "lepton:" + p,
+			Caps: []image.DeviceCap{{Width: 160, Height: 120, Framerate: 9}},
+		})
+	}
+	if len(devices) == 0 {
+		return nil, image.ErrNoDevices
+	}
+	return devices, nil
+}
+
+// Open returns a recorder for a thermal device:
+//
+//	lepton:/dev/spidev0.0    a FLIR Lepton 3, 160x120
+//	lepton2:/dev/spidev0.0   a FLIR Lepton 2, 80x60
+//	mlx90640:command [args]  an MLX90640, 32x24, read from a command that
+//	                         prints 768 temperatures per line, at 8Hz
+//
+// If id is empty, the first device from ListDevices is used.
+func Open(ctx context.Context, id string, opts ...Option) (*Recorder, error) {
+	var xopts RecorderOpts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
+	}
+	if id == "" {
+		devs, err := ListDevices()
+		if err != nil {
+			return nil, fmt.Errorf("listing devices: %w", err)
+		}
+		id = devs[0].ID
+	}
+
+	t := strings.SplitN(id, ":", 2)
+	if len(t) != 2 || t[1] == "" {
+		return nil, fmt.Errorf("bad thermal device %q, must be lepton:, lepton2: or mlx90640: followed by a device or command", id)
+	}
+	var src frame.Source
+	var err error
+	switch t[0] {
+	case "lepton", "lepton2":
+		version := 3
+		if t[0] == "lepton2" {
+			version = 2
+		}
+		src, err = lepton.Open(ctx,
+			lepton.WithDevice(t[1]),
+			lepton.WithVersion(version),
+			lepton.WithVerbose(xopts.Verbose),
+			lepton.WithLogger(xopts.Logger),
+			lepton.WithClock(xopts.Clock),
+		)
+	case "mlx90640":
+		src, err = frame.NewStream(ctx,
+			frame.WithShape(24, 32),
+			frame.WithEncoding(frame.EncodingText),
+			frame.WithScale(1, "°C"),
+			frame.WithFrequency(8),
+			frame.WithCommand(strings.Fields(t[1])...),
+			frame.WithVerbose(xopts.Verbose),
+			frame.WithLogger(xopts.Logger),
+			frame.WithClock(xopts.Clock),
+		)
+	default:
+		return nil, fmt.Errorf("unknown thermal sensor %q, must be lepton, lepton2 or mlx90640", t[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewRecorder(ctx, src, xopts)
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,305 @@
+// Package thermal turns frames of temperatures from thermal sensors into
+// images, for classifying with image models trained on thermal images, e.g.
+// for presence detection or finding hot spots.
+//
+// Sources are frame.Source with shape [rows columns], like a FLIR Lepton read
+// with package sensor/lepton, or an MLX90640 read with frame.NewStream from a
+// tool that prints a line of 768 temperatures per frame. Temperatures are
+// scaled from a fixed range, or from the coldest to the hottest pixel of each
+// frame, to grayscale or to a color palette.
+//
+// Importing this package registers image backend "thermal", see
+// ListDevices.
+package thermal
+
+import (
+	"context"
+	"fmt"
+	goimage "image"
+	"image/color"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/frame"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+// Palette maps scaled temperatures to colors.
+type Palette string
+
+// Palettes.
+const (
+	PaletteGray Palette = "gray" // Cold is black, hot is white.
+	PaletteIron Palette = "iron" // Black, through purple, red and yellow, to white.
+)
+
+// ParsePalette parses the name of a palette.
+func ParsePalette(s string) (Palette, error) {
+	switch p := Palette(s); p {
+	case PaletteGray, PaletteIron:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown palette %q, must be gray or iron", s)
+}
+
+// ironStops are the colors of PaletteIron, evenly spaced.
+var ironStops = []color.RGBA{
+	{0, 0, 0, 255},
+	{32, 0, 140, 255},
+	{180, 0, 150, 255},
+	{255, 120, 0, 255},
+	{255, 220, 0, 255},
+	{255, 255, 255, 255},
+}
+
+// iron returns the color of f, between 0 and 1, in PaletteIron.
+func iron(f float64) color.RGBA {
+	x := f * float64(len(ironStops)-1)
+	i := int(x)
+	if i >= len(ironStops)-1 {
+		return ironStops[len(ironStops)-1]
+	}
+	a, b := ironStops[i], ironStops[i+1]
+	t := x - float64(i)
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + t*(float64(b)-float64(a)) + 0.5)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// Image returns an image of width by height temperatures in row-major order.
+// Temperatures are scaled from min to max, with values outside the range
+// clamped. If min and max are equal, the range of values in temps is used. The
+// image is an *image.Gray for PaletteGray, and an *image.RGBA otherwise.
+func Image(temps []float64, width, height int, min, max float64, palette Palette) goimage.Image {
+	if min == max && len(temps) > 0 {
+		min, max = temps[0], temps[0]
+		for _, v := range temps {
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+	}
+	scale := func(v float64) float64 {
+		if max == min {
+			return 0
+		}
+		f := (v - min) / (max - min)
+		if f < 0 {
+			return 0
+		} else if f > 1 {
+			return 1
+		}
+		return f
+	}
+
+	r := goimage.Rect(0, 0, width, height)
+	if palette == PaletteGray || palette == "" {
+		img := goimage.NewGray(r)
+		for i, v := range temps[:width*height] {
+			img.Pix[i] = uint8(scale(v)*255 + 0.5)
+		}
+		return img
+	}
+	img := goimage.NewRGBA(r)
+	for i, v := range temps[:width*height] {
+		c := iron(scale(v))
+		copy(img.Pix[4*i:], []uint8{c.R, c.G, c.B, c.A})
+	}
+	return img
+}
+
+// RecorderOpts are options for a Recorder.
+type RecorderOpts struct {
+	// Temperatures scaled to the darkest and brightest pixels. If equal,
+	// e.g. both 0, each frame is scaled from its coldest to its hottest
+	// pixel.
+	Min, Max float64
+
+	// Defaults to PaletteGray.
+	Palette Palette
+
+	// How often to send an image. If 0, every frame of the source is sent.
+	Interval time.Duration
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// Used for throttling to Interval. If nil, edgeimpulse.SystemClock is
+	// used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts RecorderOpts) apply(o *RecorderOpts) {
+	*o = opts
+}
+
+// WithRange sets RecorderOpts.Min and RecorderOpts.Max.
+func WithRange(min, max float64) Option {
+	return optionFunc(func(o *RecorderOpts) {
+		o.Min = min
+		o.Max = max
+	})
+}
+
+// WithPalette sets RecorderOpts.Palette.
+func WithPalette(palette Palette) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Palette = palette })
+}
+
+// WithInterval sets RecorderOpts.Interval.
+func WithInterval(interval time.Duration) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Interval = interval })
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// Recorder is an image recorder, turning frames of a thermal source into
+// images.
+type Recorder struct {
+	opts        RecorderOpts
+	logger      edgeimpulse.Logger
+	src         frame.Source
+	imageEvents chan image.Event
+	cancel      context.CancelFunc
+	done        chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Check that Recorder implements interface Recorder.
+var _ image.Recorder = (*Recorder)(nil)
+
+// NewRecorder returns a recorder sending an image for frames of src, which
+// must have shape [rows columns]. Closing the recorder closes src.
+//
+// Callers must call Close to clean up. Canceling ctx also stops the recorder.
+func NewRecorder(ctx context.Context, src frame.Source, opts ...Option) (*Recorder, error) {
+	r := &Recorder{src: src}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	shape := src.Shape()
+	if len(shape) != 2 {
+		return nil, fmt.Errorf("source has shape %v, need [rows columns]", shape)
+	}
+	if r.opts.Palette == "" {
+		r.opts.Palette = PaletteGray
+	}
+	if _, err := ParsePalette(string(r.opts.Palette)); err != nil {
+		return nil, err
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
+	r.imageEvents = make(chan image.Event)
+	r.done = make(chan struct{})
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+
+	go func() {
+		defer close(r.done)
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				r.sendErr(ctx, err)
+			}
+		}()
+
+		throttle := image.NewThrottle(r.opts.Interval, r.opts.Clock)
+		height, width := shape[0], shape[1]
+		for {
+			var ev frame.Event
+			select {
+			case <-ctx.Done():
+				return
+			case ev = <-src.Events():
+			}
+			if ev.Err != nil {
+				r.sendErr(ctx, fmt.Errorf("reading thermal frames: %w", ev.Err))
+				return
+			}
+			now, due := throttle.Due()
+			if !due {
+				continue
+			}
+			if len(ev.Frame.Values) != width*height {
+				r.logger.Logf(edgeimpulse.LogDebug, "skipping frame with %d values, expected %d", len(ev.Frame.Values), width*height)
+				continue
+			}
+			img := Image(ev.Frame.Values, width, height, r.opts.Min, r.opts.Max, r.opts.Palette)
+			select {
+			case r.imageEvents <- image.Event{Image: img}:
+				throttle.Used(now)
+			default:
+				r.logger.Logf(edgeimpulse.LogDebug, "dropping image, classifier still busy")
+			}
+		}
+	}()
+
+	return r, nil
+}
+
+func (r *Recorder) sendErr(ctx context.Context, err error) {
+	select {
+	case r.imageEvents <- image.Event{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// Events returns a channel on which Events can be received.
+func (r *Recorder) Events() chan image.Event {
+	return r.imageEvents
+}
+
+// Close stops the recorder and closes the source.
+func (r *Recorder) Close() error {
+	r.cancel()
+	<-r.done
+	return r.src.Close()
+}
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
@@ -0,0 +1,80 @@
+package thermal
+
+import (
+	"context"
+	goimage "image"
+	"image/color"
+	"testing"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/frame"
+)
+
+func TestImage(t *testing.T) {
+	temps := []float64{10, 20, 30, 40}
+
+	gray := Image(temps, 2, 2, 20, 30, PaletteGray).(*goimage.Gray)
+	if got := gray.Pix; got[0] != 0 || got[1] != 0 || got[2] != 255 || got[3] != 255 {
+		t.Errorf("fixed range: got %v, expected [0 0 255 255]", got)
+	}
+
+	gray = Image(temps, 2, 2, 0, 0, PaletteGray).(*goimage.Gray)
+	if got := gray.Pix; got[0] != 0 || got[1] != 85 || got[2] != 170 || got[3] != 255 {
+		t.Errorf("auto range: got %v, expected [0 85 170 255]", got)
+	}
+
+	rgba := Image(temps, 2, 2, 10, 40, PaletteIron).(*goimage.RGBA)
+	if c := rgba.RGBAAt(0, 0); c != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("iron coldest: got %v, expected black", c)
+	}
+	if c := rgba.RGBAAt(1, 1); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("iron hottest: got %v, expected white", c)
+	}
+
+	// A uniform frame does not divide by zero.
+	gray = Image([]float64{5, 5}, 2, 1, 0, 0, PaletteGray).(*goimage.Gray)
+	if got := gray.Pix; got[0] != 0 || got[1] != 0 {
+		t.Errorf("uniform: got %v, expected [0 0]", got)
+	}
+}
+
+type fakeSource struct {
+	events chan frame.Event
+}
+
+func (s *fakeSource) Shape() []int             { return []int{1, 2} }
+func (s *fakeSource) Units() string            { return "°C" }
+func (s *fakeSource) Frequency() float64       { return 1 }
+func (s *fakeSource) Events() chan frame.Event { return s.events }
+func (s *fakeSource) Close() error             { return nil }
+
+func TestRecorder(t *testing.T) {
+	src := &fakeSource{make(chan frame.Event)}
+	r, err := NewRecorder(context.Background(), src, WithRange(0, 100))
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	defer r.Close()
+
+	// The recorder only sends images while a reader is waiting, so keep
+	// sending frames until one arrives.
+	go func() {
+		for {
+			select {
+			case src.events <- frame.Event{Frame: frame.Frame{Values: []float64{0, 100}}}:
+			case <-r.done:
+				return
+			}
+		}
+	}()
+	ev := <-r.Events()
+	if ev.Err != nil {
+		t.Fatalf("got error %v", ev.Err)
+	}
+	if b := ev.Image.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Errorf("got bounds %v, expected 2x1", b)
+	}
+
+	if _, err := NewRecorder(context.Background(), src, WithPalette("rainbow")); err == nil {
+		t.Errorf("expected error for unknown palette")
+	}
+}
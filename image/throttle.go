@@ -0,0 +1,33 @@
+package image
+
+import (
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Throttle limits frames from a recorder to one per interval. Recording
+// programs do not deliver frames at exact intervals, so a frame is due once 90%
+// of the interval has passed since the last frame that was used.
+type Throttle struct {
+	interval time.Duration
+	clock    edgeimpulse.Clock
+	last     time.Time
+}
+
+// NewThrottle returns a new throttle. If clock is nil, SystemClock is used.
+func NewThrottle(interval time.Duration, clock edgeimpulse.Clock) *Throttle {
+	return &Throttle{interval: interval, clock: edgeimpulse.DefaultClock(clock)}
+}
+
+// Due returns the current time, and whether a frame arriving now should be
+// used. Call Used with the returned time once the frame has been delivered.
+func (t *Throttle) Due() (time.Time, bool) {
+	now := t.clock.Now()
+	return now, now.Sub(t.last) >= t.interval*9/10
+}
+
+// Used records that a frame arriving at now was delivered.
+func (t *Throttle) Used(now time.Time) {
+	t.last = now
+}
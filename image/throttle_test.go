@@ -0,0 +1,32 @@
+package image_test
+
+import (
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+func TestThrottle(t *testing.T) {
+	clock := edgeimpulse.NewManualClock(time.Unix(1000, 0))
+	th := image.NewThrottle(100*time.Millisecond, clock)
+
+	check := func(advance time.Duration, expDue bool, use bool) {
+		t.Helper()
+		clock.Advance(advance)
+		now, due := th.Due()
+		if due != expDue {
+			t.Fatalf("after %v, got due %v, expected %v", advance, due, expDue)
+		}
+		if due && use {
+			th.Used(now)
+		}
+	}
+	check(0, true, true)
+	check(50*time.Millisecond, false, false)
+	check(40*time.Millisecond, true, false) // 90% of interval.
+	check(0, true, true)                    // Previous frame was dropped, not used.
+	check(89*time.Millisecond, false, false)
+	check(time.Millisecond, true, true)
+}
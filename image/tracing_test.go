@@ -0,0 +1,137 @@
+package image_test
+
+import (
+	"context"
+	"image"
+	"sync"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	eimage "github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+type testRunner struct{}
+
+func (testRunner) ModelParameters() edgeimpulse.ModelParameters {
+	return edgeimpulse.ModelParameters{SensorType: edgeimpulse.SensorTypeCamera, ImageInputWidth: 2, ImageInputHeight: 2, ImageChannelCount: 1}
+}
+
+func (testRunner) Project() edgeimpulse.Project {
+	return edgeimpulse.Project{}
+}
+
+func (testRunner) Classify(data []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+	var resp edgeimpulse.RunnerClassifyResponse
+	resp.Result.Classification = map[string]float64{"cat": 1}
+	return resp, nil
+}
+
+func (testRunner) Close() error {
+	return nil
+}
+
+type testRecorder chan eimage.Event
+
+func (r testRecorder) Events() chan eimage.Event {
+	return r
+}
+
+func (r testRecorder) Close() error {
+	return nil
+}
+
+type spanKey struct{}
+
+// recordingTracer records spans, and the order of events, such as starting
+// and ending spans.
+type recordingTracer struct {
+	mutex  sync.Mutex
+	events int
+	spans  []*recordingSpan
+}
+
+type recordingSpan struct {
+	t          *recordingTracer
+	name       string
+	parent     *recordingSpan
+	start, end int // Sequence numbers of events, end is 0 until ended.
+}
+
+// event returns the sequence number of a next event.
+func (t *recordingTracer) event() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.events++
+	return t.events
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attrs ...edgeimpulse.Attribute) (context.Context, edgeimpulse.Span) {
+	parent, _ := ctx.Value(spanKey{}).(*recordingSpan)
+	s := &recordingSpan{t: t, name: name, parent: parent, start: t.event()}
+	t.mutex.Lock()
+	t.spans = append(t.spans, s)
+	t.mutex.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...edgeimpulse.Attribute) {}
+func (s *recordingSpan) RecordError(err error)                        {}
+func (s *recordingSpan) End() {
+	end := s.t.event()
+	s.t.mutex.Lock()
+	s.end = end
+	s.t.mutex.Unlock()
+}
+
+func TestClassifierTracing(t *testing.T) {
+	tracer := &recordingTracer{}
+	recorder := make(testRecorder, 1)
+	recorder <- eimage.Event{Image: image.NewGray(image.Rect(0, 0, 2, 2))}
+	var handled int
+	done := make(chan struct{})
+	c, err := eimage.NewClassifier(context.Background(), testRunner{}, recorder,
+		eimage.WithTracer(tracer),
+		eimage.WithOnResult(func(ev eimage.ClassifyEvent) {
+			if s, _ := ev.Context.Value(spanKey{}).(*recordingSpan); s == nil || s.name != "eim.postprocess" {
+				t.Errorf("event context without eim.postprocess span")
+			}
+			handled = tracer.event()
+			close(done)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	c.Close()
+
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	frame := tracer.spans[0]
+	if frame.name != "eim.frame" || frame.parent != nil {
+		t.Fatalf("got first span %s, expected eim.frame without parent", frame.name)
+	}
+	var stages []*recordingSpan
+	for _, s := range tracer.spans {
+		if s.parent == frame {
+			stages = append(stages, s)
+		}
+	}
+	names := []string{"eim.capture", "eim.preprocess", "eim.classify", "eim.postprocess"}
+	if len(stages) != len(names) {
+		t.Fatalf("got %d child spans of frame, expected %d", len(stages), len(names))
+	}
+	prev := frame.start
+	for i, s := range stages {
+		if s.name != names[i] {
+			t.Fatalf("got child span %d %s, expected %s", i, s.name, names[i])
+		}
+		if s.start < prev || s.end < s.start {
+			t.Fatalf("span %s not after previous stage", s.name)
+		}
+		prev = s.end
+	}
+	if post := stages[3]; handled == 0 || post.end < handled || frame.end < post.end {
+		t.Fatalf("postprocess ended at %d and frame at %d, before handler at %d", post.end, frame.end, handled)
+	}
+}
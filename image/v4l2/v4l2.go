@@ -0,0 +1,167 @@
+// Package v4l2 reads and sets controls of V4L2 cameras on linux, such as
+// exposure, gain and focus, e.g. to fix over-exposed scenes before recording.
+//
+// Controls are named like v4l2-ctl names them: the name reported by the
+// driver in lower case, with underscores, e.g. exposure_auto, gain or
+// focus_absolute. Names changed by newer kernels are accepted under the old
+// name too, e.g. exposure_auto for auto_exposure.
+package v4l2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Types of controls.
+const (
+	TypeInteger = 1
+	TypeBoolean = 2
+	TypeMenu    = 3
+	TypeButton  = 4
+)
+
+// Control is a control of a camera.
+type Control struct {
+	ID      uint32
+	Name    string // E.g. exposure_auto.
+	Type    int
+	Min     int
+	Max     int
+	Step    int
+	Default int
+	Value   int
+}
+
+func (c Control) String() string {
+	return fmt.Sprintf("%s=%d (min %d, max %d, step %d, default %d)", c.Name, c.Value, c.Min, c.Max, c.Step, c.Default)
+}
+
+// aliases map names of controls renamed in linux 5.x to their old names, and
+// the other way around.
+var aliases = map[string]string{
+	"exposure_auto":          "auto_exposure",
+	"auto_exposure":          "exposure_auto",
+	"exposure_absolute":      "exposure_time_absolute",
+	"exposure_time_absolute": "exposure_absolute",
+}
+
+// controlName turns a driver's name of a control, e.g. "Exposure, Auto", into
+// the name used by v4l2-ctl, e.g. exposure_auto.
+func controlName(s string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if underscore && b.Len() > 0 {
+				b.WriteByte('_')
+			}
+			underscore = false
+			b.WriteRune(r)
+		} else {
+			underscore = true
+		}
+	}
+	return b.String()
+}
+
+// find returns the control named name, or by its alias.
+func find(controls []Control, name string) (Control, bool) {
+	for _, c := range controls {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	if alias, ok := aliases[name]; ok {
+		for _, c := range controls {
+			if c.Name == alias {
+				return c, true
+			}
+		}
+	}
+	return Control{}, false
+}
+
+// Setting is a value for a control.
+type Setting struct {
+	Name  string
+	Value int
+}
+
+// ParseSetting parses name=value. Values are integers, or true or false for
+// booleans.
+func ParseSetting(s string) (Setting, error) {
+	t := strings.SplitN(s, "=", 2)
+	if len(t) != 2 || t[0] == "" {
+		return Setting{}, fmt.Errorf("bad control setting %q, expected name=value", s)
+	}
+	var v int
+	switch t[1] {
+	case "true":
+		v = 1
+	case "false":
+		v = 0
+	default:
+		var err error
+		v, err = strconv.Atoi(t[1])
+		if err != nil {
+			return Setting{}, fmt.Errorf("bad value for control %s: %w", t[0], err)
+		}
+	}
+	return Setting{t[0], v}, nil
+}
+
+// Settings is a flag.Value collecting settings from a repeatable flag.
+type Settings []Setting
+
+func (s *Settings) String() string {
+	var l []string
+	for _, x := range *s {
+		l = append(l, fmt.Sprintf("%s=%d", x.Name, x.Value))
+	}
+	return strings.Join(l, ",")
+}
+
+// Set adds a setting, after checking it parses.
+func (s *Settings) Set(v string) error {
+	x, err := ParseSetting(v)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, x)
+	return nil
+}
+
+// Set applies settings to the camera at device, e.g. /dev/video0, in order,
+// so exposure_auto can be set to manual before setting exposure_absolute.
+// Values are checked against the range of controls.
+func Set(device string, settings []Setting) error {
+	controls, err := List(device)
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		c, ok := find(controls, s.Name)
+		if !ok {
+			return fmt.Errorf("%s: unknown control %q", device, s.Name)
+		}
+		if s.Value < c.Min || s.Value > c.Max {
+			return fmt.Errorf("%s: value %d for %s out of range %d to %d", device, s.Value, s.Name, c.Min, c.Max)
+		}
+		if err := setControl(device, c.ID, s.Value); err != nil {
+			return fmt.Errorf("%s: setting %s: %w", device, s.Name, err)
+		}
+	}
+	return nil
+}
+
+// List returns the enabled controls of the camera at device, with their
+// current values.
+func List(device string) ([]Control, error) {
+	controls, err := listControls(device)
+	if err != nil {
+		return nil, fmt.Errorf("%s: listing controls: %w", device, err)
+	}
+	return controls, nil
+}
@@ -0,0 +1,97 @@
+//go:build linux
+// +build linux
+
+package v4l2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// V4L2 ioctls and values, from linux/videodev2.h.
+const (
+	vidiocQueryctrl     = 0xc0445624
+	vidiocGCtrl         = 0xc008561b
+	vidiocSCtrl         = 0xc008561c
+	queryctrlSize       = 68
+	ctrlFlagNextCtrl    = 0x80000000
+	ctrlFlagDisabled    = 0x0001
+	ctrlTypeCtrlClass   = 6
+	queryctrlNameOffset = 8
+	queryctrlNameSize   = 32
+)
+
+func ioctl(f *os.File, req uintptr, arg []byte) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(&arg[0]))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func listControls(device string) ([]Control, error) {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var controls []Control
+	id := uint32(ctrlFlagNextCtrl)
+	for {
+		var q [queryctrlSize]byte
+		binary.LittleEndian.PutUint32(q[0:], id)
+		if err := ioctl(f, vidiocQueryctrl, q[:]); err != nil {
+			if err == syscall.EINVAL {
+				// No more controls.
+				break
+			}
+			return nil, err
+		}
+		id = binary.LittleEndian.Uint32(q[0:])
+		typ := binary.LittleEndian.Uint32(q[4:])
+		flags := binary.LittleEndian.Uint32(q[60:])
+		next := id | ctrlFlagNextCtrl
+		if typ == ctrlTypeCtrlClass || flags&ctrlFlagDisabled != 0 {
+			id = next
+			continue
+		}
+		name := q[queryctrlNameOffset : queryctrlNameOffset+queryctrlNameSize]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		c := Control{
+			ID:      id,
+			Name:    controlName(string(name)),
+			Type:    int(typ),
+			Min:     int(int32(binary.LittleEndian.Uint32(q[40:]))),
+			Max:     int(int32(binary.LittleEndian.Uint32(q[44:]))),
+			Step:    int(int32(binary.LittleEndian.Uint32(q[48:]))),
+			Default: int(int32(binary.LittleEndian.Uint32(q[52:]))),
+		}
+		if typ != TypeButton {
+			var ctrl [8]byte
+			binary.LittleEndian.PutUint32(ctrl[0:], id)
+			if err := ioctl(f, vidiocGCtrl, ctrl[:]); err == nil {
+				c.Value = int(int32(binary.LittleEndian.Uint32(ctrl[4:])))
+			}
+		}
+		controls = append(controls, c)
+		id = next
+	}
+	return controls, nil
+}
+
+func setControl(device string, id uint32, value int) error {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var ctrl [8]byte
+	binary.LittleEndian.PutUint32(ctrl[0:], id)
+	binary.LittleEndian.PutUint32(ctrl[4:], uint32(int32(value)))
+	return ioctl(f, vidiocSCtrl, ctrl[:])
+}
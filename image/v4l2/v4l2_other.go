@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package v4l2
+
+import (
+	"fmt"
+)
+
+func listControls(device string) ([]Control, error) {
+	return nil, fmt.Errorf("v4l2 controls only supported on linux")
+}
+
+func setControl(device string, id uint32, value int) error {
+	return fmt.Errorf("v4l2 controls only supported on linux")
+}
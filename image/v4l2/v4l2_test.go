@@ -0,0 +1,46 @@
+package v4l2
+
+import (
+	"testing"
+)
+
+func TestControlName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Exposure, Auto":                  "exposure_auto",
+		"Focus, Absolute":                 "focus_absolute",
+		"Gain":                            "gain",
+		"White Balance Temperature, Auto": "white_balance_temperature_auto",
+		"Power Line Frequency":            "power_line_frequency",
+	} {
+		if got := controlName(name); got != expected {
+			t.Errorf("controlName(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func TestParseSetting(t *testing.T) {
+	var s Settings
+	for _, v := range []string{"exposure_auto=1", "gain=-5", "focus_auto=false"} {
+		if err := s.Set(v); err != nil {
+			t.Fatalf("set %q: %v", v, err)
+		}
+	}
+	if got := s.String(); got != "exposure_auto=1,gain=-5,focus_auto=0" {
+		t.Errorf("got %q", got)
+	}
+	for _, v := range []string{"gain", "=1", "gain=high"} {
+		if _, err := ParseSetting(v); err == nil {
+			t.Errorf("parsing %q succeeded", v)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	controls := []Control{{ID: 1, Name: "auto_exposure"}, {ID: 2, Name: "gain"}}
+	if c, ok := find(controls, "exposure_auto"); !ok || c.ID != 1 {
+		t.Errorf("alias not found, got %v, %v", c, ok)
+	}
+	if _, ok := find(controls, "focus_absolute"); ok {
+		t.Errorf("found unknown control")
+	}
+}
@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"time"
+)
+
+// ClipFrame is a JPEG-encoded frame of a clip.
+type ClipFrame struct {
+	Time time.Time
+	JPEG []byte
+}
+
+// Clip is a short video of the frames before and after a detection.
+type Clip struct {
+	Label    string            // Label that triggered the clip, used as label when uploading.
+	Time     time.Time         // Time of the trigger.
+	Metadata map[string]string // Stored with the sample when uploading.
+	Frames   []ClipFrame
+}
+
+// UploadOpts returns options for uploading the clip with its label and
+// metadata.
+func (c *Clip) UploadOpts() *UploadOpts {
+	return &UploadOpts{Label: c.Label, Metadata: c.Metadata}
+}
+
+// Filename returns a filename for uploading the clip as AVI, with its label
+// and time.
+func (c *Clip) Filename() string {
+	return fmt.Sprintf("clip-%s-%d.avi", c.Label, c.Time.UnixNano())
+}
+
+// ClipBuffer keeps the frames of the last pre duration, and turns them into a
+// clip when a detection triggers it, adding the frames of the post duration
+// after the trigger. A ClipBuffer is not safe for concurrent use.
+type ClipBuffer struct {
+	pre, post time.Duration
+
+	frames []ClipFrame // Frames within pre of the last frame, oldest first.
+	clip   *Clip       // Clip being recorded, nil if none.
+}
+
+// NewClipBuffer returns a ClipBuffer for clips with frames from pre before
+// until post after a trigger.
+func NewClipBuffer(pre, post time.Duration) *ClipBuffer {
+	return &ClipBuffer{pre: pre, post: post}
+}
+
+// Add encodes img taken at t as JPEG and adds it to the buffer, or the clip
+// being recorded. When a clip is complete, it is returned.
+func (b *ClipBuffer) Add(t time.Time, img image.Image) (*Clip, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		return nil, fmt.Errorf("encoding frame: %w", err)
+	}
+	f := ClipFrame{t, buf.Bytes()}
+
+	if c := b.clip; c != nil {
+		c.Frames = append(c.Frames, f)
+		if t.Sub(c.Time) < b.post {
+			return nil, nil
+		}
+		b.clip = nil
+		return c, nil
+	}
+
+	b.frames = append(b.frames, f)
+	var i int
+	for i < len(b.frames) && t.Sub(b.frames[i].Time) > b.pre {
+		i++
+	}
+	b.frames = b.frames[i:]
+	return nil, nil
+}
+
+// Trigger starts a clip for a detection of label at t, with the buffered
+// frames. Trigger returns false if a clip is already being recorded, which
+// then includes t.
+func (b *ClipBuffer) Trigger(t time.Time, label string, metadata map[string]string) bool {
+	if b.clip != nil {
+		return false
+	}
+	b.clip = &Clip{Label: label, Time: t, Metadata: metadata, Frames: b.frames}
+	b.frames = nil
+	return true
+}
+
+// AVI returns the frames of the clip as motion JPEG in an AVI file, at the
+// average frame rate of the clip.
+func (c *Clip) AVI() ([]byte, error) {
+	if len(c.Frames) == 0 {
+		return nil, fmt.Errorf("clip without frames")
+	}
+	config, err := jpeg.DecodeConfig(bytes.NewReader(c.Frames[0].JPEG))
+	if err != nil {
+		return nil, fmt.Errorf("decoding first frame: %w", err)
+	}
+	n := len(c.Frames)
+	usPerFrame := uint32(time.Second / time.Microsecond)
+	if n > 1 {
+		d := c.Frames[n-1].Time.Sub(c.Frames[0].Time) / time.Duration(n-1)
+		if d > 0 {
+			usPerFrame = uint32(d / time.Microsecond)
+		}
+	}
+
+	var movi, idx1 bytes.Buffer
+	movi.WriteString("movi")
+	var maxSize uint32
+	for _, f := range c.Frames {
+		size := uint32(len(f.JPEG))
+		if size > maxSize {
+			maxSize = size
+		}
+		// Index offsets are relative to the "movi" fourcc.
+		idx1.WriteString("00dc")
+		le(&idx1, uint32(0x10), uint32(movi.Len()), size) // AVIIF_KEYFRAME.
+		writeChunk(&movi, "00dc", f.JPEG)
+	}
+
+	w, h := uint32(config.Width), uint32(config.Height)
+	var avih, strh, strf bytes.Buffer
+	le(&avih, usPerFrame, uint32(0), uint32(0), uint32(0x10), uint32(n), uint32(0), uint32(1), maxSize, w, h, [4]uint32{}) // AVIF_HASINDEX.
+	strh.WriteString("vidsMJPG")
+	le(&strh, uint32(0), uint16(0), uint16(0), uint32(0), usPerFrame, uint32(time.Second/time.Microsecond), uint32(0), uint32(n), maxSize, int32(-1), uint32(0), [4]uint16{0, 0, uint16(w), uint16(h)})
+	le(&strf, uint32(40), int32(w), int32(h), uint16(1), uint16(24), [4]byte{'M', 'J', 'P', 'G'}, w*h*3, [4]uint32{})
+
+	var strl, hdrl, riff bytes.Buffer
+	strl.WriteString("strl")
+	writeChunk(&strl, "strh", strh.Bytes())
+	writeChunk(&strl, "strf", strf.Bytes())
+	hdrl.WriteString("hdrl")
+	writeChunk(&hdrl, "avih", avih.Bytes())
+	writeChunk(&hdrl, "LIST", strl.Bytes())
+	riff.WriteString("AVI ")
+	writeChunk(&riff, "LIST", hdrl.Bytes())
+	writeChunk(&riff, "LIST", movi.Bytes())
+	writeChunk(&riff, "idx1", idx1.Bytes())
+
+	var file bytes.Buffer
+	writeChunk(&file, "RIFF", riff.Bytes())
+	return file.Bytes(), nil
+}
+
+// le writes values little endian to buf.
+func le(buf *bytes.Buffer, values ...interface{}) {
+	for _, v := range values {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+}
+
+// writeChunk writes a RIFF chunk with data to buf, padded to an even size.
+func writeChunk(buf *bytes.Buffer, fourcc string, data []byte) {
+	buf.WriteString(fourcc)
+	le(buf, uint32(len(data)))
+	buf.Write(data)
+	if len(data)%2 != 0 {
+		buf.WriteByte(0)
+	}
+}
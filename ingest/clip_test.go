@@ -0,0 +1,54 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+	"time"
+)
+
+func TestClipBuffer(t *testing.T) {
+	b := NewClipBuffer(2*time.Second, time.Second)
+	img := image.NewGray(image.Rect(0, 0, 8, 6))
+	t0 := time.Unix(1000, 0)
+	at := func(i int) time.Time { return t0.Add(time.Duration(i) * 500 * time.Millisecond) }
+
+	// 10 frames, every 500ms: only the last 5 are within 2s.
+	for i := 0; i < 10; i++ {
+		if c, err := b.Add(at(i), img); err != nil || c != nil {
+			t.Fatalf("add %d: got clip %v, err %v", i, c, err)
+		}
+	}
+	if !b.Trigger(at(9), "person", nil) {
+		t.Fatalf("trigger failed")
+	}
+	if b.Trigger(at(9), "person", nil) {
+		t.Fatalf("second trigger while recording succeeded")
+	}
+	var clip *Clip
+	for i := 10; clip == nil; i++ {
+		var err error
+		clip, err = b.Add(at(i), img)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(clip.Frames) != 7 || !clip.Frames[0].Time.Equal(at(5)) || !clip.Frames[6].Time.Equal(at(11)) {
+		t.Errorf("got %d frames from %v to %v", len(clip.Frames), clip.Frames[0].Time, clip.Frames[len(clip.Frames)-1].Time)
+	}
+
+	buf, err := clip.AVI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:4]) != "RIFF" || string(buf[8:12]) != "AVI " || int(binary.LittleEndian.Uint32(buf[4:]))+8 != len(buf) {
+		t.Errorf("bad riff header %q", buf[:12])
+	}
+	if n := bytes.Count(buf, []byte("00dc")); n != 2*7 {
+		t.Errorf("got %d frame chunks and index entries, expected 14", n)
+	}
+	if _, err := (&Clip{}).AVI(); err == nil {
+		t.Errorf("missing error for clip without frames")
+	}
+}
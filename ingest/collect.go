@@ -11,7 +11,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"strings"
@@ -62,6 +63,9 @@ type Collector struct {
 	HTTPClient       *http.Client
 	IngestionBaseURL string
 
+	// If set, stamps all uploads, see Session.
+	Session *Session
+
 	hmacKey []byte
 	apiKey  string
 }
@@ -73,7 +77,7 @@ type Collector struct {
 func NewCollector(apiKey, hmacKey string) (*Collector, error) {
 	hmacKeyBuf, err := hex.DecodeString(hmacKey)
 	if err != nil {
-		return nil, fmt.Errorf("parsing hmac key: %v", err)
+		return nil, fmt.Errorf("parsing hmac key: %w", err)
 	}
 	baseURL := IngestionBaseURL
 	host := os.Getenv("EI_HOST")
@@ -84,7 +88,7 @@ func NewCollector(apiKey, hmacKey string) (*Collector, error) {
 	} else if strings.HasSuffix(host, "edgeimpulse.com") {
 		baseURL = "https://ingestion." + host
 	}
-	c := &Collector{http.DefaultClient, baseURL, hmacKeyBuf, apiKey}
+	c := &Collector{HTTPClient: http.DefaultClient, IngestionBaseURL: baseURL, hmacKey: hmacKeyBuf, apiKey: apiKey}
 	return c, nil
 }
 
@@ -92,6 +96,14 @@ func NewCollector(apiKey, hmacKey string) (*Collector, error) {
 type UploadOpts struct {
 	Label              string
 	DisallowDuplicates bool
+
+	// Metadata is stored with the sample in EdgeImpulse Studio.
+	Metadata map[string]string
+
+	// Tags and a dataset version of the sample, stored as metadata with keys
+	// MetadataTags, comma-separated, and MetadataDatasetVersion.
+	Tags           []string
+	DatasetVersion string
 }
 
 // Upload sends the payload data to EdgeImpulse for ingestion.
@@ -117,7 +129,7 @@ func (c *Collector) Upload(ctx context.Context, filename string, category string
 	}
 	buf, err := json.Marshal(data)
 	if err != nil {
-		return "", fmt.Errorf("marshal data to JSON: %v", err)
+		return "", fmt.Errorf("marshal data to JSON: %w", err)
 	}
 
 	// Now actually sign the data (that has the zero signature).
@@ -135,20 +147,11 @@ func (c *Collector) Upload(ctx context.Context, filename string, category string
 	if category == "split" {
 		pbuf, err := json.Marshal(payload)
 		if err != nil {
-			return "", fmt.Errorf("marshal payload: %v", err)
+			return "", fmt.Errorf("marshal payload: %w", err)
 		}
-		h := fmt.Sprintf("%x", md5.Sum(pbuf))
-		for _, b := range h {
-			if b == 'f' {
-				continue
-			} else if b >= '0' && b <= '9' || b == 'a' || b == 'b' {
-				category = "training"
-			} else if b == 'c' || b == 'd' || b == 'e' {
-				category = "testing"
-			} else {
-				return "", fmt.Errorf("internal error: cannot determine category for split, byte %v", b)
-			}
-			break
+		category, err = splitCategory(pbuf)
+		if err != nil {
+			return "", err
 		}
 	}
 
@@ -156,17 +159,88 @@ func (c *Collector) Upload(ctx context.Context, filename string, category string
 	url := fmt.Sprintf("%s/api/%s/data", c.IngestionBaseURL, category)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(buf))
 	if err != nil {
-		return "", fmt.Errorf("new HTTP request: %v", err)
+		return "", fmt.Errorf("new HTTP request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	return c.do(req, filename, opts)
+}
+
+// UploadFile sends a file, e.g. a JPEG image or WAV audio file, to EdgeImpulse
+// for ingestion. The type of data is determined by EdgeImpulse from the
+// extension of filename.
+// UploadFile returns the response message from EdgeImpulse.
+// For HTTP-related errors, the (wrapped) underlying errors from net/http or an HTTPError can be returned.
+func (c *Collector) UploadFile(ctx context.Context, filename string, category string, data []byte, opts *UploadOpts) (string, error) {
+	switch category {
+	case "split":
+		var err error
+		category, err = splitCategory(data)
+		if err != nil {
+			return "", err
+		}
+	case "training", "testing":
+	default:
+		return "", fmt.Errorf("invalid category %q, need one of: split, training, testing", category)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("data", filename)
+	if err != nil {
+		return "", fmt.Errorf("creating multipart form: %w", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return "", fmt.Errorf("writing multipart form: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return "", fmt.Errorf("closing multipart form: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/%s/files", c.IngestionBaseURL, category)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, &body)
+	if err != nil {
+		return "", fmt.Errorf("new HTTP request: %w", err)
+	}
+	req.Header.Add("Content-Type", mw.FormDataContentType())
+	return c.do(req, filename, opts)
+}
+
+// splitCategory returns either "training" or "testing" based on a hash of buf,
+// resulting in roughly an 80/20 split.
+func splitCategory(buf []byte) (string, error) {
+	h := fmt.Sprintf("%x", md5.Sum(buf))
+	for _, b := range h {
+		if b == 'f' {
+			continue
+		} else if b >= '0' && b <= '9' || b == 'a' || b == 'b' {
+			return "training", nil
+		} else if b == 'c' || b == 'd' || b == 'e' {
+			return "testing", nil
+		} else {
+			return "", fmt.Errorf("internal error: cannot determine category for split, byte %v", b)
+		}
 	}
+	return "training", nil
+}
+
+// do adds the authentication and upload option headers to req, executes it and
+// returns the response message.
+func (c *Collector) do(req *http.Request, filename string, opts *UploadOpts) (string, error) {
 	req.Header.Add("x-api-key", c.apiKey)
 	req.Header.Add("x-file-name", filename)
-	req.Header.Add("Content-Type", "application/json")
 	if opts != nil && opts.Label != "" {
 		req.Header.Add("x-label", opts.Label)
 	}
 	if opts != nil && opts.DisallowDuplicates {
 		req.Header.Add("x-disallow-duplicates", "1")
 	}
+	if metadata := c.Session.metadata(opts); len(metadata) > 0 {
+		buf, err := json.Marshal(metadata)
+		if err != nil {
+			return "", fmt.Errorf("marshal metadata: %w", err)
+		}
+		req.Header.Add("x-metadata", string(buf))
+	}
 
 	// Perform HTTP request, and handle the response, including possible errors.
 	resp, err := c.HTTPClient.Do(req)
@@ -177,13 +251,13 @@ func (c *Collector) Upload(ctx context.Context, filename string, category string
 	if resp.StatusCode != 200 {
 		// Attempt to read a response message to use in error message, otherwise use http status message.
 		msg := resp.Status
-		buf, err := ioutil.ReadAll(resp.Body)
+		buf, err := io.ReadAll(resp.Body)
 		if err == nil && len(buf) > 0 {
 			msg = string(buf)
 		}
 		return "", HTTPError{resp.StatusCode, msg}
 	}
-	respBuf, err := ioutil.ReadAll(resp.Body)
+	respBuf, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", fmt.Errorf("reading response message: %w", err)
 	}
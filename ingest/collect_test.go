@@ -0,0 +1,60 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUploadFile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/testing/files" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "key" || r.Header.Get("x-label") != "cat" || r.Header.Get("x-metadata") != `{"score":"0.5"}` {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		f, fh, err := r.FormFile("data")
+		if err != nil {
+			t.Errorf("form file: %v", err)
+			return
+		}
+		buf, _ := io.ReadAll(f)
+		if fh.Filename != "test.jpg" || string(buf) != "jpegdata" {
+			t.Errorf("unexpected file %q with data %q", fh.Filename, buf)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c, err := NewCollector("key", "")
+	if err != nil {
+		t.Fatalf("new collector: %v", err)
+	}
+	c.IngestionBaseURL = srv.URL
+
+	opts := &UploadOpts{Label: "cat", Metadata: map[string]string{"score": "0.5"}}
+	msg, err := c.UploadFile(context.Background(), "test.jpg", "testing", []byte("jpegdata"), opts)
+	if err != nil || msg != "ok" {
+		t.Fatalf("upload file, got %q, %v, expected ok", msg, err)
+	}
+
+	if _, err := c.UploadFile(context.Background(), "test.jpg", "bogus", nil, nil); err == nil {
+		t.Fatalf("missing error for invalid category")
+	}
+}
+
+func TestQueueClosed(t *testing.T) {
+	c, err := NewCollector("key", "")
+	if err != nil {
+		t.Fatalf("new collector: %v", err)
+	}
+	q := NewQueue(c, "testing", 1, nil)
+	q.Close()
+	if q.Add(QueueFile{Filename: "test.jpg"}) {
+		t.Fatalf("add after close succeeded, expected file dropped")
+	}
+	q.Close()
+}
@@ -0,0 +1,195 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DirExtensions are the extensions of files uploaded by UploadDir.
+var DirExtensions = []string{".jpg", ".jpeg", ".png", ".wav", ".json", ".cbor", ".csv", ".mp4", ".avi"}
+
+// DefaultDirState is the name of the state file UploadDir keeps in the
+// directory if DirOpts.StatePath is empty.
+const DefaultDirState = ".ei-upload-state.jsonl"
+
+// File statuses reported by UploadDir.
+const (
+	FileUploaded = "uploaded"
+	FileSkipped  = "skipped" // Uploaded before, according to the state file.
+	FileFailed   = "failed"
+)
+
+// FileStatus is the result of uploading a file with UploadDir.
+type FileStatus struct {
+	Path   string // Relative to the directory.
+	Label  string
+	Status string // FileUploaded, FileSkipped or FileFailed.
+	Err    error  // For FileFailed.
+}
+
+// DirOpts are options for UploadDir.
+type DirOpts struct {
+	// File recording which files were uploaded, as JSON lines. A file is
+	// uploaded again if its size or modification time changed. If empty,
+	// DefaultDirState in the directory.
+	StatePath string
+
+	// Label of files directly in the directory, not in a label folder.
+	Label string
+
+	DisallowDuplicates bool
+
+	// If not nil, called for each file after it is handled.
+	Status func(FileStatus)
+}
+
+// dirState is a line of the state file of UploadDir.
+type dirState struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Uploaded time.Time `json:"uploaded"`
+}
+
+// UploadDir uploads the files with DirExtensions in dir to category, labeled
+// by the name of the folder they are in directly below dir, e.g. files in
+// dir/cat/ are labeled cat. Uploaded files are recorded in a state file, so an
+// interrupted upload of many files can be resumed by calling UploadDir again,
+// skipping files already uploaded. Failed files are reported and skipped, and
+// UploadDir returns an error if any file failed.
+func (c *Collector) UploadDir(ctx context.Context, dir, category string, opts *DirOpts) error {
+	if opts == nil {
+		opts = &DirOpts{}
+	}
+	statePath := opts.StatePath
+	if statePath == "" {
+		statePath = filepath.Join(dir, DefaultDirState)
+	}
+	uploaded, err := readDirState(statePath)
+	if err != nil {
+		return err
+	}
+	state, err := os.OpenFile(statePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("opening state file: %w", err)
+	}
+	defer state.Close()
+
+	status := func(s FileStatus) {
+		if opts.Status != nil {
+			opts.Status(s)
+		}
+	}
+	var failed int
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && path != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !dirExtension(path) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		st := FileStatus{Path: rel, Label: opts.Label}
+		if i := strings.Index(rel, "/"); i >= 0 {
+			st.Label = rel[:i]
+		}
+		if s, ok := uploaded[rel]; ok && s.Size == info.Size() && s.ModTime.Equal(info.ModTime()) {
+			st.Status = FileSkipped
+			status(st)
+			return nil
+		}
+
+		buf, err := os.ReadFile(path)
+		if err == nil {
+			uopts := &UploadOpts{Label: st.Label, DisallowDuplicates: opts.DisallowDuplicates}
+			_, err = c.UploadFile(ctx, filepath.Base(path), category, buf, uopts)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			st.Status, st.Err = FileFailed, err
+			status(st)
+			return nil
+		}
+		line, err := json.Marshal(dirState{rel, info.Size(), info.ModTime(), time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err := state.Write(append(line, '\n')); err != nil {
+			return fmt.Errorf("writing state file: %w", err)
+		}
+		st.Status = FileUploaded
+		status(st)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d files failed to upload", failed)
+	}
+	return state.Close()
+}
+
+// dirExtension returns whether the extension of path is in DirExtensions.
+func dirExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range DirExtensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// readDirState returns the files recorded in the state file at path, which
+// may not exist yet. An incomplete last line, from an interrupted write, is
+// ignored.
+func readDirState(path string) (map[string]dirState, error) {
+	m := map[string]dirState{}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s dirState
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			continue
+		}
+		m[s.Path] = s
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading state file: %w", err)
+	}
+	return m, nil
+}
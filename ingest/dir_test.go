@@ -0,0 +1,60 @@
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadDir(t *testing.T) {
+	var fail bool
+	uploads := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get("x-file-name")
+		if fail && name == "b.jpg" {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		uploads[name] = r.Header.Get("x-label")
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c, err := NewCollector("key", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.IngestionBaseURL = srv.URL
+
+	dir := t.TempDir()
+	for _, p := range []string{"cat/a.jpg", "dog/b.jpg", "c.wav", "notes.txt"} {
+		path := filepath.Join(dir, p)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses := map[string]string{}
+	opts := &DirOpts{Label: "unknown", Status: func(s FileStatus) { statuses[s.Path] = s.Status }}
+	fail = true
+	if err := c.UploadDir(context.Background(), dir, "training", opts); err == nil {
+		t.Fatalf("missing error for failed upload")
+	}
+	if len(uploads) != 2 || uploads["a.jpg"] != "cat" || uploads["c.wav"] != "unknown" || statuses["dog/b.jpg"] != FileFailed {
+		t.Fatalf("got uploads %v, statuses %v", uploads, statuses)
+	}
+
+	// Resume: only the failed file is uploaded.
+	fail = false
+	uploads = map[string]string{}
+	if err := c.UploadDir(context.Background(), dir, "training", opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads) != 1 || uploads["b.jpg"] != "dog" || statuses["cat/a.jpg"] != FileSkipped || statuses["dog/b.jpg"] != FileUploaded {
+		t.Fatalf("got uploads %v, statuses %v after resume", uploads, statuses)
+	}
+}
@@ -0,0 +1,73 @@
+package ingest
+
+import (
+	"context"
+	"sync"
+)
+
+// QueueFile is a file to upload through a Queue.
+type QueueFile struct {
+	Filename string
+	Data     []byte
+	Opts     *UploadOpts
+}
+
+// Queue uploads files in the background with UploadFile, so a classification
+// loop is not blocked on the network. If too many files are pending, new files
+// are dropped.
+type Queue struct {
+	files chan QueueFile
+	done  chan struct{}
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+// NewQueue starts a queue that uploads files to category through collector,
+// keeping at most size files pending. If errorf is not nil, it is called for
+// failed uploads.
+//
+// Callers must call Close to wait for pending uploads to finish.
+func NewQueue(collector *Collector, category string, size int, errorf func(format string, args ...interface{})) *Queue {
+	q := &Queue{
+		files: make(chan QueueFile, size),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(q.done)
+		for f := range q.files {
+			if _, err := collector.UploadFile(context.Background(), f.Filename, category, f.Data, f.Opts); err != nil && errorf != nil {
+				errorf("uploading %s: %v", f.Filename, err)
+			}
+		}
+	}()
+	return q
+}
+
+// Add queues a file for uploading. Add returns false if the file was dropped
+// because the queue is full or closed.
+func (q *Queue) Add(f QueueFile) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.files <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting files and waits for pending uploads to finish.
+func (q *Queue) Close() error {
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.files)
+	}
+	q.mutex.Unlock()
+	<-q.done
+	return nil
+}
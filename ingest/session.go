@@ -0,0 +1,98 @@
+package ingest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Metadata keys of the fields of UploadOpts and Session.
+const (
+	MetadataTags           = "tags"
+	MetadataDatasetVersion = "dataset_version"
+	MetadataSession        = "session"
+)
+
+// Session stamps all uploads of a Collector with the same tags, dataset
+// version and metadata, so the samples of a collection campaign can be
+// tracked and filtered in Studio. Set it as Collector.Session.
+type Session struct {
+	ID             string // Identifies the session, e.g. as generated by NewSession.
+	Tags           []string
+	DatasetVersion string
+	Metadata       map[string]string
+}
+
+// NewSession returns a session with a new ID of the current time and a random
+// suffix, e.g. 20240102T150405-1a2b3c4d.
+func NewSession(tags []string, datasetVersion string) (*Session, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("generating session id: %w", err)
+	}
+	id := time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(buf)
+	return &Session{ID: id, Tags: tags, DatasetVersion: datasetVersion}, nil
+}
+
+// ParseTags returns the non-empty tags of a comma-separated list.
+func ParseTags(s string) []string {
+	var l []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			l = append(l, t)
+		}
+	}
+	return l
+}
+
+// metadata returns the metadata sent for opts, stamped by session s, which
+// may be nil. Fields of opts take precedence over metadata with the same key,
+// and opts over the session. Tags of the session and opts are combined.
+func (s *Session) metadata(opts *UploadOpts) map[string]string {
+	m := map[string]string{}
+	var tags []string
+	version := ""
+	if s != nil {
+		for k, v := range s.Metadata {
+			m[k] = v
+		}
+		if s.ID != "" {
+			m[MetadataSession] = s.ID
+		}
+		tags = append(tags, s.Tags...)
+		version = s.DatasetVersion
+	}
+	if opts != nil {
+		for k, v := range opts.Metadata {
+			m[k] = v
+		}
+		tags = append(tags, opts.Tags...)
+		if opts.DatasetVersion != "" {
+			version = opts.DatasetVersion
+		}
+	}
+	if len(tags) > 0 {
+		m[MetadataTags] = strings.Join(uniqueTags(tags), ",")
+	}
+	if version != "" {
+		m[MetadataDatasetVersion] = version
+	}
+	return m
+}
+
+// uniqueTags returns the sorted tags without duplicates.
+func uniqueTags(tags []string) []string {
+	seen := map[string]bool{}
+	var l []string
+	for _, t := range tags {
+		if !seen[t] {
+			seen[t] = true
+			l = append(l, t)
+		}
+	}
+	sort.Strings(l)
+	return l
+}
@@ -0,0 +1,35 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSessionMetadata(t *testing.T) {
+	s := &Session{ID: "s1", Tags: []string{"field", "night"}, DatasetVersion: "v1", Metadata: map[string]string{"site": "a", "score": "0"}}
+	opts := &UploadOpts{Tags: []string{"night", "blurry"}, Metadata: map[string]string{"score": "0.5"}}
+	expect := map[string]string{
+		"site":            "a",
+		"score":           "0.5",
+		"session":         "s1",
+		"tags":            "blurry,field,night",
+		"dataset_version": "v1",
+	}
+	if m := s.metadata(opts); !reflect.DeepEqual(m, expect) {
+		t.Errorf("got %v, expected %v", m, expect)
+	}
+
+	opts.DatasetVersion = "v2"
+	if m := s.metadata(opts); m["dataset_version"] != "v2" {
+		t.Errorf("got dataset version %q, expected v2 of opts", m["dataset_version"])
+	}
+
+	var none *Session
+	if m := none.metadata(nil); len(m) != 0 {
+		t.Errorf("got %v without session and opts, expected no metadata", m)
+	}
+
+	if tags := ParseTags(" a,,b ,"); !reflect.DeepEqual(tags, []string{"a", "b"}) {
+		t.Errorf("got tags %q, expected a and b", tags)
+	}
+}
@@ -0,0 +1,159 @@
+// Package classifier implements what the classifiers of packages audio, image
+// and timeseries share: stopping on Close or cancellation of their context,
+// recovering from panics, calling handlers for events, and statistics.
+package classifier
+
+import (
+	"context"
+	"sync"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Opts are the options all classifiers have.
+type Opts struct {
+	Verbose  bool
+	Logger   edgeimpulse.Logger
+	Tracer   edgeimpulse.Tracer
+	Clock    edgeimpulse.Clock
+	Handlers bool // Whether events are passed to handlers, see Handle.
+}
+
+// Loop runs the goroutines of a classifier, and stops them on Close or when
+// its context is canceled.
+type Loop struct {
+	Logger edgeimpulse.Logger // From Opts, or the default.
+	Tracer edgeimpulse.Tracer // From Opts, or the default.
+	Clock  edgeimpulse.Clock  // From Opts, or the default.
+	Stats  *edgeimpulse.Stats // Of the classifications.
+
+	ctx      context.Context
+	handlers bool
+	stop     chan struct{} // Closed by Close.
+	stopOnce sync.Once
+	quit     chan struct{} // Closed by Close, or when ctx is done.
+	done     chan struct{} // Closed when the classifying goroutine is done.
+	handled  chan struct{} // Closed when the handler goroutine is done, if any.
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// New returns a loop for a classifier with opts, stopped when ctx is done.
+func New(ctx context.Context, opts Opts) *Loop {
+	l := &Loop{
+		Logger:   edgeimpulse.DefaultLogger(opts.Logger, opts.Verbose),
+		Tracer:   edgeimpulse.DefaultTracer(opts.Tracer),
+		Clock:    edgeimpulse.DefaultClock(opts.Clock),
+		Stats:    &edgeimpulse.Stats{},
+		ctx:      ctx,
+		handlers: opts.Handlers,
+		stop:     make(chan struct{}),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	return l
+}
+
+// Context returns the context of the classifier.
+func (l *Loop) Context() context.Context {
+	return l.ctx
+}
+
+// Done is closed when the classifier is closed or its context is done, after
+// which its goroutines stop, and sending events fails.
+func (l *Loop) Done() <-chan struct{} {
+	return l.quit
+}
+
+// Run runs fn in the goroutine that classifies and sends events, see Go. Close
+// waits for it to return, and for done to be called, e.g. to close the
+// channel of events. Run must be called once, before other goroutines are
+// started.
+func (l *Loop) Run(fn func(), onPanic func(err error), done func()) {
+	go func() {
+		defer close(l.quit)
+		select {
+		case <-l.stop:
+		case <-l.ctx.Done():
+		}
+	}()
+	l.Go(fn, onPanic, func() {
+		defer close(l.done)
+		done()
+	})
+}
+
+// Go runs fn in a new goroutine. If fn panics, the panic is recovered as error
+// for Err, and passed to onPanic, e.g. to send it as event. Then done is
+// called, if not nil.
+func (l *Loop) Go(fn func(), onPanic func(err error), done func()) {
+	go func() {
+		if done != nil {
+			defer done()
+		}
+		defer l.recover(onPanic)
+		fn()
+	}()
+}
+
+func (l *Loop) recover(onPanic func(err error)) {
+	if x := recover(); x != nil {
+		err := edgeimpulse.PanicError(x)
+		l.mutex.Lock()
+		l.err = err
+		l.mutex.Unlock()
+		onPanic(err)
+	}
+}
+
+// Deliver sends the event of a classification with send, and ends its trace
+// once the event is delivered: with handlers, after the handler goroutine
+// calls the handler and then Trace.End, and otherwise after sending.
+func (l *Loop) Deliver(t *Trace, send func() bool) bool {
+	ok := send()
+	if !ok || !l.handlers {
+		t.End()
+	}
+	return ok
+}
+
+// Handle runs fn in a new goroutine that calls the handlers for events, until
+// the events are closed. Close waits for it to return. Handle must be called
+// once if Opts.Handlers is set, before Close.
+func (l *Loop) Handle(fn func()) {
+	l.handled = make(chan struct{})
+	go func() {
+		defer close(l.handled)
+		fn()
+	}()
+}
+
+// HandleError passes err to onError, or logs it if onError is nil.
+func (l *Loop) HandleError(err error, onError func(err error)) {
+	if onError != nil {
+		onError(err)
+	} else {
+		l.Logger.Logf(edgeimpulse.LogError, "%s", err)
+	}
+}
+
+// Close stops the goroutines, and waits for the classifying and handler
+// goroutines to return. Close can be called multiple times.
+func (l *Loop) Close() error {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	<-l.done
+	if l.handled != nil {
+		<-l.handled
+	}
+	return nil
+}
+
+// Err returns the error recovered from a panic, or nil.
+func (l *Loop) Err() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.err
+}
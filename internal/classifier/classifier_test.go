@@ -0,0 +1,33 @@
+package classifier
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLoop(t *testing.T) {
+	l := New(context.Background(), Opts{})
+	events := make(chan error, 1)
+	l.Run(func() {
+		panic("bad input")
+	}, func(err error) {
+		events <- err
+	}, func() {
+		close(events)
+	})
+	var handled []error
+	l.Handle(func() {
+		for err := range events {
+			handled = append(handled, err)
+		}
+	})
+	l.Close()
+	if len(handled) != 1 || handled[0] != l.Err() || l.Err() == nil {
+		t.Fatalf("handled %v, err %v, expected the recovered panic", handled, l.Err())
+	}
+	select {
+	case <-l.Done():
+	default:
+		t.Fatalf("loop not done after close")
+	}
+}
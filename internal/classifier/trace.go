@@ -0,0 +1,76 @@
+package classifier
+
+import (
+	"context"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Trace holds the spans of one frame or window of samples: a span for the
+// frame, with child spans for its stages, started one after another: capture,
+// e.g. preprocess and classify, and postprocess.
+type Trace struct {
+	tracer edgeimpulse.Tracer
+	ctx    context.Context  // Of the frame span.
+	frame  edgeimpulse.Span // Nil after End.
+	stage  edgeimpulse.Span // Of the current stage, or nil.
+}
+
+// StartTrace starts span name for a frame, e.g. "eim.frame", and its
+// "eim.capture" stage, before waiting for input.
+func (l *Loop) StartTrace(name string) *Trace {
+	ctx, span := l.Tracer.Start(l.ctx, name)
+	t := &Trace{tracer: l.Tracer, ctx: ctx, frame: span}
+	t.Stage("eim.capture")
+	return t
+}
+
+// SetAttributes sets attrs on the frame span, e.g. the ID of the frame once
+// captured.
+func (t *Trace) SetAttributes(attrs ...edgeimpulse.Attribute) {
+	t.frame.SetAttributes(attrs...)
+}
+
+// Stage ends the current stage, and starts stage name. It returns the context
+// of the stage, e.g. for the event of the "eim.postprocess" stage.
+func (t *Trace) Stage(name string) context.Context {
+	t.endStage()
+	ctx, span := t.tracer.Start(t.ctx, name)
+	t.stage = span
+	return ctx
+}
+
+// StageAttributes sets attrs on the span of the current stage.
+func (t *Trace) StageAttributes(attrs ...edgeimpulse.Attribute) {
+	t.stage.SetAttributes(attrs...)
+}
+
+func (t *Trace) endStage() {
+	if t.stage != nil {
+		t.stage.End()
+		t.stage = nil
+	}
+}
+
+// Fail records err in the current stage and the frame, and ends them.
+func (t *Trace) Fail(err error) {
+	if t.frame == nil {
+		return
+	}
+	if t.stage != nil {
+		t.stage.RecordError(err)
+	}
+	t.frame.RecordError(err)
+	t.End()
+}
+
+// End ends the current stage and the frame, e.g. once the event of the frame
+// is delivered. End does nothing on a nil or ended trace.
+func (t *Trace) End() {
+	if t == nil || t.frame == nil {
+		return
+	}
+	t.endStage()
+	t.frame.End()
+	t.frame = nil
+}
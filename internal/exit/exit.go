@@ -0,0 +1,67 @@
+// Package exit defines the exit codes of the commands, and writes fatal errors
+// as text or JSON, so supervisors and scripts can react to failures without
+// parsing log messages.
+package exit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Exit codes used by all commands.
+const (
+	OK      = 0 // Success.
+	Runtime = 1 // Error while running, e.g. a failed classification or upload.
+	Usage   = 2 // Invalid command-line usage, as used by package flag.
+	Config  = 3 // Invalid configuration or input, e.g. a bad flag value or unreadable input file.
+	Model   = 4 // The model could not be loaded or started.
+	Device  = 5 // No device available, or a device could not be opened.
+
+	NotDetected = 6 // In single-shot mode, no label passed the threshold.
+)
+
+var kinds = map[int]string{
+	OK:      "ok",
+	Runtime: "runtime",
+	Usage:   "usage",
+	Config:  "config",
+	Model:   "model",
+	Device:  "device",
+
+	NotDetected: "not_detected",
+}
+
+// Format is the format for errors written by Errorf and Fatalf, either "text"
+// or "json". Commands set it with their -error-format flag.
+var Format = "text"
+
+// Error is the JSON representation of an error.
+type Error struct {
+	Code  int    `json:"code"`
+	Kind  string `json:"kind"` // E.g. "model" or "device".
+	Error string `json:"error"`
+}
+
+// Errorf writes an error message in Format, and returns code, for returning
+// from a main function.
+func Errorf(code int, format string, args ...interface{}) int {
+	msg := fmt.Sprintf(format, args...)
+	if Format != "json" {
+		log.Print(msg)
+		return code
+	}
+	buf, err := json.Marshal(Error{code, kinds[code], msg})
+	if err != nil {
+		log.Print(msg)
+		return code
+	}
+	fmt.Fprintln(os.Stderr, string(buf))
+	return code
+}
+
+// Fatalf writes an error message like Errorf, and exits with code.
+func Fatalf(code int, format string, args ...interface{}) {
+	os.Exit(Errorf(code, format, args...))
+}
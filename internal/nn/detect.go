@@ -0,0 +1,120 @@
+package nn
+
+import (
+	"fmt"
+	"math"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// fomo returns boxes from the output of a FOMO network: a grid of shape [1
+// rows columns labels+1] with scores per cell, background first. Adjacent
+// cells of the same label with at least threshold are merged into a box, in
+// pixels of the input image, with the highest score of its cells.
+func fomo(values []float64, shape []int, mp edgeimpulse.ModelParameters, threshold float64) []edgeimpulse.BoundingBox {
+	if len(shape) != 4 || shape[3] != len(mp.Labels)+1 {
+		return nil
+	}
+	rows, cols, n := shape[1], shape[2], shape[3]
+	cellW := mp.ImageInputWidth / cols
+	cellH := mp.ImageInputHeight / rows
+
+	// Label of each cell, -1 for background.
+	labels := make([]int, rows*cols)
+	scores := make([]float64, rows*cols)
+	for i := range labels {
+		labels[i] = -1
+		for l := 1; l < n; l++ {
+			if v := values[i*n+l]; v >= threshold && v > scores[i] {
+				labels[i] = l - 1
+				scores[i] = v
+			}
+		}
+	}
+
+	var boxes []edgeimpulse.BoundingBox
+	seen := make([]bool, rows*cols)
+	for i, l := range labels {
+		if l < 0 || seen[i] {
+			continue
+		}
+		minX, minY, maxX, maxY := cols, rows, -1, -1
+		var score float64
+		stack := []int{i}
+		seen[i] = true
+		for len(stack) > 0 {
+			j := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := j%cols, j/cols
+			minX, maxX = minInt(minX, x), maxInt(maxX, x)
+			minY, maxY = minInt(minY, y), maxInt(maxY, y)
+			score = math.Max(score, scores[j])
+			for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				nx, ny := x+d[0], y+d[1]
+				if nx < 0 || nx >= cols || ny < 0 || ny >= rows {
+					continue
+				}
+				k := ny*cols + nx
+				if !seen[k] && labels[k] == l {
+					seen[k] = true
+					stack = append(stack, k)
+				}
+			}
+		}
+		boxes = append(boxes, edgeimpulse.BoundingBox{
+			Label:  mp.Labels[l],
+			Value:  score,
+			X:      minX * cellW,
+			Y:      minY * cellH,
+			Width:  (maxX - minX + 1) * cellW,
+			Height: (maxY - minY + 1) * cellH,
+		})
+	}
+	return boxes
+}
+
+// ssd returns boxes from the outputs of an SSD network, in the order of the
+// TensorFlow detection postprocessing: boxes [1 n 4] with normalized ymin,
+// xmin, ymax, xmax, classes [1 n], scores [1 n], and the count [1].
+func ssd(outputs [][]float64, tensors []Tensor, mp edgeimpulse.ModelParameters, threshold float64) ([]edgeimpulse.BoundingBox, error) {
+	if len(outputs) != 4 || len(tensors[0].Shape) != 3 || tensors[0].Shape[2] != 4 {
+		return nil, fmt.Errorf("unsupported object detection outputs, expected boxes, classes, scores and count")
+	}
+	coords, classes, scores := outputs[0], outputs[1], outputs[2]
+	count := len(scores)
+	if len(outputs[3]) == 1 && int(outputs[3][0]) < count {
+		count = int(outputs[3][0])
+	}
+	w, h := float64(mp.ImageInputWidth), float64(mp.ImageInputHeight)
+	var boxes []edgeimpulse.BoundingBox
+	for i := 0; i < count; i++ {
+		c := int(classes[i])
+		if scores[i] < threshold || c < 0 || c >= len(mp.Labels) {
+			continue
+		}
+		ymin, xmin, ymax, xmax := coords[4*i], coords[4*i+1], coords[4*i+2], coords[4*i+3]
+		boxes = append(boxes, edgeimpulse.BoundingBox{
+			Label:  mp.Labels[c],
+			Value:  scores[i],
+			X:      int(math.Round(xmin * w)),
+			Y:      int(math.Round(ymin * h)),
+			Width:  int(math.Round((xmax - xmin) * w)),
+			Height: int(math.Round((ymax - ymin) * h)),
+		})
+	}
+	return boxes, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,149 @@
+package nn
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Model implements edgeimpulse.Runner with an Engine.
+type Model struct {
+	engine     Engine
+	info       edgeimpulse.ModelInfo
+	preprocess func([]float64) ([]float64, error)
+	threshold  float64
+
+	mutex  sync.Mutex
+	closed bool
+}
+
+// NewModel returns a runner for engine, with the model parameters and project
+// from info. Preprocess, if not nil, turns features into the input of the
+// network, e.g. a DSP block. Otherwise packed pixels of image models are
+// scaled to 0 to 1, and other features are passed as is, as for raw data
+// blocks, or image features already scaled as set by ImageFeatureScaling of
+// the model parameters. Threshold is the minimum score of bounding boxes, 0.5 if 0.
+//
+// On errors, engine is closed.
+func NewModel(engine Engine, info edgeimpulse.ModelInfo, preprocess func([]float64) ([]float64, error), threshold float64) (*Model, error) {
+	if threshold == 0 {
+		threshold = 0.5
+	}
+	m := &Model{engine: engine, info: info, preprocess: preprocess, threshold: threshold}
+	in := engine.Input()
+	mp := info.ModelParameters
+	if preprocess == nil {
+		n := mp.InputFeaturesCount
+		if mp.SensorType == edgeimpulse.SensorTypeCamera {
+			n = mp.ImageInputWidth * mp.ImageInputHeight * mp.ImageChannelCount
+		}
+		if n != in.Len() {
+			engine.Close()
+			return nil, fmt.Errorf("input tensor has %d values, model parameters need %d", in.Len(), n)
+		}
+	}
+	if mp.ModelType == edgeimpulse.ModelTypeClassification {
+		if out := engine.Outputs(); len(out) != 1 || out[0].Len() != len(mp.Labels) {
+			engine.Close()
+			return nil, fmt.Errorf("output tensors do not match %d labels", len(mp.Labels))
+		}
+	}
+	if mp.ModelType.ObjectDetection() {
+		// Report the minimum score like a model process would.
+		m.info.ModelParameters.Thresholds = []edgeimpulse.Threshold{
+			{Type: edgeimpulse.ThresholdObjectDetection, MinScore: threshold},
+		}
+	}
+	return m, nil
+}
+
+// ModelParameters returns the model parameters from the model info.
+func (m *Model) ModelParameters() edgeimpulse.ModelParameters {
+	return m.info.ModelParameters
+}
+
+// Project returns the project from the model info.
+func (m *Model) Project() edgeimpulse.Project {
+	return m.info.Project
+}
+
+// Classify runs the network on features, in the format of a model process.
+func (m *Model) Classify(features []float64) (resp edgeimpulse.RunnerClassifyResponse, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return resp, fmt.Errorf("model closed")
+	}
+
+	mp := m.info.ModelParameters
+	start := time.Now()
+	values := features
+	if m.preprocess != nil {
+		values, err = m.preprocess(features)
+		if err != nil {
+			return resp, fmt.Errorf("preprocessing: %w", err)
+		}
+	} else if mp.SensorType == edgeimpulse.SensorTypeCamera && packed(mp) {
+		values = Pixels(features, mp.ImageChannelCount)
+	}
+	in := m.engine.Input()
+	if mp.SensorType == edgeimpulse.SensorTypeCamera && m.preprocess == nil && channelsFirst(in, mp) {
+		values = planar(values, mp.ImageChannelCount)
+	}
+	if len(values) != in.Len() {
+		return resp, fmt.Errorf("got %d values, input tensor has %d", len(values), in.Len())
+	}
+	dsp := time.Since(start)
+
+	start = time.Now()
+	bufs, err := m.engine.Invoke(Quantize(values, in))
+	if err != nil {
+		return resp, fmt.Errorf("invoke: %w", err)
+	}
+	var outputs [][]float64
+	for i, t := range m.engine.Outputs() {
+		outputs = append(outputs, Dequantize(bufs[i], t))
+	}
+
+	switch mp.ModelType {
+	case edgeimpulse.ModelTypeClassification:
+		resp.Result.Classification = map[string]float64{}
+		for i, l := range mp.Labels {
+			resp.Result.Classification[l] = outputs[0][i]
+		}
+	default:
+		var boxes []edgeimpulse.BoundingBox
+		if len(outputs) == 1 {
+			boxes = fomo(outputs[0], m.engine.Outputs()[0].Shape, mp, m.threshold)
+		} else {
+			boxes, err = ssd(outputs, m.engine.Outputs(), mp, m.threshold)
+			if err != nil {
+				return resp, err
+			}
+		}
+		resp.Result.BoundingBoxes = boxes
+	}
+	resp.Success = true
+	resp.Timing.DSP = float64(dsp) / float64(time.Millisecond)
+	resp.Timing.Classification = float64(time.Since(start)) / float64(time.Millisecond)
+	return resp, nil
+}
+
+// packed returns whether image features are packed RGB pixels, instead of
+// values per channel scaled for the network.
+func packed(mp edgeimpulse.ModelParameters) bool {
+	return mp.ImageFeatureScaling == "" || mp.ImageFeatureScaling == "packed"
+}
+
+// Close closes the engine.
+func (m *Model) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return nil
+	}
+	m.closed = true
+	return m.engine.Close()
+}
@@ -0,0 +1,166 @@
+// Package nn runs neural networks exported from Edge Impulse Studio
+// in-process, with an inference engine such as TensorFlow Lite. It converts
+// features to the input tensor of the network, and the output tensors to
+// classifications or bounding boxes, like a model process does.
+package nn
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// DataType is the type of the elements of a tensor.
+type DataType int
+
+// Data types of tensors supported for inputs and outputs.
+const (
+	Float32 DataType = iota
+	Int8
+	Uint8
+)
+
+func (t DataType) String() string {
+	switch t {
+	case Float32:
+		return "float32"
+	case Int8:
+		return "int8"
+	case Uint8:
+		return "uint8"
+	}
+	return fmt.Sprintf("datatype(%d)", int(t))
+}
+
+// size returns the number of bytes of an element.
+func (t DataType) size() int {
+	if t == Float32 {
+		return 4
+	}
+	return 1
+}
+
+// Tensor describes an input or output tensor of a network.
+type Tensor struct {
+	Type  DataType
+	Shape []int
+
+	// For quantized tensors, a value is Scale*(q-ZeroPoint).
+	Scale     float64
+	ZeroPoint int
+}
+
+// Len returns the number of elements of the tensor.
+func (t Tensor) Len() int {
+	n := 1
+	for _, d := range t.Shape {
+		n *= d
+	}
+	return n
+}
+
+// Bytes returns the size of the data of the tensor.
+func (t Tensor) Bytes() int {
+	return t.Len() * t.Type.size()
+}
+
+// Engine is a loaded network of an inference engine.
+type Engine interface {
+	// Input and Outputs describe the tensors of the network.
+	Input() Tensor
+	Outputs() []Tensor
+
+	// Invoke runs the network on the data of the input tensor, returning
+	// the data of the output tensors. Invoke is not called concurrently.
+	Invoke(input []byte) ([][]byte, error)
+
+	Close() error
+}
+
+// Quantize returns the data of tensor t holding values.
+func Quantize(values []float64, t Tensor) []byte {
+	buf := make([]byte, len(values)*t.Type.size())
+	for i, v := range values {
+		switch t.Type {
+		case Float32:
+			binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(v)))
+		case Int8:
+			buf[i] = byte(int8(quantize(v, t, -128, 127)))
+		case Uint8:
+			buf[i] = byte(quantize(v, t, 0, 255))
+		}
+	}
+	return buf
+}
+
+func quantize(v float64, t Tensor, min, max int) int {
+	scale := t.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	q := int(math.Round(v/scale)) + t.ZeroPoint
+	if q < min {
+		return min
+	}
+	if q > max {
+		return max
+	}
+	return q
+}
+
+// Dequantize returns the values of the data buf of tensor t.
+func Dequantize(buf []byte, t Tensor) []float64 {
+	n := len(buf) / t.Type.size()
+	values := make([]float64, n)
+	for i := range values {
+		switch t.Type {
+		case Float32:
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+		case Int8:
+			values[i] = t.Scale * float64(int(int8(buf[i]))-t.ZeroPoint)
+		case Uint8:
+			values[i] = t.Scale * float64(int(buf[i])-t.ZeroPoint)
+		}
+	}
+	return values
+}
+
+// Pixels returns the input of an image network for features of packed RGB
+// pixels, as sent by image classifiers: per pixel, channels values between 0
+// and 1. For a single channel, the luminance is used, like Studio does.
+func Pixels(features []float64, channels int) []float64 {
+	values := make([]float64, 0, len(features)*channels)
+	for _, f := range features {
+		v := uint32(f)
+		r := float64((v>>16)&0xff) / 255
+		g := float64((v>>8)&0xff) / 255
+		b := float64(v&0xff) / 255
+		if channels == 1 {
+			values = append(values, 0.299*r+0.587*g+0.114*b)
+		} else {
+			values = append(values, r, g, b)
+		}
+	}
+	return values
+}
+
+// channelsFirst returns whether image input tensor t has shape [1 channels
+// height width], as for networks trained with PyTorch, instead of [1 height
+// width channels].
+func channelsFirst(t Tensor, mp edgeimpulse.ModelParameters) bool {
+	c := mp.ImageChannelCount
+	return len(t.Shape) == 4 && t.Shape[1] == c && t.Shape[2] == mp.ImageInputHeight && t.Shape[3] == mp.ImageInputWidth && t.Shape[3] != c
+}
+
+// planar reorders interleaved pixel values, e.g. RGBRGB, to planes of
+// channels, e.g. RRGGBB.
+func planar(values []float64, channels int) []float64 {
+	n := len(values) / channels
+	out := make([]float64, len(values))
+	for i, v := range values {
+		out[(i%channels)*n+i/channels] = v
+	}
+	return out
+}
@@ -0,0 +1,168 @@
+package nn
+
+import (
+	"math"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestQuantize(t *testing.T) {
+	for _, tensor := range []Tensor{
+		{Type: Float32},
+		{Type: Int8, Scale: 1.0 / 255, ZeroPoint: -128},
+		{Type: Uint8, Scale: 1.0 / 255},
+	} {
+		values := []float64{0, 0.5, 1}
+		got := Dequantize(Quantize(values, tensor), tensor)
+		for i := range values {
+			if math.Abs(got[i]-values[i]) > 0.01 {
+				t.Errorf("%s: got %v, expected %v", tensor.Type, got, values)
+				break
+			}
+		}
+	}
+
+	// Values out of range are clamped.
+	tensor := Tensor{Type: Int8, Scale: 0.1}
+	if got := Quantize([]float64{100, -100}, tensor); int8(got[0]) != 127 || int8(got[1]) != -128 {
+		t.Errorf("got %v, expected clamped values", got)
+	}
+}
+
+func TestPixels(t *testing.T) {
+	features := []float64{0xff0000, 0x00ff00}
+	got := Pixels(features, 3)
+	expected := []float64{1, 0, 0, 0, 1, 0}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("got %v, expected %v", got, expected)
+		}
+	}
+	if got := Pixels([]float64{0xffffff}, 1); len(got) != 1 || math.Abs(got[0]-1) > 1e-9 {
+		t.Errorf("got %v, expected [1]", got)
+	}
+}
+
+func TestPlanar(t *testing.T) {
+	got := planar([]float64{1, 2, 3, 4, 5, 6}, 3)
+	expected := []float64{1, 4, 2, 5, 3, 6}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("got %v, expected %v", got, expected)
+		}
+	}
+}
+
+type fakeEngine struct {
+	in     Tensor
+	out    []Tensor
+	result [][]float64
+	closed bool
+}
+
+func (e *fakeEngine) Input() Tensor     { return e.in }
+func (e *fakeEngine) Outputs() []Tensor { return e.out }
+func (e *fakeEngine) Close() error      { e.closed = true; return nil }
+
+func (e *fakeEngine) Invoke(input []byte) ([][]byte, error) {
+	var bufs [][]byte
+	for i, t := range e.out {
+		bufs = append(bufs, Quantize(e.result[i], t))
+	}
+	return bufs, nil
+}
+
+func TestModel(t *testing.T) {
+	info := edgeimpulse.ModelInfo{
+		ModelParameters: edgeimpulse.ModelParameters{
+			ModelType:          edgeimpulse.ModelTypeClassification,
+			SensorType:         edgeimpulse.SensorTypeCamera,
+			ImageInputWidth:    2,
+			ImageInputHeight:   2,
+			ImageChannelCount:  1,
+			InputFeaturesCount: 4,
+			Labels:             []string{"a", "b"},
+		},
+	}
+	engine := &fakeEngine{
+		in:     Tensor{Type: Int8, Shape: []int{1, 2, 2, 1}, Scale: 1.0 / 255, ZeroPoint: -128},
+		out:    []Tensor{{Type: Float32, Shape: []int{1, 2}}},
+		result: [][]float64{{0.25, 0.75}},
+	}
+	m, err := NewModel(engine, info, nil, 0)
+	if err != nil {
+		t.Fatalf("new model: %v", err)
+	}
+	resp, err := m.Classify([]float64{0, 0, 0, 0})
+	if err != nil {
+		t.Fatalf("classify: %v", err)
+	}
+	if !resp.Success || resp.Result.Classification["b"] != 0.75 {
+		t.Errorf("got %v", resp)
+	}
+	if _, err := m.Classify([]float64{0}); err == nil {
+		t.Errorf("classify with wrong number of features succeeded")
+	}
+	m.Close()
+	if !engine.closed {
+		t.Errorf("engine not closed")
+	}
+
+	engine = &fakeEngine{in: Tensor{Type: Float32, Shape: []int{1, 3}}}
+	if _, err := NewModel(engine, info, nil, 0); err == nil || !engine.closed {
+		t.Errorf("new model with mismatched input succeeded, or did not close engine")
+	}
+}
+
+func TestFOMO(t *testing.T) {
+	mp := edgeimpulse.ModelParameters{
+		ImageInputWidth:  16,
+		ImageInputHeight: 16,
+		Labels:           []string{"a", "b"},
+	}
+	// 2x2 grid, background first: two adjacent cells of a, one of b.
+	values := []float64{
+		0.1, 0.9, 0,
+		0.2, 0.7, 0.1,
+		0.1, 0, 0.9,
+		1, 0, 0,
+	}
+	boxes := fomo(values, []int{1, 2, 2, 3}, mp, 0.5)
+	if len(boxes) != 2 {
+		t.Fatalf("got %d boxes, expected 2: %v", len(boxes), boxes)
+	}
+	if b := boxes[0]; b.Label != "a" || b.Value != 0.9 || b.X != 0 || b.Y != 0 || b.Width != 16 || b.Height != 8 {
+		t.Errorf("got first box %+v", b)
+	}
+	if b := boxes[1]; b.Label != "b" || b.X != 0 || b.Y != 8 || b.Width != 8 || b.Height != 8 {
+		t.Errorf("got second box %+v", b)
+	}
+}
+
+func TestSSD(t *testing.T) {
+	mp := edgeimpulse.ModelParameters{
+		ImageInputWidth:  100,
+		ImageInputHeight: 100,
+		Labels:           []string{"a", "b"},
+	}
+	tensors := []Tensor{
+		{Shape: []int{1, 2, 4}},
+		{Shape: []int{1, 2}},
+		{Shape: []int{1, 2}},
+		{Shape: []int{1}},
+	}
+	outputs := [][]float64{
+		{0.1, 0.2, 0.5, 0.6, 0, 0, 1, 1},
+		{1, 0},
+		{0.8, 0.3},
+		{2},
+	}
+	boxes, err := ssd(outputs, tensors, mp, 0.5)
+	if err != nil {
+		t.Fatalf("ssd: %v", err)
+	}
+	if len(boxes) != 1 || boxes[0] != (edgeimpulse.BoundingBox{Label: "b", Value: 0.8, X: 20, Y: 10, Width: 40, Height: 40}) {
+		t.Errorf("got %+v", boxes)
+	}
+}
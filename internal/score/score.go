@@ -0,0 +1,37 @@
+// Package score finds scores of labels in classification results, for the
+// commands that act on a label, e.g. to fire a trigger or upload a sample.
+package score
+
+import (
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Label returns the score for label in the classification of resp, or the
+// highest score of its bounding boxes with that label.
+func Label(resp edgeimpulse.RunnerClassifyResponse, label string) float64 {
+	score := resp.Result.Classification[label]
+	for _, b := range resp.Result.BoundingBoxes {
+		if b.Label == label && b.Value > score {
+			score = b.Value
+		}
+	}
+	return score
+}
+
+// Top returns the label with the highest score in the classification or
+// bounding boxes of resp.
+func Top(resp edgeimpulse.RunnerClassifyResponse) (string, float64) {
+	var label string
+	var score float64
+	for l, v := range resp.Result.Classification {
+		if v > score {
+			label, score = l, v
+		}
+	}
+	for _, b := range resp.Result.BoundingBoxes {
+		if b.Value > score {
+			label, score = b.Label, b.Value
+		}
+	}
+	return label, score
+}
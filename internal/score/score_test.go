@@ -0,0 +1,23 @@
+package score
+
+import (
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestScore(t *testing.T) {
+	var resp edgeimpulse.RunnerClassifyResponse
+	resp.Result.Classification = map[string]float64{"cat": 0.3, "dog": 0.2}
+	resp.Result.BoundingBoxes = []edgeimpulse.BoundingBox{{Label: "dog", Value: 0.4}, {Label: "dog", Value: 0.1}}
+
+	if s := Label(resp, "dog"); s != 0.4 {
+		t.Errorf("got score %v for dog, expected 0.4", s)
+	}
+	if s := Label(resp, "bird"); s != 0 {
+		t.Errorf("got score %v for bird, expected 0", s)
+	}
+	if l, s := Top(resp); l != "dog" || s != 0.4 {
+		t.Errorf("got top %s %v, expected dog 0.4", l, s)
+	}
+}
@@ -0,0 +1,270 @@
+// Package websocket implements a minimal RFC 6455 WebSocket client, for
+// talking to Edge Impulse services without external dependencies. Messages
+// are read and written whole, extensions are not supported.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Opcodes of frames.
+const (
+	OpContinuation = 0
+	OpText         = 1
+	OpBinary       = 2
+	OpClose        = 8
+	OpPing         = 9
+	OpPong         = 10
+)
+
+// maxMessageSize limits the size of messages read, to protect against bad
+// peers.
+const maxMessageSize = 16 * 1024 * 1024
+
+// ErrClosed is returned when reading from a connection that the peer closed.
+var ErrClosed = errors.New("websocket: connection closed")
+
+// Conn is a WebSocket connection.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mutex sync.Mutex // Serializes writes.
+}
+
+// Dial connects to a ws:// or wss:// URL and performs the opening handshake.
+func Dial(ctx context.Context, rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %w", err)
+	}
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host += ":80"
+		}
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", host)
+	case "wss":
+		if u.Port() == "" {
+			host += ":443"
+		}
+		d := tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = d.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q, must be ws or wss", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial: %w", err)
+	}
+	c, err := handshake(ctx, conn, u)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake sends the upgrade request and verifies the response.
+func handshake(ctx context.Context, conn net.Conn, u *url.URL) (*Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req, err := http.NewRequest("GET", (&url.URL{Scheme: "http", Host: u.Host, Path: u.Path, RawQuery: u.RawQuery}).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("writing handshake: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, fmt.Errorf("reading handshake: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("handshake: unexpected status %s", resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != AcceptKey(key) {
+		return nil, fmt.Errorf("handshake: bad Sec-WebSocket-Accept")
+	}
+	return &Conn{conn: conn, r: r}, nil
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept value for key.
+func AcceptKey(key string) string {
+	h := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// WriteMessage writes a single-frame message.
+func (c *Conn) WriteMessage(opcode int, payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return WriteFrame(c.conn, opcode, payload, true)
+}
+
+// WriteFrame writes a final frame with opcode and payload to w, masking the
+// payload if mask is set.
+func WriteFrame(w io.Writer, opcode int, payload []byte, mask bool) error {
+	buf := []byte{0x80 | byte(opcode), 0}
+	n := len(payload)
+	switch {
+	case n < 126:
+		buf[1] = byte(n)
+	case n <= 0xffff:
+		buf[1] = 126
+		buf = append(buf, byte(n>>8), byte(n))
+	default:
+		buf[1] = 127
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		buf = append(buf, ext[:]...)
+	}
+	if mask {
+		buf[1] |= 0x80
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		buf = append(buf, key[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		for i := range payload {
+			buf[start+i] ^= key[i%4]
+		}
+	} else {
+		buf = append(buf, payload...)
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadMessage reads the next text or binary message, joining fragmented
+// frames. Pings are answered, pongs are skipped. If the peer closes the
+// connection, ErrClosed is returned.
+func (c *Conn) ReadMessage() (opcode int, payload []byte, err error) {
+	var msg []byte
+	msgOpcode := -1
+	for {
+		fin, op, data, err := ReadFrame(c.r)
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case OpPing:
+			c.mutex.Lock()
+			err := WriteFrame(c.conn, OpPong, data, true)
+			c.mutex.Unlock()
+			if err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			c.mutex.Lock()
+			WriteFrame(c.conn, OpClose, nil, true)
+			c.mutex.Unlock()
+			return 0, nil, ErrClosed
+		case OpContinuation:
+			if msgOpcode < 0 {
+				return 0, nil, fmt.Errorf("websocket: continuation without message")
+			}
+		default:
+			if msgOpcode >= 0 {
+				return 0, nil, fmt.Errorf("websocket: new message in fragmented message")
+			}
+			msgOpcode = op
+		}
+		if len(msg)+len(data) > maxMessageSize {
+			return 0, nil, fmt.Errorf("websocket: message too large")
+		}
+		msg = append(msg, data...)
+		if fin {
+			return msgOpcode, msg, nil
+		}
+	}
+}
+
+// ReadFrame reads a frame from r, unmasking its payload if needed.
+func ReadFrame(r io.Reader) (fin bool, opcode int, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = hdr[0]&0x80 != 0
+	opcode = int(hdr[0] & 0x0f)
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > maxMessageSize {
+		return false, 0, nil, fmt.Errorf("websocket: frame too large")
+	}
+	var key [4]byte
+	masked := hdr[1]&0x80 != 0
+	if masked {
+		if _, err := io.ReadFull(r, key[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+// Close sends a close frame and closes the connection.
+func (c *Conn) Close() error {
+	c.mutex.Lock()
+	WriteFrame(c.conn, OpClose, nil, true)
+	c.mutex.Unlock()
+	return c.conn.Close()
+}
@@ -0,0 +1,45 @@
+package location
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// gpsdParser parses the JSON reports of gpsd. Only TPV reports, with the
+// time-position-velocity of the receiver, are used.
+type gpsdParser struct{}
+
+type gpsdTPV struct {
+	Class  string    `json:"class"`
+	Mode   int       `json:"mode"` // 0 or 1 no fix, 2 for 2D, 3 for 3D.
+	Time   time.Time `json:"time"`
+	Lat    float64   `json:"lat"`
+	Lon    float64   `json:"lon"`
+	Alt    float64   `json:"alt"`    // Older gpsd versions.
+	AltMSL float64   `json:"altMSL"` // Since gpsd 3.20.
+	Speed  float64   `json:"speed"`
+	Track  float64   `json:"track"`
+}
+
+func (gpsdParser) parse(line string, fix *Fix) (ok, valid bool) {
+	var tpv gpsdTPV
+	if err := json.Unmarshal([]byte(line), &tpv); err != nil || tpv.Class != "TPV" {
+		return false, false
+	}
+	if tpv.Mode < 2 {
+		return true, false
+	}
+	alt := tpv.AltMSL
+	if alt == 0 {
+		alt = tpv.Alt
+	}
+	*fix = Fix{
+		Time:      tpv.Time,
+		Latitude:  tpv.Lat,
+		Longitude: tpv.Lon,
+		Altitude:  alt,
+		Speed:     tpv.Speed,
+		Course:    tpv.Track,
+	}
+	return true, true
+}
@@ -0,0 +1,207 @@
+// Package location reads positions from GNSS receivers, through gpsd or
+// directly as NMEA sentences from a serial port, for tagging uploaded samples
+// and classification results with where they happened, e.g. in vehicles or on
+// mobile devices.
+package location
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/sensor/serial"
+)
+
+// Fix is a position reported by a receiver.
+type Fix struct {
+	Time      time.Time `json:"time"`
+	Latitude  float64   `json:"latitude"`           // Degrees, positive is north.
+	Longitude float64   `json:"longitude"`          // Degrees, positive is east.
+	Altitude  float64   `json:"altitude,omitempty"` // Meters above mean sea level, 0 if unknown.
+	Speed     float64   `json:"speed,omitempty"`    // Meters per second over ground.
+	Course    float64   `json:"course,omitempty"`   // Degrees from true north.
+}
+
+// Metadata returns the fix as metadata for uploads, see ingest.UploadOpts.
+func (f Fix) Metadata() map[string]string {
+	m := map[string]string{
+		"latitude":  strconv.FormatFloat(f.Latitude, 'f', 6, 64),
+		"longitude": strconv.FormatFloat(f.Longitude, 'f', 6, 64),
+	}
+	if f.Altitude != 0 {
+		m["altitude"] = strconv.FormatFloat(f.Altitude, 'f', 1, 64)
+	}
+	if !f.Time.IsZero() {
+		m["location_time"] = f.Time.UTC().Format(time.RFC3339)
+	}
+	return m
+}
+
+func (f Fix) String() string {
+	return fmt.Sprintf("%.6f,%.6f", f.Latitude, f.Longitude)
+}
+
+// Provider provides the current position.
+type Provider interface {
+	// Fix returns the latest fix, and false if the receiver has no fix.
+	Fix() (Fix, bool)
+
+	// Close stops reading from the receiver.
+	Close() error
+}
+
+// Current returns the latest fix of p, or nil if p is nil or has no fix, for
+// attaching to results.
+func Current(p Provider) *Fix {
+	if p == nil {
+		return nil
+	}
+	fix, ok := p.Fix()
+	if !ok {
+		return nil
+	}
+	return &fix
+}
+
+// parser turns a line from a receiver into an update of the fix.
+type parser interface {
+	// parse updates the fix from line, returning whether the line was
+	// understood and whether the receiver has a fix.
+	parse(line string, fix *Fix) (ok, valid bool)
+}
+
+// Receiver reads fixes from a gpsd connection or an NMEA stream.
+type Receiver struct {
+	rc   io.ReadCloser
+	done chan struct{}
+
+	mutex sync.Mutex
+	fix   Fix
+	valid bool
+	err   error
+}
+
+// Ensure that Receiver implements the Provider interface.
+var _ Provider = (*Receiver)(nil)
+
+func newReceiver(rc io.ReadCloser, p parser) *Receiver {
+	r := &Receiver{rc: rc, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		scanner := bufio.NewScanner(rc)
+		for scanner.Scan() {
+			r.mutex.Lock()
+			fix := r.fix
+			if ok, valid := p.parse(scanner.Text(), &fix); ok {
+				r.fix = fix
+				r.valid = valid
+			}
+			r.mutex.Unlock()
+		}
+		err := scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		r.mutex.Lock()
+		r.valid = false
+		r.err = err
+		r.mutex.Unlock()
+	}()
+	return r
+}
+
+// Fix returns the latest fix, and false if the receiver reports no fix, or
+// reading from the receiver failed.
+func (r *Receiver) Fix() (Fix, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.fix, r.valid
+}
+
+// Err returns the error that stopped reading, or nil.
+func (r *Receiver) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
+
+// Close stops reading and closes the connection or serial port.
+func (r *Receiver) Close() error {
+	err := r.rc.Close()
+	<-r.done
+	return err
+}
+
+// Open returns a provider for spec, as used in command-line flags:
+//
+//	gpsd                   gpsd on localhost:2947
+//	gpsd:host:port         gpsd at another address
+//	nmea:/dev/ttyUSB0      NMEA sentences from a serial port at 9600 baud
+//	nmea:/dev/ttyUSB0:4800 NMEA sentences at another baud rate
+func Open(ctx context.Context, spec string) (Provider, error) {
+	kind, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		kind, arg = spec[:i], spec[i+1:]
+	}
+	switch kind {
+	case "gpsd":
+		return DialGpsd(ctx, arg)
+	case "nmea":
+		baud := 9600
+		if i := strings.LastIndex(arg, ":"); i >= 0 {
+			b, err := strconv.Atoi(arg[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("parsing baud rate in location %q: %w", spec, err)
+			}
+			arg, baud = arg[:i], b
+		}
+		if arg == "" {
+			return nil, fmt.Errorf("location %q: missing serial device", spec)
+		}
+		return OpenNMEA(ctx, arg, baud)
+	}
+	return nil, fmt.Errorf("unknown location provider %q, must be gpsd or nmea", spec)
+}
+
+// DialGpsd connects to gpsd at addr, by default localhost:2947, and starts
+// watching for fixes.
+func DialGpsd(ctx context.Context, addr string) (*Receiver, error) {
+	if addr == "" {
+		addr = "localhost:2947"
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to gpsd: %w", err)
+	}
+	if _, err := io.WriteString(conn, `?WATCH={"enable":true,"json":true};`+"\n"); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("watching gpsd: %w", err)
+	}
+	return newReceiver(conn, gpsdParser{}), nil
+}
+
+// OpenNMEA configures the baud rate of the serial port of a receiver and
+// starts reading NMEA sentences from it.
+func OpenNMEA(ctx context.Context, device string, baud int) (*Receiver, error) {
+	if err := serial.Configure(ctx, device, baud); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, fmt.Errorf("opening serial port: %w", err)
+	}
+	return NewNMEA(f), nil
+}
+
+// NewNMEA starts reading NMEA sentences from rc, which is closed by Close.
+func NewNMEA(rc io.ReadCloser) *Receiver {
+	return newReceiver(rc, nmeaParser{})
+}
@@ -0,0 +1,70 @@
+package location
+
+import (
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNMEA(t *testing.T) {
+	var p nmeaParser
+	var fix Fix
+
+	ok, valid := p.parse("$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6A", &fix)
+	if !ok || !valid {
+		t.Fatalf("rmc: got ok %v, valid %v", ok, valid)
+	}
+	if math.Abs(fix.Latitude-48.1173) > 1e-6 || math.Abs(fix.Longitude-11.516667) > 1e-6 {
+		t.Fatalf("rmc: got position %v", fix)
+	}
+	if math.Abs(fix.Speed-22.4*knot) > 1e-9 || fix.Course != 84.4 {
+		t.Fatalf("rmc: got speed %v, course %v", fix.Speed, fix.Course)
+	}
+	if want := time.Date(1994, 3, 23, 12, 35, 19, 0, time.UTC); !fix.Time.Equal(want) {
+		t.Fatalf("rmc: got time %v, expected %v", fix.Time, want)
+	}
+
+	ok, valid = p.parse("$GPGGA,123520,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,*4D", &fix)
+	if !ok || !valid || fix.Altitude != 545.4 || fix.Time.Second() != 20 || fix.Time.Day() != 23 {
+		t.Fatalf("gga: got ok %v, valid %v, fix %+v", ok, valid, fix)
+	}
+
+	if ok, valid = p.parse("$GPRMC,123521,V,,,,,,,230394,,*38", &fix); !ok || valid {
+		t.Fatalf("rmc without fix: got ok %v, valid %v", ok, valid)
+	}
+	if ok, valid = p.parse("$GNGGA,123522,4807.038,S,01131.000,W,0,00,,,M,,M,,*4B", &fix); !ok || valid {
+		t.Fatalf("gga without fix: got ok %v, valid %v", ok, valid)
+	}
+	if ok, _ = p.parse("$GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W*6B", &fix); ok {
+		t.Fatalf("bad checksum accepted")
+	}
+	if ok, _ = p.parse("$GPGSV,2,1,08,01,40,083,46*7F", &fix); ok {
+		t.Fatalf("unsupported sentence accepted")
+	}
+
+	if lon, err := nmeaCoord("01131.000", "W"); err != nil || math.Abs(lon+11.516667) > 1e-6 {
+		t.Fatalf("coord: got %v, %v", lon, err)
+	}
+}
+
+func TestReceiver(t *testing.T) {
+	lines := `{"class":"VERSION","release":"3.22"}
+{"class":"TPV","mode":1}
+{"class":"TPV","mode":3,"time":"2026-05-01T10:00:00.000Z","lat":52.37,"lon":4.89,"altMSL":2.5,"speed":1.5,"track":90}
+`
+	r := newReceiver(io.NopCloser(strings.NewReader(lines)), gpsdParser{})
+	<-r.done
+	fix, valid := r.Fix()
+	if valid {
+		t.Fatalf("fix valid after end of stream")
+	}
+	if fix.Latitude != 52.37 || fix.Longitude != 4.89 || fix.Altitude != 2.5 || fix.Speed != 1.5 || fix.Course != 90 {
+		t.Fatalf("got fix %+v", fix)
+	}
+	m := fix.Metadata()
+	if m["latitude"] != "52.370000" || m["longitude"] != "4.890000" || m["location_time"] != "2026-05-01T10:00:00Z" {
+		t.Fatalf("got metadata %v", m)
+	}
+}
@@ -0,0 +1,126 @@
+package location
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// knot is a speed of one nautical mile per hour, in meters per second.
+const knot = 1852.0 / 3600
+
+// nmeaParser parses RMC and GGA sentences from any talker, e.g. $GPRMC for
+// GPS or $GNRMC for combined systems. Other sentences are ignored.
+type nmeaParser struct{}
+
+func (nmeaParser) parse(line string, fix *Fix) (ok, valid bool) {
+	fields, err := nmeaFields(line)
+	if err != nil || len(fields[0]) != 5 {
+		return false, false
+	}
+	switch fields[0][2:] {
+	case "RMC":
+		// time, status, lat, N/S, lon, E/W, speed in knots, course, date, ...
+		if len(fields) < 10 {
+			return false, false
+		}
+		if fields[2] != "A" {
+			return true, false
+		}
+		f := *fix
+		if f.Latitude, err = nmeaCoord(fields[3], fields[4]); err != nil {
+			return false, false
+		}
+		if f.Longitude, err = nmeaCoord(fields[5], fields[6]); err != nil {
+			return false, false
+		}
+		f.Speed, _ = strconv.ParseFloat(fields[7], 64)
+		f.Speed *= knot
+		f.Course, _ = strconv.ParseFloat(fields[8], 64)
+		if t, err := time.Parse("020106 150405.999999999", fields[9]+" "+fields[1]); err == nil {
+			f.Time = t
+		}
+		*fix = f
+		return true, true
+
+	case "GGA":
+		// time, lat, N/S, lon, E/W, quality, satellites, hdop, altitude, ...
+		if len(fields) < 10 {
+			return false, false
+		}
+		if fields[6] == "" || fields[6] == "0" {
+			return true, false
+		}
+		f := *fix
+		if f.Latitude, err = nmeaCoord(fields[2], fields[3]); err != nil {
+			return false, false
+		}
+		if f.Longitude, err = nmeaCoord(fields[4], fields[5]); err != nil {
+			return false, false
+		}
+		f.Altitude, _ = strconv.ParseFloat(fields[9], 64)
+		// GGA has no date, take it from an earlier RMC sentence.
+		if t, err := time.Parse("150405.999999999", fields[1]); err == nil && !f.Time.IsZero() {
+			y, m, d := f.Time.Date()
+			f.Time = time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+		}
+		*fix = f
+		return true, true
+	}
+	return false, false
+}
+
+// nmeaFields verifies the checksum of a sentence and returns its
+// comma-separated fields, starting with the talker and type, e.g. GPRMC.
+func nmeaFields(line string) ([]string, error) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "$") {
+		return nil, fmt.Errorf("not a sentence")
+	}
+	line = line[1:]
+	if i := strings.LastIndex(line, "*"); i >= 0 {
+		want, err := strconv.ParseUint(line[i+1:], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parsing checksum: %w", err)
+		}
+		line = line[:i]
+		var sum byte
+		for j := 0; j < len(line); j++ {
+			sum ^= line[j]
+		}
+		if sum != byte(want) {
+			return nil, fmt.Errorf("checksum mismatch, got %02X, expected %02X", sum, want)
+		}
+	}
+	return strings.Split(line, ","), nil
+}
+
+// nmeaCoord parses a coordinate as degrees and minutes, ddmm.mmmm for
+// latitude and dddmm.mmmm for longitude, with hemisphere N, S, E or W.
+func nmeaCoord(s, hemisphere string) (float64, error) {
+	i := strings.Index(s, ".")
+	if i < 0 {
+		i = len(s)
+	}
+	if i < 3 {
+		return 0, fmt.Errorf("bad coordinate %q", s)
+	}
+	deg, err := strconv.ParseFloat(s[:i-2], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad coordinate %q", s)
+	}
+	min, err := strconv.ParseFloat(s[i-2:], 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad coordinate %q", s)
+	}
+	v := deg + min/60
+	switch hemisphere {
+	case "N", "E":
+	case "S", "W":
+		v = -v
+	default:
+		return 0, fmt.Errorf("bad hemisphere %q", hemisphere)
+	}
+	return v, nil
+}
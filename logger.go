@@ -0,0 +1,74 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"log"
+)
+
+// LogLevel is the severity of a log message.
+type LogLevel int
+
+// Log levels, in increasing severity.
+const (
+	LogDebug LogLevel = iota // Details, e.g. commands started and images dropped.
+	LogInfo                  // Noteworthy events, e.g. trace files written.
+	LogError                 // Errors that are not returned to the caller.
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogError:
+		return "error"
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// Logger receives log messages from the runner, recorders and classifiers.
+// Implement it to integrate SDK messages with the logging of an application,
+// e.g. by forwarding to a slog.Logger.
+type Logger interface {
+	Logf(level LogLevel, format string, args ...interface{})
+}
+
+// LoggerFunc is a function that implements Logger.
+type LoggerFunc func(level LogLevel, format string, args ...interface{})
+
+// Logf calls f.
+func (f LoggerFunc) Logf(level LogLevel, format string, args ...interface{}) {
+	f(level, format, args...)
+}
+
+// NopLogger discards all messages.
+var NopLogger Logger = LoggerFunc(func(LogLevel, string, ...interface{}) {})
+
+// NewStdLogger returns a logger that writes messages with at least level min
+// to l. If l is nil, the standard logger of package log is used.
+func NewStdLogger(l *log.Logger, min LogLevel) Logger {
+	return LoggerFunc(func(level LogLevel, format string, args ...interface{}) {
+		if level < min {
+			return
+		}
+		if l == nil {
+			log.Printf(format, args...)
+		} else {
+			l.Printf(format, args...)
+		}
+	})
+}
+
+// DefaultLogger returns l if it is not nil. Otherwise it returns the logger
+// used when options do not specify a Logger: the standard logger, with debug
+// messages only if verbose is set.
+func DefaultLogger(l Logger, verbose bool) Logger {
+	if l != nil {
+		return l
+	}
+	if verbose {
+		return NewStdLogger(nil, LogDebug)
+	}
+	return NewStdLogger(nil, LogInfo)
+}
@@ -0,0 +1,20 @@
+package edgeimpulse_test
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := edgeimpulse.NewStdLogger(log.New(&buf, "", 0), edgeimpulse.LogInfo)
+	l.Logf(edgeimpulse.LogDebug, "debug %d", 1)
+	l.Logf(edgeimpulse.LogInfo, "info %d", 2)
+	l.Logf(edgeimpulse.LogError, "error %d", 3)
+	if s := buf.String(); s != "info 2\nerror 3\n" {
+		t.Errorf("got %q", s)
+	}
+}
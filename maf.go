@@ -8,11 +8,13 @@ type labelState struct {
 	index  int
 	sum    float64
 	values []float64
+	count  int // Number of values seen, at most len(values).
 }
 
 // MAF is a moving average filter, for smoothing out classification values.
 type MAF struct {
-	state map[string]*labelState
+	state  map[string]*labelState
+	warmup bool
 }
 
 // NewMAF returns a new moving average filter with a history of given size.
@@ -28,7 +30,7 @@ func NewMAF(size int, labels []string) (*MAF, error) {
 		state: map[string]*labelState{},
 	}
 	for _, label := range labels {
-		maf.state[label] = &labelState{0, 0, make([]float64, size)}
+		maf.state[label] = &labelState{values: make([]float64, size)}
 	}
 	return maf, nil
 }
@@ -46,7 +48,6 @@ func (m *MAF) Update(classification map[string]float64) (map[string]float64, err
 	}
 
 	// todo: check that all labels from initialization are present?
-	// todo: for the first "size" updates, only take present values into account?
 
 	r := map[string]float64{}
 	for label, value := range classification {
@@ -57,7 +58,14 @@ func (m *MAF) Update(classification map[string]float64) (map[string]float64, err
 		ls.sum -= ls.values[ls.index]
 		ls.sum += value
 		ls.values[ls.index] = value
-		r[label] = ls.sum / float64(len(ls.values))
+		if ls.count < len(ls.values) {
+			ls.count++
+		}
+		if m.warmup {
+			r[label] = ls.sum / float64(ls.count)
+		} else {
+			r[label] = ls.sum / float64(len(ls.values))
+		}
 		ls.index++
 		if ls.index >= len(ls.values) {
 			ls.index = 0
@@ -65,3 +73,51 @@ func (m *MAF) Update(classification map[string]float64) (map[string]float64, err
 	}
 	return r, nil
 }
+
+// SetWarmup sets whether the filter averages over only the values seen so far,
+// until the history is full. By default, the history is initialized to zeroes,
+// which suppresses high values during the first updates.
+func (m *MAF) SetWarmup(warmup bool) {
+	m.warmup = warmup
+}
+
+// Reset clears the history of the filter, as if it was newly created.
+func (m *MAF) Reset() {
+	for _, ls := range m.state {
+		for i := range ls.values {
+			ls.values[i] = 0
+		}
+		ls.index = 0
+		ls.sum = 0
+		ls.count = 0
+	}
+}
+
+// UpdateResponse adds one classify response to the moving average filter, and
+// returns the smoothed values. For classification models, the classification
+// is used as with Update. For object detection models, the value of a label is
+// the highest value of its bounding boxes, or 0 if the label was not detected.
+// Bounding boxes with unknown labels result in an error.
+func (m *MAF) UpdateResponse(resp RunnerClassifyResponse) (map[string]float64, error) {
+	if resp.Result.Classification != nil {
+		return m.Update(resp.Result.Classification)
+	}
+	if m.state == nil {
+		return nil, fmt.Errorf("invalid MAF, use NewMAF")
+	}
+
+	scores := map[string]float64{}
+	for label := range m.state {
+		scores[label] = 0
+	}
+	for _, b := range resp.Result.BoundingBoxes {
+		v, ok := scores[b.Label]
+		if !ok {
+			return nil, fmt.Errorf("unknown label %q", b.Label)
+		}
+		if b.Value > v {
+			scores[b.Label] = b.Value
+		}
+	}
+	return m.Update(scores)
+}
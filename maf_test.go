@@ -1,9 +1,10 @@
 package edgeimpulse_test
 
 import (
+	"encoding/json"
 	"testing"
 
-	edgeimpulse "github.com/edgeimpulse/linux-sdk-go"
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
 )
 
 func TestMAF(t *testing.T) {
@@ -58,3 +59,73 @@ func TestMAF(t *testing.T) {
 		t.Fatalf("missing error for new MAF without labels")
 	}
 }
+
+func TestMAFWarmup(t *testing.T) {
+	m, err := edgeimpulse.NewMAF(3, []string{"a"})
+	if err != nil {
+		t.Fatalf("making new MAF: %v", err)
+	}
+	m.SetWarmup(true)
+
+	r, _ := m.Update(map[string]float64{"a": 1})
+	if r["a"] != 1 {
+		t.Fatalf("unexpected result after first update with warmup: %v", r)
+	}
+	r, _ = m.Update(map[string]float64{"a": 2})
+	if r["a"] != 3.0/2 {
+		t.Fatalf("unexpected result after second update with warmup: %v", r)
+	}
+	m.Update(map[string]float64{"a": 3})
+	r, _ = m.Update(map[string]float64{"a": 4})
+	if r["a"] != 9.0/3 {
+		t.Fatalf("unexpected result after full history with warmup: %v", r)
+	}
+
+	m.Reset()
+	r, _ = m.Update(map[string]float64{"a": 5})
+	if r["a"] != 5 {
+		t.Fatalf("unexpected result after reset: %v", r)
+	}
+
+	m.SetWarmup(false)
+	m.Reset()
+	r, _ = m.Update(map[string]float64{"a": 3})
+	if r["a"] != 1 {
+		t.Fatalf("unexpected result after reset without warmup: %v", r)
+	}
+}
+
+func TestMAFUpdateResponse(t *testing.T) {
+	m, err := edgeimpulse.NewMAF(2, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("making new MAF: %v", err)
+	}
+
+	update := func(result string) (map[string]float64, error) {
+		t.Helper()
+		var resp edgeimpulse.RunnerClassifyResponse
+		if err := json.Unmarshal([]byte(`{"result": `+result+`}`), &resp); err != nil {
+			t.Fatalf("parsing response: %v", err)
+		}
+		return m.UpdateResponse(resp)
+	}
+
+	r, err := update(`{"bounding_boxes": [{"label": "a", "value": 0.4}, {"label": "a", "value": 0.8}]}`)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if r["a"] != 0.4 || r["b"] != 0 {
+		t.Fatalf("unexpected result after update with boxes: %v", r)
+	}
+	r, _ = update(`{}`)
+	if r["a"] != 0.4 || r["b"] != 0 {
+		t.Fatalf("unexpected result after update without boxes: %v", r)
+	}
+	r, _ = update(`{"classification": {"a": 0.5, "b": 1}}`)
+	if r["a"] != 0.25 || r["b"] != 0.5 {
+		t.Fatalf("unexpected result after update with classification: %v", r)
+	}
+	if _, err := update(`{"bounding_boxes": [{"label": "c", "value": 0.4}]}`); err == nil {
+		t.Fatalf("missing error for unknown label")
+	}
+}
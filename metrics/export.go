@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// WritePrometheus writes all metrics in r in the Prometheus text exposition
+// format.
+func WritePrometheus(w io.Writer, r *Registry) error {
+	for _, m := range r.Snapshot() {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.Name, m.Help, m.Name, m.Type); err != nil {
+			return err
+		}
+		var err error
+		switch m.Type {
+		case TypeCounter:
+			_, err = fmt.Fprintf(w, "%s %d\n", m.Name, m.Value)
+		case TypeHistogram:
+			for _, b := range m.Buckets {
+				le := strconv.FormatFloat(b.UpperBound, 'g', -1, 64)
+				if math.IsInf(b.UpperBound, 1) {
+					le = "+Inf"
+				}
+				if _, err = fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", m.Name, le, b.Count); err != nil {
+					return err
+				}
+			}
+			_, err = fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", m.Name, strconv.FormatFloat(m.Sum, 'g', -1, 64), m.Name, m.Count)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns an HTTP handler serving the metrics in r in Prometheus text
+// format.
+func Handler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WritePrometheus(w, r)
+	})
+}
+
+// PublishExpvar publishes the metrics in r as expvar variable name, as a JSON
+// object keyed by metric name. Like expvar.Publish, it panics if name is
+// already published.
+func PublishExpvar(name string, r *Registry) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		m := map[string]interface{}{}
+		for _, x := range r.Snapshot() {
+			if x.Type == TypeCounter {
+				m[x.Name] = x.Value
+			} else {
+				// JSON cannot represent +Inf, leave out the last bucket.
+				m[x.Name] = map[string]interface{}{"count": x.Count, "sum": x.Sum, "buckets": x.Buckets[:len(x.Buckets)-1]}
+			}
+		}
+		return m
+	}))
+}
@@ -0,0 +1,160 @@
+// Package metrics collects counters and histograms from runners, recorders and
+// classifiers, and exports them in Prometheus text format or through expvar.
+//
+// The SDK updates the metrics in Default. Serve them with:
+//
+//	http.Handle("/metrics", metrics.Handler(metrics.Default))
+//
+// Custom exporters can read all metrics with Registry.Snapshot.
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Registry is a set of named metrics. A Registry is safe for concurrent use.
+type Registry struct {
+	mutex      sync.Mutex
+	counters   map[string]*Counter
+	histograms map[string]*histogram
+}
+
+// NewRegistry returns a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{counters: map[string]*Counter{}, histograms: map[string]*histogram{}}
+}
+
+// Default is the registry updated by the SDK.
+var Default = NewRegistry()
+
+// Metrics maintained by the SDK in Default. Each captured frame is either
+// delivered to its consumer or dropped, so FramesCaptured minus FramesDropped
+// is the number of frames delivered. The runners also register histogram
+// eim_classify_seconds, with the duration of classify requests.
+var (
+	FramesCaptured  = Default.Counter("eim_frames_captured_total", "Images, audio windows or sensor samples captured by recorders, delivered or dropped.")
+	FramesDropped   = Default.Counter("eim_frames_dropped_total", "Images, audio windows or sensor samples dropped because the consumer was busy.")
+	Classifications = Default.Counter("eim_classifications_total", "Successful classifications by runners.")
+	ClassifyErrors  = Default.Counter("eim_classify_errors_total", "Failed classifications by runners.")
+	RunnerStarts    = Default.Counter("eim_runner_starts_total", "Model processes started, including restarts.")
+)
+
+// Counter is a monotonically increasing count.
+type Counter struct {
+	name, help string
+	value      int64
+}
+
+// Inc adds 1 to the counter.
+func (c *Counter) Inc() {
+	atomic.AddInt64(&c.value, 1)
+}
+
+// Add adds n to the counter.
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+// Value returns the current count.
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// Histogram is a histogram of durations, such as edgeimpulse.Histogram,
+// exported in seconds.
+type Histogram interface {
+	// CumulativeBuckets returns upper bounds of buckets with the number of
+	// durations up to each bound, and the number and sum of all durations.
+	CumulativeBuckets() (bounds []time.Duration, counts []int64, count int64, sum time.Duration)
+}
+
+type histogram struct {
+	name, help string
+	h          Histogram
+}
+
+// Counter returns the counter with name, registering it if needed.
+func (r *Registry) Counter(name, help string) *Counter {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	if _, ok := r.histograms[name]; ok {
+		panic(fmt.Sprintf("metric %q already registered as histogram", name))
+	}
+	c := &Counter{name: name, help: help}
+	r.counters[name] = c
+	return c
+}
+
+// RegisterHistogram registers histogram h with name. It panics if name is
+// already registered.
+func (r *Registry) RegisterHistogram(name, help string, h Histogram) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.counters[name]; ok {
+		panic(fmt.Sprintf("metric %q already registered as counter", name))
+	}
+	if _, ok := r.histograms[name]; ok {
+		panic(fmt.Sprintf("metric %q already registered", name))
+	}
+	r.histograms[name] = &histogram{name, help, h}
+}
+
+// Type is the kind of metric.
+type Type string
+
+// Types of metrics.
+const (
+	TypeCounter   Type = "counter"
+	TypeHistogram Type = "histogram"
+)
+
+// Bucket is a cumulative histogram bucket.
+type Bucket struct {
+	UpperBound float64 `json:"le"` // +Inf for the last bucket.
+	Count      int64   `json:"count"`
+}
+
+// Metric is the state of a single metric at a point in time.
+type Metric struct {
+	Name string `json:"name"`
+	Help string `json:"help"`
+	Type Type   `json:"type"`
+
+	Value int64 `json:"value,omitempty"` // For counters.
+
+	// For histograms.
+	Count   int64    `json:"count,omitempty"`
+	Sum     float64  `json:"sum,omitempty"`
+	Buckets []Bucket `json:"buckets,omitempty"`
+}
+
+// Snapshot returns all metrics, ordered by name.
+func (r *Registry) Snapshot() []Metric {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var l []Metric
+	for _, c := range r.counters {
+		l = append(l, Metric{Name: c.name, Help: c.help, Type: TypeCounter, Value: c.Value()})
+	}
+	for _, h := range r.histograms {
+		bounds, counts, count, sum := h.h.CumulativeBuckets()
+		m := Metric{Name: h.name, Help: h.help, Type: TypeHistogram, Count: count, Sum: sum.Seconds()}
+		for i, b := range bounds {
+			m.Buckets = append(m.Buckets, Bucket{b.Seconds(), counts[i]})
+		}
+		m.Buckets = append(m.Buckets, Bucket{math.Inf(1), count})
+		l = append(l, m)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+	return l
+}
@@ -0,0 +1,45 @@
+package metrics_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.Counter("frames_total", "Frames.")
+	c.Inc()
+	c.Add(2)
+	if r.Counter("frames_total", "") != c {
+		t.Errorf("registering counter again returned new counter")
+	}
+	var h edgeimpulse.Histogram
+	r.RegisterHistogram("latency_seconds", "Latency.", &h)
+	h.Record(50 * time.Millisecond)
+	h.Record(500 * time.Millisecond)
+	h.Record(5 * time.Second)
+
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	for _, exp := range []string{
+		"# HELP frames_total Frames.\n# TYPE frames_total counter\nframes_total 3\n",
+		"# HELP latency_seconds Latency.\n# TYPE latency_seconds histogram\n",
+		"latency_seconds_bucket{le=\"1e-06\"} 0\n",
+		"latency_seconds_bucket{le=\"0.065536\"} 1\n",
+		"latency_seconds_bucket{le=\"0.524288\"} 2\n",
+		"latency_seconds_bucket{le=\"8.388608\"} 3\n",
+		"latency_seconds_bucket{le=\"+Inf\"} 3\nlatency_seconds_sum 5.55\nlatency_seconds_count 3\n",
+	} {
+		if !strings.Contains(s, exp) {
+			t.Errorf("missing %q in:\n%s", exp, s)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package edgeimpulse
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ModelInfo holds the parameters a model reports about itself.
+type ModelInfo struct {
+	ModelParameters ModelParameters `json:"model_parameters"`
+	Project         Project         `json:"project"`
+
+	// Protocol features of the model process. Zero if not known, e.g. for
+	// model info cached by older versions.
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+// ModelInfoOpts contains options for ReadModelInfo.
+type ModelInfoOpts struct {
+	// Directory for cached model info. If empty, directory edge-impulse-linux
+	// in the user cache directory is used, see os.UserCacheDir.
+	CacheDir string
+
+	// If set, cached model info is ignored, and the cache is refreshed by
+	// starting the model.
+	Refresh bool
+
+	// Options for starting the model process when the model info is not
+	// cached.
+	RunnerOpts RunnerOpts
+}
+
+// ReadModelInfo returns the model parameters and project of the model file at
+// modelPath. The model info is cached, keyed by the path, size and
+// modification time of the model file, so the model process is only started
+// for new or changed models. Failing to use the cache is not an error; the
+// model is started instead.
+func ReadModelInfo(modelPath string, opts *ModelInfoOpts) (ModelInfo, error) {
+	var xopts ModelInfoOpts
+	if opts != nil {
+		xopts = *opts
+	}
+	logger := DefaultLogger(xopts.RunnerOpts.Logger, false)
+
+	cachePath, err := modelInfoCachePath(modelPath, xopts.CacheDir)
+	if err != nil {
+		logger.Logf(LogDebug, "model info cache: %v", err)
+	} else if !xopts.Refresh {
+		var info ModelInfo
+		if buf, err := os.ReadFile(cachePath); err == nil && json.Unmarshal(buf, &info) == nil {
+			return info, nil
+		}
+	}
+
+	runner, err := NewRunnerProcess(modelPath, &xopts.RunnerOpts)
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	info := ModelInfo{runner.ModelParameters(), runner.Project(), runner.Capabilities()}
+	runner.Close()
+
+	if cachePath != "" {
+		if err := writeModelInfoCache(cachePath, info); err != nil {
+			logger.Logf(LogInfo, "writing model info cache: %v", err)
+		}
+	}
+	return info, nil
+}
+
+// ReadModelInfoFile reads model info from a JSON file, in the format printed
+// by "eimclassify -info", e.g. for running a model exported as TensorFlow Lite
+// or ONNX in-process. The model type and sensor type are set like for a model
+// process.
+func ReadModelInfoFile(path string) (ModelInfo, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return ModelInfo{}, err
+	}
+	info, err := ParseModelInfo(buf)
+	if err != nil {
+		return ModelInfo{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return info, nil
+}
+
+// ParseModelInfo parses model info in JSON, like ReadModelInfoFile.
+func ParseModelInfo(buf []byte) (ModelInfo, error) {
+	var info ModelInfo
+	if err := json.Unmarshal(buf, &info); err != nil {
+		return ModelInfo{}, fmt.Errorf("parsing model info: %w", err)
+	}
+	info.ModelParameters.setDefaults()
+	return info, nil
+}
+
+// modelInfoCachePath returns the path of the cache file for the model.
+func modelInfoCachePath(modelPath, cacheDir string) (string, error) {
+	if cacheDir == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		cacheDir = filepath.Join(dir, "edge-impulse-linux")
+	}
+	absPath, err := filepath.Abs(modelPath)
+	if err != nil {
+		return "", err
+	}
+	fi, err := os.Stat(absPath)
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d", absPath, fi.Size(), fi.ModTime().UnixNano())))
+	return filepath.Join(cacheDir, fmt.Sprintf("modelinfo-%x.json", key[:16])), nil
+}
+
+// writeModelInfoCache atomically writes info to path.
+func writeModelInfoCache(path string, info ModelInfo) error {
+	buf, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "modelinfo-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
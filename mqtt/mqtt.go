@@ -0,0 +1,415 @@
+// Package mqtt implements a minimal MQTT 3.1.1 client, for publishing results
+// to a broker and subscribing to sensor data without external dependencies.
+// Only QoS 0 is supported.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Packet types.
+const (
+	typeConnect    = 1
+	typeConnack    = 2
+	typePublish    = 3
+	typeSubscribe  = 8
+	typeSuback     = 9
+	typePingreq    = 12
+	typePingresp   = 13
+	typeDisconnect = 14
+)
+
+// ErrClosed is returned when using a closed client.
+var ErrClosed = errors.New("mqtt: client closed")
+
+// Opts are options for connecting to a broker.
+type Opts struct {
+	ClientID  string        // If empty, the broker assigns an ID.
+	Username  string        // Optional.
+	Password  string        // Optional, only used with Username.
+	KeepAlive time.Duration // If zero, 60 seconds is used.
+}
+
+// Client is a connection to an MQTT broker.
+type Client struct {
+	conn net.Conn
+
+	mutex    sync.Mutex // Serializes writes.
+	closed   bool
+	err      error // Set when reading fails.
+	done     chan struct{}
+	packetID uint16
+	subs     []*subscription
+	subacks  map[uint16]chan byte // Pending subscribes, receiving the return code.
+}
+
+// Handler is called for messages received on a subscribed topic.
+type Handler func(topic string, payload []byte)
+
+type subscription struct {
+	filter  string
+	handler Handler
+}
+
+// Dial connects to the broker at addr (host:port), and sends a connect packet.
+//
+// Callers must call Close to disconnect.
+func Dial(addr string, opts *Opts) (*Client, error) {
+	return DialContext(context.Background(), addr, opts)
+}
+
+// DialContext is like Dial, but gives up connecting when ctx is done.
+func DialContext(ctx context.Context, addr string, opts *Opts) (*Client, error) {
+	var xopts Opts
+	if opts != nil {
+		xopts = *opts
+	}
+	if xopts.KeepAlive == 0 {
+		xopts.KeepAlive = 60 * time.Second
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dialing mqtt broker: %w", err)
+	}
+
+	// Variable header: protocol name, level, flags, keep alive. Then payload.
+	var flags byte = 0x02 // Clean session.
+	var p []byte
+	p = appendString(p, "MQTT")
+	p = append(p, 4, 0)
+	p = appendUint16(p, uint16(xopts.KeepAlive/time.Second))
+	p = appendString(p, xopts.ClientID)
+	if xopts.Username != "" {
+		flags |= 0x80
+		p = appendString(p, xopts.Username)
+		if xopts.Password != "" {
+			flags |= 0x40
+			p = appendString(p, xopts.Password)
+		}
+	}
+	p[7] = flags
+
+	conn.SetDeadline(deadline)
+	if err := writePacket(conn, typeConnect<<4, p); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("writing connect: %w", err)
+	}
+	r := bufio.NewReader(conn)
+	header, body, err := readPacket(r)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("reading connack: %w", err)
+	}
+	if header>>4 != typeConnack || len(body) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected packet type %d, expected connack", header>>4)
+	}
+	if body[1] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("connection refused by broker, code %d", body[1])
+	}
+	conn.SetDeadline(time.Time{})
+
+	c := &Client{conn: conn, done: make(chan struct{}), subacks: map[uint16]chan byte{}}
+	go c.read(r)
+	go c.ping(xopts.KeepAlive / 2)
+	return c, nil
+}
+
+// read reads packets from the broker until the connection fails.
+func (c *Client) read(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		header, body, err := readPacket(r)
+		if err != nil {
+			c.mutex.Lock()
+			c.err = err
+			c.mutex.Unlock()
+			return
+		}
+		switch header >> 4 {
+		case typePingresp:
+			// Nothing to do.
+		case typeSuback:
+			if len(body) < 3 {
+				continue
+			}
+			id := uint16(body[0])<<8 | uint16(body[1])
+			c.mutex.Lock()
+			if ch, ok := c.subacks[id]; ok {
+				ch <- body[2]
+				delete(c.subacks, id)
+			}
+			c.mutex.Unlock()
+		case typePublish:
+			c.dispatch(header, body)
+		default:
+			c.mutex.Lock()
+			c.err = fmt.Errorf("unexpected packet type %d from broker", header>>4)
+			c.mutex.Unlock()
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+// ping sends pings to keep the connection alive.
+func (c *Client) ping(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+			c.mutex.Lock()
+			if !c.closed {
+				writePacket(c.conn, typePingreq<<4, nil)
+			}
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// Publish sends payload to topic with QoS 0. If retain is set, the broker
+// keeps the message for new subscribers.
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	if c.err != nil {
+		return fmt.Errorf("mqtt connection: %w", c.err)
+	}
+	var header byte = typePublish << 4
+	if retain {
+		header |= 1
+	}
+	p := appendString(nil, topic)
+	p = append(p, payload...)
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := writePacket(c.conn, header, p); err != nil {
+		c.err = err
+		return fmt.Errorf("writing publish: %w", err)
+	}
+	return nil
+}
+
+// Subscribe subscribes to topic filter, which can contain the wildcards "+"
+// and "#", with QoS 0, and waits for the broker to acknowledge. An error is
+// returned if the broker rejects the subscription. Handler is called for each
+// message on a matching topic, from the goroutine reading from the broker: it
+// must return quickly, and must not call Subscribe or Close.
+func (c *Client) Subscribe(filter string, handler Handler) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return ErrClosed
+	}
+	if c.err != nil {
+		err := c.err
+		c.mutex.Unlock()
+		return fmt.Errorf("mqtt connection: %w", err)
+	}
+	sub := &subscription{filter, handler}
+	c.subs = append(c.subs, sub)
+	c.packetID++
+	if c.packetID == 0 {
+		c.packetID = 1
+	}
+	id := c.packetID
+	suback := make(chan byte, 1)
+	c.subacks[id] = suback
+	p := appendUint16(nil, id)
+	p = appendString(p, filter)
+	p = append(p, 0) // Requested QoS.
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if err := writePacket(c.conn, typeSubscribe<<4|0x02, p); err != nil {
+		c.err = err
+		c.mutex.Unlock()
+		c.unsubscribe(id, sub)
+		return fmt.Errorf("writing subscribe: %w", err)
+	}
+	c.mutex.Unlock()
+
+	t := time.NewTimer(10 * time.Second)
+	defer t.Stop()
+	select {
+	case code := <-suback:
+		if code&0x80 != 0 {
+			c.unsubscribe(id, sub)
+			return fmt.Errorf("subscription to %q rejected by broker", filter)
+		}
+		return nil
+	case <-c.done:
+		c.unsubscribe(id, sub)
+		if err := c.Err(); err != nil {
+			return fmt.Errorf("mqtt connection: %w", err)
+		}
+		return ErrClosed
+	case <-t.C:
+		c.unsubscribe(id, sub)
+		return fmt.Errorf("timeout waiting for suback")
+	}
+}
+
+// unsubscribe forgets a subscription that failed, without telling the broker.
+func (c *Client) unsubscribe(id uint16, sub *subscription) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.subacks, id)
+	subs := make([]*subscription, 0, len(c.subs))
+	for _, s := range c.subs {
+		if s != sub {
+			subs = append(subs, s)
+		}
+	}
+	c.subs = subs
+}
+
+// dispatch calls the handlers of subscriptions matching a publish packet.
+func (c *Client) dispatch(header byte, body []byte) {
+	if len(body) < 2 {
+		return
+	}
+	n := int(body[0])<<8 | int(body[1])
+	if len(body) < 2+n {
+		return
+	}
+	topic := string(body[2 : 2+n])
+	payload := body[2+n:]
+	if (header>>1)&0x03 > 0 {
+		// Packet identifier for QoS 1 and 2, though we only subscribe with
+		// QoS 0.
+		if len(payload) < 2 {
+			return
+		}
+		payload = payload[2:]
+	}
+
+	c.mutex.Lock()
+	subs := c.subs
+	c.mutex.Unlock()
+	for _, s := range subs {
+		if matchTopic(s.filter, topic) {
+			s.handler(topic, payload)
+		}
+	}
+}
+
+// matchTopic returns whether topic matches filter, with wildcards "+" for a
+// single level and "#" for remaining levels.
+func matchTopic(filter, topic string) bool {
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// Done returns a channel that is closed when the connection is broken, or after
+// Close. Err returns the cause of a broken connection.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error that broke the connection, if any.
+func (c *Client) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	writePacket(c.conn, typeDisconnect<<4, nil)
+	c.mutex.Unlock()
+
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func writePacket(w io.Writer, header byte, body []byte) error {
+	buf := []byte{header}
+	// Remaining length, variable length encoded.
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			break
+		}
+	}
+	buf = append(buf, body...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var n, shift int
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, fmt.Errorf("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
@@ -0,0 +1,155 @@
+package mqtt
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	published := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		header, body, err := readPacket(r)
+		if err != nil || header>>4 != typeConnect {
+			t.Errorf("reading connect: %x %v", header, err)
+			return
+		}
+		if string(body[2:6]) != "MQTT" || body[7] != 0xc2 {
+			t.Errorf("unexpected connect %q", body)
+		}
+		writePacket(conn, typeConnack<<4, []byte{0, 0})
+		header, body, err = readPacket(r)
+		if err != nil || header>>4 != typePublish {
+			t.Errorf("reading publish: %x %v", header, err)
+			return
+		}
+		published <- string(body)
+	}()
+
+	c, err := Dial(l.Addr().String(), &Opts{ClientID: "test", Username: "user", Password: "pass"})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if err := c.Publish("a/b", []byte("hello"), false); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if s := <-published; s != "\x00\x03a/bhello" {
+		t.Fatalf("got publish body %q", s)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if _, _, err := readPacket(r); err != nil {
+			return
+		}
+		writePacket(conn, typeConnack<<4, []byte{0, 0})
+		header, body, err := readPacket(r)
+		if err != nil || header != typeSubscribe<<4|0x02 {
+			t.Errorf("reading subscribe: %x %v", header, err)
+			return
+		}
+		if string(body[2:]) != "\x00\x09sensors/+\x00" {
+			t.Errorf("unexpected subscribe %q", body)
+		}
+		writePacket(conn, typeSuback<<4, []byte{body[0], body[1], 0})
+		writePacket(conn, typePublish<<4, appendString(nil, "other/a")) // Not subscribed.
+		writePacket(conn, typePublish<<4, append(appendString(nil, "sensors/a"), "1,2,3"...))
+		readPacket(r) // Until disconnect.
+	}()
+
+	c, err := Dial(l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	received := make(chan string, 2)
+	if err := c.Subscribe("sensors/+", func(topic string, payload []byte) {
+		received <- topic + " " + string(payload)
+	}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if s := <-received; s != "sensors/a 1,2,3" {
+		t.Fatalf("got message %q", s)
+	}
+
+	for _, tc := range []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a/b/c", true},
+		{"#", "a", true},
+		{"a/b", "a", false},
+	} {
+		if m := matchTopic(tc.filter, tc.topic); m != tc.match {
+			t.Errorf("matchTopic(%q, %q) = %v, expected %v", tc.filter, tc.topic, m, tc.match)
+		}
+	}
+}
+
+func TestSubscribeRejected(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		r := bufio.NewReader(conn)
+		if _, _, err := readPacket(r); err != nil {
+			return
+		}
+		writePacket(conn, typeConnack<<4, []byte{0, 0})
+		_, body, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		writePacket(conn, typeSuback<<4, []byte{body[0], body[1], 0x80})
+		conn.Close() // Breaks the connection.
+	}()
+
+	c, err := Dial(l.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if err := c.Subscribe("secret/#", func(topic string, payload []byte) {}); err == nil {
+		t.Fatalf("subscribe succeeded, expected rejection")
+	}
+	<-c.Done()
+	if c.Err() == nil {
+		t.Fatalf("missing error for broken connection")
+	}
+}
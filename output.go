@@ -0,0 +1,59 @@
+package edgeimpulse
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Streams of model process output, passed to RunnerOpts.Output.
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+)
+
+// maxOutputLine is the length after which a line of model process output is
+// passed on without waiting for its newline.
+const maxOutputLine = 16 * 1024
+
+// lineWriter passes each line written to it to fn, without the newline.
+type lineWriter struct {
+	stream string
+	fn     func(stream, line string)
+
+	mutex sync.Mutex
+	buf   []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			if len(w.buf) >= maxOutputLine {
+				w.emit(len(w.buf))
+			}
+			break
+		}
+		w.emit(i)
+		w.buf = w.buf[1:]
+	}
+	return len(p), nil
+}
+
+// emit passes the first n bytes of the buffer to fn, and removes them. Must be
+// called with lock held.
+func (w *lineWriter) emit(n int) {
+	w.fn(w.stream, string(bytes.TrimSuffix(w.buf[:n], []byte("\r"))))
+	w.buf = w.buf[n:]
+}
+
+// Flush passes a final line without newline to fn.
+func (w *lineWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if len(w.buf) > 0 {
+		w.emit(len(w.buf))
+	}
+}
@@ -0,0 +1,57 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ping sends a hello request to the model process, and returns the time until
+// its response. It checks that the process is alive and responsive, e.g. for a
+// health endpoint that should detect a hung model before classifications
+// fail. The request is sent alongside classifications in progress, without
+// waiting for them, and the response is awaited for at most
+// RunnerOpts.ClassifyTimeout.
+func (r *RunnerProcess) Ping() (time.Duration, error) {
+	req := runnerHelloRequest{ID: r.nextID(), Hello: ProtocolVersion}
+	var resp runnerHelloResponse
+	t0 := time.Now()
+	if err := r.transact(req.ID, req, &resp, r.opts.ClassifyTimeout); err != nil {
+		return 0, fmt.Errorf("ping model: %w", err)
+	}
+	return time.Since(t0), nil
+}
+
+// Ping pings all model processes, and returns the longest time until a
+// response, see RunnerProcess.Ping.
+func (p *RunnerPool) Ping() (time.Duration, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.closed {
+		return 0, errRunnerClosed
+	}
+	var max time.Duration
+	for i, r := range p.runners {
+		d, err := r.Ping()
+		if err != nil {
+			return 0, fmt.Errorf("model process %d: %w", i, err)
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return max, nil
+}
+
+// Ping pings the current runner if it supports it, like RunnerProcess, and
+// returns 0 otherwise.
+func (r *RestartRunner) Ping() (time.Duration, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.closed {
+		return 0, errRunnerClosed
+	}
+	if pr, ok := r.runner.(interface{ Ping() (time.Duration, error) }); ok {
+		return pr.Ping()
+	}
+	return 0, nil
+}
@@ -0,0 +1,185 @@
+package edgeimpulse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// PipelineResult is a classification, or an error, produced by the source of
+// a Pipeline.
+type PipelineResult struct {
+	// If not nil, an error occurred and other fields are not meaningful.
+	Err error
+
+	// The classification response from the model.
+	RunnerClassifyResponse
+
+	// When the result was produced.
+	Time time.Time
+
+	// The input that was classified, e.g. an image.Image for images,
+	// or []float64 with the samples for audio and time series.
+	Input interface{}
+}
+
+// PipelineSource produces classifications for a Pipeline, typically an image,
+// audio or time series classifier of a recorder, see the NewSource functions
+// of packages image, audio and timeseries.
+type PipelineSource interface {
+	// Start starts classifying input with runner. Results are sent on the
+	// returned channel until the input ends, ctx is canceled, or the
+	// returned closer is called, after which the channel is closed. A
+	// source that is not read from drops input.
+	Start(ctx context.Context, runner Runner) (<-chan PipelineResult, io.Closer, error)
+}
+
+// PipelineFilter post-processes responses, e.g. a filter from package
+// pipeline.
+type PipelineFilter interface {
+	Apply(resp RunnerClassifyResponse) (RunnerClassifyResponse, error)
+}
+
+// PipelineSink receives the results of a Pipeline, e.g. a sink from package
+// sink, see sink.ForPipeline.
+type PipelineSink interface {
+	// Send delivers a result. Send may block until the result is delivered
+	// or ctx is canceled, delaying the next results.
+	Send(ctx context.Context, r PipelineResult) error
+
+	// Close releases resources, such as files and connections.
+	Close() error
+}
+
+// Pipeline composes a source, a model, post-processing filters and sinks, and
+// runs them until the input ends or the context is canceled:
+//
+//	err := edgeimpulse.NewPipeline().
+//		Source(image.NewSource(recorder)).
+//		Model("model.eim").
+//		Filter(pipeline.NewThreshold(0.6)).
+//		Sink(sink.ForPipeline(sink.NewJSON(os.Stdout), "camera")).
+//		Run(ctx)
+//
+// Results are post-processed and sent to the sinks one at a time. While a
+// result is being processed, the source drops new input, so slow sinks reduce
+// the rate of classifications instead of queueing results.
+//
+// Errors in the configuration are returned by Run.
+type Pipeline struct {
+	source     PipelineSource
+	modelPath  string
+	runnerOpts []RunnerOption
+	runner     Runner
+	filters    []PipelineFilter
+	sinks      []PipelineSink
+	logger     Logger
+}
+
+// NewPipeline returns an empty pipeline, to be configured with its methods.
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// Source sets the source of the pipeline. The pipeline closes the source.
+func (p *Pipeline) Source(s PipelineSource) *Pipeline {
+	p.source = s
+	return p
+}
+
+// Model sets the path of the model file to start a RunnerProcess for, with
+// opts. The pipeline closes the runner.
+func (p *Pipeline) Model(path string, opts ...RunnerOption) *Pipeline {
+	p.modelPath = path
+	p.runnerOpts = opts
+	return p
+}
+
+// Runner sets the runner to classify with, instead of starting a model with
+// Model. The pipeline does not close runner.
+func (p *Pipeline) Runner(r Runner) *Pipeline {
+	p.runner = r
+	return p
+}
+
+// Filter adds a filter, applied in the order added.
+func (p *Pipeline) Filter(f PipelineFilter) *Pipeline {
+	p.filters = append(p.filters, f)
+	return p
+}
+
+// Sink adds a sink that receives all results. The pipeline closes the sink.
+func (p *Pipeline) Sink(s PipelineSink) *Pipeline {
+	p.sinks = append(p.sinks, s)
+	return p
+}
+
+// Logger sets the logger for errors. If not set, the standard logger is used.
+func (p *Pipeline) Logger(l Logger) *Pipeline {
+	p.logger = l
+	return p
+}
+
+// Run starts the model and the source, and processes results until the input
+// ends or ctx is canceled. All components are then shut down in order, see
+// Group, and sinks are closed, also when starting failed. Errors from the
+// source, filters and sinks are logged. Run returns an error if starting
+// failed, or the first error from closing the components.
+func (p *Pipeline) Run(ctx context.Context) (rerr error) {
+	group := NewGroup(ctx)
+	defer func() {
+		if err := group.Close(); err != nil && rerr == nil {
+			rerr = err
+		}
+	}()
+	ctx = group.Context()
+	for _, s := range p.sinks {
+		group.Add(StageOutput, s)
+	}
+
+	if p.source == nil {
+		return errors.New("pipeline without source")
+	}
+	logger := DefaultLogger(p.logger, false)
+
+	runner := p.runner
+	if runner == nil {
+		if p.modelPath == "" {
+			return errors.New("pipeline without model")
+		}
+		opts := append([]RunnerOption{WithLogger(p.logger)}, p.runnerOpts...)
+		r, err := NewRunnerProcess(p.modelPath, opts...)
+		if err != nil {
+			return fmt.Errorf("starting model: %w", err)
+		}
+		group.Add(StageModel, r)
+		runner = r
+	}
+
+	results, closer, err := p.source.Start(ctx, runner)
+	if err != nil {
+		return fmt.Errorf("starting source: %w", err)
+	}
+	group.Add(StageClassify, closer)
+
+	for r := range results {
+		if r.Err != nil {
+			logger.Logf(LogError, "pipeline source: %v", r.Err)
+			continue
+		}
+		for _, f := range p.filters {
+			r.RunnerClassifyResponse, err = f.Apply(r.RunnerClassifyResponse)
+			if err != nil {
+				logger.Logf(LogError, "applying filter: %v", err)
+			}
+		}
+		for _, s := range p.sinks {
+			if err := s.Send(ctx, r); err != nil && ctx.Err() == nil {
+				logger.Logf(LogError, "sending result: %v", err)
+			}
+		}
+	}
+	return nil
+}
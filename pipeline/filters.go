@@ -0,0 +1,373 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// copyClassification returns a copy of resp with its own classification map.
+func copyClassification(resp edgeimpulse.RunnerClassifyResponse) edgeimpulse.RunnerClassifyResponse {
+	if resp.Result.Classification == nil {
+		return resp
+	}
+	m := make(map[string]float64, len(resp.Result.Classification))
+	for k, v := range resp.Result.Classification {
+		m[k] = v
+	}
+	resp.Result.Classification = m
+	return resp
+}
+
+// MAF is a filter smoothing classifications with a moving average filter.
+// Bounding boxes are passed through unchanged.
+type MAF struct {
+	maf *edgeimpulse.MAF
+}
+
+// NewMAF returns a moving average filter of size for labels. The filter averages
+// over the values seen until size values have been seen.
+func NewMAF(size int, labels []string) (*MAF, error) {
+	maf, err := edgeimpulse.NewMAF(size, labels)
+	if err != nil {
+		return nil, err
+	}
+	maf.SetWarmup(true)
+	return &MAF{maf}, nil
+}
+
+// Apply replaces the scores in classifications with their moving averages.
+func (f *MAF) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	if resp.Result.Classification == nil {
+		return resp, nil
+	}
+	r, err := f.maf.Update(resp.Result.Classification)
+	if err != nil {
+		return resp, fmt.Errorf("moving average filter: %w", err)
+	}
+	resp.Result.Classification = r
+	return resp, nil
+}
+
+// Reset clears the moving averages.
+func (f *MAF) Reset() {
+	f.maf.Reset()
+}
+
+// EMA is a filter smoothing classifications with an exponential moving
+// average. Bounding boxes are passed through unchanged.
+type EMA struct {
+	alpha float64
+	state map[string]float64
+}
+
+// NewEMA returns an exponential moving average filter. Alpha is the weight of
+// a new value, between 0 and 1. Lower is smoother.
+func NewEMA(alpha float64) (*EMA, error) {
+	if alpha <= 0 || alpha > 1 {
+		return nil, fmt.Errorf("alpha must be > 0 and <= 1")
+	}
+	return &EMA{alpha, map[string]float64{}}, nil
+}
+
+// Apply replaces the scores in classifications with their exponential moving
+// averages. Labels seen for the first time start at their score.
+func (f *EMA) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	resp = copyClassification(resp)
+	for label, v := range resp.Result.Classification {
+		if prev, ok := f.state[label]; ok {
+			v = f.alpha*v + (1-f.alpha)*prev
+		}
+		f.state[label] = v
+		resp.Result.Classification[label] = v
+	}
+	return resp, nil
+}
+
+// Reset clears the averages.
+func (f *EMA) Reset() {
+	f.state = map[string]float64{}
+}
+
+// Threshold is a filter removing labels from classifications, and bounding
+// boxes, with a score below a minimum.
+type Threshold struct {
+	min float64
+}
+
+// NewThreshold returns a threshold filter.
+func NewThreshold(min float64) *Threshold {
+	return &Threshold{min}
+}
+
+// Apply removes labels and bounding boxes scoring below the minimum.
+func (f *Threshold) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	resp = copyClassification(resp)
+	for label, v := range resp.Result.Classification {
+		if v < f.min {
+			delete(resp.Result.Classification, label)
+		}
+	}
+	if resp.Result.BoundingBoxes != nil {
+		boxes := resp.Result.BoundingBoxes[:0:0]
+		for _, b := range resp.Result.BoundingBoxes {
+			if b.Value >= f.min {
+				boxes = append(boxes, b)
+			}
+		}
+		resp.Result.BoundingBoxes = boxes
+	}
+	return resp, nil
+}
+
+// Reset does nothing, a threshold filter has no state.
+func (f *Threshold) Reset() {
+}
+
+// NMS is a filter applying non-maximum suppression to bounding boxes: of boxes
+// with the same label that overlap, only the box with the highest score is
+// kept.
+type NMS struct {
+	maxIoU float64
+}
+
+// NewNMS returns a non-maximum suppression filter. Boxes with an intersection
+// over union above maxIoU with a higher scoring box are removed.
+func NewNMS(maxIoU float64) *NMS {
+	return &NMS{maxIoU}
+}
+
+// Apply removes overlapping bounding boxes of the same label, keeping the box
+// with the highest score.
+func (f *NMS) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	if resp.Result.BoundingBoxes == nil {
+		return resp, nil
+	}
+	boxes := append(resp.Result.BoundingBoxes[:0:0], resp.Result.BoundingBoxes...)
+	sort.SliceStable(boxes, func(i, j int) bool {
+		return boxes[i].Value > boxes[j].Value
+	})
+	kept := boxes[:0:0]
+	for _, b := range boxes {
+		keep := true
+		for _, k := range kept {
+			if k.Label == b.Label && iou(k.X, k.Y, k.Width, k.Height, b.X, b.Y, b.Width, b.Height) > f.maxIoU {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			kept = append(kept, b)
+		}
+	}
+	resp.Result.BoundingBoxes = kept
+	return resp, nil
+}
+
+// Reset does nothing, a non-maximum suppression filter has no state.
+func (f *NMS) Reset() {
+}
+
+// iou returns the intersection over union of two boxes.
+func iou(ax, ay, aw, ah, bx, by, bw, bh int) float64 {
+	x0, y0 := max(ax, bx), max(ay, by)
+	x1, y1 := min(ax+aw, bx+bw), min(ay+ah, by+bh)
+	if x1 <= x0 || y1 <= y0 {
+		return 0
+	}
+	inter := float64((x1 - x0) * (y1 - y0))
+	return inter / (float64(aw*ah+bw*bh) - inter)
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Tracker is a filter replacing bounding boxes with boxes smoothed across
+// frames by an edgeimpulse.BoxSmoother.
+type Tracker struct {
+	smoother *edgeimpulse.BoxSmoother
+}
+
+// NewTracker returns a tracker filter.
+func NewTracker(opts edgeimpulse.BoxSmootherOpts) (*Tracker, error) {
+	s, err := edgeimpulse.NewBoxSmoother(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tracker{s}, nil
+}
+
+// Apply replaces the bounding boxes with the smoothed boxes of the tracks. No
+// bounding boxes, including nil, counts as no detections, so tracks decay.
+func (f *Tracker) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	boxes := resp.Result.BoundingBoxes[:0:0]
+	for _, b := range f.smoother.Update(resp) {
+		boxes = append(boxes, edgeimpulse.BoundingBox{Label: b.Label, Value: b.Value, X: b.X, Y: b.Y, Width: b.Width, Height: b.Height})
+	}
+	resp.Result.BoundingBoxes = boxes
+	return resp, nil
+}
+
+// Reset drops all tracks.
+func (f *Tracker) Reset() {
+	f.smoother.Reset()
+}
+
+// Debounce is a filter that only passes labels that are active according to an
+// edgeimpulse.Detector. Scores of inactive labels in classifications are set
+// to 0, and bounding boxes of inactive labels are removed. For bounding boxes,
+// the score of a label is the highest score of its boxes.
+type Debounce struct {
+	detector *edgeimpulse.Detector
+}
+
+// NewDebounce returns a debounce filter.
+func NewDebounce(opts edgeimpulse.DetectorOpts) (*Debounce, error) {
+	d, err := edgeimpulse.NewDetector(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Debounce{d}, nil
+}
+
+// Apply updates the detector with the scores of the labels, and zeroes or
+// removes the labels that are not active.
+func (f *Debounce) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	if resp.Result.Classification != nil {
+		resp = copyClassification(resp)
+		f.detector.Update(resp.Result.Classification)
+		for label := range resp.Result.Classification {
+			if !f.detector.Active(label) {
+				resp.Result.Classification[label] = 0
+			}
+		}
+		return resp, nil
+	}
+
+	scores := map[string]float64{}
+	for _, b := range resp.Result.BoundingBoxes {
+		if b.Value > scores[b.Label] {
+			scores[b.Label] = b.Value
+		}
+	}
+	f.detector.Update(scores)
+	if resp.Result.BoundingBoxes != nil {
+		boxes := resp.Result.BoundingBoxes[:0:0]
+		for _, b := range resp.Result.BoundingBoxes {
+			if f.detector.Active(b.Label) {
+				boxes = append(boxes, b)
+			}
+		}
+		resp.Result.BoundingBoxes = boxes
+	}
+	return resp, nil
+}
+
+// Reset makes all labels inactive.
+func (f *Debounce) Reset() {
+	f.detector.Reset()
+}
+
+// Cooldown is a filter that passes a label with a score at or above a
+// threshold only once per cooldown period, see edgeimpulse.Cooldown. During the
+// period, the score of the label in classifications is set to 0, and its
+// bounding boxes are removed.
+type Cooldown struct {
+	threshold float64
+	cooldown  *edgeimpulse.Cooldown
+	now       func() time.Time
+}
+
+// NewCooldown returns a cooldown filter.
+func NewCooldown(period time.Duration, threshold float64) *Cooldown {
+	return &Cooldown{threshold, edgeimpulse.NewCooldown(period), time.Now}
+}
+
+// Apply zeroes the scores in classifications, and removes the bounding boxes,
+// of labels that reach the threshold during their cooldown period.
+func (f *Cooldown) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	t := f.now()
+	if resp.Result.Classification != nil {
+		resp = copyClassification(resp)
+		for label, v := range resp.Result.Classification {
+			if v >= f.threshold && !f.cooldown.Allow(label, t) {
+				resp.Result.Classification[label] = 0
+			}
+		}
+	}
+	if resp.Result.BoundingBoxes != nil {
+		// All boxes of a label in this response belong to the same event.
+		allowed := map[string]bool{}
+		boxes := resp.Result.BoundingBoxes[:0:0]
+		for _, b := range resp.Result.BoundingBoxes {
+			if b.Value >= f.threshold {
+				ok, seen := allowed[b.Label]
+				if !seen {
+					ok = f.cooldown.Allow(b.Label, t)
+					allowed[b.Label] = ok
+				}
+				if !ok {
+					continue
+				}
+			}
+			boxes = append(boxes, b)
+		}
+		resp.Result.BoundingBoxes = boxes
+	}
+	return resp, nil
+}
+
+// Reset ends all cooldown periods.
+func (f *Cooldown) Reset() {
+	f.cooldown.Reset()
+}
+
+// Vote is a filter replacing the scores in classifications with the fraction of
+// votes for each label in a majority vote over the last classifications, see
+// edgeimpulse.MajorityVote. Bounding boxes are passed through unchanged.
+type Vote struct {
+	vote *edgeimpulse.MajorityVote
+}
+
+// NewVote returns a majority vote filter over size classifications.
+func NewVote(size int) (*Vote, error) {
+	m, err := edgeimpulse.NewMajorityVote(size)
+	if err != nil {
+		return nil, err
+	}
+	return &Vote{m}, nil
+}
+
+// Apply adds the top label of the classification as vote, and replaces the
+// scores with the fraction of votes in the window for each label.
+func (f *Vote) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	if resp.Result.Classification == nil {
+		return resp, nil
+	}
+	v := f.vote.Update(resp.Result.Classification)
+	counts := f.vote.Counts()
+	c := map[string]float64{}
+	for label := range resp.Result.Classification {
+		c[label] = float64(counts[label]) / float64(v.Total)
+	}
+	resp.Result.Classification = c
+	return resp, nil
+}
+
+// Reset clears the votes.
+func (f *Vote) Reset() {
+	f.vote.Reset()
+}
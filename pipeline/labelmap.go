@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Ways to aggregate the scores of labels in a group.
+const (
+	AggregateMax = "max"
+	AggregateSum = "sum"
+)
+
+// LabelMapConfig configures a LabelMap. It can be read from a JSON file, e.g.:
+//
+//	{
+//		"rename": {"jan": "person"},
+//		"groups": {"vehicle": ["car", "truck", "bus"]},
+//		"aggregate": "max"
+//	}
+type LabelMapConfig struct {
+	// Labels to rename, from model label to new label.
+	Rename map[string]string `json:"rename,omitempty"`
+
+	// Groups of model labels that are replaced by a single label. Groups are
+	// applied to the model labels, before renaming.
+	Groups map[string][]string `json:"groups,omitempty"`
+
+	// How scores of a group are combined in a classification: AggregateMax
+	// or AggregateSum. If empty, AggregateMax is used. Scores of bounding
+	// boxes are never combined, only their labels are changed.
+	Aggregate string `json:"aggregate,omitempty"`
+}
+
+// LabelMap is a filter that renames and groups labels in classifications and
+// bounding boxes. Labels not renamed or grouped are kept as is.
+type LabelMap struct {
+	labels map[string]string // Model label to new label.
+	sum    bool
+}
+
+// NewLabelMap returns a new label map filter.
+func NewLabelMap(config LabelMapConfig) (*LabelMap, error) {
+	m := &LabelMap{labels: map[string]string{}}
+	switch config.Aggregate {
+	case "", AggregateMax:
+	case AggregateSum:
+		m.sum = true
+	default:
+		return nil, fmt.Errorf("unknown aggregate %q, must be max or sum", config.Aggregate)
+	}
+	for from, to := range config.Rename {
+		m.labels[from] = to
+	}
+	for group, labels := range config.Groups {
+		for _, label := range labels {
+			if prev, ok := m.labels[label]; ok && prev != group {
+				return nil, fmt.Errorf("label %q mapped to both %q and %q", label, prev, group)
+			}
+			m.labels[label] = group
+		}
+	}
+	return m, nil
+}
+
+// LoadLabelMap reads a JSON LabelMapConfig from path and returns a label map
+// filter.
+func LoadLabelMap(path string) (*LabelMap, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading label map: %w", err)
+	}
+	var config LabelMapConfig
+	if err := json.Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("parsing label map %s: %w", path, err)
+	}
+	return NewLabelMap(config)
+}
+
+// Label returns the new label for a model label.
+func (m *LabelMap) Label(label string) string {
+	if to, ok := m.labels[label]; ok {
+		return to
+	}
+	return label
+}
+
+// Apply renames the labels of classifications and bounding boxes, combining
+// the scores of labels mapped to the same label.
+func (m *LabelMap) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	if resp.Result.Classification != nil {
+		c := map[string]float64{}
+		for label, v := range resp.Result.Classification {
+			label = m.Label(label)
+			if m.sum {
+				c[label] += v
+			} else if prev, ok := c[label]; !ok || v > prev {
+				c[label] = v
+			}
+		}
+		resp.Result.Classification = c
+	}
+	if resp.Result.BoundingBoxes != nil {
+		boxes := append(resp.Result.BoundingBoxes[:0:0], resp.Result.BoundingBoxes...)
+		for i := range boxes {
+			boxes[i].Label = m.Label(boxes[i].Label)
+		}
+		resp.Result.BoundingBoxes = boxes
+	}
+	return resp, nil
+}
+
+// Reset does nothing, a label map has no state.
+func (m *LabelMap) Reset() {
+}
@@ -0,0 +1,140 @@
+// Package pipeline chains post-processing filters, such as smoothing,
+// thresholding and non-maximum suppression, on classify responses.
+//
+// Both image and audio ClassifyEvents embed a RunnerClassifyResponse, so a
+// pipeline is applied to either the same way:
+//
+//	p, err := pipeline.Parse("ema:0.5,threshold:0.6", runner.ModelParameters().Labels)
+//	...
+//	ev.RunnerClassifyResponse, err = p.Apply(ev.RunnerClassifyResponse)
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Filter transforms classify responses. Filters may keep state across calls,
+// e.g. for smoothing. Filters must not modify the response passed in, but
+// return a modified copy.
+type Filter interface {
+	Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error)
+
+	// Reset clears state kept across calls.
+	Reset()
+}
+
+// Pipeline is a Filter that applies filters in order.
+type Pipeline struct {
+	filters []Filter
+}
+
+// New returns a pipeline applying filters in order.
+func New(filters ...Filter) *Pipeline {
+	return &Pipeline{filters}
+}
+
+// Apply applies all filters in order, stopping at the first error.
+func (p *Pipeline) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	for _, f := range p.filters {
+		var err error
+		resp, err = f.Apply(resp)
+		if err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// Reset resets all filters.
+func (p *Pipeline) Reset() {
+	for _, f := range p.filters {
+		f.Reset()
+	}
+}
+
+// Parse returns a pipeline for spec, a comma-separated list of filters with
+// optional parameters after a colon. Labels are the labels of the model, used
+// by the moving average filter. Filters:
+//
+//	maf:size              moving average over size responses
+//	ema:alpha             exponential moving average, new value weighs alpha
+//	threshold:min         remove scores and boxes below min
+//	nms:iou               non-maximum suppression of boxes overlapping by iou
+//	tracker:alpha         smooth boxes across frames, see edgeimpulse.BoxSmoother
+//	debounce:on:off:n:m   hold labels active, see edgeimpulse.Detector
+//	vote:size             fraction of top label votes over size responses
+//	cooldown:secs:min     pass a label scoring at least min once per period
+//	labels:path           rename and group labels, see LabelMapConfig
+//
+// An empty spec results in an empty pipeline.
+func Parse(spec string, labels []string) (*Pipeline, error) {
+	p := &Pipeline{}
+	if spec == "" {
+		return p, nil
+	}
+	for _, s := range strings.Split(spec, ",") {
+		if strings.HasPrefix(s, "labels:") {
+			m, err := LoadLabelMap(strings.TrimPrefix(s, "labels:"))
+			if err != nil {
+				return nil, err
+			}
+			p.filters = append(p.filters, m)
+			continue
+		}
+
+		t := strings.Split(s, ":")
+		name := t[0]
+		var args []float64
+		for _, a := range t[1:] {
+			v, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing parameter for filter %q: %w", name, err)
+			}
+			args = append(args, v)
+		}
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+
+		var f Filter
+		var err error
+		switch name {
+		case "maf":
+			f, err = NewMAF(int(arg(0, 4)), labels)
+		case "ema":
+			f, err = NewEMA(arg(0, 0.5))
+		case "threshold":
+			f = NewThreshold(arg(0, 0.5))
+		case "nms":
+			f = NewNMS(arg(0, 0.5))
+		case "tracker":
+			f, err = NewTracker(edgeimpulse.BoxSmootherOpts{Alpha: arg(0, 0)})
+		case "debounce":
+			f, err = NewDebounce(edgeimpulse.DetectorOpts{
+				Threshold:        arg(0, 0.5),
+				ReleaseThreshold: arg(1, 0),
+				Activate:         int(arg(2, 0)),
+				Deactivate:       int(arg(3, 0)),
+			})
+		case "vote":
+			f, err = NewVote(int(arg(0, 5)))
+		case "cooldown":
+			f = NewCooldown(time.Duration(arg(0, 10)*float64(time.Second)), arg(1, 0.5))
+		default:
+			return nil, fmt.Errorf("unknown filter %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", name, err)
+		}
+		p.filters = append(p.filters, f)
+	}
+	return p, nil
+}
@@ -0,0 +1,112 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func response(t *testing.T, s string) edgeimpulse.RunnerClassifyResponse {
+	t.Helper()
+	var resp edgeimpulse.RunnerClassifyResponse
+	if err := json.Unmarshal([]byte(s), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestPipeline(t *testing.T) {
+	p, err := Parse("ema:0.5,threshold:0.5", []string{"yes", "no"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := response(t, `{"result": {"classification": {"yes": 1, "no": 0}}}`)
+	resp, err := p.Apply(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Result.Classification) != 1 || resp.Result.Classification["yes"] != 1 {
+		t.Errorf("got %v, expected yes=1", resp.Result.Classification)
+	}
+	if in.Result.Classification["no"] != 0 || len(in.Result.Classification) != 2 {
+		t.Errorf("input classification was modified")
+	}
+
+	resp, err = p.Apply(response(t, `{"result": {"classification": {"yes": 0, "no": 1}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Result.Classification) != 2 || resp.Result.Classification["yes"] != 0.5 || resp.Result.Classification["no"] != 0.5 {
+		t.Errorf("got %v, expected yes=0.5 no=0.5", resp.Result.Classification)
+	}
+
+	if _, err := Parse("bogus", nil); err == nil {
+		t.Errorf("parsing unknown filter succeeded, expected error")
+	}
+}
+
+func TestNMS(t *testing.T) {
+	resp, err := NewNMS(0.5).Apply(response(t, `{"result": {"bounding_boxes": [
+		{"label": "car", "value": 0.6, "x": 0, "y": 0, "width": 10, "height": 10},
+		{"label": "car", "value": 0.9, "x": 1, "y": 1, "width": 10, "height": 10},
+		{"label": "bus", "value": 0.7, "x": 0, "y": 0, "width": 10, "height": 10},
+		{"label": "car", "value": 0.5, "x": 50, "y": 50, "width": 10, "height": 10}
+	]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes := resp.Result.BoundingBoxes
+	if len(boxes) != 3 || boxes[0].Value != 0.9 || boxes[1].Label != "bus" || boxes[2].X != 50 {
+		t.Errorf("got %+v", boxes)
+	}
+}
+
+func TestTrackerNil(t *testing.T) {
+	f, err := NewTracker(edgeimpulse.BoxSmootherOpts{MaxMissed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := f.Apply(response(t, `{"result": {"bounding_boxes": [{"label": "car", "value": 0.8, "x": 0, "y": 0, "width": 10, "height": 10}]}}`))
+	if err != nil || len(resp.Result.BoundingBoxes) != 1 {
+		t.Fatalf("got %+v, %v, expected one box", resp.Result.BoundingBoxes, err)
+	}
+	// Responses without bounding boxes are frames without detections, so
+	// the track decays and is dropped.
+	resp, err = f.Apply(response(t, `{"result": {}}`))
+	if err != nil || len(resp.Result.BoundingBoxes) != 1 || resp.Result.BoundingBoxes[0].Value >= 0.8 {
+		t.Fatalf("got %+v, %v, expected one decayed box", resp.Result.BoundingBoxes, err)
+	}
+	resp, err = f.Apply(response(t, `{"result": {}}`))
+	if err != nil || len(resp.Result.BoundingBoxes) != 0 {
+		t.Fatalf("got %+v, %v, expected no boxes", resp.Result.BoundingBoxes, err)
+	}
+}
+
+func TestLabelMap(t *testing.T) {
+	m, err := NewLabelMap(LabelMapConfig{
+		Rename:    map[string]string{"jan": "person"},
+		Groups:    map[string][]string{"vehicle": {"car", "truck"}},
+		Aggregate: AggregateSum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := m.Apply(response(t, `{"result": {"classification": {"jan": 0.2, "car": 0.3, "truck": 0.4, "other": 0.1}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := resp.Result.Classification
+	if len(c) != 3 || c["person"] != 0.2 || c["vehicle"] != 0.7 || c["other"] != 0.1 {
+		t.Errorf("got %v", c)
+	}
+
+	resp, err = m.Apply(response(t, `{"result": {"bounding_boxes": [{"label": "truck", "value": 0.9}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.BoundingBoxes[0].Label != "vehicle" {
+		t.Errorf("got label %q, expected vehicle", resp.Result.BoundingBoxes[0].Label)
+	}
+}
@@ -0,0 +1,81 @@
+package edgeimpulse_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+type fakeSource struct {
+	n      int
+	closed bool
+}
+
+func (s *fakeSource) Start(ctx context.Context, runner edgeimpulse.Runner) (<-chan edgeimpulse.PipelineResult, io.Closer, error) {
+	c := make(chan edgeimpulse.PipelineResult)
+	go func() {
+		defer close(c)
+		for i := 0; i < s.n; i++ {
+			resp, err := runner.Classify([]float64{float64(i)})
+			c <- edgeimpulse.PipelineResult{Err: err, RunnerClassifyResponse: resp, Input: i}
+		}
+		c <- edgeimpulse.PipelineResult{Err: errors.New("logged")}
+	}()
+	return c, s, nil
+}
+
+func (s *fakeSource) Close() error {
+	s.closed = true
+	return nil
+}
+
+type labelFilter struct{}
+
+func (labelFilter) Apply(resp edgeimpulse.RunnerClassifyResponse) (edgeimpulse.RunnerClassifyResponse, error) {
+	resp.Result.Classification = map[string]float64{"yes": 1}
+	return resp, nil
+}
+
+type recordingSink struct {
+	results []edgeimpulse.PipelineResult
+	closed  bool
+}
+
+func (s *recordingSink) Send(ctx context.Context, r edgeimpulse.PipelineResult) error {
+	s.results = append(s.results, r)
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestPipeline(t *testing.T) {
+	src := &fakeSource{n: 3}
+	runner := &countingRunner{}
+	sink := &recordingSink{}
+	logger := edgeimpulse.LoggerFunc(func(level edgeimpulse.LogLevel, format string, args ...interface{}) {})
+	err := edgeimpulse.NewPipeline().Source(src).Runner(runner).Filter(labelFilter{}).Sink(sink).Logger(logger).Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if runner.classified != 3 || len(sink.results) != 3 {
+		t.Fatalf("got %d classifications and %d results, expected 3", runner.classified, len(sink.results))
+	}
+	for i, r := range sink.results {
+		if r.Input != i || r.Result.Classification["yes"] != 1 {
+			t.Errorf("result %d: got input %v, classification %v", i, r.Input, r.Result.Classification)
+		}
+	}
+	if !src.closed || !sink.closed || runner.closed {
+		t.Errorf("closed source %v, sink %v, runner %v, expected source and sink", src.closed, sink.closed, runner.closed)
+	}
+
+	if err := edgeimpulse.NewPipeline().Runner(runner).Run(context.Background()); err == nil {
+		t.Errorf("pipeline without source: got nil error")
+	}
+}
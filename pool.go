@@ -0,0 +1,158 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// RunnerPool is a runner that distributes classifications over multiple model
+// processes of the same model, e.g. one per core, for higher throughput of
+// classifications from multiple goroutines. A classification waits until a
+// process is idle.
+type RunnerPool struct {
+	modelParams ModelParameters
+	project     Project
+	runners     []*RunnerProcess
+	idle        chan *RunnerProcess
+	stats       *Stats // Shared by all runners.
+
+	mutex  sync.RWMutex // Held for reading while classifying.
+	closed bool
+}
+
+// Ensure that RunnerPool implements interface Runner.
+var _ Runner = (*RunnerPool)(nil)
+
+// NewRunnerPool starts n model processes for the model file at modelPath, or
+// a process per core if n <= 0. Opts are applied to each process, typically
+// with WithThreads(1) so processes don't compete for cores. If
+// RunnerOpts.WorkDir is set, each process uses a subdirectory named after its
+// index, starting at 0.
+func NewRunnerPool(modelPath string, n int, opts ...RunnerOption) (*RunnerPool, error) {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	var xopts RunnerOpts
+	for _, o := range opts {
+		if o != nil {
+			o.applyRunner(&xopts)
+		}
+	}
+
+	p := &RunnerPool{
+		runners: make([]*RunnerProcess, n),
+		idle:    make(chan *RunnerProcess, n),
+		stats:   &Stats{},
+	}
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range p.runners {
+		ropts := xopts
+		if ropts.WorkDir != "" {
+			ropts.WorkDir = filepath.Join(ropts.WorkDir, strconv.Itoa(i))
+			if err := os.MkdirAll(ropts.WorkDir, 0755); err != nil {
+				errs[i] = err
+				continue
+			}
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p.runners[i], errs[i] = NewRunnerProcess(modelPath, &ropts)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("starting model process %d: %w", i, err)
+		}
+	}
+
+	p.modelParams = p.runners[0].ModelParameters()
+	p.project = p.runners[0].Project()
+	for _, r := range p.runners {
+		r.stats = p.stats
+		p.idle <- r
+	}
+	return p, nil
+}
+
+// ModelParameters returns the parameters of the model.
+func (p *RunnerPool) ModelParameters() ModelParameters {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.modelParams
+}
+
+// Project returns the project of the model.
+func (p *RunnerPool) Project() Project {
+	return p.project
+}
+
+// Size returns the number of model processes.
+func (p *RunnerPool) Size() int {
+	return len(p.runners)
+}
+
+// Classify classifies data with an idle model process, waiting for one if all
+// are busy.
+func (p *RunnerPool) Classify(data []float64) (RunnerClassifyResponse, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.closed {
+		return RunnerClassifyResponse{}, errRunnerClosed
+	}
+	r := <-p.idle
+	defer func() {
+		p.idle <- r
+	}()
+	return r.Classify(data)
+}
+
+// Stats returns the latencies and errors of classifications of all model
+// processes, see RunnerProcess.Stats.
+func (p *RunnerPool) Stats() *Stats {
+	return p.stats
+}
+
+// SetThreshold changes the threshold of block blockID in all model processes,
+// see RunnerProcess.SetThreshold. It waits for classifications in progress.
+func (p *RunnerPool) SetThreshold(blockID int, params map[string]float64) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return errRunnerClosed
+	}
+	for _, r := range p.runners {
+		if err := r.SetThreshold(blockID, params); err != nil {
+			return err
+		}
+	}
+	p.modelParams = p.runners[0].ModelParameters()
+	return nil
+}
+
+// Close waits for classifications in progress, and stops all model processes.
+func (p *RunnerPool) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	var err error
+	for _, r := range p.runners {
+		if r == nil {
+			continue
+		}
+		if xerr := r.Close(); xerr != nil && err == nil {
+			err = xerr
+		}
+	}
+	return err
+}
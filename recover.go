@@ -0,0 +1,19 @@
+package edgeimpulse
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrPanic is returned, wrapped, when a goroutine of a recorder or classifier
+// recovered from a panic, e.g. while handling a malformed frame. The component
+// stops after a panic.
+var ErrPanic = errors.New("panic")
+
+// PanicError returns an error wrapping ErrPanic for v, a value returned by
+// recover, including the stack trace. Call PanicError from the deferred
+// function that recovers.
+func PanicError(v interface{}) error {
+	return fmt.Errorf("%w: %v\n%s", ErrPanic, v, debug.Stack())
+}
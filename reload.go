@@ -0,0 +1,154 @@
+package edgeimpulse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// ErrInputChanged is returned, possibly wrapped, by Reload if the new model
+// takes different input than the current model, e.g. another image size or
+// sample rate, which classifiers created for the runner cannot provide.
+var ErrInputChanged = errors.New("model input changed")
+
+// Reload replaces the model with the model file at modelPath, e.g. after an
+// over-the-air update. The new model process is started and has said hello
+// before classifications are switched to it, so classifications continue
+// during the reload. The old process is stopped when its classifications in
+// progress are done, or after RunnerOpts.ClassifyTimeout. On error, the old
+// model keeps running.
+//
+// The new model must take the same input as the current model, see
+// SameInput, or an error wrapping ErrInputChanged is returned. Classifiers
+// keep working with the new model, its labels and thresholds may differ.
+// ModelParameters, Project and Capabilities reflect the new model from the
+// moment classifications are switched. Thresholds set with SetThreshold are
+// not applied to the new model, RunnerOpts.MinScore is.
+//
+// Reload is not supported for runners connected with NewRunnerTCP or
+// NewRunnerSocket.
+func (r *RunnerProcess) Reload(modelPath string) error {
+	if r.addr != "" {
+		return errors.New("cannot reload a model of a remote runner")
+	}
+	modelPath, err := filepath.Abs(modelPath)
+	if err != nil {
+		return fmt.Errorf("absolute path for modelPath %q: %w", modelPath, err)
+	}
+	if !r.opts.SkipArchCheck {
+		if err := CheckModelArch(modelPath); err != nil {
+			return err
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	select {
+	case <-r.stop:
+		return errRunnerClosed
+	default:
+	}
+
+	np, helloResp, err := r.start(context.Background(), modelPath)
+	if err != nil {
+		np.stop()
+		return fmt.Errorf("starting new model: %w", err)
+	}
+	m := r.newModel(helloResp)
+	if err := SameInput(r.state().params, m.params); err != nil {
+		np.stop()
+		return err
+	}
+
+	// Set the minimum score before switching, so no classification uses
+	// the default of the new model.
+	thresholds := map[int]runnerSetThresholdRequest{}
+	if r.opts.MinScore > 0 && m.params.ModelType.ObjectDetection() && m.caps.Thresholds {
+		for i, t := range m.params.Thresholds {
+			if t.Type != ThresholdObjectDetection {
+				continue
+			}
+			params := map[string]float64{"min_score": r.opts.MinScore}
+			req := runnerSetThresholdRequest{ID: r.nextID(), SetThreshold: map[string]float64{"id": float64(t.ID), "min_score": r.opts.MinScore}}
+			var resp RunnerResponse
+			if _, err := r.transactWith(np, req.ID, req, &resp, r.opts.ClassifyTimeout); err != nil {
+				np.stop()
+				return fmt.Errorf("setting minimum score of new model: %w", err)
+			}
+			m = m.withThreshold(i, params)
+			thresholds[t.ID] = req
+			break
+		}
+	}
+
+	// Switch classifications and model state at once.
+	r.procMutex.Lock()
+	old := r.proc
+	if old == nil {
+		// Requests are waiting for a respawn.
+		close(r.procUp)
+	}
+	r.proc = np
+	r.model = m
+	r.modelPath = modelPath
+	r.thresholds = thresholds
+	r.gen++
+	gen := r.gen
+	r.procMutex.Unlock()
+	if r.opts.Respawn {
+		go r.respawn(np, gen)
+	}
+	r.logger.Logf(LogInfo, "model reloaded from %s", modelPath)
+
+	if old != nil {
+		old.drain(r.opts.ClassifyTimeout)
+		old.stop()
+	}
+	return nil
+}
+
+// SameInput returns an error wrapping ErrInputChanged if models with
+// parameters a and b take different input: another sensor, number of
+// features, sample rate, number of axes, continuous slice size, image size,
+// number of image channels or image feature scaling.
+func SameInput(a, b ModelParameters) error {
+	type input struct {
+		Sensor              int64
+		InputFeaturesCount  int
+		Frequency           float64
+		AxisCount           int
+		SliceSize           int
+		UseContinuousMode   bool
+		ImageInputWidth     int
+		ImageInputHeight    int
+		ImageChannelCount   int
+		ImageFeatureScaling string
+	}
+	in := func(mp ModelParameters) input {
+		return input{mp.Sensor, mp.InputFeaturesCount, mp.Frequency, mp.AxisCount, mp.SliceSize, mp.UseContinuousMode, mp.ImageInputWidth, mp.ImageInputHeight, mp.ImageChannelCount, mp.ImageFeatureScaling}
+	}
+	if ia, ib := in(a), in(b); ia != ib {
+		return fmt.Errorf("%w: from %+v to %+v", ErrInputChanged, ia, ib)
+	}
+	return nil
+}
+
+// drain waits until no requests are in flight, for at most timeout.
+func (p *modelProcess) drain(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		p.pendingMutex.Lock()
+		n := len(p.pending)
+		p.pendingMutex.Unlock()
+		if n == 0 {
+			return
+		}
+		select {
+		case <-p.readDone:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
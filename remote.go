@@ -0,0 +1,70 @@
+package edgeimpulse
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// NewRunnerTCP connects to a model runner at addr, e.g. "192.168.1.10:7000",
+// that speaks the runner protocol over TCP, instead of starting a local model
+// process. This lets a small device classify with a model running on a faster
+// machine on the network. The remote side can be a model process behind a
+// forwarder, e.g.:
+//
+//	modelfile.eim /tmp/runner.sock &
+//	socat TCP-LISTEN:7000,reuseaddr UNIX-CONNECT:/tmp/runner.sock
+//
+// Options for the local process, WorkDir, Threads and Env, are ignored.
+// HelloTimeout also limits connecting. With Respawn, a lost connection is
+// reestablished, like a crashed process is restarted.
+func NewRunnerTCP(addr string, opts ...RunnerOption) (*RunnerProcess, error) {
+	return connectRunner("tcp", addr, opts)
+}
+
+// NewRunnerSocket connects to a model process listening on the unix domain
+// socket at path, e.g. started by systemd with the socket path as argument,
+// instead of starting one. Close closes the connection, but leaves the process
+// running. Options are as for NewRunnerTCP.
+func NewRunnerSocket(path string, opts ...RunnerOption) (*RunnerProcess, error) {
+	return connectRunner("unix", path, opts)
+}
+
+func connectRunner(network, addr string, opts []RunnerOption) (runner *RunnerProcess, rerr error) {
+	r := newRunner(opts)
+	r.network = network
+	r.addr = addr
+	defer func() {
+		if rerr != nil {
+			r.Close()
+		}
+	}()
+	if err := r.init(context.Background()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// dial connects to the runner at r.addr. The returned process has exited when
+// its connection is closed.
+func (r *RunnerProcess) dial(ctx context.Context) (*modelProcess, error) {
+	p := &modelProcess{
+		cancel:   func() {},
+		exited:   make(chan struct{}),
+		pending:  map[int64]chan []byte{},
+		readDone: make(chan struct{}),
+	}
+	d := net.Dialer{Timeout: r.opts.HelloTimeout}
+	conn, err := d.DialContext(ctx, r.network, r.addr)
+	if err != nil {
+		close(p.exited)
+		return p, fmt.Errorf("connecting to runner: %w", err)
+	}
+	p.conn = conn
+	go func() {
+		<-p.readDone
+		p.waitErr = p.readErr
+		close(p.exited)
+	}()
+	return p, nil
+}
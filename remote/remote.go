@@ -0,0 +1,436 @@
+// Package remote connects a device to Edge Impulse Studio through the remote
+// management service, like the data forwarder does. The device shows up in the
+// Devices list of the project, and samples can be recorded from its sensors in
+// the data acquisition view, labeled on the fly, and are uploaded directly
+// into the project.
+//
+// Any source of samples can be used, through a timeseries.Recorder, e.g. from
+// package sensor/iio, sensor/serial or frame.
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/ingest"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/websocket"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// DefaultURL is the remote management service of Edge Impulse.
+var DefaultURL = "wss://remote-mgmt.edgeimpulse.com"
+
+// ErrRejected is returned by Run when the service rejects the device, e.g.
+// for an invalid API key.
+var ErrRejected = errors.New("remote management rejected device")
+
+// Sensor is a sensor that Studio can record samples from.
+type Sensor struct {
+	// Name shown in Studio, e.g. "Accelerometer".
+	Name string
+
+	// Longest sample Studio may request. If 0, 5 minutes.
+	MaxSampleLength time.Duration
+
+	// Frequencies in Hz that can be selected in Studio.
+	Frequencies []float64
+
+	// Open starts recording at frequency, one of Frequencies. The recorder
+	// is closed after the sample has been recorded.
+	Open func(ctx context.Context, frequency float64) (timeseries.Recorder, error)
+}
+
+// ClientOpts are options for a Client.
+type ClientOpts struct {
+	// URL of the remote management service. If empty, DefaultURL is used.
+	URL string
+
+	// API key of the project. Required.
+	APIKey string
+
+	// Globally unique ID of the device, e.g. its MAC address. Required.
+	DeviceID string
+
+	// Type of the device, shown in Studio. Defaults to "LINUX_GO".
+	DeviceType string
+
+	// Sensors of the device, at least one is required.
+	Sensors []Sensor
+
+	// Base URL for uploading samples. If empty, the default of
+	// ingest.NewCollector is used.
+	IngestionURL string
+
+	// If set, called for each recorded sample to get metadata stored with
+	// it, e.g. the location from package location.
+	Metadata func() map[string]string
+
+	// Delay before reconnecting after the connection breaks. If 0, 5
+	// seconds.
+	ReconnectDelay time.Duration
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+}
+
+// Option configures a client created with NewClient. A *ClientOpts is also an
+// Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *ClientOpts)
+}
+
+type optionFunc func(o *ClientOpts)
+
+func (fn optionFunc) apply(o *ClientOpts) {
+	fn(o)
+}
+
+func (opts *ClientOpts) apply(o *ClientOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithURL sets ClientOpts.URL.
+func WithURL(url string) Option {
+	return optionFunc(func(o *ClientOpts) { o.URL = url })
+}
+
+// WithAPIKey sets ClientOpts.APIKey.
+func WithAPIKey(key string) Option {
+	return optionFunc(func(o *ClientOpts) { o.APIKey = key })
+}
+
+// WithDevice sets ClientOpts.DeviceID and ClientOpts.DeviceType.
+func WithDevice(id, deviceType string) Option {
+	return optionFunc(func(o *ClientOpts) {
+		o.DeviceID = id
+		o.DeviceType = deviceType
+	})
+}
+
+// WithSensors sets ClientOpts.Sensors.
+func WithSensors(sensors ...Sensor) Option {
+	return optionFunc(func(o *ClientOpts) { o.Sensors = sensors })
+}
+
+// WithIngestionURL sets ClientOpts.IngestionURL.
+func WithIngestionURL(url string) Option {
+	return optionFunc(func(o *ClientOpts) { o.IngestionURL = url })
+}
+
+// WithMetadata sets ClientOpts.Metadata.
+func WithMetadata(fn func() map[string]string) Option {
+	return optionFunc(func(o *ClientOpts) { o.Metadata = fn })
+}
+
+// WithVerbose sets ClientOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *ClientOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets ClientOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *ClientOpts) { o.Logger = logger })
+}
+
+// Messages of the protocol. Each message is a JSON object with a single field.
+type (
+	helloSensor struct {
+		Name             string    `json:"name"`
+		MaxSampleLengthS int       `json:"maxSampleLengthS"`
+		Frequencies      []float64 `json:"frequencies"`
+	}
+
+	hello struct {
+		Version                   int           `json:"version"`
+		APIKey                    string        `json:"apiKey"`
+		DeviceID                  string        `json:"deviceId"`
+		DeviceType                string        `json:"deviceType"`
+		Connection                string        `json:"connection"`
+		Sensors                   []helloSensor `json:"sensors"`
+		SupportsSnapshotStreaming bool          `json:"supportsSnapshotStreaming"`
+	}
+
+	helloResponse struct {
+		Valid bool   `json:"valid"`
+		Err   string `json:"err"`
+	}
+
+	sampleRequest struct {
+		Label    string  `json:"label"`
+		Length   int64   `json:"length"`   // In milliseconds.
+		Path     string  `json:"path"`     // E.g. /api/training/data.
+		HMACKey  string  `json:"hmacKey"`  // Key for signing the sample.
+		Interval float64 `json:"interval"` // In milliseconds.
+		Sensor   string  `json:"sensor"`
+	}
+
+	serverMessage struct {
+		Hello  *helloResponse `json:"hello"`
+		Sample *sampleRequest `json:"sample"`
+		Err    string         `json:"err"`
+	}
+)
+
+// Client connects a device to Studio.
+type Client struct {
+	opts   ClientOpts
+	logger edgeimpulse.Logger
+}
+
+// NewClient returns a client, call Run to connect.
+func NewClient(opts ...Option) (*Client, error) {
+	c := &Client{}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&c.opts)
+		}
+	}
+	if c.opts.APIKey == "" || c.opts.DeviceID == "" {
+		return nil, fmt.Errorf("api key and device id are required")
+	}
+	if len(c.opts.Sensors) == 0 {
+		return nil, fmt.Errorf("no sensors")
+	}
+	for _, s := range c.opts.Sensors {
+		if s.Name == "" || s.Open == nil || len(s.Frequencies) == 0 {
+			return nil, fmt.Errorf("sensor %q: name, frequencies and open function are required", s.Name)
+		}
+	}
+	if c.opts.URL == "" {
+		c.opts.URL = DefaultURL
+	}
+	if c.opts.DeviceType == "" {
+		c.opts.DeviceType = "LINUX_GO"
+	}
+	if c.opts.ReconnectDelay == 0 {
+		c.opts.ReconnectDelay = 5 * time.Second
+	}
+	c.logger = edgeimpulse.DefaultLogger(c.opts.Logger, c.opts.Verbose)
+	return c, nil
+}
+
+// Run connects to the service and records samples as requested from Studio,
+// until ctx is canceled. After the connection breaks, Run reconnects. Run
+// returns an error wrapping ErrRejected if the service rejects the device.
+func (c *Client) Run(ctx context.Context) error {
+	for {
+		err := c.serve(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrRejected) {
+			return err
+		}
+		c.logger.Logf(edgeimpulse.LogError, "remote management: %v, reconnecting in %v", err, c.opts.ReconnectDelay)
+		t := time.NewTimer(c.opts.ReconnectDelay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// serve handles a single connection.
+func (c *Client) serve(ctx context.Context) error {
+	conn, err := websocket.Dial(ctx, c.opts.URL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// Keep the connection alive, and unblock reading when done.
+		t := time.NewTicker(30 * time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-t.C:
+				conn.WriteMessage(websocket.OpPing, nil)
+			}
+		}
+	}()
+
+	h := hello{
+		Version:    3,
+		APIKey:     c.opts.APIKey,
+		DeviceID:   c.opts.DeviceID,
+		DeviceType: c.opts.DeviceType,
+		Connection: "ip",
+	}
+	for _, s := range c.opts.Sensors {
+		max := s.MaxSampleLength
+		if max == 0 {
+			max = 5 * time.Minute
+		}
+		h.Sensors = append(h.Sensors, helloSensor{s.Name, int(max.Seconds()), s.Frequencies})
+	}
+	if err := c.send(conn, map[string]interface{}{"hello": h}); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var mutex sync.Mutex
+	busy := false
+
+	for {
+		_, buf, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("reading message: %w", err)
+		}
+		var msg serverMessage
+		if err := json.Unmarshal(buf, &msg); err != nil {
+			c.logger.Logf(edgeimpulse.LogDebug, "remote management: skipping message %q: %v", buf, err)
+			continue
+		}
+		switch {
+		case msg.Hello != nil:
+			if !msg.Hello.Valid {
+				return fmt.Errorf("%w: %s", ErrRejected, msg.Hello.Err)
+			}
+			c.logger.Logf(edgeimpulse.LogInfo, "connected to remote management as device %s", c.opts.DeviceID)
+		case msg.Sample != nil:
+			mutex.Lock()
+			if busy {
+				mutex.Unlock()
+				c.send(conn, map[string]interface{}{"sample": false, "error": "already sampling"})
+				continue
+			}
+			busy = true
+			mutex.Unlock()
+			req := *msg.Sample
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() {
+					mutex.Lock()
+					busy = false
+					mutex.Unlock()
+				}()
+				if err := c.sample(ctx, conn, req); err != nil {
+					c.logger.Logf(edgeimpulse.LogError, "sampling %s: %v", req.Sensor, err)
+					c.send(conn, map[string]interface{}{"sample": false, "error": err.Error()})
+				}
+			}()
+		case msg.Err != "":
+			c.logger.Logf(edgeimpulse.LogError, "remote management: %s", msg.Err)
+		default:
+			c.logger.Logf(edgeimpulse.LogDebug, "remote management: skipping message %q", buf)
+		}
+	}
+}
+
+func (c *Client) send(conn *websocket.Conn, msg interface{}) error {
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := conn.WriteMessage(websocket.OpText, buf); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	return nil
+}
+
+// sample records and uploads a sample as requested, reporting progress.
+func (c *Client) sample(ctx context.Context, conn *websocket.Conn, req sampleRequest) error {
+	var sensor *Sensor
+	for i := range c.opts.Sensors {
+		if c.opts.Sensors[i].Name == req.Sensor {
+			sensor = &c.opts.Sensors[i]
+		}
+	}
+	if sensor == nil {
+		return fmt.Errorf("unknown sensor %q", req.Sensor)
+	}
+	if req.Interval <= 0 || req.Length <= 0 {
+		return fmt.Errorf("invalid interval %vms or length %dms", req.Interval, req.Length)
+	}
+	category, err := pathCategory(req.Path)
+	if err != nil {
+		return err
+	}
+	collector, err := ingest.NewCollector(c.opts.APIKey, req.HMACKey)
+	if err != nil {
+		return err
+	}
+	if c.opts.IngestionURL != "" {
+		collector.IngestionBaseURL = c.opts.IngestionURL
+	}
+	if err := c.send(conn, map[string]interface{}{"sample": true}); err != nil {
+		return err
+	}
+
+	recorder, err := sensor.Open(ctx, 1000/req.Interval)
+	if err != nil {
+		return fmt.Errorf("opening sensor: %w", err)
+	}
+	defer recorder.Close()
+	if err := c.send(conn, map[string]interface{}{"sampleStarted": true}); err != nil {
+		return err
+	}
+	n := int(float64(req.Length) / req.Interval)
+	values := make([][]float64, 0, n)
+	for len(values) < n {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-recorder.Events():
+			if ev.Err != nil {
+				return fmt.Errorf("recording: %w", ev.Err)
+			}
+			values = append(values, ev.Sample.Values)
+		}
+	}
+	recorder.Close()
+
+	if err := c.send(conn, map[string]interface{}{"sampleUploading": true}); err != nil {
+		return err
+	}
+	payload := ingest.CollectPayload{
+		DeviceName: c.opts.DeviceID,
+		DeviceType: c.opts.DeviceType,
+		IntervalMS: int64(req.Interval),
+		Values:     values,
+	}
+	for _, a := range recorder.Axes() {
+		payload.Sensors = append(payload.Sensors, ingest.Sensor{Name: a.Name, Units: a.Units})
+	}
+	opts := &ingest.UploadOpts{Label: req.Label}
+	if c.opts.Metadata != nil {
+		opts.Metadata = c.opts.Metadata()
+	}
+	name, err := collector.Upload(ctx, req.Label+".json", category, payload, opts)
+	if err != nil {
+		return fmt.Errorf("uploading: %w", err)
+	}
+	c.logger.Logf(edgeimpulse.LogInfo, "uploaded sample %s", name)
+	return c.send(conn, map[string]interface{}{"sampleFinished": true})
+}
+
+// pathCategory returns the category of an ingestion path like
+// /api/training/data.
+func pathCategory(path string) (string, error) {
+	t := strings.Split(strings.Trim(path, "/"), "/")
+	if len(t) != 3 || t[0] != "api" || t[2] != "data" {
+		return "", fmt.Errorf("unsupported ingestion path %q", path)
+	}
+	return t[1], nil
+}
@@ -0,0 +1,130 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/websocket"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+type fakeRecorder struct {
+	events chan timeseries.Event
+}
+
+func (r *fakeRecorder) Axes() []timeseries.Axis {
+	return []timeseries.Axis{{Name: "accX", Units: "m/s2"}}
+}
+func (r *fakeRecorder) Frequency() float64            { return 100 }
+func (r *fakeRecorder) Events() chan timeseries.Event { return r.events }
+func (r *fakeRecorder) Close() error                  { return nil }
+
+func TestClient(t *testing.T) {
+	uploads := make(chan string, 1)
+	ingestion := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		uploads <- r.URL.Path + " " + r.Header.Get("x-label") + " " + r.Header.Get("x-metadata") + " " + string(buf)
+		w.Write([]byte("sample1"))
+	}))
+	defer ingestion.Close()
+
+	// Messages received by the remote management service, after hello.
+	received := make(chan string, 10)
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + websocket.AcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		rw.Flush()
+
+		read := func() string {
+			_, _, buf, err := websocket.ReadFrame(rw)
+			if err != nil {
+				return err.Error()
+			}
+			return string(buf)
+		}
+		write := func(s string) {
+			websocket.WriteFrame(conn, websocket.OpText, []byte(s), false)
+		}
+		if msg := read(); !strings.Contains(msg, `"apiKey":"ei_test"`) || !strings.Contains(msg, `"frequencies":[100]`) {
+			t.Errorf("bad hello %s", msg)
+		}
+		write(`{"hello":{"valid":true}}`)
+		write(`{"sample":{"label":"wave","length":30,"path":"/api/testing/data","hmacKey":"abcd","interval":10,"sensor":"Accelerometer"}}`)
+		for i := 0; i < 4; i++ {
+			received <- read()
+		}
+		bufio.NewReader(rw).ReadByte()
+	}))
+	defer service.Close()
+
+	recorder := &fakeRecorder{make(chan timeseries.Event, 3)}
+	for i := 0; i < 3; i++ {
+		recorder.events <- timeseries.Event{Sample: timeseries.Sample{Values: []float64{float64(i)}}}
+	}
+	c, err := NewClient(
+		WithURL("ws"+strings.TrimPrefix(service.URL, "http")),
+		WithAPIKey("ei_test"),
+		WithDevice("00:11:22:33:44:55", ""),
+		WithIngestionURL(ingestion.URL),
+		WithMetadata(func() map[string]string { return map[string]string{"site": "lab"} }),
+		WithSensors(Sensor{
+			Name:        "Accelerometer",
+			Frequencies: []float64{100},
+			Open: func(ctx context.Context, frequency float64) (timeseries.Recorder, error) {
+				if frequency != 100 {
+					t.Errorf("got frequency %v, expected 100", frequency)
+				}
+				return recorder, nil
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Run(ctx)
+
+	for _, want := range []string{`{"sample":true}`, `{"sampleStarted":true}`, `{"sampleUploading":true}`, `{"sampleFinished":true}`} {
+		select {
+		case msg := <-received:
+			if msg != want {
+				t.Fatalf("got message %s, expected %s", msg, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("timeout waiting for %s", want)
+		}
+	}
+	upload := <-uploads
+	if !strings.HasPrefix(upload, `/api/testing/data wave {"site":"lab"} `) {
+		t.Fatalf("bad upload %s", upload)
+	}
+	var data struct {
+		Payload struct {
+			IntervalMS int64       `json:"interval_ms"`
+			Values     [][]float64 `json:"values"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(upload[strings.Index(upload, "{\"protected"):]), &data); err != nil {
+		t.Fatalf("parsing upload: %v", err)
+	}
+	if data.Payload.IntervalMS != 10 || len(data.Payload.Values) != 3 {
+		t.Fatalf("got payload %+v", data.Payload)
+	}
+
+	if _, err := pathCategory("/api/training/files"); err == nil {
+		t.Fatalf("unsupported path accepted")
+	}
+}
@@ -0,0 +1,189 @@
+package edgeimpulse
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RestartRunnerOpts are options for NewRestartRunner. A zero limit is not
+// enforced.
+type RestartRunnerOpts struct {
+	MaxAge             time.Duration // Restart a model process after it ran this long.
+	MaxClassifications int64         // Restart a model process after this many classifications.
+
+	// Receives log messages. If nil, the standard logger is used.
+	Logger Logger
+
+	// For the age of model processes. If nil, SystemClock is used.
+	Clock Clock
+}
+
+// RestartRunner is a runner that proactively replaces its model process after
+// a maximum age or number of classifications, e.g. to mitigate slow memory
+// growth of some model builds in deployments running for months. The new
+// process is started, including its hello handshake, while the old one keeps
+// classifying, and replaces it between classifications, so no classification
+// is lost or delayed by the restart.
+type RestartRunner struct {
+	open        func() (Runner, error)
+	opts        RestartRunnerOpts
+	logger      Logger
+	clock       Clock
+	modelParams ModelParameters
+	project     Project
+	stats       *Stats
+
+	mutex   sync.RWMutex // Held for reading while classifying.
+	runner  Runner
+	started time.Time
+	count   int64              // Updated atomically while classifying.
+	next    chan restartResult // Receives the new runner while starting.
+	closed  bool
+}
+
+type restartResult struct {
+	runner Runner
+	err    error
+}
+
+var errRunnerClosed = errors.New("runner closed")
+
+// Ensure that RestartRunner implements interface Runner.
+var _ Runner = (*RestartRunner)(nil)
+
+// NewRestartRunner opens a runner with open, e.g. a function calling
+// NewRunnerProcess, and returns a runner that calls open again to restart it
+// according to opts.
+func NewRestartRunner(open func() (Runner, error), opts RestartRunnerOpts) (*RestartRunner, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	clock := DefaultClock(opts.Clock)
+	return &RestartRunner{
+		open:        open,
+		opts:        opts,
+		logger:      DefaultLogger(opts.Logger, false),
+		clock:       clock,
+		modelParams: r.ModelParameters(),
+		project:     r.Project(),
+		stats:       &Stats{},
+		runner:      r,
+		started:     clock.Now(),
+	}, nil
+}
+
+// ModelParameters returns the parameters of the model.
+func (r *RestartRunner) ModelParameters() ModelParameters {
+	return r.modelParams
+}
+
+// Project returns the project of the model.
+func (r *RestartRunner) Project() Project {
+	return r.project
+}
+
+// Threads returns the number of threads of the current runner, if it reports
+// them like RunnerProcess, or else 0.
+func (r *RestartRunner) Threads() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if t, ok := r.runner.(interface{ Threads() int }); ok {
+		return t.Threads()
+	}
+	return 0
+}
+
+// Classify classifies data with the current runner, first replacing it if a
+// restarted runner is ready.
+func (r *RestartRunner) Classify(data []float64) (RunnerClassifyResponse, error) {
+	r.restart()
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.closed {
+		return RunnerClassifyResponse{}, errRunnerClosed
+	}
+	atomic.AddInt64(&r.count, 1)
+	t0 := r.clock.Now()
+	resp, err := r.runner.Classify(data)
+	r.record(resp, err, t0)
+	return resp, err
+}
+
+// record adds a classification started at t0 to the stats.
+func (r *RestartRunner) record(resp RunnerClassifyResponse, err error, t0 time.Time) {
+	if err != nil {
+		r.stats.AddError()
+	} else {
+		r.stats.Add(resp, r.clock.Now().Sub(t0))
+	}
+}
+
+// Stats returns the latencies and errors of classifications across all model
+// processes since the runner started, see RunnerProcess.Stats.
+func (r *RestartRunner) Stats() *Stats {
+	return r.stats
+}
+
+// restart starts a new runner in the background when the current one is due,
+// and replaces the current runner when the new one is ready.
+func (r *RestartRunner) restart() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return
+	}
+	age := r.clock.Now().Sub(r.started)
+	due := r.opts.MaxAge > 0 && age >= r.opts.MaxAge || r.opts.MaxClassifications > 0 && r.count >= r.opts.MaxClassifications
+	if r.next == nil {
+		if due {
+			r.logger.Logf(LogInfo, "restarting model process after %v and %d classifications", age.Round(time.Second), r.count)
+			next := make(chan restartResult, 1)
+			r.next = next
+			go func() {
+				nr, err := r.open()
+				next <- restartResult{nr, err}
+			}()
+		}
+		return
+	}
+	var res restartResult
+	select {
+	case res = <-r.next:
+		r.next = nil
+	default:
+		return
+	}
+	if res.err != nil {
+		// Keep the current runner, and try again after another period.
+		r.logger.Logf(LogError, "restarting model process: %v", res.err)
+	} else {
+		// Classifications in progress hold the lock for reading, so the
+		// old runner is idle.
+		if err := r.runner.Close(); err != nil {
+			r.logger.Logf(LogDebug, "closing replaced model process: %v", err)
+		}
+		r.runner = res.runner
+	}
+	r.started = r.clock.Now()
+	r.count = 0
+}
+
+// Close closes the current runner, and a runner that is being started.
+func (r *RestartRunner) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if r.next != nil {
+		if res := <-r.next; res.runner != nil {
+			res.runner.Close()
+		}
+		r.next = nil
+	}
+	return r.runner.Close()
+}
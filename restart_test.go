@@ -0,0 +1,98 @@
+package edgeimpulse_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+type countingRunner struct {
+	mutex      sync.Mutex
+	classified int
+	closed     bool
+}
+
+func (r *countingRunner) ModelParameters() edgeimpulse.ModelParameters {
+	return edgeimpulse.ModelParameters{}
+}
+
+func (r *countingRunner) Project() edgeimpulse.Project {
+	return edgeimpulse.Project{}
+}
+
+func (r *countingRunner) Classify(data []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.classified++
+	return edgeimpulse.RunnerClassifyResponse{}, nil
+}
+
+func (r *countingRunner) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closed = true
+	return nil
+}
+
+func TestRestartRunner(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    edgeimpulse.RestartRunnerOpts
+		advance time.Duration
+	}{
+		{"classifications", edgeimpulse.RestartRunnerOpts{MaxClassifications: 3}, 0},
+		{"age", edgeimpulse.RestartRunnerOpts{MaxAge: time.Hour}, time.Hour},
+	} {
+		var mutex sync.Mutex
+		var opened []*countingRunner
+		open := func() (edgeimpulse.Runner, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			r := &countingRunner{}
+			opened = append(opened, r)
+			return r, nil
+		}
+		clock := edgeimpulse.NewManualClock(time.Unix(1000, 0))
+		tc.opts.Clock = clock
+		r, err := edgeimpulse.NewRestartRunner(open, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: new: %v", tc.name, err)
+		}
+		clock.Advance(tc.advance)
+
+		// Classify until the new runner replaces the first, which must
+		// keep classifying while the new runner starts.
+		classified := 0
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if _, err := r.Classify(nil); err != nil {
+				t.Fatalf("%s: classify: %v", tc.name, err)
+			}
+			classified++
+			mutex.Lock()
+			done := len(opened) == 2 && opened[1].classified > 0
+			mutex.Unlock()
+			if done {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: runner not restarted after %d classifications", tc.name, classified)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		first, second := opened[0], opened[1]
+		if !first.closed || first.classified+second.classified != classified {
+			t.Errorf("%s: got first closed %v, %d+%d classifications, expected closed, %d", tc.name, first.closed, first.classified, second.classified, classified)
+		}
+
+		r.Close()
+		if _, err := r.Classify(nil); err == nil {
+			t.Errorf("%s: classify after close: expected error", tc.name)
+		}
+		if !second.closed {
+			t.Errorf("%s: second runner not closed", tc.name)
+		}
+	}
+}
@@ -2,12 +2,13 @@
 package edgeimpulse
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"net"
 	"os"
 	"os/exec"
@@ -15,8 +16,11 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
 )
 
 // Runner is a running model with model and project parameters, and the ability
@@ -24,35 +28,93 @@ import (
 type Runner interface {
 	ModelParameters() ModelParameters
 	Project() Project
-	Classify(data []float64) (RunnerClassifyResponse, error)
+	Classify(data []float64) (RunnerClassifyResponse, error) // Must not retain data after returning.
 	Close() error
 }
 
-// RunnerProcess is a running model process that can classify data.
+// ContinuousRunner is a Runner that also classifies slices of a window, with
+// the model keeping the rest of the window, see RunnerProcess.ClassifyContinuous.
+type ContinuousRunner interface {
+	Runner
+	ClassifyContinuous(slice []float64) (RunnerClassifyResponse, error) // Must not retain slice after returning.
+}
+
+// RunnerProcess is a running model process that can classify data, or a
+// connection to a model runner started elsewhere, see NewRunnerTCP and
+// NewRunnerSocket.
 type RunnerProcess struct {
-	modelParams ModelParameters
-	project     Project
-	opts        RunnerOpts
-	tempDir     string             // Temp dir created for this runner if any. Removed on close.
-	cancel      context.CancelFunc // For stopping model process.
-	conn        net.Conn           // Unix domain socket to model process.
-	mutex       sync.Mutex         // Serializing writing requests to model process.
-	lastID      int64
+	opts       RunnerOpts
+	logger     Logger
+	trace      TraceWriter // Nil if not tracing.
+	modelPath  string
+	network    string        // Network of runner to connect to, for NewRunnerTCP and NewRunnerSocket.
+	addr       string        // Address of runner to connect to.
+	tempDir    string        // Temp dir created for this runner if any. Removed on close.
+	mutex      sync.Mutex    // Serializing changes of model parameters, and Close.
+	writeMutex sync.Mutex    // Serializing writing requests to model process.
+	lastID     int64         // Updated atomically.
+	threads    int           // Effective number of threads, 0 if engine default.
+	stats      *Stats        // Latencies and errors of classifications, shared by a RunnerPool.
+	stop       chan struct{} // Closed by Close.
+
+	procMutex  sync.Mutex
+	model      *modelState                       // Replaced as a whole, never modified.
+	proc       *modelProcess                     // Nil while respawning.
+	procUp     chan struct{}                     // Closed when proc is set after respawning.
+	thresholds map[int]runnerSetThresholdRequest // Thresholds set by block ID, for restoring after respawning.
+	gen        int                               // Incremented by Reload, stops respawning of the replaced process.
+}
+
+// modelState is the model of a runner, from the hello response of its
+// process. Requests use the state at the time they are made.
+type modelState struct {
+	params  ModelParameters
+	slots   []ModelParameters // Of each model slot, the first is params.
+	project Project
+	caps    Capabilities
+	debug   bool // Request debug output, if enabled and supported.
+}
+
+// state returns the current model state.
+func (r *RunnerProcess) state() *modelState {
+	r.procMutex.Lock()
+	defer r.procMutex.Unlock()
+	return r.model
 }
 
 // ModelParameters returns the parameters for this runner.
 func (r *RunnerProcess) ModelParameters() ModelParameters {
-	return r.modelParams
+	return r.state().params
 }
 
 // Project returns the project for this runner.
 func (r *RunnerProcess) Project() Project {
-	return r.project
+	return r.state().project
+}
+
+// Capabilities returns the protocol features supported by the model process.
+func (r *RunnerProcess) Capabilities() Capabilities {
+	return r.state().caps
+}
+
+// Threads returns the number of threads the model process was started with,
+// from RunnerOpts.Threads or an OMP_NUM_THREADS environment variable, or 0 if
+// the engine decides.
+func (r *RunnerProcess) Threads() int {
+	return r.threads
 }
 
 // Ensure that RunnerProcess implements interface Runner.
 var _ Runner = (*RunnerProcess)(nil)
 
+// ErrModelError is returned, wrapped, when the model process responds with an
+// error instead of a result, e.g. for input of the wrong size.
+var ErrModelError = errors.New("model error")
+
+// ErrModelExited is returned, wrapped, for requests that failed because the
+// model process exited, e.g. crashed, see RunnerOpts.Respawn.
+var ErrModelExited = errors.New("model process exited")
+
 // RunnerResponse represents the basic status of a response from the model.
 type RunnerResponse struct {
 	ID      int64  `json:"id"`
@@ -73,9 +135,53 @@ func (r RunnerResponse) runnerResponse() RunnerResponse {
 // runnerHelloRequest is a request to the model for its parameters.
 type runnerHelloRequest struct {
 	ID    int64 `json:"id"`
-	Hello int   `json:"hello"` // 1
+	Hello int   `json:"hello"` // ProtocolVersion.
 }
 
+// ProtocolVersion is the version of the runner protocol spoken by this package,
+// sent to the model in the hello request.
+const ProtocolVersion = 1
+
+// Capabilities are the protocol features a model process supports, derived
+// from its hello response: from the feature flags of models that report them,
+// and otherwise from the model parameters.
+//
+// Runners only use features the model supports, so both older and newer model
+// files work: unknown fields in responses are ignored, and features missing in
+// older models are reported with errors like ErrNoThreshold instead of failing
+// requests.
+type Capabilities struct {
+	// Protocol version of the model process, 1 if not reported.
+	Version int `json:"version"`
+
+	// Thresholds can be changed at runtime, see RunnerProcess.SetMinScore.
+	Thresholds bool `json:"thresholds"`
+
+	// Slices of a window can be classified with
+	// RunnerProcess.ClassifyContinuous, with the model keeping the rest of
+	// the window.
+	Continuous bool `json:"continuous"`
+
+	// Model tracks objects across frames itself.
+	Tracking bool `json:"tracking"`
+
+	// Number of model slots, impulses built into one model process, see
+	// RunnerProcess.ClassifySlot. 1 for regular models.
+	Slots int `json:"slots"`
+
+	// Classifications can return debug output of the model, see
+	// RunnerOpts.Debug. Only models reporting feature flags support it.
+	Debug bool `json:"debug"`
+}
+
+// Feature flags in the hello response of models that report them.
+const (
+	featureThresholds     = "thresholds"
+	featureContinuous     = "continuous"
+	featureObjectTracking = "object_tracking"
+	featureDebug          = "debug"
+)
+
 // ModelType can be "classification" or "object_detection". May be expanded in
 // the future.
 type ModelType string
@@ -88,8 +194,18 @@ const (
 	// ModelTypeObjectDetection indicates the model returns returns
 	// bounding boxes for recognized objects.
 	ModelTypeObjectDetection ModelType = "object_detection"
+
+	// ModelTypeConstrainedObjectDetection indicates a FOMO model, which
+	// returns bounding boxes of the centroids of recognized objects.
+	ModelTypeConstrainedObjectDetection ModelType = "constrained_object_detection"
 )
 
+// ObjectDetection returns whether the model returns bounding boxes, for either
+// object detection model type.
+func (t ModelType) ObjectDetection() bool {
+	return t == ModelTypeObjectDetection || t == ModelTypeConstrainedObjectDetection
+}
+
 // SensorType describes the source of measurements/values.
 type SensorType string
 
@@ -113,16 +229,177 @@ type ModelParameters struct {
 
 	InputFeaturesCount int `json:"input_features_count"`
 
+	// Number of values per sample, e.g. 3 for a 3-axis accelerometer.
+	AxisCount int `json:"axis_count"`
+
 	// For images only.
 	ImageInputHeight  int `json:"image_input_height"`
 	ImageInputWidth   int `json:"image_input_width"`
 	ImageChannelCount int `json:"image_channel_count"`
 
+	// Scaling of the image features the runner takes, see image.ParseScaling.
+	// Empty for packed RGB pixels, as taken by model processes. Runners
+	// taking the input of the network directly, e.g. with a model info file
+	// for a network that needs mean/std normalization, set it to another
+	// scaling.
+	ImageFeatureScaling string `json:"image_feature_scaling,omitempty"`
+
 	// Labels in resulting classifications.
 	Labels     []string `json:"labels"`
 	LabelCount int      `json:"label_count"`
 
 	HasAnomaly float64 `json:"has_anomaly"`
+
+	// Number of features in a slice of a window, for models that classify
+	// continuously, e.g. keyword spotting. Zero if not reported by the model.
+	SliceSize         int  `json:"slice_size"`
+	UseContinuousMode bool `json:"use_continuous_mode"`
+
+	// Post-processing settings as configured in Studio, nil if not reported
+	// by the model.
+	PerformanceCalibration *PerformanceCalibration `json:"performance_calibration,omitempty"`
+
+	// Inference engine the model was built for, zero if not reported by the
+	// model. See Engine for its name.
+	InferencingEngine int `json:"inferencing_engine,omitempty"`
+
+	// Thresholds applied by the model that can be changed at runtime, empty
+	// if not supported by the model. See RunnerProcess.SetThreshold.
+	Thresholds []Threshold `json:"thresholds,omitempty"`
+
+	// Whether the model tracks objects across frames, for newer object
+	// detection models.
+	HasObjectTracking bool `json:"has_object_tracking,omitempty"`
+}
+
+// ThresholdObjectDetection is the type of the threshold for the minimum score
+// of bounding boxes.
+const ThresholdObjectDetection = "object_detection"
+
+// Threshold is a threshold applied by the model, e.g. the minimum score of
+// bounding boxes.
+type Threshold struct {
+	ID       int    // Of the block the threshold belongs to.
+	Type     string // E.g. ThresholdObjectDetection, or "anomaly_gmm".
+	MinScore float64
+
+	// Parameters of the threshold by name, e.g. "min_score" for object
+	// detection or "min_anomaly_score" for anomaly detection, including
+	// MinScore if present.
+	Params map[string]float64
+}
+
+// UnmarshalJSON parses a threshold, with all numeric fields besides the ID as
+// parameters.
+func (t *Threshold) UnmarshalJSON(buf []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return err
+	}
+	*t = Threshold{Params: map[string]float64{}}
+	for k, v := range m {
+		var err error
+		switch k {
+		case "id":
+			err = json.Unmarshal(v, &t.ID)
+		case "type":
+			err = json.Unmarshal(v, &t.Type)
+		default:
+			var f float64
+			if json.Unmarshal(v, &f) == nil {
+				t.Params[k] = f
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("threshold field %q: %w", k, err)
+		}
+	}
+	t.MinScore = t.Params["min_score"]
+	return nil
+}
+
+// MarshalJSON returns the threshold in the format of the model.
+func (t Threshold) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{"id": t.ID, "type": t.Type}
+	for k, v := range t.Params {
+		m[k] = v
+	}
+	if _, ok := t.Params["min_score"]; ok || t.MinScore != 0 || t.Type == ThresholdObjectDetection {
+		m["min_score"] = t.MinScore
+	}
+	return json.Marshal(m)
+}
+
+// MinScore returns the minimum score of bounding boxes applied by the model,
+// and whether the model reported it.
+func (p ModelParameters) MinScore() (float64, bool) {
+	for _, t := range p.Thresholds {
+		if t.Type == ThresholdObjectDetection {
+			return t.MinScore, true
+		}
+	}
+	return 0, false
+}
+
+// engines are the names of inferencing engines, by number.
+var engines = map[int]string{
+	1:   "utensor",
+	2:   "tflite",
+	3:   "cubeai",
+	4:   "tflite-full",
+	5:   "tensaiflow",
+	6:   "tensorrt",
+	7:   "drp-ai",
+	8:   "tflite-tidl",
+	9:   "akida",
+	10:  "syntiant",
+	11:  "onnx-tidl",
+	12:  "memryx",
+	255: "none",
+}
+
+// Engine returns the name of the inference engine of the model, e.g. tflite
+// or tensorrt, or empty if not reported.
+func (p ModelParameters) Engine() string {
+	if p.InferencingEngine == 0 {
+		return ""
+	}
+	if name, ok := engines[p.InferencingEngine]; ok {
+		return name
+	}
+	return fmt.Sprintf("engine(%d)", p.InferencingEngine)
+}
+
+// setDefaults sets the model type if the model did not report one, and the
+// sensor type from the numeric sensor.
+func (p *ModelParameters) setDefaults() {
+	if string(p.ModelType) == "" {
+		p.ModelType = ModelTypeClassification
+	}
+	switch p.Sensor {
+	default:
+		p.SensorType = SensorTypeUnknown
+	case 1:
+		p.SensorType = SensorTypeMicrophone
+	case 2:
+		p.SensorType = SensorTypeAccelerometer
+	case 3:
+		p.SensorType = SensorTypeCamera
+	}
+}
+
+// PerformanceCalibration holds post-processing parameters for continuous
+// audio models, as tuned with performance calibration in EdgeImpulse Studio.
+type PerformanceCalibration struct {
+	// Duration over which results should be averaged.
+	AverageWindowDurationMS float64 `json:"average_window_duration_ms"`
+
+	// Minimum averaged score for a detection.
+	DetectionThreshold float64 `json:"detection_threshold"`
+
+	// Duration after a detection during which no new detections should be
+	// made.
+	SuppressionMS float64 `json:"suppression_ms"`
 }
 
 // String returns a human-readable summary of the model parameters.
@@ -141,6 +418,9 @@ func (p ModelParameters) String() string {
 	if len(p.Labels) > 0 {
 		s += ", classes " + strings.Join(p.Labels, ",")
 	}
+	if engine := p.Engine(); engine != "" {
+		s += ", engine " + engine
+	}
 	return s
 }
 
@@ -161,14 +441,38 @@ func (p Project) String() string {
 // runnerHelloResponse is the response from the model to a runnerHelloRequest.
 type runnerHelloResponse struct {
 	RunnerResponse
+	Version         int             `json:"version,omitempty"` // Protocol version, absent in older models.
 	ModelParameters ModelParameters `json:"model_parameters"`
 	Project         Project         `json:"project"`
+
+	// For models with multiple slots, parameters of each, the first equal
+	// to ModelParameters.
+	ModelSlots []ModelParameters `json:"model_slots,omitempty"`
+
+	// Feature flags, e.g. "continuous" and "debug", absent in older models.
+	Features []string `json:"features,omitempty"`
+}
+
+// runnerSetThresholdRequest is a request to the model to change a threshold.
+// SetThreshold holds the parameters, and "id" of the block.
+type runnerSetThresholdRequest struct {
+	ID           int64              `json:"id"`
+	SetThreshold map[string]float64 `json:"set_threshold"`
+}
+
+// runnerClassifyContinuousRequest is a request to the model to classify a
+// slice of data, with the previous slices of the window kept by the model.
+type runnerClassifyContinuousRequest struct {
+	ID                 int64     `json:"id"`
+	ClassifyContinuous []float64 `json:"classify_continuous"`
+	Debug              bool      `json:"debug,omitempty"`
 }
 
 // RunnerClassifyRequest is a request to the model to classify data.
 type RunnerClassifyRequest struct {
 	ID       int64     `json:"id"`
 	Classify []float64 `json:"classify"`
+	Debug    bool      `json:"debug,omitempty"` // Request debug output, see Capabilities.Debug.
 }
 
 // RunnerClassifyResponse is the response from the model to a
@@ -176,27 +480,42 @@ type RunnerClassifyRequest struct {
 type RunnerClassifyResponse struct {
 	RunnerResponse
 
-	Result struct {
-		// Based on the ModelType, either Classification or BoundingBoxes will be set.
-		Classification map[string]float64 `json:"classification,omitempty"`
+	Result ClassifyResult `json:"result"`
+	Timing Timing         `json:"timing"`
+
+	// Debug output of the model if requested, e.g. the features after
+	// signal processing. The format depends on the model.
+	Debug json.RawMessage `json:"debug,omitempty"`
+}
+
+// ClassifyResult is the result of a classification.
+type ClassifyResult struct {
+	// Based on the ModelType, either Classification or BoundingBoxes will be set.
+	Classification Classification `json:"classification,omitempty"`
+	BoundingBoxes  []BoundingBox  `json:"bounding_boxes,omitempty"`
 
-		BoundingBoxes []struct {
-			Label  string  `json:"label"`
-			Value  float64 `json:"value"`
-			X      int     `json:"x"`
-			Y      int     `json:"y"`
-			Width  int     `json:"width"`
-			Height int     `json:"height"`
-		} `json:"bounding_boxes,omitempty"`
+	Anomaly float64 `json:"anomaly,omitempty"`
+}
+
+// Classification holds the score of each label.
+type Classification map[string]float64
 
-		Anomaly float64 `json:"anomaly,omitempty"`
-	} `json:"result"`
+// BoundingBox is an object found by an object detection model, in pixels of
+// the input of the model.
+type BoundingBox struct {
+	Label  string  `json:"label"`
+	Value  float64 `json:"value"` // Score.
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+}
 
-	Timing struct {
-		DSP            float64 `json:"dsp"`
-		Classification float64 `json:"classification"`
-		Anomaly        float64 `json:"anomaly"`
-	} `json:"timing"`
+// Timing holds how long the steps of a classification took, in milliseconds.
+type Timing struct {
+	DSP            float64 `json:"dsp"`
+	Classification float64 `json:"classification"`
+	Anomaly        float64 `json:"anomaly"`
 }
 
 // String returns a summary of the result, with classification or error
@@ -236,24 +555,194 @@ type RunnerOpts struct {
 	// directory is created.
 	WorkDir string
 
-	// If not empty, the JSON-encoded requests and responses are written to
-	// this directory.
+	// If set, the JSON-encoded requests and responses are written to it as
+	// traces named runner-<id>-request.json and runner-<id>-response.json.
+	Trace TraceWriter
+
+	// If not empty and Trace is nil, traces are written to this directory,
+	// see DirTraceWriter.
 	TraceDir string
+
+	// Number of threads the model may use for inference, for engines that
+	// support it. If > 0, passed to the model process as environment
+	// variable OMP_NUM_THREADS. ThreadsPerformanceCores uses a thread for
+	// each performance core, e.g. on big.LITTLE SoCs where the default of
+	// a thread per core is often slower.
+	Threads int
+
+	// Additional environment variables for the model process, as KEY=value,
+	// e.g. to select a hardware delegate of the engine: USE_GPU_INFERENCE=0
+	// runs TensorFlow Lite models on the NPU of an i.MX 8M Plus instead of
+	// the GPU.
+	Env []string
+
+	// Additional command-line arguments for the model process, after the
+	// path of the runner socket.
+	Args []string
+
+	// If > 0, minimum score of bounding boxes for object detection models,
+	// set in the model process if it supports it, see SetMinScore. Models
+	// that don't support it keep their own minimum.
+	MinScore float64
+
+	// Receives log messages. If nil, messages are written to the standard
+	// logger.
+	Logger Logger
+
+	// If set, classifications request debug output from the model, returned
+	// in RunnerClassifyResponse.Debug, if the model supports it, see
+	// Capabilities.Debug.
+	Debug bool
+
+	// Receives each line the model process writes to stdout or stderr, with
+	// stream OutputStdout or OutputStderr, e.g. diagnostics of the engine or
+	// the reason of a crash. If nil, lines are logged at LogDebug level.
+	Output func(stream, line string)
+
+	// Maximum time to wait for the response of the model process to a
+	// classification, and to other requests after starting. If 0,
+	// DefaultClassifyTimeout is used. Slow models on low-power boards may
+	// need more, and a lower timeout detects hung fast models sooner.
+	ClassifyTimeout time.Duration
+
+	// Maximum time to wait for the hello response of the model process when
+	// starting it, e.g. while it loads its weights. If 0,
+	// DefaultHelloTimeout is used.
+	HelloTimeout time.Duration
+
+	// If set, the model process is started again when it exits, e.g. after a
+	// crash, with increasing delays between failed attempts. Requests in
+	// flight fail with ErrModelExited, later requests wait for the new
+	// process for at most ClassifyTimeout.
+	Respawn bool
+
+	// If set, models are started without checking they are built for the
+	// current OS and architecture, see CheckModelArch, e.g. for executables
+	// run through an emulator registered with binfmt_misc.
+	SkipArchCheck bool
+}
+
+// Defaults for RunnerOpts.ClassifyTimeout and RunnerOpts.HelloTimeout.
+const (
+	DefaultClassifyTimeout = 5 * time.Second
+	DefaultHelloTimeout    = 5 * time.Second
+)
+
+// RunnerOption configures a runner started with NewRunnerProcess. A
+// *RunnerOpts is also a RunnerOption, and replaces all settings made by
+// earlier options.
+type RunnerOption interface {
+	applyRunner(o *RunnerOpts)
+}
+
+type runnerOptionFunc func(o *RunnerOpts)
+
+func (fn runnerOptionFunc) applyRunner(o *RunnerOpts) {
+	fn(o)
+}
+
+func (opts *RunnerOpts) applyRunner(o *RunnerOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithWorkDir sets RunnerOpts.WorkDir.
+func WithWorkDir(dir string) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.WorkDir = dir })
+}
+
+// WithTrace sets RunnerOpts.Trace.
+func WithTrace(tw TraceWriter) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.Trace = tw })
+}
+
+// WithTraceDir sets RunnerOpts.TraceDir.
+func WithTraceDir(dir string) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.TraceDir = dir })
+}
+
+// WithThreads sets RunnerOpts.Threads.
+func WithThreads(n int) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.Threads = n })
+}
+
+// WithEnv sets RunnerOpts.Env.
+func WithEnv(env ...string) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.Env = env })
+}
+
+// WithArgs sets RunnerOpts.Args.
+func WithArgs(args ...string) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.Args = args })
+}
+
+// WithMinScore sets RunnerOpts.MinScore.
+func WithMinScore(score float64) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.MinScore = score })
+}
+
+// WithClassifyTimeout sets RunnerOpts.ClassifyTimeout.
+func WithClassifyTimeout(d time.Duration) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.ClassifyTimeout = d })
+}
+
+// WithHelloTimeout sets RunnerOpts.HelloTimeout.
+func WithHelloTimeout(d time.Duration) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.HelloTimeout = d })
+}
+
+// WithRespawn sets RunnerOpts.Respawn.
+func WithRespawn(respawn bool) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.Respawn = respawn })
+}
+
+// WithDebug sets RunnerOpts.Debug.
+func WithDebug(debug bool) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.Debug = debug })
+}
+
+// WithLogger sets RunnerOpts.Logger.
+func WithLogger(logger Logger) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.Logger = logger })
+}
+
+// WithOutput sets RunnerOpts.Output.
+func WithOutput(fn func(stream, line string)) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.Output = fn })
+}
+
+// WithSkipArchCheck sets RunnerOpts.SkipArchCheck.
+func WithSkipArchCheck(skip bool) RunnerOption {
+	return runnerOptionFunc(func(o *RunnerOpts) { o.SkipArchCheck = skip })
 }
 
 // NewRunnerProcess creates and starts a new runner from a model file.
+// Options are applied in order, and may be nil.
 // Always call Close on a runner, to cleanup any temporary directories.
-func NewRunnerProcess(modelPath string, opts *RunnerOpts) (runner *RunnerProcess, rerr error) {
+func NewRunnerProcess(modelPath string, opts ...RunnerOption) (*RunnerProcess, error) {
+	return NewRunnerProcessContext(context.Background(), modelPath, opts...)
+}
+
+// NewRunnerProcessContext is like NewRunnerProcess, but stops starting the
+// model when ctx is canceled, e.g. on shutdown of the application or after a
+// deadline for loading a slow model. The process and temporary directory are
+// then removed, and the error wraps the error of ctx. Canceling ctx after
+// NewRunnerProcessContext returns does not affect the runner.
+func NewRunnerProcessContext(ctx context.Context, modelPath string, opts ...RunnerOption) (runner *RunnerProcess, rerr error) {
 	var err error
 	modelPath, err = filepath.Abs(modelPath)
 	if err != nil {
-		return nil, fmt.Errorf("absolute path for modelPath %q: %v", modelPath, err)
+		return nil, fmt.Errorf("absolute path for modelPath %q: %w", modelPath, err)
 	}
 
-	r := &RunnerProcess{}
-	if opts != nil {
-		r.opts = *opts
+	r := newRunner(opts)
+	if !r.opts.SkipArchCheck {
+		if err := CheckModelArch(modelPath); err != nil {
+			return nil, err
+		}
 	}
+	r.modelPath = modelPath
 
 	// Make sure we cleanup on failure.
 	defer func() {
@@ -265,138 +754,715 @@ func NewRunnerProcess(modelPath string, opts *RunnerOpts) (runner *RunnerProcess
 	if r.opts.WorkDir == "" {
 		dir, err := TempDir()
 		if err != nil {
-			return nil, fmt.Errorf("making temp dir: %v", err)
+			return nil, fmt.Errorf("making temp dir: %w", err)
 		}
 		r.opts.WorkDir = dir
 		r.tempDir = dir
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	r.cancel = cancel
-	cmd := exec.CommandContext(ctx, modelPath, "runner.sock")
+	if r.opts.Threads == ThreadsPerformanceCores {
+		r.opts.Threads = PerformanceCores()
+	}
+	r.threads = r.opts.Threads
+	if r.threads <= 0 {
+		r.threads = threadsEnv(r.opts.Env)
+	}
+
+	if err := r.init(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// newRunner returns a runner with opts applied and defaults set.
+func newRunner(opts []RunnerOption) *RunnerProcess {
+	r := &RunnerProcess{
+		stats: &Stats{},
+		stop:  make(chan struct{}),
+	}
+	for _, o := range opts {
+		if o != nil {
+			o.applyRunner(&r.opts)
+		}
+	}
+	r.logger = DefaultLogger(r.opts.Logger, false)
+	r.trace = DefaultTraceWriter(r.opts.Trace, r.opts.TraceDir)
+	if r.opts.ClassifyTimeout <= 0 {
+		r.opts.ClassifyTimeout = DefaultClassifyTimeout
+	}
+	if r.opts.HelloTimeout <= 0 {
+		r.opts.HelloTimeout = DefaultHelloTimeout
+	}
+	return r
+}
+
+// init starts the model, and sets the parameters from its hello response.
+func (r *RunnerProcess) init(ctx context.Context) error {
+	p, helloResp, err := r.start(ctx, r.modelPath)
+	if p != nil {
+		r.proc = p
+	}
+	if err != nil {
+		return err
+	}
+	r.procMutex.Lock()
+	r.model = r.newModel(helloResp)
+	r.procMutex.Unlock()
+
+	if r.opts.MinScore > 0 && r.state().params.ModelType.ObjectDetection() {
+		if err := r.SetMinScore(r.opts.MinScore); errors.Is(err, ErrNoThreshold) {
+			r.logger.Logf(LogInfo, "model does not support setting minimum score, keeping its own")
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if r.opts.Respawn {
+		go r.respawn(p, 0)
+	}
+	return nil
+}
+
+// newModel returns the parameters, project and capabilities from the hello
+// response of the model.
+func (r *RunnerProcess) newModel(helloResp runnerHelloResponse) *modelState {
+	mp := helloResp.ModelParameters
+	mp.setDefaults()
+	m := &modelState{
+		params:  mp,
+		project: helloResp.Project,
+		slots:   []ModelParameters{mp},
+	}
+	if len(helloResp.ModelSlots) > 1 {
+		m.slots = make([]ModelParameters, len(helloResp.ModelSlots))
+		for i, smp := range helloResp.ModelSlots {
+			smp.setDefaults()
+			m.slots[i] = smp
+		}
+	}
+	m.caps = Capabilities{
+		Version:    helloResp.Version,
+		Thresholds: len(mp.Thresholds) > 0,
+		Continuous: mp.UseContinuousMode && mp.SliceSize > 0,
+		Tracking:   mp.HasObjectTracking,
+		Slots:      len(m.slots),
+	}
+	if helloResp.Features != nil {
+		features := map[string]bool{}
+		for _, f := range helloResp.Features {
+			features[f] = true
+		}
+		m.caps.Thresholds = features[featureThresholds] && len(mp.Thresholds) > 0
+		m.caps.Continuous = features[featureContinuous] && mp.SliceSize > 0
+		m.caps.Tracking = features[featureObjectTracking]
+		m.caps.Debug = features[featureDebug]
+	}
+	if m.caps.Version == 0 {
+		m.caps.Version = 1
+	}
+	if m.caps.Version > ProtocolVersion {
+		r.logger.Logf(LogDebug, "model speaks newer protocol version %d, using version %d", m.caps.Version, ProtocolVersion)
+	}
+	m.debug = r.opts.Debug && m.caps.Debug
+	if r.opts.Debug && !m.caps.Debug {
+		r.logger.Logf(LogInfo, "model does not support debug output, classifying without")
+	}
+	return m
+}
+
+// modelProcess is a started model process, with its connection.
+type modelProcess struct {
+	cancel  context.CancelFunc // For stopping the process.
+	conn    net.Conn           // Unix domain socket to model process.
+	exited  chan struct{}      // Closed when the process exited.
+	waitErr error              // Exit status, set before exited is closed.
+
+	// Requests in flight receive their response from the reading
+	// goroutine, by ID.
+	pendingMutex sync.Mutex
+	pending      map[int64]chan []byte
+	readDone     chan struct{} // Closed when reading responses stopped.
+	readErr      error         // Why reading stopped, set before readDone is closed.
+}
+
+// start starts a process for the model file at modelPath, or connects to the
+// runner at r.addr, and says hello. A non-nil process is returned for
+// stopping, also on error.
+func (r *RunnerProcess) start(ctx context.Context, modelPath string) (*modelProcess, runnerHelloResponse, error) {
+	var helloResp runnerHelloResponse
+	var p *modelProcess
+	var err error
+	if r.addr != "" {
+		p, err = r.dial(ctx)
+	} else {
+		p, err = r.launch(ctx, modelPath)
+	}
+	if err != nil {
+		return p, helloResp, err
+	}
+	go p.read(r.logger)
+
+	// Stop the process if ctx is canceled while waiting for hello, failing
+	// the request.
+	done := make(chan struct{})
+	canceled := make(chan bool)
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.stop()
+			canceled <- true
+		case <-done:
+			canceled <- false
+		}
+	}()
+	helloReq := runnerHelloRequest{ID: r.nextID(), Hello: ProtocolVersion}
+	_, err = r.transactWith(p, helloReq.ID, helloReq, &helloResp, r.opts.HelloTimeout)
+	close(done)
+	if <-canceled {
+		return p, helloResp, fmt.Errorf("hello to model: %w", ctx.Err())
+	}
+	if err != nil {
+		return p, helloResp, fmt.Errorf("hello to model: %w", err)
+	}
+	metrics.RunnerStarts.Inc()
+	return p, helloResp, nil
+}
+
+// launch starts a model process and connects to it, waiting for its socket
+// until ctx is canceled.
+func (r *RunnerProcess) launch(ctx context.Context, modelPath string) (*modelProcess, error) {
+	// The process outlives ctx.
+	pctx, cancel := context.WithCancel(context.Background())
+	p := &modelProcess{
+		cancel:   cancel,
+		exited:   make(chan struct{}),
+		pending:  map[int64]chan []byte{},
+		readDone: make(chan struct{}),
+	}
+	cmd := exec.CommandContext(pctx, modelPath, append([]string{"runner.sock"}, r.opts.Args...)...)
 	cmd.Dir = r.opts.WorkDir
+	if r.opts.Threads > 0 || len(r.opts.Env) > 0 {
+		cmd.Env = os.Environ()
+		if r.opts.Threads > 0 {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("OMP_NUM_THREADS=%d", r.opts.Threads))
+		}
+		cmd.Env = append(cmd.Env, r.opts.Env...)
+	}
+	output := r.opts.Output
+	if output == nil {
+		output = func(stream, line string) {
+			r.logger.Logf(LogDebug, "model %s: %s", stream, line)
+		}
+	}
+	stdout := &lineWriter{stream: OutputStdout, fn: output}
+	stderr := &lineWriter{stream: OutputStderr, fn: output}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	sockPath := r.opts.WorkDir + "/runner.sock"
+	// Of an earlier process.
+	os.Remove(sockPath)
 	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("starting model process: %v", err)
+		close(p.exited)
+		return p, fmt.Errorf("starting model process: %w", err)
 	}
-	go cmd.Wait()
+	go func() {
+		p.waitErr = cmd.Wait()
+		stdout.Flush()
+		stderr.Flush()
+		close(p.exited)
+	}()
 
-	sockPath := r.opts.WorkDir + "/runner.sock"
 	for i := 0; ; i++ {
 		conn, err := net.Dial("unix", sockPath)
 		if err == nil {
-			r.conn = conn
+			p.conn = conn
 			break
 		}
-		if !errors.Is(err, syscall.ENOENT) {
-			return nil, fmt.Errorf("opening runner socket: %v", err)
+		// The socket may exist before the model process listens on it.
+		if !errors.Is(err, syscall.ENOENT) && !errors.Is(err, syscall.ECONNREFUSED) {
+			return p, fmt.Errorf("opening runner socket: %w", err)
 		}
 		if i == 1000 {
-			return nil, fmt.Errorf("no socket from runner")
+			return p, fmt.Errorf("no socket from runner")
+		}
+		select {
+		case <-time.After(1 * time.Millisecond):
+		case <-ctx.Done():
+			return p, fmt.Errorf("waiting for runner socket: %w", ctx.Err())
 		}
-		time.Sleep(1 * time.Millisecond)
 	}
+	return p, nil
+}
 
-	helloReq := runnerHelloRequest{ID: r.nextID(), Hello: 1}
-	var helloResp runnerHelloResponse
-	if err := r.transact(helloReq.ID, helloReq, &helloResp); err != nil {
-		return nil, fmt.Errorf("hello to model: %v", err)
+// stop stops the process and closes its connection.
+func (p *modelProcess) stop() {
+	p.cancel()
+	if p.conn != nil {
+		p.conn.Close()
 	}
-	mp := helloResp.ModelParameters
-	if string(mp.ModelType) == "" {
-		mp.ModelType = ModelTypeClassification
+}
+
+// respawn waits for process p to exit, and restarts the model with
+// increasing delays until it is running again, repeatedly until Close, or
+// until Reload replaces the processes of generation gen.
+func (r *RunnerProcess) respawn(p *modelProcess, gen int) {
+	for {
+		select {
+		case <-p.exited:
+		case <-r.stop:
+			return
+		}
+		select {
+		case <-r.stop:
+			// Stopped by Close.
+			return
+		default:
+		}
+		r.procMutex.Lock()
+		modelPath, reloaded := r.modelPath, r.gen != gen
+		r.procMutex.Unlock()
+		if reloaded {
+			return
+		}
+		r.down(p)
+		p.stop()
+		r.logger.Logf(LogError, "model process exited: %v, restarting", p.waitErr)
+
+		backoff := 100 * time.Millisecond
+		for {
+			select {
+			case <-time.After(backoff):
+			case <-r.stop:
+				return
+			}
+			np, _, err := r.start(context.Background(), modelPath)
+			if err == nil {
+				p = np
+				break
+			}
+			np.stop()
+			r.logger.Logf(LogError, "restarting model process: %v", err)
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+		}
+
+		// Restore the thresholds set on the previous process.
+		r.procMutex.Lock()
+		var thresholds []runnerSetThresholdRequest
+		for _, req := range r.thresholds {
+			thresholds = append(thresholds, req)
+		}
+		r.procMutex.Unlock()
+		for _, req := range thresholds {
+			req.ID = r.nextID()
+			var resp RunnerResponse
+			if _, err := r.transactWith(p, req.ID, req, &resp, r.opts.ClassifyTimeout); err != nil {
+				r.logger.Logf(LogError, "restoring threshold: %v", err)
+			}
+		}
+
+		r.procMutex.Lock()
+		select {
+		case <-r.stop:
+			r.procMutex.Unlock()
+			p.stop()
+			return
+		default:
+		}
+		if r.gen != gen {
+			r.procMutex.Unlock()
+			p.stop()
+			return
+		}
+		r.proc = p
+		close(r.procUp)
+		r.procMutex.Unlock()
+		r.logger.Logf(LogInfo, "model process restarted")
 	}
-	switch mp.Sensor {
-	default:
-		mp.SensorType = SensorTypeUnknown
-	case 1:
-		mp.SensorType = SensorTypeMicrophone
-	case 2:
-		mp.SensorType = SensorTypeAccelerometer
-	case 3:
-		mp.SensorType = SensorTypeCamera
+}
+
+// down marks exited process p as no longer current, so requests wait for
+// the respawned process.
+func (r *RunnerProcess) down(p *modelProcess) {
+	r.procMutex.Lock()
+	defer r.procMutex.Unlock()
+	if r.proc == p {
+		r.proc = nil
+		r.procUp = make(chan struct{})
 	}
-	r.modelParams = mp
-	r.project = helloResp.Project
+}
 
-	return r, nil
+// replaced returns whether p is no longer the current process, e.g. after
+// Reload.
+func (r *RunnerProcess) replaced(p *modelProcess) bool {
+	r.procMutex.Lock()
+	defer r.procMutex.Unlock()
+	return r.proc != nil && r.proc != p
 }
 
-// Do a single request/response transaction.
-func (r *RunnerProcess) transact(id int64, req interface{}, resp runnerResponser) error {
-	if err := json.NewEncoder(r.conn).Encode(req); err != nil {
-		return fmt.Errorf("writing json to model: %v", err)
+// process returns the running model process, waiting at most timeout while it
+// is respawned.
+func (r *RunnerProcess) process(timeout time.Duration) (*modelProcess, error) {
+	r.procMutex.Lock()
+	p, up := r.proc, r.procUp
+	r.procMutex.Unlock()
+	if p != nil {
+		return p, nil
+	}
+	select {
+	case <-up:
+		return r.process(timeout)
+	case <-r.stop:
+		return nil, errRunnerClosed
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("waiting for model process to restart: %w", ErrModelExited)
 	}
+}
 
-	r.writeTrace(fmt.Sprintf("%s/runner-%d-request.json", r.opts.TraceDir, id), req)
+// requestBuffers holds buffers for encoding requests, which for classify
+// requests can be large.
+var requestBuffers = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
 
-	r.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+// Do a single request/response transaction with the current model process,
+// waiting at most timeout for the response. Transactions of multiple
+// goroutines are in flight at the same time, their responses are matched by
+// ID.
+func (r *RunnerProcess) transact(id int64, req interface{}, resp runnerResponser, timeout time.Duration) error {
+	for try := 0; ; try++ {
+		p, err := r.process(timeout)
+		if err != nil {
+			return err
+		}
+		sent, err := r.transactWith(p, id, req, resp, timeout)
+		if !sent && try == 0 && (r.opts.Respawn || r.replaced(p)) && errors.Is(err, ErrModelExited) {
+			// The request did not reach the exited process, so send it
+			// to the respawned or reloaded process instead.
+			r.down(p)
+			continue
+		}
+		return err
+	}
+}
+
+// transactWith does a transaction with model process p. Sent is false if the
+// request could not be written.
+func (r *RunnerProcess) transactWith(p *modelProcess, id int64, req interface{}, resp runnerResponser, timeout time.Duration) (sent bool, rerr error) {
+	buf := requestBuffers.Get().(*bytes.Buffer)
+	defer requestBuffers.Put(buf)
+	buf.Reset()
+	var err error
+	if jw, ok := req.(jsonWriter); ok {
+		err = jw.writeJSON(buf)
+	} else {
+		err = json.NewEncoder(buf).Encode(req)
+	}
+	if err != nil {
+		return false, fmt.Errorf("encoding json for model: %w", err)
+	}
+
+	c := make(chan []byte, 1)
+	p.pendingMutex.Lock()
+	p.pending[id] = c
+	p.pendingMutex.Unlock()
+	forget := func() {
+		p.pendingMutex.Lock()
+		delete(p.pending, id)
+		p.pendingMutex.Unlock()
+	}
 
-	dec := json.NewDecoder(r.conn)
-	if err := dec.Decode(resp); err != nil {
-		return fmt.Errorf("reading json from model: %v", err)
+	r.writeMutex.Lock()
+	_, err = p.conn.Write(buf.Bytes())
+	r.writeMutex.Unlock()
+	if err != nil {
+		forget()
+		return false, r.exitErr(p, fmt.Errorf("writing json to model: %w", err))
 	}
 
-	r.writeTrace(fmt.Sprintf("%s/runner-%d-response.json", r.opts.TraceDir, id), resp)
+	r.writeTrace(fmt.Sprintf("runner-%d-request.json", id), req)
 
-	// Model writes a zero byte after the JSON. It's probably already read, and buffered in the decoder, but not necessarily. So make sure to drain it.
-	buf, err := ioutil.ReadAll(dec.Buffered())
-	if err == nil && len(buf) == 0 {
-		r.conn.Read([]byte{0})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var data []byte
+	select {
+	case data = <-c:
+	case <-timer.C:
+		forget()
+		return true, fmt.Errorf("reading json from model: %w", os.ErrDeadlineExceeded)
+	case <-p.readDone:
+		select {
+		case data = <-c:
+		default:
+			forget()
+			return true, r.exitErr(p, fmt.Errorf("reading json from model: %w", p.readErr))
+		}
+	}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return true, fmt.Errorf("reading json from model: %w", err)
 	}
 
+	r.writeTrace(fmt.Sprintf("runner-%d-response.json", id), resp)
+
 	if !resp.runnerResponse().Success {
-		return fmt.Errorf("classifying: %s", resp.runnerResponse().Error)
+		return true, fmt.Errorf("classifying: %w: %s", ErrModelError, resp.runnerResponse().Error)
 	}
-	return nil
+	return true, nil
 }
 
-func (r *RunnerProcess) writeTrace(filename string, data interface{}) {
-	if r.opts.TraceDir == "" {
-		return
+// exitErr returns ErrModelExited with err if process p exited by itself, e.g.
+// crashed, or else err. The exit is only noticed shortly after the connection
+// fails.
+func (r *RunnerProcess) exitErr(p *modelProcess, err error) error {
+	select {
+	case <-r.stop:
+		return err
+	case <-p.exited:
+	case <-time.After(100 * time.Millisecond):
+		return err
 	}
+	return fmt.Errorf("%w (%v): %v", ErrModelExited, p.waitErr, err)
+}
 
-	f, err := os.Create(filename)
-	if err != nil {
-		log.Printf("trace, creating %s: %v", filename, err)
-		return
+// read reads responses from the model process and hands them to the
+// transactions waiting for them, until reading fails, e.g. after Close.
+func (p *modelProcess) read(logger Logger) {
+	br := bufio.NewReader(p.conn)
+	for {
+		// Model writes a zero byte after the JSON of each response.
+		buf, err := br.ReadBytes(0)
+		if err != nil {
+			p.readErr = err
+			close(p.readDone)
+			return
+		}
+		buf = buf[:len(buf)-1]
+		var resp struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(buf, &resp); err != nil {
+			logger.Logf(LogError, "parsing response from model: %v", err)
+			continue
+		}
+		p.pendingMutex.Lock()
+		c, ok := p.pending[resp.ID]
+		delete(p.pending, resp.ID)
+		p.pendingMutex.Unlock()
+		if !ok {
+			// E.g. the transaction timed out.
+			logger.Logf(LogDebug, "dropping response %d from model without request", resp.ID)
+			continue
+		}
+		c <- buf
 	}
-	defer f.Close()
+}
 
-	if err := json.NewEncoder(f).Encode(data); err != nil {
-		log.Printf("trace, writing data: %v", err)
-	}
-	log.Printf("trace %s", filename)
+// writeTrace writes data as JSON to trace name, if tracing.
+func (r *RunnerProcess) writeTrace(name string, data interface{}) {
+	WriteTrace(r.trace, r.logger, name, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(data)
+	})
 }
 
 func (r *RunnerProcess) nextID() int64 {
-	r.lastID++
-	return r.lastID
+	return atomic.AddInt64(&r.lastID, 1)
 }
 
 // Classify executes the model on the features and returns the resulting
-// classification.
-func (r *RunnerProcess) Classify(data []float64) (resp RunnerClassifyResponse, rerr error) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-
+// classification. Classify can be called from multiple goroutines, their
+// requests are sent to the model process without waiting for earlier
+// responses.
+func (r *RunnerProcess) Classify(data []float64) (RunnerClassifyResponse, error) {
 	req := RunnerClassifyRequest{
 		ID:       r.nextID(),
 		Classify: data,
+		Debug:    r.state().debug,
+	}
+	return r.classify(req.ID, req)
+}
+
+// classifyLatency is exported as metric eim_classify_seconds.
+var classifyLatency = &Histogram{}
+
+func init() {
+	metrics.Default.RegisterHistogram("eim_classify_seconds", "Duration of classify requests to runners.", classifyLatency)
+}
+
+// classify sends classify request req with id, and records its latency and
+// errors in the metrics and stats.
+func (r *RunnerProcess) classify(id int64, req interface{}) (resp RunnerClassifyResponse, err error) {
+	t0 := time.Now()
+	err = r.transact(id, req, &resp, r.opts.ClassifyTimeout)
+	d := time.Since(t0)
+	classifyLatency.Record(d)
+	if err != nil {
+		metrics.ClassifyErrors.Inc()
+		r.stats.AddError()
+	} else {
+		metrics.Classifications.Inc()
+		r.stats.Add(resp, d)
 	}
-	rerr = r.transact(req.ID, req, &resp)
 	return
 }
 
+// Stats returns the latencies and errors of classifications since the runner
+// started, including DSP and classification time reported by the model. The
+// end-to-end latency is the duration of the request to the model.
+func (r *RunnerProcess) Stats() *Stats {
+	return r.stats
+}
+
+// ErrNoThreshold is returned, possibly wrapped, by SetMinScore and
+// SetThreshold if the model does not report the threshold, e.g. because it
+// was built with an older version of the SDK.
+var ErrNoThreshold = errors.New("model has no minimum score threshold")
+
+// SetMinScore sets the minimum score of bounding boxes in the model process,
+// so boxes with lower scores are not returned. This saves encoding and
+// post-processing of many low-confidence boxes, compared to filtering them
+// afterwards. ModelParameters reflects the new minimum score.
+func (r *RunnerProcess) SetMinScore(score float64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.setMinScore(score)
+}
+
+// setMinScore sets the minimum score. Must be called with r.mutex held.
+func (r *RunnerProcess) setMinScore(score float64) error {
+	for _, t := range r.state().params.Thresholds {
+		if t.Type == ThresholdObjectDetection {
+			if err := r.setThreshold(t.ID, map[string]float64{"min_score": score}); err != nil {
+				return fmt.Errorf("setting minimum score: %w", err)
+			}
+			return nil
+		}
+	}
+	return ErrNoThreshold
+}
+
+// SetThreshold changes parameters of the threshold of block blockID in the
+// model process, e.g. {"min_score": 0.6} for an object detection block, or
+// {"min_anomaly_score": 1.5} for an anomaly detection block, see
+// ModelParameters.Thresholds for the blocks and their parameters.
+// ModelParameters reflects the new parameters. If the model has no threshold
+// for the block, an error wrapping ErrNoThreshold is returned.
+func (r *RunnerProcess) SetThreshold(blockID int, params map[string]float64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.setThreshold(blockID, params); err != nil {
+		return fmt.Errorf("setting threshold: %w", err)
+	}
+	return nil
+}
+
+// setThreshold changes a threshold. Must be called with r.mutex held, so the
+// model state is not replaced meanwhile.
+func (r *RunnerProcess) setThreshold(blockID int, params map[string]float64) error {
+	m := r.state()
+	i := -1
+	for j, t := range m.params.Thresholds {
+		if t.ID == blockID {
+			i = j
+		}
+	}
+	if !m.caps.Thresholds || i < 0 {
+		return fmt.Errorf("%w for block %d", ErrNoThreshold, blockID)
+	}
+
+	req := runnerSetThresholdRequest{ID: r.nextID(), SetThreshold: map[string]float64{}}
+	for k, v := range params {
+		req.SetThreshold[k] = v
+	}
+	req.SetThreshold["id"] = float64(blockID)
+	var resp RunnerResponse
+	if err := r.transact(req.ID, req, &resp, r.opts.ClassifyTimeout); err != nil {
+		return err
+	}
+
+	nm := m.withThreshold(i, params)
+	r.procMutex.Lock()
+	r.model = nm
+	if prev, ok := r.thresholds[blockID]; ok {
+		// Keep earlier parameters for restoring.
+		for k, v := range prev.SetThreshold {
+			if _, ok := req.SetThreshold[k]; !ok {
+				req.SetThreshold[k] = v
+			}
+		}
+	}
+	if r.thresholds == nil {
+		r.thresholds = map[int]runnerSetThresholdRequest{}
+	}
+	r.thresholds[blockID] = req
+	r.procMutex.Unlock()
+	return nil
+}
+
+// withThreshold returns a copy of m with params set for threshold i.
+func (m *modelState) withThreshold(i int, params map[string]float64) *modelState {
+	// Don't modify the thresholds returned earlier by ModelParameters.
+	l := append([]Threshold{}, m.params.Thresholds...)
+	t := l[i]
+	t.Params = map[string]float64{}
+	for k, v := range l[i].Params {
+		t.Params[k] = v
+	}
+	for k, v := range params {
+		t.Params[k] = v
+	}
+	if v, ok := params["min_score"]; ok {
+		t.MinScore = v
+	}
+	l[i] = t
+	nm := *m
+	nm.params.Thresholds = l
+	if len(nm.slots) == 1 {
+		nm.slots = []ModelParameters{nm.params}
+	}
+	return &nm
+}
+
+// ErrNoContinuous is returned by ClassifyContinuous if the model does not
+// classify continuously.
+var ErrNoContinuous = errors.New("model does not support continuous classification")
+
+// ClassifyContinuous classifies a slice of ModelParameters.SliceSize features,
+// with the model combining it with the previous slices into a window, for
+// models that classify continuously, see Capabilities. Must not retain slice
+// after returning.
+func (r *RunnerProcess) ClassifyContinuous(slice []float64) (RunnerClassifyResponse, error) {
+	m := r.state()
+	if !m.caps.Continuous {
+		return RunnerClassifyResponse{}, ErrNoContinuous
+	}
+
+	req := runnerClassifyContinuousRequest{
+		ID:                 r.nextID(),
+		ClassifyContinuous: slice,
+		Debug:              m.debug,
+	}
+	return r.classify(req.ID, req)
+}
+
 // Close shuts down the runner, stopping the model process.
 func (r *RunnerProcess) Close() error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if r.cancel != nil {
-		r.cancel()
+	r.procMutex.Lock()
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
 	}
-	if r.conn != nil {
-		r.conn.Close()
+	p := r.proc
+	r.proc = nil
+	r.procMutex.Unlock()
+	if p != nil {
+		p.stop()
 	}
 	if r.tempDir != "" {
 		os.RemoveAll(r.tempDir)
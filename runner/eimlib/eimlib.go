@@ -0,0 +1,205 @@
+// Package eimlib runs models of the "C++ library" deployment of Edge Impulse
+// Studio in-process, as an edgeimpulse.Runner, loaded from a shared library
+// instead of starting an .eim model process. Features are passed to the
+// library directly, without JSON encoding and a socket, which matters for
+// high-rate audio and image classification.
+//
+// The C++ library must be built into a shared library with the C interface in
+// shim/eim_go.h, implemented by shim/eim_go.cpp, e.g.:
+//
+//	g++ -O3 -fPIC -shared -I. -Ishim ... shim/eim_go.cpp <sdk sources> -o libmodel.so
+//
+// Loading shared libraries requires cgo and libdl, and must be enabled with
+// build tag eimlib:
+//
+//	go build -tags eimlib ./...
+//
+// Without the tag, New returns an error.
+//
+// The model parameters and project are reported by the library, like by a
+// model process. Features are the same as for a model process, e.g. packed
+// RGB pixels for images.
+package eimlib
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// ErrNotSupported is returned by New when built without tag eimlib.
+var ErrNotSupported = errors.New("shared library support not built in, build with -tags eimlib")
+
+// RunnerOpts are options for New.
+type RunnerOpts struct {
+	// Maximum number of bounding boxes returned by object detection models.
+	// Defaults to 100.
+	MaxBoxes int
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+}
+
+// Option configures a runner created with New. A *RunnerOpts is also an
+// Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RunnerOpts)
+}
+
+type optionFunc func(o *RunnerOpts)
+
+func (fn optionFunc) apply(o *RunnerOpts) {
+	fn(o)
+}
+
+func (opts *RunnerOpts) apply(o *RunnerOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithMaxBoxes sets RunnerOpts.MaxBoxes.
+func WithMaxBoxes(n int) Option {
+	return optionFunc(func(o *RunnerOpts) { o.MaxBoxes = n })
+}
+
+// WithVerbose sets RunnerOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RunnerOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Logger = logger })
+}
+
+// library is a loaded shared library.
+type library interface {
+	// Info returns the model info as JSON.
+	Info() []byte
+
+	// Classify classifies features, with room for scores of labels and
+	// boxes.
+	Classify(features []float32, labels, boxes int) (output, error)
+
+	Close() error
+}
+
+// output is the result of a classification by the library.
+type output struct {
+	scores  []float64 // Per label.
+	boxes   []edgeimpulse.BoundingBox
+	anomaly float64
+	timing  [3]float64 // DSP, classification and anomaly, in milliseconds.
+}
+
+// Runner classifies with a model in a shared library. Classify can be called
+// concurrently, calls are serialized.
+type Runner struct {
+	info     edgeimpulse.ModelInfo
+	maxBoxes int
+
+	mutex sync.Mutex
+	lib   library // Nil after Close.
+}
+
+// Ensure that Runner implements the edgeimpulse.Float32Runner interface.
+var _ edgeimpulse.Float32Runner = (*Runner)(nil)
+
+// New loads the shared library at path, e.g. "./libmodel.so". A path without
+// slash is searched like by dlopen(3). Always call Close on a runner. A library
+// can only be loaded once per process.
+func New(path string, opts ...Option) (*Runner, error) {
+	var xopts RunnerOpts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
+	}
+	if xopts.MaxBoxes <= 0 {
+		xopts.MaxBoxes = 100
+	}
+	logger := edgeimpulse.DefaultLogger(xopts.Logger, xopts.Verbose)
+
+	lib, err := openLibrary(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := edgeimpulse.ParseModelInfo(lib.Info())
+	if err != nil {
+		lib.Close()
+		return nil, fmt.Errorf("library %s: %w", path, err)
+	}
+	mp := info.ModelParameters
+	logger.Logf(edgeimpulse.LogDebug, "eimlib: loaded %s, %s model with %d input features", path, mp.ModelType, mp.InputFeaturesCount)
+	return &Runner{info: info, maxBoxes: xopts.MaxBoxes, lib: lib}, nil
+}
+
+// ModelParameters returns the model parameters reported by the library.
+func (r *Runner) ModelParameters() edgeimpulse.ModelParameters {
+	return r.info.ModelParameters
+}
+
+// Project returns the project reported by the library.
+func (r *Runner) Project() edgeimpulse.Project {
+	return r.info.Project
+}
+
+// Classify classifies features, in the same format as for a model process.
+func (r *Runner) Classify(data []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+	data32 := make([]float32, len(data))
+	for i, v := range data {
+		data32[i] = float32(v)
+	}
+	return r.Classify32(data32)
+}
+
+// Classify32 is like Classify, for float32 features, which the library takes.
+func (r *Runner) Classify32(data []float32) (edgeimpulse.RunnerClassifyResponse, error) {
+	var resp edgeimpulse.RunnerClassifyResponse
+	mp := r.info.ModelParameters
+	if len(data) != mp.InputFeaturesCount {
+		return resp, fmt.Errorf("got %d features, model takes %d", len(data), mp.InputFeaturesCount)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.lib == nil {
+		return resp, errors.New("runner closed")
+	}
+	out, err := r.lib.Classify(data, len(mp.Labels), r.maxBoxes)
+	if err != nil {
+		return resp, err
+	}
+
+	if mp.ModelType.ObjectDetection() {
+		resp.Result.BoundingBoxes = append([]edgeimpulse.BoundingBox{}, out.boxes...)
+	} else {
+		resp.Result.Classification = map[string]float64{}
+		for i, l := range mp.Labels {
+			resp.Result.Classification[l] = out.scores[i]
+		}
+	}
+	resp.Result.Anomaly = out.anomaly
+	resp.Timing.DSP = out.timing[0]
+	resp.Timing.Classification = out.timing[1]
+	resp.Timing.Anomaly = out.timing[2]
+	resp.Success = true
+	return resp, nil
+}
+
+// Close unloads the library.
+func (r *Runner) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.lib == nil {
+		return nil
+	}
+	err := r.lib.Close()
+	r.lib = nil
+	return err
+}
@@ -0,0 +1,163 @@
+//go:build eimlib
+// +build eimlib
+
+package eimlib
+
+/*
+#cgo CFLAGS: -I${SRCDIR}/shim
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+#include "eim_go.h"
+
+typedef int (*version_fn)(void);
+typedef const char *(*info_fn)(void);
+typedef int (*classify_fn)(const float *, size_t, eim_go_result *);
+
+static int call_version(void *fn) {
+	return ((version_fn)fn)();
+}
+
+static const char *call_info(void *fn) {
+	return ((info_fn)fn)();
+}
+
+static int call_classify(void *fn, const float *features, size_t n, eim_go_result *result) {
+	return ((classify_fn)fn)(features, n, result);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// clibrary is a shared library loaded with dlopen. Buffers passed to the
+// library are allocated in C, and reused.
+type clibrary struct {
+	handle   unsafe.Pointer
+	info     unsafe.Pointer
+	classify unsafe.Pointer
+
+	features  *C.float
+	nfeatures int
+	result    *C.eim_go_result
+	labels    int
+	boxes     int
+}
+
+func openLibrary(path string) (library, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	l := &clibrary{}
+	l.handle = C.dlopen(cpath, C.RTLD_NOW|C.RTLD_LOCAL)
+	if l.handle == nil {
+		return nil, fmt.Errorf("loading library: %s", C.GoString(C.dlerror()))
+	}
+	symbol := func(name string) (unsafe.Pointer, error) {
+		cname := C.CString(name)
+		defer C.free(unsafe.Pointer(cname))
+		p := C.dlsym(l.handle, cname)
+		if p == nil {
+			return nil, fmt.Errorf("library without %s, see shim/eim_go.h", name)
+		}
+		return p, nil
+	}
+	version, err := symbol("eim_go_version")
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+	if v := C.call_version(version); v != C.EIM_GO_VERSION {
+		l.Close()
+		return nil, fmt.Errorf("library has interface version %d, need %d", int(v), C.EIM_GO_VERSION)
+	}
+	if l.info, err = symbol("eim_go_info"); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if l.classify, err = symbol("eim_go_classify"); err != nil {
+		l.Close()
+		return nil, err
+	}
+	l.result = (*C.eim_go_result)(C.calloc(1, C.sizeof_eim_go_result))
+	return l, nil
+}
+
+func (l *clibrary) Info() []byte {
+	return []byte(C.GoString(C.call_info(l.info)))
+}
+
+func (l *clibrary) Classify(features []float32, labels, boxes int) (output, error) {
+	var out output
+	if len(features) == 0 {
+		return out, fmt.Errorf("no features")
+	}
+	if l.nfeatures != len(features) {
+		C.free(unsafe.Pointer(l.features))
+		l.features = (*C.float)(C.malloc(C.size_t(len(features)) * C.sizeof_float))
+		l.nfeatures = len(features)
+	}
+	if l.labels != labels || l.boxes != boxes {
+		C.free(unsafe.Pointer(l.result.classification))
+		C.free(unsafe.Pointer(l.result.boxes))
+		l.result.classification = (*C.float)(C.calloc(C.size_t(labels+1), C.sizeof_float))
+		l.result.boxes = (*C.eim_go_box)(C.calloc(C.size_t(boxes+1), C.sizeof_eim_go_box))
+		l.result.boxes_cap = C.size_t(boxes)
+		l.labels = labels
+		l.boxes = boxes
+	}
+
+	in := (*[1 << 30]C.float)(unsafe.Pointer(l.features))[:len(features):len(features)]
+	for i, v := range features {
+		in[i] = C.float(v)
+	}
+	l.result.boxes_len = 0
+	l.result.anomaly = 0
+	if res := C.call_classify(l.classify, l.features, C.size_t(len(features)), l.result); res != 0 {
+		return out, fmt.Errorf("classifying: error %d", int(res))
+	}
+
+	scores := (*[1 << 30]C.float)(unsafe.Pointer(l.result.classification))[:labels:labels]
+	out.scores = make([]float64, labels)
+	for i, v := range scores {
+		out.scores[i] = float64(v)
+	}
+	n := int(l.result.boxes_len)
+	if n > boxes {
+		n = boxes
+	}
+	cboxes := (*[1 << 24]C.eim_go_box)(unsafe.Pointer(l.result.boxes))[:n:n]
+	for _, b := range cboxes {
+		out.boxes = append(out.boxes, edgeimpulse.BoundingBox{
+			Label:  C.GoString(b.label),
+			Value:  float64(b.value),
+			X:      int(b.x),
+			Y:      int(b.y),
+			Width:  int(b.width),
+			Height: int(b.height),
+		})
+	}
+	out.anomaly = float64(l.result.anomaly)
+	out.timing = [3]float64{float64(l.result.timing_dsp), float64(l.result.timing_classification), float64(l.result.timing_anomaly)}
+	return out, nil
+}
+
+func (l *clibrary) Close() error {
+	if l.result != nil {
+		C.free(unsafe.Pointer(l.result.classification))
+		C.free(unsafe.Pointer(l.result.boxes))
+		C.free(unsafe.Pointer(l.result))
+		l.result = nil
+	}
+	C.free(unsafe.Pointer(l.features))
+	l.features = nil
+	if l.handle != nil {
+		C.dlclose(l.handle)
+		l.handle = nil
+	}
+	return nil
+}
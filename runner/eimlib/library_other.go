@@ -0,0 +1,8 @@
+//go:build !eimlib
+// +build !eimlib
+
+package eimlib
+
+func openLibrary(path string) (library, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,111 @@
+// Implementation of eim_go.h for an Edge Impulse C++ library deployment.
+//
+// Build it with the sources of the deployment into a shared library, e.g. with
+// the Makefile of the example-standalone-inferencing-linux repository, adding
+// this file to the sources and -fPIC -shared to the flags:
+//
+//	g++ -O3 -fPIC -shared -I. -Ishim ... shim/eim_go.cpp <sdk sources> -o libmodel.so
+
+#include <stdio.h>
+#include <string>
+
+#include "edge-impulse-sdk/classifier/ei_run_classifier.h"
+#include "eim_go.h"
+
+// quote returns s as JSON string.
+static std::string quote(const char *s) {
+	std::string r = "\"";
+	for (; *s; s++) {
+		if (*s == '"' || *s == '\\') {
+			r += '\\';
+		}
+		r += *s;
+	}
+	return r + "\"";
+}
+
+extern "C" int eim_go_version(void) {
+	return EIM_GO_VERSION;
+}
+
+extern "C" const char *eim_go_info(void) {
+	static std::string info;
+	if (!info.empty()) {
+		return info.c_str();
+	}
+
+#if EI_CLASSIFIER_OBJECT_DETECTION_CONSTRAINED == 1
+	const char *model_type = "constrained_object_detection";
+#elif EI_CLASSIFIER_OBJECT_DETECTION == 1
+	const char *model_type = "object_detection";
+#else
+	const char *model_type = "classification";
+#endif
+	int channels = 0;
+	if (EI_CLASSIFIER_INPUT_WIDTH > 0 && EI_CLASSIFIER_INPUT_HEIGHT > 0) {
+		channels = EI_CLASSIFIER_NN_INPUT_FRAME_SIZE / (EI_CLASSIFIER_INPUT_WIDTH * EI_CLASSIFIER_INPUT_HEIGHT);
+	}
+	std::string labels;
+	for (size_t i = 0; i < EI_CLASSIFIER_LABEL_COUNT; i++) {
+		if (i > 0) {
+			labels += ",";
+		}
+		labels += quote(ei_classifier_inferencing_categories[i]);
+	}
+
+	char buf[1024];
+	snprintf(buf, sizeof(buf),
+		"\"model_type\":\"%s\",\"sensor\":%d,\"interval_ms\":%f,\"frequency\":%f,"
+		"\"input_features_count\":%d,\"axis_count\":%d,"
+		"\"image_input_width\":%d,\"image_input_height\":%d,\"image_channel_count\":%d,"
+		"\"label_count\":%d,\"has_anomaly\":%d,\"slice_size\":%d,\"use_continuous_mode\":%s,"
+		"\"inferencing_engine\":%d",
+		model_type, EI_CLASSIFIER_SENSOR, (double)EI_CLASSIFIER_INTERVAL_MS, (double)EI_CLASSIFIER_FREQUENCY,
+		EI_CLASSIFIER_DSP_INPUT_FRAME_SIZE, EI_CLASSIFIER_RAW_SAMPLES_PER_FRAME,
+		EI_CLASSIFIER_INPUT_WIDTH, EI_CLASSIFIER_INPUT_HEIGHT, channels,
+		EI_CLASSIFIER_LABEL_COUNT, EI_CLASSIFIER_HAS_ANOMALY, EI_CLASSIFIER_SLICE_SIZE,
+		EI_CLASSIFIER_SENSOR == EI_CLASSIFIER_SENSOR_MICROPHONE ? "true" : "false",
+		EI_CLASSIFIER_INFERENCING_ENGINE);
+	std::string mp = std::string("{") + buf + ",\"labels\":[" + labels + "]}";
+
+	snprintf(buf, sizeof(buf), "\"deploy_version\":%d,\"id\":%d", EI_CLASSIFIER_PROJECT_DEPLOY_VERSION, EI_CLASSIFIER_PROJECT_ID);
+	std::string project = std::string("{") + buf + ",\"name\":" + quote(EI_CLASSIFIER_PROJECT_NAME) + ",\"owner\":" + quote(EI_CLASSIFIER_PROJECT_OWNER) + "}";
+
+	info = "{\"version\":1,\"model_parameters\":" + mp + ",\"project\":" + project + "}";
+	return info.c_str();
+}
+
+extern "C" int eim_go_classify(const float *features, size_t n, eim_go_result *result) {
+	signal_t signal;
+	int err = numpy::signal_from_buffer(features, n, &signal);
+	if (err != 0) {
+		return err;
+	}
+	ei_impulse_result_t r = { 0 };
+	EI_IMPULSE_ERROR res = run_classifier(&signal, &r, false);
+	if (res != EI_IMPULSE_OK) {
+		return res;
+	}
+
+#if EI_CLASSIFIER_OBJECT_DETECTION == 1
+	result->boxes_len = 0;
+	for (size_t i = 0; i < r.bounding_boxes_count && result->boxes_len < result->boxes_cap; i++) {
+		ei_impulse_result_bounding_box_t b = r.bounding_boxes[i];
+		if (b.value == 0) {
+			continue;
+		}
+		result->boxes[result->boxes_len++] = eim_go_box{ b.label, b.value, b.x, b.y, b.width, b.height };
+	}
+#else
+	for (size_t i = 0; i < EI_CLASSIFIER_LABEL_COUNT; i++) {
+		result->classification[i] = r.classification[i].value;
+	}
+#endif
+#if EI_CLASSIFIER_HAS_ANOMALY == 1
+	result->anomaly = r.anomaly;
+#endif
+	result->timing_dsp = r.timing.dsp;
+	result->timing_classification = r.timing.classification;
+	result->timing_anomaly = r.timing.anomaly;
+	return EI_IMPULSE_OK;
+}
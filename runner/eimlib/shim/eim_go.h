@@ -0,0 +1,53 @@
+// C interface of an Edge Impulse C++ library deployment, built as a shared
+// library for package runner/eimlib of the Edge Impulse Linux SDK for Go. See
+// eim_go.cpp.
+#ifndef EIM_GO_H
+#define EIM_GO_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+// Version of this interface, returned by eim_go_version.
+#define EIM_GO_VERSION 1
+
+typedef struct {
+	const char *label;
+	float value;
+	uint32_t x;
+	uint32_t y;
+	uint32_t width;
+	uint32_t height;
+} eim_go_box;
+
+// Result of a classification. The arrays are allocated by the caller.
+typedef struct {
+	float *classification; // Score per label, in order of the labels.
+	eim_go_box *boxes;     // Bounding boxes of object detection models.
+	size_t boxes_cap;      // Room in boxes.
+	size_t boxes_len;      // Number of boxes, set by eim_go_classify.
+	float anomaly;
+	float timing_dsp;            // Milliseconds.
+	float timing_classification; // Milliseconds.
+	float timing_anomaly;        // Milliseconds.
+} eim_go_result;
+
+// eim_go_version returns EIM_GO_VERSION.
+int eim_go_version(void);
+
+// eim_go_info returns the model parameters and project as JSON, in the format
+// of the hello response of model processes.
+const char *eim_go_info(void);
+
+// eim_go_classify classifies n features, and returns 0 (EI_IMPULSE_OK) or an
+// EI_IMPULSE_ERROR.
+int eim_go_classify(const float *features, size_t n, eim_go_result *result);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
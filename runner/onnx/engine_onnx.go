@@ -0,0 +1,308 @@
+//go:build onnx
+// +build onnx
+
+package onnx
+
+/*
+#cgo LDFLAGS: -lonnxruntime
+#include <stdlib.h>
+#include <string.h>
+#include <onnxruntime_c_api.h>
+
+// The C API is a table of functions, these wrappers call them for Go, and turn
+// statuses into error messages to be freed by the caller.
+
+static const OrtApi *ort;
+
+static char *ort_error(OrtStatus *status) {
+	if (status == NULL) {
+		return NULL;
+	}
+	char *msg = strdup(ort->GetErrorMessage(status));
+	ort->ReleaseStatus(status);
+	return msg;
+}
+
+static char *ort_open(const char *path, int threads, OrtEnv **env, OrtSession **session) {
+	if (ort == NULL) {
+		ort = OrtGetApiBase()->GetApi(ORT_API_VERSION);
+		if (ort == NULL) {
+			return strdup("onnx runtime library does not support api version of headers");
+		}
+	}
+	char *err = ort_error(ort->CreateEnv(ORT_LOGGING_LEVEL_WARNING, "edgeimpulse", env));
+	if (err != NULL) {
+		return err;
+	}
+	OrtSessionOptions *opts = NULL;
+	err = ort_error(ort->CreateSessionOptions(&opts));
+	if (err == NULL && threads > 0) {
+		err = ort_error(ort->SetIntraOpNumThreads(opts, threads));
+	}
+	if (err == NULL) {
+		err = ort_error(ort->CreateSession(*env, path, opts, session));
+	}
+	if (opts != NULL) {
+		ort->ReleaseSessionOptions(opts);
+	}
+	return err;
+}
+
+static void ort_close(OrtEnv *env, OrtSession *session) {
+	if (session != NULL) {
+		ort->ReleaseSession(session);
+	}
+	if (env != NULL) {
+		ort->ReleaseEnv(env);
+	}
+}
+
+static size_t ort_count(OrtSession *session, int input) {
+	size_t n = 0;
+	OrtStatus *status = input ? ort->SessionGetInputCount(session, &n) : ort->SessionGetOutputCount(session, &n);
+	free(ort_error(status));
+	return n;
+}
+
+// ort_describe returns the name, element type and shape of input or output i,
+// with at most 8 dimensions.
+static char *ort_describe(OrtSession *session, int input, size_t i, char **name, int *type, int64_t *dims, size_t *ndims) {
+	OrtAllocator *alloc;
+	char *err = ort_error(ort->GetAllocatorWithDefaultOptions(&alloc));
+	if (err != NULL) {
+		return err;
+	}
+	char *n = NULL;
+	err = ort_error(input ? ort->SessionGetInputName(session, i, alloc, &n) : ort->SessionGetOutputName(session, i, alloc, &n));
+	if (err != NULL) {
+		return err;
+	}
+	*name = strdup(n);
+	ort->AllocatorFree(alloc, n);
+
+	OrtTypeInfo *info = NULL;
+	err = ort_error(input ? ort->SessionGetInputTypeInfo(session, i, &info) : ort->SessionGetOutputTypeInfo(session, i, &info));
+	if (err != NULL) {
+		return err;
+	}
+	const OrtTensorTypeAndShapeInfo *tinfo;
+	enum ONNXTensorElementDataType et = ONNX_TENSOR_ELEMENT_DATA_TYPE_UNDEFINED;
+	err = ort_error(ort->CastTypeInfoToTensorInfo(info, &tinfo));
+	if (err == NULL) {
+		err = ort_error(ort->GetTensorElementType(tinfo, &et));
+	}
+	if (err == NULL) {
+		err = ort_error(ort->GetDimensionsCount(tinfo, ndims));
+	}
+	if (err == NULL && *ndims > 8) {
+		err = strdup("more than 8 dimensions");
+	}
+	if (err == NULL) {
+		err = ort_error(ort->GetDimensions(tinfo, dims, *ndims));
+	}
+	*type = et;
+	ort->ReleaseTypeInfo(info);
+	return err;
+}
+
+// ort_run runs the session with float input data, and copies the outputs,
+// each expected to have counts[i] floats, to outputs[i].
+static char *ort_run(OrtSession *session, const char *input_name, float *data, size_t n, int64_t *dims, size_t ndims, const char **output_names, size_t noutputs, void **outputs, size_t *counts) {
+	OrtMemoryInfo *mem;
+	char *err = ort_error(ort->CreateCpuMemoryInfo(OrtArenaAllocator, OrtMemTypeDefault, &mem));
+	if (err != NULL) {
+		return err;
+	}
+	OrtValue *in = NULL;
+	err = ort_error(ort->CreateTensorWithDataAsOrtValue(mem, data, n*sizeof(float), dims, ndims, ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT, &in));
+	ort->ReleaseMemoryInfo(mem);
+	if (err != NULL) {
+		return err;
+	}
+	OrtValue **out = calloc(noutputs, sizeof(OrtValue *));
+	err = ort_error(ort->Run(session, NULL, &input_name, (const OrtValue *const *)&in, 1, output_names, noutputs, out));
+	for (size_t i = 0; err == NULL && i < noutputs; i++) {
+		OrtTensorTypeAndShapeInfo *info;
+		size_t count = 0;
+		err = ort_error(ort->GetTensorTypeAndShape(out[i], &info));
+		if (err == NULL) {
+			err = ort_error(ort->GetTensorShapeElementCount(info, &count));
+			ort->ReleaseTensorTypeAndShapeInfo(info);
+		}
+		if (err == NULL && count != counts[i]) {
+			err = strdup("unexpected number of values in output");
+		}
+		void *p;
+		if (err == NULL) {
+			err = ort_error(ort->GetTensorMutableData(out[i], &p));
+		}
+		if (err == NULL) {
+			memcpy(outputs[i], p, count*sizeof(float));
+		}
+	}
+	for (size_t i = 0; i < noutputs; i++) {
+		if (out[i] != NULL) {
+			ort->ReleaseValue(out[i]);
+		}
+	}
+	free(out);
+	ort->ReleaseValue(in);
+	return err;
+}
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"unsafe"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/nn"
+)
+
+// Element type of float tensors, ONNX_TENSOR_ELEMENT_DATA_TYPE_FLOAT.
+const typeFloat = 1
+
+// engine is an ONNX Runtime session.
+type engine struct {
+	env         *C.OrtEnv
+	session     *C.OrtSession
+	inputName   *C.char
+	inputDims   []C.int64_t
+	input       nn.Tensor
+	outputNames []*C.char
+	outputBufs  []unsafe.Pointer // Allocated with C.malloc.
+	outputs     []nn.Tensor
+}
+
+// cerror returns an error for msg, freeing it, or nil if msg is nil.
+func cerror(msg *C.char) error {
+	if msg == nil {
+		return nil
+	}
+	defer C.free(unsafe.Pointer(msg))
+	return errors.New(C.GoString(msg))
+}
+
+func newEngine(path string, threads int) (nn.Engine, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	e := &engine{}
+	if err := cerror(C.ort_open(cpath, C.int(threads), &e.env, &e.session)); err != nil {
+		e.Close()
+		return nil, fmt.Errorf("loading onnx model %s: %w", path, err)
+	}
+	if n := C.ort_count(e.session, 1); n != 1 {
+		e.Close()
+		return nil, fmt.Errorf("model has %d inputs, expected 1", int(n))
+	}
+	var err error
+	e.inputName, e.input, err = e.describe(true, 0)
+	if err != nil {
+		e.Close()
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	for _, d := range e.input.Shape {
+		e.inputDims = append(e.inputDims, C.int64_t(d))
+	}
+	n := int(C.ort_count(e.session, 0))
+	if n == 0 {
+		e.Close()
+		return nil, fmt.Errorf("model has no outputs")
+	}
+	for i := 0; i < n; i++ {
+		name, t, err := e.describe(false, i)
+		if err != nil {
+			e.Close()
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+		e.outputNames = append(e.outputNames, name)
+		e.outputs = append(e.outputs, t)
+		e.outputBufs = append(e.outputBufs, C.malloc(C.size_t(t.Bytes())))
+	}
+	return e, nil
+}
+
+// describe returns the name and tensor of an input or output. Dynamic
+// dimensions, e.g. for the batch size, are set to 1.
+func (e *engine) describe(input bool, i int) (*C.char, nn.Tensor, error) {
+	var in C.int
+	if input {
+		in = 1
+	}
+	var name *C.char
+	var typ C.int
+	var dims [8]C.int64_t
+	var ndims C.size_t
+	if err := cerror(C.ort_describe(e.session, in, C.size_t(i), &name, &typ, &dims[0], &ndims)); err != nil {
+		if name != nil {
+			C.free(unsafe.Pointer(name))
+		}
+		return nil, nn.Tensor{}, err
+	}
+	if typ != typeFloat {
+		C.free(unsafe.Pointer(name))
+		return nil, nn.Tensor{}, fmt.Errorf("unsupported element type %d, only float32 is supported", int(typ))
+	}
+	t := nn.Tensor{Type: nn.Float32}
+	for _, d := range dims[:ndims] {
+		if d <= 0 {
+			d = 1
+		}
+		t.Shape = append(t.Shape, int(d))
+	}
+	return name, t, nil
+}
+
+func (e *engine) Input() nn.Tensor {
+	return e.input
+}
+
+func (e *engine) Outputs() []nn.Tensor {
+	return e.outputs
+}
+
+func (e *engine) Invoke(input []byte) ([][]byte, error) {
+	if len(input) != e.input.Bytes() {
+		return nil, fmt.Errorf("got %d bytes of input, tensor has %d", len(input), e.input.Bytes())
+	}
+	// Copied to C memory, the session may hold on to it during the run.
+	data := (*C.float)(C.malloc(C.size_t(len(input))))
+	defer C.free(unsafe.Pointer(data))
+	values := (*[1 << 30]C.float)(unsafe.Pointer(data))[:e.input.Len():e.input.Len()]
+	for i := range values {
+		values[i] = C.float(math.Float32frombits(binary.LittleEndian.Uint32(input[4*i:])))
+	}
+	counts := make([]C.size_t, len(e.outputs))
+	for i, t := range e.outputs {
+		counts[i] = C.size_t(t.Len())
+	}
+	err := cerror(C.ort_run(e.session, e.inputName, data, C.size_t(e.input.Len()), &e.inputDims[0], C.size_t(len(e.inputDims)), &e.outputNames[0], C.size_t(len(e.outputNames)), &e.outputBufs[0], &counts[0]))
+	if err != nil {
+		return nil, fmt.Errorf("onnx run: %w", err)
+	}
+	bufs := make([][]byte, len(e.outputs))
+	for i, t := range e.outputs {
+		bufs[i] = C.GoBytes(e.outputBufs[i], C.int(t.Bytes()))
+	}
+	return bufs, nil
+}
+
+func (e *engine) Close() error {
+	C.ort_close(e.env, e.session)
+	e.env, e.session = nil, nil
+	C.free(unsafe.Pointer(e.inputName))
+	e.inputName = nil
+	for _, p := range e.outputNames {
+		C.free(unsafe.Pointer(p))
+	}
+	e.outputNames = nil
+	for _, p := range e.outputBufs {
+		C.free(p)
+	}
+	e.outputBufs = nil
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !onnx
+// +build !onnx
+
+package onnx
+
+import (
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/nn"
+)
+
+func newEngine(path string, threads int) (nn.Engine, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,180 @@
+// Package onnx runs models exported from Edge Impulse Studio as ONNX
+// in-process with ONNX Runtime, as an edgeimpulse.Runner, without a model
+// process and socket.
+//
+// Support for ONNX Runtime requires cgo and the ONNX Runtime C library
+// (libonnxruntime), and must be enabled with build tag onnx:
+//
+//	go build -tags onnx ./...
+//
+// Without the tag, New returns an error.
+//
+// The .onnx file only holds the neural network. The model parameters and
+// project are read from a JSON file, in the format printed by "eimclassify
+// -info" for the .eim model of the same impulse. Images are scaled to 0 to 1,
+// with channels first or last as the input of the network requires. For
+// networks that need other scaling, e.g. mean/std normalization, set
+// "image_feature_scaling" in the model parameters of the JSON file, e.g. to
+// "imagenet", and image classifiers scale the pixels instead. For audio
+// impulses, set RunnerOpts.Preprocess to the Features method of a dsp.MFE or
+// dsp.MFCC with the parameters of the block in Studio. Features of impulses
+// with a raw data block are passed as is. Inputs must be float32. Anomaly
+// detection is not supported.
+package onnx
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/nn"
+)
+
+// ErrNotSupported is returned by New when built without tag onnx.
+var ErrNotSupported = errors.New("onnx runtime support not built in, build with -tags onnx")
+
+// RunnerOpts are options for New.
+type RunnerOpts struct {
+	// Model info file with model parameters and project. Defaults to the
+	// model path with extension .json instead of .onnx.
+	InfoPath string
+
+	// Number of threads for operators. If 0, ONNX Runtime decides.
+	// edgeimpulse.ThreadsPerformanceCores uses a thread for each performance
+	// core.
+	Threads int
+
+	// Minimum score of bounding boxes of object detection models. Defaults
+	// to 0.5.
+	Threshold float64
+
+	// If set, computes the input of the network from features passed to
+	// Classify, e.g. the Features method of a dsp.MFE.
+	Preprocess func(features []float64) ([]float64, error)
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+}
+
+// Option configures a runner created with New. A *RunnerOpts is also an
+// Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RunnerOpts)
+}
+
+type optionFunc func(o *RunnerOpts)
+
+func (fn optionFunc) apply(o *RunnerOpts) {
+	fn(o)
+}
+
+func (opts *RunnerOpts) apply(o *RunnerOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithInfoPath sets RunnerOpts.InfoPath.
+func WithInfoPath(path string) Option {
+	return optionFunc(func(o *RunnerOpts) { o.InfoPath = path })
+}
+
+// WithThreads sets RunnerOpts.Threads.
+func WithThreads(n int) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Threads = n })
+}
+
+// WithThreshold sets RunnerOpts.Threshold.
+func WithThreshold(threshold float64) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Threshold = threshold })
+}
+
+// WithPreprocess sets RunnerOpts.Preprocess.
+func WithPreprocess(fn func(features []float64) ([]float64, error)) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Preprocess = fn })
+}
+
+// WithVerbose sets RunnerOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RunnerOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Logger = logger })
+}
+
+// Runner classifies with an ONNX Runtime session. Classify can be called
+// concurrently, calls are serialized.
+type Runner struct {
+	model   *nn.Model
+	threads int
+}
+
+// Ensure that Runner implements the edgeimpulse.Runner interface.
+var _ edgeimpulse.Runner = (*Runner)(nil)
+
+// New loads the model at modelPath, and its model info. Always call Close on
+// a runner, to free the session.
+func New(modelPath string, opts ...Option) (*Runner, error) {
+	var xopts RunnerOpts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
+	}
+	if xopts.InfoPath == "" {
+		xopts.InfoPath = strings.TrimSuffix(modelPath, filepath.Ext(modelPath)) + ".json"
+	}
+	logger := edgeimpulse.DefaultLogger(xopts.Logger, xopts.Verbose)
+
+	info, err := edgeimpulse.ReadModelInfoFile(xopts.InfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading model info: %w", err)
+	}
+	if xopts.Threads == edgeimpulse.ThreadsPerformanceCores {
+		xopts.Threads = edgeimpulse.PerformanceCores()
+	}
+	engine, err := newEngine(modelPath, xopts.Threads)
+	if err != nil {
+		return nil, err
+	}
+	in := engine.Input()
+	logger.Logf(edgeimpulse.LogDebug, "onnx: loaded %s, input %s %v, %d outputs", modelPath, in.Type, in.Shape, len(engine.Outputs()))
+	model, err := nn.NewModel(engine, info, xopts.Preprocess, xopts.Threshold)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", modelPath, err)
+	}
+	return &Runner{model, xopts.Threads}, nil
+}
+
+// Threads returns the number of threads the model runs with, or 0 if ONNX Runtime
+// decides.
+func (r *Runner) Threads() int {
+	return r.threads
+}
+
+// ModelParameters returns the model parameters from the model info.
+func (r *Runner) ModelParameters() edgeimpulse.ModelParameters {
+	return r.model.ModelParameters()
+}
+
+// Project returns the project from the model info.
+func (r *Runner) Project() edgeimpulse.Project {
+	return r.model.Project()
+}
+
+// Classify classifies features, in the same format as for a model process.
+func (r *Runner) Classify(data []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+	return r.model.Classify(data)
+}
+
+// Close frees the session.
+func (r *Runner) Close() error {
+	return r.model.Close()
+}
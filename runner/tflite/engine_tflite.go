@@ -0,0 +1,140 @@
+//go:build tflite
+// +build tflite
+
+package tflite
+
+/*
+#cgo LDFLAGS: -ltensorflowlite_c
+#include <stdlib.h>
+#include <tensorflow/lite/c/c_api.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/nn"
+)
+
+// engine is a TensorFlow Lite interpreter.
+type engine struct {
+	model   *C.TfLiteModel
+	options *C.TfLiteInterpreterOptions
+	interp  *C.TfLiteInterpreter
+	input   nn.Tensor
+	outputs []nn.Tensor
+}
+
+func newEngine(path string, threads int) (nn.Engine, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+
+	e := &engine{}
+	e.model = C.TfLiteModelCreateFromFile(cpath)
+	if e.model == nil {
+		return nil, fmt.Errorf("loading tflite model %s", path)
+	}
+	e.options = C.TfLiteInterpreterOptionsCreate()
+	if threads > 0 {
+		C.TfLiteInterpreterOptionsSetNumThreads(e.options, C.int32_t(threads))
+	}
+	e.interp = C.TfLiteInterpreterCreate(e.model, e.options)
+	if e.interp == nil {
+		e.Close()
+		return nil, fmt.Errorf("creating tflite interpreter for %s", path)
+	}
+	if C.TfLiteInterpreterAllocateTensors(e.interp) != C.kTfLiteOk {
+		e.Close()
+		return nil, fmt.Errorf("allocating tensors for %s", path)
+	}
+	if n := C.TfLiteInterpreterGetInputTensorCount(e.interp); n != 1 {
+		e.Close()
+		return nil, fmt.Errorf("model has %d inputs, expected 1", int(n))
+	}
+	var err error
+	e.input, err = tensor(C.TfLiteInterpreterGetInputTensor(e.interp, 0))
+	if err != nil {
+		e.Close()
+		return nil, fmt.Errorf("input: %w", err)
+	}
+	n := int(C.TfLiteInterpreterGetOutputTensorCount(e.interp))
+	for i := 0; i < n; i++ {
+		t, err := tensor(C.TfLiteInterpreterGetOutputTensor(e.interp, C.int32_t(i)))
+		if err != nil {
+			e.Close()
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+		e.outputs = append(e.outputs, t)
+	}
+	return e, nil
+}
+
+// tensor describes t.
+func tensor(t *C.TfLiteTensor) (nn.Tensor, error) {
+	var xt nn.Tensor
+	switch C.TfLiteTensorType(t) {
+	case C.kTfLiteFloat32:
+		xt.Type = nn.Float32
+	case C.kTfLiteInt8:
+		xt.Type = nn.Int8
+	case C.kTfLiteUInt8:
+		xt.Type = nn.Uint8
+	default:
+		return xt, fmt.Errorf("unsupported tensor type %d", int(C.TfLiteTensorType(t)))
+	}
+	for i := 0; i < int(C.TfLiteTensorNumDims(t)); i++ {
+		xt.Shape = append(xt.Shape, int(C.TfLiteTensorDim(t, C.int32_t(i))))
+	}
+	q := C.TfLiteTensorQuantizationParams(t)
+	xt.Scale = float64(q.scale)
+	xt.ZeroPoint = int(q.zero_point)
+	return xt, nil
+}
+
+func (e *engine) Input() nn.Tensor {
+	return e.input
+}
+
+func (e *engine) Outputs() []nn.Tensor {
+	return e.outputs
+}
+
+func (e *engine) Invoke(input []byte) ([][]byte, error) {
+	in := C.TfLiteInterpreterGetInputTensor(e.interp, 0)
+	if len(input) != int(C.TfLiteTensorByteSize(in)) {
+		return nil, fmt.Errorf("got %d bytes of input, tensor has %d", len(input), int(C.TfLiteTensorByteSize(in)))
+	}
+	if C.TfLiteTensorCopyFromBuffer(in, unsafe.Pointer(&input[0]), C.size_t(len(input))) != C.kTfLiteOk {
+		return nil, fmt.Errorf("copying input")
+	}
+	if C.TfLiteInterpreterInvoke(e.interp) != C.kTfLiteOk {
+		return nil, fmt.Errorf("tflite invoke failed")
+	}
+	bufs := make([][]byte, len(e.outputs))
+	for i := range e.outputs {
+		out := C.TfLiteInterpreterGetOutputTensor(e.interp, C.int32_t(i))
+		buf := make([]byte, int(C.TfLiteTensorByteSize(out)))
+		if len(buf) > 0 && C.TfLiteTensorCopyToBuffer(out, unsafe.Pointer(&buf[0]), C.size_t(len(buf))) != C.kTfLiteOk {
+			return nil, fmt.Errorf("copying output %d", i)
+		}
+		bufs[i] = buf
+	}
+	return bufs, nil
+}
+
+func (e *engine) Close() error {
+	if e.interp != nil {
+		C.TfLiteInterpreterDelete(e.interp)
+		e.interp = nil
+	}
+	if e.options != nil {
+		C.TfLiteInterpreterOptionsDelete(e.options)
+		e.options = nil
+	}
+	if e.model != nil {
+		C.TfLiteModelDelete(e.model)
+		e.model = nil
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Package tflite runs models from the "TensorFlow Lite" deployment of Edge
+// Impulse Studio in-process, as an edgeimpulse.Runner, without a model
+// process and socket.
+//
+// Support for TensorFlow Lite requires cgo and the TensorFlow Lite C library
+// (libtensorflowlite_c), and must be enabled with build tag tflite:
+//
+//	go build -tags tflite ./...
+//
+// Without the tag, New returns an error.
+//
+// The .tflite file only holds the neural network. The model parameters and
+// project are read from a JSON file, in the format printed by "eimclassify
+// -info" for the .eim model of the same impulse. The network of an impulse
+// with an image or raw data block gets the same features as a model process.
+// For other blocks, e.g. MFE or spectral analysis, set RunnerOpts.Preprocess to
+// compute the features of the block. Anomaly detection is not supported.
+package tflite
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/nn"
+)
+
+// ErrNotSupported is returned by New when built without tag tflite.
+var ErrNotSupported = errors.New("tensorflow lite support not built in, build with -tags tflite")
+
+// RunnerOpts are options for New.
+type RunnerOpts struct {
+	// Model info file with model parameters and project. Defaults to the
+	// model path with extension .json instead of .tflite.
+	InfoPath string
+
+	// Number of threads for the interpreter. If 0, TensorFlow Lite decides.
+	// edgeimpulse.ThreadsPerformanceCores uses a thread for each performance
+	// core.
+	Threads int
+
+	// Minimum score of bounding boxes of object detection models. Defaults
+	// to 0.5.
+	Threshold float64
+
+	// If set, computes the input of the network from features passed to
+	// Classify, e.g. the DSP block of the impulse.
+	Preprocess func(features []float64) ([]float64, error)
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+}
+
+// Option configures a runner created with New. A *RunnerOpts is also an
+// Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RunnerOpts)
+}
+
+type optionFunc func(o *RunnerOpts)
+
+func (fn optionFunc) apply(o *RunnerOpts) {
+	fn(o)
+}
+
+func (opts *RunnerOpts) apply(o *RunnerOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithInfoPath sets RunnerOpts.InfoPath.
+func WithInfoPath(path string) Option {
+	return optionFunc(func(o *RunnerOpts) { o.InfoPath = path })
+}
+
+// WithThreads sets RunnerOpts.Threads.
+func WithThreads(n int) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Threads = n })
+}
+
+// WithThreshold sets RunnerOpts.Threshold.
+func WithThreshold(threshold float64) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Threshold = threshold })
+}
+
+// WithPreprocess sets RunnerOpts.Preprocess.
+func WithPreprocess(fn func(features []float64) ([]float64, error)) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Preprocess = fn })
+}
+
+// WithVerbose sets RunnerOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RunnerOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RunnerOpts) { o.Logger = logger })
+}
+
+// Runner classifies with a TensorFlow Lite interpreter. Classify can be
+// called concurrently, calls are serialized.
+type Runner struct {
+	model   *nn.Model
+	threads int
+}
+
+// Ensure that Runner implements the edgeimpulse.Runner interface.
+var _ edgeimpulse.Runner = (*Runner)(nil)
+
+// New loads the model at modelPath, and its model info. Always call Close on
+// a runner, to free the interpreter.
+func New(modelPath string, opts ...Option) (*Runner, error) {
+	var xopts RunnerOpts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
+	}
+	if xopts.InfoPath == "" {
+		xopts.InfoPath = strings.TrimSuffix(modelPath, filepath.Ext(modelPath)) + ".json"
+	}
+	logger := edgeimpulse.DefaultLogger(xopts.Logger, xopts.Verbose)
+
+	info, err := edgeimpulse.ReadModelInfoFile(xopts.InfoPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading model info: %w", err)
+	}
+	if xopts.Threads == edgeimpulse.ThreadsPerformanceCores {
+		xopts.Threads = edgeimpulse.PerformanceCores()
+	}
+	engine, err := newEngine(modelPath, xopts.Threads)
+	if err != nil {
+		return nil, err
+	}
+	in := engine.Input()
+	logger.Logf(edgeimpulse.LogDebug, "tflite: loaded %s, input %s %v, %d outputs", modelPath, in.Type, in.Shape, len(engine.Outputs()))
+	model, err := nn.NewModel(engine, info, xopts.Preprocess, xopts.Threshold)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", modelPath, err)
+	}
+	return &Runner{model, xopts.Threads}, nil
+}
+
+// Threads returns the number of threads the model runs with, or 0 if TensorFlow Lite
+// decides.
+func (r *Runner) Threads() int {
+	return r.threads
+}
+
+// ModelParameters returns the model parameters from the model info.
+func (r *Runner) ModelParameters() edgeimpulse.ModelParameters {
+	return r.model.ModelParameters()
+}
+
+// Project returns the project from the model info.
+func (r *Runner) Project() edgeimpulse.Project {
+	return r.model.Project()
+}
+
+// Classify classifies features, in the same format as for a model process.
+func (r *Runner) Classify(data []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+	return r.model.Classify(data)
+}
+
+// Close frees the interpreter.
+func (r *Runner) Close() error {
+	return r.model.Close()
+}
@@ -0,0 +1,230 @@
+// Command fakemodel is a stand-in for an .eim model file, for tests. It speaks
+// the runner protocol on the unix domain socket named on the command line,
+// with model parameters and results read from fakemodel.json in the working
+// directory. See package runnertest for the configuration and helpers to
+// build and start it.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/runnertest"
+)
+
+type request struct {
+	ID       int64     `json:"id"`
+	Hello    int       `json:"hello"`
+	Classify []float64 `json:"classify"`
+
+	ClassifyContinuous []float64 `json:"classify_continuous"`
+
+	SetThreshold map[string]float64 `json:"set_threshold"`
+
+	Slot  int  `json:"slot"`
+	Debug bool `json:"debug"`
+}
+
+type timing struct {
+	DSP            float64 `json:"dsp"`
+	Classification float64 `json:"classification"`
+	Anomaly        float64 `json:"anomaly"`
+}
+
+type response struct {
+	ID      int64  `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+
+	// For hello.
+	Version         int         `json:"version,omitempty"`
+	ModelParameters interface{} `json:"model_parameters,omitempty"`
+	Project         interface{} `json:"project,omitempty"`
+	ModelSlots      interface{} `json:"model_slots,omitempty"`
+	Features        []string    `json:"features,omitempty"`
+
+	// For classify.
+	Result json.RawMessage `json:"result,omitempty"`
+	Timing *timing         `json:"timing,omitempty"`
+	Debug  interface{}     `json:"debug,omitempty"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("fakemodel: ")
+	if len(os.Args) < 2 {
+		log.Fatalf("usage: fakemodel socket [arg ...]")
+	}
+	if len(os.Args) > 2 {
+		fmt.Printf("args %s\n", strings.Join(os.Args[2:], " "))
+	}
+
+	buf, err := os.ReadFile(runnertest.ConfigFile)
+	if err != nil {
+		log.Fatalf("reading config: %v", err)
+	}
+	var config runnertest.Config
+	if err := json.Unmarshal(buf, &config); err != nil {
+		log.Fatalf("parsing config: %v", err)
+	}
+
+	l, err := net.Listen("unix", os.Args[1])
+	if err != nil {
+		log.Fatalf("listen: %v", err)
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		log.Fatalf("accept: %v", err)
+	}
+	l.Close()
+	os.Remove(os.Args[1])
+
+	dec := json.NewDecoder(conn)
+	var n, classified int
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			// Runner closed the connection.
+			return
+		}
+		if req.Classify != nil || req.ClassifyContinuous != nil {
+			classified++
+			if classified == config.CrashAfter {
+				log.Fatalf("crashing after %d classify requests", classified)
+			}
+		}
+		resp := response{ID: req.ID, Success: true}
+		mp := config.ModelParameters
+		if req.Slot > 0 && req.Slot < len(config.ModelSlots) {
+			mp = config.ModelSlots[req.Slot]
+		}
+		switch {
+		case req.Hello > 0:
+			time.Sleep(config.HelloDelay)
+			resp.Version = config.Version
+			resp.ModelParameters = mp
+			resp.Project = config.Project
+			if len(config.ModelSlots) > 0 {
+				resp.ModelSlots = config.ModelSlots
+			}
+			resp.Features = config.Features
+		case req.Slot < 0 || req.Slot > 0 && req.Slot >= len(config.ModelSlots):
+			resp.Success = false
+			resp.Error = fmt.Sprintf("unknown slot %d", req.Slot)
+		case req.SetThreshold != nil:
+			id := int(req.SetThreshold["id"])
+			var found bool
+			for i, t := range mp.Thresholds {
+				if t.ID != id {
+					continue
+				}
+				found = true
+				if t.Params == nil {
+					t.Params = map[string]float64{}
+				}
+				for k, v := range req.SetThreshold {
+					if k != "id" {
+						t.Params[k] = v
+					}
+				}
+				if v, ok := t.Params["min_score"]; ok {
+					t.MinScore = v
+				}
+				config.ModelParameters.Thresholds[i] = t
+			}
+			if !found {
+				resp.Success = false
+				resp.Error = fmt.Sprintf("unknown threshold %d", id)
+			}
+		case config.Error != "":
+			resp.Success = false
+			resp.Error = config.Error
+		case req.ClassifyContinuous != nil:
+			if !mp.UseContinuousMode || len(req.ClassifyContinuous) != mp.SliceSize {
+				resp.Success = false
+				resp.Error = fmt.Sprintf("the features array should have %d items, but had %d", mp.SliceSize, len(req.ClassifyContinuous))
+				break
+			}
+			resp.Result = result(config, mp, n)
+			resp.Timing = &timing{DSP: 1, Classification: 2}
+			n++
+		case mp.InputFeaturesCount > 0 && len(req.Classify) != mp.InputFeaturesCount:
+			resp.Success = false
+			resp.Error = fmt.Sprintf("the features array should have %d items, but had %d", mp.InputFeaturesCount, len(req.Classify))
+		default:
+			resp.Result = result(config, mp, n)
+			resp.Timing = &timing{DSP: 1, Classification: 2}
+			n++
+		}
+
+		if req.Debug && resp.Result != nil {
+			resp.Debug = map[string]int{"features": len(req.Classify) + len(req.ClassifyContinuous)}
+		}
+
+		buf, err := json.Marshal(resp)
+		if err != nil {
+			log.Fatalf("marshal response: %v", err)
+		}
+		// Like real models, end a response with a zero byte.
+		if _, err := conn.Write(append(buf, 0)); err != nil {
+			log.Fatalf("writing response: %v", err)
+		}
+	}
+}
+
+// result returns the n-th configured result, cycling through them, or equal
+// scores for all labels of mp if none are configured. Bounding boxes below the
+// object detection threshold are removed.
+func result(config runnertest.Config, mp edgeimpulse.ModelParameters, n int) json.RawMessage {
+	if len(config.Results) > 0 {
+		r := config.Results[n%len(config.Results)]
+		if min, ok := mp.MinScore(); ok {
+			r = filterBoxes(r, min)
+		}
+		return r
+	}
+	labels := mp.Labels
+	c := map[string]float64{}
+	for _, l := range labels {
+		c[l] = 1 / float64(len(labels))
+	}
+	buf, err := json.Marshal(map[string]interface{}{"classification": c})
+	if err != nil {
+		log.Fatalf("marshal result: %v", err)
+	}
+	return buf
+}
+
+// filterBoxes returns result without bounding boxes scoring below min.
+func filterBoxes(result json.RawMessage, min float64) json.RawMessage {
+	var r map[string]json.RawMessage
+	if err := json.Unmarshal(result, &r); err != nil || r["bounding_boxes"] == nil {
+		return result
+	}
+	var boxes []map[string]interface{}
+	if err := json.Unmarshal(r["bounding_boxes"], &boxes); err != nil {
+		log.Fatalf("parsing bounding boxes: %v", err)
+	}
+	l := []map[string]interface{}{}
+	for _, b := range boxes {
+		if v, _ := b["value"].(float64); v >= min {
+			l = append(l, b)
+		}
+	}
+	buf, err := json.Marshal(l)
+	if err != nil {
+		log.Fatalf("marshal bounding boxes: %v", err)
+	}
+	r["bounding_boxes"] = buf
+	buf, err = json.Marshal(r)
+	if err != nil {
+		log.Fatalf("marshal result: %v", err)
+	}
+	return buf
+}
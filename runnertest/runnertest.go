@@ -0,0 +1,146 @@
+// Package runnertest provides a fake model process for testing code that uses
+// runners and classifiers end-to-end, without .eim model files.
+//
+// The fake model is a small Go program that speaks the runner protocol. Build
+// it once, then start runners with model parameters and canned results:
+//
+//	model := runnertest.Build(t)
+//	runner := runnertest.NewRunner(t, model, runnertest.Config{
+//		ModelParameters: edgeimpulse.ModelParameters{
+//			Sensor:             3, // Camera.
+//			ImageInputWidth:    96,
+//			ImageInputHeight:   96,
+//			ImageChannelCount:  3,
+//			InputFeaturesCount: 96 * 96,
+//			Labels:             []string{"person", "other"},
+//		},
+//		Results: []json.RawMessage{
+//			json.RawMessage(`{"classification": {"person": 0.9, "other": 0.1}}`),
+//		},
+//	})
+package runnertest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// ConfigFile is the name of the configuration file of the fake model, read
+// from its working directory.
+const ConfigFile = "fakemodel.json"
+
+// Config configures the fake model.
+type Config struct {
+	// Returned in response to the hello request. Sensor must be set to the
+	// numeric sensor: 1 for microphone, 2 for accelerometer, 3 for camera.
+	ModelParameters edgeimpulse.ModelParameters `json:"model_parameters"`
+	Project         edgeimpulse.Project         `json:"project"`
+
+	// Results returned for classify requests in turn, cycling, each the
+	// "result" object of a response. If empty, each label scores
+	// 1/len(labels).
+	Results []json.RawMessage `json:"results,omitempty"`
+
+	// If set, classify requests fail with this error message.
+	Error string `json:"error,omitempty"`
+
+	// Protocol version in the hello response. If 0, no version is sent, like
+	// older models.
+	Version int `json:"version,omitempty"`
+
+	// If > 0, the model exits without responding when it receives this
+	// many classify requests, like a crashing model.
+	CrashAfter int `json:"crash_after,omitempty"`
+
+	// If > 0, the model waits this long before responding to the hello
+	// request, like a model loading large weights.
+	HelloDelay time.Duration `json:"hello_delay,omitempty"`
+
+	// If set, the model has multiple slots with these parameters, the first
+	// normally equal to ModelParameters. Without Results, classifications
+	// score the labels of the slot.
+	ModelSlots []edgeimpulse.ModelParameters `json:"model_slots,omitempty"`
+
+	// Feature flags in the hello response, e.g. "debug". If nil, none are
+	// sent, like older models. Classify requests asking for debug output get
+	// the number of features as debug output.
+	Features []string `json:"features,omitempty"`
+}
+
+// WriteConfig writes config to ConfigFile in dir. Use dir as RunnerOpts.WorkDir
+// when starting a runner with the fake model.
+func WriteConfig(dir string, config Config) error {
+	buf, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), buf, 0644); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+	return nil
+}
+
+// BuildBinary builds the fake model program into dir, and returns its path.
+// The go command must be in the PATH.
+func BuildBinary(dir string) (string, error) {
+	path := filepath.Join(dir, "fakemodel")
+	cmd := exec.Command("go", "build", "-o", path, "github.com/edgeimpulse/linux-sdk-go/v2/runnertest/fakemodel")
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("building fake model: %w", err)
+	}
+	return path, nil
+}
+
+var build struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+// Build builds the fake model once per test binary, and returns its path. Tests
+// are skipped if the go command is not available.
+func Build(t testing.TB) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found, needed for building fake model")
+	}
+	build.once.Do(func() {
+		dir, err := os.MkdirTemp("", "runnertest")
+		if err != nil {
+			build.err = err
+			return
+		}
+		build.path, build.err = BuildBinary(dir)
+	})
+	if build.err != nil {
+		t.Fatal(build.err)
+	}
+	return build.path
+}
+
+// NewRunner starts a runner for the fake model at path, configured with
+// config. The runner is closed when the test finishes.
+func NewRunner(t testing.TB, path string, config Config) *edgeimpulse.RunnerProcess {
+	t.Helper()
+	dir := t.TempDir()
+	if err := WriteConfig(dir, config); err != nil {
+		t.Fatal(err)
+	}
+	runner, err := edgeimpulse.NewRunnerProcess(path, edgeimpulse.WithWorkDir(dir))
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	t.Cleanup(func() {
+		runner.Close()
+	})
+	return runner
+}
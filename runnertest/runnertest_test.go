@@ -0,0 +1,973 @@
+package runnertest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	goimage "image"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/audio"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+	"github.com/edgeimpulse/linux-sdk-go/v2/runnertest"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+func TestRunner(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             2,
+			Frequency:          100,
+			InputFeaturesCount: 3,
+			Labels:             []string{"idle", "wave"},
+			InferencingEngine:  2,
+		},
+		Project: edgeimpulse.Project{Name: "test", Owner: "runnertest"},
+		Results: []json.RawMessage{
+			json.RawMessage(`{"classification": {"idle": 0.2, "wave": 0.8}}`),
+		},
+	})
+
+	if mp := runner.ModelParameters(); mp.SensorType != edgeimpulse.SensorTypeAccelerometer || len(mp.Labels) != 2 {
+		t.Errorf("got model parameters %+v", mp)
+	}
+	if engine := runner.ModelParameters().Engine(); engine != "tflite" {
+		t.Errorf("got engine %q, expected tflite", engine)
+	}
+	if p := runner.Project(); p.Name != "test" {
+		t.Errorf("got project %+v", p)
+	}
+	resp, err := runner.Classify([]float64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Classification["wave"] != 0.8 {
+		t.Errorf("got %v", resp)
+	}
+	if resp, err := runner.Classify32([]float32{1, 2.5, 3}); err != nil || resp.Result.Classification["wave"] != 0.8 {
+		t.Errorf("classify32: got %v, %v", resp, err)
+	}
+	if _, err := runner.Classify([]float64{1}); !errors.Is(err, edgeimpulse.ErrModelError) {
+		t.Errorf("got error %v, expected ErrModelError", err)
+	}
+	// Fakemodel reports 1ms DSP and 2ms classification.
+	if s := runner.Stats().Snapshot(); s.Total.Count != 2 || s.Errors != 1 || s.DSP.Max != time.Millisecond || s.Classification.Max != 2*time.Millisecond {
+		t.Errorf("got stats %+v", s)
+	}
+	if d, err := runner.Ping(); err != nil || d <= 0 {
+		t.Errorf("ping: got %v, %v", d, err)
+	}
+	runner.Close()
+	if _, err := runner.Ping(); err == nil {
+		t.Errorf("ping after close: got no error")
+	}
+}
+
+func TestConcurrentClassify(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             2,
+			InputFeaturesCount: 3,
+			Labels:             []string{"idle", "wave"},
+		},
+	})
+
+	// Requests with a bad number of features fail, so responses routed to the
+	// wrong request would show.
+	errc := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func(good bool) {
+			for j := 0; j < 20; j++ {
+				data := []float64{1}
+				if good {
+					data = []float64{1, 2, 3}
+				}
+				_, err := runner.Classify(data)
+				if good && err != nil || !good && !errors.Is(err, edgeimpulse.ErrModelError) {
+					errc <- fmt.Errorf("classify with %d features: %v", len(data), err)
+					return
+				}
+			}
+			errc <- nil
+		}(i%2 == 0)
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errc; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRunnerPool(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	config := runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{Sensor: 2, InputFeaturesCount: 3, Labels: []string{"idle", "wave"}},
+	}
+	for i := 0; i < 3; i++ {
+		sub := filepath.Join(dir, fmt.Sprint(i))
+		if err := os.Mkdir(sub, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := runnertest.WriteConfig(sub, config); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool, err := edgeimpulse.NewRunnerPool(model, 3, edgeimpulse.WithWorkDir(dir))
+	if err != nil {
+		t.Fatalf("new runner pool: %v", err)
+	}
+	if pool.Size() != 3 || len(pool.ModelParameters().Labels) != 2 {
+		t.Errorf("got size %d, model parameters %+v", pool.Size(), pool.ModelParameters())
+	}
+	errc := make(chan error)
+	for i := 0; i < 6; i++ {
+		go func() {
+			_, err := pool.Classify([]float64{1, 2, 3})
+			errc <- err
+		}()
+	}
+	for i := 0; i < 6; i++ {
+		if err := <-errc; err != nil {
+			t.Errorf("classify: %v", err)
+		}
+	}
+	if _, err := pool.Ping(); err != nil {
+		t.Errorf("ping: %v", err)
+	}
+	if err := pool.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := pool.Classify([]float64{1, 2, 3}); err == nil {
+		t.Errorf("classify after close: got nil error")
+	}
+}
+
+func TestRespawn(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	err := runnertest.WriteConfig(dir, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{Sensor: 2, InputFeaturesCount: 3, Labels: []string{"idle", "wave"}},
+		CrashAfter:      3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := edgeimpulse.LoggerFunc(func(level edgeimpulse.LogLevel, format string, args ...interface{}) {})
+	var mutex sync.Mutex
+	var stderr []string
+	output := func(stream, line string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if stream == edgeimpulse.OutputStderr {
+			stderr = append(stderr, line)
+		}
+	}
+	runner, err := edgeimpulse.NewRunnerProcess(model, edgeimpulse.WithWorkDir(dir), edgeimpulse.WithRespawn(true), edgeimpulse.WithLogger(logger), edgeimpulse.WithOutput(output))
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	defer runner.Close()
+
+	for i := 1; i <= 4; i++ {
+		_, err := runner.Classify([]float64{1, 2, 3})
+		if i == 3 && !errors.Is(err, edgeimpulse.ErrModelExited) {
+			t.Errorf("classify %d: got error %v, expected ErrModelExited", i, err)
+		} else if i != 3 && err != nil {
+			t.Errorf("classify %d: %v", i, err)
+		}
+	}
+
+	// The crashed process was waited for before respawning.
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(stderr) != 1 || stderr[0] != "fakemodel: crashing after 3 classify requests" {
+		t.Errorf("got stderr %q", stderr)
+	}
+}
+
+func TestRunnerArgs(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	if err := runnertest.WriteConfig(dir, runnertest.Config{ModelParameters: edgeimpulse.ModelParameters{Sensor: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	lines := make(chan string, 1)
+	output := func(stream, line string) {
+		lines <- stream + ": " + line
+	}
+	runner, err := edgeimpulse.NewRunnerProcess(model, edgeimpulse.WithWorkDir(dir), edgeimpulse.WithArgs("-v", "x"), edgeimpulse.WithOutput(output))
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	defer runner.Close()
+	if line := <-lines; line != "stdout: args -v x" {
+		t.Errorf("got output %q", line)
+	}
+}
+
+func TestRunnerContext(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	if err := runnertest.WriteConfig(dir, runnertest.Config{ModelParameters: edgeimpulse.ModelParameters{Sensor: 2}, HelloDelay: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	t0 := time.Now()
+	_, err := edgeimpulse.NewRunnerProcessContext(ctx, model, edgeimpulse.WithWorkDir(dir), edgeimpulse.WithHelloTimeout(time.Minute))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, expected context.DeadlineExceeded", err)
+	}
+	if d := time.Since(t0); d > 10*time.Second {
+		t.Errorf("canceled start took %v", d)
+	}
+}
+
+func TestReload(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	config := runnertest.Config{ModelParameters: edgeimpulse.ModelParameters{Sensor: 2, InputFeaturesCount: 3, Labels: []string{"idle", "wave"}}}
+	if err := runnertest.WriteConfig(dir, config); err != nil {
+		t.Fatal(err)
+	}
+	// The replaced process must not be respawned.
+	logger := edgeimpulse.LoggerFunc(func(level edgeimpulse.LogLevel, format string, args ...interface{}) {
+		if level == edgeimpulse.LogError {
+			t.Errorf(format, args...)
+		}
+	})
+	runner, err := edgeimpulse.NewRunnerProcess(model, edgeimpulse.WithWorkDir(dir), edgeimpulse.WithRespawn(true), edgeimpulse.WithLogger(logger))
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	defer runner.Close()
+
+	// Classify while reloading, no classification may fail.
+	stop := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				errc <- nil
+				return
+			default:
+			}
+			if _, err := runner.Classify([]float64{1, 2, 3}); err != nil {
+				errc <- err
+				return
+			}
+			// Read the model state while it is replaced, for the race
+			// detector.
+			runner.ModelParameters()
+			runner.Capabilities()
+		}
+	}()
+
+	// The fake model reads its config when starting.
+	config.ModelParameters.Labels = []string{"idle", "wave", "snake"}
+	if err := runnertest.WriteConfig(dir, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Reload(model); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	close(stop)
+	if err := <-errc; err != nil {
+		t.Errorf("classify during reload: %v", err)
+	}
+	if labels := runner.ModelParameters().Labels; len(labels) != 3 {
+		t.Errorf("got labels %v after reload", labels)
+	}
+	if resp, err := runner.Classify([]float64{1, 2, 3}); err != nil || len(resp.Result.Classification) != 3 {
+		t.Errorf("classify after reload: got %v, %v", resp, err)
+	}
+	if err := runner.Reload(filepath.Join(dir, "missing.eim")); err == nil {
+		t.Errorf("reload of missing model: got no error")
+	}
+
+	// Classifiers cannot provide input of another shape.
+	config.ModelParameters.InputFeaturesCount = 4
+	if err := runnertest.WriteConfig(dir, config); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Reload(model); !errors.Is(err, edgeimpulse.ErrInputChanged) {
+		t.Errorf("reload with other input: got error %v, expected ErrInputChanged", err)
+	}
+	if n := runner.ModelParameters().InputFeaturesCount; n != 3 {
+		t.Errorf("got %d input features after rejected reload, expected 3", n)
+	}
+	if _, err := runner.Classify([]float64{1, 2, 3}); err != nil {
+		t.Errorf("classify after failed reload: %v", err)
+	}
+}
+
+func TestModelSlots(t *testing.T) {
+	model := runnertest.Build(t)
+	detector := edgeimpulse.ModelParameters{Sensor: 3, InputFeaturesCount: 3, Labels: []string{"person"}}
+	classifier := edgeimpulse.ModelParameters{Sensor: 3, InputFeaturesCount: 2, Labels: []string{"helmet", "no-helmet"}}
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: detector,
+		ModelSlots:      []edgeimpulse.ModelParameters{detector, classifier},
+	})
+
+	if n := runner.Capabilities().Slots; n != 2 {
+		t.Errorf("got %d slots, expected 2", n)
+	}
+	if slots := runner.Slots(); len(slots) != 2 || slots[1].InputFeaturesCount != 2 {
+		t.Errorf("got slots %+v", slots)
+	}
+	if resp, err := runner.ClassifySlot(0, []float64{1, 2, 3}); err != nil || len(resp.Result.Classification) != 1 {
+		t.Errorf("classify slot 0: got %v, %v", resp, err)
+	}
+	if resp, err := runner.ClassifySlot(1, []float64{1, 2}); err != nil || resp.Result.Classification["helmet"] != 0.5 {
+		t.Errorf("classify slot 1: got %v, %v", resp, err)
+	}
+	if _, err := runner.ClassifySlot(2, []float64{1, 2}); !errors.Is(err, edgeimpulse.ErrNoSlot) {
+		t.Errorf("got error %v, expected ErrNoSlot", err)
+	}
+}
+
+func TestRunnerTCP(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	err := runnertest.WriteConfig(dir, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{Sensor: 2, InputFeaturesCount: 3, Labels: []string{"idle", "wave"}},
+		Project:         edgeimpulse.Project{Name: "remote"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Remote runner: the fake model, with its socket forwarded over TCP.
+	cmd := exec.Command(model, "runner.sock")
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var mconn net.Conn
+		for i := 0; i < 1000; i++ {
+			mconn, err = net.Dial("unix", filepath.Join(dir, "runner.sock"))
+			if err == nil {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err != nil {
+			return
+		}
+		defer mconn.Close()
+		go io.Copy(mconn, conn)
+		io.Copy(conn, mconn)
+	}()
+
+	runner, err := edgeimpulse.NewRunnerTCP(l.Addr().String())
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	defer runner.Close()
+	if p := runner.Project(); p.Name != "remote" {
+		t.Errorf("got project %+v", p)
+	}
+	resp, err := runner.Classify([]float64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Classification["wave"] != 0.5 {
+		t.Errorf("got %v", resp)
+	}
+}
+
+func TestRunnerSocket(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	err := runnertest.WriteConfig(dir, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{Sensor: 2, InputFeaturesCount: 3, Labels: []string{"idle", "wave"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Started externally, e.g. by systemd.
+	cmd := exec.Command(model, "runner.sock")
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the model to listen.
+	var runner *edgeimpulse.RunnerProcess
+	for i := 0; i < 1000; i++ {
+		runner, err = edgeimpulse.NewRunnerSocket(filepath.Join(dir, "runner.sock"))
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		t.Fatalf("new runner: %v", err)
+	}
+	if mp := runner.ModelParameters(); len(mp.Labels) != 2 {
+		t.Errorf("got model parameters %+v", mp)
+	}
+	if _, err := runner.Classify([]float64{1, 2, 3}); err != nil {
+		t.Error(err)
+	}
+	runner.Close()
+	// The fake model exits by itself when the connection is closed, it must
+	// not have been killed.
+	if err := cmd.Wait(); err != nil {
+		t.Errorf("model process: %v", err)
+	}
+}
+
+func TestSetMinScore(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			ModelType: edgeimpulse.ModelTypeObjectDetection,
+			Sensor:    3,
+			Labels:    []string{"car"},
+			Thresholds: []edgeimpulse.Threshold{
+				{ID: 3, Type: edgeimpulse.ThresholdObjectDetection, MinScore: 0.2},
+				{ID: 4, Type: "anomaly_gmm", Params: map[string]float64{"min_anomaly_score": 1}},
+			},
+		},
+		Results: []json.RawMessage{
+			json.RawMessage(`{"bounding_boxes": [{"label": "car", "value": 0.3}, {"label": "car", "value": 0.9}]}`),
+		},
+	})
+
+	if min, ok := runner.ModelParameters().MinScore(); !ok || min != 0.2 {
+		t.Errorf("got min score %v, %v, expected 0.2", min, ok)
+	}
+	if err := runner.SetMinScore(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if min, _ := runner.ModelParameters().MinScore(); min != 0.5 {
+		t.Errorf("got min score %v after setting, expected 0.5", min)
+	}
+	resp, err := runner.Classify(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Result.BoundingBoxes) != 1 || resp.Result.BoundingBoxes[0].Value != 0.9 {
+		t.Errorf("got boxes %+v, expected one with value 0.9", resp.Result.BoundingBoxes)
+	}
+
+	if got := runner.ModelParameters().Thresholds[1].Params["min_anomaly_score"]; got != 1 {
+		t.Errorf("got min anomaly score %v, expected 1", got)
+	}
+	if err := runner.SetThreshold(4, map[string]float64{"min_anomaly_score": 2.5}); err != nil {
+		t.Fatal(err)
+	}
+	if got := runner.ModelParameters().Thresholds[1].Params["min_anomaly_score"]; got != 2.5 {
+		t.Errorf("got min anomaly score %v after setting, expected 2.5", got)
+	}
+	if err := runner.SetThreshold(9, map[string]float64{"min_score": 1}); !errors.Is(err, edgeimpulse.ErrNoThreshold) {
+		t.Errorf("got error %v for unknown block, expected ErrNoThreshold", err)
+	}
+
+	runner = runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{ModelType: edgeimpulse.ModelTypeObjectDetection, Sensor: 3},
+	})
+	if err := runner.SetMinScore(0.5); !errors.Is(err, edgeimpulse.ErrNoThreshold) {
+		t.Errorf("got error %v, expected ErrNoThreshold", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	model := runnertest.Build(t)
+	old := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{Sensor: 1, Labels: []string{"yes"}},
+	})
+	if caps := old.Capabilities(); caps != (edgeimpulse.Capabilities{Version: 1, Slots: 1}) {
+		t.Errorf("got capabilities %+v for old model", caps)
+	}
+	if _, err := old.ClassifyContinuous([]float64{1}); !errors.Is(err, edgeimpulse.ErrNoContinuous) {
+		t.Errorf("got error %v, expected ErrNoContinuous", err)
+	}
+
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		Version: 2,
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             1,
+			Frequency:          4,
+			InputFeaturesCount: 16,
+			SliceSize:          4,
+			UseContinuousMode:  true,
+			Labels:             []string{"yes"},
+		},
+		// Unknown fields from newer models are ignored.
+		Results: []json.RawMessage{
+			json.RawMessage(`{"classification": {"yes": 0.7}, "visual_anomaly_grid": [], "new_field": {"x": 1}}`),
+		},
+	})
+	if caps := runner.Capabilities(); caps != (edgeimpulse.Capabilities{Version: 2, Continuous: true, Slots: 1}) {
+		t.Errorf("got capabilities %+v", caps)
+	}
+	resp, err := runner.ClassifyContinuous([]float64{1, 2, 3, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Result.Classification["yes"] != 0.7 {
+		t.Errorf("got %v", resp)
+	}
+}
+
+type recorder struct {
+	events chan image.Event
+}
+
+func (r recorder) Events() chan image.Event { return r.events }
+func (r recorder) Close() error             { return nil }
+
+func TestFeatureFlags(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	err := runnertest.WriteConfig(dir, runnertest.Config{
+		Version: 2,
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             1,
+			InputFeaturesCount: 8,
+			SliceSize:          4,
+			Labels:             []string{"yes"},
+			Thresholds:         []edgeimpulse.Threshold{{ID: 3, Type: "anomaly_gmm", Params: map[string]float64{"min_anomaly_score": 0.5}}},
+		},
+		// Flags decide over model parameters: no thresholds, but continuous
+		// mode.
+		Features: []string{"continuous", "debug", "unknown"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, err := edgeimpulse.NewRunnerProcess(model, edgeimpulse.WithWorkDir(dir), edgeimpulse.WithDebug(true))
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	defer runner.Close()
+
+	if caps := runner.Capabilities(); caps != (edgeimpulse.Capabilities{Version: 2, Continuous: true, Slots: 1, Debug: true}) {
+		t.Errorf("got capabilities %+v", caps)
+	}
+	resp, err := runner.Classify([]float64{1, 2, 3, 4, 5, 6, 7, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Debug) != `{"features":8}` {
+		t.Errorf("got debug output %s", resp.Debug)
+	}
+	if resp, err := runner.Classify32([]float32{1, 2, 3, 4, 5, 6, 7, 8}); err != nil || resp.Debug == nil {
+		t.Errorf("classify32: got %v, debug %s, %v", resp, resp.Debug, err)
+	}
+}
+
+func TestImageClassifier(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             3,
+			ImageInputWidth:    4,
+			ImageInputHeight:   4,
+			ImageChannelCount:  3,
+			InputFeaturesCount: 16,
+			Labels:             []string{"a", "b"},
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rec := recorder{make(chan image.Event, 1)}
+	trace := &memTrace{traces: map[string][]byte{}}
+	cl, err := image.NewClassifier(ctx, runner, rec, image.WithTrace(trace), image.WithLogger(edgeimpulse.NopLogger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	rec.events <- image.Event{Image: goimage.NewRGBA(goimage.Rect(0, 0, 8, 6))}
+	select {
+	case ev := <-cl.Events:
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		if ev.FrameID != 1 || ev.Result.Classification["a"] != 0.5 {
+			t.Errorf("got event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for classification")
+	}
+
+	// The image is traced before it is classified, numbered like the
+	// classify request after the hello request.
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+	if buf := trace.traces["image-2.png"]; !bytes.HasPrefix(buf, []byte("\x89PNG")) {
+		t.Errorf("got traces %v, expected image-2.png", trace.names())
+	}
+}
+
+// memTrace is a TraceWriter keeping traces in memory.
+type memTrace struct {
+	mutex  sync.Mutex
+	traces map[string][]byte
+}
+
+type memTraceFile struct {
+	bytes.Buffer
+	t    *memTrace
+	name string
+}
+
+func (t *memTrace) CreateTrace(name string) (io.WriteCloser, error) {
+	return &memTraceFile{t: t, name: name}, nil
+}
+
+// names returns the names of the traces. Must be called with lock held.
+func (t *memTrace) names() []string {
+	var l []string
+	for name := range t.traces {
+		l = append(l, name)
+	}
+	return l
+}
+
+func (f *memTraceFile) Close() error {
+	f.t.mutex.Lock()
+	defer f.t.mutex.Unlock()
+	f.t.traces[f.name] = f.Bytes()
+	return nil
+}
+
+type audioRecorder struct {
+	r io.Reader
+}
+
+func (r audioRecorder) Reader() io.Reader { return r.r }
+func (r audioRecorder) Close() error      { return nil }
+
+func TestAudioClassifier(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             1,
+			Frequency:          1000,
+			InputFeaturesCount: 1000,
+			Labels:             []string{"noise", "yes"},
+		},
+		Results: []json.RawMessage{
+			json.RawMessage(`{"classification": {"noise": 0.1, "yes": 0.9}}`),
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Silence as 16 bit samples, an interval at a time, like a recorder.
+	// Windows are dropped while the classifier is busy.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		for {
+			if _, err := pw.Write(make([]byte, 2*250)); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	rec := audioRecorder{pr}
+	cl, err := audio.NewClassifier(ctx, runner, rec, 250*time.Millisecond, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	select {
+	case ev := <-cl.Events:
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		if ev.WindowID != 1 || ev.Result.Classification["yes"] != 0.9 || len(ev.Samples) != 1000 {
+			t.Errorf("got event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for classification")
+	}
+}
+
+func TestAudioClassifierContinuous(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             1,
+			Frequency:          1000,
+			InputFeaturesCount: 1000,
+			SliceSize:          250,
+			UseContinuousMode:  true,
+			Labels:             []string{"noise", "yes"},
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() {
+		for {
+			if _, err := pw.Write(make([]byte, 2*100)); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	// The interval is ignored, slices are sent as soon as they are read.
+	cl, err := audio.NewClassifier(ctx, runner, audioRecorder{pr}, time.Hour, audio.WithContinuous(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	select {
+	case ev := <-cl.Events:
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		if ev.WindowID != 1 || len(ev.Samples) != 250 {
+			t.Errorf("got event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for classification")
+	}
+
+	runner = runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{Sensor: 1, Frequency: 1000, InputFeaturesCount: 1000},
+	})
+	if _, err := audio.NewClassifier(ctx, runner, audioRecorder{pr}, time.Second, audio.WithContinuous(true)); !errors.Is(err, edgeimpulse.ErrNoContinuous) {
+		t.Errorf("got error %v, expected ErrNoContinuous", err)
+	}
+}
+
+type sensorRecorder struct {
+	events chan timeseries.Event
+}
+
+func (r sensorRecorder) Axes() []timeseries.Axis {
+	return []timeseries.Axis{{Name: "accX"}, {Name: "accY"}, {Name: "accZ"}}
+}
+func (r sensorRecorder) Frequency() float64            { return 100 }
+func (r sensorRecorder) Events() chan timeseries.Event { return r.events }
+func (r sensorRecorder) Close() error                  { return nil }
+
+func TestTimeseriesClassifier(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             2,
+			Frequency:          100,
+			AxisCount:          3,
+			InputFeaturesCount: 30,
+			Labels:             []string{"idle", "wave"},
+		},
+		Results: []json.RawMessage{
+			json.RawMessage(`{"classification": {"idle": 0.2, "wave": 0.8}}`),
+		},
+	})
+
+	rec := sensorRecorder{make(chan timeseries.Event, 10)}
+	cl, err := timeseries.NewClassifier(context.Background(), runner, rec, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// A window is 10 samples.
+	for i := 0; i < 10; i++ {
+		v := float64(i)
+		rec.events <- timeseries.Event{Sample: timeseries.Sample{Values: []float64{v, v, v}}}
+	}
+	select {
+	case ev := <-cl.Events:
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		if ev.WindowID != 1 || ev.Result.Classification["wave"] != 0.8 || len(ev.Features) != 30 || ev.Features[0] != 0 || ev.Features[29] != 9 {
+			t.Errorf("got event %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for classification")
+	}
+}
+
+func TestClassifierClose(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             3,
+			ImageInputWidth:    4,
+			ImageInputHeight:   4,
+			ImageChannelCount:  3,
+			InputFeaturesCount: 16,
+			Labels:             []string{"a", "b"},
+		},
+	})
+
+	rec := recorder{make(chan image.Event, 3)}
+	cl, err := image.NewClassifier(context.Background(), runner, rec, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without reading Events, the classifier blocks on sending the second
+	// event. Close must not deadlock.
+	for i := 0; i < 3; i++ {
+		rec.events <- image.Event{Image: goimage.NewRGBA(goimage.Rect(0, 0, 4, 4))}
+	}
+	time.Sleep(100 * time.Millisecond)
+	cl.Close()
+	cl.Close()
+	for range cl.Events {
+	}
+}
+
+func TestClassifierHandlers(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             3,
+			ImageInputWidth:    4,
+			ImageInputHeight:   4,
+			ImageChannelCount:  3,
+			InputFeaturesCount: 16,
+			Labels:             []string{"a", "b"},
+		},
+	})
+
+	results := make(chan image.ClassifyEvent, 1)
+	errs := make(chan error, 1)
+	rec := recorder{make(chan image.Event, 2)}
+	cl, err := image.NewClassifier(context.Background(), runner, rec,
+		image.WithOnResult(func(ev image.ClassifyEvent) { results <- ev }),
+		image.WithOnError(func(err error) { errs <- err }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	rec.events <- image.Event{Err: errors.New("test")}
+	rec.events <- image.Event{Image: goimage.NewRGBA(goimage.Rect(0, 0, 4, 4))}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err.Error() != "test" {
+				t.Errorf("got error %v", err)
+			}
+		case ev := <-results:
+			if ev.FrameID != 1 {
+				t.Errorf("got event %+v", ev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for handlers")
+		}
+	}
+}
+
+func TestReadModelInfo(t *testing.T) {
+	model := runnertest.Build(t)
+	dir := t.TempDir()
+	config := runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{Sensor: 1, Frequency: 16000, Labels: []string{"noise", "yes"}},
+		Project:         edgeimpulse.Project{Name: "test"},
+	}
+	if err := runnertest.WriteConfig(dir, config); err != nil {
+		t.Fatal(err)
+	}
+	opts := &edgeimpulse.ModelInfoOpts{
+		CacheDir:   t.TempDir(),
+		RunnerOpts: edgeimpulse.RunnerOpts{WorkDir: dir},
+	}
+	check := func() {
+		t.Helper()
+		info, err := edgeimpulse.ReadModelInfo(model, opts)
+		if err != nil {
+			t.Fatalf("reading model info: %v", err)
+		}
+		if info.ModelParameters.SensorType != edgeimpulse.SensorTypeMicrophone || info.Project.Name != "test" {
+			t.Fatalf("got model info %+v", info)
+		}
+	}
+	check()
+
+	// Without config, the fake model fails to start, so info must come from
+	// the cache.
+	if err := os.Remove(filepath.Join(dir, runnertest.ConfigFile)); err != nil {
+		t.Fatal(err)
+	}
+	check()
+
+	opts.Refresh = true
+	if _, err := edgeimpulse.ReadModelInfo(model, opts); err == nil {
+		t.Fatalf("refresh without config succeeded")
+	}
+}
+
+func TestClassifierPanic(t *testing.T) {
+	model := runnertest.Build(t)
+	runner := runnertest.NewRunner(t, model, runnertest.Config{
+		ModelParameters: edgeimpulse.ModelParameters{
+			Sensor:             3,
+			ImageInputWidth:    4,
+			ImageInputHeight:   4,
+			ImageChannelCount:  3,
+			InputFeaturesCount: 16,
+			Labels:             []string{"a", "b"},
+		},
+	})
+
+	rec := recorder{make(chan image.Event, 1)}
+	cl, err := image.NewClassifier(context.Background(), runner, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Close()
+
+	// A nil image makes preprocessing panic.
+	rec.events <- image.Event{}
+	ev, ok := <-cl.Events
+	if !ok || !errors.Is(ev.Err, edgeimpulse.ErrPanic) {
+		t.Fatalf("got event %v, expected ErrPanic", ev.Err)
+	}
+	if _, ok := <-cl.Events; ok {
+		t.Fatalf("events not closed after panic")
+	}
+	if !errors.Is(cl.Err(), edgeimpulse.ErrPanic) {
+		t.Fatalf("got Err %v, expected ErrPanic", cl.Err())
+	}
+}
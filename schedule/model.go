@@ -0,0 +1,106 @@
+package schedule
+
+import (
+	"errors"
+	"sync"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Model is a runner that can be suspended, stopping its model process to save
+// power, and resumed, starting a new process. Classify resumes a suspended
+// model.
+type Model struct {
+	open        func() (edgeimpulse.Runner, error)
+	modelParams edgeimpulse.ModelParameters
+	project     edgeimpulse.Project
+
+	mutex  sync.RWMutex // Held for reading while classifying.
+	runner edgeimpulse.Runner
+	closed bool
+}
+
+var errClosed = errors.New("model closed")
+
+// Ensure that Model implements interface Runner.
+var _ edgeimpulse.Runner = (*Model)(nil)
+
+// NewModel opens a runner with open, e.g. a function calling
+// edgeimpulse.NewRunnerProcess, and returns a model for it. Open is called
+// again to resume the model.
+func NewModel(open func() (edgeimpulse.Runner, error)) (*Model, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return &Model{open: open, modelParams: r.ModelParameters(), project: r.Project(), runner: r}, nil
+}
+
+// ModelParameters returns the parameters of the model, also while suspended.
+func (m *Model) ModelParameters() edgeimpulse.ModelParameters {
+	return m.modelParams
+}
+
+// Project returns the project of the model, also while suspended.
+func (m *Model) Project() edgeimpulse.Project {
+	return m.project
+}
+
+// Classify classifies data, resuming the model if it is suspended.
+func (m *Model) Classify(data []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+	m.mutex.RLock()
+	for m.runner == nil {
+		m.mutex.RUnlock()
+		if err := m.Resume(); err != nil {
+			return edgeimpulse.RunnerClassifyResponse{}, err
+		}
+		m.mutex.RLock()
+	}
+	defer m.mutex.RUnlock()
+	return m.runner.Classify(data)
+}
+
+// Suspend closes the runner, after classifications in progress.
+func (m *Model) Suspend() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.runner == nil {
+		return nil
+	}
+	err := m.runner.Close()
+	m.runner = nil
+	return err
+}
+
+// Resume opens a new runner if the model is suspended.
+func (m *Model) Resume() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.closed {
+		return errClosed
+	}
+	if m.runner != nil {
+		return nil
+	}
+	r, err := m.open()
+	if err != nil {
+		return err
+	}
+	m.runner = r
+	return nil
+}
+
+// Suspended returns whether the model is suspended.
+func (m *Model) Suspended() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.runner == nil
+}
+
+// Close closes the runner. The model cannot be resumed after Close.
+func (m *Model) Close() error {
+	m.mutex.Lock()
+	m.closed = true
+	m.mutex.Unlock()
+	return m.Suspend()
+}
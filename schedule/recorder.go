@@ -0,0 +1,127 @@
+package schedule
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/image"
+)
+
+// ImageRecorder is an image recorder that records only during the windows of
+// a schedule, opening a recorder when a window opens and closing it when the
+// window ends.
+type ImageRecorder struct {
+	scheduler *Scheduler
+	events    chan image.Event
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Check that ImageRecorder implements interface Recorder.
+var _ image.Recorder = (*ImageRecorder)(nil)
+
+// session is a recorder opened for a window, forwarding its events.
+type session struct {
+	recorder image.Recorder
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func (s *session) Close() error {
+	s.cancel()
+	<-s.done
+	return s.recorder.Close()
+}
+
+// NewImageRecorder returns a recorder that calls open at the start of each
+// window of schedule, e.g. with a function calling the NewRecorder of an
+// image.Backend, and sends its images on Events until the end of the window.
+//
+// If open fails, an event with the error is sent, and no more images are
+// recorded. Callers must call Close to clean up.
+func NewImageRecorder(ctx context.Context, schedule Schedule, open func(ctx context.Context) (image.Recorder, error), opts ...Option) *ImageRecorder {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &ImageRecorder{
+		events: make(chan image.Event),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	r.scheduler = New(schedule, func(sctx context.Context) (io.Closer, error) {
+		rec, err := open(sctx)
+		if err != nil {
+			return nil, err
+		}
+		sctx, scancel := context.WithCancel(sctx)
+		s := &session{rec, scancel, make(chan struct{})}
+		go func() {
+			defer close(s.done)
+			for {
+				select {
+				case <-sctx.Done():
+					return
+				case ev := <-rec.Events():
+					select {
+					case r.events <- ev:
+					case <-sctx.Done():
+						return
+					}
+				}
+			}
+		}()
+		return s, nil
+	}, opts...)
+
+	go func() {
+		defer close(r.done)
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				r.sendErr(ctx, err)
+			}
+		}()
+		if err := r.scheduler.Run(ctx); err != nil {
+			r.sendErr(ctx, err)
+		}
+	}()
+	return r
+}
+
+func (r *ImageRecorder) sendErr(ctx context.Context, err error) {
+	select {
+	case r.events <- image.Event{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// Events returns a channel on which Events can be received.
+func (r *ImageRecorder) Events() chan image.Event {
+	return r.events
+}
+
+// Active returns whether the recorder is in a window of its schedule.
+func (r *ImageRecorder) Active() bool {
+	return r.scheduler.Active()
+}
+
+// Close stops the schedule, closing the recorder of the current window.
+func (r *ImageRecorder) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *ImageRecorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
@@ -0,0 +1,127 @@
+// Package schedule runs classification in duty cycles, for battery and solar
+// powered devices: e.g. 5 seconds on and 25 seconds off, or only during
+// daily windows like 06:00-18:00.
+//
+// A Scheduler starts a session, e.g. a recorder, when a window of its
+// Schedule opens, and closes it when the window ends. NewImageRecorder wraps
+// an image recorder this way, so a classifier reading from it stays in place.
+// With a Model, the model process is stopped during off periods that are long
+// enough, and started again when a window opens.
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schedule determines when classification runs.
+type Schedule interface {
+	// Next returns the window that contains t, or the first window after
+	// t. End is after start.
+	Next(t time.Time) (start, end time.Time)
+}
+
+// DutyCycle is a schedule that is on for On, then off for Off, repeatedly.
+// Cycles are aligned to the Unix epoch, so devices with the same duty cycle
+// run at the same time.
+type DutyCycle struct {
+	On, Off time.Duration
+}
+
+// Next returns the on period containing t, or the next one.
+func (d DutyCycle) Next(t time.Time) (start, end time.Time) {
+	period := d.On + d.Off
+	start = time.Unix(0, 0).Add(t.Sub(time.Unix(0, 0)) / period * period)
+	if !t.Before(start.Add(d.On)) {
+		start = start.Add(period)
+	}
+	return start, start.Add(d.On)
+}
+
+// Window is a daily window, with times since midnight. If End is not after
+// Start, the window ends the next day.
+type Window struct {
+	Start, End time.Duration
+}
+
+// Daily is a schedule that is on during windows each day, in the time zone of
+// the times passed to Next.
+type Daily []Window
+
+// Next returns the window containing t, or the next one.
+func (d Daily) Next(t time.Time) (start, end time.Time) {
+	y, m, day := t.Date()
+	first := true
+	// Windows of yesterday may still be open.
+	for i := -1; i <= 1; i++ {
+		midnight := time.Date(y, m, day+i, 0, 0, 0, 0, t.Location())
+		for _, w := range d {
+			s := midnight.Add(w.Start)
+			e := midnight.Add(w.End)
+			if w.End <= w.Start {
+				e = e.Add(24 * time.Hour)
+			}
+			if !e.After(t) {
+				continue
+			}
+			if first || s.Before(start) {
+				start, end = s, e
+				first = false
+			}
+		}
+	}
+	return start, end
+}
+
+// Always is a schedule that is always on.
+type Always struct{}
+
+// Next returns a window from t that does not end.
+func (Always) Next(t time.Time) (start, end time.Time) {
+	return t, t.Add(1<<63 - 1)
+}
+
+// Parse parses a schedule: a duty cycle like "5s/25s" for 5 seconds on and
+// 25 seconds off, or daily windows like "06:00-09:00,17:00-20:00".
+func Parse(s string) (Schedule, error) {
+	if i := strings.Index(s, "/"); i >= 0 {
+		on, err := time.ParseDuration(s[:i])
+		if err != nil || on <= 0 {
+			return nil, fmt.Errorf("bad on duration in duty cycle %q", s)
+		}
+		off, err := time.ParseDuration(s[i+1:])
+		if err != nil || off < 0 {
+			return nil, fmt.Errorf("bad off duration in duty cycle %q", s)
+		}
+		return DutyCycle{on, off}, nil
+	}
+	var d Daily
+	for _, t := range strings.Split(s, ",") {
+		l := strings.Split(strings.TrimSpace(t), "-")
+		if len(l) != 2 {
+			return nil, fmt.Errorf("bad window %q, must be like 06:00-18:00", t)
+		}
+		var w Window
+		var err error
+		if w.Start, err = parseTimeOfDay(l[0]); err == nil {
+			w.End, err = parseTimeOfDay(l[1])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("window %q: %w", t, err)
+		}
+		d = append(d, w)
+	}
+	sort.Slice(d, func(i, j int) bool { return d[i].Start < d[j].Start })
+	return d, nil
+}
+
+// parseTimeOfDay parses a time like "06:30" as duration since midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q, must be like 06:30", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
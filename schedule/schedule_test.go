@@ -0,0 +1,127 @@
+package schedule_test
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/schedule"
+)
+
+func TestDutyCycle(t *testing.T) {
+	d := schedule.DutyCycle{On: 5 * time.Second, Off: 25 * time.Second}
+	t0 := time.Unix(900, 0) // Multiple of 30s.
+
+	check := func(t1 time.Time, expStart time.Time) {
+		t.Helper()
+		start, end := d.Next(t1)
+		if !start.Equal(expStart) || !end.Equal(expStart.Add(5*time.Second)) {
+			t.Errorf("next of %v: got %v-%v, expected start %v", t1.Sub(t0), start.Sub(t0), end.Sub(t0), expStart.Sub(t0))
+		}
+	}
+	check(t0, t0)
+	check(t0.Add(4*time.Second), t0)
+	check(t0.Add(5*time.Second), t0.Add(30*time.Second))
+	check(t0.Add(29*time.Second), t0.Add(30*time.Second))
+}
+
+func TestDaily(t *testing.T) {
+	s, err := schedule.Parse("22:00-02:00,06:00-09:30")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	day := func(d, h, m int) time.Time {
+		return time.Date(2024, 3, d, h, m, 0, 0, time.UTC)
+	}
+	for _, c := range []struct {
+		t, start, end time.Time
+	}{
+		{day(10, 1, 0), day(9, 22, 0), day(10, 2, 0)}, // In window of yesterday.
+		{day(10, 3, 0), day(10, 6, 0), day(10, 9, 30)},
+		{day(10, 7, 0), day(10, 6, 0), day(10, 9, 30)},
+		{day(10, 9, 30), day(10, 22, 0), day(11, 2, 0)},
+	} {
+		start, end := s.Next(c.t)
+		if !start.Equal(c.start) || !end.Equal(c.end) {
+			t.Errorf("next of %v: got %v-%v, expected %v-%v", c.t, start, end, c.start, c.end)
+		}
+	}
+
+	for _, bad := range []string{"6-9", "06:00", "1s/x", "0s/1s"} {
+		if _, err := schedule.Parse(bad); err == nil {
+			t.Errorf("parse %q: expected error", bad)
+		}
+	}
+}
+
+type closerFunc func() error
+
+func (fn closerFunc) Close() error {
+	return fn()
+}
+
+type fakeRunner struct {
+	closed bool
+}
+
+func (r *fakeRunner) ModelParameters() edgeimpulse.ModelParameters {
+	return edgeimpulse.ModelParameters{}
+}
+func (r *fakeRunner) Project() edgeimpulse.Project { return edgeimpulse.Project{} }
+func (r *fakeRunner) Close() error                 { r.closed = true; return nil }
+func (r *fakeRunner) Classify(data []float64) (edgeimpulse.RunnerClassifyResponse, error) {
+	return edgeimpulse.RunnerClassifyResponse{}, nil
+}
+
+func TestScheduler(t *testing.T) {
+	var mutex sync.Mutex
+	var opened []*fakeRunner
+	model, err := schedule.NewModel(func() (edgeimpulse.Runner, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		r := &fakeRunner{}
+		opened = append(opened, r)
+		return r, nil
+	})
+	if err != nil {
+		t.Fatalf("new model: %v", err)
+	}
+
+	var starts, stops int
+	session := func(ctx context.Context) (io.Closer, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		starts++
+		return closerFunc(func() error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			stops++
+			return nil
+		}), nil
+	}
+	s := schedule.New(schedule.DutyCycle{On: 20 * time.Millisecond, Off: 40 * time.Millisecond}, session, schedule.WithModel(model, 30*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if starts < 2 || stops != starts {
+		t.Errorf("got %d starts and %d stops, expected at least 2, and equal", starts, stops)
+	}
+	// The model is suspended in each off period, and resumed for each window.
+	if len(opened) < 2 || !opened[0].closed {
+		t.Errorf("got %d model processes, expected at least 2, with the first closed", len(opened))
+	}
+
+	model.Close()
+	if _, err := model.Classify(nil); err == nil {
+		t.Errorf("classify after close: expected error")
+	}
+}
@@ -0,0 +1,192 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Opts are options for a Scheduler.
+type Opts struct {
+	// If set, the model is suspended during off periods of at least
+	// ModelIdle, and resumed when a window opens.
+	Model     *Model
+	ModelIdle time.Duration
+
+	// If set, called when a session starts, with true, and when it stops,
+	// with false. E.g. for pausing health checks during off periods.
+	OnChange func(active bool)
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For determining windows. Waiting for windows uses timers, so a manual
+	// clock must be advanced along with real time. If nil,
+	// edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a scheduler created with New. A *Opts is also an Option,
+// and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *Opts)
+}
+
+type optionFunc func(o *Opts)
+
+func (fn optionFunc) apply(o *Opts) {
+	fn(o)
+}
+
+func (opts *Opts) apply(o *Opts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithModel sets Opts.Model and Opts.ModelIdle.
+func WithModel(m *Model, idle time.Duration) Option {
+	return optionFunc(func(o *Opts) {
+		o.Model = m
+		o.ModelIdle = idle
+	})
+}
+
+// WithOnChange sets Opts.OnChange.
+func WithOnChange(fn func(active bool)) Option {
+	return optionFunc(func(o *Opts) { o.OnChange = fn })
+}
+
+// WithVerbose sets Opts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *Opts) { o.Verbose = verbose })
+}
+
+// WithLogger sets Opts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *Opts) { o.Logger = logger })
+}
+
+// WithClock sets Opts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *Opts) { o.Clock = clock })
+}
+
+// Scheduler starts and stops sessions according to a schedule.
+type Scheduler struct {
+	schedule Schedule
+	session  func(ctx context.Context) (io.Closer, error)
+	opts     Opts
+	logger   edgeimpulse.Logger
+	clock    edgeimpulse.Clock
+
+	mutex  sync.Mutex
+	active bool
+}
+
+// New returns a scheduler that calls session when a window opens, and closes
+// the returned closer when the window ends. The context passed to session is
+// canceled at the end of the window too.
+func New(schedule Schedule, session func(ctx context.Context) (io.Closer, error), opts ...Option) *Scheduler {
+	s := &Scheduler{schedule: schedule, session: session}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&s.opts)
+		}
+	}
+	s.logger = edgeimpulse.DefaultLogger(s.opts.Logger, s.opts.Verbose)
+	s.clock = edgeimpulse.DefaultClock(s.opts.Clock)
+	return s
+}
+
+// Active returns whether a session is running.
+func (s *Scheduler) Active() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.active
+}
+
+func (s *Scheduler) setActive(active bool) {
+	s.mutex.Lock()
+	s.active = active
+	s.mutex.Unlock()
+	if s.opts.OnChange != nil {
+		s.opts.OnChange(active)
+	}
+}
+
+// Run runs sessions until ctx is canceled, or a session fails to start. Run
+// returns nil when ctx is canceled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		now := s.clock.Now()
+		start, end := s.schedule.Next(now)
+		if start.After(now) {
+			if m := s.opts.Model; m != nil && s.opts.ModelIdle > 0 && start.Sub(now) >= s.opts.ModelIdle && !m.Suspended() {
+				s.logger.Logf(edgeimpulse.LogDebug, "suspending model until %s", start.Format(time.RFC3339))
+				if err := m.Suspend(); err != nil {
+					s.logger.Logf(edgeimpulse.LogError, "suspending model: %v", err)
+				}
+			}
+			if !s.wait(ctx, start.Sub(now)) {
+				return nil
+			}
+		}
+
+		if m := s.opts.Model; m != nil {
+			if err := m.Resume(); err != nil {
+				return fmt.Errorf("resuming model: %w", err)
+			}
+		}
+		s.logger.Logf(edgeimpulse.LogDebug, "starting session until %s", end.Format(time.RFC3339))
+		sctx, cancel := context.WithCancel(ctx)
+		c, err := s.session(sctx)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("starting session: %w", err)
+		}
+		s.setActive(true)
+		// Keep the session while windows follow each other without a gap.
+		for {
+			if !s.wait(ctx, end.Sub(s.clock.Now())) {
+				break
+			}
+			next, nextEnd := s.schedule.Next(end)
+			if next.After(end) {
+				break
+			}
+			end = nextEnd
+		}
+		s.setActive(false)
+		cancel()
+		if err := c.Close(); err != nil {
+			s.logger.Logf(edgeimpulse.LogError, "closing session: %v", err)
+		}
+		s.logger.Logf(edgeimpulse.LogDebug, "stopped session")
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// wait waits for d, returning false if ctx is canceled first.
+func (s *Scheduler) wait(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
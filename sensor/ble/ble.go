@@ -0,0 +1,450 @@
+// Package ble records samples from wireless sensor nodes over Bluetooth Low
+// Energy, by subscribing to notifications of a GATT characteristic. It uses
+// the gatttool command from BlueZ.
+package ble
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+var errInstallHint = fmt.Errorf("gatttool %w, install with: sudo apt install -y bluez", exec.ErrNotFound)
+
+// baseUUID is the Bluetooth base UUID, for expanding 16 and 32 bit UUIDs.
+const baseUUID = "-0000-1000-8000-00805f9b34fb"
+
+// ParseFunc turns the value of a notification into the values of one or more
+// samples, each with a value per axis.
+type ParseFunc func(value []byte) ([][]float64, error)
+
+// ParseFloat32 is the default ParseFunc. It parses a notification as little
+// endian float32 values, as a single sample.
+func ParseFloat32(value []byte) ([][]float64, error) {
+	if len(value)%4 != 0 {
+		return nil, fmt.Errorf("value of %d bytes is not a multiple of 4", len(value))
+	}
+	values := make([]float64, len(value)/4)
+	for i := range values {
+		values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(value[4*i:])))
+	}
+	return [][]float64{values}, nil
+}
+
+// RecorderOpts are options for a Recorder.
+type RecorderOpts struct {
+	// Bluetooth address of the sensor node, e.g. "C0:98:E5:49:00:01".
+	Address string
+
+	// If set, the address is a random address, common for sensor nodes.
+	RandomAddress bool
+
+	// UUID of the service with the characteristic. If empty, the
+	// characteristic is looked up in all services. UUIDs can be in short
+	// form, e.g. "181a".
+	ServiceUUID string
+
+	// UUID of the characteristic to subscribe to.
+	CharacteristicUUID string
+
+	// Names and units of the axes of the samples.
+	Axes []timeseries.Axis
+
+	// Samples per second the sensor node sends, typically the frequency of
+	// the model.
+	Frequency float64
+
+	// Parses notifications into samples. If nil, ParseFloat32 is used.
+	Parse ParseFunc
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For the time of samples. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A *RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts *RecorderOpts) apply(o *RecorderOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithAddress sets RecorderOpts.Address and RecorderOpts.RandomAddress.
+func WithAddress(address string, random bool) Option {
+	return optionFunc(func(o *RecorderOpts) {
+		o.Address = address
+		o.RandomAddress = random
+	})
+}
+
+// WithCharacteristic sets RecorderOpts.ServiceUUID and
+// RecorderOpts.CharacteristicUUID.
+func WithCharacteristic(service, characteristic string) Option {
+	return optionFunc(func(o *RecorderOpts) {
+		o.ServiceUUID = service
+		o.CharacteristicUUID = characteristic
+	})
+}
+
+// WithAxes sets RecorderOpts.Axes.
+func WithAxes(axes ...timeseries.Axis) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Axes = axes })
+}
+
+// WithFrequency sets RecorderOpts.Frequency.
+func WithFrequency(frequency float64) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Frequency = frequency })
+}
+
+// WithParse sets RecorderOpts.Parse.
+func WithParse(fn ParseFunc) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Parse = fn })
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// normalizeUUID returns the full lower case form of a UUID.
+func normalizeUUID(s string) string {
+	s = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(s), "0x"))
+	switch len(s) {
+	case 4:
+		return "0000" + s + baseUUID
+	case 8:
+		return s + baseUUID
+	}
+	return s
+}
+
+// characteristic is a characteristic as listed by gatttool.
+type characteristic struct {
+	handle      uint16 // Declaration handle.
+	valueHandle uint16
+	uuid        string
+}
+
+// parseHandle parses a handle like "0x0025".
+func parseHandle(s string) (uint16, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s), "0x"), 16, 16)
+	return uint16(v), err
+}
+
+// parseFields parses lines like "handle = 0x0002, char properties = 0x02,
+// char value handle = 0x0003, uuid = 00002a00-...", into a map of names to
+// values.
+func parseFields(line string) map[string]string {
+	m := map[string]string{}
+	// Service lines use "uuid: ...", without a comma.
+	line = strings.Replace(line, " uuid: ", ", uuid = ", 1)
+	for _, f := range strings.Split(line, ",") {
+		t := strings.SplitN(f, "=", 2)
+		if len(t) == 2 {
+			m[strings.TrimSpace(t[0])] = strings.TrimSpace(t[1])
+		}
+	}
+	return m
+}
+
+// parseServiceRange returns the handle range of the service with uuid from the
+// output of "gatttool --primary".
+func parseServiceRange(output, uuid string) (start, end uint16, err error) {
+	uuid = normalizeUUID(uuid)
+	for _, line := range strings.Split(output, "\n") {
+		m := parseFields(line)
+		if normalizeUUID(m["uuid"]) != uuid {
+			continue
+		}
+		if start, err = parseHandle(m["attr handle"]); err != nil {
+			return 0, 0, fmt.Errorf("parsing service handle: %w", err)
+		}
+		if end, err = parseHandle(m["end grp handle"]); err != nil {
+			return 0, 0, fmt.Errorf("parsing service end handle: %w", err)
+		}
+		return start, end, nil
+	}
+	return 0, 0, fmt.Errorf("service %s not found", uuid)
+}
+
+// parseCharacteristic returns the characteristic with uuid from the output of
+// "gatttool --characteristics".
+func parseCharacteristic(output, uuid string) (characteristic, error) {
+	uuid = normalizeUUID(uuid)
+	for _, line := range strings.Split(output, "\n") {
+		m := parseFields(line)
+		if normalizeUUID(m["uuid"]) != uuid {
+			continue
+		}
+		var c characteristic
+		var err error
+		c.uuid = uuid
+		if c.handle, err = parseHandle(m["handle"]); err != nil {
+			return characteristic{}, fmt.Errorf("parsing characteristic handle: %w", err)
+		}
+		if c.valueHandle, err = parseHandle(m["char value handle"]); err != nil {
+			return characteristic{}, fmt.Errorf("parsing characteristic value handle: %w", err)
+		}
+		return c, nil
+	}
+	return characteristic{}, fmt.Errorf("characteristic %s not found", uuid)
+}
+
+// parseNotification parses a notification or indication line from gatttool,
+// e.g. "Notification handle = 0x0025 value: 01 02 0a", returning false for
+// other lines.
+func parseNotification(line string) (handle uint16, value []byte, ok bool) {
+	line = strings.TrimSpace(line)
+	var rest string
+	switch {
+	case strings.HasPrefix(line, "Notification handle = "):
+		rest = strings.TrimPrefix(line, "Notification handle = ")
+	case strings.HasPrefix(line, "Indication   handle = "):
+		rest = strings.TrimPrefix(line, "Indication   handle = ")
+	default:
+		return 0, nil, false
+	}
+	t := strings.SplitN(rest, " value: ", 2)
+	if len(t) != 2 {
+		return 0, nil, false
+	}
+	handle, err := parseHandle(t[0])
+	if err != nil {
+		return 0, nil, false
+	}
+	value, err = hex.DecodeString(strings.Join(strings.Fields(t[1]), ""))
+	if err != nil {
+		return 0, nil, false
+	}
+	return handle, value, true
+}
+
+// Recorder records samples from notifications of a BLE characteristic.
+type Recorder struct {
+	opts   RecorderOpts
+	logger edgeimpulse.Logger
+	clock  edgeimpulse.Clock
+	events chan timeseries.Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Ensure that Recorder implements the Recorder interface.
+var _ timeseries.Recorder = (*Recorder)(nil)
+
+// NewRecorder connects to the sensor node, looks up the characteristic, and
+// subscribes to its notifications.
+//
+// Callers must call Close to clean up. Canceling ctx also stops the recorder.
+func NewRecorder(ctx context.Context, opts ...Option) (*Recorder, error) {
+	r := &Recorder{done: make(chan struct{})}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if r.opts.Address == "" || r.opts.CharacteristicUUID == "" {
+		return nil, fmt.Errorf("address and characteristic uuid are required")
+	}
+	if len(r.opts.Axes) == 0 {
+		return nil, fmt.Errorf("no axes")
+	}
+	if r.opts.Frequency <= 0 {
+		return nil, fmt.Errorf("frequency must be > 0")
+	}
+	if r.opts.Parse == nil {
+		r.opts.Parse = ParseFloat32
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
+	r.clock = edgeimpulse.DefaultClock(r.opts.Clock)
+
+	gatttool := func(ctx context.Context, args ...string) *exec.Cmd {
+		xargs := []string{"-b", r.opts.Address}
+		if r.opts.RandomAddress {
+			xargs = append(xargs, "-t", "random")
+		}
+		xargs = append(xargs, args...)
+		r.logger.Logf(edgeimpulse.LogDebug, "running gatttool with args %s", xargs)
+		return exec.CommandContext(ctx, "gatttool", xargs...)
+	}
+	output := func(cmd *exec.Cmd) (string, error) {
+		buf, err := cmd.Output()
+		if errors.Is(err, exec.ErrNotFound) {
+			err = errInstallHint
+		}
+		return string(buf), err
+	}
+
+	args := []string{"--characteristics"}
+	if r.opts.ServiceUUID != "" {
+		out, err := output(gatttool(ctx, "--primary"))
+		if err != nil {
+			return nil, fmt.Errorf("listing services: %w", err)
+		}
+		start, end, err := parseServiceRange(out, r.opts.ServiceUUID)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-s", fmt.Sprintf("0x%04x", start), "-e", fmt.Sprintf("0x%04x", end))
+	}
+	out, err := output(gatttool(ctx, args...))
+	if err != nil {
+		return nil, fmt.Errorf("listing characteristics: %w", err)
+	}
+	char, err := parseCharacteristic(out, r.opts.CharacteristicUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+
+	// Enable notifications by writing to the client characteristic
+	// configuration descriptor, which follows the value.
+	cmd := gatttool(ctx, "--char-write-req", fmt.Sprintf("--handle=0x%04x", char.valueHandle+1), "--value=0100", "--listen")
+	if r.opts.Verbose {
+		cmd.Stderr = os.Stderr
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("stdout pipe for gatttool: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		if errors.Is(err, exec.ErrNotFound) {
+			err = errInstallHint
+		}
+		return nil, fmt.Errorf("starting command gatttool: %w", err)
+	}
+
+	// Room for a second of samples, so a consumer that is briefly busy
+	// does not cause gaps.
+	r.events = make(chan timeseries.Event, int(r.opts.Frequency)+1)
+
+	go func() {
+		defer close(r.done)
+		defer cmd.Wait()
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				r.sendErr(ctx, err)
+			}
+		}()
+
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			handle, value, ok := parseNotification(scanner.Text())
+			if !ok || handle != char.valueHandle {
+				r.logger.Logf(edgeimpulse.LogDebug, "gatttool: %s", scanner.Text())
+				continue
+			}
+			samples, err := r.opts.Parse(value)
+			if err != nil {
+				r.logger.Logf(edgeimpulse.LogDebug, "parsing notification %x: %v", value, err)
+				continue
+			}
+			now := r.clock.Now()
+			for _, values := range samples {
+				if len(values) != len(r.opts.Axes) {
+					r.logger.Logf(edgeimpulse.LogDebug, "skipping sample with %d values, expected %d", len(values), len(r.opts.Axes))
+					continue
+				}
+				metrics.FramesCaptured.Inc()
+				select {
+				case r.events <- timeseries.Event{Sample: timeseries.Sample{Time: now, Values: values}}:
+				default:
+					metrics.FramesDropped.Inc()
+					r.logger.Logf(edgeimpulse.LogDebug, "dropping sample, consumer still busy")
+				}
+			}
+		}
+		if ctx.Err() == nil {
+			r.sendErr(ctx, fmt.Errorf("gatttool stopped, sensor node disconnected?"))
+		}
+	}()
+
+	return r, nil
+}
+
+func (r *Recorder) sendErr(ctx context.Context, err error) {
+	select {
+	case r.events <- timeseries.Event{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// Axes returns the axes of the samples.
+func (r *Recorder) Axes() []timeseries.Axis {
+	return r.opts.Axes
+}
+
+// Frequency returns the configured number of samples per second.
+func (r *Recorder) Frequency() float64 {
+	return r.opts.Frequency
+}
+
+// Events returns the channel on which samples are sent.
+func (r *Recorder) Events() chan timeseries.Event {
+	return r.events
+}
+
+// Close stops recording, disconnecting from the sensor node.
+func (r *Recorder) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
@@ -0,0 +1,38 @@
+package ble
+
+import (
+	"testing"
+)
+
+func TestParseGatttool(t *testing.T) {
+	const primary = `attr handle = 0x0001, end grp handle = 0x0007 uuid: 00001800-0000-1000-8000-00805f9b34fb
+attr handle = 0x0020, end grp handle = 0x0030 uuid: 0000181a-0000-1000-8000-00805f9b34fb
+`
+	start, end, err := parseServiceRange(primary, "181A")
+	if err != nil || start != 0x20 || end != 0x30 {
+		t.Fatalf("got service range %#x-%#x, err %v", start, end, err)
+	}
+
+	const chars = `handle = 0x0021, char properties = 0x12, char value handle = 0x0022, uuid = 00002a6e-0000-1000-8000-00805f9b34fb
+handle = 0x0024, char properties = 0x12, char value handle = 0x0025, uuid = 00002a6f-0000-1000-8000-00805f9b34fb
+`
+	c, err := parseCharacteristic(chars, "0x2a6f")
+	if err != nil || c.valueHandle != 0x25 {
+		t.Fatalf("got characteristic %+v, err %v", c, err)
+	}
+	if _, err := parseCharacteristic(chars, "2a00"); err == nil {
+		t.Errorf("expected error for missing characteristic")
+	}
+
+	handle, value, ok := parseNotification("Notification handle = 0x0025 value: 00 00 80 3f ")
+	if !ok || handle != 0x25 || len(value) != 4 {
+		t.Fatalf("got handle %#x, value %x, ok %v", handle, value, ok)
+	}
+	samples, err := ParseFloat32(value)
+	if err != nil || len(samples) != 1 || samples[0][0] != 1 {
+		t.Errorf("got samples %v, err %v", samples, err)
+	}
+	if _, _, ok := parseNotification("Characteristic value was written successfully"); ok {
+		t.Errorf("parsed non-notification")
+	}
+}
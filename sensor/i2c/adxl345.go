@@ -0,0 +1,64 @@
+package i2c
+
+import (
+	"encoding/binary"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// ADXL345 registers.
+const (
+	adxl345DevID      = 0x00
+	adxl345BWRate     = 0x2c
+	adxl345PowerCtl   = 0x2d
+	adxl345DataFormat = 0x31
+	adxl345Data       = 0x32
+)
+
+// ADXL345Addr is the default address of an ADXL345, 0x1d with SDO high.
+const ADXL345Addr = 0x53
+
+// standardGravity is 1g in m/s2.
+const standardGravity = 9.80665
+
+// ADXL345 is a 3-axis accelerometer, configured for a range of ±16g at full
+// resolution, sampling at 100Hz.
+type ADXL345 struct {
+	conn Conn
+}
+
+// NewADXL345 checks the chip id and starts measuring.
+func NewADXL345(conn Conn) (*ADXL345, error) {
+	if err := checkID(conn, "adxl345", adxl345DevID, 0xe5); err != nil {
+		return nil, err
+	}
+	for _, w := range [][2]byte{
+		{adxl345BWRate, 0x0a},     // 100Hz.
+		{adxl345DataFormat, 0x0b}, // Full resolution, ±16g.
+		{adxl345PowerCtl, 0x08},   // Measure.
+	} {
+		if err := conn.WriteReg(w[0], w[1]); err != nil {
+			return nil, err
+		}
+	}
+	return &ADXL345{conn}, nil
+}
+
+// Axes returns accX, accY and accZ.
+func (s *ADXL345) Axes() []timeseries.Axis {
+	return []timeseries.Axis{{Name: "accX", Units: "m/s2"}, {Name: "accY", Units: "m/s2"}, {Name: "accZ", Units: "m/s2"}}
+}
+
+// Read returns the acceleration in m/s2.
+func (s *ADXL345) Read() ([]float64, error) {
+	var buf [6]byte
+	if err := s.conn.ReadReg(adxl345Data, buf[:]); err != nil {
+		return nil, err
+	}
+	values := make([]float64, 3)
+	for i := range values {
+		// 3.9mg per LSB at full resolution.
+		values[i] = float64(int16(binary.LittleEndian.Uint16(buf[2*i:]))) * 0.0039 * standardGravity
+	}
+	return values, nil
+}
@@ -0,0 +1,140 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// BME280 registers.
+const (
+	bme280Calib1   = 0x88
+	bme280ChipID   = 0xd0
+	bme280Calib2   = 0xe1
+	bme280CtrlHum  = 0xf2
+	bme280CtrlMeas = 0xf4
+	bme280Config   = 0xf5
+	bme280Data     = 0xf7
+)
+
+// BMEAddr is the default address of a BME280 or BME680, 0x77 with SDO high.
+const BMEAddr = 0x76
+
+// environmentAxes are the axes of the BME sensors.
+var environmentAxes = []timeseries.Axis{
+	{Name: "temperature", Units: "degC"},
+	{Name: "pressure", Units: "hPa"},
+	{Name: "humidity", Units: "%"},
+}
+
+// BME280 is a temperature, pressure and humidity sensor, configured for
+// continuous measurement without oversampling or filtering.
+type BME280 struct {
+	conn Conn
+
+	// Calibration.
+	t1                             uint16
+	t2, t3                         int16
+	p1                             uint16
+	p2, p3, p4, p5, p6, p7, p8, p9 int16
+	h1, h3                         uint8
+	h2, h4, h5                     int16
+	h6                             int8
+}
+
+// NewBME280 checks the chip id, reads the calibration, and starts measuring.
+func NewBME280(conn Conn) (*BME280, error) {
+	if err := checkID(conn, "bme280", bme280ChipID, 0x60); err != nil {
+		return nil, err
+	}
+	var c1 [26]byte
+	var c2 [7]byte
+	if err := conn.ReadReg(bme280Calib1, c1[:]); err != nil {
+		return nil, fmt.Errorf("reading calibration: %w", err)
+	}
+	if err := conn.ReadReg(bme280Calib2, c2[:]); err != nil {
+		return nil, fmt.Errorf("reading calibration: %w", err)
+	}
+	s := &BME280{conn: conn}
+	u16 := func(i int) uint16 { return binary.LittleEndian.Uint16(c1[i:]) }
+	s.t1 = u16(0)
+	s.t2 = int16(u16(2))
+	s.t3 = int16(u16(4))
+	s.p1 = u16(6)
+	for i, p := range []*int16{&s.p2, &s.p3, &s.p4, &s.p5, &s.p6, &s.p7, &s.p8, &s.p9} {
+		*p = int16(u16(8 + 2*i))
+	}
+	s.h1 = c1[25]
+	s.h2 = int16(binary.LittleEndian.Uint16(c2[0:]))
+	s.h3 = c2[2]
+	s.h4 = int16(int8(c2[3]))<<4 | int16(c2[4]&0x0f)
+	s.h5 = int16(int8(c2[5]))<<4 | int16(c2[4]>>4)
+	s.h6 = int8(c2[6])
+
+	for _, w := range [][2]byte{
+		{bme280CtrlHum, 0x01},  // Humidity oversampling x1. Applied on write of ctrl_meas.
+		{bme280Config, 0x00},   // Standby 0.5ms, no filter.
+		{bme280CtrlMeas, 0x27}, // Temperature and pressure oversampling x1, normal mode.
+	} {
+		if err := conn.WriteReg(w[0], w[1]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Axes returns temperature, pressure and humidity.
+func (s *BME280) Axes() []timeseries.Axis {
+	return environmentAxes
+}
+
+// Read returns the temperature in °C, pressure in hPa and relative humidity in
+// %, compensated with the formulas from the datasheet.
+func (s *BME280) Read() ([]float64, error) {
+	var buf [8]byte
+	if err := s.conn.ReadReg(bme280Data, buf[:]); err != nil {
+		return nil, err
+	}
+	adcP := float64(uint32(buf[0])<<12 | uint32(buf[1])<<4 | uint32(buf[2])>>4)
+	adcT := float64(uint32(buf[3])<<12 | uint32(buf[4])<<4 | uint32(buf[5])>>4)
+	adcH := float64(uint32(buf[6])<<8 | uint32(buf[7]))
+
+	t1, t2, t3 := float64(s.t1), float64(s.t2), float64(s.t3)
+	v1 := (adcT/16384 - t1/1024) * t2
+	v2 := (adcT/131072 - t1/8192) * (adcT/131072 - t1/8192) * t3
+	tFine := v1 + v2
+	temperature := tFine / 5120
+
+	var pressure float64
+	v1 = tFine/2 - 64000
+	v2 = v1 * v1 * float64(s.p6) / 32768
+	v2 += v1 * float64(s.p5) * 2
+	v2 = v2/4 + float64(s.p4)*65536
+	v1 = (float64(s.p3)*v1*v1/524288 + float64(s.p2)*v1) / 524288
+	v1 = (1 + v1/32768) * float64(s.p1)
+	if v1 != 0 {
+		p := 1048576 - adcP
+		p = (p - v2/4096) * 6250 / v1
+		v1 = float64(s.p9) * p * p / 2147483648
+		v2 = p * float64(s.p8) / 32768
+		pressure = (p + (v1+v2+float64(s.p7))/16) / 100
+	}
+
+	h := tFine - 76800
+	h = (adcH - (float64(s.h4)*64 + float64(s.h5)/16384*h)) * (float64(s.h2) / 65536 * (1 + float64(s.h6)/67108864*h*(1+float64(s.h3)/67108864*h)))
+	h *= 1 - float64(s.h1)*h/524288
+	humidity := clamp(h, 0, 100)
+
+	return []float64{temperature, pressure, humidity}, nil
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
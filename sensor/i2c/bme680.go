@@ -0,0 +1,154 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// BME680 registers.
+const (
+	bme680MeasStatus = 0x1d
+	bme680Data       = 0x1f
+	bme680CtrlGas1   = 0x71
+	bme680CtrlHum    = 0x72
+	bme680CtrlMeas   = 0x74
+	bme680Config     = 0x75
+	bme680Calib1     = 0x89
+	bme680ChipID     = 0xd0
+	bme680Calib2     = 0xe1
+)
+
+// BME680 is a temperature, pressure, humidity and gas sensor. Only
+// temperature, pressure and humidity are read; the gas heater is off. Each
+// Read triggers a measurement, which takes about 10ms.
+type BME680 struct {
+	conn Conn
+
+	// Calibration.
+	t1                 uint16
+	t2                 int16
+	t3                 int8
+	p1                 uint16
+	p2, p4, p5, p8, p9 int16
+	p3, p6, p7         int8
+	p10                uint8
+	h1, h2             uint16
+	h3, h4, h5, h7     int8
+	h6                 uint8
+}
+
+// NewBME680 checks the chip id, and reads the calibration.
+func NewBME680(conn Conn) (*BME680, error) {
+	if err := checkID(conn, "bme680", bme680ChipID, 0x61); err != nil {
+		return nil, err
+	}
+	c := make([]byte, 25+16)
+	if err := conn.ReadReg(bme680Calib1, c[:25]); err != nil {
+		return nil, fmt.Errorf("reading calibration: %w", err)
+	}
+	if err := conn.ReadReg(bme680Calib2, c[25:]); err != nil {
+		return nil, fmt.Errorf("reading calibration: %w", err)
+	}
+	u16 := func(i int) uint16 { return binary.LittleEndian.Uint16(c[i:]) }
+	s := &BME680{conn: conn}
+	s.t1 = u16(33)
+	s.t2 = int16(u16(1))
+	s.t3 = int8(c[3])
+	s.p1 = u16(5)
+	s.p2 = int16(u16(7))
+	s.p3 = int8(c[9])
+	s.p4 = int16(u16(11))
+	s.p5 = int16(u16(13))
+	s.p6 = int8(c[16])
+	s.p7 = int8(c[15])
+	s.p8 = int16(u16(19))
+	s.p9 = int16(u16(21))
+	s.p10 = c[23]
+	s.h1 = uint16(c[27])<<4 | uint16(c[26]&0x0f)
+	s.h2 = uint16(c[25])<<4 | uint16(c[26]>>4)
+	s.h3 = int8(c[28])
+	s.h4 = int8(c[29])
+	s.h5 = int8(c[30])
+	s.h6 = c[31]
+	s.h7 = int8(c[32])
+
+	for _, w := range [][2]byte{
+		{bme680CtrlHum, 0x01},  // Humidity oversampling x1.
+		{bme680Config, 0x00},   // No filter.
+		{bme680CtrlGas1, 0x00}, // No gas measurement.
+	} {
+		if err := conn.WriteReg(w[0], w[1]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Axes returns temperature, pressure and humidity.
+func (s *BME680) Axes() []timeseries.Axis {
+	return environmentAxes
+}
+
+// Read measures the temperature in °C, pressure in hPa and relative humidity
+// in %, compensated with the formulas from the datasheet.
+func (s *BME680) Read() ([]float64, error) {
+	// Temperature and pressure oversampling x1, forced mode.
+	if err := s.conn.WriteReg(bme680CtrlMeas, 0x25); err != nil {
+		return nil, err
+	}
+	var status [1]byte
+	for i := 0; ; i++ {
+		if i == 20 {
+			return nil, fmt.Errorf("timeout waiting for measurement")
+		}
+		time.Sleep(5 * time.Millisecond)
+		if err := s.conn.ReadReg(bme680MeasStatus, status[:]); err != nil {
+			return nil, err
+		}
+		// New data, and no longer measuring.
+		if status[0]&0x80 != 0 && status[0]&0x20 == 0 {
+			break
+		}
+	}
+
+	var buf [8]byte
+	if err := s.conn.ReadReg(bme680Data, buf[:]); err != nil {
+		return nil, err
+	}
+	adcP := float64(uint32(buf[0])<<12 | uint32(buf[1])<<4 | uint32(buf[2])>>4)
+	adcT := float64(uint32(buf[3])<<12 | uint32(buf[4])<<4 | uint32(buf[5])>>4)
+	adcH := float64(uint32(buf[6])<<8 | uint32(buf[7]))
+
+	t1 := float64(s.t1)
+	v1 := (adcT/16384 - t1/1024) * float64(s.t2)
+	v2 := (adcT/131072 - t1/8192) * (adcT/131072 - t1/8192) * float64(s.t3) * 16
+	tFine := v1 + v2
+	temperature := tFine / 5120
+
+	var pressure float64
+	v1 = tFine/2 - 64000
+	v2 = v1 * v1 * float64(s.p6) / 131072
+	v2 += v1 * float64(s.p5) * 2
+	v2 = v2/4 + float64(s.p4)*65536
+	v1 = (float64(s.p3)*v1*v1/16384 + float64(s.p2)*v1) / 524288
+	v1 = (1 + v1/32768) * float64(s.p1)
+	if v1 != 0 {
+		p := 1048576 - adcP
+		p = (p - v2/4096) * 6250 / v1
+		v1 = float64(s.p9) * p * p / 2147483648
+		v2 = p * float64(s.p8) / 32768
+		v3 := (p / 256) * (p / 256) * (p / 256) * float64(s.p10) / 131072
+		pressure = (p + (v1+v2+v3+float64(s.p7)*128)/16) / 100
+	}
+
+	v1 = adcH - (float64(s.h1)*16 + float64(s.h3)/2*temperature)
+	v2 = v1 * (float64(s.h2) / 262144 * (1 + float64(s.h4)/16384*temperature + float64(s.h5)/1048576*temperature*temperature))
+	v3 := float64(s.h6) / 16384
+	v4 := float64(s.h7) / 2097152
+	humidity := clamp(v2+(v3+v4*temperature)*v2*v2, 0, 100)
+
+	return []float64{temperature, pressure, humidity}, nil
+}
@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package i2c
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ioctl to set the address of the device for subsequent reads and writes.
+const i2cSlave = 0x0703
+
+// Device is a device on an I2C bus, accessed through i2c-dev.
+type Device struct {
+	f *os.File
+}
+
+// Open opens the device at addr on bus, e.g. 1 for /dev/i2c-1.
+func Open(bus int, addr uint16) (*Device, error) {
+	f, err := os.OpenFile(fmt.Sprintf("/dev/i2c-%d", bus), os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), i2cSlave, uintptr(addr)); errno != 0 {
+		f.Close()
+		return nil, fmt.Errorf("setting i2c address 0x%02x: %w", addr, errno)
+	}
+	return &Device{f}, nil
+}
+
+// ReadReg reads len(buf) bytes starting at register reg.
+func (d *Device) ReadReg(reg byte, buf []byte) error {
+	if _, err := d.f.Write([]byte{reg}); err != nil {
+		return err
+	}
+	n, err := d.f.Read(buf)
+	if err == nil && n != len(buf) {
+		err = fmt.Errorf("short read, %d of %d bytes", n, len(buf))
+	}
+	return err
+}
+
+// WriteReg writes value to register reg.
+func (d *Device) WriteReg(reg, value byte) error {
+	_, err := d.f.Write([]byte{reg, value})
+	return err
+}
+
+// Close closes the device.
+func (d *Device) Close() error {
+	return d.f.Close()
+}
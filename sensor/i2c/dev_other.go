@@ -0,0 +1,33 @@
+//go:build !linux
+// +build !linux
+
+package i2c
+
+import (
+	"errors"
+)
+
+var errUnsupported = errors.New("i2c is only supported on linux")
+
+// Device is a device on an I2C bus, accessed through i2c-dev.
+type Device struct{}
+
+// Open opens the device at addr on bus, e.g. 1 for /dev/i2c-1.
+func Open(bus int, addr uint16) (*Device, error) {
+	return nil, errUnsupported
+}
+
+// ReadReg reads len(buf) bytes starting at register reg.
+func (d *Device) ReadReg(reg byte, buf []byte) error {
+	return errUnsupported
+}
+
+// WriteReg writes value to register reg.
+func (d *Device) WriteReg(reg, value byte) error {
+	return errUnsupported
+}
+
+// Close closes the device.
+func (d *Device) Close() error {
+	return errUnsupported
+}
@@ -0,0 +1,224 @@
+// Package i2c reads common hobbyist sensors on an I2C bus: the BME280 and
+// BME680 environmental sensors, the ADXL345 accelerometer, and the MPU6050
+// accelerometer and gyroscope. A Recorder polls a sensor at a fixed frequency,
+// for classification with the time-series classifier, or for uploading with
+// package ingest.
+//
+// Devices are accessed through the Linux i2c-dev interface, /dev/i2c-*. Load
+// the module with "sudo modprobe i2c-dev" if the files do not exist.
+package i2c
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// Conn is a connection to a device on an I2C bus.
+type Conn interface {
+	// ReadReg reads len(buf) bytes starting at register reg.
+	ReadReg(reg byte, buf []byte) error
+
+	// WriteReg writes value to register reg.
+	WriteReg(reg, value byte) error
+
+	// Close closes the connection.
+	Close() error
+}
+
+// Sensor is a sensor on an I2C bus.
+type Sensor interface {
+	// Axes returns the axes of the values of Read.
+	Axes() []timeseries.Axis
+
+	// Read returns the current values, one per axis.
+	Read() ([]float64, error)
+}
+
+// checkID reads the chip id register, returning an error if it does not have
+// one of the expected values.
+func checkID(conn Conn, name string, reg byte, ids ...byte) error {
+	var id [1]byte
+	if err := conn.ReadReg(reg, id[:]); err != nil {
+		return fmt.Errorf("reading %s chip id: %w", name, err)
+	}
+	for _, x := range ids {
+		if id[0] == x {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected chip id 0x%02x for %s, wrong address?", id[0], name)
+}
+
+// RecorderOpts are options for a Recorder.
+type RecorderOpts struct {
+	// Samples per second, typically the frequency of the model. Defaults to
+	// 100.
+	Frequency float64
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For the time of samples and polling. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A *RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts *RecorderOpts) apply(o *RecorderOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithFrequency sets RecorderOpts.Frequency.
+func WithFrequency(frequency float64) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Frequency = frequency })
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// Recorder polls a sensor at a fixed frequency.
+type Recorder struct {
+	opts   RecorderOpts
+	sensor Sensor
+	logger edgeimpulse.Logger
+	clock  edgeimpulse.Clock
+	events chan timeseries.Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Ensure that Recorder implements the Recorder interface.
+var _ timeseries.Recorder = (*Recorder)(nil)
+
+// NewRecorder starts polling sensor.
+//
+// Callers must call Close to clean up. Close does not close the connection of
+// the sensor. Canceling ctx also stops the recorder.
+func NewRecorder(ctx context.Context, sensor Sensor, opts ...Option) (*Recorder, error) {
+	r := &Recorder{sensor: sensor, done: make(chan struct{})}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if r.opts.Frequency <= 0 {
+		r.opts.Frequency = 100
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
+	r.clock = edgeimpulse.DefaultClock(r.opts.Clock)
+
+	// Room for a second of samples, so a consumer that is briefly busy
+	// does not cause gaps.
+	r.events = make(chan timeseries.Event, int(r.opts.Frequency)+1)
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+
+	go func() {
+		defer close(r.done)
+		sendErr := func(err error) {
+			select {
+			case r.events <- timeseries.Event{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				sendErr(err)
+			}
+		}()
+
+		ticker := r.clock.NewTicker(time.Duration(float64(time.Second) / r.opts.Frequency))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+			values, err := r.sensor.Read()
+			if err != nil {
+				sendErr(fmt.Errorf("reading sensor: %w", err))
+				return
+			}
+			metrics.FramesCaptured.Inc()
+			select {
+			case r.events <- timeseries.Event{Sample: timeseries.Sample{Time: r.clock.Now(), Values: values}}:
+			default:
+				metrics.FramesDropped.Inc()
+				r.logger.Logf(edgeimpulse.LogDebug, "dropping sample, consumer still busy")
+			}
+		}
+	}()
+
+	return r, nil
+}
+
+// Axes returns the axes of the sensor.
+func (r *Recorder) Axes() []timeseries.Axis {
+	return r.sensor.Axes()
+}
+
+// Frequency returns the number of samples per second.
+func (r *Recorder) Frequency() float64 {
+	return r.opts.Frequency
+}
+
+// Events returns the channel on which samples are sent.
+func (r *Recorder) Events() chan timeseries.Event {
+	return r.events
+}
+
+// Close stops polling.
+func (r *Recorder) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
@@ -0,0 +1,103 @@
+package i2c_test
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/sensor/i2c"
+)
+
+// fakeConn is a device with 256 registers.
+type fakeConn struct {
+	regs [256]byte
+}
+
+func (c *fakeConn) ReadReg(reg byte, buf []byte) error {
+	copy(buf, c.regs[reg:])
+	return nil
+}
+
+func (c *fakeConn) WriteReg(reg, value byte) error {
+	c.regs[reg] = value
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func near(a, b, epsilon float64) bool {
+	return math.Abs(a-b) < epsilon
+}
+
+func TestADXL345(t *testing.T) {
+	c := &fakeConn{}
+	c.regs[0x00] = 0xe5
+	// 1g on z, 256 LSB at full resolution.
+	binary.LittleEndian.PutUint16(c.regs[0x36:], 256)
+	s, err := i2c.NewADXL345(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.regs[0x2d] != 0x08 {
+		t.Errorf("not measuring")
+	}
+	v, err := s.Read()
+	if err != nil || len(v) != 3 || v[0] != 0 || !near(v[2], 9.79, 0.01) {
+		t.Errorf("got values %v, err %v", v, err)
+	}
+
+	c.regs[0x00] = 0
+	if _, err := i2c.NewADXL345(c); err == nil {
+		t.Errorf("expected error for wrong chip id")
+	}
+}
+
+func TestMPU6050(t *testing.T) {
+	c := &fakeConn{}
+	c.regs[0x75] = 0x68
+	c.regs[0x6b] = 0x40 // Sleeping.
+	v := -16384
+	binary.BigEndian.PutUint16(c.regs[0x3b:], uint16(int16(v)))
+	binary.BigEndian.PutUint16(c.regs[0x47:], 131*180)
+	s, err := i2c.NewMPU6050(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.regs[0x6b] != 0 {
+		t.Errorf("not woken up")
+	}
+	values, err := s.Read()
+	if err != nil || len(values) != 6 || !near(values[0], -9.81, 0.01) || !near(values[5], math.Pi, 0.001) {
+		t.Errorf("got values %v, err %v", values, err)
+	}
+}
+
+func TestBME280(t *testing.T) {
+	// Calibration and readings from the example in the BMP280 datasheet,
+	// which has the same compensation for temperature and pressure.
+	c := &fakeConn{}
+	c.regs[0xd0] = 0x60
+	for i, v := range []int{27504, 26435, -1000, 36477, -10685, 3024, 2855, 140, -7, 15500, -14600, 6000} {
+		binary.LittleEndian.PutUint16(c.regs[0x88+2*i:], uint16(int16(v)))
+	}
+	put20 := func(reg int, v uint32) {
+		c.regs[reg] = byte(v >> 12)
+		c.regs[reg+1] = byte(v >> 4)
+		c.regs[reg+2] = byte(v << 4)
+	}
+	put20(0xf7, 415148)
+	put20(0xfa, 519888)
+	s, err := i2c.NewBME280(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(v[0], 25.08, 0.01) || !near(v[1], 1006.53, 0.01) {
+		t.Errorf("got temperature %v, pressure %v", v[0], v[1])
+	}
+}
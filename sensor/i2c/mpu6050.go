@@ -0,0 +1,70 @@
+package i2c
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// MPU6050 registers.
+const (
+	mpu6050GyroConfig  = 0x1b
+	mpu6050AccelConfig = 0x1c
+	mpu6050Data        = 0x3b
+	mpu6050PwrMgmt1    = 0x6b
+	mpu6050WhoAmI      = 0x75
+)
+
+// MPU6050Addr is the default address of an MPU6050, 0x69 with AD0 high.
+const MPU6050Addr = 0x68
+
+// MPU6050 is a 3-axis accelerometer and 3-axis gyroscope, configured for
+// ranges of ±2g and ±250°/s.
+type MPU6050 struct {
+	conn Conn
+}
+
+// NewMPU6050 checks the chip id and wakes up the device.
+func NewMPU6050(conn Conn) (*MPU6050, error) {
+	if err := checkID(conn, "mpu6050", mpu6050WhoAmI, 0x68); err != nil {
+		return nil, err
+	}
+	for _, w := range [][2]byte{
+		{mpu6050PwrMgmt1, 0x00},    // Wake up.
+		{mpu6050AccelConfig, 0x00}, // ±2g.
+		{mpu6050GyroConfig, 0x00},  // ±250°/s.
+	} {
+		if err := conn.WriteReg(w[0], w[1]); err != nil {
+			return nil, err
+		}
+	}
+	return &MPU6050{conn}, nil
+}
+
+// Axes returns accX, accY, accZ, gyrX, gyrY and gyrZ.
+func (s *MPU6050) Axes() []timeseries.Axis {
+	return []timeseries.Axis{
+		{Name: "accX", Units: "m/s2"}, {Name: "accY", Units: "m/s2"}, {Name: "accZ", Units: "m/s2"},
+		{Name: "gyrX", Units: "rad/s"}, {Name: "gyrY", Units: "rad/s"}, {Name: "gyrZ", Units: "rad/s"},
+	}
+}
+
+// Read returns the acceleration in m/s2 and angular velocity in rad/s, like
+// the IIO driver of the MPU6050, see package sensor/iio.
+func (s *MPU6050) Read() ([]float64, error) {
+	// Accelerometer, temperature and gyroscope, each big endian int16.
+	var buf [14]byte
+	if err := s.conn.ReadReg(mpu6050Data, buf[:]); err != nil {
+		return nil, err
+	}
+	raw := func(i int) float64 {
+		return float64(int16(binary.BigEndian.Uint16(buf[2*i:])))
+	}
+	values := make([]float64, 6)
+	for i := 0; i < 3; i++ {
+		values[i] = raw(i) / 16384 * standardGravity
+		values[3+i] = raw(4+i) / 131 * math.Pi / 180
+	}
+	return values, nil
+}
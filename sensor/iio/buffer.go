@@ -0,0 +1,260 @@
+package iio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// scanType is the format of a channel in a scan, as described in the
+// scan_elements/in_*_type files, e.g. "le:s12/16>>4".
+type scanType struct {
+	bigEndian   bool
+	signed      bool
+	bits        int // Significant bits.
+	storageBits int // 8, 16, 32 or 64.
+	shift       int
+}
+
+// parseScanType parses a scan type like "be:s12/16>>4".
+func parseScanType(s string) (scanType, error) {
+	var t scanType
+	bad := func() (scanType, error) {
+		return scanType{}, fmt.Errorf("bad iio scan type %q", s)
+	}
+
+	s = strings.TrimSpace(s)
+	endian, rest, ok := cut(s, ":")
+	if !ok {
+		return bad()
+	}
+	switch endian {
+	case "be":
+		t.bigEndian = true
+	case "le":
+	default:
+		return bad()
+	}
+	if rest == "" {
+		return bad()
+	}
+	switch rest[0] {
+	case 's':
+		t.signed = true
+	case 'u':
+	default:
+		return bad()
+	}
+	rest = rest[1:]
+	bits, rest, ok := cut(rest, "/")
+	if !ok {
+		return bad()
+	}
+	storage, shift, ok := cut(rest, ">>")
+	if !ok {
+		return bad()
+	}
+	var err error
+	if t.bits, err = strconv.Atoi(bits); err != nil {
+		return bad()
+	}
+	if t.storageBits, err = strconv.Atoi(storage); err != nil {
+		return bad()
+	}
+	if t.shift, err = strconv.Atoi(shift); err != nil {
+		return bad()
+	}
+	switch t.storageBits {
+	case 8, 16, 32, 64:
+	default:
+		return bad()
+	}
+	if t.bits <= 0 || t.bits+t.shift > t.storageBits {
+		return bad()
+	}
+	return t, nil
+}
+
+// cut is strings.Cut, which is not available in Go 1.16.
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// decode returns the raw value of the channel stored in buf.
+func (t scanType) decode(buf []byte) float64 {
+	var order binary.ByteOrder = binary.LittleEndian
+	if t.bigEndian {
+		order = binary.BigEndian
+	}
+	var v uint64
+	switch t.storageBits {
+	case 8:
+		v = uint64(buf[0])
+	case 16:
+		v = uint64(order.Uint16(buf))
+	case 32:
+		v = uint64(order.Uint32(buf))
+	case 64:
+		v = order.Uint64(buf)
+	}
+	v >>= uint(t.shift)
+	if t.bits < 64 {
+		v &= 1<<uint(t.bits) - 1
+	}
+	if t.signed {
+		// Sign-extend.
+		n := uint(64 - t.bits)
+		return float64(int64(v<<n) >> n)
+	}
+	return float64(v)
+}
+
+// scanField is a channel in a scan.
+type scanField struct {
+	typ    scanType
+	index  int // Scan index, determines order in the scan.
+	offset int // In bytes, from start of scan.
+	chanI  int // Index in the recorder's channels.
+}
+
+// scanLayout orders the fields by scan index and sets their offsets, each
+// aligned to its storage size. It returns the size of a scan, which is padded
+// to the largest storage size.
+func scanLayout(fields []scanField) int {
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].index < fields[j].index
+	})
+	size, largest := 0, 1
+	for i := range fields {
+		n := fields[i].typ.storageBits / 8
+		if n > largest {
+			largest = n
+		}
+		size = (size + n - 1) / n * n
+		fields[i].offset = size
+		size += n
+	}
+	return (size + largest - 1) / largest * largest
+}
+
+// buffer reads scans from the buffered character device of an IIO device.
+type buffer struct {
+	dir    string
+	f      *os.File
+	fields []scanField
+	scan   []byte
+	nchans int
+}
+
+// openBuffer enables the scan elements for chans and the buffer of the device
+// in dir, and opens its character device.
+func openBuffer(dir string, chans []channel, frequency float64, trigger string) (rb *buffer, rerr error) {
+	write := func(name, value string) error {
+		return os.WriteFile(filepath.Join(dir, name), []byte(value), 0644)
+	}
+
+	// Disable the buffer, it cannot be configured while enabled.
+	if err := write("buffer/enable", "0"); err != nil {
+		return nil, fmt.Errorf("disabling iio buffer: %w", err)
+	}
+
+	// Only the channels we record should be in scans.
+	enabled, err := filepath.Glob(filepath.Join(dir, "scan_elements", "*_en"))
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range enabled {
+		if err := os.WriteFile(p, []byte("0"), 0644); err != nil {
+			return nil, fmt.Errorf("disabling scan element: %w", err)
+		}
+	}
+
+	b := &buffer{dir: dir, nchans: len(chans)}
+	for i, c := range chans {
+		elem := filepath.Join("scan_elements", "in_"+c.name)
+		if err := write(elem+"_en", "1"); err != nil {
+			return nil, fmt.Errorf("enabling scan element %s: %w", c.name, err)
+		}
+		buf, err := os.ReadFile(filepath.Join(dir, elem+"_index"))
+		if err != nil {
+			return nil, fmt.Errorf("reading scan index of %s: %w", c.name, err)
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(string(buf)))
+		if err != nil {
+			return nil, fmt.Errorf("parsing scan index of %s: %w", c.name, err)
+		}
+		buf, err = os.ReadFile(filepath.Join(dir, elem+"_type"))
+		if err != nil {
+			return nil, fmt.Errorf("reading scan type of %s: %w", c.name, err)
+		}
+		typ, err := parseScanType(string(buf))
+		if err != nil {
+			return nil, err
+		}
+		b.fields = append(b.fields, scanField{typ: typ, index: index, chanI: i})
+	}
+	b.scan = make([]byte, scanLayout(b.fields))
+
+	if trigger != "" {
+		if err := write("trigger/current_trigger", trigger); err != nil {
+			return nil, fmt.Errorf("setting iio trigger: %w", err)
+		}
+	}
+	// Not all devices have a configurable sampling frequency.
+	if _, err := os.Stat(filepath.Join(dir, "sampling_frequency")); err == nil {
+		if err := write("sampling_frequency", strconv.FormatFloat(frequency, 'f', -1, 64)); err != nil {
+			return nil, fmt.Errorf("setting sampling frequency: %w", err)
+		}
+	}
+	if err := write("buffer/enable", "1"); err != nil {
+		return nil, fmt.Errorf("enabling iio buffer: %w", err)
+	}
+	defer func() {
+		if rerr != nil {
+			b.disable()
+		}
+	}()
+
+	// Non-blocking, so the file is added to the runtime poller, and closing it
+	// unblocks a pending read.
+	b.f, err = os.OpenFile(filepath.Join(devDir, filepath.Base(dir)), os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening iio buffer: %w", err)
+	}
+	return b, nil
+}
+
+// read reads a scan, returning the raw values in order of the recorder's
+// channels.
+func (b *buffer) read() ([]float64, error) {
+	if _, err := io.ReadFull(b.f, b.scan); err != nil {
+		return nil, err
+	}
+	raw := make([]float64, b.nchans)
+	for _, f := range b.fields {
+		raw[f.chanI] = f.typ.decode(b.scan[f.offset:])
+	}
+	return raw, nil
+}
+
+// close closes the character device, causing pending reads to fail.
+func (b *buffer) close() {
+	if b.f != nil {
+		b.f.Close()
+	}
+}
+
+// disable disables the buffer of the device.
+func (b *buffer) disable() error {
+	b.close()
+	return os.WriteFile(filepath.Join(b.dir, "buffer/enable"), []byte("0"), 0644)
+}
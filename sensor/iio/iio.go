@@ -0,0 +1,456 @@
+// Package iio records accelerometer, gyroscope and magnetometer data from
+// Linux Industrial I/O (IIO) devices, e.g. an mpu6050, either by polling the
+// raw values in sysfs, or by reading scans from the buffered character device.
+package iio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// devicesDir is where the kernel lists IIO devices.
+var devicesDir = "/sys/bus/iio/devices"
+
+// devDir holds the buffered character devices.
+var devDir = "/dev"
+
+// channelTypes maps IIO channel types to axis names and units.
+var channelTypes = map[string]timeseries.Axis{
+	"accel":   {Name: "acc", Units: "m/s2"},
+	"anglvel": {Name: "gyr", Units: "rad/s"},
+	"magn":    {Name: "mag", Units: "gauss"},
+}
+
+// ErrDeviceNotFound is returned, wrapped, when the requested device does not
+// exist.
+var ErrDeviceNotFound = errors.New("iio device not found")
+
+// Device is an IIO device.
+type Device struct {
+	ID   string // E.g. "iio:device0".
+	Name string // E.g. "mpu6050".
+}
+
+// ListDevices returns the IIO devices of the system.
+func ListDevices() ([]Device, error) {
+	files, err := os.ReadDir(devicesDir)
+	if err != nil {
+		return nil, fmt.Errorf("listing iio devices: %w", err)
+	}
+	var l []Device
+	for _, fi := range files {
+		if !strings.HasPrefix(fi.Name(), "iio:device") {
+			continue
+		}
+		buf, _ := os.ReadFile(filepath.Join(devicesDir, fi.Name(), "name"))
+		l = append(l, Device{ID: fi.Name(), Name: strings.TrimSpace(string(buf))})
+	}
+	return l, nil
+}
+
+// deviceDir returns the sysfs directory for device, which is either a path, a
+// device such as "iio:device0", or the name of a device such as "mpu6050".
+func deviceDir(device string) (string, error) {
+	if strings.Contains(device, "/") {
+		return device, nil
+	}
+	dir := filepath.Join(devicesDir, device)
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+	devs, err := ListDevices()
+	if err != nil {
+		return "", err
+	}
+	for _, d := range devs {
+		if d.Name == device {
+			return filepath.Join(devicesDir, d.ID), nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrDeviceNotFound, device)
+}
+
+// channel is a single axis of an IIO sensor.
+type channel struct {
+	axis   timeseries.Axis
+	name   string // E.g. "accel_x", as used in sysfs file names.
+	path   string // Of the file with the raw value.
+	scale  float64
+	offset float64
+}
+
+// value returns the value for raw in the units of the channel.
+func (c channel) value(raw float64) float64 {
+	return (raw + c.offset) * c.scale
+}
+
+// openChannels returns the x, y and z channels of each of the channel types
+// (e.g. "accel", "anglvel") in device directory dir.
+func openChannels(dir string, types []string) ([]channel, error) {
+	readFloat := func(name string, def float64) (float64, error) {
+		buf, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return def, nil
+			}
+			return 0, err
+		}
+		return strconv.ParseFloat(strings.TrimSpace(string(buf)), 64)
+	}
+
+	var chans []channel
+	for _, t := range types {
+		axis, ok := channelTypes[t]
+		if !ok {
+			return nil, fmt.Errorf("unknown iio channel type %q", t)
+		}
+		for _, a := range []string{"x", "y", "z"} {
+			c := channel{
+				axis: timeseries.Axis{Name: axis.Name + strings.ToUpper(a), Units: axis.Units},
+				name: t + "_" + a,
+				path: filepath.Join(dir, fmt.Sprintf("in_%s_%s_raw", t, a)),
+			}
+			if _, err := os.Stat(c.path); err != nil {
+				return nil, fmt.Errorf("iio channel %s %s: %w", t, a, err)
+			}
+			// Scale and offset can be per axis, or shared for the channel type.
+			shared, err := readFloat(fmt.Sprintf("in_%s_scale", t), 1)
+			if err != nil {
+				return nil, fmt.Errorf("reading scale of %s: %w", t, err)
+			}
+			if c.scale, err = readFloat(fmt.Sprintf("in_%s_%s_scale", t, a), shared); err != nil {
+				return nil, fmt.Errorf("reading scale of %s %s: %w", t, a, err)
+			}
+			shared, err = readFloat(fmt.Sprintf("in_%s_offset", t), 0)
+			if err != nil {
+				return nil, fmt.Errorf("reading offset of %s: %w", t, err)
+			}
+			if c.offset, err = readFloat(fmt.Sprintf("in_%s_%s_offset", t, a), shared); err != nil {
+				return nil, fmt.Errorf("reading offset of %s %s: %w", t, a, err)
+			}
+			chans = append(chans, c)
+		}
+	}
+	return chans, nil
+}
+
+// read returns the current value of the channel in its units.
+func (c channel) read() (float64, error) {
+	buf, err := os.ReadFile(c.path)
+	if err != nil {
+		return 0, err
+	}
+	raw, err := strconv.ParseFloat(strings.TrimSpace(string(buf)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing raw value from %s: %w", c.path, err)
+	}
+	return c.value(raw), nil
+}
+
+// RecorderOpts are options for a Recorder.
+type RecorderOpts struct {
+	// Path of the sysfs directory, a device such as "iio:device0", or the
+	// name of a device such as "mpu6050".
+	DeviceID string
+
+	// Channel types to record: accel, anglvel, magn. Each has an x, y and z
+	// axis. Defaults to accel.
+	Channels []string
+
+	// Samples per second, typically the frequency of the model. Defaults to
+	// 100.
+	Frequency float64
+
+	// If set, read scans from the buffered character device, e.g.
+	// /dev/iio:device0, instead of polling sysfs. Buffered reading gives more
+	// accurate timing, but requires a trigger on most devices.
+	Buffered bool
+
+	// For buffered reading, if set, the name of the trigger to set as the
+	// current trigger of the device.
+	Trigger string
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For the time of samples and polling. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A *RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts *RecorderOpts) apply(o *RecorderOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithDevice sets RecorderOpts.DeviceID.
+func WithDevice(id string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.DeviceID = id })
+}
+
+// WithChannels sets RecorderOpts.Channels.
+func WithChannels(types ...string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Channels = types })
+}
+
+// WithFrequency sets RecorderOpts.Frequency.
+func WithFrequency(frequency float64) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Frequency = frequency })
+}
+
+// WithBuffered sets RecorderOpts.Buffered and RecorderOpts.Trigger.
+func WithBuffered(trigger string) Option {
+	return optionFunc(func(o *RecorderOpts) {
+		o.Buffered = true
+		o.Trigger = trigger
+	})
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// Recorder records samples from an IIO device.
+type Recorder struct {
+	opts   RecorderOpts
+	logger edgeimpulse.Logger
+	clock  edgeimpulse.Clock
+	dir    string
+	chans  []channel
+	events chan timeseries.Event
+	cancel context.CancelFunc
+	done   chan struct{}
+	buffer *buffer // For buffered reading.
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Ensure that Recorder implements the Recorder interface.
+var _ timeseries.Recorder = (*Recorder)(nil)
+
+// NewRecorder opens the IIO device and starts recording.
+//
+// Callers must call Close to clean up. Canceling ctx also stops the recorder.
+func NewRecorder(ctx context.Context, opts ...Option) (recorder *Recorder, rerr error) {
+	r := &Recorder{done: make(chan struct{})}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if len(r.opts.Channels) == 0 {
+		r.opts.Channels = []string{"accel"}
+	}
+	if r.opts.Frequency <= 0 {
+		r.opts.Frequency = 100
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
+	r.clock = edgeimpulse.DefaultClock(r.opts.Clock)
+
+	var err error
+	if r.opts.DeviceID == "" {
+		devs, err := ListDevices()
+		if err != nil {
+			return nil, err
+		}
+		if len(devs) == 0 {
+			return nil, fmt.Errorf("%w: no iio devices", ErrDeviceNotFound)
+		}
+		r.opts.DeviceID = devs[0].ID
+	}
+	r.dir, err = deviceDir(r.opts.DeviceID)
+	if err != nil {
+		return nil, err
+	}
+	r.chans, err = openChannels(r.dir, r.opts.Channels)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if rerr != nil {
+			r.Close()
+		}
+	}()
+
+	if r.opts.Buffered {
+		r.buffer, err = openBuffer(r.dir, r.chans, r.opts.Frequency, r.opts.Trigger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Room for a second of samples, so a consumer that is briefly busy
+	// does not cause gaps.
+	r.events = make(chan timeseries.Event, int(r.opts.Frequency)+1)
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+
+	go func() {
+		defer close(r.done)
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				r.send(ctx, timeseries.Event{Err: err}, true)
+			}
+		}()
+
+		if r.buffer != nil {
+			r.readBuffer(ctx)
+		} else {
+			r.poll(ctx)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		// Canceled, by the caller or by Close. Closing the buffer unblocks
+		// reads.
+		if r.buffer != nil {
+			r.buffer.close()
+		}
+	}()
+
+	return r, nil
+}
+
+// send sends ev, dropping samples if the consumer is not keeping up. Errors
+// are always delivered, unless ctx is canceled.
+func (r *Recorder) send(ctx context.Context, ev timeseries.Event, block bool) {
+	if block {
+		select {
+		case r.events <- ev:
+		case <-ctx.Done():
+		}
+		return
+	}
+	metrics.FramesCaptured.Inc()
+	select {
+	case r.events <- ev:
+	default:
+		metrics.FramesDropped.Inc()
+		r.logger.Logf(edgeimpulse.LogDebug, "dropping sample, consumer still busy")
+	}
+}
+
+// poll reads the raw values from sysfs at the frequency.
+func (r *Recorder) poll(ctx context.Context) {
+	ticker := r.clock.NewTicker(time.Duration(float64(time.Second) / r.opts.Frequency))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+		values := make([]float64, len(r.chans))
+		for i, c := range r.chans {
+			v, err := c.read()
+			if err != nil {
+				r.send(ctx, timeseries.Event{Err: fmt.Errorf("reading %s: %w", c.axis.Name, err)}, true)
+				return
+			}
+			values[i] = v
+		}
+		r.send(ctx, timeseries.Event{Sample: timeseries.Sample{Time: r.clock.Now(), Values: values}}, false)
+	}
+}
+
+// readBuffer reads scans from the buffered character device.
+func (r *Recorder) readBuffer(ctx context.Context) {
+	for {
+		raw, err := r.buffer.read()
+		if err != nil {
+			if ctx.Err() == nil {
+				r.send(ctx, timeseries.Event{Err: fmt.Errorf("reading iio buffer: %w", err)}, true)
+			}
+			return
+		}
+		values := make([]float64, len(r.chans))
+		for i, c := range r.chans {
+			values[i] = c.value(raw[i])
+		}
+		r.send(ctx, timeseries.Event{Sample: timeseries.Sample{Time: r.clock.Now(), Values: values}}, false)
+	}
+}
+
+// Axes returns the axes of the recorded channels, e.g. accX, accY, accZ.
+func (r *Recorder) Axes() []timeseries.Axis {
+	l := make([]timeseries.Axis, len(r.chans))
+	for i, c := range r.chans {
+		l[i] = c.axis
+	}
+	return l
+}
+
+// Frequency returns the number of samples per second.
+func (r *Recorder) Frequency() float64 {
+	return r.opts.Frequency
+}
+
+// Events returns the channel on which samples are sent.
+func (r *Recorder) Events() chan timeseries.Event {
+	return r.events
+}
+
+// Close stops recording, and for buffered reading, disables the buffer.
+func (r *Recorder) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+	if r.buffer != nil {
+		return r.buffer.disable()
+	}
+	return nil
+}
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
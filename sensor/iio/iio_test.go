@@ -0,0 +1,94 @@
+package iio
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanType(t *testing.T) {
+	tests := []struct {
+		spec string
+		buf  []byte
+		exp  float64
+	}{
+		{"le:s16/16>>0", []byte{0xfe, 0xff}, -2},
+		{"be:s16/16>>0", []byte{0xff, 0xfe}, -2},
+		{"le:u16/16>>0", []byte{0xfe, 0xff}, 65534},
+		{"le:s12/16>>4", []byte{0xf0, 0xff}, -1},
+		{"be:u12/16>>4", []byte{0x12, 0x30}, 0x123},
+		{"le:s32/32>>0", []byte{1, 0, 0, 0}, 1},
+		{"le:u8/8>>0", []byte{200}, 200},
+	}
+	for _, test := range tests {
+		typ, err := parseScanType(test.spec)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", test.spec, err)
+		}
+		if v := typ.decode(test.buf); v != test.exp {
+			t.Errorf("decoding %v with %q: got %v, expected %v", test.buf, test.spec, v, test.exp)
+		}
+	}
+
+	for _, spec := range []string{"", "xe:s16/16>>0", "le:x16/16>>0", "le:s16/12>>0", "le:s16/16", "le:s16/24>>0", "le:s12/16>>8"} {
+		if _, err := parseScanType(spec); err == nil {
+			t.Errorf("parsing %q: expected error", spec)
+		}
+	}
+}
+
+func TestScanLayout(t *testing.T) {
+	s16, _ := parseScanType("le:s16/16>>0")
+	s64, _ := parseScanType("le:s64/64>>0")
+	fields := []scanField{
+		{typ: s64, index: 3, chanI: 0},
+		{typ: s16, index: 1, chanI: 1},
+		{typ: s16, index: 0, chanI: 2},
+	}
+	size := scanLayout(fields)
+	if size != 16 {
+		t.Errorf("got size %d, expected 16", size)
+	}
+	for i, exp := range []int{0, 2, 8} {
+		if fields[i].offset != exp {
+			t.Errorf("field %d: got offset %d, expected %d", i, fields[i].offset, exp)
+		}
+	}
+}
+
+func TestPoll(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"in_accel_x_raw":  "10\n",
+		"in_accel_y_raw":  "-20\n",
+		"in_accel_z_raw":  "0\n",
+		"in_accel_scale":  "0.5\n",
+		"in_accel_offset": "2\n",
+	}
+	for name, s := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewRecorder(context.Background(), WithDevice(dir), WithFrequency(1000))
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	defer r.Close()
+
+	if axes := r.Axes(); len(axes) != 3 || axes[0].Name != "accX" || axes[2].Units != "m/s2" {
+		t.Fatalf("unexpected axes %v", axes)
+	}
+	ev := <-r.Events()
+	if ev.Err != nil {
+		t.Fatalf("reading sample: %v", ev.Err)
+	}
+	exp := []float64{6, -9, 1}
+	for i, v := range ev.Sample.Values {
+		if v != exp[i] {
+			t.Fatalf("got values %v, expected %v", ev.Sample.Values, exp)
+		}
+	}
+}
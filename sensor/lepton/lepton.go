@@ -0,0 +1,384 @@
+// Package lepton reads thermal frames from a FLIR Lepton camera module over
+// SPI, with the VoSPI protocol, through the spidev driver on linux.
+//
+// The Lepton must already be powered and running, which it is after power-on
+// with its default settings. For temperatures in degrees Celsius, use a
+// radiometric Lepton (2.5 or 3.5) with TLinear enabled, its default. Other
+// modules produce raw 14-bit counts, use WithRaw for those.
+//
+// Frames are sent as frame.Frame, with shape [rows columns], so they can be
+// turned into images with package image/thermal, or classified as time series.
+package lepton
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/frame"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+)
+
+// VoSPI packets of Leptons without telemetry.
+const (
+	packetSize       = 164
+	packetPixels     = 80
+	segmentPackets   = 60
+	packetsPerRead   = 20 // Stays under the default 4096 byte buffer of spidev.
+	resyncDelay      = 200 * time.Millisecond
+	kelvinOffset     = 273.15
+	defaultFrequency = 9 // Unique frames per second, as limited by export regulations.
+)
+
+// OpenOpts are options for Open.
+type OpenOpts struct {
+	// SPI device, defaults to /dev/spidev0.0.
+	Device string
+
+	// SPI clock in Hz, defaults to 16MHz. Leptons support up to 20MHz.
+	Speed int
+
+	// Lepton version: 2 for 80x60 modules, or 3 for 160x120 modules.
+	// Defaults to 3.
+	Version int
+
+	// If set, pixel values are sent as is, instead of converted from
+	// centikelvin to degrees Celsius.
+	Raw bool
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For the time of frames and resynchronizing. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a camera opened with Open. A *OpenOpts is also an Option,
+// and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *OpenOpts)
+}
+
+type optionFunc func(o *OpenOpts)
+
+func (fn optionFunc) apply(o *OpenOpts) {
+	fn(o)
+}
+
+func (opts *OpenOpts) apply(o *OpenOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithDevice sets OpenOpts.Device.
+func WithDevice(device string) Option {
+	return optionFunc(func(o *OpenOpts) { o.Device = device })
+}
+
+// WithSpeed sets OpenOpts.Speed.
+func WithSpeed(hz int) Option {
+	return optionFunc(func(o *OpenOpts) { o.Speed = hz })
+}
+
+// WithVersion sets OpenOpts.Version.
+func WithVersion(version int) Option {
+	return optionFunc(func(o *OpenOpts) { o.Version = version })
+}
+
+// WithRaw sets OpenOpts.Raw.
+func WithRaw(raw bool) Option {
+	return optionFunc(func(o *OpenOpts) { o.Raw = raw })
+}
+
+// WithVerbose sets OpenOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *OpenOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets OpenOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *OpenOpts) { o.Logger = logger })
+}
+
+// WithClock sets OpenOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *OpenOpts) { o.Clock = clock })
+}
+
+// Camera is a Lepton, sending frames of temperatures.
+type Camera struct {
+	opts   OpenOpts
+	shape  []int
+	logger edgeimpulse.Logger
+	clock  edgeimpulse.Clock
+	rc     io.ReadCloser
+	events chan frame.Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Ensure that Camera implements the frame.Source interface.
+var _ frame.Source = (*Camera)(nil)
+
+// Open opens the SPI device and starts reading frames.
+//
+// Callers must call Close to clean up. Canceling ctx also stops reading.
+func Open(ctx context.Context, opts ...Option) (*Camera, error) {
+	var xopts OpenOpts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
+	}
+	if xopts.Device == "" {
+		xopts.Device = "/dev/spidev0.0"
+	}
+	if xopts.Speed == 0 {
+		xopts.Speed = 16000000
+	}
+	if xopts.Version == 0 {
+		xopts.Version = 3
+	}
+	if xopts.Version != 2 && xopts.Version != 3 {
+		return nil, fmt.Errorf("unknown lepton version %d, must be 2 or 3", xopts.Version)
+	}
+	rc, err := openSPI(xopts.Device, xopts.Speed)
+	if err != nil {
+		return nil, fmt.Errorf("opening spi device %s: %w", xopts.Device, err)
+	}
+	return newCamera(ctx, rc, xopts), nil
+}
+
+// newCamera starts reading VoSPI packets from rc.
+func newCamera(ctx context.Context, rc io.ReadCloser, opts OpenOpts) *Camera {
+	ctx, cancel := context.WithCancel(ctx)
+	a := newAssembler(opts.Version)
+	c := &Camera{
+		opts:   opts,
+		shape:  []int{a.height, a.width},
+		logger: edgeimpulse.DefaultLogger(opts.Logger, opts.Verbose),
+		clock:  edgeimpulse.DefaultClock(opts.Clock),
+		rc:     rc,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		events: make(chan frame.Event, defaultFrequency+1),
+	}
+
+	go func() {
+		<-ctx.Done()
+		// Unblocks the reading goroutine.
+		c.rc.Close()
+	}()
+
+	go func() {
+		defer close(c.done)
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				c.mutex.Lock()
+				c.err = err
+				c.mutex.Unlock()
+				c.sendErr(ctx, err)
+			}
+		}()
+
+		buf := make([]byte, packetsPerRead*packetSize)
+		var prev []uint16
+		for {
+			if _, err := io.ReadFull(c.rc, buf); err != nil {
+				if ctx.Err() == nil {
+					c.sendErr(ctx, fmt.Errorf("reading packets: %w", err))
+				}
+				return
+			}
+			for p := buf; len(p) >= packetSize; p = p[packetSize:] {
+				pixels, ok, resync := a.add(p[:packetSize])
+				if resync {
+					// Deassert chip select long enough for the Lepton
+					// to start over at the next frame.
+					c.logger.Logf(edgeimpulse.LogDebug, "lost vospi sync, resynchronizing")
+					t := c.clock.NewTimer(resyncDelay)
+					select {
+					case <-t.C():
+					case <-ctx.Done():
+						t.Stop()
+						return
+					}
+					break
+				}
+				// VoSPI repeats frames to reach 27 frames per second.
+				if !ok || equal(pixels, prev) {
+					continue
+				}
+				prev = pixels
+				c.send(pixels)
+			}
+		}
+	}()
+
+	return c
+}
+
+func equal(a, b []uint16) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// send sends a frame of pixels, converted to temperatures unless raw.
+func (c *Camera) send(pixels []uint16) {
+	values := make([]float64, len(pixels))
+	for i, v := range pixels {
+		if c.opts.Raw {
+			values[i] = float64(v)
+		} else {
+			values[i] = float64(v)/100 - kelvinOffset
+		}
+	}
+	metrics.FramesCaptured.Inc()
+	select {
+	case c.events <- frame.Event{Frame: frame.Frame{Time: c.clock.Now(), Values: values}}:
+	default:
+		metrics.FramesDropped.Inc()
+		c.logger.Logf(edgeimpulse.LogDebug, "dropping frame, consumer still busy")
+	}
+}
+
+func (c *Camera) sendErr(ctx context.Context, err error) {
+	select {
+	case c.events <- frame.Event{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// Shape returns the dimensions of frames: [120 160] for a Lepton 3, [60 80]
+// for a Lepton 2.
+func (c *Camera) Shape() []int {
+	return c.shape
+}
+
+// Units returns "°C", or empty for raw values.
+func (c *Camera) Units() string {
+	if c.opts.Raw {
+		return ""
+	}
+	return "°C"
+}
+
+// Frequency returns the number of unique frames per second.
+func (c *Camera) Frequency() float64 {
+	return defaultFrequency
+}
+
+// Events returns the channel on which frames are sent.
+func (c *Camera) Events() chan frame.Event {
+	return c.events
+}
+
+// Close stops reading and closes the SPI device.
+func (c *Camera) Close() error {
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+// Err returns the error that stopped the camera after recovering from a
+// panic, or nil.
+func (c *Camera) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+// assembler assembles frames from VoSPI packets. A Lepton 2 frame is a
+// single segment of 60 packets, each a row of 80 pixels. A Lepton 3 frame is
+// four segments of 60 packets, each packet half a row of 160 pixels, with the
+// segment number in packet 20.
+type assembler struct {
+	width, height int
+	segments      int
+	segment       []uint16 // Pixels of the segment being read.
+	frame         []uint16
+	packet        int // Next expected packet number.
+	number        int // Number of the segment being read, from packet 20.
+	last          int // Last segment copied into frame, 0 if none.
+}
+
+func newAssembler(version int) *assembler {
+	a := &assembler{width: 80, height: 60, segments: 1}
+	if version == 3 {
+		a.width, a.height, a.segments = 160, 120, 4
+	}
+	a.segment = make([]uint16, segmentPackets*packetPixels)
+	a.frame = make([]uint16, a.width*a.height)
+	return a
+}
+
+// add adds a packet. When it completes a frame, the frame is returned, with
+// ok set. If packets arrive out of order, resync is set, and the caller must
+// pause reading to resynchronize.
+func (a *assembler) add(p []byte) (pixels []uint16, ok, resync bool) {
+	id := binary.BigEndian.Uint16(p)
+	if id&0x0f00 == 0x0f00 {
+		// Discard packet, sent while no frame is ready.
+		return nil, false, false
+	}
+	n := int(id & 0x0fff)
+	if n != a.packet {
+		a.packet = 0
+		a.last = 0
+		if n != 0 {
+			return nil, false, true
+		}
+	}
+	for i := 0; i < packetPixels; i++ {
+		a.segment[n*packetPixels+i] = binary.BigEndian.Uint16(p[4+2*i:])
+	}
+	a.packet++
+	if n == 20 {
+		a.number = 1
+		if a.segments > 1 {
+			a.number = int(id>>12) & 0x7
+		}
+	}
+	if a.packet < segmentPackets {
+		return nil, false, false
+	}
+	a.packet = 0
+
+	switch {
+	case a.number < 1 || a.number > a.segments:
+		// Invalid segment, sent while the next frame is not ready.
+		return nil, false, false
+	case a.number == a.last+1:
+	case a.number == 1:
+		// Start of a new frame, after missing part of the previous one.
+	default:
+		a.last = 0
+		return nil, false, false
+	}
+	copy(a.frame[(a.number-1)*len(a.segment):], a.segment)
+	a.last = a.number
+	if a.last < a.segments {
+		return nil, false, false
+	}
+	a.last = 0
+	return append([]uint16(nil), a.frame...), true, false
+}
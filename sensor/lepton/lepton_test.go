@@ -0,0 +1,102 @@
+package lepton
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// packet returns a VoSPI packet with number n of segment seg, with pixels
+// set to value.
+func packet(n, seg int, value uint16) []byte {
+	p := make([]byte, packetSize)
+	id := uint16(n)
+	if n == 20 {
+		id |= uint16(seg) << 12
+	}
+	binary.BigEndian.PutUint16(p, id)
+	for i := 0; i < packetPixels; i++ {
+		binary.BigEndian.PutUint16(p[4+2*i:], value)
+	}
+	return p
+}
+
+// segment returns the packets of a segment, with pixels set to value.
+func segment(seg int, value uint16) [][]byte {
+	var l [][]byte
+	for n := 0; n < segmentPackets; n++ {
+		l = append(l, packet(n, seg, value))
+	}
+	return l
+}
+
+func TestAssembler(t *testing.T) {
+	a := newAssembler(3)
+	discard := make([]byte, packetSize)
+	binary.BigEndian.PutUint16(discard, 0x0f00)
+
+	var packets [][]byte
+	packets = append(packets, discard)
+	packets = append(packets, segment(0, 1)...) // Invalid, skipped.
+	packets = append(packets, segment(3, 1)...) // Not a start of a frame, skipped.
+	for seg := 1; seg <= 4; seg++ {
+		packets = append(packets, segment(seg, uint16(seg))...)
+	}
+	var frames [][]uint16
+	for _, p := range packets {
+		pixels, ok, resync := a.add(p)
+		if resync {
+			t.Fatalf("unexpected resync")
+		}
+		if ok {
+			frames = append(frames, pixels)
+		}
+	}
+	if len(frames) != 1 {
+		t.Fatalf("got %d frames, expected 1", len(frames))
+	}
+	f := frames[0]
+	if len(f) != 160*120 {
+		t.Fatalf("got %d pixels, expected %d", len(f), 160*120)
+	}
+	// Each segment is a quarter of the rows.
+	for _, c := range []struct{ row, value int }{{0, 1}, {29, 1}, {30, 2}, {89, 3}, {119, 4}} {
+		if v := f[c.row*160+159]; int(v) != c.value {
+			t.Errorf("row %d: got %d, expected %d", c.row, v, c.value)
+		}
+	}
+
+	if _, _, resync := a.add(packet(5, 0, 0)); !resync {
+		t.Errorf("expected resync for out of order packet")
+	}
+}
+
+func TestCamera(t *testing.T) {
+	var buf bytes.Buffer
+	// A frame twice, the duplicate is skipped, at 300K. Packets are read 20
+	// at a time, so 120 packets are read in full.
+	for i := 0; i < 2; i++ {
+		for _, p := range segment(1, 30000) {
+			buf.Write(p)
+		}
+	}
+	c := newCamera(context.Background(), io.NopCloser(&buf), OpenOpts{Version: 2})
+	defer c.Close()
+
+	if s := c.Shape(); len(s) != 2 || s[0] != 60 || s[1] != 80 {
+		t.Fatalf("got shape %v, expected [60 80]", s)
+	}
+	ev := <-c.Events()
+	if ev.Err != nil {
+		t.Fatalf("got error %v", ev.Err)
+	}
+	if v := ev.Frame.Values[0]; v < 26.84 || v > 26.86 {
+		t.Errorf("got %v°C, expected 26.85", v)
+	}
+	ev = <-c.Events()
+	if ev.Err == nil {
+		t.Errorf("expected error at end of data, got frame")
+	}
+}
@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package lepton
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ioctls of spidev, from linux/spi/spidev.h.
+const (
+	spiIOCWrMode        = 0x40016b01
+	spiIOCWrBitsPerWord = 0x40016b03
+	spiIOCWrMaxSpeedHz  = 0x40046b04
+)
+
+// openSPI opens a spidev device in mode 3, with 8 bits per word, at speed Hz.
+func openSPI(device string, speed int) (io.ReadCloser, error) {
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	mode := uint8(3)
+	bits := uint8(8)
+	hz := uint32(speed)
+	for _, c := range []struct {
+		name string
+		req  uintptr
+		arg  unsafe.Pointer
+	}{
+		{"mode", spiIOCWrMode, unsafe.Pointer(&mode)},
+		{"bits per word", spiIOCWrBitsPerWord, unsafe.Pointer(&bits)},
+		{"speed", spiIOCWrMaxSpeedHz, unsafe.Pointer(&hz)},
+	} {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), c.req, uintptr(c.arg)); errno != 0 {
+			f.Close()
+			return nil, fmt.Errorf("setting spi %s: %w", c.name, errno)
+		}
+	}
+	return f, nil
+}
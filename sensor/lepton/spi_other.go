@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package lepton
+
+import (
+	"errors"
+	"io"
+)
+
+// openSPI returns an error, spidev is only available on linux.
+func openSPI(device string, speed int) (io.ReadCloser, error) {
+	return nil, errors.New("spi is only supported on linux")
+}
@@ -0,0 +1,399 @@
+// Package mqtt records sensor data that is published to an MQTT broker, as
+// JSON or CSV, e.g. by existing installations of sensor nodes. Payloads are
+// turned into samples with a Mapping.
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+	mqttclient "github.com/edgeimpulse/linux-sdk-go/v2/mqtt"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// Format is the encoding of payloads.
+type Format string
+
+// Formats of payloads.
+const (
+	FormatAuto Format = ""     // JSON if the payload starts with { or [, otherwise CSV.
+	FormatJSON Format = "json" // An object, or an array of objects, each a sample.
+	FormatCSV  Format = "csv"  // One line per sample, values separated by commas.
+)
+
+// Mapping describes how to turn payloads into samples.
+type Mapping struct {
+	Format Format
+
+	// For JSON, the field of each axis, with dots separating the names of
+	// nested objects, e.g. "accel.x". If empty, the names of the axes are
+	// used.
+	Fields []string
+
+	// For JSON, if set, the field with the time of the sample, in
+	// milliseconds since the epoch, or as RFC3339 string. If empty, or
+	// for CSV, the time of receiving the message is used.
+	TimeField string
+
+	// For CSV, the column of each axis, starting at 0. If empty, the first
+	// columns are used, one per axis.
+	Columns []int
+}
+
+// Decode returns the samples in payload, with a value for each of axes. Now is
+// the time of samples without time field.
+func (m Mapping) Decode(payload []byte, axes []timeseries.Axis, now time.Time) ([]timeseries.Sample, error) {
+	format := m.Format
+	if format == FormatAuto {
+		format = FormatCSV
+		if p := bytes.TrimSpace(payload); len(p) > 0 && (p[0] == '{' || p[0] == '[') {
+			format = FormatJSON
+		}
+	}
+	switch format {
+	case FormatJSON:
+		return m.decodeJSON(payload, axes, now)
+	case FormatCSV:
+		return m.decodeCSV(payload, axes, now)
+	}
+	return nil, fmt.Errorf("unknown format %q", m.Format)
+}
+
+func (m Mapping) decodeJSON(payload []byte, axes []timeseries.Axis, now time.Time) ([]timeseries.Sample, error) {
+	fields := m.Fields
+	if len(fields) == 0 {
+		for _, a := range axes {
+			fields = append(fields, a.Name)
+		}
+	}
+	if len(fields) != len(axes) {
+		return nil, fmt.Errorf("%d fields for %d axes", len(fields), len(axes))
+	}
+
+	var objs []map[string]interface{}
+	if p := bytes.TrimSpace(payload); len(p) > 0 && p[0] == '[' {
+		if err := json.Unmarshal(p, &objs); err != nil {
+			return nil, fmt.Errorf("parsing json: %w", err)
+		}
+	} else {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(p, &obj); err != nil {
+			return nil, fmt.Errorf("parsing json: %w", err)
+		}
+		objs = append(objs, obj)
+	}
+
+	samples := make([]timeseries.Sample, len(objs))
+	for i, obj := range objs {
+		s := timeseries.Sample{Time: now, Values: make([]float64, len(fields))}
+		for j, f := range fields {
+			v, ok := lookup(obj, f).(float64)
+			if !ok {
+				return nil, fmt.Errorf("field %q missing or not a number", f)
+			}
+			s.Values[j] = v
+		}
+		if m.TimeField != "" {
+			switch v := lookup(obj, m.TimeField).(type) {
+			case float64:
+				s.Time = time.Unix(0, int64(v*float64(time.Millisecond)))
+			case string:
+				t, err := time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return nil, fmt.Errorf("parsing time field: %w", err)
+				}
+				s.Time = t
+			default:
+				return nil, fmt.Errorf("time field %q missing", m.TimeField)
+			}
+		}
+		samples[i] = s
+	}
+	return samples, nil
+}
+
+// lookup returns the value at the dot-separated path in obj, or nil.
+func lookup(obj map[string]interface{}, path string) interface{} {
+	var v interface{} = obj
+	for _, name := range strings.Split(path, ".") {
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = o[name]
+	}
+	return v
+}
+
+func (m Mapping) decodeCSV(payload []byte, axes []timeseries.Axis, now time.Time) ([]timeseries.Sample, error) {
+	columns := m.Columns
+	if len(columns) == 0 {
+		for i := range axes {
+			columns = append(columns, i)
+		}
+	}
+	if len(columns) != len(axes) {
+		return nil, fmt.Errorf("%d columns for %d axes", len(columns), len(axes))
+	}
+
+	var samples []timeseries.Sample
+	for _, line := range strings.Split(string(payload), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		t := strings.Split(line, ",")
+		s := timeseries.Sample{Time: now, Values: make([]float64, len(columns))}
+		for i, c := range columns {
+			if c < 0 || c >= len(t) {
+				return nil, fmt.Errorf("line %q has no column %d", line, c)
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(t[c]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing column %d: %w", c, err)
+			}
+			s.Values[i] = v
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// RecorderOpts are options for a Recorder.
+type RecorderOpts struct {
+	// Address of the broker, host:port.
+	Addr string
+
+	// Options for connecting to the broker.
+	Opts mqttclient.Opts
+
+	// Topic filter to subscribe to, e.g. "sensors/+/imu".
+	Topic string
+
+	// Names and units of the axes of the samples.
+	Axes []timeseries.Axis
+
+	// Samples per second published, typically the frequency of the model.
+	Frequency float64
+
+	// How to turn payloads into samples.
+	Mapping Mapping
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For the time of samples without time field. If nil,
+	// edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A *RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts *RecorderOpts) apply(o *RecorderOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithBroker sets RecorderOpts.Addr and RecorderOpts.Opts.
+func WithBroker(addr string, opts mqttclient.Opts) Option {
+	return optionFunc(func(o *RecorderOpts) {
+		o.Addr = addr
+		o.Opts = opts
+	})
+}
+
+// WithTopic sets RecorderOpts.Topic.
+func WithTopic(topic string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Topic = topic })
+}
+
+// WithAxes sets RecorderOpts.Axes.
+func WithAxes(axes ...timeseries.Axis) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Axes = axes })
+}
+
+// WithFrequency sets RecorderOpts.Frequency.
+func WithFrequency(frequency float64) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Frequency = frequency })
+}
+
+// WithMapping sets RecorderOpts.Mapping.
+func WithMapping(m Mapping) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Mapping = m })
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// Recorder records samples from messages published to a broker.
+type Recorder struct {
+	opts   RecorderOpts
+	logger edgeimpulse.Logger
+	clock  edgeimpulse.Clock
+	client *mqttclient.Client
+	events chan timeseries.Event
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Ensure that Recorder implements the Recorder interface.
+var _ timeseries.Recorder = (*Recorder)(nil)
+
+// NewRecorder connects to the broker and subscribes to the topic. An error is
+// returned if the broker rejects the subscription. Messages that cannot be
+// decoded are logged and skipped. If the connection to the broker breaks, an
+// error event is sent and the recorder stops: it does not reconnect.
+//
+// Callers must call Close to clean up. Canceling ctx also stops the recorder.
+func NewRecorder(ctx context.Context, opts ...Option) (*Recorder, error) {
+	r := &Recorder{done: make(chan struct{})}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if r.opts.Addr == "" || r.opts.Topic == "" {
+		return nil, fmt.Errorf("broker address and topic are required")
+	}
+	if len(r.opts.Axes) == 0 {
+		return nil, fmt.Errorf("no axes")
+	}
+	if r.opts.Frequency <= 0 {
+		return nil, fmt.Errorf("frequency must be > 0")
+	}
+	r.logger = edgeimpulse.DefaultLogger(r.opts.Logger, r.opts.Verbose)
+	r.clock = edgeimpulse.DefaultClock(r.opts.Clock)
+
+	// Room for a second of samples, so a consumer that is briefly busy
+	// does not cause gaps.
+	r.events = make(chan timeseries.Event, int(r.opts.Frequency)+1)
+
+	client, err := mqttclient.Dial(r.opts.Addr, &r.opts.Opts)
+	if err != nil {
+		return nil, err
+	}
+	r.client = client
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+
+	handle := func(topic string, payload []byte) {
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				r.logger.Logf(edgeimpulse.LogError, "handling message on %s: %v", topic, err)
+			}
+		}()
+
+		samples, err := r.opts.Mapping.Decode(payload, r.opts.Axes, r.clock.Now())
+		if err != nil {
+			r.logger.Logf(edgeimpulse.LogDebug, "skipping message on %s: %v", topic, err)
+			return
+		}
+		for _, s := range samples {
+			metrics.FramesCaptured.Inc()
+			select {
+			case r.events <- timeseries.Event{Sample: s}:
+			default:
+				metrics.FramesDropped.Inc()
+				r.logger.Logf(edgeimpulse.LogDebug, "dropping sample, consumer still busy")
+			}
+		}
+	}
+	if err := client.Subscribe(r.opts.Topic, handle); err != nil {
+		cancel()
+		client.Close()
+		return nil, err
+	}
+
+	// Watch the connection. We do not reconnect: the error event stops the
+	// consumer, which can start a new recorder.
+	go func() {
+		defer close(r.done)
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.Done():
+		}
+		err := client.Err()
+		if err == nil {
+			err = mqttclient.ErrClosed
+		}
+		select {
+		case r.events <- timeseries.Event{Err: fmt.Errorf("mqtt connection: %w", err)}:
+		case <-ctx.Done():
+		}
+	}()
+
+	return r, nil
+}
+
+// Axes returns the axes of the samples.
+func (r *Recorder) Axes() []timeseries.Axis {
+	return r.opts.Axes
+}
+
+// Frequency returns the configured number of samples per second.
+func (r *Recorder) Frequency() float64 {
+	return r.opts.Frequency
+}
+
+// Events returns the channel on which samples are sent.
+func (r *Recorder) Events() chan timeseries.Event {
+	return r.events
+}
+
+// Close disconnects from the broker.
+func (r *Recorder) Close() error {
+	r.cancel()
+	<-r.done
+	return r.client.Close()
+}
+
+// Err returns the error of the last panic recovered from while handling a
+// message, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
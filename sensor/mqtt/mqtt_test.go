@@ -0,0 +1,40 @@
+package mqtt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/sensor/mqtt"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+func TestMappingDecode(t *testing.T) {
+	axes := []timeseries.Axis{{Name: "x"}, {Name: "y"}}
+	now := time.Unix(100, 0)
+
+	m := mqtt.Mapping{Fields: []string{"accel.x", "accel.y"}, TimeField: "ts"}
+	samples, err := m.Decode([]byte(`[{"accel": {"x": 1, "y": 2}, "ts": 1500}, {"accel": {"x": 3, "y": 4}, "ts": "2020-01-01T00:00:00Z"}]`), axes, now)
+	if err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if len(samples) != 2 || samples[0].Values[1] != 2 || samples[1].Values[0] != 3 {
+		t.Fatalf("got samples %v", samples)
+	}
+	if !samples[0].Time.Equal(time.Unix(1, 500*1000*1000)) || samples[1].Time.Year() != 2020 {
+		t.Errorf("got times %v, %v", samples[0].Time, samples[1].Time)
+	}
+
+	samples, err = mqtt.Mapping{}.Decode([]byte(`{"x": 5, "y": 6, "z": 7}`), axes, now)
+	if err != nil || len(samples) != 1 || samples[0].Values[1] != 6 || !samples[0].Time.Equal(now) {
+		t.Fatalf("got samples %v, err %v", samples, err)
+	}
+
+	samples, err = mqtt.Mapping{Columns: []int{2, 0}}.Decode([]byte("1,2,3\n4,5,6\n"), axes, now)
+	if err != nil || len(samples) != 2 || samples[0].Values[0] != 3 || samples[1].Values[1] != 4 {
+		t.Fatalf("got samples %v, err %v", samples, err)
+	}
+
+	if _, err := (mqtt.Mapping{}).Decode([]byte(`{"x": 1}`), axes, now); err == nil {
+		t.Errorf("expected error for missing field")
+	}
+}
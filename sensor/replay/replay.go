@@ -0,0 +1,368 @@
+// Package replay replays logged sensor data from CSV or JSON lines files, at
+// real time or a multiple of it, for reproducible offline evaluation of models
+// with the time-series classifier.
+//
+// CSV files start with a header with the names of the columns. JSON lines
+// files have an object per line. Both have a timestamp field, by default named
+// "timestamp", in milliseconds or as RFC3339 string, like the CSV files
+// exported by Edge Impulse:
+//
+//	timestamp,accX,accY,accZ
+//	0,-0.51,0.02,9.81
+//	16,-0.49,0.03,9.79
+//
+// Or:
+//
+//	{"timestamp": 0, "accX": -0.51, "accY": 0.02, "accZ": 9.81}
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// RecorderOpts are options for a Recorder.
+type RecorderOpts struct {
+	// File to replay. Files ending in .csv are parsed as CSV, others as
+	// JSON lines.
+	Path string
+
+	// Names of the fields to replay, in the order the model expects them.
+	// If empty, all fields other than the timestamp are replayed, in order
+	// of the CSV header, or sorted by name for JSON lines.
+	Axes []string
+
+	// Name of the timestamp field. Defaults to "timestamp".
+	TimeField string
+
+	// Replay speed, a multiple of real time. Defaults to 1. If negative,
+	// samples are replayed as fast as they are read.
+	Speed float64
+
+	// If set, the file is replayed from the start again after the end.
+	Loop bool
+
+	// Samples per second. If 0, derived from the timestamps.
+	Frequency float64
+
+	// For pacing the replay. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A *RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts *RecorderOpts) apply(o *RecorderOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithPath sets RecorderOpts.Path.
+func WithPath(path string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Path = path })
+}
+
+// WithAxes sets RecorderOpts.Axes.
+func WithAxes(names ...string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Axes = names })
+}
+
+// WithTimeField sets RecorderOpts.TimeField.
+func WithTimeField(name string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.TimeField = name })
+}
+
+// WithSpeed sets RecorderOpts.Speed.
+func WithSpeed(speed float64) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Speed = speed })
+}
+
+// WithLoop sets RecorderOpts.Loop.
+func WithLoop(loop bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Loop = loop })
+}
+
+// WithFrequency sets RecorderOpts.Frequency.
+func WithFrequency(frequency float64) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Frequency = frequency })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// Recorder replays samples from a file. Unlike live recorders, it does not
+// drop samples when the consumer is busy, but waits, so replays are
+// reproducible. After the last sample, an event with error io.EOF is sent,
+// unless Loop is set.
+type Recorder struct {
+	opts    RecorderOpts
+	axes    []timeseries.Axis
+	samples []timeseries.Sample
+	events  chan timeseries.Event
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Ensure that Recorder implements the Recorder interface.
+var _ timeseries.Recorder = (*Recorder)(nil)
+
+// NewRecorder reads the file and starts replaying it.
+//
+// Callers must call Close to clean up. Canceling ctx also stops the recorder.
+func NewRecorder(ctx context.Context, opts ...Option) (*Recorder, error) {
+	r := &Recorder{done: make(chan struct{})}
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&r.opts)
+		}
+	}
+	if r.opts.TimeField == "" {
+		r.opts.TimeField = "timestamp"
+	}
+	if r.opts.Speed == 0 {
+		r.opts.Speed = 1
+	}
+
+	f, err := os.Open(r.opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var names []string
+	if strings.EqualFold(filepath.Ext(r.opts.Path), ".csv") {
+		names, r.samples, err = ReadCSV(f, r.opts.TimeField, r.opts.Axes)
+	} else {
+		names, r.samples, err = ReadJSONL(f, r.opts.TimeField, r.opts.Axes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", r.opts.Path, err)
+	}
+	if len(r.samples) == 0 {
+		return nil, fmt.Errorf("reading %s: no samples", r.opts.Path)
+	}
+	for _, name := range names {
+		r.axes = append(r.axes, timeseries.Axis{Name: name})
+	}
+	if r.opts.Frequency <= 0 {
+		n := len(r.samples)
+		d := r.samples[n-1].Time.Sub(r.samples[0].Time)
+		if n < 2 || d <= 0 {
+			return nil, fmt.Errorf("cannot derive frequency from timestamps, set frequency")
+		}
+		r.opts.Frequency = float64(n-1) / d.Seconds()
+	}
+
+	r.events = make(chan timeseries.Event)
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+	go r.replay(ctx)
+	return r, nil
+}
+
+// replay sends the samples, paced by their timestamps.
+func (r *Recorder) replay(ctx context.Context) {
+	defer close(r.done)
+
+	clock := edgeimpulse.DefaultClock(r.opts.Clock)
+	send := func(ev timeseries.Event) bool {
+		select {
+		case r.events <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		start := clock.Now()
+		first := r.samples[0].Time
+		for _, s := range r.samples {
+			if r.opts.Speed > 0 {
+				due := start.Add(time.Duration(float64(s.Time.Sub(first)) / r.opts.Speed))
+				if d := due.Sub(clock.Now()); d > 0 {
+					t := clock.NewTimer(d)
+					select {
+					case <-t.C():
+					case <-ctx.Done():
+						t.Stop()
+						return
+					}
+				}
+			}
+			if !send(timeseries.Event{Sample: s}) {
+				return
+			}
+		}
+		if !r.opts.Loop {
+			send(timeseries.Event{Err: io.EOF})
+			return
+		}
+	}
+}
+
+// Axes returns the axes of the replayed samples.
+func (r *Recorder) Axes() []timeseries.Axis {
+	return r.axes
+}
+
+// Frequency returns the number of samples per second of the file, not taking
+// the replay speed into account.
+func (r *Recorder) Frequency() float64 {
+	return r.opts.Frequency
+}
+
+// Events returns the channel on which samples are sent.
+func (r *Recorder) Events() chan timeseries.Event {
+	return r.events
+}
+
+// Close stops replaying.
+func (r *Recorder) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// parseTime parses a timestamp in milliseconds, or as RFC3339 string.
+func parseTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(ms*float64(time.Millisecond))), nil
+	}
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+// ReadCSV reads samples from CSV data with a header. If axes is empty, all
+// columns except the timestamp are read. The names of the read axes are
+// returned. Sample times are the timestamps from the file.
+func ReadCSV(r io.Reader, timeField string, axes []string) ([]string, []timeseries.Sample, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	tcol, ok := columns[timeField]
+	if !ok {
+		return nil, nil, fmt.Errorf("no column %q", timeField)
+	}
+	if len(axes) == 0 {
+		for _, name := range header {
+			if name = strings.TrimSpace(name); name != timeField {
+				axes = append(axes, name)
+			}
+		}
+	}
+	var cols []int
+	for _, name := range axes {
+		i, ok := columns[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("no column %q", name)
+		}
+		cols = append(cols, i)
+	}
+
+	var samples []timeseries.Sample
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+		t, err := parseTime(record[tcol])
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: parsing timestamp: %w", line, err)
+		}
+		s := timeseries.Sample{Time: t, Values: make([]float64, len(cols))}
+		for i, c := range cols {
+			if s.Values[i], err = strconv.ParseFloat(record[c], 64); err != nil {
+				return nil, nil, fmt.Errorf("line %d: parsing %s: %w", line, axes[i], err)
+			}
+		}
+		samples = append(samples, s)
+	}
+	return axes, samples, nil
+}
+
+// ReadJSONL reads samples from JSON lines data, one object per line. If axes
+// is empty, all fields except the timestamp of the first object are read,
+// sorted by name. The names of the read axes are returned. Sample times are
+// the timestamps from the file.
+func ReadJSONL(r io.Reader, timeField string, axes []string) ([]string, []timeseries.Sample, error) {
+	var samples []timeseries.Sample
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(text), &obj); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(axes) == 0 {
+			for name := range obj {
+				if name != timeField {
+					axes = append(axes, name)
+				}
+			}
+			sort.Strings(axes)
+		}
+		raw, ok := obj[timeField]
+		if !ok {
+			return nil, nil, fmt.Errorf("line %d: no field %q", line, timeField)
+		}
+		var ts interface{}
+		if err := json.Unmarshal(raw, &ts); err != nil {
+			return nil, nil, fmt.Errorf("line %d: parsing timestamp: %w", line, err)
+		}
+		t, err := parseTime(fmt.Sprint(ts))
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: parsing timestamp: %w", line, err)
+		}
+		s := timeseries.Sample{Time: t, Values: make([]float64, len(axes))}
+		for i, name := range axes {
+			raw, ok := obj[name]
+			if !ok {
+				return nil, nil, fmt.Errorf("line %d: no field %q", line, name)
+			}
+			if err := json.Unmarshal(raw, &s.Values[i]); err != nil {
+				return nil, nil, fmt.Errorf("line %d: parsing %s: %w", line, name, err)
+			}
+		}
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return axes, samples, nil
+}
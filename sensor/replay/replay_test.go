@@ -0,0 +1,59 @@
+package replay_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/sensor/replay"
+)
+
+func TestReadJSONL(t *testing.T) {
+	const data = `{"timestamp": 0, "y": 2, "x": 1}
+
+{"timestamp": "1970-01-01T00:00:00.010Z", "y": 4, "x": 3}
+`
+	axes, samples, err := replay.ReadJSONL(strings.NewReader(data), "timestamp", nil)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(axes) != 2 || axes[0] != "x" || len(samples) != 2 || samples[1].Values[1] != 4 {
+		t.Fatalf("got axes %v, samples %v", axes, samples)
+	}
+	if d := samples[1].Time.Sub(samples[0].Time).Milliseconds(); d != 10 {
+		t.Errorf("got interval %dms, expected 10ms", d)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wave.csv")
+	const data = "timestamp,accX,accY,accZ\n0,1,2,3\n10,4,5,6\n20,7,8,9\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := replay.NewRecorder(context.Background(), replay.WithPath(path), replay.WithAxes("accZ", "accX"), replay.WithSpeed(-1))
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	defer r.Close()
+
+	if r.Frequency() != 100 {
+		t.Errorf("got frequency %v, expected 100", r.Frequency())
+	}
+	var values [][]float64
+	for ev := range r.Events() {
+		if ev.Err == io.EOF {
+			break
+		} else if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		values = append(values, ev.Sample.Values)
+	}
+	if len(values) != 3 || values[0][0] != 3 || values[2][1] != 7 {
+		t.Errorf("got values %v", values)
+	}
+}
@@ -0,0 +1,367 @@
+// Package serial records sensor values that a microcontroller streams over a
+// serial port in the text protocol of the Edge Impulse data forwarder: one
+// line per sample, with the values of all axes separated by commas or tabs,
+// e.g. "0.12,-9.81,0.40". Lines that do not parse, like boot messages, are
+// skipped.
+package serial
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+// RecorderOpts are options for a Recorder.
+type RecorderOpts struct {
+	// Serial device, e.g. /dev/ttyACM0 or /dev/ttyUSB0.
+	DeviceID string
+
+	// Baud rate of the serial port, configured with stty. Defaults to
+	// 115200.
+	Baud int
+
+	// Number of values per sample. If 0, detected from the data.
+	AxisCount int
+
+	// Names of the axes, e.g. accX, accY, accZ. If empty, axes are named
+	// after their position: axis0, axis1, etc.
+	AxisNames []string
+
+	// Samples per second sent by the device. If 0, it is measured during
+	// the first second of data, like the data forwarder does.
+	Frequency float64
+
+	Verbose bool
+
+	// Receives log messages. If nil, the standard logger is used, with debug
+	// messages only if Verbose is set.
+	Logger edgeimpulse.Logger
+
+	// For the time of samples. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Option configures a recorder created with NewRecorder. A *RecorderOpts is
+// also an Option, and replaces all settings made by earlier options.
+type Option interface {
+	apply(o *RecorderOpts)
+}
+
+type optionFunc func(o *RecorderOpts)
+
+func (fn optionFunc) apply(o *RecorderOpts) {
+	fn(o)
+}
+
+func (opts *RecorderOpts) apply(o *RecorderOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithDevice sets RecorderOpts.DeviceID.
+func WithDevice(id string) Option {
+	return optionFunc(func(o *RecorderOpts) { o.DeviceID = id })
+}
+
+// WithBaud sets RecorderOpts.Baud.
+func WithBaud(baud int) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Baud = baud })
+}
+
+// WithAxes sets RecorderOpts.AxisCount and RecorderOpts.AxisNames.
+func WithAxes(names ...string) Option {
+	return optionFunc(func(o *RecorderOpts) {
+		o.AxisCount = len(names)
+		o.AxisNames = names
+	})
+}
+
+// WithAxisCount sets RecorderOpts.AxisCount.
+func WithAxisCount(n int) Option {
+	return optionFunc(func(o *RecorderOpts) { o.AxisCount = n })
+}
+
+// WithFrequency sets RecorderOpts.Frequency.
+func WithFrequency(frequency float64) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Frequency = frequency })
+}
+
+// WithVerbose sets RecorderOpts.Verbose.
+func WithVerbose(verbose bool) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets RecorderOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Logger = logger })
+}
+
+// WithClock sets RecorderOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) Option {
+	return optionFunc(func(o *RecorderOpts) { o.Clock = clock })
+}
+
+// parseLine parses the values of a line, separated by commas or tabs.
+func parseLine(line string) ([]float64, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, fmt.Errorf("empty line")
+	}
+	fields := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == '\t'
+	})
+	values := make([]float64, len(fields))
+	for i, s := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing value %q: %w", s, err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// Recorder records samples from a serial port.
+type Recorder struct {
+	opts    RecorderOpts
+	logger  edgeimpulse.Logger
+	clock   edgeimpulse.Clock
+	port    io.ReadCloser
+	scanner *bufio.Scanner
+	axes    []timeseries.Axis
+	events  chan timeseries.Event
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mutex sync.Mutex
+	err   error // Set after recovering from a panic.
+}
+
+// Ensure that Recorder implements the Recorder interface.
+var _ timeseries.Recorder = (*Recorder)(nil)
+
+// NewRecorder opens the serial port, configures its baud rate, and starts
+// recording. If the axis count or frequency is not configured, it first
+// reads data for a second to detect them.
+//
+// Callers must call Close to clean up. Canceling ctx also stops the recorder.
+func NewRecorder(ctx context.Context, opts ...Option) (*Recorder, error) {
+	var xopts RecorderOpts
+	for _, o := range opts {
+		if o != nil {
+			o.apply(&xopts)
+		}
+	}
+	if xopts.DeviceID == "" {
+		return nil, fmt.Errorf("no serial device")
+	}
+	if xopts.Baud == 0 {
+		xopts.Baud = 115200
+	}
+	if err := Configure(ctx, xopts.DeviceID, xopts.Baud); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(xopts.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("opening serial port: %w", err)
+	}
+	r, err := newRecorder(ctx, f, xopts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Configure sets the baud rate of the serial port, in raw mode, with stty.
+func Configure(ctx context.Context, device string, baud int) error {
+	deviceFlag := "-F"
+	if runtime.GOOS == "darwin" {
+		deviceFlag = "-f"
+	}
+	cmd := exec.CommandContext(ctx, "stty", deviceFlag, device, strconv.Itoa(baud), "raw", "-echo")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("configuring serial port with stty: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// newRecorder starts recording from port, which is closed by Close.
+func newRecorder(ctx context.Context, port io.ReadCloser, opts RecorderOpts) (*Recorder, error) {
+	r := &Recorder{
+		opts:    opts,
+		logger:  edgeimpulse.DefaultLogger(opts.Logger, opts.Verbose),
+		clock:   edgeimpulse.DefaultClock(opts.Clock),
+		port:    port,
+		scanner: bufio.NewScanner(port),
+		done:    make(chan struct{}),
+	}
+	if r.opts.AxisCount == 0 {
+		r.opts.AxisCount = len(opts.AxisNames)
+	}
+	if len(opts.AxisNames) > 0 && r.opts.AxisCount != len(opts.AxisNames) {
+		return nil, fmt.Errorf("axis count %d does not match %d axis names", r.opts.AxisCount, len(opts.AxisNames))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+	go func() {
+		<-ctx.Done()
+		// Unblocks detecting and the reading goroutine.
+		r.port.Close()
+	}()
+
+	if r.opts.AxisCount == 0 || r.opts.Frequency <= 0 {
+		if err := r.detect(ctx); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	for i := 0; i < r.opts.AxisCount; i++ {
+		a := timeseries.Axis{Name: fmt.Sprintf("axis%d", i)}
+		if i < len(r.opts.AxisNames) {
+			a.Name = r.opts.AxisNames[i]
+		}
+		r.axes = append(r.axes, a)
+	}
+
+	// Room for a second of samples, so a consumer that is briefly busy
+	// does not cause gaps.
+	r.events = make(chan timeseries.Event, int(r.opts.Frequency)+1)
+
+	go func() {
+		defer close(r.done)
+		defer func() {
+			if x := recover(); x != nil {
+				err := edgeimpulse.PanicError(x)
+				r.mutex.Lock()
+				r.err = err
+				r.mutex.Unlock()
+				r.sendErr(ctx, err)
+			}
+		}()
+
+		for r.scanner.Scan() {
+			values, err := parseLine(r.scanner.Text())
+			if err != nil || len(values) != r.opts.AxisCount {
+				r.logger.Logf(edgeimpulse.LogDebug, "skipping line %q", r.scanner.Text())
+				continue
+			}
+			metrics.FramesCaptured.Inc()
+			select {
+			case r.events <- timeseries.Event{Sample: timeseries.Sample{Time: r.clock.Now(), Values: values}}:
+			default:
+				metrics.FramesDropped.Inc()
+				r.logger.Logf(edgeimpulse.LogDebug, "dropping sample, consumer still busy")
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		err := r.scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		r.sendErr(ctx, fmt.Errorf("reading serial port: %w", err))
+	}()
+
+	return r, nil
+}
+
+// detect reads lines for a second, setting the axis count from the first
+// valid line, and the frequency from the number of valid lines.
+func (r *Recorder) detect(ctx context.Context) error {
+	// Skip the first line, it is likely partial.
+	r.scanner.Scan()
+
+	var start time.Time
+	n := 0
+	for r.scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		values, err := parseLine(r.scanner.Text())
+		if err != nil {
+			continue
+		}
+		if r.opts.AxisCount == 0 {
+			r.opts.AxisCount = len(values)
+			r.logger.Logf(edgeimpulse.LogDebug, "detected %d axes", r.opts.AxisCount)
+		} else if len(values) != r.opts.AxisCount {
+			continue
+		}
+		if r.opts.Frequency > 0 {
+			return nil
+		}
+		now := r.clock.Now()
+		if start.IsZero() {
+			start = now
+			continue
+		}
+		n++
+		if d := now.Sub(start); d >= time.Second {
+			r.opts.Frequency = float64(n) / d.Seconds()
+			r.logger.Logf(edgeimpulse.LogDebug, "detected frequency %.1fHz", r.opts.Frequency)
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		// The port was closed because ctx was canceled.
+		return ctx.Err()
+	}
+	if err := r.scanner.Err(); err != nil {
+		return fmt.Errorf("reading serial port: %w", err)
+	}
+	return fmt.Errorf("reading serial port: no valid data: %w", io.ErrUnexpectedEOF)
+}
+
+func (r *Recorder) sendErr(ctx context.Context, err error) {
+	select {
+	case r.events <- timeseries.Event{Err: err}:
+	case <-ctx.Done():
+	}
+}
+
+// Axes returns the axes of the samples.
+func (r *Recorder) Axes() []timeseries.Axis {
+	return r.axes
+}
+
+// Frequency returns the number of samples per second, as configured or
+// detected.
+func (r *Recorder) Frequency() float64 {
+	return r.opts.Frequency
+}
+
+// Events returns the channel on which samples are sent.
+func (r *Recorder) Events() chan timeseries.Event {
+	return r.events
+}
+
+// Close stops recording and closes the serial port.
+func (r *Recorder) Close() error {
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// Err returns the error that stopped the recorder after recovering from a
+// panic, or nil.
+func (r *Recorder) Err() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.err
+}
@@ -0,0 +1,73 @@
+package serial
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	values, err := parseLine("1.5,-2\t3e1\r\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(values) != 3 || values[0] != 1.5 || values[1] != -2 || values[2] != 30 {
+		t.Errorf("got values %v", values)
+	}
+	for _, s := range []string{"", "booting...", "1,x,3"} {
+		if _, err := parseLine(s); err == nil {
+			t.Errorf("parsing %q: expected error", s)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		// Partial first line, a boot message, and a line with the wrong number of values.
+		io.WriteString(pw, "2,3\nhello\n1,2,3\n1,2\n4,5,6\n")
+	}()
+
+	r, err := newRecorder(context.Background(), pr, RecorderOpts{Frequency: 100})
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	defer r.Close()
+
+	if axes := r.Axes(); len(axes) != 3 || axes[2].Name != "axis2" {
+		t.Fatalf("got axes %v", axes)
+	}
+	select {
+	case ev := <-r.Events():
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		if v := ev.Sample.Values; len(v) != 3 || v[0] != 4 || v[2] != 6 {
+			t.Errorf("got values %v", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for sample")
+	}
+}
+
+func TestRecorderDetectCanceled(t *testing.T) {
+	pr, _ := io.Pipe() // A silent device, never writing.
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := newRecorder(ctx, pr, RecorderOpts{})
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, expected context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for canceled detection")
+	}
+}
@@ -0,0 +1,26 @@
+package sink
+
+import (
+	"context"
+)
+
+type channel chan<- Result
+
+// NewChan returns a sink sending results on c. Send blocks until the result is
+// received or the context is canceled. Close does not close c.
+func NewChan(c chan<- Result) Sink {
+	return channel(c)
+}
+
+func (c channel) Send(ctx context.Context, r Result) error {
+	select {
+	case c <- r:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c channel) Close() error {
+	return nil
+}
@@ -0,0 +1,122 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileOpts are options for a file sink.
+type FileOpts struct {
+	// Size in bytes after which the file is rotated. If zero, 10MB is used.
+	MaxSize int64
+
+	// Number of rotated files to keep, named path.1, path.2, etc. If zero,
+	// 5 is used.
+	MaxFiles int
+}
+
+// File is a sink writing results as newline-delimited JSON to a file, rotating
+// the file when it becomes too large.
+type File struct {
+	path string
+	opts FileOpts
+
+	mutex sync.Mutex
+	f     *os.File
+	size  int64
+}
+
+// NewFile opens path for appending results.
+//
+// Callers must call Close to close the file.
+func NewFile(path string, opts *FileOpts) (*File, error) {
+	s := &File{path: path}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.MaxSize == 0 {
+		s.opts.MaxSize = 10 * 1024 * 1024
+	}
+	if s.opts.MaxFiles == 0 {
+		s.opts.MaxFiles = 5
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *File) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening result file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat result file: %w", err)
+	}
+	s.f = f
+	s.size = fi.Size()
+	return nil
+}
+
+// rotate renames the current file to path.1, shifting older files, and opens a
+// new file.
+func (s *File) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("closing result file: %w", err)
+	}
+	s.f = nil
+	os.Remove(fmt.Sprintf("%s.%d", s.path, s.opts.MaxFiles))
+	for i := s.opts.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return fmt.Errorf("rotating result file: %w", err)
+	}
+	return s.open()
+}
+
+// Send appends the result to the file, rotating it first if it is too large.
+func (s *File) Send(ctx context.Context, r Result) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal result: %w", err)
+	}
+	buf = append(buf, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && s.size+int64(len(buf)) > s.opts.MaxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(buf)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing result: %w", err)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *File) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
@@ -0,0 +1,65 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPOpts are options for an HTTP webhook sink.
+type HTTPOpts struct {
+	Client *http.Client      // If nil, a client with a timeout of DefaultTimeout is used.
+	Header map[string]string // Additional request headers, e.g. for authentication.
+}
+
+// HTTP is a sink POSTing results as JSON to a webhook URL.
+type HTTP struct {
+	url  string
+	opts HTTPOpts
+}
+
+// NewHTTP returns a sink POSTing results to url.
+func NewHTTP(url string, opts *HTTPOpts) *HTTP {
+	s := &HTTP{url: url}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Client == nil {
+		s.opts.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+	return s
+}
+
+// Send POSTs the result. Responses other than 2xx result in an error.
+func (s *HTTP) Send(ctx context.Context, r Result) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal result: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("new HTTP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.opts.Header {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.opts.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("http response error: %s", resp.Status)
+	}
+	return nil
+}
+
+// Close is a no-op.
+func (s *HTTP) Close() error {
+	return nil
+}
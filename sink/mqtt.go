@@ -0,0 +1,88 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/mqtt"
+)
+
+// MQTTOpts are options for an MQTT sink.
+type MQTTOpts struct {
+	mqtt.Opts
+	Retain     bool          // Whether the broker should retain the last result.
+	RetryDelay time.Duration // Time after a failed connect before connecting again. If zero, 5 seconds is used.
+}
+
+// MQTT is a sink publishing results as JSON to a topic on an MQTT broker. The
+// connection is made on the first Send, and remade after failures. While the
+// broker cannot be reached, results are dropped with an error without
+// connecting again until MQTTOpts.RetryDelay has passed.
+type MQTT struct {
+	addr  string
+	topic string
+	opts  MQTTOpts
+
+	mutex    sync.Mutex
+	client   *mqtt.Client
+	dialErr  error     // Of the last failed connect.
+	dialTime time.Time // Of the last failed connect.
+}
+
+// NewMQTT returns a sink publishing to topic on the broker at addr
+// (host:port).
+func NewMQTT(addr, topic string, opts *MQTTOpts) *MQTT {
+	s := &MQTT{addr: addr, topic: topic}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.RetryDelay == 0 {
+		s.opts.RetryDelay = 5 * time.Second
+	}
+	return s
+}
+
+// Send publishes the result, connecting to the broker if needed. Connecting
+// stops when ctx is done.
+func (s *MQTT) Send(ctx context.Context, r Result) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal result: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client != nil && s.client.Err() != nil {
+		s.client.Close()
+		s.client = nil
+	}
+	if s.client == nil {
+		if s.dialErr != nil && time.Since(s.dialTime) < s.opts.RetryDelay {
+			return fmt.Errorf("mqtt broker unavailable: %w", s.dialErr)
+		}
+		c, err := mqtt.DialContext(ctx, s.addr, &s.opts.Opts)
+		if err != nil {
+			s.dialErr = err
+			s.dialTime = time.Now()
+			return err
+		}
+		s.dialErr = nil
+		s.client = c
+	}
+	return s.client.Publish(s.topic, buf, s.opts.Retain)
+}
+
+// Close disconnects from the broker.
+func (s *MQTT) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
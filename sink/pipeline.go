@@ -0,0 +1,26 @@
+package sink
+
+import (
+	"context"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+type pipelineSink struct {
+	sink   Sink
+	source string
+}
+
+// ForPipeline returns s as an edgeimpulse.PipelineSink, sending the results of
+// a pipeline with source as Result.Source.
+func ForPipeline(s Sink, source string) edgeimpulse.PipelineSink {
+	return pipelineSink{s, source}
+}
+
+func (p pipelineSink) Send(ctx context.Context, r edgeimpulse.PipelineResult) error {
+	return p.sink.Send(ctx, Result{Time: r.Time, Source: p.source, Response: r.RunnerClassifyResponse})
+}
+
+func (p pipelineSink) Close() error {
+	return p.sink.Close()
+}
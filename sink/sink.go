@@ -0,0 +1,160 @@
+// Package sink implements destinations for classification results, such as
+// stdout, files, MQTT brokers and HTTP webhooks.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/location"
+)
+
+// Result is a classification result to send to a sink.
+type Result struct {
+	Time     time.Time                          `json:"time"`
+	Source   string                             `json:"source,omitempty"` // E.g. the name of the command or device.
+	Response edgeimpulse.RunnerClassifyResponse `json:"response"`
+	Location *location.Fix                      `json:"location,omitempty"` // Where the input was captured, if known.
+	Command  string                             `json:"command,omitempty"`  // Voice command recognized in the input, see edgeimpulse.Grammar.
+}
+
+// DefaultTimeout is the time a send to a sink opened from specs may take,
+// after which the result is dropped with an error. It keeps an unreachable
+// broker or webhook from stalling classification.
+const DefaultTimeout = 5 * time.Second
+
+// Sink is a destination for results.
+type Sink interface {
+	// Send delivers a result. Send may block until the result is delivered
+	// or ctx is canceled.
+	Send(ctx context.Context, r Result) error
+
+	// Close releases resources, such as files and connections.
+	Close() error
+}
+
+type multi []Sink
+
+// Multi returns a sink that sends results to all sinks. An error from one sink
+// does not prevent sending to the others, the first error is returned.
+func Multi(sinks ...Sink) Sink {
+	return multi(sinks)
+}
+
+func (m multi) Send(ctx context.Context, r Result) error {
+	var err error
+	for _, s := range m {
+		if xerr := s.Send(ctx, r); xerr != nil && err == nil {
+			err = xerr
+		}
+	}
+	return err
+}
+
+func (m multi) Close() error {
+	var err error
+	for _, s := range m {
+		if xerr := s.Close(); xerr != nil && err == nil {
+			err = xerr
+		}
+	}
+	return err
+}
+
+type timeout struct {
+	Sink
+	d time.Duration
+}
+
+// WithTimeout returns a sink that cancels sends to s that take longer than d.
+func WithTimeout(s Sink, d time.Duration) Sink {
+	return timeout{s, d}
+}
+
+func (t timeout) Send(ctx context.Context, r Result) error {
+	ctx, cancel := context.WithTimeout(ctx, t.d)
+	defer cancel()
+	return t.Sink.Send(ctx, r)
+}
+
+// Parse returns a sink for spec, as used in command-line flags:
+//
+//	text                     human-readable results on stdout
+//	json                     newline-delimited JSON on stdout
+//	file:/path/results.json  newline-delimited JSON to a file, with rotation
+//	mqtt://host:1883/topic   JSON to an MQTT topic, with optional user:pass@
+//	http://... https://...   JSON POSTed to a webhook
+func Parse(spec string) (Sink, error) {
+	switch spec {
+	case "text":
+		return NewText(os.Stdout), nil
+	case "json":
+		return NewJSON(os.Stdout), nil
+	}
+	if strings.HasPrefix(spec, "file:") {
+		return NewFile(strings.TrimPrefix(spec, "file:"), nil)
+	}
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parsing sink %q: %w", spec, err)
+	}
+	switch u.Scheme {
+	case "mqtt":
+		opts := MQTTOpts{}
+		if u.User != nil {
+			opts.Username = u.User.Username()
+			opts.Password, _ = u.User.Password()
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host += ":1883"
+		}
+		return NewMQTT(host, strings.TrimPrefix(u.Path, "/"), &opts), nil
+	case "http", "https":
+		return NewHTTP(spec, nil), nil
+	}
+	return nil, fmt.Errorf("unknown sink %q, need one of: text, json, file:path, mqtt://host/topic, http(s)://...", spec)
+}
+
+// Specs is a flag.Value collecting sink specs from a repeatable flag.
+type Specs []string
+
+func (s *Specs) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set adds a spec, after checking it parses.
+func (s *Specs) Set(spec string) error {
+	if spec != "text" && spec != "json" && !strings.HasPrefix(spec, "file:") && !strings.Contains(spec, "://") {
+		return fmt.Errorf("unknown sink %q", spec)
+	}
+	*s = append(*s, spec)
+	return nil
+}
+
+// Open parses and opens all specs, returning a single sink. If no specs are
+// present, def is used. Sends to the sink are limited to DefaultTimeout.
+func (s Specs) Open(def string) (Sink, error) {
+	specs := s
+	if len(specs) == 0 {
+		specs = Specs{def}
+	}
+	var sinks []Sink
+	for _, spec := range specs {
+		x, err := Parse(spec)
+		if err != nil {
+			Multi(sinks...).Close()
+			return nil, err
+		}
+		sinks = append(sinks, x)
+	}
+	if len(sinks) == 1 {
+		return WithTimeout(sinks[0], DefaultTimeout), nil
+	}
+	return WithTimeout(Multi(sinks...), DefaultTimeout), nil
+}
@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+type writer struct {
+	mutex sync.Mutex
+	w     io.Writer
+	json  bool
+}
+
+// NewText returns a sink writing results to w in human-readable form, one line
+// per result.
+func NewText(w io.Writer) Sink {
+	return &writer{w: w}
+}
+
+// NewJSON returns a sink writing results to w as newline-delimited JSON.
+func NewJSON(w io.Writer) Sink {
+	return &writer{w: w, json: true}
+}
+
+func (s *writer) Send(ctx context.Context, r Result) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var err error
+	if s.json {
+		err = json.NewEncoder(s.w).Encode(r)
+	} else if r.Command != "" {
+		_, err = fmt.Fprintf(s.w, "command %s\n", r.Command)
+	} else if r.Location != nil {
+		_, err = fmt.Fprintf(s.w, "%s at %s\n", r.Response, r.Location)
+	} else {
+		_, err = fmt.Fprintf(s.w, "%s\n", r.Response)
+	}
+	if err != nil {
+		return fmt.Errorf("writing result: %w", err)
+	}
+	return nil
+}
+
+// Close does not close the underlying writer.
+func (s *writer) Close() error {
+	return nil
+}
@@ -0,0 +1,65 @@
+package edgeimpulse
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoSlot is returned, possibly wrapped, by ClassifySlot for a slot the
+// model does not have.
+var ErrNoSlot = errors.New("model has no such slot")
+
+// runnerClassifySlotRequest is a classify request for a model slot.
+type runnerClassifySlotRequest struct {
+	ID       int64     `json:"id"`
+	Classify []float64 `json:"classify"`
+	Slot     int       `json:"slot"`
+	Debug    bool      `json:"debug,omitempty"`
+}
+
+// Slots returns the parameters of the model slots of the process, for models
+// built with multiple impulses, e.g. a detector and a classifier of its
+// crops. The first slot is the model returned by ModelParameters. Regular
+// models have a single slot.
+func (r *RunnerProcess) Slots() []ModelParameters {
+	return r.state().slots
+}
+
+// ClassifySlot classifies data with the model in slot, an index into Slots.
+// Data must have the features of that slot. Slot 0 classifies like Classify.
+func (r *RunnerProcess) ClassifySlot(slot int, data []float64) (RunnerClassifyResponse, error) {
+	if slot == 0 {
+		return r.Classify(data)
+	}
+	m := r.state()
+	if slot < 0 || slot >= len(m.slots) {
+		return RunnerClassifyResponse{}, fmt.Errorf("%w: slot %d of %d", ErrNoSlot, slot, len(m.slots))
+	}
+	req := runnerClassifySlotRequest{
+		ID:       r.nextID(),
+		Classify: data,
+		Slot:     slot,
+		Debug:    m.debug,
+	}
+	return r.classify(req.ID, req)
+}
+
+// ClassifySlot is like Classify, for a model slot, see
+// RunnerProcess.ClassifySlot.
+func (p *RunnerPool) ClassifySlot(slot int, data []float64) (RunnerClassifyResponse, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if p.closed {
+		return RunnerClassifyResponse{}, errRunnerClosed
+	}
+	r := <-p.idle
+	defer func() {
+		p.idle <- r
+	}()
+	return r.ClassifySlot(slot, data)
+}
+
+// Slots returns the parameters of the model slots, see RunnerProcess.Slots.
+func (p *RunnerPool) Slots() []ModelParameters {
+	return p.runners[0].Slots()
+}
@@ -0,0 +1,201 @@
+package edgeimpulse
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram buckets grow exponentially, with 4 buckets per doubling, starting
+// at 1µs. The last bucket holds all larger values, from about 2 minutes.
+const histogramBuckets = 4*27 + 1
+
+// Histogram records durations, such as latencies, in fixed exponential buckets.
+// Percentiles are estimated with a relative error of at most about 19%.
+// A Histogram is safe for concurrent use. The zero value is ready for use.
+type Histogram struct {
+	mutex    sync.Mutex
+	buckets  [histogramBuckets]int64
+	count    int64
+	sum      time.Duration
+	min, max time.Duration
+}
+
+// HistogramSnapshot is a summary of a histogram at a point in time.
+type HistogramSnapshot struct {
+	Count int64         `json:"count"`
+	Min   time.Duration `json:"min"`
+	Max   time.Duration `json:"max"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+}
+
+func histogramBucket(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	i := int(math.Ceil(4 * math.Log2(us)))
+	if i >= histogramBuckets {
+		i = histogramBuckets - 1
+	}
+	return i
+}
+
+// CumulativeBuckets returns upper bounds at each doubling from 1µs to about a
+// minute, with the number of durations up to each bound, and the number and
+// sum of all durations, e.g. to export the histogram with package metrics.
+func (h *Histogram) CumulativeBuckets() (bounds []time.Duration, counts []int64, count int64, sum time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var n int64
+	// The last bucket has no upper bound.
+	for i, c := range h.buckets[:histogramBuckets-1] {
+		n += c
+		if i%4 == 0 {
+			bounds = append(bounds, histogramBound(i))
+			counts = append(counts, n)
+		}
+	}
+	return bounds, counts, h.count, h.sum
+}
+
+// histogramBound returns the upper bound of bucket i.
+func histogramBound(i int) time.Duration {
+	return time.Duration(math.Pow(2, float64(i)/4) * float64(time.Microsecond))
+}
+
+// Record adds a duration to the histogram.
+func (h *Histogram) Record(d time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.buckets[histogramBucket(d)]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Reset clears the histogram.
+func (h *Histogram) Reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.buckets = [histogramBuckets]int64{}
+	h.count = 0
+	h.sum = 0
+	h.min = 0
+	h.max = 0
+}
+
+// Snapshot returns a summary of the histogram.
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s := HistogramSnapshot{Count: h.count, Min: h.min, Max: h.max}
+	if h.count == 0 {
+		return s
+	}
+	s.Mean = h.sum / time.Duration(h.count)
+	s.P50 = h.percentile(0.5)
+	s.P90 = h.percentile(0.9)
+	s.P95 = h.percentile(0.95)
+	s.P99 = h.percentile(0.99)
+	return s
+}
+
+// percentile returns the upper bound of the bucket containing the p-th value,
+// clamped to the minimum and maximum seen. Must be called with lock held.
+func (h *Histogram) percentile(p float64) time.Duration {
+	n := int64(math.Ceil(p * float64(h.count)))
+	var seen int64
+	for i, c := range h.buckets {
+		seen += c
+		if seen >= n {
+			d := histogramBound(i)
+			if d > h.max {
+				d = h.max
+			}
+			if d < h.min {
+				d = h.min
+			}
+			return d
+		}
+	}
+	return h.max
+}
+
+// Stats keeps latency histograms for classifications, and a count of failed
+// classifications. Classifiers record the timing reported by the model, and the
+// end-to-end duration from receiving input to having a classification. Runners
+// record the duration of the request to the model as end-to-end duration.
+// Total.Count is the number of successful classifications. Stats is safe for
+// concurrent use.
+//
+// Stats implements expvar.Var, so it can be published directly:
+//
+//	expvar.Publish("classifier", classifier.Stats())
+type Stats struct {
+	errors int64 // Updated atomically. First for 64-bit alignment.
+
+	DSP            Histogram // Signal processing, as reported by the model.
+	Classification Histogram // Neural network, as reported by the model.
+	Anomaly        Histogram // Anomaly detection, as reported by the model.
+	Total          Histogram // End-to-end, as measured by the classifier.
+}
+
+// StatsSnapshot is a summary of Stats at a point in time.
+type StatsSnapshot struct {
+	DSP            HistogramSnapshot `json:"dsp"`
+	Classification HistogramSnapshot `json:"classification"`
+	Anomaly        HistogramSnapshot `json:"anomaly"`
+	Total          HistogramSnapshot `json:"total"`
+	Errors         int64             `json:"errors"`
+}
+
+// Add records the timing of resp and the end-to-end duration total.
+func (s *Stats) Add(resp RunnerClassifyResponse, total time.Duration) {
+	ms := func(v float64) time.Duration {
+		return time.Duration(v * float64(time.Millisecond))
+	}
+	s.DSP.Record(ms(resp.Timing.DSP))
+	s.Classification.Record(ms(resp.Timing.Classification))
+	s.Anomaly.Record(ms(resp.Timing.Anomaly))
+	s.Total.Record(total)
+}
+
+// AddError counts a failed classification.
+func (s *Stats) AddError() {
+	atomic.AddInt64(&s.errors, 1)
+}
+
+// Snapshot returns a summary of all histograms and the error count.
+func (s *Stats) Snapshot() StatsSnapshot {
+	return StatsSnapshot{s.DSP.Snapshot(), s.Classification.Snapshot(), s.Anomaly.Snapshot(), s.Total.Snapshot(), atomic.LoadInt64(&s.errors)}
+}
+
+// Reset clears all histograms and the error count.
+func (s *Stats) Reset() {
+	atomic.StoreInt64(&s.errors, 0)
+	s.DSP.Reset()
+	s.Classification.Reset()
+	s.Anomaly.Reset()
+	s.Total.Reset()
+}
+
+// String returns the snapshot as JSON, for expvar.
+func (s *Stats) String() string {
+	buf, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(buf)
+}
@@ -0,0 +1,51 @@
+package edgeimpulse_test
+
+import (
+	"testing"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestHistogram(t *testing.T) {
+	var h edgeimpulse.Histogram
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+	s := h.Snapshot()
+	if s.Count != 100 || s.Min != time.Millisecond || s.Max != 100*time.Millisecond {
+		t.Errorf("got %+v", s)
+	}
+	if s.Mean != 50500*time.Microsecond {
+		t.Errorf("got mean %v, expected 50.5ms", s.Mean)
+	}
+	within := func(name string, got, exp time.Duration) {
+		t.Helper()
+		if got < exp || float64(got) > 1.2*float64(exp) {
+			t.Errorf("got %s %v, expected about %v", name, got, exp)
+		}
+	}
+	within("p50", s.P50, 50*time.Millisecond)
+	within("p90", s.P90, 90*time.Millisecond)
+	within("p95", s.P95, 95*time.Millisecond)
+	within("p99", s.P99, 99*time.Millisecond)
+
+	h.Reset()
+	if s := h.Snapshot(); s.Count != 0 || s.P50 != 0 {
+		t.Errorf("after reset, got %+v", s)
+	}
+}
+
+func TestBenchmark(t *testing.T) {
+	r := &countingRunner{}
+	res, err := edgeimpulse.Benchmark(r, edgeimpulse.BenchmarkOpts{N: 20, Warmup: 2, Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Count != 20 || res.Stats.Total.Count != 20 || r.classified != 22 {
+		t.Errorf("got %d classifications, %d measured, %d classified", res.Count, res.Stats.Total.Count, r.classified)
+	}
+	if res.Throughput <= 0 {
+		t.Errorf("got throughput %v", res.Throughput)
+	}
+}
@@ -0,0 +1,154 @@
+// Package status implements an HTTP handler exposing the live state of a
+// classification pipeline for remote troubleshooting: model parameters, the
+// health of components like recorders and classifiers, the most recent
+// results, and the frame counters. Applications typically mount it under
+// /debug/edgeimpulse.
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+)
+
+// Status collects the state of a pipeline. Status is an http.Handler that
+// serves the state as JSON. It is safe for concurrent use.
+type Status struct {
+	mutex      sync.Mutex
+	runner     edgeimpulse.Runner
+	start      time.Time
+	components []component
+	results    []Result // Ring buffer.
+	next       int      // Index in results for the next result.
+	count      int64    // Total results recorded.
+	now        func() time.Time
+}
+
+type component struct {
+	name string
+	err  func() error
+}
+
+// Result is a recorded result of a classification, or an error.
+type Result struct {
+	Time     time.Time                           `json:"time"`
+	Response *edgeimpulse.RunnerClassifyResponse `json:"response,omitempty"`
+	Error    string                              `json:"error,omitempty"`
+}
+
+// Component is the health of a component, e.g. a recorder.
+type Component struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Snapshot is the state of a pipeline at a moment.
+type Snapshot struct {
+	Uptime          string                       `json:"uptime"`
+	ModelParameters *edgeimpulse.ModelParameters `json:"model_parameters,omitempty"`
+	Project         *edgeimpulse.Project         `json:"project,omitempty"`
+	Components      []Component                  `json:"components"`
+	ResultCount     int64                        `json:"result_count"`
+	Results         []Result                     `json:"results"` // Most recent last.
+	Counters        map[string]int64             `json:"counters"`
+	Goroutines      int                          `json:"goroutines"`
+}
+
+// New returns a status for a pipeline with runner, which may be nil, keeping
+// the last n results.
+func New(runner edgeimpulse.Runner, n int) *Status {
+	if n <= 0 {
+		n = 1
+	}
+	s := &Status{runner: runner, results: make([]Result, 0, n), now: time.Now}
+	s.start = s.now()
+	return s
+}
+
+// SetRunner sets the runner whose model parameters and project are reported,
+// e.g. when the handler is served before the model is started.
+func (s *Status) SetRunner(runner edgeimpulse.Runner) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.runner = runner
+}
+
+// AddComponent registers a component whose health is reported by err, e.g. the
+// Err method of a recorder or classifier. A component is healthy while err
+// returns nil.
+func (s *Status) AddComponent(name string, err func() error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.components = append(s.components, component{name, err})
+}
+
+// Record adds a successful classification result.
+func (s *Status) Record(resp edgeimpulse.RunnerClassifyResponse) {
+	s.add(Result{Time: s.now(), Response: &resp})
+}
+
+// RecordError adds a failed classification.
+func (s *Status) RecordError(err error) {
+	s.add(Result{Time: s.now(), Error: err.Error()})
+}
+
+func (s *Status) add(r Result) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count++
+	if len(s.results) < cap(s.results) {
+		s.results = append(s.results, r)
+		return
+	}
+	s.results[s.next] = r
+	s.next = (s.next + 1) % len(s.results)
+}
+
+// Snapshot returns the current state.
+func (s *Status) Snapshot() Snapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snap := Snapshot{
+		Uptime:      s.now().Sub(s.start).Round(time.Second).String(),
+		Components:  []Component{},
+		ResultCount: s.count,
+		Results:     append(append([]Result{}, s.results[s.next:]...), s.results[:s.next]...),
+		Counters:    map[string]int64{},
+		Goroutines:  runtime.NumGoroutine(),
+	}
+	if s.runner != nil {
+		mp := s.runner.ModelParameters()
+		p := s.runner.Project()
+		snap.ModelParameters = &mp
+		snap.Project = &p
+	}
+	for _, c := range s.components {
+		sc := Component{Name: c.name, OK: true}
+		if err := c.err(); err != nil {
+			sc.OK = false
+			sc.Error = err.Error()
+		}
+		snap.Components = append(snap.Components, sc)
+	}
+	for _, m := range metrics.Default.Snapshot() {
+		if m.Type == metrics.TypeCounter {
+			snap.Counters[m.Name] = m.Value
+		}
+	}
+	return snap
+}
+
+// ServeHTTP writes the snapshot as JSON.
+func (s *Status) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(s.Snapshot())
+}
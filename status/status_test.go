@@ -0,0 +1,44 @@
+package status_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/status"
+)
+
+func TestStatus(t *testing.T) {
+	s := status.New(nil, 2)
+	s.AddComponent("recorder", func() error { return nil })
+	s.AddComponent("classifier", func() error { return errors.New("broken") })
+
+	for _, score := range []float64{0.1, 0.2, 0.3} {
+		var resp edgeimpulse.RunnerClassifyResponse
+		resp.Result.Classification = map[string]float64{"yes": score}
+		s.Record(resp)
+	}
+	s.RecordError(errors.New("failed"))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/edgeimpulse", nil))
+	var snap status.Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("parsing response: %v", err)
+	}
+
+	if snap.ResultCount != 4 || len(snap.Results) != 2 {
+		t.Fatalf("got %d results, %d recent, expected 4 and 2", snap.ResultCount, len(snap.Results))
+	}
+	if r := snap.Results[0]; r.Response == nil || r.Response.Result.Classification["yes"] != 0.3 {
+		t.Fatalf("got oldest result %+v, expected score 0.3", r)
+	}
+	if r := snap.Results[1]; r.Error != "failed" {
+		t.Fatalf("got newest result %+v, expected error", r)
+	}
+	if len(snap.Components) != 2 || !snap.Components[0].OK || snap.Components[1].OK || snap.Components[1].Error != "broken" {
+		t.Fatalf("got components %+v", snap.Components)
+	}
+}
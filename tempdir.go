@@ -1,22 +1,45 @@
 package edgeimpulse
 
 import (
-	"io/ioutil"
 	"os"
+	"sync"
 )
 
-// TempDir returns either a temporary directory in /dev/shm (if it exists), or
-// otherwise in the OS default temporary directory.
+var tempRoot struct {
+	sync.Mutex
+	path string
+}
+
+// SetTempRoot sets the directory in which TempDir creates temporary
+// directories, for runners and recorders. If path is empty, the default is
+// restored: /dev/shm if it exists, otherwise the OS default temporary
+// directory. Useful on systems where /dev/shm is small or mounted noexec.
+func SetTempRoot(path string) {
+	tempRoot.Lock()
+	defer tempRoot.Unlock()
+	tempRoot.path = path
+}
+
+// TempDir returns a new temporary directory in the root set with SetTempRoot,
+// or by default either in /dev/shm (if it exists), or otherwise in the OS
+// default temporary directory.
 func TempDir() (string, error) {
+	tempRoot.Lock()
+	root := tempRoot.path
+	tempRoot.Unlock()
+	if root != "" {
+		return os.MkdirTemp(root, "edge-impulse-cli")
+	}
+
 	// Attempt to make temp dir for runner in /dev/shm. If that fails (eg
 	// no permission), then attempt at OS default temp dir.
 	// Check if /dev/shm exists first. Don't want to accidentially create a
 	// directory in /dev (if someones runs this as root).
 	if fi, err := os.Stat("/dev/shm"); err == nil && fi.IsDir() {
-		dir, err := ioutil.TempDir("/dev/shm", "edge-impulse-cli")
+		dir, err := os.MkdirTemp("/dev/shm", "edge-impulse-cli")
 		if err == nil {
 			return dir, nil
 		}
 	}
-	return ioutil.TempDir("", "edge-impulse-cli")
+	return os.MkdirTemp("", "edge-impulse-cli")
 }
@@ -0,0 +1,24 @@
+package edgeimpulse_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestSetTempRoot(t *testing.T) {
+	root := t.TempDir()
+	edgeimpulse.SetTempRoot(root)
+	defer edgeimpulse.SetTempRoot("")
+
+	dir, err := edgeimpulse.TempDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if filepath.Dir(dir) != root {
+		t.Errorf("got temp dir %s, expected in %s", dir, root)
+	}
+}
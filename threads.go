@@ -0,0 +1,66 @@
+package edgeimpulse
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ThreadsPerformanceCores as RunnerOpts.Threads runs the model with a thread
+// for each performance core, see PerformanceCores.
+const ThreadsPerformanceCores = -1
+
+// PerformanceCores returns the number of CPU cores with the highest maximum
+// frequency, e.g. the "big" cores of a big.LITTLE SoC. Engines typically
+// default to a thread per core, and threads on the slower cores then hold up
+// the faster ones. If the frequencies of cores are not available, e.g. on
+// other platforms than linux, the number of CPUs is returned.
+func PerformanceCores() int {
+	paths, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cpufreq/cpuinfo_max_freq")
+	var freqs []int
+	for _, p := range paths {
+		buf, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		if f, err := strconv.Atoi(strings.TrimSpace(string(buf))); err == nil {
+			freqs = append(freqs, f)
+		}
+	}
+	if n := fastest(freqs); n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}
+
+// fastest returns the number of the highest frequencies in freqs.
+func fastest(freqs []int) int {
+	var max, n int
+	for _, f := range freqs {
+		if f > max {
+			max, n = f, 1
+		} else if f == max {
+			n++
+		}
+	}
+	return n
+}
+
+// threadsEnv returns the number of threads from environment variable
+// OMP_NUM_THREADS in env, or else in the environment of this process, or 0 if
+// not set.
+func threadsEnv(env []string) int {
+	v, ok := os.LookupEnv("OMP_NUM_THREADS")
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "OMP_NUM_THREADS=") {
+			v, ok = strings.TrimPrefix(kv, "OMP_NUM_THREADS="), true
+		}
+	}
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(v)
+	return n
+}
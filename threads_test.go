@@ -0,0 +1,21 @@
+package edgeimpulse
+
+import (
+	"testing"
+)
+
+func TestFastest(t *testing.T) {
+	// E.g. an RK3399: 4 little cores at 1.4GHz, 2 big at 1.8GHz.
+	if n := fastest([]int{1416000, 1416000, 1416000, 1416000, 1800000, 1800000}); n != 2 {
+		t.Errorf("got %d, expected 2", n)
+	}
+	if n := fastest(nil); n != 0 {
+		t.Errorf("got %d for no frequencies, expected 0", n)
+	}
+}
+
+func TestThreadsEnv(t *testing.T) {
+	if n := threadsEnv([]string{"USE_GPU_INFERENCE=0", "OMP_NUM_THREADS=2"}); n != 2 {
+		t.Errorf("got %d, expected 2 from env", n)
+	}
+}
@@ -0,0 +1,336 @@
+package timeseries
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+	"github.com/edgeimpulse/linux-sdk-go/v2/internal/classifier"
+	"github.com/edgeimpulse/linux-sdk-go/v2/metrics"
+)
+
+// ClassifyEvent is the result of classifying one window of samples.
+type ClassifyEvent struct {
+	// If set, an error occurred and other fields are not meaningful.
+	Err error
+
+	// The classification response from the model. Always a successful response.
+	edgeimpulse.RunnerClassifyResponse
+
+	// How long classifying took.
+	Classifying time.Duration
+
+	// The features that were classified: the values of all axes of each
+	// sample in the window, interleaved, oldest sample first.
+	Features []float64
+
+	// Time of the last sample in the window.
+	Time time.Time
+
+	// Sequence number of the window of samples, starting at 1.
+	WindowID int64
+
+	// Context with the "eim.postprocess" tracing span of this window, for
+	// tracing post-processing. See ClassifierOpts.Tracer.
+	Context context.Context
+
+	trace *classifier.Trace
+}
+
+// ClassifierOpts are options for the classifier.
+type ClassifierOpts struct {
+	Verbose bool               // Print verbose logging.
+	Logger  edgeimpulse.Logger // Receives log messages. If nil, the standard logger is used, see edgeimpulse.DefaultLogger.
+	Tracer  edgeimpulse.Tracer // If set, spans are started for each window of samples, and its capture, classification and postprocessing.
+	Clock   edgeimpulse.Clock  // For measuring latencies. If nil, edgeimpulse.SystemClock is used.
+
+	// If OnResult or OnError is set, the classifier calls them for each
+	// event from a goroutine it manages, instead of sending events on
+	// Events, which must not be read. Handlers are called one at a time,
+	// and should return quickly; slow handlers cause input to be dropped.
+	// Errors without OnError are logged. Handlers must not call Close.
+	OnResult func(ev ClassifyEvent) // For successful classifications.
+	OnError  func(err error)        // For errors, e.g. reading input or from the model.
+}
+
+// ClassifierOption configures a classifier created with NewClassifier. A
+// *ClassifierOpts is also a ClassifierOption, and replaces all settings made
+// by earlier options.
+type ClassifierOption interface {
+	applyClassifier(o *ClassifierOpts)
+}
+
+type classifierOptionFunc func(o *ClassifierOpts)
+
+func (fn classifierOptionFunc) applyClassifier(o *ClassifierOpts) {
+	fn(o)
+}
+
+func (opts *ClassifierOpts) applyClassifier(o *ClassifierOpts) {
+	if opts != nil {
+		*o = *opts
+	}
+}
+
+// WithVerbose sets ClassifierOpts.Verbose.
+func WithVerbose(verbose bool) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Verbose = verbose })
+}
+
+// WithLogger sets ClassifierOpts.Logger.
+func WithLogger(logger edgeimpulse.Logger) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Logger = logger })
+}
+
+// WithTracer sets ClassifierOpts.Tracer.
+func WithTracer(tracer edgeimpulse.Tracer) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Tracer = tracer })
+}
+
+// WithClock sets ClassifierOpts.Clock.
+func WithClock(clock edgeimpulse.Clock) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.Clock = clock })
+}
+
+// WithOnResult sets ClassifierOpts.OnResult.
+func WithOnResult(fn func(ev ClassifyEvent)) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.OnResult = fn })
+}
+
+// WithOnError sets ClassifierOpts.OnError.
+func WithOnError(fn func(err error)) ClassifierOption {
+	return classifierOptionFunc(func(o *ClassifierOpts) { o.OnError = fn })
+}
+
+// Classifier continuously reads samples from a recorder, classifies windows of
+// samples, and sends the results on channel Events. Events is closed when the
+// classifier stops, after Close, canceling its context, or an error.
+type Classifier struct {
+	Events chan ClassifyEvent
+
+	loop *classifier.Loop
+}
+
+// window is a window of samples to classify, or an error reading samples.
+type window struct {
+	features []float64
+	time     time.Time
+	err      error
+}
+
+// NewClassifier reads samples from recorder, and classifies a window of
+// samples every interval, sending the results on its channel Events. The
+// window size follows from the model parameters: InputFeaturesCount values,
+// AxisCount per sample. If interval is 0, windows do not overlap.
+//
+// The axes of the recorder must match the axes, in order, that the model was
+// trained on. The recorder should sample at the frequency of the model.
+//
+// Callers must call Close on the classifier to clean it up, and separately
+// close the runner and recorder. Canceling ctx also stops the classifier.
+func NewClassifier(ctx context.Context, runner edgeimpulse.Runner, recorder Recorder, interval time.Duration, opts ...ClassifierOption) (*Classifier, error) {
+	var xopts ClassifierOpts
+	for _, o := range opts {
+		if o != nil {
+			o.applyClassifier(&xopts)
+		}
+	}
+	handlers := xopts.OnResult != nil || xopts.OnError != nil
+	loop := classifier.New(ctx, classifier.Opts{Verbose: xopts.Verbose, Logger: xopts.Logger, Tracer: xopts.Tracer, Clock: xopts.Clock, Handlers: handlers})
+	logger, clock := loop.Logger, loop.Clock
+
+	modelParams := runner.ModelParameters()
+	if modelParams.SensorType == edgeimpulse.SensorTypeCamera {
+		return nil, fmt.Errorf("sensor for this model was %q, expected time-series sensor", modelParams.SensorType)
+	}
+	axes := len(recorder.Axes())
+	if axes == 0 {
+		return nil, fmt.Errorf("recorder has no axes")
+	}
+	if modelParams.AxisCount > 0 && modelParams.AxisCount != axes {
+		return nil, fmt.Errorf("model expects %d axes, recorder has %d", modelParams.AxisCount, axes)
+	}
+	if modelParams.InputFeaturesCount == 0 || modelParams.InputFeaturesCount%axes != 0 {
+		return nil, fmt.Errorf("model input features count %d is not a multiple of %d axes", modelParams.InputFeaturesCount, axes)
+	}
+	windowSamples := modelParams.InputFeaturesCount / axes
+	if freq := recorder.Frequency(); modelParams.Frequency > 0 && freq != modelParams.Frequency {
+		logger.Logf(edgeimpulse.LogInfo, "recorder frequency %vHz differs from model frequency %vHz", freq, modelParams.Frequency)
+	}
+
+	// Number of new samples between classifications.
+	stride := windowSamples
+	if interval > 0 {
+		stride = int(interval.Seconds() * recorder.Frequency())
+		if stride < 1 {
+			stride = 1
+		}
+	}
+
+	c := &Classifier{
+		Events: make(chan ClassifyEvent, 1),
+		loop:   loop,
+	}
+
+	// One window can be pending while classifying, so windows are not lost
+	// to scheduling when the classifier keeps up, as with non-overlapping
+	// windows.
+	windows := make(chan window, 1)
+
+	// send delivers an event, returning false if the classifier was stopped
+	// instead. Only the classifying goroutine sends events, and closes Events
+	// when done.
+	send := func(ev ClassifyEvent) bool {
+		select {
+		case c.Events <- ev:
+			return true
+		case <-loop.Done():
+			return false
+		}
+	}
+
+	loop.Run(func() {
+		var windowID int64
+		for {
+			tr := loop.StartTrace("eim.window")
+			var w window
+			select {
+			case <-loop.Done():
+				tr.End()
+				return
+			case x, ok := <-windows:
+				if !ok {
+					tr.End()
+					return
+				}
+				w = x
+			}
+			if w.err != nil {
+				tr.Fail(w.err)
+				send(ClassifyEvent{Err: w.err})
+				return
+			}
+			windowID++
+			tr.SetAttributes(edgeimpulse.Attribute{Key: "eim.window.id", Value: windowID})
+			tr.Stage("eim.classify")
+			t0 := clock.Now()
+			resp, err := runner.Classify(w.features)
+			if err != nil {
+				loop.Stats.AddError()
+				tr.Fail(err)
+				send(ClassifyEvent{Err: err})
+				return
+			}
+			tr.StageAttributes(edgeimpulse.TimingAttributes(resp)...)
+			latency := clock.Now().Sub(t0)
+			loop.Stats.Add(resp, latency)
+			pctx := tr.Stage("eim.postprocess")
+			ev := ClassifyEvent{nil, resp, latency, w.features, w.time, windowID, pctx, tr}
+			if !loop.Deliver(tr, func() bool { return send(ev) }) {
+				return
+			}
+		}
+	}, func(err error) {
+		send(ClassifyEvent{Err: err})
+	}, func() {
+		close(c.Events)
+	})
+
+	sendErr := func(err error) {
+		select {
+		case windows <- window{err: err}:
+		case <-loop.Done():
+		}
+	}
+	loop.Go(func() {
+		// Features of the current window, oldest sample first.
+		features := make([]float64, modelParams.InputFeaturesCount)
+		count := 0 // Samples in features.
+		fresh := 0 // Samples since last window.
+		events := recorder.Events()
+		for {
+			var ev Event
+			select {
+			case <-loop.Done():
+				return
+			case x, ok := <-events:
+				if !ok {
+					sendErr(fmt.Errorf("recorder stopped"))
+					return
+				}
+				ev = x
+			}
+			if ev.Err != nil {
+				sendErr(fmt.Errorf("reading samples: %w", ev.Err))
+				return
+			}
+			if len(ev.Sample.Values) != axes {
+				sendErr(fmt.Errorf("sample has %d values, expected %d", len(ev.Sample.Values), axes))
+				return
+			}
+
+			if count == windowSamples {
+				copy(features, features[axes:])
+				count--
+			}
+			copy(features[count*axes:], ev.Sample.Values)
+			count++
+			fresh++
+			if count < windowSamples || fresh < stride {
+				continue
+			}
+			fresh = 0
+
+			// Copy features so we don't interfere with existing classifier.
+			f := make([]float64, len(features))
+			copy(f, features)
+			metrics.FramesCaptured.Inc()
+			select {
+			case windows <- window{features: f, time: ev.Sample.Time}:
+			default:
+				metrics.FramesDropped.Inc()
+				logger.Logf(edgeimpulse.LogDebug, "dropping samples, classifier still busy")
+			}
+		}
+	}, sendErr, func() {
+		// When we stop, also stop the classifier.
+		close(windows)
+	})
+
+	if handlers {
+		loop.Handle(func() {
+			for ev := range c.Events {
+				if ev.Err != nil {
+					loop.HandleError(ev.Err, xopts.OnError)
+					continue
+				}
+				if xopts.OnResult != nil {
+					xopts.OnResult(ev)
+				}
+				ev.trace.End()
+			}
+		})
+	}
+
+	return c, nil
+}
+
+// Stats returns latency statistics of the classifications so far.
+func (c *Classifier) Stats() *edgeimpulse.Stats {
+	return c.loop.Stats
+}
+
+// Close shuts down the classifier, waiting for a classification in progress to
+// finish, and for handlers to return. Events is closed when Close returns.
+// Close can be called multiple times, and does not require Events to be read.
+// Close does not close the runner or recorder.
+func (c *Classifier) Close() error {
+	return c.loop.Close()
+}
+
+// Err returns the error that stopped the classifier after recovering from a
+// panic, or nil. The error is also sent on Events.
+func (c *Classifier) Err() error {
+	return c.loop.Err()
+}
@@ -0,0 +1,223 @@
+package timeseries
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// FusionOpts are options for NewFusion.
+type FusionOpts struct {
+	// Samples per second of the fused recorder, typically the frequency of
+	// the model. Defaults to the highest frequency of the sources.
+	Frequency float64
+
+	// If set, the names of the axes of fused samples, in the order the
+	// model expects them, selected from the axes of the sources. Names must
+	// be unique among the axes of the sources. If empty, the axes of all
+	// sources are used, in order of the sources.
+	Axes []string
+
+	// For the time and rate of fused samples. If nil, edgeimpulse.SystemClock is used.
+	Clock edgeimpulse.Clock
+}
+
+// Fusion is a Recorder that combines the samples of multiple recorders, e.g.
+// an accelerometer and an environmental sensor, into a single stream for a
+// sensor fusion model. Sources with different frequencies are aligned by
+// resampling: at the frequency of the fusion, each fused sample holds the most
+// recent sample of each source.
+type Fusion struct {
+	sources   []Recorder
+	axes      []Axis
+	index     []int // For each axis, index in the concatenated values of the sources.
+	frequency float64
+	events    chan Event
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mutex  sync.Mutex
+	latest [][]float64 // Latest values per source, nil until the first sample.
+}
+
+// Ensure that Fusion implements the Recorder interface.
+var _ Recorder = (*Fusion)(nil)
+
+// NewFusion starts combining the samples of sources. No samples are sent
+// until each source has delivered a sample. An error from a source is sent
+// as event, after which the fusion stops.
+//
+// Callers must call Close, which also closes the sources.
+func NewFusion(ctx context.Context, sources []Recorder, opts *FusionOpts) (*Fusion, error) {
+	var xopts FusionOpts
+	if opts != nil {
+		xopts = *opts
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources to fuse")
+	}
+	clock := edgeimpulse.DefaultClock(xopts.Clock)
+
+	f := &Fusion{
+		sources:   sources,
+		frequency: xopts.Frequency,
+		done:      make(chan struct{}),
+		latest:    make([][]float64, len(sources)),
+	}
+
+	var all []Axis
+	for _, s := range sources {
+		all = append(all, s.Axes()...)
+		if f.frequency < s.Frequency() && xopts.Frequency <= 0 {
+			f.frequency = s.Frequency()
+		}
+	}
+	if f.frequency <= 0 {
+		return nil, fmt.Errorf("no frequency for fusion")
+	}
+	if len(xopts.Axes) == 0 {
+		f.axes = all
+		for i := range all {
+			f.index = append(f.index, i)
+		}
+	} else {
+		for _, name := range xopts.Axes {
+			index := -1
+			for i, a := range all {
+				if a.Name != name {
+					continue
+				}
+				if index >= 0 {
+					return nil, fmt.Errorf("axis %q present in multiple sources", name)
+				}
+				index = i
+			}
+			if index < 0 {
+				return nil, fmt.Errorf("no source with axis %q", name)
+			}
+			f.axes = append(f.axes, all[index])
+			f.index = append(f.index, index)
+		}
+	}
+
+	f.events = make(chan Event, int(f.frequency)+1)
+	ctx, cancel := context.WithCancel(ctx)
+	f.cancel = cancel
+
+	// Collect the latest sample of each source.
+	var wg sync.WaitGroup
+	errc := make(chan error, len(sources))
+	for i, s := range sources {
+		wg.Add(1)
+		go func(i int, events chan Event, naxes int) {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ev, ok := <-events:
+					if !ok {
+						errc <- fmt.Errorf("source %d stopped", i)
+						return
+					}
+					if ev.Err != nil {
+						errc <- fmt.Errorf("source %d: %w", i, ev.Err)
+						return
+					}
+					if len(ev.Sample.Values) != naxes {
+						errc <- fmt.Errorf("source %d: sample has %d values, expected %d", i, len(ev.Sample.Values), naxes)
+						return
+					}
+					f.mutex.Lock()
+					f.latest[i] = ev.Sample.Values
+					f.mutex.Unlock()
+				}
+			}
+		}(i, s.Events(), len(s.Axes()))
+	}
+
+	go func() {
+		defer close(f.done)
+		defer wg.Wait()
+		defer cancel()
+
+		ticker := clock.NewTicker(time.Duration(float64(time.Second) / f.frequency))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errc:
+				select {
+				case f.events <- Event{Err: err}:
+				case <-ctx.Done():
+				}
+				return
+			case <-ticker.C():
+			}
+			values, ok := f.fuse()
+			if !ok {
+				continue
+			}
+			select {
+			case f.events <- Event{Sample: Sample{Time: clock.Now(), Values: values}}:
+			default:
+				// Consumer is not keeping up, drop the sample.
+			}
+		}
+	}()
+
+	return f, nil
+}
+
+// fuse returns the fused values of the latest samples of the sources, or false
+// if not all sources have delivered a sample yet.
+func (f *Fusion) fuse() ([]float64, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var all []float64
+	for _, l := range f.latest {
+		if l == nil {
+			return nil, false
+		}
+		all = append(all, l...)
+	}
+	values := make([]float64, len(f.index))
+	for i, index := range f.index {
+		values[i] = all[index]
+	}
+	return values, true
+}
+
+// Axes returns the axes of the fused samples.
+func (f *Fusion) Axes() []Axis {
+	return f.axes
+}
+
+// Frequency returns the number of fused samples per second.
+func (f *Fusion) Frequency() float64 {
+	return f.frequency
+}
+
+// Events returns the channel on which fused samples are sent.
+func (f *Fusion) Events() chan Event {
+	return f.events
+}
+
+// Close stops the fusion and closes the sources, returning the first error
+// from closing a source.
+func (f *Fusion) Close() error {
+	f.cancel()
+	<-f.done
+	var err error
+	for _, s := range f.sources {
+		if xerr := s.Close(); xerr != nil && err == nil {
+			err = xerr
+		}
+	}
+	return err
+}
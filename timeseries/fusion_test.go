@@ -0,0 +1,60 @@
+package timeseries_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edgeimpulse/linux-sdk-go/v2/timeseries"
+)
+
+type recorder struct {
+	axes      []timeseries.Axis
+	frequency float64
+	events    chan timeseries.Event
+}
+
+func (r recorder) Axes() []timeseries.Axis       { return r.axes }
+func (r recorder) Frequency() float64            { return r.frequency }
+func (r recorder) Events() chan timeseries.Event { return r.events }
+func (r recorder) Close() error                  { return nil }
+
+func TestFusion(t *testing.T) {
+	acc := recorder{[]timeseries.Axis{{Name: "accX"}, {Name: "accY"}}, 100, make(chan timeseries.Event, 1)}
+	env := recorder{[]timeseries.Axis{{Name: "temp"}}, 1, make(chan timeseries.Event, 1)}
+	f, err := timeseries.NewFusion(context.Background(), []timeseries.Recorder{acc, env}, &timeseries.FusionOpts{
+		Axes: []string{"temp", "accY", "accX"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if f.Frequency() != 100 {
+		t.Errorf("got frequency %v, expected 100", f.Frequency())
+	}
+	if axes := f.Axes(); len(axes) != 3 || axes[0].Name != "temp" {
+		t.Errorf("got axes %v", axes)
+	}
+
+	acc.events <- timeseries.Event{Sample: timeseries.Sample{Values: []float64{1, 2}}}
+	env.events <- timeseries.Event{Sample: timeseries.Sample{Values: []float64{20}}}
+	select {
+	case ev := <-f.Events():
+		if ev.Err != nil {
+			t.Fatal(ev.Err)
+		}
+		exp := []float64{20, 2, 1}
+		for i, v := range ev.Sample.Values {
+			if v != exp[i] {
+				t.Fatalf("got values %v, expected %v", ev.Sample.Values, exp)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for sample")
+	}
+
+	if _, err := timeseries.NewFusion(context.Background(), []timeseries.Recorder{acc, env}, &timeseries.FusionOpts{Axes: []string{"gyrX"}}); err == nil {
+		t.Errorf("expected error for unknown axis")
+	}
+}
@@ -0,0 +1,46 @@
+// Package timeseries implements recording multi-axis sensor data, like
+// accelerometers and gyroscopes, and classifying windows of samples.
+package timeseries
+
+import (
+	"time"
+)
+
+// Axis describes one value in each sample of a recorder, e.g. the x-axis of an
+// accelerometer.
+type Axis struct {
+	Name  string // E.g. "accX".
+	Units string // E.g. "m/s2".
+}
+
+// Sample is a reading of all axes of a recorder at a point in time.
+type Sample struct {
+	Time   time.Time
+	Values []float64 // One per axis, in the order of Recorder.Axes.
+}
+
+// Event is a single sample (or error) coming from a Recorder.
+type Event struct {
+	// If not nil, an error occurred.
+	Err error
+
+	// Sample read from the recorder. If Err is set, Sample is not valid.
+	Sample Sample
+}
+
+// Recorder is a source of multi-axis samples at a fixed frequency, for example
+// an accelerometer.
+type Recorder interface {
+	// Axes returns the axes of the samples.
+	Axes() []Axis
+
+	// Frequency returns the number of samples per second.
+	Frequency() float64
+
+	// Events returns a channel from which Events can be read, each
+	// containing a sample.
+	Events() chan Event
+
+	// Close shuts down the recorder. No further Events will be sent.
+	Close() error
+}
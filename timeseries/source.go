@@ -0,0 +1,63 @@
+package timeseries
+
+import (
+	"context"
+	"io"
+	"time"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+// Source is an edgeimpulse.PipelineSource classifying the samples of a
+// recorder with a Classifier. Results have the []float64 features of the
+// window as input, and the time of its last sample.
+type Source struct {
+	recorder Recorder
+	interval time.Duration
+	opts     []ClassifierOption
+}
+
+// Ensure Source implements interface edgeimpulse.PipelineSource.
+var _ edgeimpulse.PipelineSource = (*Source)(nil)
+
+// NewSource returns a source for recorder, classifying every interval with
+// opts, as for NewClassifier. Opts must not set OnResult or OnError. The
+// recorder is closed with the source.
+func NewSource(recorder Recorder, interval time.Duration, opts ...ClassifierOption) *Source {
+	return &Source{recorder, interval, opts}
+}
+
+// Start starts a classifier with runner.
+func (s *Source) Start(ctx context.Context, runner edgeimpulse.Runner) (<-chan edgeimpulse.PipelineResult, io.Closer, error) {
+	c, err := NewClassifier(ctx, runner, s.recorder, s.interval, s.opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make(chan edgeimpulse.PipelineResult)
+	go func() {
+		defer close(results)
+		for ev := range c.Events {
+			r := edgeimpulse.PipelineResult{Err: ev.Err, RunnerClassifyResponse: ev.RunnerClassifyResponse, Time: ev.Time, Input: ev.Features}
+			select {
+			case results <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, sourceCloser{s.recorder, c}, nil
+}
+
+// sourceCloser stops capture before the classifier.
+type sourceCloser struct {
+	recorder   Recorder
+	classifier *Classifier
+}
+
+func (c sourceCloser) Close() error {
+	err := c.recorder.Close()
+	if xerr := c.classifier.Close(); xerr != nil && err == nil {
+		err = xerr
+	}
+	return err
+}
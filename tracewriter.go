@@ -0,0 +1,74 @@
+package edgeimpulse
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// TraceWriter stores traces of classifications, such as the JSON requests to
+// and responses from the model process written by a runner, e.g.
+// "runner-1-request.json", and the images sent by the image classifier, e.g.
+// "image-1.png". Implementations can keep traces in memory, add them to a
+// compressed archive, or upload them. Traces may be created from multiple
+// goroutines at the same time.
+type TraceWriter interface {
+	// CreateTrace returns a writer for the trace named name. The trace is
+	// complete when the writer is closed.
+	CreateTrace(name string) (io.WriteCloser, error)
+}
+
+// DirTraceWriter is a TraceWriter that writes each trace to a file in the
+// directory, the default used for the TraceDir options. Files of earlier
+// traces with the same name are overwritten.
+type DirTraceWriter string
+
+// CreateTrace creates the file name in the directory.
+func (d DirTraceWriter) CreateTrace(name string) (io.WriteCloser, error) {
+	return os.Create(filepath.Join(string(d), name))
+}
+
+// DefaultTraceWriter returns tw if it is not nil. Otherwise it returns a
+// DirTraceWriter for dir if dir is not empty, or else nil.
+func DefaultTraceWriter(tw TraceWriter, dir string) TraceWriter {
+	if tw != nil {
+		return tw
+	}
+	if dir != "" {
+		return DirTraceWriter(dir)
+	}
+	return nil
+}
+
+// WriteTrace writes trace name with write to tw, logging the trace or errors
+// to logger. It does nothing if tw is nil.
+func WriteTrace(tw TraceWriter, logger Logger, name string, write func(w io.Writer) error) {
+	if tw == nil {
+		return
+	}
+	path := name
+	if d, ok := tw.(DirTraceWriter); ok {
+		path = filepath.Join(string(d), name)
+	}
+	if err := writeTrace(tw, name, write); err != nil {
+		logger.Logf(LogError, "trace %s: %v", path, err)
+		return
+	}
+	logger.Logf(LogInfo, "trace %s", path)
+}
+
+func writeTrace(tw TraceWriter, name string, write func(w io.Writer) error) error {
+	w, err := tw.CreateTrace(name)
+	if err != nil {
+		return fmt.Errorf("creating: %w", err)
+	}
+	if err := write(w); err != nil {
+		w.Close()
+		return fmt.Errorf("writing: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("closing: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package edgeimpulse
+
+import (
+	"context"
+)
+
+// Tracer starts spans around the stages of classification, e.g. preprocessing
+// and classifying a frame. The interface has the shape of the OpenTelemetry
+// tracing API, so an adapter is a few lines, without this module depending on
+// OpenTelemetry:
+//
+//	type otelTracer struct{ t trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string, attrs ...edgeimpulse.Attribute) (context.Context, edgeimpulse.Span) {
+//		ctx, span := t.t.Start(ctx, name)
+//		s := otelSpan{span}
+//		s.SetAttributes(attrs...)
+//		return ctx, s
+//	}
+//
+// Classifiers start a span per frame or window of samples, "eim.frame" or
+// "eim.window", with the ID as attribute, and child spans for its stages:
+// "eim.capture" while waiting for the input, "eim.preprocess" for images,
+// "eim.classify", and "eim.postprocess" until the ClassifyEvent is delivered:
+// until the handler returns, or else until the event is sent on the channel.
+// The context of the postprocess span is passed in ClassifyEvents, for tracing
+// post-processing in the application.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a single traced operation, started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key/value pair annotating a span.
+type Attribute struct {
+	Key   string
+	Value interface{} // E.g. string, int64, float64 or bool.
+}
+
+type nopTracer struct{}
+
+func (nopTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	return ctx, nopSpan{}
+}
+
+type nopSpan struct{}
+
+func (nopSpan) SetAttributes(attrs ...Attribute) {}
+func (nopSpan) RecordError(err error)            {}
+func (nopSpan) End()                             {}
+
+// DefaultTracer returns t if it is not nil, and otherwise a tracer that does
+// nothing.
+func DefaultTracer(t Tracer) Tracer {
+	if t != nil {
+		return t
+	}
+	return nopTracer{}
+}
+
+// TimingAttributes returns the timing reported by the model in resp as
+// attributes, in milliseconds.
+func TimingAttributes(resp RunnerClassifyResponse) []Attribute {
+	return []Attribute{
+		{"eim.timing.dsp_ms", resp.Timing.DSP},
+		{"eim.timing.classification_ms", resp.Timing.Classification},
+		{"eim.timing.anomaly_ms", resp.Timing.Anomaly},
+	}
+}
@@ -0,0 +1,105 @@
+package edgeimpulse
+
+import (
+	"fmt"
+)
+
+// Vote is the outcome of a MajorityVote.
+type Vote struct {
+	Label   string // Label with the most votes. Ties are won by the label with the most recent vote.
+	Support int    // Number of votes for Label.
+	Total   int    // Number of votes in the window.
+}
+
+// Majority returns whether Label has more than half of the votes in the window.
+func (v Vote) Majority() bool {
+	return v.Support*2 > v.Total
+}
+
+// MajorityVote keeps the top label of the last classifications, and returns
+// the label occurring most often. This is an alternative to a moving average
+// filter for models whose scores are noisy but whose top label is stable.
+type MajorityVote struct {
+	votes []string // Ring buffer.
+	index int
+	count int // Number of votes, at most len(votes).
+}
+
+// NewMajorityVote returns a new majority vote over a window of size
+// classifications.
+func NewMajorityVote(size int) (*MajorityVote, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be > 0")
+	}
+	return &MajorityVote{votes: make([]string, size)}, nil
+}
+
+// Update adds the label with the highest score in classification as vote, and
+// returns the vote over the window. Until the window is full, the vote is over
+// the classifications seen so far.
+func (m *MajorityVote) Update(classification map[string]float64) Vote {
+	var label string
+	score := -1.0
+	for l, v := range classification {
+		if v > score || v == score && l < label {
+			label, score = l, v
+		}
+	}
+	return m.Add(label)
+}
+
+// UpdateResponse is like Update, but for a classify response. For object
+// detection models, the label of the bounding box with the highest score is
+// the vote, or the empty label if nothing was detected.
+func (m *MajorityVote) UpdateResponse(resp RunnerClassifyResponse) Vote {
+	if resp.Result.Classification != nil {
+		return m.Update(resp.Result.Classification)
+	}
+	scores := map[string]float64{}
+	for _, b := range resp.Result.BoundingBoxes {
+		if b.Value > scores[b.Label] {
+			scores[b.Label] = b.Value
+		}
+	}
+	return m.Update(scores)
+}
+
+// Add adds a vote for label, and returns the vote over the window.
+func (m *MajorityVote) Add(label string) Vote {
+	m.votes[m.index] = label
+	m.index = (m.index + 1) % len(m.votes)
+	if m.count < len(m.votes) {
+		m.count++
+	}
+
+	counts := m.Counts()
+	v := Vote{Total: m.count}
+	// Walk from most recent to oldest, so ties go to the label with the most
+	// recent vote.
+	for i := 0; i < m.count; i++ {
+		l := m.votes[(m.index-1-i+2*len(m.votes))%len(m.votes)]
+		if counts[l] > v.Support {
+			v.Label = l
+			v.Support = counts[l]
+		}
+	}
+	return v
+}
+
+// Counts returns the number of votes for each label in the window.
+func (m *MajorityVote) Counts() map[string]int {
+	counts := map[string]int{}
+	for i := 0; i < m.count; i++ {
+		counts[m.votes[(m.index-1-i+2*len(m.votes))%len(m.votes)]]++
+	}
+	return counts
+}
+
+// Reset clears the window.
+func (m *MajorityVote) Reset() {
+	for i := range m.votes {
+		m.votes[i] = ""
+	}
+	m.index = 0
+	m.count = 0
+}
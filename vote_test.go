@@ -0,0 +1,41 @@
+package edgeimpulse_test
+
+import (
+	"testing"
+
+	edgeimpulse "github.com/edgeimpulse/linux-sdk-go/v2"
+)
+
+func TestMajorityVote(t *testing.T) {
+	m, err := edgeimpulse.NewMajorityVote(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(v, exp edgeimpulse.Vote) {
+		t.Helper()
+		if v != exp {
+			t.Errorf("got %+v, expected %+v", v, exp)
+		}
+	}
+	check(m.Update(map[string]float64{"yes": 0.6, "no": 0.4}), edgeimpulse.Vote{"yes", 1, 1})
+	check(m.Update(map[string]float64{"yes": 0.3, "no": 0.7}), edgeimpulse.Vote{"no", 1, 2})
+	check(m.Update(map[string]float64{"yes": 0.9, "no": 0.1}), edgeimpulse.Vote{"yes", 2, 3})
+	// Oldest "yes" drops out of the window.
+	check(m.Update(map[string]float64{"yes": 0.2, "no": 0.8}), edgeimpulse.Vote{"no", 2, 3})
+	if !(edgeimpulse.Vote{"no", 2, 3}).Majority() {
+		t.Errorf("2 of 3 is not a majority")
+	}
+
+	m.Reset()
+	check(m.Add("yes"), edgeimpulse.Vote{"yes", 1, 1})
+
+	// Ties go to the label with the most recent vote.
+	m, err = edgeimpulse.NewMajorityVote(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(m.Add("a"), edgeimpulse.Vote{"a", 1, 1})
+	check(m.Add("b"), edgeimpulse.Vote{"b", 1, 2})
+	check(m.Add("b"), edgeimpulse.Vote{"b", 2, 3})
+	check(m.Add("a"), edgeimpulse.Vote{"a", 2, 4})
+}